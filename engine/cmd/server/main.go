@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/handlers"
+	"health-dashboard-backend/internal/logger"
+	"health-dashboard-backend/internal/metrics"
+	"health-dashboard-backend/internal/middleware"
+	"health-dashboard-backend/internal/services"
+	"health-dashboard-backend/internal/storage"
+	"health-dashboard-backend/internal/vectordb"
+)
+
+func main() {
+	// Load configuration first
+	cfg, err := config.Load()
+	if err != nil {
+		// Use basic fmt.Printf for config loading errors since logger isn't ready yet
+		panic("Failed to load configuration: " + err.Error())
+	}
+	if err := cfg.Validate(); err != nil {
+		// One problem per line, so every missing setting is visible at once
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	// Initialize configurable logger based on LOG_MODE
+	customLogger, err := logger.NewLogger(logger.LogMode(cfg.LogMode), logger.FileOptions{
+		Path:       cfg.LogFilePath,
+		MaxSizeMB:  cfg.LogMaxSizeMB,
+		MaxBackups: cfg.LogMaxBackups,
+		MaxAgeDays: cfg.LogMaxAgeDays,
+	})
+	if err != nil {
+		panic("Failed to initialize logger: " + err.Error())
+	}
+	defer customLogger.Close()
+
+	// Get the underlying zap logger for compatibility with existing code
+	zapLogger := customLogger.GetZapLogger()
+
+	// Log the current logging mode for visibility
+	switch logger.LogMode(cfg.LogMode) {
+	case logger.ModePrint:
+		customLogger.Print("🖨️  Logger initialized in PRINT mode - logs will be displayed in console")
+	case logger.ModeWrite:
+		customLogger.Print("📝 Logger initialized in WRITE mode - logs will be written to logs.json")
+	case logger.ModeNone:
+		customLogger.Print("🚫 Logger initialized in NONE mode - logging is disabled")
+	}
+
+	// Initialize Clerk
+	middleware.InitClerk(cfg.ClerkSecretKey)
+
+	// Initialize AWS services
+	dynamoClient, err := database.NewDynamoDBClient(cfg)
+	if err != nil {
+		zapLogger.Fatal("Failed to initialize DynamoDB client", zap.Error(err))
+	}
+
+	s3Client, err := storage.NewS3Client(cfg)
+	if err != nil {
+		zapLogger.Fatal("Failed to initialize S3 client", zap.Error(err))
+	}
+
+	// Initialize Pinecone
+	pineconeClient, err := vectordb.NewPineconeClient(cfg)
+	if err != nil {
+		zapLogger.Fatal("Failed to initialize Pinecone client", zap.Error(err))
+	}
+	pineconeClient.SetLogger(zapLogger)
+
+	// Catch an index built for a different embedding model before the first upsert fails
+	if dimension, known := services.ExpectedEmbeddingDimension(cfg); known {
+		validateCtx, cancelValidate := context.WithTimeout(context.Background(), 30*time.Second)
+		err := pineconeClient.ValidateIndexConfiguration(validateCtx, dimension)
+		cancelValidate()
+		if errors.Is(err, vectordb.ErrIndexDimensionMismatch) {
+			zapLogger.Fatal("Pinecone index doesn't match the embedding model", zap.Error(err))
+		} else if err != nil {
+			zapLogger.Warn("Failed to validate Pinecone index configuration", zap.Error(err))
+		}
+	} else {
+		zapLogger.Warn("Unknown embedding model dimension, skipping Pinecone index validation",
+			zap.String("embedding_model", cfg.EmbeddingModel))
+	}
+
+	// Initialize AI clients using factory
+	aiFactory := services.NewAIClientFactory(cfg)
+
+	llmClient, err := aiFactory.CreateLLMClient()
+	if err != nil {
+		zapLogger.Fatal("Failed to initialize LLM client", zap.Error(err))
+	}
+
+	embeddingClient, err := aiFactory.CreateEmbeddingClient()
+	if err != nil {
+		zapLogger.Fatal("Failed to initialize embedding client", zap.Error(err))
+	}
+
+	// Initialize services
+	healthService := services.NewHealthService(dynamoClient, cfg)
+	healthService.SetLogger(zapLogger)
+	ragService := services.NewRAGService(pineconeClient, llmClient, embeddingClient, cfg)
+	ragService.SetLogger(zapLogger)
+	documentService := services.NewDocumentService(s3Client, dynamoClient, ragService, cfg)
+	documentService.SetLogger(zapLogger)
+	ragService.SetChunkContentFetcher(documentService)
+	ragService.SetDocumentLookup(dynamoClient)
+	insightsCache := services.NewInsightsCache(dynamoClient, cfg)
+	aiAgent := services.NewAIAgent(healthService, ragService, llmClient, insightsCache, cfg, zapLogger)
+	authService := services.NewAuthService(zapLogger)
+	middleware.SetRoleLookup(authService.GetUserRoles)
+	aiAgent.SetUserProfileLookup(authService)
+	chatService := services.NewChatService(dynamoClient, cfg)
+	aiAgent.SetConversationHistory(chatService)
+	diagnosticsService := services.NewDiagnosticsService(dynamoClient, s3Client, pineconeClient, llmClient, embeddingClient, cfg)
+	diagnosticsService.SetQueueStatsSource(documentService)
+
+	// Initialize handlers
+	healthHandler := handlers.NewHealthHandler(healthService, zapLogger)
+	documentHandler := handlers.NewDocumentHandler(documentService, ragService, zapLogger)
+	chatHandler := handlers.NewChatHandler(aiAgent, chatService, zapLogger)
+	chatHandler.SetAllowedOrigins(cfg.CORSAllowedOrigins, cfg.CORSAllowAllOrigins)
+	// POST /api/chat and WebSocket chat messages share one allowance per user
+	chatRateLimiter := middleware.NewRateLimiter(cfg)
+	chatHandler.SetRateLimiter(chatRateLimiter)
+	sessionStore, err := services.NewSessionStore(cfg, dynamoClient)
+	if err != nil {
+		zapLogger.Fatal("Failed to initialize WebSocket session store", zap.Error(err))
+	}
+	chatHandler.SetSessionStore(sessionStore)
+	chatHandler.SetStreamCoalesceInterval(time.Duration(cfg.StreamCoalesceMs) * time.Millisecond)
+	chatHandler.SetWebSocketTimeouts(
+		time.Duration(cfg.WSPingIntervalSeconds)*time.Second,
+		time.Duration(cfg.WSIdleTimeoutMinutes)*time.Minute)
+	dashboardHandler := handlers.NewDashboardHandler(healthService, aiAgent, zapLogger)
+	authHandler := handlers.NewAuthHandler(authService, zapLogger)
+	adminHandler := handlers.NewAdminHandler(diagnosticsService, chatService, zapLogger)
+	readinessHandler := handlers.NewReadinessHandler(diagnosticsService, zapLogger)
+
+	// Setup Gin router
+	if cfg.Environment == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	router := gin.New()
+	router.Use(middleware.RequestLogger(zapLogger))
+	router.Use(middleware.Prometheus())
+	router.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowAllOrigins:  cfg.CORSAllowAllOrigins,
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "accept", "origin", "Cache-Control", "X-Requested-With"},
+		ExposedHeaders:   []string{"Content-Length", "Access-Control-Allow-Origin", "Access-Control-Allow-Headers", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           cfg.CORSMaxAge,
+	}))
+	router.Use(gin.Recovery())
+
+	// Health check endpoint
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+	})
+	router.GET("/health/ready", readinessHandler.Ready)
+
+	// Prometheus scrape endpoint
+	router.GET("/metrics", metrics.Handler())
+
+	// API routes
+	api := router.Group("/api")
+	{
+		// Auth routes (with optional auth for checking status)
+		auth := api.Group("/auth")
+		auth.Use(middleware.ClerkAuthWithTestMode(cfg))
+		{
+			auth.GET("/check", authHandler.CheckAuth)
+			auth.GET("/me", middleware.RequireAuthWithTestMode(cfg), authHandler.GetCurrentUser)
+			auth.PUT("/profile", middleware.RequireAuthWithTestMode(cfg), authHandler.UpdateProfile)
+			auth.GET("/roles", middleware.RequireAuthWithTestMode(cfg), authHandler.GetUserRoles)
+			auth.PUT("/roles", middleware.RequireAuthWithTestMode(cfg), middleware.RequireRole("admin"), authHandler.UpdateUserRoles)
+		}
+
+		// Health data endpoints
+		healthRoutes := api.Group("/health")
+		healthRoutes.Use(middleware.RequireAuthWithTestMode(cfg))
+		{
+			healthRoutes.POST("/metrics", healthHandler.AddHealthData)
+			healthRoutes.POST("/metrics/import", healthHandler.ImportMetricsCSV)
+			healthRoutes.POST("/metrics/custom", healthHandler.RegisterCustomMetric)
+			healthRoutes.POST("/metrics/composite", healthHandler.AddCompositeHealthData)
+			healthRoutes.GET("/metrics/:type", healthHandler.GetMetricHistory)
+			healthRoutes.GET("/latest", healthHandler.GetLatestMetrics)
+			healthRoutes.GET("/summary", middleware.RequireUnscopedMetricAccess(), healthHandler.GetHealthSummary)
+			healthRoutes.GET("/trends", healthHandler.GetHealthTrends)
+			healthRoutes.GET("/export", healthHandler.ExportMetrics)
+			healthRoutes.GET("/supported-metrics", healthHandler.GetSupportedMetrics)
+			healthRoutes.POST("/validate", healthHandler.ValidateHealthInput)
+			healthRoutes.DELETE("/metrics/:type/:timestamp", healthHandler.DeleteHealthData)
+			healthRoutes.POST("/goals", healthHandler.CreateGoal)
+			healthRoutes.GET("/goals", healthHandler.GetGoals)
+			healthRoutes.DELETE("/goals/:id", healthHandler.DeleteGoal)
+			healthRoutes.GET("/ranges", healthHandler.GetRangeBands)
+			healthRoutes.GET("/cardio-risk", middleware.RequireUnscopedMetricAccess(), healthHandler.GetCardioRisk)
+			healthRoutes.GET("/profile/ranges", healthHandler.GetCustomRanges)
+			healthRoutes.PUT("/profile/ranges/:type", healthHandler.SetCustomRange)
+			healthRoutes.DELETE("/profile/ranges/:type", healthHandler.DeleteCustomRange)
+		}
+
+		// Document endpoints
+		documentRoutes := api.Group("/documents")
+		// Documents hold lab results and feed answers from every metric, so scoped credentials are refused
+		documentRoutes.Use(middleware.RequireAuthWithTestMode(cfg), middleware.RequireUnscopedMetricAccess())
+		{
+			documentRoutes.POST("/upload", middleware.RateLimit(cfg), documentHandler.UploadDocument)
+			documentRoutes.GET("", documentHandler.ListDocuments)
+			documentRoutes.GET("/tags", documentHandler.GetDocumentTags)
+			documentRoutes.GET("/:id", documentHandler.GetDocument)
+			documentRoutes.PATCH("/:id", documentHandler.UpdateDocument)
+			documentRoutes.GET("/:id/status", documentHandler.GetDocumentStatus)
+			documentRoutes.GET("/:id/view", documentHandler.GetDocumentViewURL)
+			documentRoutes.GET("/:id/access-log", documentHandler.GetDocumentAccessLog)
+			documentRoutes.GET("/:id/reference-ranges", documentHandler.GetReferenceRanges)
+			documentRoutes.GET("/:id/chunks", documentHandler.GetDocumentChunks)
+			documentRoutes.POST("/:id/process", documentHandler.ProcessDocument)
+			documentRoutes.POST("/:id/retry", documentHandler.RetryProcessDocument)
+			documentRoutes.POST("/:id/archive", documentHandler.ArchiveDocument)
+			documentRoutes.POST("/:id/unarchive", documentHandler.UnarchiveDocument)
+			documentRoutes.POST("/query", documentHandler.QueryDocuments)
+			documentRoutes.POST("/reprocess", middleware.RequireRole("admin"), documentHandler.ReprocessDocuments)
+			documentRoutes.DELETE("/:id", documentHandler.DeleteDocument)
+			documentRoutes.GET("/search", documentHandler.SearchDocuments)
+		}
+
+		// Chat endpoints
+		chatRoutes := api.Group("/chat")
+		chatRoutes.Use(middleware.RequireAuthWithTestMode(cfg), middleware.RequireUnscopedMetricAccess())
+		{
+			chatRoutes.POST("", chatRateLimiter.Middleware(), chatHandler.ProcessQuery)
+			chatRoutes.POST("/analyze", chatHandler.AnalyzeQuery)
+			chatRoutes.GET("/history", chatHandler.GetChatHistory)
+			chatRoutes.DELETE("/history", chatHandler.DeleteHistory)
+			chatRoutes.GET("/messages/:id/sources", chatHandler.GetMessageSources)
+			chatRoutes.GET("/messages/:id/prompt", chatHandler.GetMessagePrompt)
+			chatRoutes.GET("/sessions/active", chatHandler.GetActiveSessions)
+			chatRoutes.DELETE("/sessions/:id", chatHandler.CloseSession)
+			chatRoutes.DELETE("/sessions/:id/history", chatHandler.DeleteSession)
+		}
+
+		// Dashboard endpoints
+		dashboardRoutes := api.Group("/dashboard")
+		dashboardRoutes.Use(middleware.RequireAuthWithTestMode(cfg), middleware.RequireUnscopedMetricAccess())
+		{
+			dashboardRoutes.GET("/summary", dashboardHandler.GetSummary)
+			dashboardRoutes.GET("/trends", dashboardHandler.GetTrends)
+			dashboardRoutes.GET("/overview", dashboardHandler.GetOverview)
+			dashboardRoutes.GET("/insights", dashboardHandler.GetInsights)
+		}
+
+		// Admin endpoints
+		adminRoutes := api.Group("/admin")
+		adminRoutes.Use(middleware.RequireAuthWithTestMode(cfg), middleware.RequireRole("admin"))
+		{
+			adminRoutes.GET("/diagnostics", adminHandler.GetDiagnostics)
+			adminRoutes.GET("/chat/messages/:id/prompt", adminHandler.GetMessagePrompt)
+		}
+	}
+
+	// WebSocket for real-time chat (updated to use Clerk auth with test mode support)
+	if cfg.TestMode {
+		// In test mode, use simplified auth for WebSocket
+		router.GET("/ws/chat", middleware.TestAuth(cfg), chatHandler.HandleWebSocket)
+	} else {
+		// In normal mode, use Clerk auth for WebSocket
+		router.GET("/ws/chat", middleware.AuthWebSocket(), middleware.RequireUnscopedMetricAccess(), chatHandler.HandleWebSocket)
+	}
+
+	// Create HTTP server
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: router,
+	}
+
+	// Start server in goroutine
+	go func() {
+		if cfg.TestMode {
+			zapLogger.Warn("Starting server in TEST MODE - authentication bypassed, userID set to 'test'",
+				zap.String("port", cfg.Port),
+				zap.String("environment", cfg.Environment))
+		} else {
+			zapLogger.Info("Starting server with Clerk authentication",
+				zap.String("port", cfg.Port),
+				zap.String("environment", cfg.Environment))
+		}
+
+		var err error
+		if cfg.TLSEnabled {
+			if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+				zapLogger.Fatal("TLS enabled but certificate or key file not specified",
+					zap.String("cert_file", cfg.TLSCertFile),
+					zap.String("key_file", cfg.TLSKeyFile))
+			}
+			zapLogger.Info("Starting HTTPS server with TLS",
+				zap.String("port", cfg.Port),
+				zap.String("cert_file", cfg.TLSCertFile),
+				zap.String("key_file", cfg.TLSKeyFile))
+			err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			zapLogger.Info("Starting HTTP server",
+				zap.String("port", cfg.Port))
+			err = srv.ListenAndServe()
+		}
+
+		if err != nil && err != http.ErrServerClosed {
+			zapLogger.Fatal("Failed to start server", zap.Error(err))
+		}
+	}()
+
+	// Wait for interrupt signal for graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	zapLogger.Info("Shutting down server...")
+
+	// Give outstanding requests 30 seconds to complete
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Hijacked WebSocket connections aren't closed by srv.Shutdown, so say goodbye to them first
+	if err := chatHandler.Shutdown(ctx); err != nil {
+		zapLogger.Error("WebSocket sessions did not close before shutdown", zap.Error(err))
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		zapLogger.Fatal("Server forced to shutdown", zap.Error(err))
+	}
+
+	// Let documents already being processed finish within the same deadline
+	if err := documentService.Shutdown(ctx); err != nil {
+		zapLogger.Error("Document processing did not finish before shutdown", zap.Error(err))
+	}
+
+	if err := pineconeClient.Close(); err != nil {
+		zapLogger.Warn("Failed to close Pinecone connection", zap.Error(err))
+	}
+
+	zapLogger.Info("Server exited")
+}
@@ -39,7 +39,7 @@ func main() {
 		}
 
 		ctx := context.Background()
-		response, err := llmClient.GenerateResponse(ctx, messages, 100, 0.7)
+		response, err := llmClient.GenerateResponse(ctx, messages, ai.GenerateOptions{MaxTokens: 100, Temperature: 0.7})
 		if err != nil {
 			log.Printf("LLM request failed: %v", err)
 		} else {
@@ -100,7 +100,7 @@ func main() {
 				},
 			}
 
-			response, err := llmClient.GenerateResponse(context.Background(), messages, 200, 0.7)
+			response, err := llmClient.GenerateResponse(context.Background(), messages, ai.GenerateOptions{MaxTokens: 200, Temperature: 0.7})
 			if err != nil {
 				log.Printf("Failed to generate LLM response: %v", err)
 			} else {
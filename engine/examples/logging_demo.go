@@ -22,7 +22,7 @@ func mainLoggingDemo() {
 		println("=" + string(make([]rune, len(mode)+10)))
 
 		// Create logger with current mode
-		l, err := logger.NewLogger(mode)
+		l, err := logger.NewLogger(mode, logger.FileOptions{})
 		if err != nil {
 			panic(err)
 		}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -16,6 +19,10 @@ type Config struct {
 	JWTSecret   string
 	TestMode    bool // Add test mode flag
 
+	// TestModeSkipRoles lets the test-mode user through RequireRole. Off by default, so role-protected
+	// routes stay protected in test mode unless explicitly opened up.
+	TestModeSkipRoles bool
+
 	// TLS configuration
 	TLSEnabled  bool   // Enable TLS/HTTPS
 	TLSCertFile string // Path to TLS certificate file
@@ -24,9 +31,20 @@ type Config struct {
 	// Logging configuration
 	LogMode string // PRINT, WRITE, or NONE
 
+	// Log file settings for WRITE mode
+	LogFilePath   string
+	LogMaxSizeMB  int // Size at which the log file is rotated
+	LogMaxBackups int // Rotated files kept; 0 keeps all
+	LogMaxAgeDays int // Days rotated files are kept; 0 keeps them regardless of age
+
 	// CORS configuration
 	CORSAllowedOrigins  []string
 	CORSAllowAllOrigins bool
+	CORSMaxAge          string // Seconds browsers may cache preflight responses; empty omits the header
+
+	// Rate limiting for costly endpoints (chat, uploads), per user
+	RateLimitPerMinute int // Sustained requests per minute; 0 disables rate limiting
+	RateLimitBurst     int // Requests allowed in a burst before the per-minute rate applies
 
 	// Clerk configuration
 	ClerkSecretKey      string
@@ -39,28 +57,142 @@ type Config struct {
 	AWSSecretAccessKey  string
 	DynamoDBTableHealth string
 	DynamoDBTableDocs   string
+	DynamoDBTableChat   string
 	S3Bucket            string
+	MaxPresignMinutes   int    // Upper bound on presigned URL lifetime
+	S3UploadPartSizeMB  int    // Part size for multipart uploads; S3's minimum is 5
+	S3UploadConcurrency int    // Parts uploaded in parallel per file
+	S3SSEMode           string // Server-side encryption for uploads: "AES256", "aws:kms" or "none" for the bucket default
+	S3KMSKeyID          string // KMS key for "aws:kms"; empty uses the AWS managed key
+	S3UploadChecksums   bool   // Send Content-MD5 or SHA-256 checksums so S3 verifies uploaded data
+
+	// Document access log configuration
+	DynamoDBTableAccessLog string
+	AccessLogRetentionDays int // TTL for access-log records
+
+	// Insights cache configuration
+	DynamoDBTableInsights string
+	InsightsCacheTTLHours int // How long generated insights are reused before the agent is re-run
+
+	// Goal tracking configuration
+	DynamoDBTableGoals string
+
+	// User profile configuration
+	DynamoDBTableProfiles string
+
+	// Document chunk store configuration
+	DynamoDBTableChunks string
 
 	// Pinecone configuration
-	PineconeAPIKey    string
-	PineconeIndexName string
-	PineconeNamespace string
-	PineconeHost      string
+	PineconeAPIKey           string
+	PineconeIndexName        string
+	PineconeNamespace        string // "" is Pinecone's default namespace, where vectors written before namespacing live
+	PineconeNamespacePerUser bool   // Store each user's vectors in their own namespace under PineconeNamespace
+	PineconeHost             string
+	PineconeMaxContentBytes  int // Max chunk text bytes stored in vector metadata (Pinecone caps metadata at 40KB)
+	PineconeUpsertBatchSize  int // Max vectors sent per upsert request (Pinecone caps requests at ~100 vectors / 2MB)
 
 	// LLM configuration
-	SonarAPIKey    string
-	OpenAIAPIKey   string
-	LLMProvider    string
-	EmbeddingModel string
-	ChatModel      string
-	MaxTokens      int
-	Temperature    float32
+	SonarAPIKey     string
+	OpenAIAPIKey    string
+	AnthropicAPIKey string
+	AnthropicModel  string
+	OpenAIChatModel string // Chat model for LLM_PROVIDER=openai
+	LLMProvider     string
+	EmbeddingModel  string
+	ChatModel       string // Chat model for LLM_PROVIDER=sonar
+	MaxTokens       int
+	Temperature     float32
+
+	// Deterministic mode
+	DeterministicSeed int64 // Seed sent with deterministic requests to providers that support one
+
+	// AI provider requests
+	ProviderTimeoutSeconds int // Max duration of an LLM or embedding request; streams only wait this long for the first response
+
+	// Assistant settings
+	DefaultResponseLanguage string // ISO 639-1 code used when a request doesn't specify one
+	MaxSourcesReturned      int    // Default cap on sources included in a chat response
+	MaxSuggestions          int    // Default cap on suggestions included in a chat response
+	PromptGuardEnabled      bool   // Wrap retrieved document text in delimited data blocks in the prompt
+	PromptGuardScan         bool   // Scan retrieved document text for injection phrases and flag it
+	AssistantPersona        string // Default tone of answers: "friendly", "clinical" or "concise"
+	AssistantLocale         string // Locale for dates, numbers and units in answers, e.g. "en-GB"; empty leaves it to the model
+
+	// Chat history settings
+	StoreChatPrompts    bool // Persist the assembled LLM prompt, including health data, with each assistant message; opt-in
+	ChatHistoryMessages int  // Earlier messages of the session sent with each query; 0 disables conversation memory
+	ChatRetentionDays   int  // Purge chat messages this long after they were sent; needs TTL on the table's expires_at. 0 keeps them
+	// GSI on the chat table with user_id as partition key and sent_at as sort key; serves history
+	// across sessions in time order
+	DynamoDBChatTimestampIndex string
+
+	// Streaming settings
+	StreamCoalesceMs int // Merge streamed tokens into one SSE event per interval; 0 sends each token as it arrives
+
+	// WebSocket settings
+	WSPingIntervalSeconds int // Keepalive ping interval; a client missing two pongs is disconnected. 0 disables pings
+	WSIdleTimeoutMinutes  int // Close sessions with no client message for this long; 0 keeps them open
+
+	// WebSocket session store
+	WSSessionStore        string // "memory" or "dynamodb"; stored sessions can be resumed after a restart
+	DynamoDBTableSessions string
+	WSSessionTTLHours     int // Stored sessions expire this long after their last activity; needs TTL on expires_at
 
 	// Application settings
-	MaxFileSize      int64
-	SupportedFormats []string
-	ChunkSize        int
-	ChunkOverlap     int
+	MaxFileSize        int64
+	SupportedFormats   []string
+	ChunkSize          int
+	ChunkOverlap       int
+	ChunkByTokens      bool // Chunk by estimated tokens at sentence boundaries instead of by characters
+	ChunkMaxTokens     int  // Max tokens per chunk when ChunkByTokens is set
+	ChunkOverlapTokens int  // Tokens of overlap between chunks when ChunkByTokens is set
+	AutoComputeBMI     bool // Derive a bmi metric when weight or height is added
+	TrendMAWindow      int  // Number of points in the trend moving average
+
+	// Trend settings
+	TrendSlopeThreshold  float64 // Daily regression slope, as a fraction of the mean, above which a trend is up/down
+	TrendsMaxMetricTypes int     // Max metric types in one trends request
+	TrendsMaxCostDays    int     // Max metric types × period days in one trends request
+	TrendsConcurrency    int     // Metric histories queried in parallel per trends request
+
+	// Metric sources hidden from history and latest views unless the request asks for them, e.g. "derived"
+	MetricViewExcludedSources []string
+
+	// Idempotency settings
+	IdempotencyTTLMinutes int // How long a repeated Idempotency-Key returns the original metric; 0 disables
+
+	// Document processing settings
+	DocumentWorkers      int    // Size of the shared document processing worker pool
+	ProcessingTimeoutSec int    // Limit on processing one document, embedding and indexing included; 0 is unlimited
+	MaxProcessingPerUser int    // Max documents processed concurrently for a single user
+	StoreExtractedText   bool   // Keep each document's extracted text in S3 so it isn't re-extracted later
+	OCREnabled           bool   // Accept png/jpg uploads and extract their text with Tesseract
+	TesseractPath        string // tesseract binary used for OCR
+	OCRLanguages         string // Tesseract language codes, e.g. "eng+deu"
+	OCRTimeoutSeconds    int    // Per-image OCR limit
+
+	// Tag settings
+	NormalizeTags bool              // Trim, lowercase and dedupe tags on write
+	TagSynonyms   map[string]string // Normalized tag -> canonical tag, e.g. "labs" -> "lab"
+
+	// Embedding validation
+	EmbeddingDimension        int    // Expected embedding dimension; 0 reads it from the Pinecone index
+	EmbeddingMismatchAction   string // "regenerate" retries a mismatched chunk before skipping it; "skip" drops it immediately
+	EmbeddingMaxRegenerations int    // Regeneration attempts per chunk before it is skipped
+	EmbeddingBatchSize        int    // Chunks embedded per API request during document processing
+	EmbeddingCacheSize        int    // Embeddings kept in the in-memory LRU cache; 0 disables caching
+
+	// RAG settings
+	RAGIncludeHealthData     bool    // Embed periodic health-summary snapshots alongside documents
+	HealthSnapshotIntervalHr int     // Minimum hours between health snapshots per user
+	RAGMinRelevanceScore     float64 // Matches scoring below this are dropped; 0 keeps all
+	RAGMaxChunksPerDocument  int     // Max matches from one document; 0 is unlimited
+	HybridAlpha              float64 // Weight of vector similarity in hybrid search; the rest is keyword overlap
+
+	// Retry settings
+	RetryAttempts  int // Tries for calls that fail with transient errors (throttling, 5xx); 1 disables retries
+	RetryBackoffMs int // Wait before the first retry; doubled for each later one
 }
 
 // Load reads configuration from environment variables and .env file
@@ -75,6 +207,8 @@ func Load() (*Config, error) {
 		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key"),
 		TestMode:    getEnvAsBool("TEST_MODE", false), // Add test mode configuration
 
+		TestModeSkipRoles: getEnvAsBool("TEST_MODE_SKIP_ROLES", false),
+
 		// TLS configuration
 		TLSEnabled:  getEnvAsBool("TLS_ENABLED", false),
 		TLSCertFile: getEnv("TLS_CERT_FILE", ""),
@@ -83,9 +217,20 @@ func Load() (*Config, error) {
 		// Logging configuration
 		LogMode: getEnv("LOG_MODE", "PRINT"),
 
+		// Log file settings for WRITE mode
+		LogFilePath:   getEnv("LOG_FILE_PATH", "logs.json"),
+		LogMaxSizeMB:  getEnvAsInt("LOG_MAX_SIZE_MB", 100),
+		LogMaxBackups: getEnvAsInt("LOG_MAX_BACKUPS", 5),
+		LogMaxAgeDays: getEnvAsInt("LOG_MAX_AGE_DAYS", 30),
+
 		// CORS configuration
 		CORSAllowedOrigins:  getEnvAsStringSlice("CORS_ALLOWED_ORIGINS", []string{"http://localhost:3000", "http://localhost:3001", "https://localhost:3000", "https://localhost:3001"}),
 		CORSAllowAllOrigins: getEnvAsBool("CORS_ALLOW_ALL_ORIGINS", false),
+		CORSMaxAge:          getEnv("CORS_MAX_AGE", "86400"), // 24 hours
+
+		// Rate limiting
+		RateLimitPerMinute: getEnvAsInt("RATE_LIMIT_PER_MINUTE", 20),
+		RateLimitBurst:     getEnvAsInt("RATE_LIMIT_BURST", 5),
 
 		// Clerk configuration
 		ClerkSecretKey:      getEnv("CLERK_SECRET_KEY", ""),
@@ -98,33 +243,292 @@ func Load() (*Config, error) {
 		AWSSecretAccessKey:  getEnv("AWS_SECRET_ACCESS_KEY", ""),
 		DynamoDBTableHealth: getEnv("DYNAMODB_TABLE_HEALTH", "health-metrics"),
 		DynamoDBTableDocs:   getEnv("DYNAMODB_TABLE_DOCS", "health-documents"),
-		S3Bucket:            getEnv("S3_BUCKET", "health-documents-bucket"),
+		DynamoDBTableChat:   getEnv("DYNAMODB_TABLE_CHAT", "health-chat-messages"),
+		S3Bucket:            getEnv("S3_BUCKET", ""),
+		MaxPresignMinutes:   getEnvAsInt("MAX_PRESIGN_MINUTES", 60),
+		S3UploadPartSizeMB:  getEnvAsInt("S3_UPLOAD_PART_SIZE_MB", 8),
+		S3UploadConcurrency: getEnvAsInt("S3_UPLOAD_CONCURRENCY", 3),
+		S3SSEMode:           getEnv("S3_SSE_MODE", "AES256"),
+		S3KMSKeyID:          getEnv("S3_KMS_KEY_ID", ""),
+		S3UploadChecksums:   getEnvAsBool("S3_UPLOAD_CHECKSUMS", true),
+
+		// Document access log configuration
+		DynamoDBTableAccessLog: getEnv("DYNAMODB_TABLE_ACCESS_LOG", "health-document-access-log"),
+		AccessLogRetentionDays: getEnvAsInt("ACCESS_LOG_RETENTION_DAYS", 365),
+
+		// Insights cache configuration
+		DynamoDBTableInsights: getEnv("DYNAMODB_TABLE_INSIGHTS", "health-insights"),
+		InsightsCacheTTLHours: getEnvAsInt("INSIGHTS_CACHE_TTL_HOURS", 24),
+
+		// Goal tracking configuration
+		DynamoDBTableGoals: getEnv("DYNAMODB_TABLE_GOALS", "health-goals"),
+
+		// User profile configuration
+		DynamoDBTableProfiles: getEnv("DYNAMODB_TABLE_PROFILES", "health-user-profiles"),
+
+		// Document chunk store configuration
+		DynamoDBTableChunks: getEnv("DYNAMODB_TABLE_CHUNKS", "health-document-chunks"),
 
 		// Pinecone configuration
-		PineconeAPIKey:    getEnv("PINECONE_API_KEY", ""),
-		PineconeIndexName: getEnv("PINECONE_INDEX_NAME", "health-documents"),
-		PineconeNamespace: getEnv("PINECONE_NAMESPACE", "default"),
-		PineconeHost:      getEnv("PINECONE_HOST", ""),
+		PineconeAPIKey:           getEnv("PINECONE_API_KEY", ""),
+		PineconeIndexName:        getEnv("PINECONE_INDEX_NAME", ""),
+		PineconeNamespace:        getEnv("PINECONE_NAMESPACE", ""),
+		PineconeNamespacePerUser: getEnvAsBool("PINECONE_NAMESPACE_PER_USER", false),
+		PineconeHost:             getEnv("PINECONE_HOST", ""),
+		PineconeMaxContentBytes:  getEnvAsInt("PINECONE_MAX_CONTENT_BYTES", 30000),
+		PineconeUpsertBatchSize:  getEnvAsInt("PINECONE_UPSERT_BATCH_SIZE", 100),
 
 		// LLM configuration
-		SonarAPIKey:    getEnv("SONAR_API_KEY", ""),
-		OpenAIAPIKey:   getEnv("OPENAI_API_KEY", ""),
-		LLMProvider:    getEnv("LLM_PROVIDER", "sonar"),
-		EmbeddingModel: getEnv("EMBEDDING_MODEL", "text-embedding-ada-002"),
-		ChatModel:      getEnv("CHAT_MODEL", "sonar"),
-		MaxTokens:      getEnvAsInt("MAX_TOKENS", 4096),
-		Temperature:    getEnvAsFloat32("TEMPERATURE", 0.7),
+		SonarAPIKey:     getEnv("SONAR_API_KEY", ""),
+		OpenAIAPIKey:    getEnv("OPENAI_API_KEY", ""),
+		AnthropicAPIKey: getEnv("ANTHROPIC_API_KEY", ""),
+		AnthropicModel:  getEnv("ANTHROPIC_MODEL", "claude-3-5-sonnet-latest"),
+		OpenAIChatModel: getEnv("OPENAI_CHAT_MODEL", "gpt-4o-mini"),
+		LLMProvider:     getEnv("LLM_PROVIDER", "sonar"),
+		EmbeddingModel:  getEnv("EMBEDDING_MODEL", "text-embedding-ada-002"),
+		ChatModel:       getEnv("CHAT_MODEL", "sonar"),
+		MaxTokens:       getEnvAsInt("MAX_TOKENS", 4096),
+		Temperature:     getEnvAsFloat32("TEMPERATURE", 0.7),
+
+		// Deterministic mode
+		DeterministicSeed: getEnvAsInt64("DETERMINISTIC_SEED", 42),
+
+		// AI provider requests
+		ProviderTimeoutSeconds: getEnvAsInt("PROVIDER_TIMEOUT_SECONDS", 60),
+
+		// Assistant settings
+		DefaultResponseLanguage: getEnv("DEFAULT_RESPONSE_LANGUAGE", "en"),
+		MaxSourcesReturned:      getEnvAsInt("MAX_SOURCES_RETURNED", 5),
+		MaxSuggestions:          getEnvAsInt("MAX_SUGGESTIONS", 3),
+		PromptGuardEnabled:      getEnvAsBool("PROMPT_GUARD_ENABLED", true),
+		PromptGuardScan:         getEnvAsBool("PROMPT_GUARD_SCAN", true),
+		AssistantPersona:        getEnv("ASSISTANT_PERSONA", "friendly"),
+		AssistantLocale:         getEnv("ASSISTANT_LOCALE", ""),
+
+		// Chat history settings
+		StoreChatPrompts:           getEnvAsBool("STORE_CHAT_PROMPTS", false),
+		ChatHistoryMessages:        getEnvAsInt("CHAT_HISTORY_MESSAGES", 10),
+		ChatRetentionDays:          getEnvAsInt("CHAT_RETENTION_DAYS", 0),
+		DynamoDBChatTimestampIndex: getEnv("DYNAMODB_CHAT_TIMESTAMP_INDEX", "user_id-sent_at-index"),
+
+		// Streaming settings
+		StreamCoalesceMs: getEnvAsInt("STREAM_COALESCE_MS", 0),
+
+		// WebSocket settings
+		WSPingIntervalSeconds: getEnvAsInt("WS_PING_INTERVAL_SECONDS", 30),
+		WSIdleTimeoutMinutes:  getEnvAsInt("WS_IDLE_TIMEOUT_MINUTES", 30),
+
+		// WebSocket session store
+		WSSessionStore:        getEnv("WS_SESSION_STORE", "memory"),
+		DynamoDBTableSessions: getEnv("DYNAMODB_TABLE_SESSIONS", "health-chat-sessions"),
+		WSSessionTTLHours:     getEnvAsInt("WS_SESSION_TTL_HOURS", 168),
 
 		// Application settings
-		MaxFileSize:      getEnvAsInt64("MAX_FILE_SIZE", 10*1024*1024), // 10MB
-		SupportedFormats: []string{"pdf", "txt", "docx", "md"},
-		ChunkSize:        getEnvAsInt("CHUNK_SIZE", 1000),
-		ChunkOverlap:     getEnvAsInt("CHUNK_OVERLAP", 200),
+		MaxFileSize:        getEnvAsInt64("MAX_FILE_SIZE", 10*1024*1024), // 10MB
+		SupportedFormats:   []string{"pdf", "txt", "docx", "md"},
+		ChunkSize:          getEnvAsInt("CHUNK_SIZE", 1000),
+		ChunkOverlap:       getEnvAsInt("CHUNK_OVERLAP", 200),
+		ChunkByTokens:      getEnvAsBool("CHUNK_BY_TOKENS", false),
+		ChunkMaxTokens:     getEnvAsInt("CHUNK_MAX_TOKENS", 256),
+		ChunkOverlapTokens: getEnvAsInt("CHUNK_OVERLAP_TOKENS", 50),
+		AutoComputeBMI:     getEnvAsBool("AUTO_COMPUTE_BMI", true),
+		TrendMAWindow:      getEnvAsInt("TREND_MOVING_AVERAGE_WINDOW", 7),
+
+		// Trend settings
+		TrendSlopeThreshold:  getEnvAsFloat64("TREND_SLOPE_THRESHOLD", 0.002),
+		TrendsMaxMetricTypes: getEnvAsInt("TRENDS_MAX_METRIC_TYPES", 10),
+		TrendsMaxCostDays:    getEnvAsInt("TRENDS_MAX_COST_DAYS", 5*365),
+		TrendsConcurrency:    getEnvAsInt("TRENDS_CONCURRENCY", 4),
+
+		MetricViewExcludedSources: getEnvAsStringSlice("METRIC_VIEW_EXCLUDED_SOURCES", []string{}),
+
+		// Idempotency settings
+		IdempotencyTTLMinutes: getEnvAsInt("IDEMPOTENCY_TTL_MINUTES", 1440),
+
+		// Document processing settings
+		DocumentWorkers:      getEnvAsInt("DOCUMENT_WORKERS", 4),
+		ProcessingTimeoutSec: getEnvAsInt("PROCESSING_TIMEOUT_SECONDS", 300),
+		MaxProcessingPerUser: getEnvAsInt("MAX_PROCESSING_PER_USER", 2),
+		StoreExtractedText:   getEnvAsBool("STORE_EXTRACTED_TEXT", false),
+		OCREnabled:           getEnvAsBool("OCR_ENABLED", false),
+		TesseractPath:        getEnv("TESSERACT_PATH", "tesseract"),
+		OCRLanguages:         getEnv("OCR_LANGUAGES", "eng"),
+		OCRTimeoutSeconds:    getEnvAsInt("OCR_TIMEOUT_SECONDS", 60),
+
+		// Tag settings
+		NormalizeTags: getEnvAsBool("NORMALIZE_TAGS", true),
+		TagSynonyms:   getEnvAsStringMap("TAG_SYNONYMS", map[string]string{}),
+
+		// Embedding validation
+		EmbeddingDimension:        getEnvAsInt("EMBEDDING_DIMENSION", 0),
+		EmbeddingMismatchAction:   getEnv("EMBEDDING_MISMATCH_ACTION", "regenerate"),
+		EmbeddingMaxRegenerations: getEnvAsInt("EMBEDDING_MAX_REGENERATIONS", 2),
+		EmbeddingBatchSize:        getEnvAsInt("EMBEDDING_BATCH_SIZE", 100),
+		EmbeddingCacheSize:        getEnvAsInt("EMBEDDING_CACHE_SIZE", 0),
+
+		// RAG settings
+		RAGIncludeHealthData:     getEnvAsBool("RAG_INCLUDE_HEALTH_DATA", false),
+		HealthSnapshotIntervalHr: getEnvAsInt("HEALTH_SNAPSHOT_INTERVAL_HOURS", 24),
+		RAGMinRelevanceScore:     getEnvAsFloat64("RAG_MIN_RELEVANCE_SCORE", 0),
+		RAGMaxChunksPerDocument:  getEnvAsInt("RAG_MAX_CHUNKS_PER_DOCUMENT", 2),
+		HybridAlpha:              getEnvAsFloat64("RAG_HYBRID_ALPHA", 0.7),
+
+		// Retry settings
+		RetryAttempts:  getEnvAsInt("RETRY_ATTEMPTS", 3),
+		RetryBackoffMs: getEnvAsInt("RETRY_BACKOFF_MS", 200),
 	}
 
 	return cfg, nil
 }
 
+// modelMaxTokens is the largest completion each known chat model accepts
+var modelMaxTokens = map[string]int{
+	"sonar":                    8192,
+	"sonar-pro":                8192,
+	"sonar-reasoning":          8192,
+	"gpt-4o":                   16384,
+	"gpt-4o-mini":              16384,
+	"gpt-4-turbo":              4096,
+	"gpt-3.5-turbo":            4096,
+	"claude-3-5-sonnet-latest": 8192,
+	"claude-3-5-haiku-latest":  8192,
+	"claude-3-opus-latest":     4096,
+}
+
+// providerMaxTokens is the completion limit assumed for models not listed in modelMaxTokens
+var providerMaxTokens = map[string]int{
+	"sonar":     8192,
+	"openai":    16384,
+	"anthropic": 8192,
+}
+
+// ActiveChatModel returns the model name used by the selected LLM provider
+func (c *Config) ActiveChatModel() string {
+	switch c.LLMProvider {
+	case "anthropic":
+		return c.AnthropicModel
+	case "openai":
+		return c.OpenAIChatModel
+	}
+	return c.ChatModel
+}
+
+// ModelMaxTokens returns the completion token limit of the active chat model, or 0 when unknown
+func (c *Config) ModelMaxTokens() int {
+	if limit, ok := modelMaxTokens[c.ActiveChatModel()]; ok {
+		return limit
+	}
+	return providerMaxTokens[c.LLMProvider]
+}
+
+// Validate checks configuration values that would otherwise only fail later, deep inside a
+// request: required credentials and settings out of range. All problems are reported together.
+func (c *Config) Validate() error {
+	var errs []error
+
+	// Credentials the server can't work without. Test mode bypasses authentication and is
+	// typically run against local stand-ins, so only the AI keys are required there.
+	required := map[string]string{
+		"OPENAI_API_KEY": c.OpenAIAPIKey, // Embeddings always use OpenAI
+	}
+	if !c.TestMode {
+		required["CLERK_SECRET_KEY"] = c.ClerkSecretKey
+		required["PINECONE_API_KEY"] = c.PineconeAPIKey
+		required["PINECONE_INDEX_NAME"] = c.PineconeIndexName
+		required["S3_BUCKET"] = c.S3Bucket
+	}
+
+	switch c.LLMProvider {
+	case "sonar":
+		required["SONAR_API_KEY"] = c.SonarAPIKey
+	case "anthropic":
+		required["ANTHROPIC_API_KEY"] = c.AnthropicAPIKey
+	case "openai":
+		// Already required for embeddings
+	default:
+		errs = append(errs, fmt.Errorf("LLM_PROVIDER must be sonar, anthropic or openai, got %q", c.LLMProvider))
+	}
+
+	missing := make([]string, 0, len(required))
+	for name, value := range required {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		errs = append(errs, fmt.Errorf("%s is required", name))
+	}
+
+	if c.TLSEnabled && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE are required when TLS is enabled"))
+	}
+
+	switch c.LogMode {
+	case "PRINT", "WRITE", "NONE":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_MODE must be PRINT, WRITE or NONE, got %q", c.LogMode))
+	}
+
+	if c.Temperature < 0 || c.Temperature > 2 {
+		errs = append(errs, fmt.Errorf("TEMPERATURE must be between 0 and 2, got %g", c.Temperature))
+	} else if c.LLMProvider == "anthropic" && c.Temperature > 1 {
+		errs = append(errs, fmt.Errorf("TEMPERATURE must be between 0 and 1 for the anthropic provider, got %g", c.Temperature))
+	}
+
+	if c.MaxTokens <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_TOKENS must be positive, got %d", c.MaxTokens))
+	} else if limit := c.ModelMaxTokens(); limit > 0 && c.MaxTokens > limit {
+		errs = append(errs, fmt.Errorf("MAX_TOKENS %d exceeds the %d-token limit of model %s",
+			c.MaxTokens, limit, c.ActiveChatModel()))
+	}
+
+	// Counts and sizes the code would otherwise have to clamp where they're used
+	for _, setting := range []struct {
+		name  string
+		value int
+		min   int
+		max   int // 0 means unbounded
+	}{
+		{"DOCUMENT_WORKERS", c.DocumentWorkers, 1, 0},
+		{"MAX_PROCESSING_PER_USER", c.MaxProcessingPerUser, 1, 0},
+		{"EMBEDDING_BATCH_SIZE", c.EmbeddingBatchSize, 1, 0},
+		{"PINECONE_UPSERT_BATCH_SIZE", c.PineconeUpsertBatchSize, 1, 1000}, // Pinecone's per-request limit
+		{"TRENDS_CONCURRENCY", c.TrendsConcurrency, 1, 0},
+		{"TREND_MOVING_AVERAGE_WINDOW", c.TrendMAWindow, 1, 0},
+		{"RATE_LIMIT_PER_MINUTE", c.RateLimitPerMinute, 0, 0}, // 0 disables rate limiting
+	} {
+		if setting.value < setting.min || (setting.max > 0 && setting.value > setting.max) {
+			if setting.max > 0 {
+				errs = append(errs, fmt.Errorf("%s must be between %d and %d, got %d", setting.name, setting.min, setting.max, setting.value))
+			} else {
+				errs = append(errs, fmt.Errorf("%s must be at least %d, got %d", setting.name, setting.min, setting.value))
+			}
+		}
+	}
+	if c.RateLimitPerMinute > 0 && c.RateLimitBurst < 1 {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BURST must be at least 1 when rate limiting is enabled, got %d", c.RateLimitBurst))
+	}
+
+	if c.HybridAlpha < 0 || c.HybridAlpha > 1 {
+		errs = append(errs, fmt.Errorf("RAG_HYBRID_ALPHA must be between 0 and 1, got %g", c.HybridAlpha))
+	}
+
+	if c.CORSMaxAge != "" {
+		if seconds, err := strconv.Atoi(c.CORSMaxAge); err != nil || seconds < 0 {
+			errs = append(errs, fmt.Errorf("CORS_MAX_AGE must be a non-negative number of seconds, got %q", c.CORSMaxAge))
+		}
+	}
+
+	switch c.S3SSEMode {
+	case "AES256", "aws:kms", "none":
+	default:
+		errs = append(errs, fmt.Errorf("S3_SSE_MODE must be AES256, aws:kms or none, got %q", c.S3SSEMode))
+	}
+
+	return errors.Join(errs...)
+}
+
 // getEnv gets environment variable with fallback
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
@@ -163,6 +567,16 @@ func getEnvAsFloat32(key string, fallback float32) float32 {
 	return fallback
 }
 
+// getEnvAsFloat64 gets environment variable as float64 with fallback
+func getEnvAsFloat64(key string, fallback float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if float64Val, err := strconv.ParseFloat(value, 64); err == nil {
+			return float64Val
+		}
+	}
+	return fallback
+}
+
 // getEnvAsBool gets environment variable as bool with fallback
 func getEnvAsBool(key string, fallback bool) bool {
 	if value := os.Getenv(key); value != "" {
@@ -173,6 +587,23 @@ func getEnvAsBool(key string, fallback bool) bool {
 	return fallback
 }
 
+// getEnvAsStringMap gets environment variable as a map from comma-separated key=value pairs
+// (e.g. "labs=lab,blood work=bloodwork") with fallback. Pairs without "=" are ignored.
+func getEnvAsStringMap(key string, fallback map[string]string) map[string]string {
+	if value := os.Getenv(key); value != "" {
+		result := make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		return result
+	}
+	return fallback
+}
+
 // getEnvAsStringSlice gets environment variable as string slice with fallback
 func getEnvAsStringSlice(key string, fallback []string) []string {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig returns a configuration that passes Validate
+func validConfig() *Config {
+	return &Config{
+		ClerkSecretKey:    "sk_test",
+		PineconeAPIKey:    "pc",
+		PineconeIndexName: "health",
+		S3Bucket:          "health-documents",
+		OpenAIAPIKey:      "sk-openai",
+		SonarAPIKey:       "pplx",
+		AnthropicAPIKey:   "sk-ant",
+		LLMProvider:       "sonar",
+		LogMode:           "PRINT",
+		Temperature:       0.7,
+		MaxTokens:         1000,
+		S3SSEMode:         "AES256",
+
+		DocumentWorkers:         4,
+		MaxProcessingPerUser:    2,
+		EmbeddingBatchSize:      100,
+		PineconeUpsertBatchSize: 100,
+		TrendsConcurrency:       4,
+		TrendMAWindow:           7,
+		RateLimitPerMinute:      20,
+		RateLimitBurst:          5,
+	}
+}
+
+func TestValidateTemperaturePerProvider(t *testing.T) {
+	for _, tc := range []struct {
+		provider    string
+		temperature float32
+		wantErr     bool
+	}{
+		{"sonar", 1.5, false},
+		{"openai", 2, false},
+		{"openai", 2.5, true},
+		{"anthropic", 1, false},
+		{"anthropic", 1.5, true},
+		{"anthropic", -0.1, true},
+	} {
+		cfg := validConfig()
+		cfg.LLMProvider = tc.provider
+		cfg.Temperature = tc.temperature
+
+		err := cfg.Validate()
+		if tc.wantErr && (err == nil || !strings.Contains(err.Error(), "TEMPERATURE")) {
+			t.Errorf("%s with temperature %g: got %v, want a TEMPERATURE error", tc.provider, tc.temperature, err)
+		}
+		if !tc.wantErr && err != nil {
+			t.Errorf("%s with temperature %g: unexpected error %v", tc.provider, tc.temperature, err)
+		}
+	}
+}
+
+func TestValidateMaxTokens(t *testing.T) {
+	for _, tc := range []struct {
+		maxTokens int
+		wantErr   bool
+	}{
+		{1000, false},
+		{0, true},
+		{-5, true},
+		{10_000_000, true}, // Above the model's limit
+	} {
+		cfg := validConfig()
+		cfg.MaxTokens = tc.maxTokens
+
+		err := cfg.Validate()
+		if tc.wantErr && (err == nil || !strings.Contains(err.Error(), "MAX_TOKENS")) {
+			t.Errorf("max tokens %d: got %v, want a MAX_TOKENS error", tc.maxTokens, err)
+		}
+		if !tc.wantErr && err != nil {
+			t.Errorf("max tokens %d: unexpected error %v", tc.maxTokens, err)
+		}
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Temperature = 10
+	cfg.MaxTokens = -5
+	cfg.ClerkSecretKey = ""
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid configuration accepted")
+	}
+	for _, want := range []string{"TEMPERATURE", "MAX_TOKENS", "CLERK_SECRET_KEY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %s", err, want)
+		}
+	}
+}
+
+func TestValidateRequiredFieldsAndSettings(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		configure func(cfg *Config)
+		want      []string // Substrings of the error; none means valid
+	}{
+		{"valid", func(cfg *Config) {}, nil},
+		{"production credentials", func(cfg *Config) {
+			cfg.PineconeAPIKey, cfg.PineconeIndexName, cfg.S3Bucket = "", "", ""
+		}, []string{"PINECONE_API_KEY", "PINECONE_INDEX_NAME", "S3_BUCKET"}},
+		{"test mode skips infrastructure", func(cfg *Config) {
+			cfg.TestMode = true
+			cfg.ClerkSecretKey, cfg.PineconeAPIKey, cfg.S3Bucket = "", "", ""
+		}, nil},
+		{"test mode still needs embeddings", func(cfg *Config) {
+			cfg.TestMode = true
+			cfg.OpenAIAPIKey = ""
+		}, []string{"OPENAI_API_KEY"}},
+		{"sonar key", func(cfg *Config) { cfg.SonarAPIKey = "" }, []string{"SONAR_API_KEY"}},
+		{"anthropic key", func(cfg *Config) {
+			cfg.LLMProvider = "anthropic"
+			cfg.AnthropicAPIKey = ""
+		}, []string{"ANTHROPIC_API_KEY"}},
+		{"other provider's key unused", func(cfg *Config) {
+			cfg.LLMProvider = "openai"
+			cfg.SonarAPIKey, cfg.AnthropicAPIKey = "", ""
+		}, nil},
+		{"unknown provider", func(cfg *Config) { cfg.LLMProvider = "gemini" }, []string{"LLM_PROVIDER"}},
+		{"no document workers", func(cfg *Config) { cfg.DocumentWorkers = 0 }, []string{"DOCUMENT_WORKERS"}},
+		{"batch sizes", func(cfg *Config) {
+			cfg.EmbeddingBatchSize = 0
+			cfg.PineconeUpsertBatchSize = 5000
+		}, []string{"EMBEDDING_BATCH_SIZE", "PINECONE_UPSERT_BATCH_SIZE"}},
+		{"trend settings", func(cfg *Config) {
+			cfg.TrendsConcurrency = -1
+			cfg.TrendMAWindow = 0
+		}, []string{"TRENDS_CONCURRENCY", "TREND_MOVING_AVERAGE_WINDOW"}},
+		{"negative rate limit", func(cfg *Config) { cfg.RateLimitPerMinute = -1 }, []string{"RATE_LIMIT_PER_MINUTE"}},
+		{"rate limit without burst", func(cfg *Config) { cfg.RateLimitBurst = 0 }, []string{"RATE_LIMIT_BURST"}},
+		{"rate limiting disabled ignores burst", func(cfg *Config) {
+			cfg.RateLimitPerMinute = 0
+			cfg.RateLimitBurst = 0
+		}, nil},
+		{"TLS without files", func(cfg *Config) { cfg.TLSEnabled = true }, []string{"TLS_CERT_FILE"}},
+		{"log mode", func(cfg *Config) { cfg.LogMode = "verbose" }, []string{"LOG_MODE"}},
+		{"hybrid alpha", func(cfg *Config) { cfg.HybridAlpha = 1.5 }, []string{"RAG_HYBRID_ALPHA"}},
+		{"CORS max age", func(cfg *Config) { cfg.CORSMaxAge = "-1" }, []string{"CORS_MAX_AGE"}},
+		{"SSE mode", func(cfg *Config) { cfg.S3SSEMode = "aws:kms:dsse" }, []string{"S3_SSE_MODE"}},
+	} {
+		cfg := validConfig()
+		tc.configure(cfg)
+
+		err := cfg.Validate()
+		if len(tc.want) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: invalid configuration accepted", tc.name)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q doesn't mention %s", tc.name, err, want)
+			}
+		}
+	}
+}
+
+func TestActiveChatModelPerProvider(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "openai")
+	t.Setenv("CHAT_MODEL", "")
+	t.Setenv("OPENAI_CHAT_MODEL", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := cfg.ActiveChatModel(); got != "gpt-4o-mini" {
+		t.Errorf("openai model = %q, want the OpenAI default rather than %q", got, cfg.ChatModel)
+	}
+
+	cfg.LLMProvider = "anthropic"
+	if got := cfg.ActiveChatModel(); got != cfg.AnthropicModel {
+		t.Errorf("anthropic model = %q, want %q", got, cfg.AnthropicModel)
+	}
+	cfg.LLMProvider = "sonar"
+	if got := cfg.ActiveChatModel(); got != "sonar" {
+		t.Errorf("sonar model = %q, want sonar", got)
+	}
+}
@@ -1,23 +1,46 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 
 	"health-dashboard-backend/internal/config"
 	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/pkg/retry"
 )
 
+// ErrVersionConflict is returned when a conditional write finds a different version than expected
+var ErrVersionConflict = errors.New("version conflict")
+
 // DynamoDBClient wraps the AWS DynamoDB client
 type DynamoDBClient struct {
-	client             *dynamodb.DynamoDB
+	client             dynamodbiface.DynamoDBAPI
 	healthTableName    string
 	documentsTableName string
+	chatTableName      string
+	accessLogTableName string
+	insightsTableName  string
+	goalsTableName     string
+	profilesTableName  string
+	chunksTableName    string
+	sessionsTableName  string
+
+	chatTimestampIndex string // GSI on the chat table keyed by user_id and sent_at
+
+	retryAttempts int           // Tries for writes that fail with throttling or server errors
+	retryBackoff  time.Duration // Wait before the first retry
 }
 
 // NewDynamoDBClient creates a new DynamoDB client
@@ -40,11 +63,52 @@ func NewDynamoDBClient(cfg *config.Config) (*DynamoDBClient, error) {
 		return nil, fmt.Errorf("failed to create AWS session: %w", err)
 	}
 
+	return NewDynamoDBClientWithAPI(dynamodb.New(sess), cfg), nil
+}
+
+// NewDynamoDBClientWithAPI creates a client that sends its calls to api, such as a local
+// DynamoDB or an in-memory stand-in in tests
+func NewDynamoDBClientWithAPI(api dynamodbiface.DynamoDBAPI, cfg *config.Config) *DynamoDBClient {
 	return &DynamoDBClient{
-		client:             dynamodb.New(sess),
+		client:             api,
 		healthTableName:    cfg.DynamoDBTableHealth,
 		documentsTableName: cfg.DynamoDBTableDocs,
-	}, nil
+		chatTableName:      cfg.DynamoDBTableChat,
+		accessLogTableName: cfg.DynamoDBTableAccessLog,
+		insightsTableName:  cfg.DynamoDBTableInsights,
+		goalsTableName:     cfg.DynamoDBTableGoals,
+		profilesTableName:  cfg.DynamoDBTableProfiles,
+		chunksTableName:    cfg.DynamoDBTableChunks,
+		sessionsTableName:  cfg.DynamoDBTableSessions,
+		chatTimestampIndex: cfg.DynamoDBChatTimestampIndex,
+		retryAttempts:      cfg.RetryAttempts,
+		retryBackoff:       time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
+	}
+}
+
+// retryableAWSErrorCodes are DynamoDB error codes for throttling and transient server failures
+var retryableAWSErrorCodes = map[string]bool{
+	dynamodb.ErrCodeProvisionedThroughputExceededException: true,
+	dynamodb.ErrCodeRequestLimitExceeded:                   true,
+	dynamodb.ErrCodeInternalServerError:                    true,
+	"ThrottlingException":                                  true,
+	"ServiceUnavailable":                                   true,
+}
+
+// retryableAWSError marks throttling and 5xx errors as retryable; other errors, such as failed
+// conditions and validation errors, are returned unchanged
+func retryableAWSError(err error) error {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && retryableAWSErrorCodes[awsErr.Code()] {
+		return retry.Retryable(err)
+	}
+
+	var requestErr awserr.RequestFailure
+	if errors.As(err, &requestErr) && requestErr.StatusCode() >= 500 {
+		return retry.Retryable(err)
+	}
+
+	return err
 }
 
 // Health Data Operations
@@ -64,7 +128,10 @@ func (d *DynamoDBClient) PutHealthMetric(metric *models.HealthMetric) error {
 		Item:      item,
 	}
 
-	_, err = d.client.PutItem(input)
+	err = retry.Do(context.Background(), d.retryAttempts, d.retryBackoff, func() error {
+		_, err := d.client.PutItem(input)
+		return retryableAWSError(err)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to put health metric: %w", err)
 	}
@@ -72,8 +139,100 @@ func (d *DynamoDBClient) PutHealthMetric(metric *models.HealthMetric) error {
 	return nil
 }
 
-// GetHealthMetrics retrieves health metrics for a user within a time range
-func (d *DynamoDBClient) GetHealthMetrics(userID string, metricType string, startTime, endTime time.Time, limit int) ([]models.HealthMetric, error) {
+// maxBatchWriteItems is the DynamoDB limit on items per BatchWriteItem call
+const maxBatchWriteItems = 25
+
+// BatchPutHealthMetrics stores health metrics using BatchWriteItem in groups of 25.
+// The returned slice has one entry per metric: nil on success, or why that metric wasn't stored.
+// Items DynamoDB leaves unprocessed are retried; only those still unprocessed afterwards fail.
+func (d *DynamoDBClient) BatchPutHealthMetrics(metrics []*models.HealthMetric) []error {
+	errs := make([]error, len(metrics))
+
+	for start := 0; start < len(metrics); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+
+		requests := make([]*dynamodb.WriteRequest, 0, end-start)
+		keyIndex := make(map[string]int, end-start) // sort_key -> metric index, to map unprocessed items back
+		for i := start; i < end; i++ {
+			metric := metrics[i]
+			metric.SortKey = metric.GetSortKey()
+
+			item, err := metric.ToDynamoDBItem()
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to marshal health metric: %w", err)
+				continue
+			}
+
+			requests = append(requests, &dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{Item: item},
+			})
+			keyIndex[metric.SortKey] = i
+		}
+
+		unprocessed, err := d.batchWrite(d.healthTableName, requests)
+		if err != nil {
+			for i := start; i < end; i++ {
+				if errs[i] == nil {
+					errs[i] = fmt.Errorf("failed to put health metrics: %w", err)
+				}
+			}
+			continue
+		}
+
+		for _, request := range unprocessed {
+			sortKey := request.PutRequest.Item["sort_key"]
+			if sortKey == nil || sortKey.S == nil {
+				continue
+			}
+			if i, exists := keyIndex[*sortKey.S]; exists {
+				errs[i] = errors.New("failed to put health metric: left unprocessed after retries")
+			}
+		}
+	}
+
+	return errs
+}
+
+// errUnprocessedItems marks a BatchWriteItem call that left items unprocessed, so they're retried
+var errUnprocessedItems = errors.New("batch write left items unprocessed")
+
+// batchWrite sends a BatchWriteItem request, retrying failed calls and unprocessed items with the
+// configured backoff. It returns the requests still unprocessed after the retries; err is set only
+// when a request itself failed.
+func (d *DynamoDBClient) batchWrite(tableName string, requests []*dynamodb.WriteRequest) ([]*dynamodb.WriteRequest, error) {
+	pending := map[string][]*dynamodb.WriteRequest{tableName: requests}
+
+	err := retry.Do(context.Background(), d.retryAttempts, d.retryBackoff, func() error {
+		result, err := d.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems: pending,
+		})
+		if err != nil {
+			return retryableAWSError(err)
+		}
+
+		pending = result.UnprocessedItems
+		if len(pending[tableName]) > 0 {
+			return retry.Retryable(errUnprocessedItems)
+		}
+		return nil
+	})
+	if errors.Is(err, errUnprocessedItems) {
+		return pending[tableName], nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// GetHealthMetrics retrieves a page of health metrics for a user within a time range, latest first.
+// startKey is the LastEvaluatedKey of the previous page, or nil for the first page; the returned
+// key is nil when there are no more pages.
+func (d *DynamoDBClient) GetHealthMetrics(userID string, metricType string, startTime, endTime time.Time, limit int, startKey map[string]*dynamodb.AttributeValue) ([]models.HealthMetric, map[string]*dynamodb.AttributeValue, error) {
 
 	keyCondition := "user_id = :userID"
 	expressionValues := map[string]*dynamodb.AttributeValue{
@@ -114,11 +273,12 @@ func (d *DynamoDBClient) GetHealthMetrics(userID string, metricType string, star
 		ExpressionAttributeValues: expressionValues,
 		ScanIndexForward:          aws.Bool(false), // Latest first
 		Limit:                     aws.Int64(int64(limit)),
+		ExclusiveStartKey:         startKey,
 	}
 
 	result, err := d.client.Query(input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query health metrics: %w", err)
+		return nil, nil, fmt.Errorf("failed to query health metrics: %w", err)
 	}
 
 	var metrics []models.HealthMetric
@@ -130,11 +290,85 @@ func (d *DynamoDBClient) GetHealthMetrics(userID string, metricType string, star
 		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return metrics, result.LastEvaluatedKey, nil
+}
+
+// HealthMetricKey returns the primary key of the user's metric stored under sortKey
+func HealthMetricKey(userID, sortKey string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"user_id":  {S: aws.String(userID)},
+		"sort_key": {S: aws.String(sortKey)},
+	}
+}
+
+// ScanAllUserMetrics pages through every health metric in the user's partition in sort key order.
+// Each page is passed to handle as soon as it is read, so callers can stream results without
+// holding the full history in memory. Returning an error from handle stops the scan.
+func (d *DynamoDBClient) ScanAllUserMetrics(userID string, handle func(page []models.HealthMetric) error) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.healthTableName),
+		KeyConditionExpression: aws.String("user_id = :userID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userID": {
+				S: aws.String(userID),
+			},
+		},
+	}
+
+	return d.queryMetricPages(input, handle)
+}
+
+// ScanMetricRange pages through a user's readings of one metric type between startTime and endTime
+// in chronological order, passing each page to handle. Returning an error from handle stops the scan.
+func (d *DynamoDBClient) ScanMetricRange(userID, metricType string, startTime, endTime time.Time, handle func(page []models.HealthMetric) error) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.healthTableName),
+		KeyConditionExpression: aws.String("user_id = :userID AND sort_key BETWEEN :startKey AND :endKey"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userID":   {S: aws.String(userID)},
+			":startKey": {S: aws.String(metricType + "#" + startTime.UTC().Format("2006-01-02T15:04:05.000000Z"))},
+			":endKey":   {S: aws.String(metricType + "#" + endTime.UTC().Format("2006-01-02T15:04:05.000000Z~"))},
+		},
+	}
+
+	return d.queryMetricPages(input, handle)
+}
+
+// queryMetricPages runs a paginated health metric query, decoding each page before passing it to handle
+func (d *DynamoDBClient) queryMetricPages(input *dynamodb.QueryInput, handle func(page []models.HealthMetric) error) error {
+	var handleErr error
+	err := d.client.QueryPages(input, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		page := make([]models.HealthMetric, 0, len(output.Items))
+		for _, item := range output.Items {
+			var metric models.HealthMetric
+			if err := metric.FromDynamoDBItem(item); err != nil {
+				continue // Skip invalid items
+			}
+			page = append(page, metric)
+		}
+
+		if handleErr = handle(page); handleErr != nil {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query user metrics: %w", err)
+	}
+
+	return handleErr
 }
 
 // GetLatestHealthMetrics retrieves the latest health metrics for each type for a user
 func (d *DynamoDBClient) GetLatestHealthMetrics(userID string) (map[string]models.HealthMetric, error) {
+	return d.GetLatestHealthMetricsMatching(userID, nil, nil)
+}
+
+// GetLatestHealthMetricsMatching returns the latest reading of each metric type among those keep
+// accepts, so a filtered-out newer reading doesn't hide an older one that matches. A nil keep
+// accepts every reading. The query pages until every one of metricTypes has a reading, or through
+// the whole partition when metricTypes is empty.
+func (d *DynamoDBClient) GetLatestHealthMetricsMatching(userID string, metricTypes []string, keep func(metric *models.HealthMetric) bool) (map[string]models.HealthMetric, error) {
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(d.healthTableName),
 		KeyConditionExpression: aws.String("user_id = :userID"),
@@ -144,35 +378,54 @@ func (d *DynamoDBClient) GetLatestHealthMetrics(userID string) (map[string]model
 			},
 		},
 		ScanIndexForward: aws.Bool(false), // Latest first (descending sort key order)
-		Limit:            aws.Int64(100),  // Limit to avoid too much data
+		Limit:            aws.Int64(100),  // Page size
 	}
 
-	result, err := d.client.Query(input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query latest health metrics: %w", err)
+	wanted := make(map[string]bool, len(metricTypes))
+	for _, metricType := range metricTypes {
+		wanted[metricType] = true
 	}
 
 	latestMetrics := make(map[string]models.HealthMetric)
-	for _, item := range result.Items {
-		var metric models.HealthMetric
-		if err := metric.FromDynamoDBItem(item); err != nil {
-			continue // Skip invalid items
+	err := d.queryMetricPages(input, func(page []models.HealthMetric) error {
+		for _, metric := range page {
+			if len(wanted) > 0 && !wanted[metric.Type] {
+				continue
+			}
+			if keep != nil && !keep(&metric) {
+				continue
+			}
+
+			// Keep only the latest metric for each type
+			// Since we're sorting by sort_key descending, the first occurrence of each type is the latest
+			if _, exists := latestMetrics[metric.Type]; !exists {
+				latestMetrics[metric.Type] = metric
+			}
 		}
 
-		// Keep only the latest metric for each type
-		// Since we're sorting by sort_key descending, the first occurrence of each type is the latest
-		if _, exists := latestMetrics[metric.Type]; !exists {
-			latestMetrics[metric.Type] = metric
+		if len(wanted) > 0 && len(latestMetrics) == len(wanted) {
+			return errLatestMetricsFound
 		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLatestMetricsFound) {
+		return nil, fmt.Errorf("failed to query latest health metrics: %w", err)
 	}
 
 	return latestMetrics, nil
 }
 
+// errLatestMetricsFound stops GetLatestHealthMetricsMatching's paging once every requested type is found
+var errLatestMetricsFound = errors.New("latest metrics found")
+
 // Document Operations
 
 // PutDocument stores a document metadata in DynamoDB
 func (d *DynamoDBClient) PutDocument(document *models.Document) error {
+	if document.Version == 0 {
+		document.Version = 1
+	}
+
 	item, err := document.ToDynamoDBItem()
 	if err != nil {
 		return fmt.Errorf("failed to marshal document: %w", err)
@@ -197,6 +450,47 @@ func (d *DynamoDBClient) PutDocument(document *models.Document) error {
 	return nil
 }
 
+// GetDocumentStatus reads only the processing fields of a document. Documents are keyed by
+// user_id and sort_key rather than document_id, so this is a projected query over the user's
+// documents rather than a GetItem, but it avoids transferring lab results and other large fields.
+func (d *DynamoDBClient) GetDocumentStatus(userID, documentID string) (*models.DocumentStatus, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.documentsTableName),
+		KeyConditionExpression: aws.String("user_id = :userID"),
+		FilterExpression:       aws.String("document_id = :documentID"),
+		ProjectionExpression:   aws.String("document_id, #status, chunk_count, processing_attempts, error_message, processed_at"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"), // status is a reserved word
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userID":     {S: aws.String(userID)},
+			":documentID": {S: aws.String(documentID)},
+		},
+	}
+
+	var status *models.DocumentStatus
+	var unmarshalErr error
+	err := d.client.QueryPages(input, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		if len(output.Items) == 0 {
+			return true // The filter is applied after each page is read, so keep going
+		}
+		status = &models.DocumentStatus{}
+		unmarshalErr = status.FromDynamoDBItem(output.Items[0])
+		return false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document status: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal document status: %w", unmarshalErr)
+	}
+	if status == nil {
+		return nil, fmt.Errorf("document not found")
+	}
+
+	return status, nil
+}
+
 // GetDocument retrieves a specific document by ID
 func (d *DynamoDBClient) GetDocument(userID, documentID string) (*models.Document, error) {
 	// Query all documents for the user and find the matching document_id
@@ -240,8 +534,45 @@ func (d *DynamoDBClient) GetDocument(userID, documentID string) (*models.Documen
 	return nil, fmt.Errorf("document not found")
 }
 
-// GetUserDocuments retrieves all documents for a user
-func (d *DynamoDBClient) GetUserDocuments(userID string, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]models.Document, map[string]*dynamodb.AttributeValue, error) {
+// GetDocumentByHash returns the user's document whose file has the given content hash, or nil when
+// there is none. Like GetDocumentStatus it filters a query over the user's documents, which keeps
+// the lookup within one partition without needing an index on the hash.
+func (d *DynamoDBClient) GetDocumentByHash(userID, contentHash string) (*models.Document, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.documentsTableName),
+		KeyConditionExpression: aws.String("user_id = :userID"),
+		FilterExpression:       aws.String("content_hash = :contentHash"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userID":      {S: aws.String(userID)},
+			":contentHash": {S: aws.String(contentHash)},
+		},
+	}
+
+	var document *models.Document
+	var unmarshalErr error
+	err := d.client.QueryPages(input, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		if len(output.Items) == 0 {
+			return true // The filter is applied after each page is read, so keep going
+		}
+		document = &models.Document{}
+		unmarshalErr = document.FromDynamoDBItem(output.Items[0])
+		return false
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document by hash: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal document: %w", unmarshalErr)
+	}
+
+	return document, nil
+}
+
+// GetUserDocuments retrieves a user's documents matching filter, latest first. DynamoDB applies
+// Limit before a filter expression, so when filtering it keeps reading pages until limit matches
+// are found or the documents run out; each read is limited to the matches still needed, so the
+// returned key never skips a match.
+func (d *DynamoDBClient) GetUserDocuments(userID string, filter models.DocumentFilter, limit int, lastEvaluatedKey map[string]*dynamodb.AttributeValue) ([]models.Document, map[string]*dynamodb.AttributeValue, error) {
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(d.documentsTableName),
 		KeyConditionExpression: aws.String("user_id = :userID"),
@@ -253,37 +584,64 @@ func (d *DynamoDBClient) GetUserDocuments(userID string, limit int, lastEvaluate
 		ScanIndexForward: aws.Bool(false), // Latest first
 	}
 
-	if limit > 0 {
-		input.Limit = aws.Int64(int64(limit))
+	var conditions []string
+	if !filter.IncludeArchived {
+		conditions = append(conditions, "(attribute_not_exists(archived) OR archived = :notArchived)")
+		input.ExpressionAttributeValues[":notArchived"] = &dynamodb.AttributeValue{BOOL: aws.Bool(false)}
 	}
-
-	if lastEvaluatedKey != nil {
-		input.ExclusiveStartKey = lastEvaluatedKey
+	if filter.Category != "" {
+		conditions = append(conditions, "category = :category")
+		input.ExpressionAttributeValues[":category"] = &dynamodb.AttributeValue{S: aws.String(filter.Category)}
 	}
-
-	result, err := d.client.Query(input)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to query user documents: %w", err)
+	for i, tag := range filter.Tags {
+		placeholder := fmt.Sprintf(":tag%d", i)
+		conditions = append(conditions, fmt.Sprintf("contains(tags, %s)", placeholder))
+		input.ExpressionAttributeValues[placeholder] = &dynamodb.AttributeValue{S: aws.String(tag)}
+	}
+	if len(conditions) > 0 {
+		input.FilterExpression = aws.String(strings.Join(conditions, " AND "))
 	}
 
 	var documents []models.Document
-	for _, item := range result.Items {
-		var document models.Document
-		if err := document.FromDynamoDBItem(item); err != nil {
-			continue // Skip invalid items
+	for {
+		if limit > 0 {
+			input.Limit = aws.Int64(int64(limit - len(documents)))
+		}
+		if lastEvaluatedKey != nil {
+			input.ExclusiveStartKey = lastEvaluatedKey
+		}
+
+		result, err := d.client.Query(input)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to query user documents: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var document models.Document
+			if err := document.FromDynamoDBItem(item); err != nil {
+				continue // Skip invalid items
+			}
+			documents = append(documents, document)
+		}
+
+		lastEvaluatedKey = result.LastEvaluatedKey
+		if input.FilterExpression == nil || lastEvaluatedKey == nil || limit <= 0 || len(documents) >= limit {
+			break
 		}
-		documents = append(documents, document)
 	}
 
-	return documents, result.LastEvaluatedKey, nil
+	return documents, lastEvaluatedKey, nil
 }
 
-// UpdateDocument updates a document's metadata
+// UpdateDocument updates a document's processing state and increments its version. It is
+// unconditional; use UpdateDocumentDetails for user edits that must not clobber each other.
 func (d *DynamoDBClient) UpdateDocument(document *models.Document) error {
 	// Prepare update expression
-	updateExpression := "SET #status = :status, processed_at = :processedAt, chunk_count = :chunkCount"
+	updateExpression := "SET #status = :status, processed_at = :processedAt, chunk_count = :chunkCount, " +
+		"#version = if_not_exists(#version, :zero) + :one"
 	expressionAttributeNames := map[string]*string{
-		"#status": aws.String("status"),
+		"#status":  aws.String("status"),
+		"#version": aws.String("version"),
 	}
 	expressionAttributeValues := map[string]*dynamodb.AttributeValue{
 		":status": {
@@ -295,6 +653,24 @@ func (d *DynamoDBClient) UpdateDocument(document *models.Document) error {
 		":chunkCount": {
 			N: aws.String(fmt.Sprintf("%d", document.ChunkCount)),
 		},
+		":zero": {N: aws.String("0")},
+		":one":  {N: aws.String("1")},
+	}
+
+	// Keep the stored text key and extracted lab results written during processing
+	if document.TextS3Key != "" {
+		updateExpression += ", text_s3_key = :textS3Key"
+		expressionAttributeValues[":textS3Key"] = &dynamodb.AttributeValue{
+			S: aws.String(document.TextS3Key),
+		}
+	}
+	if document.LabResults != nil {
+		labResults, err := dynamodbattribute.Marshal(document.LabResults)
+		if err != nil {
+			return fmt.Errorf("failed to marshal lab results: %w", err)
+		}
+		updateExpression += ", lab_results = :labResults"
+		expressionAttributeValues[":labResults"] = labResults
 	}
 
 	// Add error message if present
@@ -327,16 +703,141 @@ func (d *DynamoDBClient) UpdateDocument(document *models.Document) error {
 		UpdateExpression:          aws.String(updateExpression),
 		ExpressionAttributeNames:  expressionAttributeNames,
 		ExpressionAttributeValues: expressionAttributeValues,
+		ReturnValues:              aws.String(dynamodb.ReturnValueUpdatedNew),
 	}
 
-	_, err := d.client.UpdateItem(input)
+	result, err := d.client.UpdateItem(input)
 	if err != nil {
 		return fmt.Errorf("failed to update document: %w", err)
 	}
 
+	document.Version = returnedVersion(result, document.Version+1)
+	return nil
+}
+
+// UpdateDocumentDetails writes a document's title, description and tags and increments its
+// version, but only if the stored version still equals expectedVersion (0 matches documents stored
+// before versioning). A mismatch returns ErrVersionConflict. A negative expectedVersion skips the check.
+func (d *DynamoDBClient) UpdateDocumentDetails(document *models.Document, expectedVersion int) error {
+	tags := &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+	for _, tag := range document.Tags {
+		tags.L = append(tags.L, &dynamodb.AttributeValue{S: aws.String(tag)})
+	}
+
+	// Determine the correct sort key
+	sortKey := document.SortKey
+	sortKeyName := "sort_key"
+	if sortKey == "" {
+		// Fallback to document_id for old schema
+		sortKey = document.DocumentID
+		sortKeyName = "document_id"
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.documentsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"user_id": {
+				S: aws.String(document.UserID),
+			},
+			sortKeyName: {
+				S: aws.String(sortKey),
+			},
+		},
+		UpdateExpression: aws.String("SET title = :title, description = :description, tags = :tags, " +
+			"#version = if_not_exists(#version, :zero) + :one"),
+		ConditionExpression: aws.String("attribute_exists(document_id)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":title":       {S: aws.String(document.Title)},
+			":description": {S: aws.String(document.Description)},
+			":tags":        tags,
+			":zero":        {N: aws.String("0")},
+			":one":         {N: aws.String("1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueUpdatedNew),
+	}
+
+	switch {
+	case expectedVersion == 0:
+		input.ConditionExpression = aws.String("attribute_exists(document_id) AND attribute_not_exists(#version)")
+	case expectedVersion > 0:
+		input.ConditionExpression = aws.String("attribute_exists(document_id) AND #version = :expected")
+		input.ExpressionAttributeValues[":expected"] = &dynamodb.AttributeValue{
+			N: aws.String(fmt.Sprintf("%d", expectedVersion)),
+		}
+	}
+
+	result, err := d.client.UpdateItem(input)
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("%w: document %s is no longer at version %d", ErrVersionConflict, document.DocumentID, expectedVersion)
+		}
+		return fmt.Errorf("failed to update document details: %w", err)
+	}
+
+	document.Version = returnedVersion(result, document.Version+1)
+	return nil
+}
+
+// SetDocumentArchived stores the document's archived flag and increments its version
+func (d *DynamoDBClient) SetDocumentArchived(document *models.Document) error {
+	// Determine the correct sort key
+	sortKey := document.SortKey
+	sortKeyName := "sort_key"
+	if sortKey == "" {
+		// Fallback to document_id for old schema
+		sortKey = document.DocumentID
+		sortKeyName = "document_id"
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.documentsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"user_id": {
+				S: aws.String(document.UserID),
+			},
+			sortKeyName: {
+				S: aws.String(sortKey),
+			},
+		},
+		UpdateExpression:    aws.String("SET archived = :archived, #version = if_not_exists(#version, :zero) + :one"),
+		ConditionExpression: aws.String("attribute_exists(document_id)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":archived": {BOOL: aws.Bool(document.Archived)},
+			":zero":     {N: aws.String("0")},
+			":one":      {N: aws.String("1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueUpdatedNew),
+	}
+
+	result, err := d.client.UpdateItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to update document archived flag: %w", err)
+	}
+
+	document.Version = returnedVersion(result, document.Version+1)
 	return nil
 }
 
+// returnedVersion reads the new version from an UpdateItem result, falling back when it's missing
+func returnedVersion(result *dynamodb.UpdateItemOutput, fallback int) int {
+	if result == nil || result.Attributes == nil || result.Attributes["version"] == nil {
+		return fallback
+	}
+
+	var version int
+	if err := dynamodbattribute.Unmarshal(result.Attributes["version"], &version); err != nil {
+		return fallback
+	}
+	return version
+}
+
 // DeleteDocument removes a document from DynamoDB
 func (d *DynamoDBClient) DeleteDocument(userID, documentID string) error {
 	// Query to find the document and get its sort key
@@ -390,6 +891,667 @@ func (d *DynamoDBClient) DeleteDocument(userID, documentID string) error {
 	return nil
 }
 
+// Document Chunk Operations
+
+// PutDocumentChunks stores a document's chunks in groups of 25. The table is keyed by
+// document_id/chunk_index, so storing a chunk replaces the one previously at its index.
+func (d *DynamoDBClient) PutDocumentChunks(chunks []models.DocumentChunk) error {
+	for start := 0; start < len(chunks); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		requests := make([]*dynamodb.WriteRequest, 0, end-start)
+		for i := start; i < end; i++ {
+			item, err := chunks[i].ToDynamoDBItem()
+			if err != nil {
+				return fmt.Errorf("failed to marshal document chunk: %w", err)
+			}
+			requests = append(requests, &dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{Item: item},
+			})
+		}
+
+		unprocessed, err := d.batchWrite(d.chunksTableName, requests)
+		if err != nil {
+			return fmt.Errorf("failed to put document chunks: %w", err)
+		}
+		if len(unprocessed) > 0 {
+			return fmt.Errorf("failed to put document chunks: %d left unprocessed after retries", len(unprocessed))
+		}
+	}
+
+	return nil
+}
+
+// GetDocumentChunk retrieves one stored chunk, or nil when there is none at chunkIndex
+func (d *DynamoDBClient) GetDocumentChunk(documentID string, chunkIndex int) (*models.DocumentChunk, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(d.chunksTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"document_id": {
+				S: aws.String(documentID),
+			},
+			"chunk_index": {
+				N: aws.String(strconv.Itoa(chunkIndex)),
+			},
+		},
+	}
+
+	result, err := d.client.GetItem(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document chunk: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var chunk models.DocumentChunk
+	if err := chunk.FromDynamoDBItem(result.Item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal document chunk: %w", err)
+	}
+
+	return &chunk, nil
+}
+
+// GetDocumentChunks retrieves all stored chunks of a document in chunk order
+func (d *DynamoDBClient) GetDocumentChunks(documentID string) ([]models.DocumentChunk, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.chunksTableName),
+		KeyConditionExpression: aws.String("document_id = :documentID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":documentID": {
+				S: aws.String(documentID),
+			},
+		},
+	}
+
+	var chunks []models.DocumentChunk
+	err := d.client.QueryPages(input, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range output.Items {
+			var chunk models.DocumentChunk
+			if err := chunk.FromDynamoDBItem(item); err != nil {
+				continue // Skip invalid items
+			}
+			chunks = append(chunks, chunk)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document chunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// DeleteDocumentChunks removes all stored chunks of a document
+func (d *DynamoDBClient) DeleteDocumentChunks(documentID string) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.chunksTableName),
+		KeyConditionExpression: aws.String("document_id = :documentID"),
+		ProjectionExpression:   aws.String("document_id, chunk_index"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":documentID": {
+				S: aws.String(documentID),
+			},
+		},
+	}
+
+	var requests []*dynamodb.WriteRequest
+	err := d.client.QueryPages(input, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, key := range output.Items {
+			requests = append(requests, &dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{Key: key},
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query document chunks for deletion: %w", err)
+	}
+
+	for start := 0; start < len(requests); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		unprocessed, err := d.batchWrite(d.chunksTableName, requests[start:end])
+		if err != nil {
+			return fmt.Errorf("failed to delete document chunks: %w", err)
+		}
+		if len(unprocessed) > 0 {
+			return fmt.Errorf("failed to delete document chunks: %d left unprocessed after retries", len(unprocessed))
+		}
+	}
+
+	return nil
+}
+
+// Chat Operations
+
+// PutChatMessage stores a chat message in DynamoDB. Messages with an expiry rely on TTL being
+// enabled on the chat table's expires_at attribute.
+func (d *DynamoDBClient) PutChatMessage(message *models.ChatMessage) error {
+	// Set the table and index sort keys before marshaling
+	message.SortKey = message.GetSortKey()
+	message.SentAt = message.GetSentAt()
+
+	item, err := message.ToDynamoDBItem()
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat message: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(d.chatTableName),
+		Item:      item,
+	}
+
+	err = retry.Do(context.Background(), d.retryAttempts, d.retryBackoff, func() error {
+		_, err := d.client.PutItem(input)
+		return retryableAWSError(err)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put chat message: %w", err)
+	}
+
+	return nil
+}
+
+// GetChatMessages retrieves up to limit of a user's latest chat messages, latest first, and
+// reports whether older ones remain. With a session ID it reads that session's slice of the
+// table; without one it reads the timestamp index, so messages of all sessions come back in time
+// order. A limit of 0 returns every message.
+func (d *DynamoDBClient) GetChatMessages(userID, sessionID string, limit int) ([]models.ChatMessage, bool, error) {
+	keyCondition := "user_id = :userID"
+	expressionValues := map[string]*dynamodb.AttributeValue{
+		":userID": {
+			S: aws.String(userID),
+		},
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(d.chatTableName),
+		ExpressionAttributeValues: expressionValues,
+		ScanIndexForward:          aws.Bool(false), // Latest first
+	}
+
+	if sessionID != "" {
+		keyCondition += " AND begins_with(sort_key, :sessionPrefix)"
+		expressionValues[":sessionPrefix"] = &dynamodb.AttributeValue{S: aws.String(sessionID + "#")}
+	} else {
+		input.IndexName = aws.String(d.chatTimestampIndex)
+	}
+	input.KeyConditionExpression = aws.String(keyCondition)
+
+	if limit > 0 {
+		// One extra message tells whether more remain
+		input.Limit = aws.Int64(int64(limit + 1))
+	}
+
+	// Expired messages are skipped, so keep paging until the limit is filled
+	now := time.Now()
+	var messages []models.ChatMessage
+	err := d.client.QueryPages(input, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range output.Items {
+			var message models.ChatMessage
+			if err := message.FromDynamoDBItem(item); err != nil {
+				continue // Skip invalid items
+			}
+			if message.IsExpired(now) {
+				continue // Expired but not yet removed by TTL
+			}
+			messages = append(messages, message)
+		}
+		return limit <= 0 || len(messages) <= limit
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query chat messages: %w", err)
+	}
+
+	hasMore := false
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+		hasMore = true
+	}
+
+	return messages, hasMore, nil
+}
+
+// DeleteChatSession removes all of a user's messages in one session and returns how many were
+// deleted. The chat table is partitioned by user, so another user's session matches nothing.
+func (d *DynamoDBClient) DeleteChatSession(userID, sessionID string) (int, error) {
+	_, deleted, err := d.deleteChatMessages(userID, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	return deleted[sessionID], nil
+}
+
+// DeleteAllChatSessions removes every chat message of a user and returns the IDs of the sessions
+// that had messages
+func (d *DynamoDBClient) DeleteAllChatSessions(userID string) ([]string, error) {
+	sessionIDs, _, err := d.deleteChatMessages(userID, "")
+	if err != nil {
+		return nil, err
+	}
+	return sessionIDs, nil
+}
+
+// deleteChatMessages batch-deletes a user's chat messages, restricted to one session when sessionID
+// is set. It returns the IDs of the sessions that had messages, in sort key order, and how many
+// messages each lost.
+func (d *DynamoDBClient) deleteChatMessages(userID, sessionID string) ([]string, map[string]int, error) {
+	keyCondition := "user_id = :userID"
+	expressionValues := map[string]*dynamodb.AttributeValue{
+		":userID": {
+			S: aws.String(userID),
+		},
+	}
+
+	if sessionID != "" {
+		keyCondition += " AND begins_with(sort_key, :sessionPrefix)"
+		expressionValues[":sessionPrefix"] = &dynamodb.AttributeValue{S: aws.String(sessionID + "#")}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(d.chatTableName),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ProjectionExpression:      aws.String("user_id, sort_key"),
+		ExpressionAttributeValues: expressionValues,
+	}
+
+	var sessionIDs []string
+	deleted := make(map[string]int)
+	var requests []*dynamodb.WriteRequest
+	err := d.client.QueryPages(input, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range output.Items {
+			requests = append(requests, &dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{Key: map[string]*dynamodb.AttributeValue{
+					"user_id":  item["user_id"],
+					"sort_key": item["sort_key"],
+				}},
+			})
+
+			// Sort keys are session_id#timestamp
+			sortKey := aws.StringValue(item["sort_key"].S)
+			messageSessionID := sortKey
+			if i := strings.LastIndex(sortKey, "#"); i >= 0 {
+				messageSessionID = sortKey[:i]
+			}
+			if _, seen := deleted[messageSessionID]; !seen {
+				sessionIDs = append(sessionIDs, messageSessionID)
+			}
+			deleted[messageSessionID]++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query chat messages for deletion: %w", err)
+	}
+
+	for start := 0; start < len(requests); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		unprocessed, err := d.batchWrite(d.chatTableName, requests[start:end])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to delete chat messages: %w", err)
+		}
+		if len(unprocessed) > 0 {
+			return nil, nil, fmt.Errorf("failed to delete chat messages: %d left unprocessed after retries", len(unprocessed))
+		}
+	}
+
+	return sessionIDs, deleted, nil
+}
+
+// Chat Session Operations
+
+// PutStoredChatSession stores a WebSocket chat session, replacing any earlier copy. The table is keyed
+// by session_id and expects TTL to be enabled on expires_at.
+func (d *DynamoDBClient) PutStoredChatSession(session *models.ChatSession) error {
+	item, err := session.ToDynamoDBItem()
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat session: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(d.sessionsTableName),
+		Item:      item,
+	}
+
+	_, err = d.client.PutItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to put chat session: %w", err)
+	}
+
+	return nil
+}
+
+// GetStoredChatSession retrieves a stored chat session, or nil when there is none
+func (d *DynamoDBClient) GetStoredChatSession(sessionID string) (*models.ChatSession, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(d.sessionsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"session_id": {
+				S: aws.String(sessionID),
+			},
+		},
+	}
+
+	result, err := d.client.GetItem(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat session: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var session models.ChatSession
+	if err := session.FromDynamoDBItem(result.Item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chat session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// DeleteStoredChatSession removes a stored chat session
+func (d *DynamoDBClient) DeleteStoredChatSession(sessionID string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.sessionsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"session_id": {
+				S: aws.String(sessionID),
+			},
+		},
+	}
+
+	_, err := d.client.DeleteItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+
+	return nil
+}
+
+// Document Access Log Operations
+
+// PutDocumentAccessLog stores a document access record.
+// The table is keyed by document_id/sort_key and expects TTL to be enabled on expires_at.
+func (d *DynamoDBClient) PutDocumentAccessLog(entry *models.DocumentAccessLog) error {
+	// Set the sort key before marshaling
+	entry.SortKey = entry.GetSortKey()
+
+	item, err := entry.ToDynamoDBItem()
+	if err != nil {
+		return fmt.Errorf("failed to marshal access log entry: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(d.accessLogTableName),
+		Item:      item,
+	}
+
+	_, err = d.client.PutItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to put access log entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetDocumentAccessLogs retrieves access records for a document, latest first
+func (d *DynamoDBClient) GetDocumentAccessLogs(documentID string, limit int) ([]models.DocumentAccessLog, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.accessLogTableName),
+		KeyConditionExpression: aws.String("document_id = :documentID"),
+		// TTL deletion can lag by up to a couple of days, so hide records that have already expired
+		FilterExpression: aws.String("expires_at > :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":documentID": {
+				S: aws.String(documentID),
+			},
+			":now": {
+				N: aws.String(fmt.Sprintf("%d", time.Now().Unix())),
+			},
+		},
+		ScanIndexForward: aws.Bool(false), // Latest first
+	}
+
+	if limit > 0 {
+		input.Limit = aws.Int64(int64(limit))
+	}
+
+	result, err := d.client.Query(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access logs: %w", err)
+	}
+
+	entries := make([]models.DocumentAccessLog, 0, len(result.Items))
+	for _, item := range result.Items {
+		var entry models.DocumentAccessLog
+		if err := entry.FromDynamoDBItem(item); err != nil {
+			continue // Skip invalid items
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Insights Cache Operations
+
+// PutHealthInsights stores a user's generated insights, replacing any previous entry.
+// The table is keyed by user_id and expects TTL to be enabled on expires_at.
+func (d *DynamoDBClient) PutHealthInsights(insights *models.HealthInsights) error {
+	item, err := insights.ToDynamoDBItem()
+	if err != nil {
+		return fmt.Errorf("failed to marshal health insights: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(d.insightsTableName),
+		Item:      item,
+	}
+
+	_, err = d.client.PutItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to put health insights: %w", err)
+	}
+
+	return nil
+}
+
+// GetHealthInsights retrieves a user's cached insights, returning nil when none are stored
+func (d *DynamoDBClient) GetHealthInsights(userID string) (*models.HealthInsights, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(d.insightsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"user_id": {
+				S: aws.String(userID),
+			},
+		},
+	}
+
+	result, err := d.client.GetItem(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get health insights: %w", err)
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var insights models.HealthInsights
+	if err := insights.FromDynamoDBItem(result.Item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal health insights: %w", err)
+	}
+
+	return &insights, nil
+}
+
+// Goal Operations
+
+// PutHealthGoal stores a health goal. The table is keyed by user_id/goal_id.
+func (d *DynamoDBClient) PutHealthGoal(goal *models.HealthGoal) error {
+	item, err := goal.ToDynamoDBItem()
+	if err != nil {
+		return fmt.Errorf("failed to marshal health goal: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(d.goalsTableName),
+		Item:      item,
+	}
+
+	_, err = d.client.PutItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to put health goal: %w", err)
+	}
+
+	return nil
+}
+
+// GetHealthGoals retrieves all goals for a user
+func (d *DynamoDBClient) GetHealthGoals(userID string) ([]models.HealthGoal, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.goalsTableName),
+		KeyConditionExpression: aws.String("user_id = :userID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userID": {
+				S: aws.String(userID),
+			},
+		},
+	}
+
+	goals := make([]models.HealthGoal, 0)
+	err := d.client.QueryPages(input, func(output *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range output.Items {
+			var goal models.HealthGoal
+			if err := goal.FromDynamoDBItem(item); err != nil {
+				continue // Skip invalid items
+			}
+			goals = append(goals, goal)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query health goals: %w", err)
+	}
+
+	return goals, nil
+}
+
+// DeleteHealthGoal removes a user's goal
+func (d *DynamoDBClient) DeleteHealthGoal(userID, goalID string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.goalsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"user_id": {
+				S: aws.String(userID),
+			},
+			"goal_id": {
+				S: aws.String(goalID),
+			},
+		},
+	}
+
+	_, err := d.client.DeleteItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to delete health goal: %w", err)
+	}
+
+	return nil
+}
+
+// User Profile Operations
+
+// PutUserHealthProfile stores a user's health profile. The table is keyed by user_id.
+func (d *DynamoDBClient) PutUserHealthProfile(profile *models.UserHealthProfile) error {
+	item, err := profile.ToDynamoDBItem()
+	if err != nil {
+		return fmt.Errorf("failed to marshal user profile: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(d.profilesTableName),
+		Item:      item,
+	}
+
+	_, err = d.client.PutItem(input)
+	if err != nil {
+		return fmt.Errorf("failed to put user profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserHealthProfile retrieves a user's health profile, returning nil when none is stored
+func (d *DynamoDBClient) GetUserHealthProfile(userID string) (*models.UserHealthProfile, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(d.profilesTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"user_id": {
+				S: aws.String(userID),
+			},
+		},
+	}
+
+	result, err := d.client.GetItem(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var profile models.UserHealthProfile
+	if err := profile.FromDynamoDBItem(result.Item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user profile: %w", err)
+	}
+
+	return &profile, nil
+}
+
+// TableNames returns the configured table names keyed by what they store
+func (d *DynamoDBClient) TableNames() map[string]string {
+	return map[string]string{
+		"health":     d.healthTableName,
+		"documents":  d.documentsTableName,
+		"chat":       d.chatTableName,
+		"access_log": d.accessLogTableName,
+		"insights":   d.insightsTableName,
+		"goals":      d.goalsTableName,
+		"profiles":   d.profilesTableName,
+		"chunks":     d.chunksTableName,
+		"sessions":   d.sessionsTableName,
+	}
+}
+
+// DescribeTable returns the status and approximate size of a table.
+// DynamoDB refreshes item counts roughly every six hours, so they are estimates.
+func (d *DynamoDBClient) DescribeTable(tableName string) (map[string]interface{}, error) {
+	result, err := d.client.DescribeTable(&dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", tableName, err)
+	}
+
+	table := result.Table
+	return map[string]interface{}{
+		"table_name":        tableName,
+		"status":            aws.StringValue(table.TableStatus),
+		"item_count_approx": aws.Int64Value(table.ItemCount),
+		"size_bytes_approx": aws.Int64Value(table.TableSizeBytes),
+	}, nil
+}
+
 // Health check for DynamoDB connection
 func (d *DynamoDBClient) HealthCheck() error {
 	input := &dynamodb.DescribeTableInput{
@@ -0,0 +1,168 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/models"
+)
+
+// heartRates returns n heart rate readings a minute apart
+func heartRates(n int) []*models.HealthMetric {
+	start := time.Now().UTC().Add(-time.Duration(n) * time.Minute)
+	metrics := make([]*models.HealthMetric, n)
+	for i := range metrics {
+		metrics[i] = &models.HealthMetric{
+			UserID:    "user-1",
+			Type:      "heart_rate",
+			Value:     float64(60 + i%40),
+			Unit:      "bpm",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	return metrics
+}
+
+// newTestClient returns a client backed by an in-memory DynamoDB that retries without waiting long
+func newTestClient(t *testing.T) (*config.Config, *database.DynamoDBClient, *dynamotest.Fake) {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.RetryAttempts = 3
+	cfg.RetryBackoffMs = 1
+	db, fake := dynamotest.NewClient(cfg)
+	return cfg, db, fake
+}
+
+func TestBatchPutHealthMetricsRetriesUnprocessedItems(t *testing.T) {
+	cfg, db, fake := newTestClient(t)
+
+	// The first call leaves its last two items unprocessed
+	calls := 0
+	fake.UnprocessedWrites = func(_ string, requests []*dynamodb.WriteRequest) []*dynamodb.WriteRequest {
+		calls++
+		if calls == 1 {
+			return requests[len(requests)-2:]
+		}
+		return nil
+	}
+
+	for i, err := range db.BatchPutHealthMetrics(heartRates(60)) {
+		if err != nil {
+			t.Errorf("metric %d: %v", i, err)
+		}
+	}
+	if got := fake.Calls("BatchWriteItem"); got != 4 {
+		t.Errorf("%d BatchWriteItem calls, want 3 batches and 1 retry", got)
+	}
+	if got := len(fake.Items(cfg.DynamoDBTableHealth)); got != 60 {
+		t.Errorf("stored %d metrics, want 60", got)
+	}
+}
+
+func TestBatchPutHealthMetricsReportsItemsLeftUnprocessed(t *testing.T) {
+	cfg, db, fake := newTestClient(t)
+	metrics := heartRates(30)
+	stuck := metrics[27].GetSortKey()
+
+	fake.UnprocessedWrites = func(_ string, requests []*dynamodb.WriteRequest) []*dynamodb.WriteRequest {
+		for _, request := range requests {
+			if *request.PutRequest.Item["sort_key"].S == stuck {
+				return []*dynamodb.WriteRequest{request}
+			}
+		}
+		return nil
+	}
+
+	errs := db.BatchPutHealthMetrics(metrics)
+	for i, err := range errs {
+		if (err != nil) != (i == 27) {
+			t.Errorf("metric %d: err = %v", i, err)
+		}
+	}
+	// One call for the first batch, then the configured attempts for the second
+	if got := fake.Calls("BatchWriteItem"); got != 1+cfg.RetryAttempts {
+		t.Errorf("%d BatchWriteItem calls, want %d", got, 1+cfg.RetryAttempts)
+	}
+	if got := len(fake.Items(cfg.DynamoDBTableHealth)); got != 29 {
+		t.Errorf("stored %d metrics, want 29", got)
+	}
+}
+
+func TestGetLatestHealthMetricsMatchingPagesPastFilteredReadings(t *testing.T) {
+	_, db, fake := newTestClient(t)
+	fake.MaxPageItems = 4
+
+	// Weight sorts before heart rate in descending key order, so its 20 readings fill the first pages
+	now := time.Now().UTC()
+	for i := 0; i < 20; i++ {
+		weight := &models.HealthMetric{UserID: "user-1", Type: "weight", Value: float64(70 + i), Unit: "kg", Timestamp: now.Add(-time.Duration(i) * time.Hour)}
+		if err := db.PutHealthMetric(weight); err != nil {
+			t.Fatalf("put weight: %v", err)
+		}
+	}
+	for i, source := range []string{models.MetricSourceDerived, models.MetricSourceDerived, models.MetricSourceManual} {
+		heartRate := &models.HealthMetric{UserID: "user-1", Type: "heart_rate", Value: float64(60 + i), Unit: "bpm", Source: source, Timestamp: now.Add(-time.Duration(i) * time.Hour)}
+		if err := db.PutHealthMetric(heartRate); err != nil {
+			t.Fatalf("put heart rate: %v", err)
+		}
+	}
+
+	manual := func(metric *models.HealthMetric) bool { return metric.Source != models.MetricSourceDerived }
+	latest, err := db.GetLatestHealthMetricsMatching("user-1", nil, manual)
+	if err != nil {
+		t.Fatalf("get latest: %v", err)
+	}
+	if weight := latest["weight"]; weight.Value != 70 {
+		t.Errorf("latest weight = %+v, want 70", weight)
+	}
+	if heartRate, ok := latest["heart_rate"]; !ok || heartRate.Value != 62 {
+		t.Errorf("latest manual heart rate = %+v, want 62 from the last page", heartRate)
+	}
+
+	// Paging stops once every requested type is found
+	queries := fake.Calls("Query")
+	latest, err = db.GetLatestHealthMetricsMatching("user-1", []string{"weight"}, nil)
+	if err != nil {
+		t.Fatalf("get latest weight: %v", err)
+	}
+	if len(latest) != 1 || latest["weight"].Value != 70 {
+		t.Errorf("latest = %+v, want only weight 70", latest)
+	}
+	if got := fake.Calls("Query") - queries; got != 1 {
+		t.Errorf("%d Query calls, want 1", got)
+	}
+}
+
+func TestPutChatMessageRetriesThrottling(t *testing.T) {
+	cfg, db, fake := newTestClient(t)
+
+	// The first write is throttled
+	fake.Hook = func(op string, input interface{}) error {
+		if op == "PutItem" && fake.Calls("PutItem") == 1 {
+			return awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throughput exceeded", nil)
+		}
+		return nil
+	}
+
+	message := models.NewChatMessage("user-1", "user", "How is my heart rate?")
+	message.SessionID = "session-1"
+	if err := db.PutChatMessage(message); err != nil {
+		t.Fatalf("put chat message: %v", err)
+	}
+	if got := fake.Calls("PutItem"); got != 2 {
+		t.Errorf("%d PutItem calls, want 1 throttled and 1 retry", got)
+	}
+	if got := len(fake.Items(cfg.DynamoDBTableChat)); got != 1 {
+		t.Errorf("stored %d chat messages, want 1", got)
+	}
+}
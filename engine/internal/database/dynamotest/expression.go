@@ -0,0 +1,477 @@
+package dynamotest
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// node is a parsed condition expression
+type node interface {
+	eval(names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) (bool, error)
+}
+
+// operand is a path (attribute name or #placeholder) or a :value placeholder
+type operand string
+
+// resolve returns the operand's value: the placeholder's value or the item's attribute (nil when missing)
+func (o operand) resolve(names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) (*dynamodb.AttributeValue, error) {
+	if strings.HasPrefix(string(o), ":") {
+		value, exists := values[string(o)]
+		if !exists {
+			return nil, awserr.New("ValidationException", "undefined attribute value "+string(o), nil)
+		}
+		return value, nil
+	}
+	name, err := resolveName(names, string(o))
+	if err != nil {
+		return nil, err
+	}
+	return item[name], nil
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ inner node }
+
+type compareNode struct {
+	op          string
+	left, right operand
+}
+
+type betweenNode struct{ value, low, high operand }
+
+type functionNode struct {
+	name string
+	args []operand
+}
+
+func (n andNode) eval(names map[string]*string, values, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	left, err := n.left.eval(names, values, item)
+	if err != nil || !left {
+		return false, err
+	}
+	return n.right.eval(names, values, item)
+}
+
+func (n orNode) eval(names map[string]*string, values, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	left, err := n.left.eval(names, values, item)
+	if err != nil || left {
+		return left, err
+	}
+	return n.right.eval(names, values, item)
+}
+
+func (n notNode) eval(names map[string]*string, values, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	inner, err := n.inner.eval(names, values, item)
+	return !inner, err
+}
+
+func (n compareNode) eval(names map[string]*string, values, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	left, err := n.left.resolve(names, values, item)
+	if err != nil {
+		return false, err
+	}
+	right, err := n.right.resolve(names, values, item)
+	if err != nil {
+		return false, err
+	}
+	if left == nil || right == nil {
+		return n.op == "<>" && (left == nil) != (right == nil), nil
+	}
+	if !sameType(left, right) {
+		return n.op == "<>", nil
+	}
+
+	c := compareValues(left, right)
+	switch n.op {
+	case "=":
+		return c == 0, nil
+	case "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("unsupported comparison %s", n.op)
+}
+
+func (n betweenNode) eval(names map[string]*string, values, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	low, err := compareNode{op: ">=", left: n.value, right: n.low}.eval(names, values, item)
+	if err != nil || !low {
+		return false, err
+	}
+	return compareNode{op: "<=", left: n.value, right: n.high}.eval(names, values, item)
+}
+
+func (n functionNode) eval(names map[string]*string, values, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	args := make([]*dynamodb.AttributeValue, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.resolve(names, values, item)
+		if err != nil {
+			return false, err
+		}
+		args[i] = value
+	}
+
+	switch n.name {
+	case "attribute_exists":
+		return len(args) == 1 && args[0] != nil, nil
+	case "attribute_not_exists":
+		return len(args) == 1 && args[0] == nil, nil
+	case "begins_with":
+		if len(args) != 2 || args[0] == nil || args[0].S == nil || args[1] == nil || args[1].S == nil {
+			return false, nil
+		}
+		return strings.HasPrefix(*args[0].S, *args[1].S), nil
+	case "contains":
+		if len(args) != 2 || args[0] == nil || args[1] == nil {
+			return false, nil
+		}
+		return containsValue(args[0], args[1]), nil
+	}
+	return false, fmt.Errorf("unsupported function %s", n.name)
+}
+
+// containsValue implements contains(): a substring of a string, or a member of a list or set
+func containsValue(haystack, needle *dynamodb.AttributeValue) bool {
+	switch {
+	case haystack.S != nil:
+		return needle.S != nil && strings.Contains(*haystack.S, *needle.S)
+	case haystack.L != nil:
+		for _, element := range haystack.L {
+			if element != nil && sameType(element, needle) && compareValues(element, needle) == 0 {
+				return true
+			}
+		}
+	case haystack.SS != nil:
+		for _, element := range haystack.SS {
+			if needle.S != nil && aws.StringValue(element) == *needle.S {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameType reports whether two values hold the same scalar type
+func sameType(a, b *dynamodb.AttributeValue) bool {
+	return (a.S != nil) == (b.S != nil) && (a.N != nil) == (b.N != nil) && (a.BOOL != nil) == (b.BOOL != nil)
+}
+
+// compareValues orders two scalar values: numbers numerically, strings and binary bytewise, false
+// before true. Missing values sort first.
+func compareValues(a, b *dynamodb.AttributeValue) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case a.N != nil && b.N != nil:
+		x, _ := new(big.Float).SetString(*a.N)
+		y, _ := new(big.Float).SetString(*b.N)
+		if x == nil || y == nil {
+			return strings.Compare(*a.N, *b.N)
+		}
+		return x.Cmp(y)
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S)
+	case a.B != nil && b.B != nil:
+		return strings.Compare(string(a.B), string(b.B))
+	case a.BOOL != nil && b.BOOL != nil:
+		switch {
+		case *a.BOOL == *b.BOOL:
+			return 0
+		case !*a.BOOL:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return strings.Compare(a.String(), b.String())
+}
+
+// tokenize splits an expression into names, placeholders, operators and punctuation
+func tokenize(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),+-=", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '<' || c == '>':
+			if i+1 < len(expression) && (expression[i+1] == '=' || (c == '<' && expression[i+1] == '>')) {
+				tokens = append(tokens, expression[i:i+2])
+				i += 2
+			} else {
+				tokens = append(tokens, string(c))
+				i++
+			}
+		case c == ':' || c == '#' || c == '_' || c == '.' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(expression) {
+				r := rune(expression[j])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j++
+			}
+			tokens = append(tokens, expression[i:j])
+			i = j
+		default:
+			return nil, awserr.New("ValidationException", fmt.Sprintf("unexpected %q in expression %q", c, expression), nil)
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive-descent parser over expression tokens
+type parser struct {
+	tokens []string
+	pos    int
+	source string
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *parser) expect(token string) error {
+	if got := p.next(); !strings.EqualFold(got, token) {
+		return p.errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return awserr.New("ValidationException", fmt.Sprintf(format, args...)+" in expression "+p.source, nil)
+}
+
+// parseExpression parses a key condition, filter or condition expression
+func parseExpression(expression string) (node, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, source: expression}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return n, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if strings.EqualFold(p.peek(), "NOT") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	token := p.next()
+	if token == "" {
+		return nil, p.errorf("unexpected end")
+	}
+
+	if token == "(" {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+
+	if p.peek() == "(" {
+		p.next()
+		var args []operand
+		for p.peek() != ")" {
+			args = append(args, operand(p.next()))
+			if p.peek() == "," {
+				p.next()
+			}
+			if p.peek() == "" {
+				return nil, p.errorf("unterminated call to %s", token)
+			}
+		}
+		p.next()
+		return functionNode{name: token, args: args}, nil
+	}
+
+	left := operand(token)
+	op := p.next()
+	if strings.EqualFold(op, "BETWEEN") {
+		low := operand(p.next())
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		return betweenNode{value: left, low: low, high: operand(p.next())}, nil
+	}
+	switch op {
+	case "=", "<>", "<", "<=", ">", ">=":
+		return compareNode{op: op, left: left, right: operand(p.next())}, nil
+	}
+	return nil, p.errorf("unexpected %q after %q", op, token)
+}
+
+// applyUpdate applies SET and REMOVE clauses to item
+func applyUpdate(expression string, names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) error {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return err
+	}
+	p := &parser{tokens: tokens, source: expression}
+
+	clause := ""
+	for p.peek() != "" {
+		switch strings.ToUpper(p.peek()) {
+		case "SET", "REMOVE":
+			clause = strings.ToUpper(p.next())
+			continue
+		case ",":
+			p.next()
+			continue
+		}
+
+		name, err := resolveName(names, p.next())
+		if err != nil {
+			return err
+		}
+
+		switch clause {
+		case "REMOVE":
+			delete(item, name)
+		case "SET":
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			value, err := p.parseUpdateValue(names, values, item)
+			if err != nil {
+				return err
+			}
+			item[name] = value
+		default:
+			return p.errorf("unsupported update clause before %q", name)
+		}
+	}
+	return nil
+}
+
+// parseUpdateValue parses a SET value: an operand or if_not_exists call, optionally plus or minus another
+func (p *parser) parseUpdateValue(names map[string]*string, values, item map[string]*dynamodb.AttributeValue) (*dynamodb.AttributeValue, error) {
+	left, err := p.parseUpdateOperand(names, values, item)
+	if err != nil {
+		return nil, err
+	}
+	if op := p.peek(); op == "+" || op == "-" {
+		p.next()
+		right, err := p.parseUpdateOperand(names, values, item)
+		if err != nil {
+			return nil, err
+		}
+		if left == nil || right == nil || left.N == nil || right.N == nil {
+			return nil, p.errorf("arithmetic on a non-number")
+		}
+		x, _ := new(big.Float).SetString(*left.N)
+		y, _ := new(big.Float).SetString(*right.N)
+		if op == "+" {
+			x.Add(x, y)
+		} else {
+			x.Sub(x, y)
+		}
+		return &dynamodb.AttributeValue{N: aws.String(x.Text('f', -1))}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseUpdateOperand(names map[string]*string, values, item map[string]*dynamodb.AttributeValue) (*dynamodb.AttributeValue, error) {
+	token := p.next()
+	if strings.EqualFold(token, "if_not_exists") {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		existing, err := operand(p.next()).resolve(names, values, item)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := operand(p.next()).resolve(names, values, item)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return existing, nil
+		}
+		return fallback, nil
+	}
+	return operand(token).resolve(names, values, item)
+}
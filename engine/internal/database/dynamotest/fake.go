@@ -0,0 +1,623 @@
+// Package dynamotest provides an in-memory stand-in for DynamoDB, for tests of code built on
+// database.DynamoDBClient. It implements the calls and the expression syntax the client uses:
+// key conditions (=, BETWEEN, begins_with), filter and condition expressions (comparisons, AND,
+// OR, NOT, attribute_exists, attribute_not_exists, begins_with, contains) and SET/REMOVE updates
+// with if_not_exists and arithmetic. Query pagination follows DynamoDB: Limit counts items read
+// before the filter is applied, and a page that stops at the limit returns a LastEvaluatedKey.
+package dynamotest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+)
+
+// keySchema names a table's or index's partition and (optional) sort key attributes
+type keySchema struct {
+	hashKey  string
+	rangeKey string
+}
+
+// table holds one table's items keyed by their encoded primary key
+type table struct {
+	schema  keySchema
+	indexes map[string]keySchema
+	items   map[string]map[string]*dynamodb.AttributeValue
+}
+
+// Fake is an in-memory DynamoDB. Calls it doesn't implement panic through the embedded nil
+// interface, which points a test at the missing method.
+type Fake struct {
+	dynamodbiface.DynamoDBAPI
+
+	// MaxPageItems caps the items read by one Query page, standing in for DynamoDB's 1 MB page
+	// limit so tests can exercise pagination. 0 reads whole partitions.
+	MaxPageItems int
+
+	// Hook, when set, runs before every call with the operation name ("PutItem", "Query", ...)
+	// and its input. A non-nil error fails the call with that error.
+	Hook func(op string, input interface{}) error
+
+	// UnprocessedWrites, when set, is called by BatchWriteItem with each table's requests and
+	// returns those to report as unprocessed instead of applying them
+	UnprocessedWrites func(tableName string, requests []*dynamodb.WriteRequest) []*dynamodb.WriteRequest
+
+	mu     sync.Mutex
+	tables map[string]*table
+	calls  map[string]int
+}
+
+// New creates an empty fake with no tables
+func New() *Fake {
+	return &Fake{
+		tables: make(map[string]*table),
+		calls:  make(map[string]int),
+	}
+}
+
+// NewClient creates a fake with the application's tables, named and keyed as cfg and the
+// DynamoDBClient expect, and a DynamoDBClient that uses it
+func NewClient(cfg *config.Config) (*database.DynamoDBClient, *Fake) {
+	fake := New()
+	fake.AddTable(cfg.DynamoDBTableHealth, "user_id", "sort_key")
+	fake.AddTable(cfg.DynamoDBTableDocs, "user_id", "sort_key")
+	fake.AddTable(cfg.DynamoDBTableChat, "user_id", "sort_key")
+	fake.AddIndex(cfg.DynamoDBTableChat, cfg.DynamoDBChatTimestampIndex, "user_id", "sent_at")
+	fake.AddTable(cfg.DynamoDBTableAccessLog, "document_id", "sort_key")
+	fake.AddTable(cfg.DynamoDBTableInsights, "user_id", "")
+	fake.AddTable(cfg.DynamoDBTableGoals, "user_id", "goal_id")
+	fake.AddTable(cfg.DynamoDBTableProfiles, "user_id", "")
+	fake.AddTable(cfg.DynamoDBTableChunks, "document_id", "chunk_index")
+	fake.AddTable(cfg.DynamoDBTableSessions, "session_id", "")
+
+	return database.NewDynamoDBClientWithAPI(fake, cfg), fake
+}
+
+// AddTable adds an empty table. rangeKey is empty for tables with only a partition key.
+func (f *Fake) AddTable(name, hashKey, rangeKey string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tables[name] = &table{
+		schema:  keySchema{hashKey: hashKey, rangeKey: rangeKey},
+		indexes: make(map[string]keySchema),
+		items:   make(map[string]map[string]*dynamodb.AttributeValue),
+	}
+}
+
+// AddIndex adds a global secondary index to a table
+func (f *Fake) AddIndex(tableName, indexName, hashKey, rangeKey string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tables[tableName].indexes[indexName] = keySchema{hashKey: hashKey, rangeKey: rangeKey}
+}
+
+// Items returns copies of a table's items in primary key order
+func (f *Fake) Items(tableName string) []map[string]*dynamodb.AttributeValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := f.tables[tableName]
+	items := make([]map[string]*dynamodb.AttributeValue, 0, len(t.items))
+	for _, item := range t.items {
+		items = append(items, copyItem(item))
+	}
+	sortItems(items, t.schema, t.schema, true)
+	return items
+}
+
+// Calls returns how many times op was called, including failed calls
+func (f *Fake) Calls(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[op]
+}
+
+// begin counts a call and runs the hook
+func (f *Fake) begin(op string, input interface{}) error {
+	f.mu.Lock()
+	f.calls[op]++
+	hook := f.Hook
+	f.mu.Unlock()
+
+	if hook != nil {
+		return hook(op, input)
+	}
+	return nil
+}
+
+// table looks a table up; the caller holds f.mu
+func (f *Fake) table(name *string) (*table, error) {
+	t, exists := f.tables[aws.StringValue(name)]
+	if !exists {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "table not found: "+aws.StringValue(name), nil)
+	}
+	return t, nil
+}
+
+// itemKey encodes an item's primary key under schema
+func itemKey(schema keySchema, item map[string]*dynamodb.AttributeValue) (string, error) {
+	hash := item[schema.hashKey]
+	if hash == nil {
+		return "", awserr.New("ValidationException", "missing key attribute "+schema.hashKey, nil)
+	}
+	key := encodeValue(hash)
+	if schema.rangeKey != "" {
+		rangeValue := item[schema.rangeKey]
+		if rangeValue == nil {
+			return "", awserr.New("ValidationException", "missing key attribute "+schema.rangeKey, nil)
+		}
+		key += "\x00" + encodeValue(rangeValue)
+	}
+	return key, nil
+}
+
+// keyOf extracts the attributes of schema from an item
+func keyOf(schema keySchema, item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	key := map[string]*dynamodb.AttributeValue{schema.hashKey: item[schema.hashKey]}
+	if schema.rangeKey != "" {
+		key[schema.rangeKey] = item[schema.rangeKey]
+	}
+	return key
+}
+
+// PutItem stores an item, checking ConditionExpression against the item it replaces
+func (f *Fake) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	if err := f.begin("PutItem", input); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := itemKey(t.schema, input.Item)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCondition(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, t.items[key]); err != nil {
+		return nil, err
+	}
+
+	t.items[key] = copyItem(input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// PutItemWithContext stores an item
+func (f *Fake) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return f.PutItem(input)
+}
+
+// GetItem returns the item with the given key, or an empty output when there is none
+func (f *Fake) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if err := f.begin("GetItem", input); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := itemKey(t.schema, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	item, exists := t.items[key]
+	if !exists {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: copyItem(item)}, nil
+}
+
+// GetItemWithContext returns the item with the given key
+func (f *Fake) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return f.GetItem(input)
+}
+
+// DeleteItem removes the item with the given key, checking ConditionExpression first
+func (f *Fake) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	if err := f.begin("DeleteItem", input); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := itemKey(t.schema, input.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCondition(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, t.items[key]); err != nil {
+		return nil, err
+	}
+
+	delete(t.items, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// DeleteItemWithContext removes the item with the given key
+func (f *Fake) DeleteItemWithContext(_ aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	return f.DeleteItem(input)
+}
+
+// UpdateItem applies UpdateExpression to the item with the given key, creating it when missing,
+// and returns the whole updated item for any ReturnValues setting other than NONE
+func (f *Fake) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	if err := f.begin("UpdateItem", input); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := itemKey(t.schema, input.Key)
+	if err != nil {
+		return nil, err
+	}
+	existing := t.items[key]
+	if err := checkCondition(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, existing); err != nil {
+		return nil, err
+	}
+
+	item := copyItem(existing)
+	if item == nil {
+		item = copyItem(input.Key)
+	}
+	if err := applyUpdate(aws.StringValue(input.UpdateExpression), input.ExpressionAttributeNames, input.ExpressionAttributeValues, item); err != nil {
+		return nil, err
+	}
+	t.items[key] = item
+
+	output := &dynamodb.UpdateItemOutput{}
+	if returnValues := aws.StringValue(input.ReturnValues); returnValues != "" && returnValues != dynamodb.ReturnValueNone {
+		output.Attributes = copyItem(item)
+	}
+	return output, nil
+}
+
+// UpdateItemWithContext applies an update expression
+func (f *Fake) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	return f.UpdateItem(input)
+}
+
+// BatchWriteItem applies put and delete requests, leaving those chosen by UnprocessedWrites
+// unprocessed
+func (f *Fake) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := f.begin("BatchWriteItem", input); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, requests := range input.RequestItems {
+		total += len(requests)
+	}
+	if total > 25 {
+		return nil, awserr.New("ValidationException", "too many items in BatchWriteItem", nil)
+	}
+
+	output := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]*dynamodb.WriteRequest{}}
+	for tableName, requests := range input.RequestItems {
+		if f.UnprocessedWrites != nil {
+			unprocessed := f.UnprocessedWrites(tableName, requests)
+			if len(unprocessed) > 0 {
+				output.UnprocessedItems[tableName] = unprocessed
+				skip := make(map[*dynamodb.WriteRequest]bool, len(unprocessed))
+				for _, request := range unprocessed {
+					skip[request] = true
+				}
+				var apply []*dynamodb.WriteRequest
+				for _, request := range requests {
+					if !skip[request] {
+						apply = append(apply, request)
+					}
+				}
+				requests = apply
+			}
+		}
+
+		if err := f.applyWrites(tableName, requests); err != nil {
+			return nil, err
+		}
+	}
+
+	return output, nil
+}
+
+// applyWrites stores or deletes the items of a batch
+func (f *Fake) applyWrites(tableName string, requests []*dynamodb.WriteRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(aws.String(tableName))
+	if err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		switch {
+		case request.PutRequest != nil:
+			key, err := itemKey(t.schema, request.PutRequest.Item)
+			if err != nil {
+				return err
+			}
+			t.items[key] = copyItem(request.PutRequest.Item)
+		case request.DeleteRequest != nil:
+			key, err := itemKey(t.schema, request.DeleteRequest.Key)
+			if err != nil {
+				return err
+			}
+			delete(t.items, key)
+		}
+	}
+	return nil
+}
+
+// BatchWriteItemWithContext applies put and delete requests
+func (f *Fake) BatchWriteItemWithContext(_ aws.Context, input *dynamodb.BatchWriteItemInput, _ ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	return f.BatchWriteItem(input)
+}
+
+// Query reads one page of a partition of a table or index
+func (f *Fake) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	if err := f.begin("Query", input); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	schema := t.schema
+	if input.IndexName != nil {
+		index, exists := t.indexes[*input.IndexName]
+		if !exists {
+			return nil, awserr.New("ValidationException", "index not found: "+*input.IndexName, nil)
+		}
+		schema = index
+	}
+
+	keyCondition, err := parseExpression(aws.StringValue(input.KeyConditionExpression))
+	if err != nil {
+		return nil, err
+	}
+	var filter node
+	if expression := aws.StringValue(input.FilterExpression); expression != "" {
+		if filter, err = parseExpression(expression); err != nil {
+			return nil, err
+		}
+	}
+
+	names, values := input.ExpressionAttributeNames, input.ExpressionAttributeValues
+	var matches []map[string]*dynamodb.AttributeValue
+	for _, item := range t.items {
+		if item[schema.hashKey] == nil || (schema.rangeKey != "" && item[schema.rangeKey] == nil) {
+			continue // Not projected into the index
+		}
+		ok, err := keyCondition.eval(names, values, item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, item)
+		}
+	}
+	ascending := input.ScanIndexForward == nil || *input.ScanIndexForward
+	sortItems(matches, schema, t.schema, ascending)
+
+	// Resume after ExclusiveStartKey, which need not be an item that still exists
+	start := 0
+	if input.ExclusiveStartKey != nil {
+		start = len(matches)
+		for i, item := range matches {
+			if orderItems(input.ExclusiveStartKey, item, schema, t.schema, ascending) < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	pageSize := len(matches) - start
+	limited := false
+	if input.Limit != nil && int(*input.Limit) < pageSize {
+		pageSize = int(*input.Limit)
+		limited = true
+	} else if input.Limit != nil && int(*input.Limit) == pageSize && pageSize > 0 {
+		limited = true // DynamoDB returns a key whenever a page stops at its limit
+	}
+	if f.MaxPageItems > 0 && f.MaxPageItems < pageSize {
+		pageSize = f.MaxPageItems
+		limited = true
+	}
+	page := matches[start : start+pageSize]
+
+	output := &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{}}
+	for _, item := range page {
+		if filter != nil {
+			ok, err := filter.eval(names, values, item)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		output.Items = append(output.Items, copyItem(item))
+	}
+	output.Count = aws.Int64(int64(len(output.Items)))
+	output.ScannedCount = aws.Int64(int64(len(page)))
+
+	if limited && len(page) > 0 {
+		last := page[len(page)-1]
+		lastKey := keyOf(t.schema, last)
+		for name, value := range keyOf(schema, last) {
+			lastKey[name] = value
+		}
+		output.LastEvaluatedKey = copyItem(lastKey)
+	}
+
+	return output, nil
+}
+
+// QueryWithContext reads one page of a partition
+func (f *Fake) QueryWithContext(_ aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	return f.Query(input)
+}
+
+// QueryPages reads pages until fn returns false or the partition is exhausted
+func (f *Fake) QueryPages(input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool) error {
+	pageInput := *input
+	for {
+		output, err := f.Query(&pageInput)
+		if err != nil {
+			return err
+		}
+		lastPage := output.LastEvaluatedKey == nil
+		if !fn(output, lastPage) || lastPage {
+			return nil
+		}
+		pageInput.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// QueryPagesWithContext reads pages until fn returns false or the partition is exhausted
+func (f *Fake) QueryPagesWithContext(_ aws.Context, input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool, _ ...request.Option) error {
+	return f.QueryPages(input, fn)
+}
+
+// DescribeTable reports a table as active with its current item count
+func (f *Fake) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	if err := f.begin("DescribeTable", input); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+		TableName:      input.TableName,
+		TableStatus:    aws.String(dynamodb.TableStatusActive),
+		ItemCount:      aws.Int64(int64(len(t.items))),
+		TableSizeBytes: aws.Int64(0),
+	}}, nil
+}
+
+// DescribeTableWithContext reports a table as active
+func (f *Fake) DescribeTableWithContext(_ aws.Context, input *dynamodb.DescribeTableInput, _ ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return f.DescribeTable(input)
+}
+
+// checkCondition fails with ConditionalCheckFailedException when expression is set and doesn't
+// hold for item (nil when there is no item)
+func checkCondition(expression *string, names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) error {
+	if aws.StringValue(expression) == "" {
+		return nil
+	}
+	condition, err := parseExpression(*expression)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		item = map[string]*dynamodb.AttributeValue{}
+	}
+	ok, err := condition.eval(names, values, item)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "the conditional request failed", nil)
+	}
+	return nil
+}
+
+// sortItems orders items by the query's key schema, breaking ties by the table's key so results
+// are deterministic
+func sortItems(items []map[string]*dynamodb.AttributeValue, schema, tableSchema keySchema, ascending bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return orderItems(items[i], items[j], schema, tableSchema, ascending) < 0
+	})
+}
+
+// orderItems compares two items (or keys) in query order: negative when a comes first
+func orderItems(a, b map[string]*dynamodb.AttributeValue, schema, tableSchema keySchema, ascending bool) int {
+	c := 0
+	for _, name := range []string{schema.hashKey, schema.rangeKey, tableSchema.hashKey, tableSchema.rangeKey} {
+		if name == "" || c != 0 {
+			continue
+		}
+		c = compareValues(a[name], b[name])
+	}
+	if !ascending {
+		return -c
+	}
+	return c
+}
+
+// encodeValue renders a key attribute as a string
+func encodeValue(value *dynamodb.AttributeValue) string {
+	switch {
+	case value.S != nil:
+		return "S:" + *value.S
+	case value.N != nil:
+		return "N:" + *value.N
+	case value.B != nil:
+		return "B:" + string(value.B)
+	}
+	return fmt.Sprintf("?:%v", value)
+}
+
+// copyItem returns a shallow copy of an item's attribute map, or nil for a nil item
+func copyItem(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	copied := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, value := range item {
+		copied[name] = value
+	}
+	return copied
+}
+
+// resolveName maps a #placeholder to its attribute name
+func resolveName(names map[string]*string, name string) (string, error) {
+	if !strings.HasPrefix(name, "#") {
+		return name, nil
+	}
+	resolved, exists := names[name]
+	if !exists {
+		return "", awserr.New("ValidationException", "undefined attribute name "+name, nil)
+	}
+	return *resolved, nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/middleware"
+	"health-dashboard-backend/internal/services"
+	"health-dashboard-backend/internal/utils"
+)
+
+// AdminHandler handles operational endpoints. It doesn't check roles itself; its routes must
+// be guarded with middleware.RequireRole.
+type AdminHandler struct {
+	diagnosticsService *services.DiagnosticsService
+	chatService        *services.ChatService
+	logger             *zap.Logger
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(diagnosticsService *services.DiagnosticsService, chatService *services.ChatService, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		diagnosticsService: diagnosticsService,
+		chatService:        chatService,
+		logger:             logger,
+	}
+}
+
+// GetDiagnostics handles GET /api/admin/diagnostics
+func (h *AdminHandler) GetDiagnostics(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	diagnostics := h.diagnosticsService.RunDiagnostics(ctx)
+
+	h.logger.Info("Diagnostics run",
+		zap.String("user_id", userID),
+		zap.Bool("healthy", diagnostics.Healthy),
+		zap.Int64("duration_ms", diagnostics.DurationMs))
+
+	message := "All components healthy"
+	if !diagnostics.Healthy {
+		message = "One or more components are unhealthy"
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, message, diagnostics)
+}
+
+// GetMessagePrompt handles GET /api/admin/chat/messages/:id/prompt
+func (h *AdminHandler) GetMessagePrompt(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	messageID := c.Param("id")
+	ownerID := c.Query("user_id")
+	sessionID := c.Query("session_id")
+	if ownerID == "" || sessionID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "user_id and session_id are required")
+		return
+	}
+
+	prompt, err := h.chatService.GetMessagePrompt(ownerID, sessionID, messageID)
+	if err != nil {
+		writeMessagePromptError(c, h.logger, err, ownerID, sessionID, messageID)
+		return
+	}
+
+	// Prompts contain the owner's health data, so every admin read is logged
+	h.logger.Info("Message prompt retrieved by admin",
+		zap.String("admin_id", userID),
+		zap.String("user_id", ownerID),
+		zap.String("session_id", sessionID),
+		zap.String("message_id", messageID))
+
+	utils.SuccessResponse(c, http.StatusOK, "Message prompt retrieved successfully", prompt)
+}
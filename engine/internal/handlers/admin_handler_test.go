@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/middleware"
+	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/internal/services"
+)
+
+// stubRoles makes RequireRole serve fixed roles per user for the rest of the test
+func stubRoles(t *testing.T, roles map[string][]string) {
+	t.Helper()
+
+	middleware.SetRoleLookup(func(ctx context.Context, userID string) ([]string, error) {
+		return roles[userID], nil
+	})
+	t.Cleanup(func() { middleware.SetRoleLookup(nil) })
+}
+
+func TestAdminRoutesRequireAdminRole(t *testing.T) {
+	cfg := testConfig(t)
+	db, _ := dynamotest.NewClient(cfg)
+	chatService := services.NewChatService(db, cfg)
+	handler := NewAdminHandler(nil, chatService, zap.NewNop())
+
+	response := &models.ChatResponse{ID: "reply-1", Message: "Fine.", Prompt: &models.PromptRecord{Provider: "sonar"}}
+	if err := chatService.SaveExchange("user-1", "session-1", "How am I?", response); err != nil {
+		t.Fatalf("save exchange: %v", err)
+	}
+
+	stubRoles(t, map[string][]string{"admin-1": {"admin"}, "user-2": {"user"}})
+	path := "/api/admin/chat/messages/reply-1/prompt?user_id=user-1&session_id=session-1"
+
+	for _, tc := range []struct {
+		caller string
+		want   int
+	}{
+		{"admin-1", http.StatusOK},
+		{"user-2", http.StatusForbidden},
+		{"user-1", http.StatusForbidden}, // Owning the message doesn't grant admin access
+	} {
+		router := newTestRouter(tc.caller)
+		router.GET("/api/admin/chat/messages/:id/prompt", middleware.RequireRole("admin"), handler.GetMessagePrompt)
+
+		recorder, _ := serve(t, router, http.MethodGet, path, nil)
+		if recorder.Code != tc.want {
+			t.Errorf("%s: status %d, want %d (%s)", tc.caller, recorder.Code, tc.want, recorder.Body.String())
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"health-dashboard-backend/internal/middleware"
 	"health-dashboard-backend/internal/services"
+	"health-dashboard-backend/internal/utils"
 )
 
 // AuthHandler handles authentication-related requests
@@ -28,14 +29,14 @@ func NewAuthHandler(authService *services.AuthService, logger *zap.Logger) *Auth
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	user, err := h.authService.GetUserProfile(c.Request.Context(), userID)
 	if err != nil {
 		h.logger.Error("Failed to get user profile", zap.String("user_id", userID), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user profile"})
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to get user profile")
 		return
 	}
 
@@ -67,7 +68,7 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -76,7 +77,7 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid request body")
 		return
 	}
 
@@ -84,7 +85,7 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	updatedUser, err := h.authService.UpdateUserMetadata(c.Request.Context(), userID, req.PublicMetadata)
 	if err != nil {
 		h.logger.Error("Failed to update user metadata", zap.String("user_id", userID), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to update profile")
 		return
 	}
 
@@ -116,14 +117,14 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 func (h *AuthHandler) GetUserRoles(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	roles, err := h.authService.GetUserRoles(c.Request.Context(), userID)
 	if err != nil {
 		h.logger.Error("Failed to get user roles", zap.String("user_id", userID), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user roles"})
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to get user roles")
 		return
 	}
 
@@ -137,7 +138,7 @@ func (h *AuthHandler) GetUserRoles(c *gin.Context) {
 func (h *AuthHandler) UpdateUserRoles(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -145,12 +146,12 @@ func (h *AuthHandler) UpdateUserRoles(c *gin.Context) {
 	isAdmin, err := h.authService.HasRole(c.Request.Context(), userID, "admin")
 	if err != nil {
 		h.logger.Error("Failed to check admin role", zap.String("user_id", userID), zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to verify permissions")
 		return
 	}
 
 	if !isAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		utils.ErrorResponseWithCode(c, http.StatusForbidden, utils.CodeForbidden, "Admin access required")
 		return
 	}
 
@@ -160,7 +161,7 @@ func (h *AuthHandler) UpdateUserRoles(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid request body")
 		return
 	}
 
@@ -175,7 +176,7 @@ func (h *AuthHandler) UpdateUserRoles(c *gin.Context) {
 			zap.String("admin_user_id", userID),
 			zap.String("target_user_id", req.TargetUserID),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user roles"})
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to update user roles")
 		return
 	}
 
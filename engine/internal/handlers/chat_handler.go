@@ -2,10 +2,20 @@ package handlers
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 
@@ -13,60 +23,232 @@ import (
 	"health-dashboard-backend/internal/models"
 	"health-dashboard-backend/internal/services"
 	"health-dashboard-backend/internal/utils"
+	"health-dashboard-backend/pkg/ai"
 )
 
 // ChatHandler handles chat endpoints
 type ChatHandler struct {
-	aiAgent  *services.AIAgent
-	logger   *zap.Logger
-	upgrader websocket.Upgrader
-	sessions map[string]*ChatSession
+	aiAgent     *services.AIAgent
+	chatService *services.ChatService
+	logger      *zap.Logger
+	upgrader    websocket.Upgrader
+
+	// streamCoalesce is how long streamed tokens are merged before being sent; 0 sends each one
+	streamCoalesce time.Duration
+
+	// Origins allowed to open a WebSocket, matching the CORS configuration
+	allowedOrigins  []string
+	allowAllOrigins bool
+
+	// sessionsMu guards sessions and each session's LastActive
+	sessionsMu sync.RWMutex
+	sessions   map[string]*ChatSession
+
+	// sessionStore keeps session history so a client can resume a session after reconnecting
+	sessionStore services.SessionStore
+
+	// rateLimiter is shared with POST /api/chat so WebSocket messages count against the same
+	// allowance; nil doesn't limit
+	rateLimiter *middleware.RateLimiter
+
+	// WebSocket keepalive: pings every pingInterval and drops connections that don't answer within
+	// two intervals. Sessions without a client message for idleTimeout are closed by the reaper.
+	pingInterval time.Duration
+	idleTimeout  time.Duration
+	stopReaper   chan struct{}
+	stopOnce     sync.Once
 }
 
 // ChatSession represents an active chat session
 type ChatSession struct {
-	UserID     string
-	SessionID  string
-	Connection *websocket.Conn
-	Messages   []models.ChatMessage
-	LastActive time.Time
+	UserID      string
+	SessionID   string
+	Connection  *websocket.Conn
+	Messages    []models.ChatMessage
+	ConnectedAt time.Time
+	LastActive  time.Time
+	RemoteAddr  string
+	UserAgent   string
+
+	// deleted is set, under the handler's sessionsMu, once the user deletes the session so its
+	// history isn't written back
+	deleted bool
+
+	// writeMu serializes writes to Connection, which allows only one concurrent writer
+	writeMu sync.Mutex
 }
 
 // NewChatHandler creates a new chat handler
-func NewChatHandler(aiAgent *services.AIAgent, logger *zap.Logger) *ChatHandler {
-	upgrader := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			// In production, implement proper origin checking
-			return true
-		},
+func NewChatHandler(aiAgent *services.AIAgent, chatService *services.ChatService, logger *zap.Logger) *ChatHandler {
+	ch := &ChatHandler{
+		aiAgent:     aiAgent,
+		chatService: chatService,
+		logger:      logger,
+		sessions:    make(map[string]*ChatSession),
+		stopReaper:  make(chan struct{}),
+	}
+	ch.sessionStore = services.NewMemorySessionStore(0)
+	ch.upgrader = websocket.Upgrader{CheckOrigin: ch.checkOrigin}
+
+	return ch
+}
+
+// SetAllowedOrigins sets the origins allowed to open a WebSocket. Until it is called only clients
+// that send no Origin header, which browsers always do, can connect.
+func (ch *ChatHandler) SetAllowedOrigins(origins []string, allowAll bool) {
+	ch.allowedOrigins = origins
+	ch.allowAllOrigins = allowAll
+}
+
+// checkOrigin rejects WebSocket handshakes from origins outside the CORS allowlist, so other
+// sites can't open a socket with the user's credentials. The upgrader answers rejected
+// handshakes with 403 Forbidden.
+func (ch *ChatHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || ch.allowAllOrigins || middleware.IsOriginAllowed(origin, ch.allowedOrigins) {
+		return true
+	}
+
+	ch.logger.Warn("Rejected WebSocket connection from disallowed origin",
+		zap.String("origin", origin),
+		zap.String("remote_addr", r.RemoteAddr))
+	return false
+}
+
+// SetSessionStore sets where WebSocket session history is kept; defaults to process memory
+func (ch *ChatHandler) SetSessionStore(store services.SessionStore) {
+	ch.sessionStore = store
+}
+
+// SetWebSocketTimeouts enables WebSocket keepalive pings every pingInterval and starts a reaper
+// that closes sessions idle for longer than idleTimeout. Zero disables either.
+func (ch *ChatHandler) SetWebSocketTimeouts(pingInterval, idleTimeout time.Duration) {
+	ch.pingInterval = pingInterval
+	ch.idleTimeout = idleTimeout
+	if idleTimeout <= 0 {
+		return
 	}
 
-	return &ChatHandler{
-		aiAgent:  aiAgent,
-		logger:   logger,
-		upgrader: upgrader,
-		sessions: make(map[string]*ChatSession),
+	// Check often enough that a session overstays its timeout by at most a minute
+	go func() {
+		ticker := time.NewTicker(min(idleTimeout/2, time.Minute))
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				ch.reapIdleSessions(now)
+			case <-ch.stopReaper:
+				return
+			}
+		}
+	}()
+}
+
+// reapIdleSessions closes and removes sessions with no client message for longer than idleTimeout
+func (ch *ChatHandler) reapIdleSessions(now time.Time) {
+	ch.sessionsMu.Lock()
+	var idle []*ChatSession
+	for sessionID, session := range ch.sessions {
+		if now.Sub(session.LastActive) > ch.idleTimeout {
+			idle = append(idle, session)
+			delete(ch.sessions, sessionID)
+		}
 	}
+	ch.sessionsMu.Unlock()
+
+	for _, session := range idle {
+		closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle timeout")
+		session.Connection.WriteControl(websocket.CloseMessage, closeMsg, now.Add(5*time.Second))
+		session.Connection.Close()
+
+		ch.logger.Info("Closed idle WebSocket session",
+			zap.String("user_id", session.UserID),
+			zap.String("session_id", session.SessionID))
+	}
+}
+
+// SetRateLimiter sets the limiter each WebSocket chat message takes a token from. Pass the one
+// guarding POST /api/chat so both paths share a user's allowance.
+func (ch *ChatHandler) SetRateLimiter(limiter *middleware.RateLimiter) {
+	ch.rateLimiter = limiter
+}
+
+// SetStreamCoalesceInterval sets how long streamed tokens are merged into one SSE event, so fast
+// token streams don't turn into many tiny writes. 0 sends each token as it arrives.
+func (ch *ChatHandler) SetStreamCoalesceInterval(interval time.Duration) {
+	ch.streamCoalesce = interval
+}
+
+// AnalyzeQuery handles POST /api/chat/analyze. It reports how a query would be routed without
+// retrieving context or calling the LLM, so it is cheap enough for UI hints.
+func (ch *ChatHandler) AnalyzeQuery(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	var request models.AnalyzeQueryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Message is required")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Query analyzed successfully", ch.aiAgent.AnalyzeQuery(request.Message))
 }
 
 // ProcessQuery handles POST /api/chat
 func (ch *ChatHandler) ProcessQuery(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	var request models.ChatRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		ch.logger.Error("Failed to bind chat request", zap.Error(err))
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid request format")
 		return
 	}
 
 	// Validate request
 	if request.Message == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Message is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Message is required")
+		return
+	}
+
+	if request.Language != "" {
+		if _, ok := ai.NormalizeLanguage(request.Language); !ok {
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Unsupported language code: "+request.Language)
+			return
+		}
+	}
+
+	// The request context can override the assistant's persona and locale
+	if persona := request.Context["persona"]; persona != "" {
+		if _, ok := ai.NormalizePersona(persona); !ok {
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Unsupported persona: "+persona)
+			return
+		}
+	}
+	if request.Locale == "" {
+		request.Locale = request.Context["locale"]
+	}
+	if request.Locale != "" {
+		if _, ok := ai.NormalizeLocale(request.Locale); !ok {
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid locale: "+request.Locale)
+			return
+		}
+	}
+
+	if (request.MaxSources != nil && *request.MaxSources < 0) || (request.MaxSuggestions != nil && *request.MaxSuggestions < 0) {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "max_sources and max_suggestions must be non-negative")
+		return
+	}
+
+	if request.MaxTokens < 0 {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "max_tokens must be non-negative")
 		return
 	}
 
@@ -74,13 +256,26 @@ func (ch *ChatHandler) ProcessQuery(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
-	response, err := ch.aiAgent.ProcessQuery(ctx, userID, request.Message)
+	if request.Stream {
+		ch.streamQuery(c, ctx, userID, &request)
+		return
+	}
+
+	response, err := ch.aiAgent.ProcessQuery(ctx, userID, request.SessionID, request.Message, services.QueryOptions{
+		Language:       request.Language,
+		MaxSources:     request.MaxSources,
+		MaxSuggestions: request.MaxSuggestions,
+		Deterministic:  request.Deterministic,
+		MaxTokens:      request.MaxTokens,
+		Persona:        request.Context["persona"],
+		Locale:         request.Locale,
+	})
 	if err != nil {
 		ch.logger.Error("Failed to process chat query",
 			zap.String("user_id", userID),
 			zap.String("message", request.Message),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process query")
+		utils.ErrorResponseWithCode(c, http.StatusBadGateway, utils.CodeUpstreamError, "Failed to process query")
 		return
 	}
 
@@ -91,6 +286,9 @@ func (ch *ChatHandler) ProcessQuery(c *gin.Context) {
 		response.SessionID = generateSessionID()
 	}
 
+	// Persist the exchange; a storage failure shouldn't fail the response
+	ch.persistExchange(userID, response.SessionID, request.Message, response)
+
 	ch.logger.Info("Chat query processed successfully",
 		zap.String("user_id", userID),
 		zap.String("session_id", response.SessionID),
@@ -99,39 +297,245 @@ func (ch *ChatHandler) ProcessQuery(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Query processed successfully", response)
 }
 
+// streamQuery streams the AI response for a chat request as Server-Sent Events
+func (ch *ChatHandler) streamQuery(c *gin.Context, ctx context.Context, userID string, request *models.ChatRequest) {
+	stream, err := ch.aiAgent.ProcessQueryStream(ctx, userID, request.SessionID, request.Message, services.QueryOptions{
+		Language:       request.Language,
+		MaxSources:     request.MaxSources,
+		MaxSuggestions: request.MaxSuggestions,
+		Deterministic:  request.Deterministic,
+		MaxTokens:      request.MaxTokens,
+		Persona:        request.Context["persona"],
+		Locale:         request.Locale,
+	})
+	if err != nil {
+		ch.logger.Error("Failed to start chat stream",
+			zap.String("user_id", userID),
+			zap.String("message", request.Message),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusBadGateway, utils.CodeUpstreamError, "Failed to process query")
+		return
+	}
+
+	sessionID := request.SessionID
+	if sessionID == "" {
+		sessionID = generateSessionID()
+	}
+	responseID := "resp_" + time.Now().Format("20060102150405") + "_" + randomStringChat(6)
+
+	startSSE(c)
+
+	var content, pending strings.Builder
+	tokensUsed := 0
+
+	// send writes the tokens merged since the last event
+	send := func() {
+		if pending.Len() == 0 {
+			return
+		}
+		ch.writeStreamChunk(c, models.StreamChunk{
+			ID:      responseID,
+			Content: pending.String(),
+		})
+		pending.Reset()
+	}
+
+	// A nil channel never fires, so without coalescing every token is sent as it arrives
+	var tick <-chan time.Time
+	if ch.streamCoalesce > 0 {
+		ticker := time.NewTicker(ch.streamCoalesce)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+stream:
+	for {
+		select {
+		case chunk, ok := <-stream.Chunks:
+			if !ok {
+				break stream
+			}
+
+			if chunk.Err != nil {
+				send()
+				ch.logger.Error("Chat stream failed",
+					zap.String("user_id", userID),
+					zap.String("session_id", sessionID),
+					zap.Error(chunk.Err))
+				c.SSEvent("error", models.ErrorMessage{
+					Code:    http.StatusInternalServerError,
+					Message: "Failed to process query",
+				})
+				c.Writer.Flush()
+				return
+			}
+
+			content.WriteString(chunk.Content)
+			pending.WriteString(chunk.Content)
+			if chunk.Done {
+				tokensUsed = chunk.TokensUsed
+				break stream
+			}
+
+			if tick == nil {
+				send()
+			}
+		case <-tick:
+			send()
+		}
+	}
+
+	// The final frame carries the rest of the text and the enriched response
+	response := stream.Finish(content.String(), tokensUsed)
+	response.ID = responseID
+	response.SessionID = sessionID
+	ch.writeStreamChunk(c, models.StreamChunk{
+		ID:       responseID,
+		Content:  pending.String(),
+		Done:     true,
+		Response: response,
+	})
+
+	ch.persistExchange(userID, sessionID, request.Message, response)
+
+	ch.logger.Info("Chat query streamed successfully",
+		zap.String("user_id", userID),
+		zap.String("session_id", sessionID),
+		zap.Int("tokens_used", tokensUsed))
+}
+
+// writeStreamChunk writes a single SSE data frame and flushes it to the client
+func (ch *ChatHandler) writeStreamChunk(c *gin.Context, chunk models.StreamChunk) {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		ch.logger.Error("Failed to marshal stream chunk", zap.Error(err))
+		return
+	}
+
+	fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+	c.Writer.Flush()
+}
+
 // GetChatHistory handles GET /api/chat/history
 func (ch *ChatHandler) GetChatHistory(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	sessionID := c.Query("session_id")
-	limit := c.DefaultQuery("limit", "50")
+	limitStr := c.DefaultQuery("limit", "50")
 
-	// For now, return placeholder history
-	// In a production system, you'd store and retrieve from a database
-	history := &models.ChatHistory{
-		UserID:     userID,
-		Sessions:   []models.ChatSession{},
-		TotalCount: 0,
-		HasMore:    false,
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 500 {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid limit parameter (1-500)")
+		return
+	}
+
+	history, err := ch.chatService.GetChatHistory(userID, sessionID, limit)
+	if err != nil {
+		ch.logger.Error("Failed to get chat history",
+			zap.String("user_id", userID),
+			zap.String("session_id", sessionID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve chat history")
+		return
 	}
 
 	ch.logger.Info("Chat history retrieved",
 		zap.String("user_id", userID),
 		zap.String("session_id", sessionID),
-		zap.String("limit", limit))
+		zap.Int("limit", limit),
+		zap.Int("session_count", len(history.Sessions)))
 
 	utils.SuccessResponse(c, http.StatusOK, "Chat history retrieved successfully", history)
 }
 
+// GetMessageSources handles GET /api/chat/messages/:id/sources
+func (ch *ChatHandler) GetMessageSources(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	messageID := c.Param("id")
+	sessionID := c.Query("session_id")
+	if sessionID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "session_id is required")
+		return
+	}
+
+	sources, err := ch.chatService.GetMessageSources(userID, sessionID, messageID)
+	if err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Message not found")
+			return
+		}
+		ch.logger.Error("Failed to get message sources",
+			zap.String("user_id", userID),
+			zap.String("session_id", sessionID),
+			zap.String("message_id", messageID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve message sources")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Message sources retrieved successfully", sources)
+}
+
+// GetMessagePrompt handles GET /api/chat/messages/:id/prompt
+func (ch *ChatHandler) GetMessagePrompt(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	messageID := c.Param("id")
+	sessionID := c.Query("session_id")
+	if sessionID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "session_id is required")
+		return
+	}
+
+	prompt, err := ch.chatService.GetMessagePrompt(userID, sessionID, messageID)
+	if err != nil {
+		writeMessagePromptError(c, ch.logger, err, userID, sessionID, messageID)
+		return
+	}
+
+	ch.logger.Info("Message prompt retrieved",
+		zap.String("user_id", userID),
+		zap.String("session_id", sessionID),
+		zap.String("message_id", messageID))
+
+	utils.SuccessResponse(c, http.StatusOK, "Message prompt retrieved successfully", prompt)
+}
+
+// writeMessagePromptError maps a GetMessagePrompt error to an HTTP response
+func writeMessagePromptError(c *gin.Context, logger *zap.Logger, err error, userID, sessionID, messageID string) {
+	switch {
+	case errors.Is(err, services.ErrMessageNotFound):
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Message not found")
+	case errors.Is(err, services.ErrPromptNotStored):
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "No prompt stored for this message")
+	default:
+		logger.Error("Failed to get message prompt",
+			zap.String("user_id", userID),
+			zap.String("session_id", sessionID),
+			zap.String("message_id", messageID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve message prompt")
+	}
+}
+
 // HandleWebSocket handles WebSocket connections for real-time chat
 func (ch *ChatHandler) HandleWebSocket(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -145,32 +549,43 @@ func (ch *ChatHandler) HandleWebSocket(c *gin.Context) {
 	}
 	defer conn.Close()
 
-	// Create session
-	sessionID := generateSessionID()
+	// Create session, resuming a stored one when the client asks for it
+	now := time.Now()
 	session := &ChatSession{
-		UserID:     userID,
-		SessionID:  sessionID,
-		Connection: conn,
-		Messages:   make([]models.ChatMessage, 0),
-		LastActive: time.Now(),
+		UserID:      userID,
+		SessionID:   generateSessionID(),
+		Connection:  conn,
+		Messages:    make([]models.ChatMessage, 0),
+		ConnectedAt: now,
+		LastActive:  now,
+		RemoteAddr:  c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
 	}
+	resumed := ch.resumeSession(session, c.Query("session_id"))
+	sessionID := session.SessionID
 
 	// Store session
-	ch.sessions[sessionID] = session
+	ch.addSession(session)
 
 	ch.logger.Info("WebSocket connection established",
 		zap.String("user_id", userID),
-		zap.String("session_id", sessionID))
+		zap.String("session_id", sessionID),
+		zap.Bool("resumed", resumed))
 
 	// Send welcome message
 	welcomeMsg := models.WebSocketMessage{
-		Type:      "connected",
-		Data:      gin.H{"message": "Connected to health assistant", "session_id": sessionID},
+		Type: "connected",
+		Data: gin.H{
+			"message":       "Connected to health assistant",
+			"session_id":    sessionID,
+			"resumed":       resumed,
+			"message_count": len(session.Messages),
+		},
 		Timestamp: time.Now(),
 		SessionID: sessionID,
 	}
 
-	if err := conn.WriteJSON(welcomeMsg); err != nil {
+	if err := session.writeJSON(welcomeMsg); err != nil {
 		ch.logger.Error("Failed to send welcome message", zap.Error(err))
 		return
 	}
@@ -178,15 +593,409 @@ func (ch *ChatHandler) HandleWebSocket(c *gin.Context) {
 	// Handle messages
 	ch.handleWebSocketMessages(session)
 
-	// Cleanup session when connection closes
-	delete(ch.sessions, sessionID)
+	// Cleanup session when connection closes, keeping its history for a later reconnect
+	ch.saveSession(session)
+	ch.removeSession(sessionID)
 	ch.logger.Info("WebSocket connection closed",
 		zap.String("user_id", userID),
 		zap.String("session_id", sessionID))
 }
 
+// GetActiveSessions handles GET /api/chat/sessions/active
+func (ch *ChatHandler) GetActiveSessions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	sessions := ch.activeSessions(userID)
+
+	utils.SuccessResponse(c, http.StatusOK, "Active sessions retrieved successfully", gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// CloseSession handles DELETE /api/chat/sessions/:id by force-closing one of the user's WebSocket
+// sessions. Its history is kept, so the client can resume it.
+func (ch *ChatHandler) CloseSession(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	sessionID := c.Param("id")
+
+	ch.sessionsMu.RLock()
+	session, exists := ch.sessions[sessionID]
+	ch.sessionsMu.RUnlock()
+
+	// Sessions owned by other users are reported as missing so their IDs can't be probed
+	if !exists || session.UserID != userID {
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Session not found")
+		return
+	}
+
+	ch.closeConnection(session, "session closed by user")
+
+	ch.logger.Info("WebSocket session closed by user",
+		zap.String("user_id", userID),
+		zap.String("session_id", sessionID))
+
+	utils.SuccessResponse(c, http.StatusOK, "Session closed successfully", gin.H{"session_id": sessionID})
+}
+
+// DeleteSession handles DELETE /api/chat/sessions/:id/history by deleting one of the user's sessions: its
+// stored messages, its stored WebSocket state and, when connected, its connection
+func (ch *ChatHandler) DeleteSession(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	sessionID := c.Param("id")
+
+	// Close the connection first so it can't write the session back
+	live := ch.markSessionsDeleted(userID, sessionID)
+	for _, session := range live {
+		ch.closeConnection(session, "session deleted by user")
+	}
+
+	stored, err := ch.deleteStoredSession(userID, sessionID)
+	if err != nil {
+		ch.logger.Error("Failed to delete stored chat session",
+			zap.String("user_id", userID),
+			zap.String("session_id", sessionID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to delete session")
+		return
+	}
+
+	deleted, err := ch.chatService.DeleteSession(userID, sessionID)
+	if err != nil {
+		ch.logger.Error("Failed to delete chat session",
+			zap.String("user_id", userID),
+			zap.String("session_id", sessionID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to delete session")
+		return
+	}
+
+	// Sessions owned by other users match nothing above, so they are reported as missing too
+	if len(live) == 0 && !stored && deleted == 0 {
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Session not found")
+		return
+	}
+
+	ch.logger.Info("Chat session deleted",
+		zap.String("user_id", userID),
+		zap.String("session_id", sessionID),
+		zap.Int("messages_deleted", deleted))
+
+	utils.SuccessResponse(c, http.StatusOK, "Session deleted successfully", gin.H{
+		"session_id":       sessionID,
+		"messages_deleted": deleted,
+	})
+}
+
+// DeleteHistory handles DELETE /api/chat/history by deleting all of the user's sessions and messages
+func (ch *ChatHandler) DeleteHistory(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	live := ch.markSessionsDeleted(userID, "")
+	for _, session := range live {
+		ch.closeConnection(session, "session deleted by user")
+	}
+
+	sessionIDs, err := ch.chatService.DeleteHistory(userID)
+	if err != nil {
+		ch.logger.Error("Failed to delete chat history",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to delete chat history")
+		return
+	}
+
+	// Stored WebSocket state is keyed by session alone, so clear the sessions found in the history
+	// and the ones that were connected
+	cleared := make(map[string]bool, len(sessionIDs)+len(live))
+	for _, sessionID := range sessionIDs {
+		cleared[sessionID] = true
+	}
+	for _, session := range live {
+		cleared[session.SessionID] = true
+	}
+	for sessionID := range cleared {
+		if _, err := ch.deleteStoredSession(userID, sessionID); err != nil {
+			ch.logger.Warn("Failed to delete stored chat session",
+				zap.String("user_id", userID),
+				zap.String("session_id", sessionID),
+				zap.Error(err))
+		}
+	}
+
+	ch.logger.Info("Chat history deleted",
+		zap.String("user_id", userID),
+		zap.Int("sessions_deleted", len(cleared)))
+
+	utils.SuccessResponse(c, http.StatusOK, "Chat history deleted successfully", gin.H{
+		"sessions_deleted": len(cleared),
+	})
+}
+
+// markSessionsDeleted flags the user's connected sessions as deleted, only the one with sessionID
+// when it is set, and returns them
+func (ch *ChatHandler) markSessionsDeleted(userID, sessionID string) []*ChatSession {
+	ch.sessionsMu.Lock()
+	defer ch.sessionsMu.Unlock()
+
+	var marked []*ChatSession
+	for _, session := range ch.sessions {
+		if session.UserID != userID || (sessionID != "" && session.SessionID != sessionID) {
+			continue
+		}
+		session.deleted = true
+		marked = append(marked, session)
+	}
+	return marked
+}
+
+// deleteStoredSession removes a session from the session store when it belongs to the user, and
+// reports whether it did
+func (ch *ChatHandler) deleteStoredSession(userID, sessionID string) (bool, error) {
+	stored, err := ch.sessionStore.Get(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if stored == nil || stored.UserID != userID {
+		return false, nil
+	}
+	return true, ch.sessionStore.Delete(sessionID)
+}
+
+// closeConnection sends a close frame and closes the session's connection. WriteControl and Close
+// are safe to call concurrently with the session's reader; the read loop then fails and
+// HandleWebSocket removes the session.
+func (ch *ChatHandler) closeConnection(session *ChatSession, reason string) {
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason)
+	if err := session.Connection.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(5*time.Second)); err != nil {
+		ch.logger.Warn("Failed to send close frame",
+			zap.String("session_id", session.SessionID),
+			zap.Error(err))
+	}
+	session.Connection.Close()
+}
+
+// Shutdown tells every connected WebSocket client the server is going away with a "disconnected"
+// message and a close frame, then closes the connections. Call it before shutting down the HTTP
+// server, which doesn't track hijacked WebSocket connections. It gives up on slow clients when ctx ends.
+func (ch *ChatHandler) Shutdown(ctx context.Context) error {
+	ch.stopOnce.Do(func() { close(ch.stopReaper) })
+
+	ch.sessionsMu.RLock()
+	sessions := make([]*ChatSession, 0, len(ch.sessions))
+	for _, session := range ch.sessions {
+		sessions = append(sessions, session)
+	}
+	ch.sessionsMu.RUnlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(session *ChatSession) {
+			defer wg.Done()
+			ch.disconnectSession(session, deadline)
+		}(session)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// disconnectSession sends the disconnect message and close frame to one session and closes it.
+// The read loop then fails and HandleWebSocket removes the session.
+func (ch *ChatHandler) disconnectSession(session *ChatSession, deadline time.Time) {
+	defer session.Connection.Close()
+
+	disconnectMsg := models.WebSocketMessage{
+		Type:      "disconnected",
+		Data:      gin.H{"message": "Server is shutting down", "reason": "shutdown"},
+		Timestamp: time.Now(),
+		SessionID: session.SessionID,
+	}
+
+	session.writeMu.Lock()
+	defer session.writeMu.Unlock()
+
+	session.Connection.SetWriteDeadline(deadline)
+	if err := session.Connection.WriteJSON(disconnectMsg); err != nil {
+		ch.logger.Warn("Failed to send disconnect message",
+			zap.String("session_id", session.SessionID),
+			zap.Error(err))
+		return
+	}
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	if err := session.Connection.WriteControl(websocket.CloseMessage, closeMsg, deadline); err != nil {
+		ch.logger.Warn("Failed to send close frame",
+			zap.String("session_id", session.SessionID),
+			zap.Error(err))
+	}
+}
+
+// resumeSession restores the history of a stored session into session when requestedID names one
+// of the user's sessions that isn't currently connected. It reports whether the session was resumed;
+// otherwise session keeps its freshly generated ID.
+func (ch *ChatHandler) resumeSession(session *ChatSession, requestedID string) bool {
+	if requestedID == "" {
+		return false
+	}
+
+	ch.sessionsMu.RLock()
+	_, active := ch.sessions[requestedID]
+	ch.sessionsMu.RUnlock()
+	if active {
+		return false
+	}
+
+	stored, err := ch.sessionStore.Get(requestedID)
+	if err != nil {
+		ch.logger.Warn("Failed to load stored WebSocket session",
+			zap.String("user_id", session.UserID),
+			zap.String("session_id", requestedID),
+			zap.Error(err))
+		return false
+	}
+	// Sessions owned by other users are treated as missing
+	if stored == nil || stored.UserID != session.UserID {
+		return false
+	}
+
+	session.SessionID = stored.SessionID
+	if stored.Messages != nil {
+		session.Messages = stored.Messages
+	}
+	return true
+}
+
+// saveSession writes the session's history and last activity to the session store, unless the
+// user has deleted the session
+func (ch *ChatHandler) saveSession(session *ChatSession) {
+	ch.sessionsMu.RLock()
+	lastActive, deleted := session.LastActive, session.deleted
+	ch.sessionsMu.RUnlock()
+	if deleted {
+		return
+	}
+
+	stored := &models.ChatSession{
+		SessionID:    session.SessionID,
+		UserID:       session.UserID,
+		StartTime:    session.ConnectedAt,
+		LastActive:   lastActive,
+		MessageCount: len(session.Messages),
+		Messages:     session.Messages,
+	}
+
+	if err := ch.sessionStore.Put(stored); err != nil {
+		ch.logger.Warn("Failed to save WebSocket session",
+			zap.String("user_id", session.UserID),
+			zap.String("session_id", session.SessionID),
+			zap.Error(err))
+	}
+}
+
+// writeJSON writes a message to the session's connection, serialized with other writers
+func (s *ChatSession) writeJSON(v interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.Connection.WriteJSON(v)
+}
+
+// addSession registers an active WebSocket session
+func (ch *ChatHandler) addSession(session *ChatSession) {
+	ch.sessionsMu.Lock()
+	defer ch.sessionsMu.Unlock()
+	ch.sessions[session.SessionID] = session
+}
+
+// removeSession forgets a WebSocket session once its connection has closed
+func (ch *ChatHandler) removeSession(sessionID string) {
+	ch.sessionsMu.Lock()
+	defer ch.sessionsMu.Unlock()
+	delete(ch.sessions, sessionID)
+}
+
+// touchSession records activity on a session
+func (ch *ChatHandler) touchSession(session *ChatSession) {
+	ch.sessionsMu.Lock()
+	defer ch.sessionsMu.Unlock()
+	session.LastActive = time.Now()
+}
+
+// activeSessions lists the user's open WebSocket sessions, most recently connected first
+func (ch *ChatHandler) activeSessions(userID string) []models.ActiveSession {
+	ch.sessionsMu.RLock()
+	defer ch.sessionsMu.RUnlock()
+
+	sessions := make([]models.ActiveSession, 0)
+	for _, session := range ch.sessions {
+		if session.UserID != userID {
+			continue
+		}
+		sessions = append(sessions, models.ActiveSession{
+			SessionID:      session.SessionID,
+			ConnectedSince: session.ConnectedAt,
+			LastActive:     session.LastActive,
+			RemoteAddr:     session.RemoteAddr,
+			UserAgent:      session.UserAgent,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ConnectedSince.After(sessions[j].ConnectedSince)
+	})
+
+	return sessions
+}
+
 // handleWebSocketMessages processes incoming WebSocket messages
 func (ch *ChatHandler) handleWebSocketMessages(session *ChatSession) {
+	if ch.pingInterval > 0 {
+		// Each pong (or message) extends the read deadline; a dead peer fails the read at the deadline
+		pongWait := 2 * ch.pingInterval
+		session.Connection.SetReadDeadline(time.Now().Add(pongWait))
+		session.Connection.SetPongHandler(func(string) error {
+			return session.Connection.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
+		done := make(chan struct{})
+		defer close(done)
+		go ch.pingSession(session, done)
+	}
+
 	for {
 		var wsMessage models.WebSocketMessage
 		err := session.Connection.ReadJSON(&wsMessage)
@@ -197,7 +1006,10 @@ func (ch *ChatHandler) handleWebSocketMessages(session *ChatSession) {
 			break
 		}
 
-		session.LastActive = time.Now()
+		ch.touchSession(session)
+		if ch.pingInterval > 0 {
+			session.Connection.SetReadDeadline(time.Now().Add(2 * ch.pingInterval))
+		}
 
 		switch wsMessage.Type {
 		case "message":
@@ -212,6 +1024,24 @@ func (ch *ChatHandler) handleWebSocketMessages(session *ChatSession) {
 	}
 }
 
+// pingSession pings the client every pingInterval until done is closed or a ping fails. WriteControl
+// may be called concurrently with the session's other writes.
+func (ch *ChatHandler) pingSession(session *ChatSession, done <-chan struct{}) {
+	ticker := time.NewTicker(ch.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := session.Connection.WriteControl(websocket.PingMessage, nil, time.Now().Add(ch.pingInterval)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
 // handleChatMessage processes a chat message via WebSocket
 func (ch *ChatHandler) handleChatMessage(session *ChatSession, wsMessage models.WebSocketMessage) {
 	// Extract message from WebSocket data
@@ -227,6 +1057,57 @@ func (ch *ChatHandler) handleChatMessage(session *ChatSession, wsMessage models.
 		return
 	}
 
+	// Optional response language
+	language, _ := data["language"].(string)
+	if language != "" {
+		if _, ok := ai.NormalizeLanguage(language); !ok {
+			ch.sendError(session, "Unsupported language code: "+language)
+			return
+		}
+	}
+
+	// Optional reproducible sampling
+	deterministic, _ := data["deterministic"].(bool)
+
+	// Optional persona, locale and reply length, validated as for POST /api/chat
+	persona, _ := data["persona"].(string)
+	if persona != "" {
+		if _, ok := ai.NormalizePersona(persona); !ok {
+			ch.sendError(session, "Unsupported persona: "+persona)
+			return
+		}
+	}
+	locale, _ := data["locale"].(string)
+	if locale != "" {
+		if _, ok := ai.NormalizeLocale(locale); !ok {
+			ch.sendError(session, "Invalid locale: "+locale)
+			return
+		}
+	}
+	maxTokens := 0
+	if value, exists := data["max_tokens"]; exists && value != nil {
+		number, ok := value.(float64)
+		if !ok || number < 0 || number != math.Trunc(number) {
+			ch.sendError(session, "max_tokens must be a non-negative integer")
+			return
+		}
+		maxTokens = int(number)
+	}
+
+	if allowed, wait := ch.rateLimiter.Allow(session.UserID); !allowed {
+		session.writeJSON(models.WebSocketMessage{
+			Type: "error",
+			Data: models.ErrorMessage{
+				Code:    http.StatusTooManyRequests,
+				Message: "Rate limit exceeded",
+				Details: fmt.Sprintf("retry after %d seconds", int(math.Ceil(wait.Seconds()))),
+			},
+			Timestamp: time.Now(),
+			SessionID: session.SessionID,
+		})
+		return
+	}
+
 	// Send typing indicator
 	ch.sendTypingIndicator(session, true)
 
@@ -234,7 +1115,13 @@ func (ch *ChatHandler) handleChatMessage(session *ChatSession, wsMessage models.
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	response, err := ch.aiAgent.ProcessQuery(ctx, session.UserID, message)
+	response, err := ch.aiAgent.ProcessQuery(ctx, session.UserID, session.SessionID, message, services.QueryOptions{
+		Language:      language,
+		Deterministic: deterministic,
+		MaxTokens:     maxTokens,
+		Persona:       persona,
+		Locale:        locale,
+	})
 	if err != nil {
 		ch.logger.Error("Failed to process WebSocket chat query",
 			zap.String("user_id", session.UserID),
@@ -256,7 +1143,7 @@ func (ch *ChatHandler) handleChatMessage(session *ChatSession, wsMessage models.
 		SessionID: session.SessionID,
 	}
 
-	if err := session.Connection.WriteJSON(responseMsg); err != nil {
+	if err := session.writeJSON(responseMsg); err != nil {
 		ch.logger.Error("Failed to send WebSocket response", zap.Error(err))
 		return
 	}
@@ -265,6 +1152,19 @@ func (ch *ChatHandler) handleChatMessage(session *ChatSession, wsMessage models.
 	userMsg := models.NewChatMessage(session.UserID, "user", message)
 	assistantMsg := models.NewChatMessage(session.UserID, "assistant", response.Message)
 	session.Messages = append(session.Messages, *userMsg, *assistantMsg)
+
+	ch.persistExchange(session.UserID, session.SessionID, message, response)
+	ch.saveSession(session)
+}
+
+// persistExchange stores a user message and the assistant's reply in chat history
+func (ch *ChatHandler) persistExchange(userID, sessionID, message string, response *models.ChatResponse) {
+	if err := ch.chatService.SaveExchange(userID, sessionID, message, response); err != nil {
+		ch.logger.Warn("Failed to persist chat messages",
+			zap.String("user_id", userID),
+			zap.String("session_id", sessionID),
+			zap.Error(err))
+	}
 }
 
 // handleTypingIndicator handles typing indicator messages
@@ -285,7 +1185,7 @@ func (ch *ChatHandler) sendTypingIndicator(session *ChatSession, isTyping bool)
 		SessionID: session.SessionID,
 	}
 
-	session.Connection.WriteJSON(indicator)
+	session.writeJSON(indicator)
 }
 
 // sendError sends an error message via WebSocket
@@ -300,20 +1200,25 @@ func (ch *ChatHandler) sendError(session *ChatSession, message string) {
 		SessionID: session.SessionID,
 	}
 
-	session.Connection.WriteJSON(errorMsg)
+	session.writeJSON(errorMsg)
 }
 
-// generateSessionID generates a unique session ID
+// generateSessionID generates a unique session ID. Stored session state is keyed by it alone, so
+// it must not collide across users.
 func generateSessionID() string {
-	return "sess_" + time.Now().Format("20060102150405") + "_" + randomStringChat(8)
+	return "sess_" + uuid.New().String()
 }
 
-// randomStringChat generates a random string for chat sessions
+// randomStringChat generates a random string for chat IDs
 func randomStringChat(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	random := make([]byte, length)
+	if _, err := rand.Read(random); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
 	result := make([]byte, length)
 	for i := range result {
-		result[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+		result[i] = charset[int(random[i])%len(charset)]
 	}
 	return string(result)
 }
@@ -0,0 +1,581 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/middleware"
+	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/internal/services"
+	"health-dashboard-backend/internal/vectordb"
+	"health-dashboard-backend/pkg/ai"
+)
+
+// chatFixture is a ChatHandler without an AI agent, backed by an in-memory DynamoDB
+type chatFixture struct {
+	cfg         *config.Config
+	db          *database.DynamoDBClient
+	fake        *dynamotest.Fake
+	chatService *services.ChatService
+	handler     *ChatHandler
+}
+
+func newChatFixture(t *testing.T) *chatFixture {
+	t.Helper()
+
+	f := &chatFixture{cfg: testConfig(t)}
+	f.db, f.fake = dynamotest.NewClient(f.cfg)
+	f.chatService = services.NewChatService(f.db, f.cfg)
+	f.handler = NewChatHandler(nil, f.chatService, zap.NewNop())
+	return f
+}
+
+// withAgent replaces the handler with one backed by an AI agent answering through llm, with no
+// documents indexed
+func (f *chatFixture) withAgent(llm ai.LLMClient) {
+	health := services.NewHealthService(f.db, f.cfg)
+	rag := services.NewRAGService(emptyVectorStore{}, llm, zeroEmbeddings{}, f.cfg)
+	agent := services.NewAIAgent(health, rag, llm, nil, f.cfg, zap.NewNop())
+	f.handler = NewChatHandler(agent, f.chatService, zap.NewNop())
+}
+
+// routes returns a router for userID with the chat routes registered as in main
+func (f *chatFixture) routes(userID string) *gin.Engine {
+	router := newTestRouter(userID)
+	chat := router.Group("/api/chat")
+	chat.POST("", f.handler.ProcessQuery)
+	chat.POST("/analyze", f.handler.AnalyzeQuery)
+	chat.GET("/history", f.handler.GetChatHistory)
+	chat.DELETE("/history", f.handler.DeleteHistory)
+	chat.GET("/messages/:id/sources", f.handler.GetMessageSources)
+	chat.GET("/messages/:id/prompt", f.handler.GetMessagePrompt)
+	chat.GET("/sessions/active", f.handler.GetActiveSessions)
+	chat.DELETE("/sessions/:id", f.handler.CloseSession)
+	chat.DELETE("/sessions/:id/history", f.handler.DeleteSession)
+	router.GET("/ws/chat", f.handler.HandleWebSocket)
+	return router
+}
+
+func TestGetMessagePromptIsOwnerOnly(t *testing.T) {
+	f := newChatFixture(t)
+
+	response := &models.ChatResponse{
+		ID:      "reply-1",
+		Message: "Your LDL is borderline high.",
+		Prompt: &models.PromptRecord{
+			Provider: "sonar",
+			Messages: []models.PromptMessage{{Role: "system", Content: "SYSTEM PROMPT"}, {Role: "user", Content: "How is my LDL?"}},
+		},
+	}
+	if err := f.chatService.SaveExchange("user-1", "session-1", "How is my LDL?", response); err != nil {
+		t.Fatalf("save exchange: %v", err)
+	}
+
+	recorder, result := serve(t, f.routes("user-1"), http.MethodGet, "/api/chat/messages/reply-1/prompt?session_id=session-1", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("owner: status %d, want 200 (%s)", recorder.Code, recorder.Body.String())
+	}
+	var prompt models.MessagePrompt
+	decodeData(t, result, &prompt)
+	if prompt.Prompt == nil || prompt.Prompt.Provider != "sonar" || len(prompt.Prompt.Messages) != 2 {
+		t.Errorf("prompt = %+v, want the stored sonar prompt", prompt.Prompt)
+	}
+
+	recorder, _ = serve(t, f.routes("user-2"), http.MethodGet, "/api/chat/messages/reply-1/prompt?session_id=session-1", nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("another user: status %d, want 404", recorder.Code)
+	}
+
+	// The raw prompt is only served by the audit endpoint
+	recorder, _ = serve(t, f.routes("user-1"), http.MethodGet, "/api/chat/history?session_id=session-1", nil)
+	if recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), "SYSTEM PROMPT") {
+		t.Errorf("history: status %d, body %s; want 200 without the prompt", recorder.Code, recorder.Body.String())
+	}
+}
+
+// connect opens a chat WebSocket as userID and returns it with the session ID from the welcome message
+func (f *chatFixture) connect(t *testing.T, userID string) (*websocket.Conn, string) {
+	t.Helper()
+
+	server := httptest.NewServer(f.routes(userID))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/chat", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var welcome models.WebSocketMessage
+	if err := conn.ReadJSON(&welcome); err != nil || welcome.Type != "connected" {
+		t.Fatalf("welcome = %+v, %v; want a connected message", welcome, err)
+	}
+	return conn, welcome.SessionID
+}
+
+// activeSessionIDs returns the IDs listed by GET /api/chat/sessions/active for userID
+func (f *chatFixture) activeSessionIDs(t *testing.T, userID string) map[string]bool {
+	t.Helper()
+
+	recorder, response := serve(t, f.routes(userID), http.MethodGet, "/api/chat/sessions/active", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("active sessions: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var active struct {
+		Sessions []models.ActiveSession `json:"sessions"`
+	}
+	decodeData(t, response, &active)
+
+	ids := make(map[string]bool)
+	for _, session := range active.Sessions {
+		ids[session.SessionID] = true
+	}
+	return ids
+}
+
+func TestActiveSessionsAreScopedToUser(t *testing.T) {
+	f := newChatFixture(t)
+	laptop, laptopID := f.connect(t, "user-1")
+	_, phoneID := f.connect(t, "user-1")
+	_, otherID := f.connect(t, "user-2")
+
+	if ids := f.activeSessionIDs(t, "user-1"); len(ids) != 2 || !ids[laptopID] || !ids[phoneID] {
+		t.Errorf("user-1 sees %v, want only its two sessions", ids)
+	}
+
+	// Another user's session looks missing
+	recorder, _ := serve(t, f.routes("user-1"), http.MethodDelete, "/api/chat/sessions/"+otherID, nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("closing another user's session: status %d, want 404", recorder.Code)
+	}
+
+	recorder, _ = serve(t, f.routes("user-1"), http.MethodDelete, "/api/chat/sessions/"+laptopID, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("close: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	laptop.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := laptop.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("closed session read %v, want a normal close frame", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for f.activeSessionIDs(t, "user-1")[laptopID] {
+		if time.Now().After(deadline) {
+			t.Fatal("closed session still listed as active")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ids := f.activeSessionIDs(t, "user-2"); len(ids) != 1 || !ids[otherID] {
+		t.Errorf("user-2 sees %v, want its session untouched", ids)
+	}
+}
+
+// startStream posts a streaming chat request to server and returns the response
+func startStream(t *testing.T, server *httptest.Server) *http.Response {
+	t.Helper()
+
+	response, err := http.Post(server.URL+"/api/chat", "application/json", strings.NewReader(`{"message":"hello","stream":true}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+// readStreamChunk reads the next SSE data frame
+func readStreamChunk(t *testing.T, reader *bufio.Reader) models.StreamChunk {
+	t.Helper()
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			var chunk models.StreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				t.Fatalf("decode event %s: %v", data, err)
+			}
+			return chunk
+		}
+	}
+}
+
+func TestStreamFlushesEachTokenToSlowConsumer(t *testing.T) {
+	f := newChatFixture(t)
+	llm := &scriptedLLM{tokens: make(chan string)}
+	f.withAgent(llm)
+	server := httptest.NewServer(f.routes("user-1"))
+	t.Cleanup(server.Close)
+
+	// The LLM blocks until the stream is open, then sends one token at a time
+	go func() {
+		for _, token := range []string{"Your ", "heart ", "rate ", "is fine."} {
+			llm.tokens <- token
+		}
+	}()
+	response := startStream(t, server)
+	if response.Header.Get("X-Accel-Buffering") != "no" || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("headers = %v, want an unbuffered event stream", response.Header)
+	}
+
+	// Each token arrives as its own event while the LLM is still producing
+	reader := bufio.NewReader(response.Body)
+	for _, want := range []string{"Your ", "heart ", "rate ", "is fine."} {
+		time.Sleep(10 * time.Millisecond) // A slow consumer
+		if chunk := readStreamChunk(t, reader); chunk.Content != want || chunk.Done {
+			t.Fatalf("got %+v, want %q before the stream ends", chunk, want)
+		}
+	}
+	close(llm.tokens)
+
+	final := readStreamChunk(t, reader)
+	if !final.Done || final.Response == nil || final.Response.Message != "Your heart rate is fine." {
+		t.Errorf("final frame = %+v, want Done with the full response", final)
+	}
+}
+
+func TestStreamCoalescesTokensWithinInterval(t *testing.T) {
+	f := newChatFixture(t)
+	llm := &scriptedLLM{tokens: make(chan string, 20)}
+	for i := 0; i < 20; i++ {
+		llm.tokens <- "x"
+	}
+	close(llm.tokens)
+	f.withAgent(llm)
+	f.handler.SetStreamCoalesceInterval(time.Hour)
+	server := httptest.NewServer(f.routes("user-1"))
+	t.Cleanup(server.Close)
+
+	// Every token lands within one interval, so they all go out in the final frame
+	chunk := readStreamChunk(t, bufio.NewReader(startStream(t, server).Body))
+	if !chunk.Done || chunk.Content != strings.Repeat("x", 20) {
+		t.Errorf("first frame = %+v, want one Done frame with all 20 tokens", chunk)
+	}
+}
+
+func TestAnalyzeQueryPreviewsRouting(t *testing.T) {
+	f := newChatFixture(t)
+	f.withAgent(&scriptedLLM{reply: "unused"})
+	router := f.routes("user-1")
+
+	recorder, response := serve(t, router, http.MethodPost, "/api/chat/analyze", map[string]string{"message": "Show my latest lab report"})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", recorder.Code, recorder.Body)
+	}
+	var analysis models.QueryAnalysis
+	decodeData(t, response, &analysis)
+	if analysis.Intent != models.IntentDocumentQuery || analysis.Route.DocumentScope != models.DocumentScopeDocuments || analysis.Route.UsesHealthData {
+		t.Errorf("analysis = %+v, want a document query searching documents only", analysis)
+	}
+
+	if recorder, _ := serve(t, router, http.MethodPost, "/api/chat/analyze", map[string]string{}); recorder.Code != http.StatusBadRequest {
+		t.Errorf("status = %d without a message, want 400", recorder.Code)
+	}
+}
+
+func TestShutdownSendsDisconnectFrame(t *testing.T) {
+	f := newChatFixture(t)
+	conn, sessionID := f.connect(t, "user-1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := f.handler.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var message models.WebSocketMessage
+	if err := conn.ReadJSON(&message); err != nil || message.Type != "disconnected" || message.SessionID != sessionID {
+		t.Fatalf("got %+v, %v; want a disconnected message for %s", message, err, sessionID)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("read after disconnect = %v, want a going-away close frame", err)
+	}
+}
+
+func TestReaperClosesIdleSessions(t *testing.T) {
+	f := newChatFixture(t)
+	f.handler.idleTimeout = time.Minute
+	conn, sessionID := f.connect(t, "user-1")
+
+	f.handler.reapIdleSessions(time.Now().Add(30 * time.Second))
+	if !f.activeSessionIDs(t, "user-1")[sessionID] {
+		t.Fatal("session reaped before its idle timeout")
+	}
+
+	f.handler.reapIdleSessions(time.Now().Add(2 * time.Minute))
+	if f.activeSessionIDs(t, "user-1")[sessionID] {
+		t.Error("idle session still active after the reaper ran")
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("read after reaping = %v, want a normal close frame", err)
+	}
+}
+
+// waitForSession polls until sessionID's active state matches want, failing after a few seconds
+func (f *chatFixture) waitForSession(t *testing.T, userID, sessionID string, want bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if f.activeSessionIDs(t, userID)[sessionID] == want {
+			return
+		}
+	}
+	t.Fatalf("session %s active = %v, want %v", sessionID, !want, want)
+}
+
+func TestPongsKeepSessionAlive(t *testing.T) {
+	f := newChatFixture(t)
+	f.handler.SetWebSocketTimeouts(20*time.Millisecond, 0)
+
+	// The client answers pings while it reads, so each pong extends the server's read deadline
+	responsive, responsiveID := f.connect(t, "user-1")
+	go func() {
+		for {
+			if _, _, err := responsive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// A client that never reads never answers a ping
+	_, silentID := f.connect(t, "user-1")
+
+	f.waitForSession(t, "user-1", silentID, false)
+	time.Sleep(200 * time.Millisecond) // Several deadlines past the first
+	if !f.activeSessionIDs(t, "user-1")[responsiveID] {
+		t.Error("session answering pings was dropped")
+	}
+}
+
+func TestWebSocketOriginMustBeAllowed(t *testing.T) {
+	f := newChatFixture(t)
+	f.handler.SetAllowedOrigins([]string{"https://app.example.com"}, false)
+	server := httptest.NewServer(f.routes("user-1"))
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/chat"
+
+	for origin, wantStatus := range map[string]int{
+		"https://app.example.com":  http.StatusSwitchingProtocols,
+		"https://evil.example.com": http.StatusForbidden,
+	} {
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		if conn != nil {
+			conn.Close()
+		}
+		if resp == nil {
+			t.Fatalf("%s: dial: %v", origin, err)
+		}
+		if resp.StatusCode != wantStatus {
+			t.Errorf("%s: handshake status %d, want %d", origin, resp.StatusCode, wantStatus)
+		}
+	}
+}
+
+func TestDeleteSessionIsOwnerOnly(t *testing.T) {
+	f := newChatFixture(t)
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		response := &models.ChatResponse{ID: "reply-" + userID, Message: "Your LDL is borderline high."}
+		if err := f.chatService.SaveExchange(userID, "session-"+userID, "How is my LDL?", response); err != nil {
+			t.Fatalf("save exchange: %v", err)
+		}
+	}
+	messageCount := func(userID string) int {
+		t.Helper()
+		history, err := f.chatService.GetChatHistory(userID, "session-"+userID, 50)
+		if err != nil {
+			t.Fatalf("history: %v", err)
+		}
+		n := 0
+		for _, session := range history.Sessions {
+			n += len(session.Messages)
+		}
+		return n
+	}
+
+	// Another user's session looks missing and is left alone
+	recorder, _ := serve(t, f.routes("user-2"), http.MethodDelete, "/api/chat/sessions/session-user-1/history", nil)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("deleting another user's session: status %d, want 404", recorder.Code)
+	}
+	if n := messageCount("user-1"); n != 2 {
+		t.Fatalf("user-1 has %d messages after another user's delete, want 2", n)
+	}
+
+	recorder, _ = serve(t, f.routes("user-1"), http.MethodDelete, "/api/chat/sessions/session-user-1/history", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("delete: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	if n := messageCount("user-1"); n != 0 {
+		t.Errorf("user-1 has %d messages after deleting the session, want 0", n)
+	}
+
+	// Wiping one user's history leaves other users' sessions
+	recorder, _ = serve(t, f.routes("user-1"), http.MethodDelete, "/api/chat/history", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("delete history: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	if n := messageCount("user-2"); n != 2 {
+		t.Errorf("user-2 has %d messages after user-1 wiped their history, want 2", n)
+	}
+}
+
+// nextFrame reads WebSocket frames until one that isn't a typing indicator
+func nextFrame(t *testing.T, conn *websocket.Conn) models.WebSocketMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var message models.WebSocketMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		if message.Type != "typing" {
+			return message
+		}
+	}
+}
+
+func TestWebSocketMessagesShareChatRateLimit(t *testing.T) {
+	f := newChatFixture(t)
+	f.withAgent(&scriptedLLM{reply: "Your heart rate is fine."})
+	f.cfg.RateLimitPerMinute = 1
+	f.cfg.RateLimitBurst = 2
+	limiter := middleware.NewRateLimiter(f.cfg)
+	f.handler.SetRateLimiter(limiter)
+
+	conn, _ := f.connect(t, "user-1")
+	ask := models.WebSocketMessage{Type: "message", Data: map[string]interface{}{"message": "How is my heart rate?"}}
+	if err := conn.WriteJSON(ask); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if frame := nextFrame(t, conn); frame.Type != "message" {
+		t.Fatalf("first message: got a %s frame (%+v), want the answer", frame.Type, frame.Data)
+	}
+
+	// POST /api/chat takes the last token of the same allowance
+	router := newTestRouter("user-1")
+	router.POST("/api/chat", limiter.Middleware(), f.handler.ProcessQuery)
+	if recorder, _ := serve(t, router, http.MethodPost, "/api/chat", map[string]string{"message": "And my weight?"}); recorder.Code != http.StatusOK {
+		t.Fatalf("POST /api/chat: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+
+	if err := conn.WriteJSON(ask); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	frame := nextFrame(t, conn)
+	data, _ := frame.Data.(map[string]interface{})
+	if frame.Type != "error" || data["code"] != float64(http.StatusTooManyRequests) {
+		t.Errorf("over the limit: got a %s frame (%+v), want a 429 error frame", frame.Type, frame.Data)
+	}
+}
+
+func TestWebSocketMessageSetsPersonaLocaleAndMaxTokens(t *testing.T) {
+	f := newChatFixture(t)
+	f.cfg.StoreChatPrompts = true
+	f.withAgent(&scriptedLLM{reply: "Your heart rate is 72 bpm."})
+
+	conn, sessionID := f.connect(t, "user-1")
+	for _, tc := range []struct {
+		data map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"persona": "pirate"}, "Unsupported persona: pirate"},
+		{map[string]interface{}{"locale": "not a locale!"}, "Invalid locale: not a locale!"},
+		{map[string]interface{}{"max_tokens": -1}, "max_tokens must be a non-negative integer"},
+		{map[string]interface{}{"max_tokens": "lots"}, "max_tokens must be a non-negative integer"},
+	} {
+		tc.data["message"] = "How is my heart rate?"
+		if err := conn.WriteJSON(models.WebSocketMessage{Type: "message", Data: tc.data}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		frame := nextFrame(t, conn)
+		data, _ := frame.Data.(map[string]interface{})
+		if frame.Type != "error" || data["message"] != tc.want {
+			t.Errorf("%v: got a %s frame (%+v), want error %q", tc.data, frame.Type, frame.Data, tc.want)
+		}
+	}
+
+	ask := models.WebSocketMessage{Type: "message", Data: map[string]interface{}{
+		"message": "How is my heart rate?", "persona": "clinical", "locale": "en-GB", "max_tokens": 200,
+	}}
+	if err := conn.WriteJSON(ask); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	frame := nextFrame(t, conn)
+	data, _ := frame.Data.(map[string]interface{})
+	replyID, _ := data["id"].(string)
+	if frame.Type != "message" || replyID == "" {
+		t.Fatalf("got a %s frame (%+v), want the answer", frame.Type, frame.Data)
+	}
+
+	recorder, result := serve(t, f.routes("user-1"), http.MethodGet, "/api/chat/messages/"+replyID+"/prompt?session_id="+sessionID, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("prompt: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var prompt models.MessagePrompt
+	decodeData(t, result, &prompt)
+	if prompt.Prompt == nil || prompt.Prompt.MaxTokens != 200 {
+		t.Fatalf("prompt = %+v, want max_tokens 200", prompt.Prompt)
+	}
+	system := prompt.Prompt.Messages[0].Content
+	if !strings.Contains(system, ai.Personas["clinical"]) || !strings.Contains(system, "en-GB") {
+		t.Errorf("system prompt = %q, want the clinical persona and en-GB locale", system)
+	}
+}
+
+func TestStreamHonoursSourceAndSuggestionCaps(t *testing.T) {
+	f := newChatFixture(t)
+	llm := &scriptedLLM{reply: "Your LDL was 140 mg/dL."}
+	vectors := chunkVectorStore{results: []vectordb.QueryResult{
+		{ID: "doc-1#0", Score: 0.9, Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "doc-1", "chunk_index": 0, "content": "LDL 140 mg/dL"}},
+		{ID: "doc-1#1", Score: 0.8, Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "doc-1", "chunk_index": 1, "content": "HDL 45 mg/dL"}},
+		{ID: "doc-1#2", Score: 0.7, Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "doc-1", "chunk_index": 2, "content": "Triglycerides 150 mg/dL"}},
+	}}
+	health := services.NewHealthService(f.db, f.cfg)
+	rag := services.NewRAGService(vectors, llm, zeroEmbeddings{}, f.cfg)
+	f.handler = NewChatHandler(services.NewAIAgent(health, rag, llm, nil, f.cfg, zap.NewNop()), f.chatService, zap.NewNop())
+	server := httptest.NewServer(f.routes("user-1"))
+	t.Cleanup(server.Close)
+
+	finalFrame := func(body string) *models.ChatResponse {
+		t.Helper()
+		response, err := http.Post(server.URL+"/api/chat", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		defer response.Body.Close()
+		reader := bufio.NewReader(response.Body)
+		for {
+			if chunk := readStreamChunk(t, reader); chunk.Done {
+				return chunk.Response
+			}
+		}
+	}
+
+	uncapped := finalFrame(`{"message":"Show my latest lab report","stream":true}`)
+	if uncapped == nil || len(uncapped.Sources) < 2 {
+		t.Fatalf("uncapped response = %+v, want several sources", uncapped)
+	}
+
+	capped := finalFrame(`{"message":"Show my latest lab report","stream":true,"max_sources":1,"max_suggestions":0}`)
+	if capped == nil || len(capped.Sources) != 1 || capped.Sources[0].ChunkID != "doc-1#0" || len(capped.Suggestions) != 0 {
+		t.Errorf("capped response = %+v, want only the most relevant source and no suggestions", capped)
+	}
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -16,13 +16,15 @@ import (
 // DashboardHandler handles dashboard summary endpoints
 type DashboardHandler struct {
 	healthService *services.HealthService
+	aiAgent       *services.AIAgent
 	logger        *zap.Logger
 }
 
 // NewDashboardHandler creates a new dashboard handler
-func NewDashboardHandler(healthService *services.HealthService, logger *zap.Logger) *DashboardHandler {
+func NewDashboardHandler(healthService *services.HealthService, aiAgent *services.AIAgent, logger *zap.Logger) *DashboardHandler {
 	return &DashboardHandler{
 		healthService: healthService,
+		aiAgent:       aiAgent,
 		logger:        logger,
 	}
 }
@@ -31,7 +33,7 @@ func NewDashboardHandler(healthService *services.HealthService, logger *zap.Logg
 func (d *DashboardHandler) GetSummary(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -41,7 +43,7 @@ func (d *DashboardHandler) GetSummary(c *gin.Context) {
 		d.logger.Error("Failed to get health summary for dashboard",
 			zap.String("user_id", userID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve dashboard summary")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve dashboard summary")
 		return
 	}
 
@@ -59,23 +61,23 @@ func (d *DashboardHandler) GetSummary(c *gin.Context) {
 func (d *DashboardHandler) GetTrends(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	// Parse query parameters
 	period := c.DefaultQuery("period", "month")
-	metricTypesParam := c.Query("metric_types")
 
-	// Parse metric types
-	var metricTypes []string
-	if metricTypesParam != "" {
-		metricTypes = strings.Split(metricTypesParam, ",")
-		// Trim whitespace from each metric type
-		for i, mt := range metricTypes {
-			metricTypes[i] = strings.TrimSpace(mt)
-		}
-	} else {
+	// Parse metric types, skipping unknown ones
+	metricTypes, ignored, err := parseMetricTypes(c, d.healthService, userID)
+	if err != nil {
+		d.logger.Error("Failed to resolve metric types",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve dashboard trends")
+		return
+	}
+	if len(metricTypes) == 0 && len(ignored) == 0 {
 		// Default to key health metrics for dashboard (excluding blood pressure for graphing)
 		metricTypes = []string{
 			"heart_rate",
@@ -87,12 +89,16 @@ func (d *DashboardHandler) GetTrends(c *gin.Context) {
 	// Get health trends
 	trends, err := d.healthService.GetHealthTrends(userID, metricTypes, period)
 	if err != nil {
+		if errors.Is(err, services.ErrTrendsTooComplex) {
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, err.Error())
+			return
+		}
 		d.logger.Error("Failed to get health trends for dashboard",
 			zap.String("user_id", userID),
 			zap.String("period", period),
 			zap.Strings("metric_types", metricTypes),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve dashboard trends")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve dashboard trends")
 		return
 	}
 
@@ -105,9 +111,10 @@ func (d *DashboardHandler) GetTrends(c *gin.Context) {
 		zap.Int("trends_count", len(trends)))
 
 	utils.SuccessResponse(c, http.StatusOK, "Dashboard trends retrieved successfully", gin.H{
-		"period": period,
-		"trends": dashboardTrends,
-		"count":  len(trends),
+		"period":               period,
+		"trends":               dashboardTrends,
+		"count":                len(trends),
+		"ignored_metric_types": ignored,
 	})
 }
 
@@ -115,7 +122,7 @@ func (d *DashboardHandler) GetTrends(c *gin.Context) {
 func (d *DashboardHandler) GetOverview(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -125,7 +132,7 @@ func (d *DashboardHandler) GetOverview(c *gin.Context) {
 		d.logger.Error("Failed to get health summary for overview",
 			zap.String("user_id", userID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve dashboard overview")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve dashboard overview")
 		return
 	}
 
@@ -142,13 +149,39 @@ func (d *DashboardHandler) GetOverview(c *gin.Context) {
 		recentTrends = []models.HealthTrend{}
 	}
 
+	healthScore, err := d.healthService.ComputeHealthScore(userID)
+	if err != nil {
+		d.logger.Warn("Failed to compute health score for overview",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		// Continue without a health score
+	}
+
+	alerts, err := d.healthService.GenerateAlerts(userID)
+	if err != nil {
+		d.logger.Warn("Failed to generate health alerts for overview",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		// Continue without alerts
+		alerts = []models.HealthAlert{}
+	}
+
+	recommendations, err := d.healthService.GenerateRecommendations(userID)
+	if err != nil {
+		d.logger.Warn("Failed to generate recommendations for overview",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		// Continue without recommendations
+		recommendations = []models.Recommendation{}
+	}
+
 	// Create overview data
 	overview := gin.H{
 		"summary":         d.enrichSummaryData(summary),
 		"recent_trends":   recentTrends,
-		"health_score":    d.calculateHealthScore(summary),
-		"recommendations": d.generateRecommendations(summary),
-		"alerts":          d.checkHealthAlerts(summary),
+		"health_score":    healthScore,
+		"recommendations": recommendations,
+		"alerts":          alerts,
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Dashboard overview retrieved successfully", overview)
@@ -174,87 +207,30 @@ func (d *DashboardHandler) enrichTrendsData(trends interface{}) interface{} {
 	return trends
 }
 
-// calculateHealthScore calculates an overall health score
-func (d *DashboardHandler) calculateHealthScore(summary interface{}) gin.H {
-	// Placeholder implementation
-	return gin.H{
-		"score":       85,
-		"category":    "Good",
-		"description": "Your health metrics are generally within normal ranges",
-	}
-}
-
-// generateRecommendations generates health recommendations
-func (d *DashboardHandler) generateRecommendations(summary interface{}) []gin.H {
-	// Placeholder implementation
-	return []gin.H{
-		{
-			"type":        "exercise",
-			"title":       "Stay Active",
-			"description": "Aim for 30 minutes of moderate exercise daily",
-			"priority":    "medium",
-		},
-		{
-			"type":        "nutrition",
-			"title":       "Monitor Blood Pressure",
-			"description": "Keep tracking your blood pressure regularly",
-			"priority":    "high",
-		},
-	}
-}
-
-// checkHealthAlerts checks for any health alerts
-func (d *DashboardHandler) checkHealthAlerts(summary interface{}) []gin.H {
-	// Placeholder implementation - would analyze metrics for concerning values
-	return []gin.H{
-		// No alerts in this example
-	}
-}
-
-// GetInsights handles GET /api/dashboard/insights
+// GetInsights handles GET /api/dashboard/insights?refresh=true
 func (d *DashboardHandler) GetInsights(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
-	// Get health summary for insights
-	summary, err := d.healthService.GetHealthSummary(userID)
+	// refresh=true bypasses cached insights and re-runs the agent
+	forceRefresh := c.Query("refresh") == "true"
+
+	insights, err := d.aiAgent.GenerateHealthInsights(c.Request.Context(), userID, forceRefresh)
 	if err != nil {
-		d.logger.Error("Failed to get health summary for insights",
+		d.logger.Error("Failed to generate health insights",
 			zap.String("user_id", userID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve health insights")
+		utils.ErrorResponseWithCode(c, http.StatusBadGateway, utils.CodeUpstreamError, "Failed to generate health insights")
 		return
 	}
 
-	// Generate insights
-	insights := d.generateInsights(summary)
-
 	utils.SuccessResponse(c, http.StatusOK, "Health insights retrieved successfully", gin.H{
-		"insights": insights,
-		"count":    len(insights),
+		"insights":     insights.Insights,
+		"count":        len(insights.Insights),
+		"generated_at": insights.GeneratedAt,
+		"cached":       insights.Cached,
 	})
 }
-
-// generateInsights generates personalized health insights
-func (d *DashboardHandler) generateInsights(summary interface{}) []gin.H {
-	// Placeholder implementation
-	return []gin.H{
-		{
-			"type":        "trend",
-			"title":       "Blood Pressure Trend",
-			"description": "Your blood pressure has been stable over the past month",
-			"confidence":  "high",
-			"action":      "continue_monitoring",
-		},
-		{
-			"type":        "pattern",
-			"title":       "Weight Pattern",
-			"description": "You've been consistently tracking your weight",
-			"confidence":  "medium",
-			"action":      "maintain_routine",
-		},
-	}
-}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/internal/services"
+)
+
+func TestGetInsightsParsesModelOutput(t *testing.T) {
+	cfg := testConfig(t)
+	db, _ := dynamotest.NewClient(cfg)
+	llm := &scriptedLLM{reply: "Here are your insights:\n```json\n" +
+		`[{"type":"risk","title":"Rising blood pressure","description":"Your systolic readings climbed this week.","confidence":"High","action":"consult_doctor"},` +
+		`{"type":"trend","title":"","description":"Dropped for having no title.","confidence":"low","action":"none"}]` +
+		"\n```"}
+	health := services.NewHealthService(db, cfg)
+	rag := services.NewRAGService(emptyVectorStore{}, llm, zeroEmbeddings{}, cfg)
+	agent := services.NewAIAgent(health, rag, llm, services.NewInsightsCache(db, cfg), cfg, zap.NewNop())
+	handler := NewDashboardHandler(health, agent, zap.NewNop())
+
+	router := newTestRouter("user-1")
+	router.GET("/api/dashboard/insights", handler.GetInsights)
+
+	recorder, response := serve(t, router, http.MethodGet, "/api/dashboard/insights", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var data struct {
+		Insights []models.Insight `json:"insights"`
+		Count    int              `json:"count"`
+	}
+	decodeData(t, response, &data)
+
+	want := models.Insight{
+		Type:        "risk",
+		Title:       "Rising blood pressure",
+		Description: "Your systolic readings climbed this week.",
+		Confidence:  "high",
+		Action:      "consult_doctor",
+	}
+	if data.Count != 1 || len(data.Insights) != 1 || data.Insights[0] != want {
+		t.Errorf("insights = %+v (count %d), want only %+v", data.Insights, data.Count, want)
+	}
+}
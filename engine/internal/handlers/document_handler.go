@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -33,7 +37,7 @@ func NewDocumentHandler(documentService *services.DocumentService, ragService *s
 func (d *DocumentHandler) UploadDocument(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -41,14 +45,14 @@ func (d *DocumentHandler) UploadDocument(c *gin.Context) {
 	err := c.Request.ParseMultipartForm(32 << 20) // 32MB
 	if err != nil {
 		d.logger.Error("Failed to parse multipart form", zap.Error(err))
-		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to parse upload form")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Failed to parse upload form")
 		return
 	}
 
 	// Get file from form
 	file, err := c.FormFile("file")
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "No file provided")
 		return
 	}
 
@@ -58,9 +62,14 @@ func (d *DocumentHandler) UploadDocument(c *gin.Context) {
 	request.Category = c.PostForm("category")
 	request.Description = c.PostForm("description")
 
+	// Tags may be repeated form fields, comma-separated, or both
+	for _, value := range c.PostFormArray("tags") {
+		request.Tags = append(request.Tags, strings.Split(value, ",")...)
+	}
+
 	// Validate required fields
 	if request.Title == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Title is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Title is required")
 		return
 	}
 
@@ -71,7 +80,16 @@ func (d *DocumentHandler) UploadDocument(c *gin.Context) {
 			zap.String("user_id", userID),
 			zap.String("filename", file.Filename),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to upload document")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to upload document")
+		return
+	}
+
+	if response.Duplicate {
+		d.logger.Info("Duplicate document upload",
+			zap.String("user_id", userID),
+			zap.String("document_id", response.Document.DocumentID),
+			zap.String("filename", file.Filename))
+		utils.SuccessResponse(c, http.StatusOK, "Document already uploaded", response)
 		return
 	}
 
@@ -83,11 +101,34 @@ func (d *DocumentHandler) UploadDocument(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusCreated, "Document uploaded successfully", response)
 }
 
+// GetDocumentTags handles GET /api/documents/tags
+func (d *DocumentHandler) GetDocumentTags(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	tags, err := d.documentService.GetUserTags(userID)
+	if err != nil {
+		d.logger.Error("Failed to get document tags",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve tags")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Tags retrieved successfully", gin.H{
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
+
 // ListDocuments handles GET /api/documents
 func (d *DocumentHandler) ListDocuments(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -97,17 +138,31 @@ func (d *DocumentHandler) ListDocuments(c *gin.Context) {
 
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit < 1 || limit > 100 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid limit parameter (1-100)")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid limit parameter (1-100)")
 		return
 	}
 
+	filter := models.DocumentFilter{
+		Category:        c.Query("category"),
+		IncludeArchived: c.Query("include_archived") == "true",
+	}
+	for _, tag := range strings.Split(c.Query("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+
 	// Get user documents
-	response, err := d.documentService.GetUserDocuments(userID, limit, cursor)
+	response, err := d.documentService.GetUserDocuments(userID, filter, limit, cursor)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid cursor parameter")
+		return
+	}
 	if err != nil {
 		d.logger.Error("Failed to get user documents",
 			zap.String("user_id", userID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve documents")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve documents")
 		return
 	}
 
@@ -118,13 +173,13 @@ func (d *DocumentHandler) ListDocuments(c *gin.Context) {
 func (d *DocumentHandler) GetDocument(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	documentID := c.Param("id")
 	if documentID == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Document ID is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
 		return
 	}
 
@@ -135,24 +190,172 @@ func (d *DocumentHandler) GetDocument(c *gin.Context) {
 			zap.String("user_id", userID),
 			zap.String("document_id", documentID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusNotFound, "Document not found")
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Document not found")
 		return
 	}
 
+	c.Header("ETag", documentETag(document.Version))
 	utils.SuccessResponse(c, http.StatusOK, "Document retrieved successfully", document)
 }
 
+// UpdateDocument handles PATCH /api/documents/:id. The expected version comes from the If-Match
+// header (the ETag returned by GET) or the body's version field; without either the update is
+// applied unconditionally.
+func (d *DocumentHandler) UpdateDocument(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	documentID := c.Param("id")
+	if documentID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
+		return
+	}
+
+	var request models.DocumentUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		d.logger.Error("Failed to bind document update", zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid input format")
+		return
+	}
+
+	expectedVersion := -1
+	if request.Version != nil {
+		expectedVersion = *request.Version
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && ifMatch != "*" {
+		version, err := parseDocumentETag(ifMatch)
+		if err != nil {
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid If-Match header")
+			return
+		}
+		expectedVersion = version
+	}
+
+	document, err := d.documentService.UpdateDocumentDetails(userID, documentID, &request, expectedVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrVersionConflict):
+			utils.ErrorResponseWithCode(c, http.StatusConflict, utils.CodeConflict, "Document was modified by another request; reload it and try again")
+		case errors.Is(err, services.ErrInvalidDocumentUpdate):
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, err.Error())
+		case errors.Is(err, services.ErrDocumentNotFound):
+			utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Document not found")
+		default:
+			d.logger.Error("Failed to update document",
+				zap.String("user_id", userID),
+				zap.String("document_id", documentID),
+				zap.Error(err))
+			utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to update document")
+		}
+		return
+	}
+
+	c.Header("ETag", documentETag(document.Version))
+	utils.SuccessResponse(c, http.StatusOK, "Document updated successfully", document)
+}
+
+// documentETag formats a document version as an ETag
+func documentETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// parseDocumentETag reads a version from an If-Match value such as "3" or W/"3"
+func parseDocumentETag(value string) (int, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	version, err := strconv.Atoi(strings.Trim(value, "\""))
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid document ETag: %q", value)
+	}
+	return version, nil
+}
+
+// GetDocumentStatus handles GET /api/documents/:id/status
+func (d *DocumentHandler) GetDocumentStatus(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	documentID := c.Param("id")
+	if documentID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
+		return
+	}
+
+	status, err := d.documentService.GetDocumentStatus(userID, documentID)
+	if err != nil {
+		d.logger.Error("Failed to get document status",
+			zap.String("user_id", userID),
+			zap.String("document_id", documentID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Document not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Document status retrieved successfully", status)
+}
+
+// ArchiveDocument handles POST /api/documents/:id/archive
+func (d *DocumentHandler) ArchiveDocument(c *gin.Context) {
+	d.setArchived(c, true)
+}
+
+// UnarchiveDocument handles POST /api/documents/:id/unarchive
+func (d *DocumentHandler) UnarchiveDocument(c *gin.Context) {
+	d.setArchived(c, false)
+}
+
+// setArchived archives or restores the document named by the :id path parameter
+func (d *DocumentHandler) setArchived(c *gin.Context, archived bool) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	documentID := c.Param("id")
+	if documentID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
+		return
+	}
+
+	document, err := d.documentService.SetArchived(userID, documentID, archived)
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Document not found")
+			return
+		}
+		d.logger.Error("Failed to update document archived flag",
+			zap.String("user_id", userID),
+			zap.String("document_id", documentID),
+			zap.Bool("archived", archived),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to update document")
+		return
+	}
+
+	message := "Document restored successfully"
+	if archived {
+		message = "Document archived successfully"
+	}
+	utils.SuccessResponse(c, http.StatusOK, message, document)
+}
+
 // DeleteDocument handles DELETE /api/documents/:id
 func (d *DocumentHandler) DeleteDocument(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	documentID := c.Param("id")
 	if documentID == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Document ID is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
 		return
 	}
 
@@ -171,7 +374,7 @@ func (d *DocumentHandler) DeleteDocument(c *gin.Context) {
 			zap.String("user_id", userID),
 			zap.String("document_id", documentID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete document")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to delete document")
 		return
 	}
 
@@ -189,23 +392,23 @@ func (d *DocumentHandler) DeleteDocument(c *gin.Context) {
 func (d *DocumentHandler) ProcessDocument(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	documentID := c.Param("id")
 	if documentID == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Document ID is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
 		return
 	}
 
 	// Process document (extract text and create embeddings)
-	if err := d.documentService.ProcessDocument(userID, documentID); err != nil {
+	if err := d.documentService.ProcessDocument(c.Request.Context(), userID, documentID); err != nil {
 		d.logger.Error("Failed to process document",
 			zap.String("user_id", userID),
 			zap.String("document_id", documentID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process document")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to process document")
 		return
 	}
 
@@ -223,23 +426,23 @@ func (d *DocumentHandler) ProcessDocument(c *gin.Context) {
 func (d *DocumentHandler) RetryProcessDocument(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	documentID := c.Param("id")
 	if documentID == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Document ID is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
 		return
 	}
 
 	// Retry processing document
-	if err := d.documentService.RetryProcessDocument(userID, documentID); err != nil {
+	if err := d.documentService.RetryProcessDocument(c.Request.Context(), userID, documentID); err != nil {
 		d.logger.Error("Failed to retry document processing",
 			zap.String("user_id", userID),
 			zap.String("document_id", documentID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retry document processing")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retry document processing")
 		return
 	}
 
@@ -253,11 +456,42 @@ func (d *DocumentHandler) RetryProcessDocument(c *gin.Context) {
 	})
 }
 
+// ReprocessDocuments handles POST /api/documents/reprocess. It re-embeds all of the caller's
+// processed documents, or those of the user named by the user_id query parameter.
+func (d *DocumentHandler) ReprocessDocuments(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	targetUserID := c.DefaultQuery("user_id", userID)
+
+	result, err := d.documentService.ReprocessAll(c.Request.Context(), targetUserID)
+	if err != nil {
+		d.logger.Error("Failed to reprocess documents",
+			zap.String("user_id", userID),
+			zap.String("target_user_id", targetUserID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to reprocess documents")
+		return
+	}
+
+	d.logger.Info("Document reprocessing queued",
+		zap.String("user_id", userID),
+		zap.String("target_user_id", targetUserID),
+		zap.Int("total", result.Total),
+		zap.Int("queued", result.Queued),
+		zap.Int("failed", len(result.Failures)))
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Document reprocessing queued", result)
+}
+
 // QueryDocuments handles POST /api/documents/query
 func (d *DocumentHandler) QueryDocuments(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -267,7 +501,7 @@ func (d *DocumentHandler) QueryDocuments(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request format")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid request format")
 		return
 	}
 
@@ -283,7 +517,7 @@ func (d *DocumentHandler) QueryDocuments(c *gin.Context) {
 			zap.String("user_id", userID),
 			zap.String("query", request.Query),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to query documents")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to query documents")
 		return
 	}
 
@@ -303,52 +537,85 @@ func (d *DocumentHandler) QueryDocuments(c *gin.Context) {
 func (d *DocumentHandler) SearchDocuments(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	query := c.Query("q")
 	if query == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Search query is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Search query is required")
 		return
 	}
 
 	limitStr := c.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit < 1 || limit > 50 {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid limit parameter (1-50)")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid limit parameter (1-50)")
+		return
+	}
+
+	mode := c.DefaultQuery("mode", "semantic")
+	switch mode {
+	case "semantic", "hybrid":
+	case "metadata":
+		d.searchDocumentMetadata(c, userID, query, limit)
+		return
+	default:
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid mode parameter (semantic, hybrid or metadata)")
 		return
 	}
 
 	// Search documents using RAG service
-	sources, err := d.ragService.SearchDocuments(c.Request.Context(), userID, query, limit)
+	sources, err := d.ragService.SearchDocuments(c.Request.Context(), userID, query, limit, mode == "hybrid")
 	if err != nil {
 		d.logger.Error("Failed to search documents",
 			zap.String("user_id", userID),
 			zap.String("query", query),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to search documents")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to search documents")
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Document search completed", gin.H{
 		"query":   query,
+		"mode":    mode,
 		"results": sources,
 		"count":   len(sources),
 	})
 }
 
+// searchDocumentMetadata handles GET /api/documents/search?mode=metadata, matching the query
+// against document titles, descriptions and tags instead of their content
+func (d *DocumentHandler) searchDocumentMetadata(c *gin.Context, userID, query string, limit int) {
+	documents, err := d.documentService.SearchMetadata(userID, query, limit)
+	if err != nil {
+		d.logger.Error("Failed to search document metadata",
+			zap.String("user_id", userID),
+			zap.String("query", query),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to search documents")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Document search completed", gin.H{
+		"query":   query,
+		"mode":    "metadata",
+		"results": documents,
+		"count":   len(documents),
+	})
+}
+
 // GetDocumentViewURL handles GET /api/documents/:id/view
 func (d *DocumentHandler) GetDocumentViewURL(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	documentID := c.Param("id")
 	if documentID == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Document ID is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
 		return
 	}
 
@@ -359,18 +626,25 @@ func (d *DocumentHandler) GetDocumentViewURL(c *gin.Context) {
 			zap.String("user_id", userID),
 			zap.String("document_id", documentID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusNotFound, "Document not found")
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Document not found")
+		return
+	}
+
+	// Requested lifetime defaults to 1 hour; the service clamps it to the configured maximum
+	expiresInMinutes, err := strconv.Atoi(c.DefaultQuery("expires_in_minutes", "60"))
+	if err != nil || expiresInMinutes < 1 {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid expires_in_minutes parameter")
 		return
 	}
 
-	// Generate presigned URL for viewing (valid for 1 hour)
-	viewURL, err := d.documentService.GetDocumentViewURL(userID, documentID, 60)
+	// Generate presigned URL for viewing
+	viewURL, effectiveMinutes, err := d.documentService.GetDocumentViewURL(userID, documentID, expiresInMinutes)
 	if err != nil {
 		d.logger.Error("Failed to generate document view URL",
 			zap.String("user_id", userID),
 			zap.String("document_id", documentID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate view URL")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to generate view URL")
 		return
 	}
 
@@ -379,12 +653,169 @@ func (d *DocumentHandler) GetDocumentViewURL(c *gin.Context) {
 		zap.String("document_id", documentID),
 		zap.String("content_type", document.ContentType))
 
+	d.recordAccess(c, userID, documentID, models.AccessActionView)
+
 	utils.SuccessResponse(c, http.StatusOK, "Document view URL generated successfully", gin.H{
 		"document_id":  documentID,
 		"view_url":     viewURL,
 		"content_type": document.ContentType,
 		"file_name":    document.FileName,
 		"title":        document.Title,
-		"expires_in":   3600, // 1 hour in seconds
+		"expires_in":   effectiveMinutes * 60, // seconds
+		"expires_at":   time.Now().Add(time.Duration(effectiveMinutes) * time.Minute),
 	})
 }
+
+// GetDocumentAccessLog handles GET /api/documents/:id/access-log
+func (d *DocumentHandler) GetDocumentAccessLog(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	documentID := c.Param("id")
+	if documentID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 500 {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid limit parameter (1-500)")
+		return
+	}
+
+	// Verify ownership before exposing the log
+	if _, err := d.documentService.GetDocument(userID, documentID); err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Document not found")
+		return
+	}
+
+	entries, err := d.documentService.GetDocumentAccessLog(userID, documentID, limit)
+	if err != nil {
+		d.logger.Error("Failed to get document access log",
+			zap.String("user_id", userID),
+			zap.String("document_id", documentID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve access log")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Access log retrieved successfully", gin.H{
+		"document_id": documentID,
+		"entries":     entries,
+		"count":       len(entries),
+	})
+}
+
+// GetReferenceRanges handles GET /api/documents/:id/reference-ranges
+func (d *DocumentHandler) GetReferenceRanges(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	documentID := c.Param("id")
+	if documentID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
+		return
+	}
+
+	// Verify ownership first so a missing document is reported as 404
+	if _, err := d.documentService.GetDocument(userID, documentID); err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Document not found")
+		return
+	}
+
+	report, err := d.documentService.ReconcileReferenceRanges(userID, documentID)
+	if err != nil {
+		d.logger.Error("Failed to reconcile reference ranges",
+			zap.String("user_id", userID),
+			zap.String("document_id", documentID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to reconcile reference ranges")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reference ranges reconciled successfully", report)
+}
+
+// GetDocumentChunks handles GET /api/documents/:id/chunks, returning the chunks indexed for a
+// processed document a page at a time
+func (d *DocumentHandler) GetDocumentChunks(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	documentID := c.Param("id")
+	if documentID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Document ID is required")
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid page parameter")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 100 {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid limit parameter (1-100)")
+		return
+	}
+
+	document, err := d.documentService.GetDocument(userID, documentID)
+	if err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Document not found")
+		return
+	}
+	if !document.IsProcessed() {
+		utils.ErrorResponseWithCode(c, http.StatusConflict, utils.CodeConflict, "Document has not been processed")
+		return
+	}
+
+	chunks, err := d.ragService.GetDocumentChunks(c.Request.Context(), userID, documentID, (page-1)*limit, limit)
+	if err != nil {
+		d.logger.Error("Failed to get document chunks",
+			zap.String("user_id", userID),
+			zap.String("document_id", documentID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve document chunks")
+		return
+	}
+
+	totalPages := (document.ChunkCount + limit - 1) / limit
+	utils.PaginatedSuccessResponse(c, http.StatusOK, "Document chunks retrieved successfully", gin.H{
+		"document_id": documentID,
+		"chunks":      chunks,
+		"count":       len(chunks),
+	}, utils.Pagination{
+		CurrentPage: page,
+		TotalPages:  totalPages,
+		TotalCount:  document.ChunkCount,
+		HasNext:     page < totalPages,
+		HasPrevious: page > 1,
+	})
+}
+
+// recordAccess writes an access-log entry in the background so auditing doesn't add request latency
+func (d *DocumentHandler) recordAccess(c *gin.Context, userID, documentID, action string) {
+	// Copy request details now; the gin context must not be used after the handler returns
+	ipAddress := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+
+	go func() {
+		if err := d.documentService.RecordDocumentAccess(userID, documentID, action, ipAddress, userAgent); err != nil {
+			d.logger.Warn("Failed to record document access",
+				zap.String("user_id", userID),
+				zap.String("document_id", documentID),
+				zap.String("action", action),
+				zap.Error(err))
+		}
+	}()
+}
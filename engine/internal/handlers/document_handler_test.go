@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/internal/services"
+	"health-dashboard-backend/internal/vectordb"
+)
+
+// documentFixture is a DocumentHandler without S3 or a vector store, backed by an in-memory DynamoDB
+type documentFixture struct {
+	cfg     *config.Config
+	db      *database.DynamoDBClient
+	fake    *dynamotest.Fake
+	service *services.DocumentService
+	handler *DocumentHandler
+}
+
+func newDocumentFixture(t *testing.T) *documentFixture {
+	t.Helper()
+
+	f := &documentFixture{cfg: testConfig(t)}
+	f.db, f.fake = dynamotest.NewClient(f.cfg)
+	f.service = services.NewDocumentService(nil, f.db, nil, f.cfg)
+	t.Cleanup(func() { f.service.Shutdown(context.Background()) })
+	f.handler = NewDocumentHandler(f.service, nil, zap.NewNop())
+	return f
+}
+
+// routes returns a router for userID with the document routes registered as in main
+func (f *documentFixture) routes(userID string) *gin.Engine {
+	router := newTestRouter(userID)
+	documents := router.Group("/api/documents")
+	documents.GET("", f.handler.ListDocuments)
+	documents.GET("/tags", f.handler.GetDocumentTags)
+	documents.GET("/:id", f.handler.GetDocument)
+	documents.PATCH("/:id", f.handler.UpdateDocument)
+	documents.GET("/:id/status", f.handler.GetDocumentStatus)
+	documents.GET("/:id/chunks", f.handler.GetDocumentChunks)
+	documents.POST("/:id/archive", f.handler.ArchiveDocument)
+	documents.POST("/:id/unarchive", f.handler.UnarchiveDocument)
+	return router
+}
+
+// putDocument stores a document record for userID with the given status
+func (f *documentFixture) putDocument(t *testing.T, userID, documentID, status string) *models.Document {
+	t.Helper()
+
+	document := &models.Document{
+		UserID:     userID,
+		DocumentID: documentID,
+		SortKey:    "general#" + documentID,
+		Title:      "Lipid panel",
+		FileName:   documentID + ".pdf",
+		Category:   "general",
+		Status:     status,
+		UploadTime: time.Now().UTC(),
+	}
+	if err := f.db.PutDocument(document); err != nil {
+		t.Fatalf("put document: %v", err)
+	}
+	return document
+}
+
+func TestDocumentStatusReflectsProcessing(t *testing.T) {
+	f := newDocumentFixture(t)
+	document := f.putDocument(t, "user-1", "doc-1", models.StatusUploaded)
+	router := f.routes("user-1")
+
+	status := func() models.DocumentStatus {
+		t.Helper()
+		recorder, response := serve(t, router, http.MethodGet, "/api/documents/doc-1/status", nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status: %d (%s)", recorder.Code, recorder.Body.String())
+		}
+		var status models.DocumentStatus
+		decodeData(t, response, &status)
+		return status
+	}
+
+	if got := status(); got.Status != models.StatusUploaded || got.ProcessedAt != nil {
+		t.Errorf("after upload: %+v, want uploaded and not processed", got)
+	}
+
+	document.Status = models.StatusProcessing
+	if err := f.db.UpdateDocument(document); err != nil {
+		t.Fatalf("update document: %v", err)
+	}
+	if got := status(); got.Status != models.StatusProcessing {
+		t.Errorf("while processing: %+v, want processing", got)
+	}
+
+	document.Status = models.StatusProcessed
+	document.ChunkCount = 7
+	document.ProcessedAt = time.Now().UTC().Truncate(time.Second)
+	if err := f.db.UpdateDocument(document); err != nil {
+		t.Fatalf("update document: %v", err)
+	}
+	got := status()
+	if got.Status != models.StatusProcessed || got.ChunkCount != 7 || got.ProcessedAt == nil || !got.ProcessedAt.Equal(document.ProcessedAt) {
+		t.Errorf("after processing: %+v, want processed with 7 chunks at %v", got, document.ProcessedAt)
+	}
+
+	if recorder, _ := serve(t, f.routes("user-2"), http.MethodGet, "/api/documents/doc-1/status", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("another user: status %d, want 404", recorder.Code)
+	}
+}
+
+func TestDocumentTagsAreNormalizedAndDistinct(t *testing.T) {
+	f := newDocumentFixture(t)
+	f.cfg.TagSynonyms = map[string]string{"labs": "lab"}
+
+	for id, tags := range map[string][]string{
+		"doc-1": {"Lab", "Cardiology"},
+		"doc-2": {"lab ", "LABS"},
+		"doc-3": {"  annual   checkup"},
+	} {
+		document := f.putDocument(t, "user-1", id, models.StatusProcessed)
+		document.Tags = tags
+		if err := f.db.PutDocument(document); err != nil {
+			t.Fatalf("put document: %v", err)
+		}
+	}
+	other := f.putDocument(t, "user-2", "doc-4", models.StatusProcessed)
+	other.Tags = []string{"private"}
+	if err := f.db.PutDocument(other); err != nil {
+		t.Fatalf("put document: %v", err)
+	}
+
+	recorder, response := serve(t, f.routes("user-1"), http.MethodGet, "/api/documents/tags", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	decodeData(t, response, &body)
+	if strings.Join(body.Tags, ",") != "annual checkup,cardiology,lab" {
+		t.Errorf("tags = %q, want annual checkup, cardiology and lab", body.Tags)
+	}
+}
+
+func TestConflictingDocumentUpdateIsRejected(t *testing.T) {
+	f := newDocumentFixture(t)
+	f.putDocument(t, "user-1", "doc-1", models.StatusProcessed)
+	router := f.routes("user-1")
+
+	recorder, _ := serve(t, router, http.MethodGet, "/api/documents/doc-1", nil)
+	etag := recorder.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("ETag = %q, want \"1\"", etag)
+	}
+
+	patch := func(ifMatch, title string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPatch, "/api/documents/doc-1", strings.NewReader(`{"title":"`+title+`"}`))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("If-Match", ifMatch)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Both tabs loaded version 1; the second save must not overwrite the first
+	if recorder := patch(etag, "March lipid panel"); recorder.Code != http.StatusOK || recorder.Header().Get("ETag") != `"2"` {
+		t.Fatalf("first update: status %d ETag %q, want 200 and \"2\"", recorder.Code, recorder.Header().Get("ETag"))
+	}
+	if recorder := patch(etag, "Cholesterol"); recorder.Code != http.StatusConflict {
+		t.Fatalf("stale update: status %d, want 409", recorder.Code)
+	}
+	if recorder := patch("not-a-version", "Cholesterol"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("invalid If-Match: status %d, want 400", recorder.Code)
+	}
+
+	document, err := f.db.GetDocument("user-1", "doc-1")
+	if err != nil {
+		t.Fatalf("get document: %v", err)
+	}
+	if document.Title != "March lipid panel" {
+		t.Errorf("title = %q, want the first update kept", document.Title)
+	}
+
+	recorder, _ = serve(t, router, http.MethodPatch, "/api/documents/doc-1", map[string]interface{}{"title": "Cholesterol", "version": 1})
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("stale body version: status %d, want 409", recorder.Code)
+	}
+}
+
+func TestArchivedDocumentsAreHiddenAndRestorable(t *testing.T) {
+	f := newDocumentFixture(t)
+	f.putDocument(t, "user-1", "doc-1", models.StatusProcessed)
+	f.putDocument(t, "user-1", "doc-2", models.StatusProcessed)
+	router := f.routes("user-1")
+
+	listed := func(query string) map[string]bool {
+		t.Helper()
+		recorder, response := serve(t, router, http.MethodGet, "/api/documents"+query, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("list: status %d (%s)", recorder.Code, recorder.Body.String())
+		}
+		var list models.DocumentListResponse
+		decodeData(t, response, &list)
+		ids := make(map[string]bool)
+		for _, document := range list.Documents {
+			ids[document.DocumentID] = true
+		}
+		return ids
+	}
+
+	if recorder, _ := serve(t, router, http.MethodPost, "/api/documents/doc-1/archive", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("archive: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	if ids := listed(""); ids["doc-1"] || !ids["doc-2"] {
+		t.Errorf("default list = %v, want only doc-2", ids)
+	}
+	if ids := listed("?include_archived=true"); !ids["doc-1"] || !ids["doc-2"] {
+		t.Errorf("list including archived = %v, want both documents", ids)
+	}
+
+	// Another user can't restore it
+	if recorder, _ := serve(t, f.routes("user-2"), http.MethodPost, "/api/documents/doc-1/unarchive", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("unarchive by another user: status %d, want 404", recorder.Code)
+	}
+
+	if recorder, _ := serve(t, router, http.MethodPost, "/api/documents/doc-1/unarchive", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("unarchive: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	if ids := listed(""); !ids["doc-1"] || !ids["doc-2"] {
+		t.Errorf("list after restoring = %v, want both documents", ids)
+	}
+}
+
+func TestDocumentChunksAreOwnerOnly(t *testing.T) {
+	f := newDocumentFixture(t)
+	vectors := chunkVectorStore{results: []vectordb.QueryResult{
+		{ID: "doc-1#1", Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "doc-1", "chunk_index": 1, "page_number": 2, "content": "LDL 140 mg/dL"}},
+		{ID: "doc-1#0", Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "doc-1", "chunk_index": 0, "page_number": 1, "content": "Total cholesterol 212 mg/dL"}},
+	}}
+	rag := services.NewRAGService(vectors, &scriptedLLM{}, zeroEmbeddings{}, f.cfg)
+	f.handler = NewDocumentHandler(f.service, rag, zap.NewNop())
+
+	document := f.putDocument(t, "user-1", "doc-1", models.StatusProcessed)
+	document.ChunkCount = 2
+	if err := f.db.PutDocument(document); err != nil {
+		t.Fatalf("put document: %v", err)
+	}
+
+	recorder, response := serve(t, f.routes("user-1"), http.MethodGet, "/api/documents/doc-1/chunks", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("owner: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var data struct {
+		Chunks []models.IndexedChunk `json:"chunks"`
+	}
+	decodeData(t, response, &data)
+	if len(data.Chunks) != 2 || data.Chunks[0].Content != "Total cholesterol 212 mg/dL" || data.Chunks[1].PageNumber != 2 {
+		t.Errorf("chunks = %+v, want both chunks in order with their pages", data.Chunks)
+	}
+
+	if recorder, _ := serve(t, f.routes("user-2"), http.MethodGet, "/api/documents/doc-1/chunks", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("another user: status %d, want 404", recorder.Code)
+	}
+}
+
+func TestDocumentListFiltersByCategoryAndTags(t *testing.T) {
+	f := newDocumentFixture(t)
+	f.fake.MaxPageItems = 2 // Matches are spread across several pages
+
+	for id, fields := range map[string]struct {
+		category string
+		tags     []string
+	}{
+		"doc-1": {"lab", []string{"lipids", "annual"}},
+		"doc-2": {"lab", []string{"lipids"}},
+		"doc-3": {"imaging", []string{"annual"}},
+		"doc-4": {"lab", []string{"annual"}},
+		"doc-5": {"prescription", nil},
+	} {
+		document := f.putDocument(t, "user-1", id, models.StatusProcessed)
+		document.Category, document.SortKey, document.Tags = fields.category, fields.category+"#"+id, fields.tags
+		if err := f.db.PutDocument(document); err != nil {
+			t.Fatalf("put document: %v", err)
+		}
+	}
+	router := f.routes("user-1")
+
+	list := func(query string) string {
+		t.Helper()
+		var ids []string
+		cursor := ""
+		for {
+			recorder, response := serve(t, router, http.MethodGet, "/api/documents?limit=1&"+query+"&cursor="+cursor, nil)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("%s: status %d (%s)", query, recorder.Code, recorder.Body.String())
+			}
+			var page models.DocumentListResponse
+			decodeData(t, response, &page)
+			for _, document := range page.Documents {
+				ids = append(ids, document.DocumentID)
+			}
+			if !page.HasMore {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		sort.Strings(ids)
+		return strings.Join(ids, ",")
+	}
+
+	for query, want := range map[string]string{
+		"category=lab":              "doc-1,doc-2,doc-4",
+		"tags=Annual":               "doc-1,doc-3,doc-4", // Filter tags are normalized like stored ones
+		"tags=lipids,annual":        "doc-1",
+		"category=lab&tags=lipids":  "doc-1,doc-2",
+		"category=imaging&tags=lab": "",
+	} {
+		if got := list(query); got != want {
+			t.Errorf("%s: listed %q, want %q", query, got, want)
+		}
+	}
+}
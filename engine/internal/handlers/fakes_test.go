@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+
+	"health-dashboard-backend/internal/vectordb"
+	"health-dashboard-backend/pkg/ai"
+)
+
+// emptyVectorStore is a VectorStore holding no vectors
+type emptyVectorStore struct{}
+
+func (emptyVectorStore) Namespace(userID string) string { return "user-" + userID }
+
+func (emptyVectorStore) UpsertVectors(ctx context.Context, namespace string, vectors []vectordb.Vector) error {
+	return nil
+}
+
+func (emptyVectorStore) QueryVectors(ctx context.Context, namespace string, queryVector []float32, topK int, filter vectordb.VectorMetadata) (*vectordb.QueryResponse, error) {
+	return &vectordb.QueryResponse{}, nil
+}
+
+func (emptyVectorStore) DeleteVectorsByFilter(ctx context.Context, namespace string, filter vectordb.VectorMetadata) error {
+	return nil
+}
+
+func (emptyVectorStore) IndexDimension(ctx context.Context) (int, error) { return 0, nil }
+
+func (emptyVectorStore) GetIndexStats(ctx context.Context) (interface{}, error) { return nil, nil }
+
+// zeroEmbeddings embeds every text as a zero vector
+type zeroEmbeddings struct{}
+
+func (zeroEmbeddings) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return make([]float32, 8), nil
+}
+
+func (zeroEmbeddings) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i := range texts {
+		embeddings[i] = make([]float32, 8)
+	}
+	return embeddings, nil
+}
+
+// scriptedLLM answers with reply, or when tokens is set streams each token sent on it until the
+// channel is closed
+type scriptedLLM struct {
+	reply  string
+	tokens chan string
+}
+
+func (l *scriptedLLM) GenerateResponse(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (*ai.ChatResponse, error) {
+	return &ai.ChatResponse{Content: l.reply, TokensUsed: 42}, nil
+}
+
+func (l *scriptedLLM) GenerateResponseStream(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (<-chan ai.StreamChunk, error) {
+	chunks := make(chan ai.StreamChunk)
+	go func() {
+		defer close(chunks)
+		if l.tokens == nil {
+			chunks <- ai.StreamChunk{Content: l.reply}
+		} else {
+			for token := range l.tokens {
+				chunks <- ai.StreamChunk{Content: token}
+			}
+		}
+		chunks <- ai.StreamChunk{Done: true, TokensUsed: 42}
+	}()
+	return chunks, nil
+}
+
+func (l *scriptedLLM) HealthCheck(ctx context.Context) error { return nil }
+
+// chunkVectorStore answers every query with results, as an index holding one document's chunks
+type chunkVectorStore struct {
+	emptyVectorStore
+	results []vectordb.QueryResult
+}
+
+func (s chunkVectorStore) QueryVectors(ctx context.Context, namespace string, queryVector []float32, topK int, filter vectordb.VectorMetadata) (*vectordb.QueryResponse, error) {
+	return &vectordb.QueryResponse{Results: s.results}, nil
+}
+
+func (chunkVectorStore) IndexDimension(ctx context.Context) (int, error) { return 8, nil }
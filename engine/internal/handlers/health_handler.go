@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -33,31 +39,53 @@ func NewHealthHandler(healthService *services.HealthService, logger *zap.Logger)
 func (h *HealthHandler) AddHealthData(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	var input models.HealthMetricInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.logger.Error("Failed to bind health metric input", zap.Error(err))
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid input format")
+		return
+	}
+	if !middleware.CheckMetricAccess(c, input.Type) {
 		return
 	}
 
 	// Validate input
-	if err := h.healthService.ValidateHealthData(&input); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := h.healthService.ValidateHealthData(userID, &input); err != nil {
+		h.validationErrorResponse(c, err)
 		return
 	}
 
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		input.IdempotencyKey = key
+	}
+
 	// Add health data
-	metric, err := h.healthService.AddHealthData(userID, &input)
+	metric, replayed, err := h.healthService.AddHealthData(userID, &input)
 	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedMetric) || errors.Is(err, services.ErrInvalidIdempotencyKey) ||
+			errors.Is(err, services.ErrFutureTimestamp) {
+			h.validationErrorResponse(c, err)
+			return
+		}
+		if errors.Is(err, services.ErrIdempotencyKeyReused) {
+			utils.ErrorResponseWithCode(c, http.StatusUnprocessableEntity, utils.CodeValidationError, err.Error())
+			return
+		}
 		h.logger.Error("Failed to add health data",
 			zap.String("user_id", userID),
 			zap.String("metric_type", input.Type),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save health data")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to save health data")
+		return
+	}
+
+	if replayed {
+		c.Header("Idempotent-Replayed", "true")
+		utils.SuccessResponse(c, http.StatusOK, "Health data already saved", metric)
 		return
 	}
 
@@ -69,29 +97,121 @@ func (h *HealthHandler) AddHealthData(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusCreated, "Health data saved successfully", metric)
 }
 
+// ImportMetricsCSV handles POST /api/health/metrics/import
+func (h *HealthHandler) ImportMetricsCSV(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "No file provided")
+		return
+	}
+
+	if !strings.EqualFold(filepath.Ext(fileHeader.Filename), ".csv") {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Only CSV files are supported")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		h.logger.Error("Failed to open uploaded CSV", zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to read file")
+		return
+	}
+	defer file.Close()
+
+	rows, results, err := h.healthService.ParseMetricsCSV(file)
+	if err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, err.Error())
+		return
+	}
+
+	// Validate each row the same way single-metric submissions are validated
+	validRows := make([]models.MetricImportRow, 0, len(rows))
+	for _, row := range rows {
+		if !middleware.CanAccessMetric(c, row.Input.Type) {
+			results = append(results, models.MetricImportRowResult{
+				Row:   row.Row,
+				Type:  row.Input.Type,
+				Error: fmt.Sprintf("access to metric type %s is not allowed", row.Input.Type),
+			})
+			continue
+		}
+		if err := h.healthService.ValidateHealthData(userID, &row.Input); err != nil {
+			results = append(results, models.MetricImportRowResult{
+				Row:   row.Row,
+				Type:  row.Input.Type,
+				Error: err.Error(),
+			})
+			continue
+		}
+		validRows = append(validRows, row)
+	}
+
+	if len(validRows) > 0 {
+		results = append(results, h.healthService.AddHealthDataBatch(userID, validRows)...)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Row < results[j].Row
+	})
+
+	report := models.MetricImportReport{
+		TotalRows: len(results),
+		Results:   results,
+	}
+	for _, result := range results {
+		if result.Success {
+			report.Imported++
+		} else {
+			report.Failed++
+		}
+	}
+
+	h.logger.Info("Health metrics CSV imported",
+		zap.String("user_id", userID),
+		zap.String("filename", fileHeader.Filename),
+		zap.Int("imported", report.Imported),
+		zap.Int("failed", report.Failed))
+
+	utils.SuccessResponse(c, http.StatusOK,
+		fmt.Sprintf("Imported %d of %d rows", report.Imported, report.TotalRows), report)
+}
+
 // AddCompositeHealthData handles POST /api/health/metrics/composite
 func (h *HealthHandler) AddCompositeHealthData(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	var input models.CompositeHealthMetricInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		h.logger.Error("Failed to bind composite health metric input", zap.Error(err))
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid input format")
+		return
+	}
+	if !middleware.CheckMetricAccess(c, services.CompositeMetricTypes(&input)...) {
 		return
 	}
 
 	// Add composite health data
 	result, err := h.healthService.AddCompositeHealthData(userID, &input)
 	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedMetric) || errors.Is(err, services.ErrFutureTimestamp) {
+			h.validationErrorResponse(c, err)
+			return
+		}
 		h.logger.Error("Failed to add composite health data",
 			zap.String("user_id", userID),
 			zap.String("metric_type", input.Type),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save health data")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to save health data")
 		return
 	}
 
@@ -106,13 +226,16 @@ func (h *HealthHandler) AddCompositeHealthData(c *gin.Context) {
 func (h *HealthHandler) GetMetricHistory(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	metricType := c.Param("type")
 	if metricType == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Metric type is required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Metric type is required")
+		return
+	}
+	if !middleware.CheckMetricAccess(c, metricType) {
 		return
 	}
 
@@ -131,7 +254,7 @@ func (h *HealthHandler) GetMetricHistory(c *gin.Context) {
 	if startTimeStr != "" {
 		startTime, err = time.Parse(time.RFC3339, startTimeStr)
 		if err != nil {
-			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid start_time format. Use RFC3339 format")
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid start_time format. Use RFC3339 format")
 			return
 		}
 	}
@@ -140,7 +263,7 @@ func (h *HealthHandler) GetMetricHistory(c *gin.Context) {
 	if endTimeStr != "" {
 		endTime, err = time.Parse(time.RFC3339, endTimeStr)
 		if err != nil {
-			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid end_time format. Use RFC3339 format")
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid end_time format. Use RFC3339 format")
 			return
 		}
 	} else {
@@ -151,46 +274,107 @@ func (h *HealthHandler) GetMetricHistory(c *gin.Context) {
 	if limitStr != "" {
 		limit, err = strconv.Atoi(limitStr)
 		if err != nil || limit < 0 {
-			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid limit value")
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid limit value")
 			return
 		}
 	}
 
+	// Aggregated history replaces raw readings with per-bucket statistics
+	if aggregate := c.Query("aggregate"); aggregate != "" {
+		h.getAggregatedHistory(c, userID, metricType, startTime, endTime, aggregate)
+		return
+	}
+
+	cursor := c.Query("cursor")
+	startKey, err := services.DecodeMetricCursor(userID, metricType, cursor)
+	if err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid cursor")
+		return
+	}
+
 	// Get metric history
-	metrics, err := h.healthService.GetMetricHistory(userID, metricType, startTime, endTime, limit)
+	filter := h.healthService.ViewSourceFilter(parseSourceFilter(c))
+	metrics, lastKey, err := h.healthService.GetMetricHistoryFromSources(userID, metricType, startTime, endTime, limit, startKey, filter)
 	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedMetric) {
+			h.validationErrorResponse(c, err)
+			return
+		}
 		h.logger.Error("Failed to get metric history",
 			zap.String("user_id", userID),
 			zap.String("metric_type", metricType),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve metric history")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve metric history")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Metric history retrieved successfully", gin.H{
+	nextCursor := services.EncodeMetricCursor(lastKey)
+	utils.PaginatedSuccessResponse(c, http.StatusOK, "Metric history retrieved successfully", gin.H{
 		"metric_type": metricType,
 		"count":       len(metrics),
 		"metrics":     metrics,
+		"next_cursor": nextCursor,
+	}, utils.Pagination{
+		TotalCount:  len(metrics),
+		HasNext:     nextCursor != "",
+		HasPrevious: cursor != "",
+		NextCursor:  nextCursor,
 	})
 }
 
+// getAggregatedHistory responds with the metric history grouped into buckets in the requested timezone
+func (h *HealthHandler) getAggregatedHistory(c *gin.Context, userID, metricType string, startTime, endTime time.Time, aggregate string) {
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid tz parameter. Use an IANA timezone name such as America/New_York")
+		return
+	}
+
+	history, err := h.healthService.GetAggregatedHistory(userID, metricType, startTime, endTime.In(loc), aggregate)
+	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedMetric) {
+			h.validationErrorResponse(c, err)
+			return
+		}
+		if errors.Is(err, services.ErrUnsupportedAggregation) {
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid aggregate parameter. Use day, week or month")
+			return
+		}
+		h.logger.Error("Failed to get aggregated metric history",
+			zap.String("user_id", userID),
+			zap.String("metric_type", metricType),
+			zap.String("aggregate", aggregate),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve metric history")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Aggregated metric history retrieved successfully", history)
+}
+
 // GetLatestMetrics handles GET /api/health/latest
 func (h *HealthHandler) GetLatestMetrics(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	// Get latest metrics
-	latestMetrics, err := h.healthService.GetLatestMetrics(userID)
+	filter := h.healthService.ViewSourceFilter(parseSourceFilter(c))
+	latestMetrics, err := h.healthService.GetLatestMetricsFromSources(userID, filter)
 	if err != nil {
 		h.logger.Error("Failed to get latest metrics",
 			zap.String("user_id", userID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve latest metrics")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve latest metrics")
 		return
 	}
+	for metricType := range latestMetrics {
+		if !middleware.CanAccessMetric(c, metricType) {
+			delete(latestMetrics, metricType)
+		}
+	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Latest metrics retrieved successfully", gin.H{
 		"metrics": latestMetrics,
@@ -198,11 +382,146 @@ func (h *HealthHandler) GetLatestMetrics(c *gin.Context) {
 	})
 }
 
+// ExportMetrics handles GET /api/health/export
+func (h *HealthHandler) ExportMetrics(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "json" {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid format parameter (csv or json)")
+		return
+	}
+
+	filename := fmt.Sprintf("health-metrics-%s.%s", time.Now().UTC().Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var writePage func(page []models.HealthMetric) error
+	var finish func() error
+	count := 0
+
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writer := csv.NewWriter(c.Writer)
+		headerWritten := false
+
+		writePage = func(page []models.HealthMetric) error {
+			if !headerWritten {
+				// Same columns the CSV import accepts, so an export can be re-imported
+				if err := writer.Write([]string{"type", "value", "unit", "timestamp", "notes", "source"}); err != nil {
+					return err
+				}
+				headerWritten = true
+			}
+			for _, metric := range page {
+				if err := writer.Write([]string{
+					metric.Type,
+					strconv.FormatFloat(metric.Value, 'f', -1, 64),
+					metric.Unit,
+					metric.Timestamp.UTC().Format(time.RFC3339),
+					metric.Notes,
+					metric.Source,
+				}); err != nil {
+					return err
+				}
+			}
+			count += len(page)
+			writer.Flush()
+			c.Writer.Flush()
+			return writer.Error()
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+
+	case "json":
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		started := false
+
+		writePage = func(page []models.HealthMetric) error {
+			for _, metric := range page {
+				data, err := json.Marshal(metric)
+				if err != nil {
+					return err
+				}
+				prefix := ","
+				if !started {
+					prefix = "["
+					started = true
+				}
+				if _, err := c.Writer.WriteString(prefix); err != nil {
+					return err
+				}
+				if _, err := c.Writer.Write(data); err != nil {
+					return err
+				}
+			}
+			count += len(page)
+			c.Writer.Flush()
+			return nil
+		}
+		finish = func() error {
+			closing := "]"
+			if !started {
+				closing = "[]"
+			}
+			_, err := c.Writer.WriteString(closing)
+			return err
+		}
+	}
+
+	// A scoped principal only exports the metric types it may read
+	export := writePage
+	if _, restricted := middleware.AllowedMetricTypes(c); restricted {
+		export = func(page []models.HealthMetric) error {
+			allowed := make([]models.HealthMetric, 0, len(page))
+			for _, metric := range page {
+				if middleware.CanAccessMetric(c, metric.Type) {
+					allowed = append(allowed, metric)
+				}
+			}
+			return writePage(allowed)
+		}
+	}
+
+	if err := h.healthService.ExportMetrics(userID, export); err != nil {
+		h.logger.Error("Failed to export health metrics",
+			zap.String("user_id", userID),
+			zap.String("format", format),
+			zap.Int("exported", count),
+			zap.Error(err))
+		// Once the body has started streaming the status can't be changed; the client sees a truncated file
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			c.Writer.Header().Del("Content-Type")
+			utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to export health data")
+		}
+		return
+	}
+
+	if err := finish(); err != nil {
+		h.logger.Error("Failed to finish health metrics export",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Health metrics exported",
+		zap.String("user_id", userID),
+		zap.String("format", format),
+		zap.Int("count", count))
+}
+
 // GetHealthSummary handles GET /api/health/summary
 func (h *HealthHandler) GetHealthSummary(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -212,7 +531,7 @@ func (h *HealthHandler) GetHealthSummary(c *gin.Context) {
 		h.logger.Error("Failed to get health summary",
 			zap.String("user_id", userID),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve health summary")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve health summary")
 		return
 	}
 
@@ -223,45 +542,59 @@ func (h *HealthHandler) GetHealthSummary(c *gin.Context) {
 func (h *HealthHandler) GetHealthTrends(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	// Parse query parameters
 	period := c.DefaultQuery("period", "month")
-	metricTypesParam := c.Query("metric_types")
 
-	var metricTypes []string
-	if metricTypesParam != "" {
-		// Parse comma-separated metric types
-		// In a real implementation, you might want to use a more robust parsing method
-		metricTypes = []string{metricTypesParam} // Simplified for now
-	} else {
-		// Default to common metrics
-		metricTypes = []string{
+	// Unknown metric types are skipped rather than failing the whole request
+	metricTypes, ignored, err := parseMetricTypes(c, h.healthService, userID)
+	if err != nil {
+		h.logger.Error("Failed to resolve metric types",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve health trends")
+		return
+	}
+	if len(metricTypes) == 0 && len(ignored) == 0 {
+		// Default to common metrics, those a scoped principal may read
+		for _, metricType := range []string{
 			"blood_pressure_systolic",
 			"blood_pressure_diastolic",
 			"heart_rate",
 			"weight",
 			"blood_glucose",
+		} {
+			if middleware.CanAccessMetric(c, metricType) {
+				metricTypes = append(metricTypes, metricType)
+			}
 		}
+	} else if !middleware.CheckMetricAccess(c, metricTypes...) {
+		return
 	}
 
 	// Get health trends
 	trends, err := h.healthService.GetHealthTrends(userID, metricTypes, period)
 	if err != nil {
+		if errors.Is(err, services.ErrTrendsTooComplex) {
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, err.Error())
+			return
+		}
 		h.logger.Error("Failed to get health trends",
 			zap.String("user_id", userID),
 			zap.String("period", period),
 			zap.Error(err))
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve health trends")
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve health trends")
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Health trends retrieved successfully", gin.H{
-		"period": period,
-		"trends": trends,
-		"count":  len(trends),
+		"period":               period,
+		"trends":               trends,
+		"count":                len(trends),
+		"ignored_metric_types": ignored,
 	})
 }
 
@@ -277,7 +610,7 @@ func (h *HealthHandler) GetSupportedMetrics(c *gin.Context) {
 func (h *HealthHandler) DeleteHealthData(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == "" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
@@ -285,33 +618,38 @@ func (h *HealthHandler) DeleteHealthData(c *gin.Context) {
 	timestampStr := c.Param("timestamp")
 
 	if metricType == "" || timestampStr == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Metric type and timestamp are required")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Metric type and timestamp are required")
 		return
 	}
 
 	// Parse timestamp
 	_, err := time.Parse(time.RFC3339, timestampStr)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid timestamp format. Use RFC3339 format")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid timestamp format. Use RFC3339 format")
 		return
 	}
 
 	// TODO: Implement delete functionality in health service
 	// For now, return not implemented
-	utils.ErrorResponse(c, http.StatusNotImplemented, "Delete functionality not yet implemented")
+	utils.ErrorResponseWithCode(c, http.StatusNotImplemented, utils.CodeNotImplemented, "Delete functionality not yet implemented")
 }
 
 // ValidateHealthInput handles POST /api/health/validate
 func (h *HealthHandler) ValidateHealthInput(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
 	var input models.HealthMetricInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid input format")
+		return
+	}
+	if !middleware.CheckMetricAccess(c, input.Type) {
 		return
 	}
 
 	// Validate input
-	if err := h.healthService.ValidateHealthData(&input); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := h.healthService.ValidateHealthData(userID, &input); err != nil {
+		h.validationErrorResponse(c, err)
 		return
 	}
 
@@ -322,3 +660,334 @@ func (h *HealthHandler) ValidateHealthInput(c *gin.Context) {
 		"unit":        input.Unit,
 	})
 }
+
+// RegisterCustomMetric handles POST /api/health/metrics/custom
+func (h *HealthHandler) RegisterCustomMetric(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	var input models.CustomMetricInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("Failed to bind custom metric input", zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid input format")
+		return
+	}
+
+	metricInfo, err := h.healthService.RegisterCustomMetric(userID, &input)
+	if err != nil {
+		if errors.Is(err, services.ErrMetricTypeConflict) {
+			utils.ErrorResponseWithCode(c, http.StatusConflict, utils.CodeConflict, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrInvalidCustomMetric) {
+			utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, err.Error())
+			return
+		}
+		h.logger.Error("Failed to register custom metric",
+			zap.String("user_id", userID),
+			zap.String("metric_type", input.Type),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to save custom metric")
+		return
+	}
+
+	h.logger.Info("Custom metric registered",
+		zap.String("user_id", userID),
+		zap.String("metric_type", input.Type))
+
+	utils.SuccessResponse(c, http.StatusCreated, "Custom metric registered successfully", gin.H{
+		"type":   strings.TrimSpace(input.Type),
+		"metric": metricInfo,
+	})
+}
+
+// CreateGoal handles POST /api/health/goals
+func (h *HealthHandler) CreateGoal(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	var input models.HealthGoalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("Failed to bind health goal input", zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid input format")
+		return
+	}
+	if !middleware.CheckMetricAccess(c, input.MetricType) {
+		return
+	}
+
+	goal, err := h.healthService.CreateGoal(userID, &input)
+	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedMetric) || errors.Is(err, services.ErrInvalidGoal) {
+			h.validationErrorResponse(c, err)
+			return
+		}
+		h.logger.Error("Failed to create health goal",
+			zap.String("user_id", userID),
+			zap.String("metric_type", input.MetricType),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to save goal")
+		return
+	}
+
+	h.logger.Info("Health goal created",
+		zap.String("user_id", userID),
+		zap.String("goal_id", goal.GoalID),
+		zap.String("metric_type", goal.MetricType))
+
+	utils.SuccessResponse(c, http.StatusCreated, "Goal saved successfully", goal)
+}
+
+// GetGoals handles GET /api/health/goals
+func (h *HealthHandler) GetGoals(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	progress, err := h.healthService.EvaluateGoals(userID)
+	if err != nil {
+		h.logger.Error("Failed to evaluate health goals",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve goals")
+		return
+	}
+	// Goals carry the latest reading, so a scoped principal only sees those of its metric types
+	if _, restricted := middleware.AllowedMetricTypes(c); restricted {
+		allowed := progress[:0]
+		for _, goal := range progress {
+			if middleware.CanAccessMetric(c, goal.Goal.MetricType) {
+				allowed = append(allowed, goal)
+			}
+		}
+		progress = allowed
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Goals retrieved successfully", gin.H{
+		"goals": progress,
+		"count": len(progress),
+	})
+}
+
+// DeleteGoal handles DELETE /api/health/goals/:id
+func (h *HealthHandler) DeleteGoal(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	goalID := c.Param("id")
+	if err := h.healthService.DeleteGoal(userID, goalID); err != nil {
+		if errors.Is(err, services.ErrGoalNotFound) {
+			utils.ErrorResponseWithCode(c, http.StatusNotFound, utils.CodeNotFound, "Goal not found")
+			return
+		}
+		h.logger.Error("Failed to delete health goal",
+			zap.String("user_id", userID),
+			zap.String("goal_id", goalID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to delete goal")
+		return
+	}
+
+	h.logger.Info("Health goal deleted",
+		zap.String("user_id", userID),
+		zap.String("goal_id", goalID))
+
+	utils.SuccessResponse(c, http.StatusOK, "Goal deleted successfully", gin.H{"goal_id": goalID})
+}
+
+// GetCustomRanges handles GET /api/health/profile/ranges
+func (h *HealthHandler) GetCustomRanges(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	customRanges, err := h.healthService.GetCustomRanges(userID)
+	if err != nil {
+		h.logger.Error("Failed to get custom ranges",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve custom ranges")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Custom ranges retrieved successfully", gin.H{
+		"custom_ranges": customRanges,
+		"count":         len(customRanges),
+	})
+}
+
+// GetRangeBands handles GET /api/health/ranges?metrics=heart_rate,weight
+func (h *HealthHandler) GetRangeBands(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	var metricTypes []string
+	if param := c.Query("metrics"); param != "" {
+		for _, metricType := range strings.Split(param, ",") {
+			if metricType = strings.TrimSpace(metricType); metricType != "" {
+				metricTypes = append(metricTypes, metricType)
+			}
+		}
+	}
+
+	bands, err := h.healthService.GetRangeBands(userID, metricTypes)
+	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedMetric) {
+			h.validationErrorResponse(c, err)
+			return
+		}
+		h.logger.Error("Failed to get range bands",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to retrieve normal ranges")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Normal ranges retrieved successfully", gin.H{
+		"ranges": bands,
+		"count":  len(bands),
+	})
+}
+
+// SetCustomRange handles PUT /api/health/profile/ranges/:type
+func (h *HealthHandler) SetCustomRange(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	metricType := c.Param("type")
+
+	var input struct {
+		Min *float64 `json:"min" binding:"required"`
+		Max *float64 `json:"max" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, "Invalid input format. Expected {\"min\": number, \"max\": number}")
+		return
+	}
+
+	profile, err := h.healthService.SetCustomRange(userID, metricType, models.Range{Min: *input.Min, Max: *input.Max})
+	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedMetric) || errors.Is(err, services.ErrInvalidRange) {
+			h.validationErrorResponse(c, err)
+			return
+		}
+		h.logger.Error("Failed to set custom range",
+			zap.String("user_id", userID),
+			zap.String("metric_type", metricType),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to save custom range")
+		return
+	}
+
+	h.logger.Info("Custom range saved",
+		zap.String("user_id", userID),
+		zap.String("metric_type", metricType))
+
+	utils.SuccessResponse(c, http.StatusOK, "Custom range saved successfully", profile)
+}
+
+// DeleteCustomRange handles DELETE /api/health/profile/ranges/:type
+func (h *HealthHandler) DeleteCustomRange(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	metricType := c.Param("type")
+
+	profile, err := h.healthService.DeleteCustomRange(userID, metricType)
+	if err != nil {
+		h.logger.Error("Failed to delete custom range",
+			zap.String("user_id", userID),
+			zap.String("metric_type", metricType),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to delete custom range")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Custom range removed successfully", profile)
+}
+
+// GetCardioRisk handles GET /api/health/cardio-risk
+func (h *HealthHandler) GetCardioRisk(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "User not authenticated")
+		return
+	}
+
+	risk, err := h.healthService.GetCardioRisk(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrInsufficientData) {
+			utils.ErrorResponseWithCodeDetails(c, http.StatusUnprocessableEntity, utils.CodeValidationError, err.Error(), gin.H{
+				"inputs":  risk.Inputs,
+				"missing": risk.Missing,
+			})
+			return
+		}
+		h.logger.Error("Failed to compute cardiovascular risk",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to compute cardiovascular risk")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Cardiovascular risk flag computed successfully", risk)
+}
+
+// parseSourceFilter reads the comma-separated ?source_filter= query parameter
+func parseSourceFilter(c *gin.Context) []string {
+	var sources []string
+	for _, source := range strings.Split(c.Query("source_filter"), ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// parseMetricTypes reads the comma-separated ?metric_types= query parameter, keeping built-in
+// metric types and the user's custom ones and returning the rest as ignored
+func parseMetricTypes(c *gin.Context, healthService *services.HealthService, userID string) (metricTypes, ignored []string, err error) {
+	var requested []string
+	for _, metricType := range strings.Split(c.Query("metric_types"), ",") {
+		if metricType = strings.TrimSpace(metricType); metricType != "" {
+			requested = append(requested, metricType)
+		}
+	}
+	return healthService.ResolveMetricTypes(userID, requested)
+}
+
+// validationErrorResponse sends a 400 for invalid health input. Unsupported metric types
+// additionally include the list of supported types so clients can correct the request.
+func (h *HealthHandler) validationErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrUnsupportedMetric) {
+		utils.ErrorResponseWithCodeDetails(c, http.StatusBadRequest, utils.CodeValidationError, err.Error(), gin.H{
+			"error":             err.Error(),
+			"supported_metrics": models.SupportedMetricTypes(),
+		})
+		return
+	}
+
+	utils.ErrorResponseWithCode(c, http.StatusBadRequest, utils.CodeValidationError, err.Error())
+}
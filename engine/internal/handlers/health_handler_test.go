@@ -0,0 +1,526 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/middleware"
+	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/internal/services"
+)
+
+// healthFixture is a HealthHandler backed by an in-memory DynamoDB, routed as in main
+type healthFixture struct {
+	cfg     *config.Config
+	db      *database.DynamoDBClient
+	fake    *dynamotest.Fake
+	service *services.HealthService
+	handler *HealthHandler
+	router  http.Handler
+}
+
+func newHealthFixture(t *testing.T) *healthFixture {
+	t.Helper()
+
+	f := &healthFixture{cfg: testConfig(t)}
+	f.db, f.fake = dynamotest.NewClient(f.cfg)
+	f.service = services.NewHealthService(f.db, f.cfg)
+	f.handler = NewHealthHandler(f.service, zap.NewNop())
+
+	router := newTestRouter("user-1")
+	health := router.Group("/api/health")
+	health.POST("/metrics", f.handler.AddHealthData)
+	health.POST("/metrics/import", f.handler.ImportMetricsCSV)
+	health.POST("/metrics/custom", f.handler.RegisterCustomMetric)
+	health.GET("/metrics/:type", f.handler.GetMetricHistory)
+	health.GET("/trends", f.handler.GetHealthTrends)
+	health.POST("/validate", f.handler.ValidateHealthInput)
+	health.GET("/export", f.handler.ExportMetrics)
+	health.POST("/goals", f.handler.CreateGoal)
+	health.GET("/goals", f.handler.GetGoals)
+	health.DELETE("/goals/:id", f.handler.DeleteGoal)
+	health.GET("/ranges", f.handler.GetRangeBands)
+	health.GET("/cardio-risk", f.handler.GetCardioRisk)
+	health.GET("/profile/ranges", f.handler.GetCustomRanges)
+	health.PUT("/profile/ranges/:type", f.handler.SetCustomRange)
+	health.DELETE("/profile/ranges/:type", f.handler.DeleteCustomRange)
+	f.router = router
+	return f
+}
+
+// putMetric stores a reading for user-1
+func (f *healthFixture) putMetric(t *testing.T, metricType string, value float64, unit string, at time.Time) {
+	t.Helper()
+
+	metric := &models.HealthMetric{UserID: "user-1", Type: metricType, Value: value, Unit: unit, Timestamp: at}
+	if err := f.db.PutHealthMetric(metric); err != nil {
+		t.Fatalf("put metric: %v", err)
+	}
+}
+
+func TestUnsupportedMetricIsBadRequest(t *testing.T) {
+	f := newHealthFixture(t)
+
+	bogus := map[string]interface{}{"type": "bogus_metric", "value": 1, "unit": "x"}
+	for _, tc := range []struct {
+		name, method, path string
+		body               interface{}
+	}{
+		{"history", http.MethodGet, "/api/health/metrics/bogus_metric", nil},
+		{"aggregated history", http.MethodGet, "/api/health/metrics/bogus_metric?aggregate=day", nil},
+		{"add", http.MethodPost, "/api/health/metrics", bogus},
+		{"validate", http.MethodPost, "/api/health/validate", bogus},
+	} {
+		recorder, response := serve(t, f.router, tc.method, tc.path, tc.body)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400 (%s)", tc.name, recorder.Code, recorder.Body.String())
+			continue
+		}
+		if response.Error.Code != "VALIDATION_ERROR" {
+			t.Errorf("%s: error code %q, want VALIDATION_ERROR", tc.name, response.Error.Code)
+		}
+
+		var details struct {
+			SupportedMetrics []string `json:"supported_metrics"`
+		}
+		if err := json.Unmarshal(response.Error.Details, &details); err != nil || len(details.SupportedMetrics) == 0 {
+			t.Errorf("%s: details %s, want the supported metric types", tc.name, response.Error.Details)
+		}
+	}
+}
+
+func TestSupportedMetricHistoryIsOK(t *testing.T) {
+	f := newHealthFixture(t)
+
+	recorder, _ := serve(t, f.router, http.MethodGet, "/api/health/metrics/heart_rate", nil)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status %d, want 200 (%s)", recorder.Code, recorder.Body.String())
+	}
+}
+
+const mixedMetricsCSV = `type,value,unit,timestamp,notes,source
+blood_glucose,110,mg/dL,2024-03-01 08:00,after breakfast,meter
+blood_glucose,110,mg/L,2024-03-01 09:00,,meter
+blood_glucose,900,mg/dL,2024-03-01 10:00,,meter
+heart_rate,72,bpm,2024-03-01 11:00,,watch
+heart_rate,fast,bpm,2024-03-01 12:00,,watch
+`
+
+func TestImportMetricsCSVReportsEachRow(t *testing.T) {
+	f := newHealthFixture(t)
+
+	recorder, response := serve(t, f.router, http.MethodPost, "/api/health/metrics/import",
+		formFile{field: "file", filename: "readings.csv", content: mixedMetricsCSV})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200 (%s)", recorder.Code, recorder.Body.String())
+	}
+
+	var report models.MetricImportReport
+	decodeData(t, response, &report)
+	if report.TotalRows != 5 || report.Imported != 2 || report.Failed != 3 {
+		t.Errorf("report = %d rows, %d imported, %d failed; want 5, 2, 3", report.TotalRows, report.Imported, report.Failed)
+	}
+
+	// Rows are numbered by file line, so the header is line 1
+	want := map[int]string{2: "", 3: "invalid unit", 4: "range", 5: "", 6: "value"}
+	for _, result := range report.Results {
+		reason, ok := want[result.Row]
+		if !ok {
+			t.Errorf("unexpected row %d", result.Row)
+			continue
+		}
+		if result.Success != (reason == "") || !strings.Contains(result.Error, reason) {
+			t.Errorf("row %d = success %v, error %q; want error containing %q", result.Row, result.Success, result.Error, reason)
+		}
+	}
+
+	if stored := len(f.fake.Items(f.cfg.DynamoDBTableHealth)); stored != 2 {
+		t.Errorf("stored %d metrics, want the 2 valid rows", stored)
+	}
+}
+
+func TestImportMetricsCSVReportsStorageFailure(t *testing.T) {
+	f := newHealthFixture(t)
+	f.fake.Hook = func(op string, input interface{}) error {
+		if op == "BatchWriteItem" {
+			return errors.New("throughput exceeded")
+		}
+		return nil
+	}
+
+	_, response := serve(t, f.router, http.MethodPost, "/api/health/metrics/import",
+		formFile{field: "file", filename: "readings.csv", content: mixedMetricsCSV})
+
+	var report models.MetricImportReport
+	decodeData(t, response, &report)
+	for _, result := range report.Results {
+		if (result.Row == 2 || result.Row == 5) && !strings.Contains(result.Error, "throughput exceeded") {
+			t.Errorf("row %d error = %q, want the storage failure reason", result.Row, result.Error)
+		}
+	}
+}
+
+func TestExportMetricsReadsEveryPage(t *testing.T) {
+	f := newHealthFixture(t)
+	f.fake.MaxPageItems = 3
+
+	base := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 8; i++ {
+		f.putMetric(t, "heart_rate", float64(60+i), "bpm", base.Add(time.Duration(i)*time.Hour))
+	}
+	f.db.PutHealthMetric(&models.HealthMetric{UserID: "user-2", Type: "heart_rate", Value: 99, Unit: "bpm", Timestamp: base})
+
+	recorder, _ := serve(t, f.router, http.MethodGet, "/api/health/export?format=csv", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200 (%s)", recorder.Code, recorder.Body.String())
+	}
+	if disposition := recorder.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment;") {
+		t.Errorf("Content-Disposition = %q, want an attachment", disposition)
+	}
+	if queries := f.fake.Calls("Query"); queries < 3 {
+		t.Errorf("read %d pages, want at least 3 for 8 items at 3 per page", queries)
+	}
+
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if len(records) != 9 || strings.Join(records[0], ",") != "type,value,unit,timestamp,notes,source" {
+		t.Fatalf("got %d CSV records starting %v, want a header and 8 rows", len(records), records[0])
+	}
+
+	recorder, _ = serve(t, f.router, http.MethodGet, "/api/health/export?format=json", nil)
+	var exported []models.HealthMetric
+	if err := json.Unmarshal(recorder.Body.Bytes(), &exported); err != nil {
+		t.Fatalf("decode JSON export %s: %v", recorder.Body.String(), err)
+	}
+	if len(exported) != 8 {
+		t.Errorf("exported %d metrics as JSON, want 8", len(exported))
+	}
+	for _, metric := range exported {
+		if metric.UserID != "user-1" {
+			t.Errorf("exported another user's metric: %+v", metric)
+		}
+	}
+}
+
+func TestGoalEndpoints(t *testing.T) {
+	f := newHealthFixture(t)
+	f.putMetric(t, "blood_pressure_systolic", 125, "mmHg", time.Now().Add(-time.Hour))
+
+	recorder, _ := serve(t, f.router, http.MethodPost, "/api/health/goals",
+		map[string]interface{}{"metric_type": "blood_pressure_systolic", "goal_type": "sideways", "target_value": 120})
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("invalid goal type: status %d, want 400", recorder.Code)
+	}
+
+	recorder, response := serve(t, f.router, http.MethodPost, "/api/health/goals",
+		map[string]interface{}{"metric_type": "blood_pressure_systolic", "goal_type": "below", "target_value": 120})
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("create: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var goal models.HealthGoal
+	decodeData(t, response, &goal)
+
+	_, response = serve(t, f.router, http.MethodGet, "/api/health/goals", nil)
+	var list struct {
+		Goals []models.GoalProgress `json:"goals"`
+	}
+	decodeData(t, response, &list)
+	if len(list.Goals) != 1 || list.Goals[0].Goal.GoalID != goal.GoalID || list.Goals[0].Status != models.GoalStatusOffTrack {
+		t.Errorf("goals = %+v, want the new goal off track", list.Goals)
+	}
+
+	if recorder, _ := serve(t, f.router, http.MethodDelete, "/api/health/goals/"+goal.GoalID, nil); recorder.Code != http.StatusOK {
+		t.Errorf("delete: status %d", recorder.Code)
+	}
+	if recorder, _ := serve(t, f.router, http.MethodDelete, "/api/health/goals/"+goal.GoalID, nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("second delete: status %d, want 404", recorder.Code)
+	}
+}
+
+func TestCustomRangeEndpoints(t *testing.T) {
+	f := newHealthFixture(t)
+
+	recorder, _ := serve(t, f.router, http.MethodPut, "/api/health/profile/ranges/heart_rate", map[string]interface{}{"min": 90, "max": 45})
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("inverted range: status %d, want 400", recorder.Code)
+	}
+	recorder, _ = serve(t, f.router, http.MethodPut, "/api/health/profile/ranges/bogus_metric", map[string]interface{}{"min": 1, "max": 2})
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("unsupported metric: status %d, want 400", recorder.Code)
+	}
+
+	recorder, _ = serve(t, f.router, http.MethodPut, "/api/health/profile/ranges/heart_rate", map[string]interface{}{"min": 45, "max": 90})
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("set: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+
+	ranges := func() map[string]models.Range {
+		t.Helper()
+		_, response := serve(t, f.router, http.MethodGet, "/api/health/profile/ranges", nil)
+		var body struct {
+			Ranges map[string]models.Range `json:"custom_ranges"`
+		}
+		decodeData(t, response, &body)
+		return body.Ranges
+	}
+	if got := ranges(); got["heart_rate"].Min != 45 || got["heart_rate"].Max != 90 {
+		t.Errorf("ranges = %+v, want heart_rate 45-90", got)
+	}
+
+	if recorder, _ := serve(t, f.router, http.MethodDelete, "/api/health/profile/ranges/heart_rate", nil); recorder.Code != http.StatusOK {
+		t.Errorf("delete: status %d", recorder.Code)
+	}
+	if got := ranges(); len(got) != 0 {
+		t.Errorf("ranges after delete = %+v, want none", got)
+	}
+}
+
+func TestRangeBands(t *testing.T) {
+	f := newHealthFixture(t)
+	if _, err := f.service.SetCustomRange("user-1", "blood_pressure_systolic", models.Range{Min: 95, Max: 125}); err != nil {
+		t.Fatalf("set custom range: %v", err)
+	}
+
+	recorder, response := serve(t, f.router, http.MethodGet, "/api/health/ranges?metrics=heart_rate,blood_pressure,steps", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var body struct {
+		Ranges []models.RangeBand `json:"ranges"`
+	}
+	decodeData(t, response, &body)
+	if len(body.Ranges) != 3 {
+		t.Fatalf("got %d bands, want 3", len(body.Ranges))
+	}
+
+	heartRate, bloodPressure, steps := body.Ranges[0], body.Ranges[1], body.Ranges[2]
+	if heartRate.Unit != "bpm" || heartRate.Range == nil || heartRate.Range.Min != 60 || heartRate.Custom {
+		t.Errorf("heart_rate = %+v, want the default 60-100 bpm", heartRate)
+	}
+	if bloodPressure.Range != nil || len(bloodPressure.Components) != 2 {
+		t.Fatalf("blood_pressure = %+v, want no range of its own and two components", bloodPressure)
+	}
+	if systolic := bloodPressure.Components[0]; !systolic.Custom || systolic.Range.Min != 95 || systolic.Range.Max != 125 {
+		t.Errorf("systolic = %+v, want the custom 95-125", systolic)
+	}
+	if steps.Range != nil {
+		t.Errorf("steps = %+v, want a null range", steps)
+	}
+
+	if recorder, _ := serve(t, f.router, http.MethodGet, "/api/health/ranges?metrics=bogus_metric", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("unsupported metric: status %d, want 400", recorder.Code)
+	}
+}
+
+func TestCardioRisk(t *testing.T) {
+	f := newHealthFixture(t)
+	recent := time.Now().UTC().Add(-time.Hour)
+	f.putMetric(t, "heart_rate", 64, "bpm", recent)
+	f.putMetric(t, "bmi", 23, "kg/m²", recent)
+
+	recorder, _ := serve(t, f.router, http.MethodGet, "/api/health/cardio-risk", nil)
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("two inputs: status %d, want 422", recorder.Code)
+	}
+
+	f.putMetric(t, "cholesterol_hdl", 35, "mg/dL", recent)
+	recorder, response := serve(t, f.router, http.MethodGet, "/api/health/cardio-risk", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var risk models.CardioRisk
+	decodeData(t, response, &risk)
+	if risk.Flag != models.CardioRiskModerate || risk.Points != 2 || risk.MaxPoints != 6 {
+		t.Errorf("got %s with %d of %d points, want moderate with 2 of 6", risk.Flag, risk.Points, risk.MaxPoints)
+	}
+}
+
+func TestTrendsTooComplexIsBadRequest(t *testing.T) {
+	f := newHealthFixture(t)
+	f.cfg.TrendsMaxMetricTypes = 2
+	f.cfg.TrendsMaxCostDays = 100
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"metric_types=heart_rate,weight&period=month", http.StatusOK},
+		{"metric_types=heart_rate,weight,steps&period=week", http.StatusBadRequest},
+		{"metric_types=heart_rate&period=year", http.StatusBadRequest},
+	} {
+		if recorder, _ := serve(t, f.router, http.MethodGet, "/api/health/trends?"+tc.query, nil); recorder.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.query, recorder.Code, tc.want)
+		}
+	}
+}
+
+func TestCustomMetricCanBeSubmittedAndReadBack(t *testing.T) {
+	f := newHealthFixture(t)
+
+	peakFlow := map[string]interface{}{
+		"type": "peak_flow", "name": "Peak flow", "unit": "L/min",
+		"normal_range": map[string]float64{"min": 400, "max": 700},
+	}
+	if recorder, _ := serve(t, f.router, http.MethodPost, "/api/health/metrics/custom", peakFlow); recorder.Code != http.StatusCreated {
+		t.Fatalf("register: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+
+	for _, value := range []float64{520, 350} {
+		reading := map[string]interface{}{"type": "peak_flow", "value": value, "unit": "L/min"}
+		if recorder, _ := serve(t, f.router, http.MethodPost, "/api/health/metrics", reading); recorder.Code != http.StatusCreated {
+			t.Fatalf("submit %v: status %d (%s)", value, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	if recorder, _ := serve(t, f.router, http.MethodGet, "/api/health/metrics/peak_flow", nil); recorder.Code != http.StatusOK {
+		t.Errorf("history: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+
+	recorder, response := serve(t, f.router, http.MethodGet, "/api/health/trends?metric_types=peak_flow&period=week", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("trends: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var trends struct {
+		Trends  []models.HealthTrend `json:"trends"`
+		Ignored []string             `json:"ignored_metric_types"`
+	}
+	decodeData(t, response, &trends)
+	if len(trends.Trends) != 1 || len(trends.Ignored) != 0 {
+		t.Fatalf("trends = %+v, ignored %v; want the peak_flow trend", trends.Trends, trends.Ignored)
+	}
+	if trend := trends.Trends[0]; trend.Unit != "L/min" || trend.AnomalyCount != 1 {
+		t.Errorf("trend = %+v, want L/min with the reading below the registered range flagged", trend)
+	}
+
+	recorder, response = serve(t, f.router, http.MethodGet, "/api/health/ranges?metrics=peak_flow", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("ranges: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var bands struct {
+		Ranges []models.RangeBand `json:"ranges"`
+	}
+	decodeData(t, response, &bands)
+	if len(bands.Ranges) != 1 || bands.Ranges[0].Range == nil || bands.Ranges[0].Range.Min != 400 {
+		t.Errorf("ranges = %+v, want the registered 400-700 range", bands.Ranges)
+	}
+}
+
+func TestCustomMetricCannotRedefineBuiltIn(t *testing.T) {
+	f := newHealthFixture(t)
+
+	heartRate := map[string]interface{}{"type": "heart_rate", "name": "My heart rate", "unit": "beats"}
+	recorder, response := serve(t, f.router, http.MethodPost, "/api/health/metrics/custom", heartRate)
+	if recorder.Code != http.StatusConflict || response.Error.Code != "CONFLICT" {
+		t.Fatalf("status %d (%s), want 409 CONFLICT", recorder.Code, recorder.Body.String())
+	}
+
+	// The built-in definition still applies
+	reading := map[string]interface{}{"type": "heart_rate", "value": 72, "unit": "beats"}
+	if recorder, _ := serve(t, f.router, http.MethodPost, "/api/health/metrics", reading); recorder.Code != http.StatusBadRequest {
+		t.Errorf("reading in the rejected unit: status %d, want 400", recorder.Code)
+	}
+}
+
+func TestTrendsParseCommaSeparatedMetricTypes(t *testing.T) {
+	f := newHealthFixture(t)
+	at := time.Now().UTC().Add(-time.Hour)
+	for _, reading := range []map[string]interface{}{
+		{"type": "weight", "value": 80, "unit": "kg", "timestamp": at},
+		{"type": "heart_rate", "value": 70, "unit": "bpm", "timestamp": at},
+	} {
+		if recorder, _ := serve(t, f.router, http.MethodPost, "/api/health/metrics", reading); recorder.Code != http.StatusCreated {
+			t.Fatalf("submit: status %d (%s)", recorder.Code, recorder.Body.String())
+		}
+	}
+
+	recorder, response := serve(t, f.router, http.MethodGet, "/api/health/trends?metric_types=weight,%20heart_rate,bogus", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	var trends struct {
+		Trends  []models.HealthTrend `json:"trends"`
+		Ignored []string             `json:"ignored_metric_types"`
+	}
+	decodeData(t, response, &trends)
+	if len(trends.Trends) != 2 || trends.Trends[0].MetricType != "weight" || trends.Trends[1].MetricType != "heart_rate" {
+		t.Errorf("trends = %+v, want weight and heart_rate", trends.Trends)
+	}
+	if len(trends.Ignored) != 1 || trends.Ignored[0] != "bogus" {
+		t.Errorf("ignored %v, want the unknown type", trends.Ignored)
+	}
+}
+
+// scopedRouter routes the fixture's handler for a principal restricted to metricTypes
+func (f *healthFixture) scopedRouter(metricTypes ...string) http.Handler {
+	router := newTestRouter("user-1")
+	router.Use(func(c *gin.Context) { middleware.SetAllowedMetricTypes(c, metricTypes) })
+	health := router.Group("/api/health")
+	health.POST("/metrics", f.handler.AddHealthData)
+	health.POST("/metrics/composite", f.handler.AddCompositeHealthData)
+	health.GET("/metrics/:type", f.handler.GetMetricHistory)
+	health.GET("/latest", f.handler.GetLatestMetrics)
+	health.GET("/trends", f.handler.GetHealthTrends)
+	health.GET("/export", f.handler.ExportMetrics)
+	return router
+}
+
+func TestScopedPrincipalOnlyAccessesAllowedMetricTypes(t *testing.T) {
+	f := newHealthFixture(t)
+	at := time.Now().UTC().Add(-time.Hour)
+	f.putMetric(t, "steps", 8000, "count", at)
+	f.putMetric(t, "blood_glucose", 95, "mg/dL", at)
+	router := f.scopedRouter("steps", "heart_rate")
+
+	forbidden := []struct {
+		name, method, path string
+		body               interface{}
+		metricType         string
+	}{
+		{"add", http.MethodPost, "/api/health/metrics", map[string]interface{}{"type": "blood_glucose", "value": 90, "unit": "mg/dL"}, "blood_glucose"},
+		{"composite", http.MethodPost, "/api/health/metrics/composite", map[string]interface{}{"type": "blood_pressure", "systolic": 120, "diastolic": 80, "unit": "mmHg"}, "blood_pressure_systolic"},
+		{"history", http.MethodGet, "/api/health/metrics/blood_glucose", nil, "blood_glucose"},
+		{"trends", http.MethodGet, "/api/health/trends?metric_types=steps,blood_glucose", nil, "blood_glucose"},
+	}
+	for _, tc := range forbidden {
+		recorder, response := serve(t, router, tc.method, tc.path, tc.body)
+		if recorder.Code != http.StatusForbidden || !strings.Contains(response.Error.Message, tc.metricType) {
+			t.Errorf("%s: status %d (%s), want 403 naming %s", tc.name, recorder.Code, response.Error.Message, tc.metricType)
+		}
+	}
+
+	reading := map[string]interface{}{"type": "heart_rate", "value": 64, "unit": "bpm"}
+	if recorder, _ := serve(t, router, http.MethodPost, "/api/health/metrics", reading); recorder.Code != http.StatusCreated {
+		t.Errorf("add allowed type: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+	if recorder, _ := serve(t, router, http.MethodGet, "/api/health/metrics/steps", nil); recorder.Code != http.StatusOK {
+		t.Errorf("history of allowed type: status %d (%s)", recorder.Code, recorder.Body.String())
+	}
+
+	// Endpoints spanning every metric only return the allowed ones
+	recorder, response := serve(t, router, http.MethodGet, "/api/health/latest", nil)
+	var latest struct {
+		Metrics map[string]json.RawMessage `json:"metrics"`
+	}
+	decodeData(t, response, &latest)
+	if recorder.Code != http.StatusOK || len(latest.Metrics) != 2 || latest.Metrics["blood_glucose"] != nil {
+		t.Errorf("latest: status %d metrics %v, want steps and heart_rate only", recorder.Code, latest.Metrics)
+	}
+
+	recorder, _ = serve(t, router, http.MethodGet, "/api/health/export?format=csv", nil)
+	if body := recorder.Body.String(); recorder.Code != http.StatusOK || strings.Contains(body, "blood_glucose") || !strings.Contains(body, "steps") {
+		t.Errorf("export: status %d body %q, want the allowed metrics only", recorder.Code, body)
+	}
+}
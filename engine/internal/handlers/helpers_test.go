@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"health-dashboard-backend/internal/config"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testConfig returns the default configuration
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	return cfg
+}
+
+// newTestRouter returns a router whose requests are authenticated as userID
+func newTestRouter(userID string) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID != "" {
+			c.Set("user_id", userID)
+			c.Set("authenticated", true)
+		}
+		c.Next()
+	})
+	return router
+}
+
+// testResponse is the decoded body of an API response
+type testResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   struct {
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Details json.RawMessage `json:"details"`
+	} `json:"error"`
+}
+
+// formFile is a request body holding a single multipart file upload
+type formFile struct {
+	field    string
+	filename string
+	content  string
+}
+
+// serve sends a request to the router. A formFile body is sent as multipart form data, an
+// io.Reader as is, and any other non-nil body as JSON.
+func serve(t *testing.T, router http.Handler, method, path string, body interface{}) (*httptest.ResponseRecorder, testResponse) {
+	t.Helper()
+
+	var reader io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case formFile:
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		part, err := writer.CreateFormFile(b.field, b.filename)
+		if err != nil {
+			t.Fatalf("create form file: %v", err)
+		}
+		io.WriteString(part, b.content)
+		writer.Close()
+		reader, contentType = &buf, writer.FormDataContentType()
+	case io.Reader:
+		reader = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+		reader, contentType = bytes.NewReader(data), "application/json"
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	var response testResponse
+	// Only API envelopes are decoded; exports and other raw bodies are left to the caller
+	if body := recorder.Body.Bytes(); len(body) > 0 && body[0] == '{' && json.Valid(body) {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decode response %s: %v", recorder.Body.String(), err)
+		}
+	}
+	return recorder, response
+}
+
+// decodeData unmarshals the data field of a response
+func decodeData(t *testing.T, response testResponse, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(response.Data, v); err != nil {
+		t.Fatalf("decode data %s: %v", response.Data, err)
+	}
+}
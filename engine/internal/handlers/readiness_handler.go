@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/utils"
+)
+
+// ReadinessChecker reports whether each dependency is reachable, e.g. services.DiagnosticsService
+type ReadinessChecker interface {
+	CheckReadiness(ctx context.Context) (bool, map[string]bool)
+}
+
+// ReadinessHandler reports whether the service's dependencies are reachable
+type ReadinessHandler struct {
+	checker ReadinessChecker
+	logger  *zap.Logger
+}
+
+// NewReadinessHandler creates a new readiness handler
+func NewReadinessHandler(checker ReadinessChecker, logger *zap.Logger) *ReadinessHandler {
+	return &ReadinessHandler{
+		checker: checker,
+		logger:  logger,
+	}
+}
+
+// Ready handles GET /health/ready, returning 503 when any dependency is down
+func (h *ReadinessHandler) Ready(c *gin.Context) {
+	healthy, status := h.checker.CheckReadiness(c.Request.Context())
+	if !healthy {
+		h.logger.Warn("Readiness check failed", zap.Any("services", status))
+	}
+
+	utils.HealthCheckResponse(c, healthy, status)
+}
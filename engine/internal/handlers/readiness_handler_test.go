@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// stubReadiness reports fixed dependency statuses
+type stubReadiness map[string]bool
+
+func (s stubReadiness) CheckReadiness(ctx context.Context) (bool, map[string]bool) {
+	healthy := true
+	for _, ok := range s {
+		healthy = healthy && ok
+	}
+	return healthy, s
+}
+
+func TestReadyReportsEachDependency(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status stubReadiness
+		want   int
+	}{
+		{"all up", stubReadiness{"dynamodb": true, "s3": true, "pinecone": true, "llm": true}, http.StatusOK},
+		{"pinecone down", stubReadiness{"dynamodb": true, "s3": true, "pinecone": false, "llm": true}, http.StatusServiceUnavailable},
+	} {
+		handler := NewReadinessHandler(tc.status, zap.NewNop())
+		router := newTestRouter("")
+		router.GET("/health/ready", handler.Ready)
+
+		recorder, _ := serve(t, router, http.MethodGet, "/health/ready", nil)
+		if recorder.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, recorder.Code, tc.want)
+		}
+
+		var body struct {
+			Services map[string]bool `json:"services"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode: %v", tc.name, err)
+		}
+		for name, ok := range tc.status {
+			if got, exists := body.Services[name]; !exists || got != ok {
+				t.Errorf("%s: %s = %v (reported %v), want %v", tc.name, name, got, exists, ok)
+			}
+		}
+	}
+}
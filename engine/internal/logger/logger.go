@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // LogMode represents the different logging modes
@@ -15,7 +17,7 @@ type LogMode string
 
 const (
 	ModePrint LogMode = "PRINT" // Print to console
-	ModeWrite LogMode = "WRITE" // Write to logs.json file
+	ModeWrite LogMode = "WRITE" // Write to a rotated JSON log file
 	ModeNone  LogMode = "NONE"  // Skip logging
 )
 
@@ -23,7 +25,8 @@ const (
 type Logger struct {
 	zapLogger *zap.Logger
 	mode      LogMode
-	logFile   *os.File
+	logFile   *lumberjack.Logger
+	writeMu   sync.Mutex // Keeps concurrent JSON lines from interleaving
 }
 
 // LogEntry represents a structured log entry for JSON file output
@@ -34,10 +37,18 @@ type LogEntry struct {
 	Fields    map[string]interface{} `json:"fields,omitempty"`
 }
 
-// NewLogger creates a new logger with the specified mode
-func NewLogger(mode LogMode) (*Logger, error) {
-	var zapLogger *zap.Logger
-	var logFile *os.File
+// FileOptions configures the log file used in WRITE mode. The file is rotated once it reaches
+// MaxSizeMB; zero MaxBackups or MaxAgeDays keeps rotated files indefinitely by that measure.
+type FileOptions struct {
+	Path       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+}
+
+// NewLogger creates a new logger with the specified mode. fileOpts is only used in WRITE mode.
+func NewLogger(mode LogMode, fileOpts FileOptions) (*Logger, error) {
+	l := &Logger{mode: mode}
 	var err error
 
 	switch mode {
@@ -49,40 +60,41 @@ func NewLogger(mode LogMode) (*Logger, error) {
 		config.EncoderConfig.MessageKey = "msg"
 		config.EncoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout("2006-01-02 15:04:05")
 		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-		zapLogger, err = config.Build()
+		l.zapLogger, err = config.Build()
 		if err != nil {
 			return nil, fmt.Errorf("failed to create console logger: %w", err)
 		}
 
 	case ModeWrite:
-		// Create a file logger that writes to logs.json
-		logFile, err = os.OpenFile("logs.json", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open log file: %w", err)
+		// Create a file logger that writes LogEntry lines to a size-rotated file
+		path := fileOpts.Path
+		if path == "" {
+			path = "logs.json"
+		}
+		l.logFile = &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    fileOpts.MaxSizeMB,
+			MaxBackups: fileOpts.MaxBackups,
+			MaxAge:     fileOpts.MaxAgeDays,
 		}
 
-		// Create a production logger that writes to the file
-		config := zap.NewProductionConfig()
-		config.OutputPaths = []string{"logs.json"}
-		zapLogger, err = config.Build()
-		if err != nil {
-			logFile.Close()
-			return nil, fmt.Errorf("failed to create file logger: %w", err)
+		// Open the file now so a bad path fails at startup rather than on the first entry
+		if _, err = l.logFile.Write(nil); err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
 		}
 
+		// Route every zap entry, including those from GetZapLogger, through writeJSONLog
+		l.zapLogger = zap.New(&entryCore{LevelEnabler: zapcore.InfoLevel, logger: l})
+
 	case ModeNone:
 		// Create a no-op logger
-		zapLogger = zap.NewNop()
+		l.zapLogger = zap.NewNop()
 
 	default:
 		return nil, fmt.Errorf("invalid log mode: %s", mode)
 	}
 
-	return &Logger{
-		zapLogger: zapLogger,
-		mode:      mode,
-		logFile:   logFile,
-	}, nil
+	return l, nil
 }
 
 // Close closes the logger and any open files
@@ -160,13 +172,13 @@ func (l *Logger) Sync() error {
 }
 
 // writeJSONLog writes a log entry to the JSON file (for WRITE mode)
-func (l *Logger) writeJSONLog(level, message string, fields map[string]interface{}) {
+func (l *Logger) writeJSONLog(timestamp time.Time, level, message string, fields map[string]interface{}) error {
 	if l.mode != ModeWrite || l.logFile == nil {
-		return
+		return nil
 	}
 
 	entry := LogEntry{
-		Timestamp: time.Now().Format(time.RFC3339),
+		Timestamp: timestamp.Format(time.RFC3339),
 		Level:     level,
 		Message:   message,
 		Fields:    fields,
@@ -174,11 +186,61 @@ func (l *Logger) writeJSONLog(level, message string, fields map[string]interface
 
 	data, err := json.Marshal(entry)
 	if err != nil {
-		return
+		return fmt.Errorf("failed to marshal log entry: %w", err)
 	}
 
-	l.logFile.Write(data)
-	l.logFile.Write([]byte("\n"))
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+
+	_, err = l.logFile.Write(append(data, '\n'))
+	return err
+}
+
+// entryCore is a zap core that writes each entry as a LogEntry line via writeJSONLog
+type entryCore struct {
+	zapcore.LevelEnabler
+	logger *Logger
+	fields []zapcore.Field // Fields added with With
+}
+
+// With returns a core that adds fields to every entry
+func (c *entryCore) With(fields []zapcore.Field) zapcore.Core {
+	return &entryCore{
+		LevelEnabler: c.LevelEnabler,
+		logger:       c.logger,
+		fields:       append(append([]zapcore.Field(nil), c.fields...), fields...),
+	}
+}
+
+// Check adds the core to the checked entry when the level is enabled
+func (c *entryCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write encodes the entry's fields into a map and writes it as a LogEntry
+func (c *entryCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(encoder)
+	}
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	var entryFields map[string]interface{}
+	if len(encoder.Fields) > 0 {
+		entryFields = encoder.Fields
+	}
+
+	return c.logger.writeJSONLog(entry.Time, entry.Level.String(), entry.Message, entryFields)
+}
+
+// Sync is a no-op; entries are written to the log file unbuffered
+func (c *entryCore) Sync() error {
+	return nil
 }
 
 // GetMode returns the current logging mode
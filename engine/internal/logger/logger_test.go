@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// readEntries decodes every line of a WRITE-mode log file
+func readEntries(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open log file: %v", err)
+	}
+	defer file.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestWriteModeProducesJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.json")
+	l, err := NewLogger(ModeWrite, FileOptions{Path: path})
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	l.Debug("below the file level")
+	l.Info("server started", zap.Int("port", 8080))
+	l.Warn("slow query")
+	l.GetZapLogger().With(zap.String("user_id", "user-1")).Error("upload failed", zap.Error(os.ErrNotExist))
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	entries := readEntries(t, path)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3 (debug is not written)", len(entries))
+	}
+	for i, want := range []struct{ level, message string }{
+		{"info", "server started"},
+		{"warn", "slow query"},
+		{"error", "upload failed"},
+	} {
+		entry := entries[i]
+		if entry["level"] != want.level || entry["message"] != want.message {
+			t.Errorf("entry %d = %v, want %s %q", i, entry, want.level, want.message)
+		}
+		timestamp, _ := entry["timestamp"].(string)
+		if _, err := time.Parse(time.RFC3339, timestamp); err != nil {
+			t.Errorf("entry %d timestamp %q is not RFC 3339", i, timestamp)
+		}
+	}
+
+	if fields, _ := entries[0]["fields"].(map[string]interface{}); fields["port"] != float64(8080) {
+		t.Errorf("info fields = %v, want port 8080", entries[0]["fields"])
+	}
+	if _, ok := entries[1]["fields"]; ok {
+		t.Errorf("warn entry has fields %v, want none", entries[1]["fields"])
+	}
+	if fields, _ := entries[2]["fields"].(map[string]interface{}); fields["user_id"] != "user-1" || fields["error"] == nil {
+		t.Errorf("error fields = %v, want user_id and error", entries[2]["fields"])
+	}
+}
+
+func TestWriteModeRotatesPastMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLogger(ModeWrite, FileOptions{Path: filepath.Join(dir, "logs.json"), MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	// About 1.5MB of entries, past the 1MB threshold
+	padding := strings.Repeat("x", 1000)
+	for i := 0; i < 1500; i++ {
+		l.Info("request served", zap.String("padding", padding))
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read log dir: %v", err)
+	}
+	var backups []string
+	for _, file := range files {
+		if file.Name() != "logs.json" {
+			backups = append(backups, file.Name())
+		}
+	}
+	if len(backups) != 1 || !strings.HasPrefix(backups[0], "logs-") {
+		t.Fatalf("log dir holds %v besides logs.json, want one rolled-over backup", backups)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "logs.json"))
+	if err != nil {
+		t.Fatalf("stat log file: %v", err)
+	}
+	if info.Size() > 1<<20 {
+		t.Errorf("current log file is %d bytes, want at most 1MB after rotating", info.Size())
+	}
+}
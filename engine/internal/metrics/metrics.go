@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HTTPRequestsTotal counts handled HTTP requests by method, route pattern and status code
+var HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_requests_total",
+	Help: "Total number of HTTP requests handled.",
+}, []string{"method", "path", "status"})
+
+// HTTPRequestDuration observes HTTP request latency by method and route pattern
+var HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "http_request_duration_seconds",
+	Help:    "HTTP request latency in seconds.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method", "path"})
+
+// EmbeddingsGenerated counts embeddings returned by the embedding provider; cache hits aren't counted
+var EmbeddingsGenerated = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "embeddings_generated_total",
+	Help: "Total number of embeddings generated by the embedding provider.",
+})
+
+// LLMTokensUsed counts tokens reported by the LLM provider, for streamed and non-streamed responses
+var LLMTokensUsed = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "llm_tokens_used_total",
+	Help: "Total number of tokens used by LLM requests.",
+})
+
+// DocumentsProcessed counts finished document processing runs by outcome ("processed" or "failed")
+var DocumentsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "documents_processed_total",
+	Help: "Total number of document processing runs by outcome.",
+}, []string{"status"})
+
+// Handler serves the default registry in the Prometheus exposition format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
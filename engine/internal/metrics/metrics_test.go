@@ -0,0 +1,42 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"health-dashboard-backend/internal/metrics"
+	"health-dashboard-backend/internal/middleware"
+)
+
+func TestRequestsAreCountedByRoutePattern(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.Prometheus())
+	router.GET("/api/health/metrics/:type", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/metrics", metrics.Handler())
+
+	counter := metrics.HTTPRequestsTotal.WithLabelValues(http.MethodGet, "/api/health/metrics/:type", "200")
+	before := testutil.ToFloat64(counter)
+
+	for _, path := range []string{"/api/health/metrics/heart_rate", "/api/health/metrics/weight"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Errorf("http_requests_total grew by %v, want 2 under the route pattern", got)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.Contains(body, `http_requests_total{method="GET",path="/api/health/metrics/:type",status="200"}`) {
+		t.Errorf("/metrics: status %d, want the request counter in:\n%s", recorder.Code, body)
+	}
+	if strings.Contains(body, "heart_rate") {
+		t.Error("/metrics labels requests by raw path")
+	}
+}
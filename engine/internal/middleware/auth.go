@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/gorilla/websocket"
 
 	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/utils"
 )
 
 // InitClerk initializes the Clerk client with the secret key
@@ -21,7 +23,7 @@ func InitClerk(secretKey string) {
 func ClerkAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Create a wrapper to convert Gin context to standard HTTP
-		handler := clerkhttp.WithHeaderAuthorization()(
+		handler := clerkhttp.WithHeaderAuthorization(clerkhttp.CustomClaimsConstructor(newMetricScopeClaims))(
 			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				// Check if user is authenticated
 				if claims, ok := clerk.SessionClaimsFromContext(r.Context()); ok {
@@ -29,6 +31,7 @@ func ClerkAuth() gin.HandlerFunc {
 					c.Set("user_id", claims.Subject)
 					c.Set("session_claims", claims)
 					c.Set("authenticated", true)
+					setMetricScope(c, claims)
 				} else {
 					c.Set("authenticated", false)
 				}
@@ -48,14 +51,15 @@ func ClerkAuth() gin.HandlerFunc {
 func RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Create a wrapper to convert Gin context to standard HTTP
-		handler := clerkhttp.RequireHeaderAuthorization()(
+		handler := clerkhttp.RequireHeaderAuthorization(clerkhttp.CustomClaimsConstructor(newMetricScopeClaims))(
 			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				if claims, ok := clerk.SessionClaimsFromContext(r.Context()); ok {
 					c.Set("user_id", claims.Subject)
 					c.Set("session_claims", claims)
 					c.Set("authenticated", true)
+					setMetricScope(c, claims)
 				} else {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+					utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Authentication required")
 					c.Abort()
 					return
 				}
@@ -132,7 +136,7 @@ func AuthWebSocket() gin.HandlerFunc {
 		}
 
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token required for WebSocket connection"})
+			utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Token required for WebSocket connection")
 			c.Abort()
 			return
 		}
@@ -144,14 +148,15 @@ func AuthWebSocket() gin.HandlerFunc {
 		req.Header.Set("Authorization", "Bearer "+tokenString)
 
 		// Use Clerk's verification
-		handler := clerkhttp.RequireHeaderAuthorization()(
+		handler := clerkhttp.RequireHeaderAuthorization(clerkhttp.CustomClaimsConstructor(newMetricScopeClaims))(
 			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				if claims, ok := clerk.SessionClaimsFromContext(r.Context()); ok {
 					c.Set("user_id", claims.Subject)
 					c.Set("session_claims", claims)
 					c.Set("authenticated", true)
+					setMetricScope(c, claims)
 				} else {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+					utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid token")
 					c.Abort()
 					return
 				}
@@ -194,13 +199,62 @@ func OptionalAuth() gin.HandlerFunc {
 	return ClerkAuth() // ClerkAuth already handles optional authentication
 }
 
-// RequireRole middleware that requires specific roles (placeholder for future implementation)
+// RoleLookup returns the roles assigned to a user, e.g. AuthService.GetUserRoles
+type RoleLookup func(ctx context.Context, userID string) ([]string, error)
+
+// roleLookup is where RequireRole reads roles from; set with SetRoleLookup
+var roleLookup RoleLookup
+
+// SetRoleLookup sets where RequireRole reads a user's roles from. Until it is called every
+// role-protected request is refused.
+func SetRoleLookup(lookup RoleLookup) {
+	roleLookup = lookup
+}
+
+// skipRoleChecksKey is set by the test-mode auth middleware when TestModeSkipRoles is on
+const skipRoleChecksKey = "skip_role_checks"
+
+// RequireRole middleware that requires the authenticated user to have at least one of allowedRoles.
+// It must run after an auth middleware. The test-mode user is checked like any other unless
+// TestModeSkipRoles is set.
 func RequireRole(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// This would check if user has required roles
-		// Implementation depends on your role system in Clerk
-		// You can use public metadata or private metadata for roles
-		c.Next()
+		userID := GetUserID(c)
+		if userID == "" || !IsAuthenticated(c) {
+			utils.ErrorResponseWithCode(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Authentication required")
+			c.Abort()
+			return
+		}
+
+		if c.GetBool(skipRoleChecksKey) {
+			c.Next()
+			return
+		}
+
+		if roleLookup == nil {
+			utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to verify permissions")
+			c.Abort()
+			return
+		}
+
+		roles, err := roleLookup(c.Request.Context(), userID)
+		if err != nil {
+			utils.ErrorResponseWithCode(c, http.StatusInternalServerError, utils.CodeInternalError, "Failed to verify permissions")
+			c.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			for _, allowed := range allowedRoles {
+				if role == allowed {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		utils.ErrorResponseWithCode(c, http.StatusForbidden, utils.CodeForbidden, "Insufficient permissions")
+		c.Abort()
 	}
 }
 
@@ -236,6 +290,7 @@ func TestAuth(cfg *config.Config) gin.HandlerFunc {
 			c.Set("user_id", "test")
 			c.Set("authenticated", true)
 			c.Set("test_mode", true)
+			c.Set(skipRoleChecksKey, cfg.TestModeSkipRoles)
 			c.Next()
 			return
 		}
@@ -252,6 +307,7 @@ func RequireAuthWithTestMode(cfg *config.Config) gin.HandlerFunc {
 			c.Set("user_id", "test")
 			c.Set("authenticated", true)
 			c.Set("test_mode", true)
+			c.Set(skipRoleChecksKey, cfg.TestModeSkipRoles)
 			c.Next()
 			return
 		}
@@ -269,6 +325,7 @@ func ClerkAuthWithTestMode(cfg *config.Config) gin.HandlerFunc {
 			c.Set("user_id", "test")
 			c.Set("authenticated", true)
 			c.Set("test_mode", true)
+			c.Set(skipRoleChecksKey, cfg.TestModeSkipRoles)
 			c.Next()
 			return
 		}
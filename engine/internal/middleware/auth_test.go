@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"health-dashboard-backend/internal/config"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serveAs sends a request through auth, then RequireRole("admin"), to a handler answering 200
+func serveAs(auth gin.HandlerFunc) int {
+	router := gin.New()
+	router.GET("/admin", auth, RequireRole("admin"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	return recorder.Code
+}
+
+// authenticatedAs marks requests as authenticated for userID, or not at all when it is empty
+func authenticatedAs(userID string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID != "" {
+			c.Set("user_id", userID)
+			c.Set("authenticated", true)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	SetRoleLookup(func(ctx context.Context, userID string) ([]string, error) {
+		switch userID {
+		case "admin-1":
+			return []string{"user", "admin"}, nil
+		case "broken":
+			return nil, errors.New("clerk unavailable")
+		}
+		return []string{"user"}, nil
+	})
+	t.Cleanup(func() { SetRoleLookup(nil) })
+
+	for _, tc := range []struct {
+		caller string
+		want   int
+	}{
+		{"admin-1", http.StatusOK},
+		{"user-1", http.StatusForbidden},
+		{"", http.StatusUnauthorized},
+		{"broken", http.StatusInternalServerError},
+	} {
+		if got := serveAs(authenticatedAs(tc.caller)); got != tc.want {
+			t.Errorf("%q: status %d, want %d", tc.caller, got, tc.want)
+		}
+	}
+}
+
+func TestRequireRoleWithoutLookupRefuses(t *testing.T) {
+	SetRoleLookup(nil)
+
+	if got := serveAs(authenticatedAs("admin-1")); got != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500 with no role lookup configured", got)
+	}
+}
+
+func TestRequireRoleInTestMode(t *testing.T) {
+	SetRoleLookup(func(ctx context.Context, userID string) ([]string, error) {
+		return []string{"user"}, nil
+	})
+	t.Cleanup(func() { SetRoleLookup(nil) })
+
+	cfg := &config.Config{TestMode: true}
+	if got := serveAs(RequireAuthWithTestMode(cfg)); got != http.StatusForbidden {
+		t.Errorf("test mode: status %d, want 403 without TestModeSkipRoles", got)
+	}
+
+	cfg.TestModeSkipRoles = true
+	if got := serveAs(RequireAuthWithTestMode(cfg)); got != http.StatusOK {
+		t.Errorf("test mode with TestModeSkipRoles: status %d, want 200", got)
+	}
+}
@@ -22,7 +22,7 @@ func CORS() gin.HandlerFunc {
 		}
 
 		// Check if origin is allowed (in production, be more restrictive)
-		if isOriginAllowed(origin, allowedOrigins) || origin == "" {
+		if IsOriginAllowed(origin, allowedOrigins) || origin == "" {
 			c.Header("Access-Control-Allow-Origin", origin)
 		}
 
@@ -47,13 +47,13 @@ func CORSWithConfig(config CORSConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
 
-		// Check if origin is allowed
-		if isOriginAllowed(origin, config.AllowedOrigins) || config.AllowAllOrigins {
-			if config.AllowAllOrigins {
-				c.Header("Access-Control-Allow-Origin", "*")
-			} else {
-				c.Header("Access-Control-Allow-Origin", origin)
-			}
+		// Check if origin is allowed. Browsers reject a "*" origin on credentialed requests, so
+		// with credentials allowed every origin is reflected instead.
+		if config.AllowAllOrigins && !config.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else if origin != "" && (config.AllowAllOrigins || IsOriginAllowed(origin, config.AllowedOrigins)) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
 		}
 
 		if config.AllowCredentials {
@@ -115,7 +115,7 @@ type CORSConfig struct {
 	AllowedHeaders   []string `json:"allowed_headers"`
 	ExposedHeaders   []string `json:"exposed_headers"`
 	AllowCredentials bool     `json:"allow_credentials"`
-	MaxAge           string   `json:"max_age"`
+	MaxAge           string   `json:"max_age"` // Preflight cache lifetime in whole seconds
 }
 
 // DefaultCORSConfig returns default CORS configuration
@@ -173,8 +173,8 @@ func ProductionCORSConfig(allowedOrigins []string) CORSConfig {
 	}
 }
 
-// isOriginAllowed checks if an origin is in the allowed list
-func isOriginAllowed(origin string, allowedOrigins []string) bool {
+// IsOriginAllowed checks if an origin is in the allowed list
+func IsOriginAllowed(origin string, allowedOrigins []string) bool {
 	for _, allowedOrigin := range allowedOrigins {
 		if origin == allowedOrigin {
 			return true
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSWildcardWithCredentialsReflectsOrigin(t *testing.T) {
+	preflight := func(config CORSConfig) http.Header {
+		t.Helper()
+		router := gin.New()
+		router.Use(CORSWithConfig(config))
+		request := httptest.NewRequest(http.MethodOptions, "/api/health/latest", nil)
+		request.Header.Set("Origin", "https://app.example.com")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusNoContent {
+			t.Fatalf("preflight: status %d, want 204", recorder.Code)
+		}
+		return recorder.Header()
+	}
+
+	header := preflight(CORSConfig{AllowAllOrigins: true, AllowCredentials: true, MaxAge: "600"})
+	if got := header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("credentialed wildcard: Allow-Origin %q, want the request origin", got)
+	}
+	if got := header.Get("Vary"); got != "Origin" {
+		t.Errorf("credentialed wildcard: Vary %q, want Origin", got)
+	}
+	if got := header.Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("credentialed wildcard: Allow-Credentials %q, want true", got)
+	}
+	if got := header.Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Max-Age %q, want the configured 600", got)
+	}
+
+	header = preflight(CORSConfig{AllowAllOrigins: true})
+	if got := header.Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("wildcard without credentials: Allow-Origin %q, want *", got)
+	}
+	if got := header.Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Max-Age %q with none configured, want it omitted", got)
+	}
+}
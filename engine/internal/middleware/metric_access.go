@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/gin-gonic/gin"
+
+	"health-dashboard-backend/internal/utils"
+)
+
+// allowedMetricTypesKey is the context key holding a principal's metric type allowlist
+const allowedMetricTypesKey = "allowed_metric_types"
+
+// metricScopeClaims are the custom session token claims that scope a principal to some metric
+// types. Tokens minted for a third-party app (e.g. from a Clerk JWT template) carry them; a token
+// without metric_types can access every metric type.
+type metricScopeClaims struct {
+	MetricTypes []string `json:"metric_types"`
+}
+
+// newMetricScopeClaims is the custom claims constructor passed to Clerk's token verification
+func newMetricScopeClaims(ctx context.Context) any {
+	return &metricScopeClaims{}
+}
+
+// setMetricScope restricts the principal to the metric types named by its verified token, if any
+func setMetricScope(c *gin.Context, claims *clerk.SessionClaims) {
+	scope, ok := claims.Custom.(*metricScopeClaims)
+	if !ok || scope.MetricTypes == nil {
+		return
+	}
+	SetAllowedMetricTypes(c, scope.MetricTypes)
+}
+
+// SetAllowedMetricTypes restricts the current principal to the given metric types. Principals
+// without an allowlist can access every metric type.
+func SetAllowedMetricTypes(c *gin.Context, metricTypes []string) {
+	allowed := make(map[string]bool, len(metricTypes))
+	for _, metricType := range metricTypes {
+		if metricType = strings.TrimSpace(metricType); metricType != "" {
+			allowed[metricType] = true
+		}
+	}
+	c.Set(allowedMetricTypesKey, allowed)
+}
+
+// AllowedMetricTypes returns the current principal's metric type allowlist, and false when the
+// principal is unrestricted
+func AllowedMetricTypes(c *gin.Context) (map[string]bool, bool) {
+	value, exists := c.Get(allowedMetricTypesKey)
+	if !exists {
+		return nil, false
+	}
+
+	allowed, ok := value.(map[string]bool)
+	return allowed, ok
+}
+
+// CanAccessMetric reports whether the current principal may read or write metricType
+func CanAccessMetric(c *gin.Context, metricType string) bool {
+	allowed, restricted := AllowedMetricTypes(c)
+	return !restricted || allowed[metricType]
+}
+
+// CheckMetricAccess reports whether the current principal may access every one of metricTypes.
+// If not, it responds 403 naming the first disallowed type.
+func CheckMetricAccess(c *gin.Context, metricTypes ...string) bool {
+	for _, metricType := range metricTypes {
+		if !CanAccessMetric(c, metricType) {
+			utils.ErrorResponseWithCode(c, http.StatusForbidden, utils.CodeForbidden,
+				fmt.Sprintf("Access to metric type %s is not allowed", metricType))
+			return false
+		}
+	}
+	return true
+}
+
+// RequireUnscopedMetricAccess middleware that refuses principals restricted to some metric types.
+// It guards endpoints that combine every metric type, such as chat and the dashboard, whose
+// responses can't be narrowed to an allowlist.
+func RequireUnscopedMetricAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, restricted := AllowedMetricTypes(c); restricted {
+			utils.ErrorResponseWithCode(c, http.StatusForbidden, utils.CodeForbidden,
+				"This endpoint reads every metric type and is not available to credentials scoped to specific metric types")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// serveScoped sends a request for metricType through a principal scoped by claims to a handler
+// answering 200 when CheckMetricAccess allows it
+func serveScoped(claims *clerk.SessionClaims, metricType string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/metrics/:type", func(c *gin.Context) {
+		setMetricScope(c, claims)
+		if CheckMetricAccess(c, c.Param("type")) {
+			c.Status(http.StatusOK)
+		}
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics/"+metricType, nil))
+	return recorder
+}
+
+func TestMetricScopeClaimRestrictsMetricTypes(t *testing.T) {
+	scoped := &clerk.SessionClaims{Custom: &metricScopeClaims{MetricTypes: []string{"steps", " heart_rate"}}}
+
+	for _, metricType := range []string{"steps", "heart_rate"} {
+		if recorder := serveScoped(scoped, metricType); recorder.Code != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", metricType, recorder.Code)
+		}
+	}
+	recorder := serveScoped(scoped, "blood_glucose")
+	if recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), "metric type blood_glucose is not allowed") {
+		t.Errorf("blood_glucose: status %d body %s, want 403 naming the type", recorder.Code, recorder.Body.String())
+	}
+
+	// Tokens without the claim, and an empty but present list, behave differently
+	for _, tc := range []struct {
+		name   string
+		claims *clerk.SessionClaims
+		want   int
+	}{
+		{"no custom claims", &clerk.SessionClaims{}, http.StatusOK},
+		{"no metric_types claim", &clerk.SessionClaims{Custom: &metricScopeClaims{}}, http.StatusOK},
+		{"empty metric_types claim", &clerk.SessionClaims{Custom: &metricScopeClaims{MetricTypes: []string{}}}, http.StatusForbidden},
+	} {
+		if recorder := serveScoped(tc.claims, "blood_glucose"); recorder.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, recorder.Code, tc.want)
+		}
+	}
+}
+
+func TestRequireUnscopedMetricAccess(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		scope []string // Nil for an unrestricted principal
+		want  int
+	}{
+		{"unrestricted", nil, http.StatusOK},
+		{"scoped", []string{"steps"}, http.StatusForbidden},
+	} {
+		router := gin.New()
+		router.GET("/chat", func(c *gin.Context) {
+			if tc.scope != nil {
+				SetAllowedMetricTypes(c, tc.scope)
+			}
+		}, RequireUnscopedMetricAccess(), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/chat", nil))
+		if recorder.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, recorder.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"health-dashboard-backend/internal/metrics"
+)
+
+// unmatchedRoute labels requests that matched no route, so unknown paths share one series
+const unmatchedRoute = "unmatched"
+
+// Prometheus middleware that records request counts and latencies. Requests are labelled by route
+// pattern (e.g. /api/health/metrics/:type) rather than raw path to keep label cardinality bounded.
+func Prometheus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = unmatchedRoute
+		}
+
+		metrics.HTTPRequestsTotal.WithLabelValues(c.Request.Method, path, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, path).Observe(time.Since(start).Seconds())
+	}
+}
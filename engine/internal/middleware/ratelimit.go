@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/utils"
+)
+
+// rateLimitIdleTTL is how long a user's bucket is kept after their last request. A bucket idle
+// this long has refilled completely, so dropping it doesn't change behaviour.
+const rateLimitIdleTTL = 10 * time.Minute
+
+// tokenBucket holds one user's remaining request allowance
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter is a per-user token bucket limiter
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	rate    float64 // Tokens added per second
+	burst   float64
+}
+
+// newRateLimiter creates a limiter and starts its idle-bucket cleanup
+func newRateLimiter(requestsPerMinute, burst int) *rateLimiter {
+	l := &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		rate:    float64(requestsPerMinute) / 60,
+		burst:   float64(burst),
+	}
+
+	go func() {
+		ticker := time.NewTicker(rateLimitIdleTTL)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			l.cleanup(now)
+		}
+	}()
+
+	return l
+}
+
+// allow takes a token from the user's bucket. When the bucket is empty it returns false and how
+// long until the next token is available.
+func (l *rateLimiter) allow(userID string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, exists := l.buckets[userID]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[userID] = bucket
+	}
+
+	elapsed := now.Sub(bucket.lastSeen).Seconds()
+	bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+	bucket.lastSeen = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// cleanup drops buckets of users who haven't made a request recently
+func (l *rateLimiter) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for userID, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > rateLimitIdleTTL {
+			delete(l.buckets, userID)
+		}
+	}
+}
+
+// RateLimiter limits each user to RateLimitPerMinute requests with bursts of up to
+// RateLimitBurst. A nil RateLimiter, returned when rate limiting is disabled, allows everything.
+type RateLimiter struct {
+	limiter *rateLimiter
+}
+
+// NewRateLimiter creates a per-user rate limiter from the config, or nil when RateLimitPerMinute
+// disables rate limiting
+func NewRateLimiter(cfg *config.Config) *RateLimiter {
+	if cfg.RateLimitPerMinute <= 0 {
+		return nil
+	}
+	return &RateLimiter{limiter: newRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)}
+}
+
+// Allow takes a token from the user's bucket. When the bucket is empty it returns false and how
+// long until the next token is available.
+func (r *RateLimiter) Allow(userID string) (bool, time.Duration) {
+	if r == nil {
+		return true, 0
+	}
+	return r.limiter.allow(userID, time.Now())
+}
+
+// Middleware limits requests through the limiter, responding 429 with Retry-After when exceeded.
+// It must run after an auth middleware; requests without a user ID are not limited.
+func (r *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := GetUserID(c)
+		if userID == "" {
+			c.Next()
+			return
+		}
+
+		allowed, wait := r.Allow(userID)
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			utils.ErrorResponseWithCodeDetails(c, http.StatusTooManyRequests, utils.CodeRateLimited, "Rate limit exceeded", gin.H{
+				"retry_after": retryAfter,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RateLimit middleware that limits each authenticated user to RateLimitPerMinute requests with
+// bursts of up to RateLimitBurst, responding 429 with Retry-After when exceeded. Each call creates
+// an independent limiter; routes that should share an allowance must share a RateLimiter.
+// It must run after an auth middleware; requests without a user ID are not limited.
+func RateLimit(cfg *config.Config) gin.HandlerFunc {
+	return NewRateLimiter(cfg).Middleware()
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"health-dashboard-backend/internal/config"
+)
+
+func TestRateLimitRejectsRequestsBeyondBurst(t *testing.T) {
+	cfg := &config.Config{RateLimitPerMinute: 1, RateLimitBurst: 3}
+	limit := RateLimit(cfg)
+
+	router := gin.New()
+	router.POST("/chat", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-User"))
+	}, limit, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(userID string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/chat", nil)
+		request.Header.Set("X-User", userID)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := send("user-1").Code; code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i+1, code)
+		}
+	}
+
+	recorder := send("user-1")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("request 4: status %d, want 429", recorder.Code)
+	}
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "0" {
+		t.Errorf("Retry-After = %q, want the seconds until the next token", retryAfter)
+	}
+
+	if code := send("user-2").Code; code != http.StatusOK {
+		t.Errorf("another user: status %d, want 200", code)
+	}
+}
+
+func TestRateLimiterRefillsOverTime(t *testing.T) {
+	limiter := newRateLimiter(60, 1)
+	now := time.Now()
+
+	if allowed, _ := limiter.allow("user-1", now); !allowed {
+		t.Fatal("first request rejected")
+	}
+	allowed, wait := limiter.allow("user-1", now)
+	if allowed || wait <= 0 || wait > time.Second {
+		t.Fatalf("second request: allowed %v, wait %v, want rejected for up to a second", allowed, wait)
+	}
+	if allowed, _ := limiter.allow("user-1", now.Add(time.Second)); !allowed {
+		t.Error("request rejected after the bucket refilled")
+	}
+
+	limiter.cleanup(now.Add(time.Second + rateLimitIdleTTL + time.Minute))
+	if len(limiter.buckets) != 0 {
+		t.Errorf("%d buckets left after cleanup, want 0", len(limiter.buckets))
+	}
+}
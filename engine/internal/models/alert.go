@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Health alert severities, from most to least severe
+const (
+	AlertSeverityCritical = "critical"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityInfo     = "info"
+)
+
+// HealthAlert flags a latest reading that is outside its normal range
+type HealthAlert struct {
+	Severity   string    `json:"severity"`
+	MetricType string    `json:"metric_type"`
+	Value      float64   `json:"value"`
+	Unit       string    `json:"unit"`
+	Status     string    `json:"status"` // "high" or "low"
+	Message    string    `json:"message"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
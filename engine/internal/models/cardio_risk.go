@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Cardiovascular risk flags
+const (
+	CardioRiskLow      = "low"
+	CardioRiskModerate = "moderate"
+	CardioRiskElevated = "elevated"
+)
+
+// CardioRiskDisclaimer is returned with every cardiovascular risk flag
+const CardioRiskDisclaimer = "This flag is a simple screening aid computed from your most recent readings using fixed " +
+	"thresholds. It is not a medical diagnosis or a validated risk score; discuss your results with a healthcare provider."
+
+// CardioRiskInput is one factor considered in the cardiovascular risk flag
+type CardioRiskInput struct {
+	Factor      string     `json:"factor"`       // blood_pressure, resting_heart_rate, bmi, ldl or hdl
+	MetricTypes []string   `json:"metric_types"` // Metrics the factor is read from
+	Available   bool       `json:"available"`
+	Values      []float64  `json:"values,omitempty"` // In MetricTypes order
+	Unit        string     `json:"unit"`
+	MeasuredAt  *time.Time `json:"measured_at,omitempty"` // Oldest of the readings used
+	Category    string     `json:"category,omitempty"`    // e.g. "stage_1_hypertension"
+	Points      int        `json:"points"`                // 0 (favourable) to 2 (unfavourable)
+	Rule        string     `json:"rule"`                  // The thresholds applied
+}
+
+// CardioRisk is a composite cardiovascular flag built from the latest readings
+type CardioRisk struct {
+	Flag        string            `json:"flag"`
+	Points      int               `json:"points"`
+	MaxPoints   int               `json:"max_points"` // 2 per available input
+	Inputs      []CardioRiskInput `json:"inputs"`
+	Missing     []string          `json:"missing"` // Factors without a reading
+	Disclaimer  string            `json:"disclaimer"`
+	EvaluatedAt time.Time         `json:"evaluated_at"`
+}
@@ -1,26 +1,53 @@
 package models
 
 import (
+	"crypto/rand"
+	"fmt"
 	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
 )
 
 // ChatMessage represents a single message in a conversation
 type ChatMessage struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Role      string    `json:"role"` // "user" or "assistant"
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
-	Metadata  Metadata  `json:"metadata,omitempty"`
+	ID        string    `json:"id" dynamodbav:"message_id"`
+	UserID    string    `json:"user_id" dynamodbav:"user_id"`
+	SortKey   string    `json:"sort_key,omitempty" dynamodbav:"sort_key"` // session_id#timestamp
+	SessionID string    `json:"session_id,omitempty" dynamodbav:"session_id"`
+	Role      string    `json:"role" dynamodbav:"role"` // "user" or "assistant"
+	Content   string    `json:"content" dynamodbav:"content"`
+	Timestamp time.Time `json:"timestamp" dynamodbav:"timestamp"`
+	Metadata  Metadata  `json:"metadata,omitempty" dynamodbav:"metadata,omitempty"`
+
+	// SentAt is Timestamp in a fixed-width UTC format that sorts as a string; the sort key of the
+	// chat table's timestamp index
+	SentAt string `json:"-" dynamodbav:"sent_at,omitempty"`
+
+	// Prompt is the exact prompt sent to the LLM for an assistant message. It is stored for
+	// auditing but never serialized in history; it is only served by the prompt endpoint.
+	Prompt *PromptRecord `json:"-" dynamodbav:"prompt,omitempty"`
+
+	// ExpiresAt is when the message is purged, in Unix seconds; the chat table's TTL attribute.
+	// Zero (omitted) keeps the message indefinitely.
+	ExpiresAt int64 `json:"-" dynamodbav:"expires_at,omitempty"`
 }
 
 // ChatRequest represents a chat request from the user
 type ChatRequest struct {
 	Message   string            `json:"message" binding:"required"`
 	SessionID string            `json:"session_id,omitempty"`
-	Context   map[string]string `json:"context,omitempty"`
-	MaxTokens int               `json:"max_tokens,omitempty"`
+	Context   map[string]string `json:"context,omitempty"`    // "persona" and "locale" override the assistant's defaults
+	MaxTokens int               `json:"max_tokens,omitempty"` // Clamped to the configured MAX_TOKENS
 	Stream    bool              `json:"stream,omitempty"`
+	Language  string            `json:"language,omitempty"` // ISO 639-1 code for the response language
+	Locale    string            `json:"locale,omitempty"`   // e.g. "es-MX"; sets the response language when Language is empty
+	// Deterministic forces temperature 0 and a fixed seed so the same question yields the same answer
+	Deterministic bool `json:"deterministic,omitempty"`
+	// Optional caps on response size; nil uses the server defaults
+	MaxSources     *int `json:"max_sources,omitempty"`
+	MaxSuggestions *int `json:"max_suggestions,omitempty"`
 }
 
 // ChatResponse represents the AI's response
@@ -34,6 +61,14 @@ type ChatResponse struct {
 	Timestamp      time.Time    `json:"timestamp"`
 	TokensUsed     int          `json:"tokens_used,omitempty"`
 	ProcessingTime int64        `json:"processing_time_ms,omitempty"`
+	Metadata       Metadata     `json:"metadata,omitempty"`
+
+	// AllSources holds every retrieved source before trimming; it is persisted with the
+	// message for the detail endpoint but never serialized in the response itself
+	AllSources []Source `json:"-"`
+
+	// Prompt is the assembled prompt that produced this response; persisted with the message only
+	Prompt *PromptRecord `json:"-"`
 }
 
 // Source represents a source document used in the response
@@ -62,7 +97,9 @@ type Metadata struct {
 	QueryType     string            `json:"query_type,omitempty"`
 	Intent        string            `json:"intent,omitempty"`
 	Confidence    float32           `json:"confidence,omitempty"`
+	Language      string            `json:"language,omitempty"`
 	RAGContext    []RAGContext      `json:"rag_context,omitempty"`
+	Sources       []Source          `json:"sources,omitempty"`
 	HealthContext []HealthContext   `json:"health_context,omitempty"`
 	Errors        []string          `json:"errors,omitempty"`
 	Debug         map[string]string `json:"debug,omitempty"`
@@ -70,10 +107,49 @@ type Metadata struct {
 
 // RAGContext represents context retrieved from documents
 type RAGContext struct {
-	DocumentID string  `json:"document_id"`
-	ChunkID    string  `json:"chunk_id"`
-	Content    string  `json:"content"`
-	Score      float32 `json:"score"`
+	DocumentID   string  `json:"document_id"`
+	DocumentName string  `json:"document_name,omitempty"` // Document title, when it could be resolved
+	ChunkID      string  `json:"chunk_id"`
+	PageNumber   int     `json:"page_number,omitempty"` // Page the chunk came from, 0 when unknown
+	Content      string  `json:"content"`
+	Score        float32 `json:"score"`
+}
+
+// MessageSources holds the full, untrimmed set of sources for a stored assistant message
+type MessageSources struct {
+	MessageID string   `json:"message_id"`
+	SessionID string   `json:"session_id"`
+	Sources   []Source `json:"sources"`
+	Count     int      `json:"count"`
+}
+
+// PromptMessage is a single role/content pair sent to the LLM
+type PromptMessage struct {
+	Role    string `json:"role" dynamodbav:"role"`
+	Content string `json:"content" dynamodbav:"content"`
+}
+
+// PromptRecord captures everything needed to reproduce an LLM call: the assembled messages,
+// the generation settings and the context they were built from
+type PromptRecord struct {
+	Messages      []PromptMessage `json:"messages" dynamodbav:"messages"`
+	Provider      string          `json:"provider" dynamodbav:"provider"`
+	Intent        string          `json:"intent,omitempty" dynamodbav:"intent,omitempty"`
+	Language      string          `json:"language,omitempty" dynamodbav:"language,omitempty"`
+	MaxTokens     int             `json:"max_tokens" dynamodbav:"max_tokens"`
+	Temperature   float32         `json:"temperature" dynamodbav:"temperature"`
+	Seed          *int64          `json:"seed,omitempty" dynamodbav:"seed,omitempty"`
+	HealthContext []HealthContext `json:"health_context,omitempty" dynamodbav:"health_context,omitempty"`
+	RAGContext    []RAGContext    `json:"rag_context,omitempty" dynamodbav:"rag_context,omitempty"`
+}
+
+// MessagePrompt is the audit view of the prompt stored with an assistant message
+type MessagePrompt struct {
+	MessageID string        `json:"message_id"`
+	SessionID string        `json:"session_id"`
+	UserID    string        `json:"user_id"`
+	Timestamp time.Time     `json:"timestamp"`
+	Prompt    *PromptRecord `json:"prompt"`
 }
 
 // HealthContext represents health data context
@@ -87,13 +163,26 @@ type HealthContext struct {
 
 // ChatSession represents a conversation session
 type ChatSession struct {
-	SessionID    string            `json:"session_id"`
-	UserID       string            `json:"user_id"`
-	StartTime    time.Time         `json:"start_time"`
-	LastActive   time.Time         `json:"last_active"`
-	MessageCount int               `json:"message_count"`
-	Messages     []ChatMessage     `json:"messages"`
-	Context      map[string]string `json:"context,omitempty"`
+	SessionID    string            `json:"session_id" dynamodbav:"session_id"`
+	UserID       string            `json:"user_id" dynamodbav:"user_id"`
+	StartTime    time.Time         `json:"start_time" dynamodbav:"start_time"`
+	LastActive   time.Time         `json:"last_active" dynamodbav:"last_active"`
+	MessageCount int               `json:"message_count" dynamodbav:"message_count"`
+	Messages     []ChatMessage     `json:"messages" dynamodbav:"messages"`
+	Context      map[string]string `json:"context,omitempty" dynamodbav:"context,omitempty"`
+
+	// ExpiresAt is when a stored session is purged, in Unix seconds; the sessions table's TTL
+	// attribute. Zero (omitted) keeps the session indefinitely.
+	ExpiresAt int64 `json:"-" dynamodbav:"expires_at,omitempty"`
+}
+
+// ActiveSession describes an open WebSocket chat connection
+type ActiveSession struct {
+	SessionID      string    `json:"session_id"`
+	ConnectedSince time.Time `json:"connected_since"`
+	LastActive     time.Time `json:"last_active"`
+	RemoteAddr     string    `json:"remote_addr,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
 }
 
 // WebSocketMessage represents a WebSocket message
@@ -122,6 +211,9 @@ type StreamChunk struct {
 	ID      string `json:"id"`
 	Content string `json:"content"`
 	Done    bool   `json:"done"`
+	// Response is set on the final (Done) chunk: the whole message with its sources, health data
+	// and metadata
+	Response *ChatResponse `json:"response,omitempty"`
 }
 
 // ChatHistory represents chat history for a user
@@ -145,6 +237,35 @@ const (
 	IntentRecommendation QueryIntent = "recommendation"
 )
 
+// Document context scopes for a routed query
+const (
+	DocumentScopeNone      = "none"      // No document retrieval
+	DocumentScopeDocuments = "documents" // Uploaded documents only
+	DocumentScopeAll       = "all"       // Documents and any indexed health snapshots
+)
+
+// QueryRoute describes which context sources a query would draw on
+type QueryRoute struct {
+	UsesHealthData      bool   `json:"uses_health_data"`      // Latest metric readings
+	UsesHealthSnapshots bool   `json:"uses_health_snapshots"` // Past health snapshots from the vector index
+	DocumentScope       string `json:"document_scope"`
+}
+
+// AnalyzeQueryRequest is a query to preview routing for
+type AnalyzeQueryRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// QueryAnalysis is a preview of how a chat query would be routed, without answering it
+type QueryAnalysis struct {
+	Query      string      `json:"query"`
+	Intent     QueryIntent `json:"intent"`
+	Confidence float32     `json:"confidence"` // 0-1, from how many intent keywords matched
+	Keywords   []string    `json:"keywords"`   // Intent keywords found in the query
+	Metrics    []string    `json:"metrics"`    // Supported metric types named in the query
+	Route      QueryRoute  `json:"route"`
+}
+
 // ToolName represents AI agent tools
 type ToolName string
 
@@ -165,6 +286,22 @@ type ToolCall struct {
 	Duration   int64                  `json:"duration_ms,omitempty"`
 }
 
+// SetRetention makes the message expire retention after its timestamp; zero or less keeps it
+// indefinitely
+func (m *ChatMessage) SetRetention(retention time.Duration) {
+	if retention <= 0 {
+		m.ExpiresAt = 0
+		return
+	}
+	m.ExpiresAt = m.Timestamp.Add(retention).Unix()
+}
+
+// IsExpired reports whether the message's retention has passed. DynamoDB TTL deletes expired
+// items in the background, so they can still be read for a while.
+func (m *ChatMessage) IsExpired(now time.Time) bool {
+	return m.ExpiresAt > 0 && now.Unix() >= m.ExpiresAt
+}
+
 // NewChatMessage creates a new chat message
 func NewChatMessage(userID, role, content string) *ChatMessage {
 	return &ChatMessage{
@@ -177,6 +314,39 @@ func NewChatMessage(userID, role, content string) *ChatMessage {
 	}
 }
 
+// ToDynamoDBItem converts ChatMessage to DynamoDB item
+func (m *ChatMessage) ToDynamoDBItem() (map[string]*dynamodb.AttributeValue, error) {
+	return dynamodbattribute.MarshalMap(m)
+}
+
+// FromDynamoDBItem converts DynamoDB item to ChatMessage
+func (m *ChatMessage) FromDynamoDBItem(item map[string]*dynamodb.AttributeValue) error {
+	return dynamodbattribute.UnmarshalMap(item, m)
+}
+
+// chatTimestampFormat is fixed-width so that timestamps in keys sort chronologically
+const chatTimestampFormat = "2006-01-02T15:04:05.000000Z"
+
+// GetSortKey returns the sort key for DynamoDB (session ID + timestamp)
+func (m *ChatMessage) GetSortKey() string {
+	return m.SessionID + "#" + m.GetSentAt()
+}
+
+// GetSentAt returns the timestamp index's sort key
+func (m *ChatMessage) GetSentAt() string {
+	return m.Timestamp.UTC().Format(chatTimestampFormat)
+}
+
+// ToDynamoDBItem converts ChatSession to DynamoDB item
+func (cs *ChatSession) ToDynamoDBItem() (map[string]*dynamodb.AttributeValue, error) {
+	return dynamodbattribute.MarshalMap(cs)
+}
+
+// FromDynamoDBItem converts DynamoDB item to ChatSession
+func (cs *ChatSession) FromDynamoDBItem(item map[string]*dynamodb.AttributeValue) error {
+	return dynamodbattribute.UnmarshalMap(item, cs)
+}
+
 // NewChatSession creates a new chat session
 func NewChatSession(userID string) *ChatSession {
 	return &ChatSession{
@@ -214,17 +384,19 @@ func generateMessageID() string {
 
 // generateSessionID generates a unique session ID
 func generateSessionID() string {
-	// Using simple timestamp-based ID for now
-	// In production, use UUID or similar
-	return "sess_" + time.Now().Format("20060102150405") + "_" + randomString(8)
+	return "sess_" + uuid.New().String()
 }
 
 // randomString generates a random string of given length
 func randomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	random := make([]byte, length)
+	if _, err := rand.Read(random); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
 	result := make([]byte, length)
 	for i := range result {
-		result[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+		result[i] = charset[int(random[i])%len(charset)]
 	}
 	return string(result)
 }
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateSessionIDIsUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := generateSessionID()
+		if !strings.HasPrefix(id, "sess_") {
+			t.Fatalf("session ID %q lacks the sess_ prefix", id)
+		}
+		if seen[id] {
+			t.Fatalf("generated duplicate session ID %q", id)
+		}
+		seen[id] = true
+	}
+}
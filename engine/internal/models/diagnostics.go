@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ComponentStatus reports the outcome of a single diagnostic check
+type ComponentStatus struct {
+	Healthy   bool                   `json:"healthy"`
+	LatencyMs int64                  `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Diagnostics is the consolidated operational report for a deployment
+type Diagnostics struct {
+	Healthy    bool                       `json:"healthy"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	DurationMs int64                      `json:"duration_ms"`
+	Components map[string]ComponentStatus `json:"components"`
+	Config     map[string]interface{}     `json:"config"` // Resolved settings with secrets redacted
+}
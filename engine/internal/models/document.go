@@ -19,8 +19,10 @@ type Document struct {
 	FileType              string    `json:"file_type" dynamodbav:"file_type"`
 	ContentType           string    `json:"content_type" dynamodbav:"content_type"`
 	FileSize              int64     `json:"file_size" dynamodbav:"file_size"`
+	ContentHash           string    `json:"content_hash,omitempty" dynamodbav:"content_hash,omitempty"` // Hex SHA-256 of the file
 	S3Key                 string    `json:"s3_key" dynamodbav:"s3_key"`
 	S3URL                 string    `json:"s3_url,omitempty" dynamodbav:"s3_url,omitempty"`
+	TextS3Key             string    `json:"text_s3_key,omitempty" dynamodbav:"text_s3_key,omitempty"` // Extracted text, when stored
 	UploadTime            time.Time `json:"upload_time" dynamodbav:"upload_time"`
 	ProcessedAt           time.Time `json:"processed_at,omitempty" dynamodbav:"processed_at,omitempty"`
 	Status                string    `json:"status" dynamodbav:"status"` // "uploaded", "processing", "processed", "failed"
@@ -32,17 +34,37 @@ type Document struct {
 	ProcessingAttempts    int       `json:"processing_attempts" dynamodbav:"processing_attempts"`
 	LastProcessingAttempt time.Time `json:"last_processing_attempt,omitempty" dynamodbav:"last_processing_attempt,omitempty"`
 	IndexedInPinecone     bool      `json:"indexed_in_pinecone" dynamodbav:"indexed_in_pinecone"`
+	Version               int       `json:"version" dynamodbav:"version"`             // Incremented on every write; 0 for documents stored before versioning
+	Archived              bool      `json:"archived" dynamodbav:"archived,omitempty"` // Hidden from listings by default; data is kept
+
+	// Values and lab reference ranges found in the text during processing
+	LabResults []LabResult `json:"lab_results,omitempty" dynamodbav:"lab_results,omitempty"`
+
+	// Filled in from the processing queue while the document waits to be processed; not stored
+	QueuePosition        int `json:"queue_position,omitempty" dynamodbav:"-"`
+	EstimatedWaitSeconds int `json:"estimated_wait_seconds,omitempty" dynamodbav:"-"`
 }
 
-// DocumentChunk represents a chunk of a document for vector storage
+// DocumentChunk represents a chunk of a document for vector storage. Chunks are also stored in
+// the chunks table, keyed by document_id/chunk_index, without their embedding.
 type DocumentChunk struct {
-	ChunkID    string            `json:"chunk_id"`
-	DocumentID string            `json:"document_id"`
-	UserID     string            `json:"user_id"`
-	Content    string            `json:"content"`
-	ChunkIndex int               `json:"chunk_index"`
-	Metadata   map[string]string `json:"metadata"`
-	Embedding  []float32         `json:"embedding,omitempty"`
+	ChunkID    string            `json:"chunk_id" dynamodbav:"chunk_id"`
+	DocumentID string            `json:"document_id" dynamodbav:"document_id"`
+	UserID     string            `json:"user_id" dynamodbav:"user_id"`
+	Content    string            `json:"content" dynamodbav:"content"`
+	ChunkIndex int               `json:"chunk_index" dynamodbav:"chunk_index"`
+	Metadata   map[string]string `json:"metadata" dynamodbav:"metadata,omitempty"`
+	Embedding  []float32         `json:"embedding,omitempty" dynamodbav:"-"`
+}
+
+// IndexedChunk is a document chunk as stored in the vector database, for inspecting what was indexed
+type IndexedChunk struct {
+	ChunkID          string                 `json:"chunk_id"`
+	ChunkIndex       int                    `json:"chunk_index"`
+	PageNumber       int                    `json:"page_number,omitempty"` // Page the chunk came from, 0 when unknown
+	Content          string                 `json:"content"`
+	ContentTruncated bool                   `json:"content_truncated,omitempty"` // Stored content was cut to fit the metadata limit
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`          // Remaining vector metadata, such as document_title
 }
 
 // DocumentUploadRequest represents a document upload request
@@ -53,6 +75,34 @@ type DocumentUploadRequest struct {
 	Tags        []string `json:"tags,omitempty"`
 }
 
+// DocumentUpdateRequest is a partial update of a document's details. Omitted fields are left
+// unchanged. Category is part of the document's key, so it can't be changed here.
+type DocumentUpdateRequest struct {
+	Title       *string   `json:"title,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	Version     *int      `json:"version,omitempty"` // Expected current version, as an alternative to If-Match
+}
+
+// DocumentStatus is the processing state of a document, small enough to poll for
+type DocumentStatus struct {
+	DocumentID         string     `json:"document_id" dynamodbav:"document_id"`
+	Status             string     `json:"status" dynamodbav:"status"`
+	ChunkCount         int        `json:"chunk_count" dynamodbav:"chunk_count"`
+	ProcessingAttempts int        `json:"processing_attempts" dynamodbav:"processing_attempts"`
+	ErrorMessage       string     `json:"error_message,omitempty" dynamodbav:"error_message"`
+	ProcessedAt        *time.Time `json:"processed_at,omitempty" dynamodbav:"processed_at"`
+
+	// Filled in from the processing queue while the document waits to be processed; not stored
+	QueuePosition        int `json:"queue_position,omitempty" dynamodbav:"-"`
+	EstimatedWaitSeconds int `json:"estimated_wait_seconds,omitempty" dynamodbav:"-"`
+}
+
+// FromDynamoDBItem converts a projected DynamoDB document item to DocumentStatus
+func (s *DocumentStatus) FromDynamoDBItem(item map[string]*dynamodb.AttributeValue) error {
+	return dynamodbattribute.UnmarshalMap(item, s)
+}
+
 // DocumentListResponse represents response for listing documents
 type DocumentListResponse struct {
 	Documents  []Document `json:"documents"`
@@ -61,11 +111,34 @@ type DocumentListResponse struct {
 	NextCursor string     `json:"next_cursor,omitempty"`
 }
 
+// DocumentFilter narrows a document listing. Empty fields don't filter.
+type DocumentFilter struct {
+	Category        string
+	Tags            []string // A document must carry every tag
+	IncludeArchived bool     // Archived documents are left out unless set
+}
+
 // DocumentUploadResponse represents response after document upload
 type DocumentUploadResponse struct {
-	Document *Document `json:"document"`
-	Status   string    `json:"status"`
-	Message  string    `json:"message"`
+	Document  *Document `json:"document"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	Duplicate bool      `json:"duplicate"` // The file was already uploaded; Document is the existing one
+}
+
+// ReprocessFailure is a document that couldn't be queued for reprocessing
+type ReprocessFailure struct {
+	DocumentID string `json:"document_id"`
+	Error      string `json:"error"`
+}
+
+// ReprocessResult summarises a request to re-embed all of a user's documents
+type ReprocessResult struct {
+	UserID   string             `json:"user_id"`
+	Total    int                `json:"total"`   // Processed documents found
+	Queued   int                `json:"queued"`  // Vectors deleted and document queued for processing
+	Skipped  int                `json:"skipped"` // Documents not yet processed, left as they are
+	Failures []ReprocessFailure `json:"failures"`
 }
 
 // DocumentStatus constants
@@ -103,6 +176,7 @@ func NewDocument(userID, title, fileName, fileType, contentType, category string
 		UploadTime:  now,
 		Status:      StatusUploaded,
 		ChunkCount:  0,
+		Version:     1,
 	}
 }
 
@@ -144,6 +218,11 @@ func (d *Document) SetS3Key(bucket string) {
 	d.S3Key = fmt.Sprintf("%s/%s/%s", d.UserID, d.DocumentID, d.FileName)
 }
 
+// ExtractedTextKey returns the S3 key the document's extracted text is stored under
+func (d *Document) ExtractedTextKey() string {
+	return fmt.Sprintf("%s/%s/extracted/text.txt", d.UserID, d.DocumentID)
+}
+
 // SetS3URL sets the S3 URL for the document
 func (d *Document) SetS3URL(url string) {
 	d.S3URL = url
@@ -212,3 +291,61 @@ func (dc *DocumentChunk) SetMetadata(key, value string) {
 	}
 	dc.Metadata[key] = value
 }
+
+// Document access actions recorded in the access log
+const (
+	AccessActionView = "view" // Presigned view URL issued
+)
+
+// DocumentAccessLog records a single access to a document for auditing
+type DocumentAccessLog struct {
+	DocumentID string    `json:"document_id" dynamodbav:"document_id"`
+	SortKey    string    `json:"-" dynamodbav:"sort_key"` // timestamp#id
+	ID         string    `json:"id" dynamodbav:"log_id"`
+	UserID     string    `json:"user_id" dynamodbav:"user_id"`
+	Action     string    `json:"action" dynamodbav:"action"`
+	IPAddress  string    `json:"ip_address" dynamodbav:"ip_address"`
+	UserAgent  string    `json:"user_agent,omitempty" dynamodbav:"user_agent,omitempty"`
+	Timestamp  time.Time `json:"timestamp" dynamodbav:"timestamp"`
+	ExpiresAt  int64     `json:"-" dynamodbav:"expires_at"` // Unix seconds; used as the table's TTL attribute
+}
+
+// NewDocumentAccessLog creates a new access log entry that expires after the given retention period
+func NewDocumentAccessLog(documentID, userID, action, ipAddress, userAgent string, retention time.Duration) *DocumentAccessLog {
+	now := time.Now().UTC()
+	return &DocumentAccessLog{
+		DocumentID: documentID,
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Action:     action,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Timestamp:  now,
+		ExpiresAt:  now.Add(retention).Unix(),
+	}
+}
+
+// ToDynamoDBItem converts DocumentChunk to DynamoDB item
+func (c *DocumentChunk) ToDynamoDBItem() (map[string]*dynamodb.AttributeValue, error) {
+	return dynamodbattribute.MarshalMap(c)
+}
+
+// FromDynamoDBItem converts DynamoDB item to DocumentChunk
+func (c *DocumentChunk) FromDynamoDBItem(item map[string]*dynamodb.AttributeValue) error {
+	return dynamodbattribute.UnmarshalMap(item, c)
+}
+
+// ToDynamoDBItem converts DocumentAccessLog to DynamoDB item
+func (l *DocumentAccessLog) ToDynamoDBItem() (map[string]*dynamodb.AttributeValue, error) {
+	return dynamodbattribute.MarshalMap(l)
+}
+
+// FromDynamoDBItem converts DynamoDB item to DocumentAccessLog
+func (l *DocumentAccessLog) FromDynamoDBItem(item map[string]*dynamodb.AttributeValue) error {
+	return dynamodbattribute.UnmarshalMap(item, l)
+}
+
+// GetSortKey returns the sort key for DynamoDB (timestamp + id)
+func (l *DocumentAccessLog) GetSortKey() string {
+	return l.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z") + "#" + l.ID
+}
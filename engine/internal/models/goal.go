@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+// Goal types
+const (
+	GoalTypeTarget = "target" // Reach TargetValue, moving from StartValue in either direction
+	GoalTypeBelow  = "below"  // Keep the metric at or below TargetValue
+	GoalTypeAbove  = "above"  // Keep the metric at or above TargetValue
+)
+
+// Goal progress statuses
+const (
+	GoalStatusAchieved = "achieved"
+	GoalStatusOnTrack  = "on_track"
+	GoalStatusOffTrack = "off_track"
+	GoalStatusNoData   = "no_data" // No reading of the metric to evaluate against
+)
+
+// HealthGoal is a user-defined target for a health metric
+type HealthGoal struct {
+	UserID      string     `json:"user_id" dynamodbav:"user_id"`
+	GoalID      string     `json:"goal_id" dynamodbav:"goal_id"`
+	MetricType  string     `json:"metric_type" dynamodbav:"metric_type"`
+	GoalType    string     `json:"goal_type" dynamodbav:"goal_type"`
+	TargetValue float64    `json:"target_value" dynamodbav:"target_value"`
+	StartValue  *float64   `json:"start_value,omitempty" dynamodbav:"start_value,omitempty"` // Baseline for target goals
+	TargetDate  *time.Time `json:"target_date,omitempty" dynamodbav:"target_date,omitempty"`
+	Notes       string     `json:"notes,omitempty" dynamodbav:"notes,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" dynamodbav:"created_at"`
+}
+
+// HealthGoalInput represents input for creating a health goal
+type HealthGoalInput struct {
+	MetricType  string     `json:"metric_type" binding:"required"`
+	GoalType    string     `json:"goal_type" binding:"required"`
+	TargetValue float64    `json:"target_value" binding:"required"`
+	StartValue  *float64   `json:"start_value,omitempty"` // Defaults to the latest reading when omitted
+	TargetDate  *time.Time `json:"target_date,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+}
+
+// GoalProgress reports how a goal is doing against the latest reading of its metric
+type GoalProgress struct {
+	Goal            HealthGoal `json:"goal"`
+	CurrentValue    *float64   `json:"current_value,omitempty"`
+	CurrentAt       *time.Time `json:"current_at,omitempty"`
+	Unit            string     `json:"unit"`
+	ProgressPercent float64    `json:"progress_percent"` // 0-100
+	Status          string     `json:"status"`
+}
+
+// NewHealthGoal creates a new goal from validated input
+func NewHealthGoal(userID string, input *HealthGoalInput) *HealthGoal {
+	return &HealthGoal{
+		UserID:      userID,
+		GoalID:      uuid.New().String(),
+		MetricType:  input.MetricType,
+		GoalType:    input.GoalType,
+		TargetValue: input.TargetValue,
+		StartValue:  input.StartValue,
+		TargetDate:  input.TargetDate,
+		Notes:       input.Notes,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// ToDynamoDBItem converts HealthGoal to DynamoDB item
+func (g *HealthGoal) ToDynamoDBItem() (map[string]*dynamodb.AttributeValue, error) {
+	return dynamodbattribute.MarshalMap(g)
+}
+
+// FromDynamoDBItem converts DynamoDB item to HealthGoal
+func (g *HealthGoal) FromDynamoDBItem(item map[string]*dynamodb.AttributeValue) error {
+	return dynamodbattribute.UnmarshalMap(item, g)
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"math"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -17,48 +19,131 @@ type HealthMetric struct {
 	Unit      string    `json:"unit" dynamodbav:"unit"`
 	Notes     string    `json:"notes,omitempty" dynamodbav:"notes,omitempty"`
 	Source    string    `json:"source,omitempty" dynamodbav:"source,omitempty"` // manual, device, etc.
+
+	// Set when the reading was submitted in a non-canonical unit and converted on ingest
+	OriginalValue *float64 `json:"original_value,omitempty" dynamodbav:"original_value,omitempty"`
+	OriginalUnit  string   `json:"original_unit,omitempty" dynamodbav:"original_unit,omitempty"`
+}
+
+// Metric sources set by the backend. Clients may send any source; an empty one counts as manual.
+const (
+	MetricSourceManual    = "manual"
+	MetricSourceDerived   = "derived"    // Computed from other readings, e.g. BMI
+	MetricSourceCSVImport = "csv_import" // Bulk CSV import without an explicit source
+)
+
+// EffectiveSource returns the reading's source, treating an empty source as manual
+func (h *HealthMetric) EffectiveSource() string {
+	if h.Source == "" {
+		return MetricSourceManual
+	}
+	return h.Source
+}
+
+// MetricSourceFilter selects readings by source. Include, when set, keeps only those sources;
+// otherwise readings from Exclude are dropped. The zero value keeps everything.
+type MetricSourceFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// Matches reports whether the filter keeps a reading
+func (f MetricSourceFilter) Matches(metric *HealthMetric) bool {
+	source := metric.EffectiveSource()
+	if len(f.Include) > 0 {
+		for _, included := range f.Include {
+			if source == included {
+				return true
+			}
+		}
+		return false
+	}
+	for _, excluded := range f.Exclude {
+		if source == excluded {
+			return false
+		}
+	}
+	return true
+}
+
+// IsEmpty reports whether the filter keeps every reading
+func (f MetricSourceFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
 }
 
 // HealthMetricInput represents input for adding health data
 type HealthMetricInput struct {
 	Type   string  `json:"type" binding:"required"`
-	Value  float64 `json:"value" binding:"required"`
+	Value  float64 `json:"value"` // Not binding:"required" so boolean "no" (0) is accepted; validated per kind
 	Unit   string  `json:"unit" binding:"required"`
 	Notes  string  `json:"notes,omitempty"`
 	Source string  `json:"source,omitempty"`
+
+	// Timestamp is when the measurement was taken; it defaults to now and must not be in the future
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+
+	// IdempotencyKey makes retried submissions return the first metric instead of storing a
+	// duplicate. The Idempotency-Key header takes precedence.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// MetricImportRow is a single parsed row from a CSV metrics import
+type MetricImportRow struct {
+	Row       int // 1-based line number in the file, counting the header
+	Input     HealthMetricInput
+	Timestamp time.Time
+}
+
+// MetricImportRowResult reports the outcome of importing one CSV row
+type MetricImportRowResult struct {
+	Row     int    `json:"row"`
+	Type    string `json:"type,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// MetricImportReport summarizes a CSV metrics import
+type MetricImportReport struct {
+	TotalRows int                     `json:"total_rows"`
+	Imported  int                     `json:"imported"`
+	Failed    int                     `json:"failed"`
+	Results   []MetricImportRowResult `json:"results"`
 }
 
 // BloodPressureInput represents input for blood pressure with both systolic and diastolic values
 type BloodPressureInput struct {
-	Type      string  `json:"type" binding:"required"` // Should be "blood_pressure"
-	Systolic  float64 `json:"systolic" binding:"required"`
-	Diastolic float64 `json:"diastolic" binding:"required"`
-	Unit      string  `json:"unit" binding:"required"` // Should be "mmHg"
-	Notes     string  `json:"notes,omitempty"`
-	Source    string  `json:"source,omitempty"`
+	Type      string     `json:"type" binding:"required"` // Should be "blood_pressure"
+	Systolic  float64    `json:"systolic" binding:"required"`
+	Diastolic float64    `json:"diastolic" binding:"required"`
+	Unit      string     `json:"unit" binding:"required"` // Should be "mmHg"
+	Notes     string     `json:"notes,omitempty"`
+	Source    string     `json:"source,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"` // Measurement time; defaults to now
 }
 
 // BloodGlucoseInput represents input for blood glucose with both fasting and postprandial values
 type BloodGlucoseInput struct {
-	Type         string  `json:"type" binding:"required"` // Should be "blood_glucose"
-	Fasting      float64 `json:"fasting" binding:"required"`
-	Postprandial float64 `json:"postprandial" binding:"required"`
-	Unit         string  `json:"unit" binding:"required"` // Should be "mg/dL"
-	Notes        string  `json:"notes,omitempty"`
-	Source       string  `json:"source,omitempty"`
+	Type         string     `json:"type" binding:"required"` // Should be "blood_glucose"
+	Fasting      float64    `json:"fasting" binding:"required"`
+	Postprandial float64    `json:"postprandial" binding:"required"`
+	Unit         string     `json:"unit" binding:"required"` // "mg/dL" or "mmol/L"
+	Notes        string     `json:"notes,omitempty"`
+	Source       string     `json:"source,omitempty"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"` // Measurement time; defaults to now
 }
 
 // CompositeHealthMetricInput represents input that can handle both regular and composite metrics
 type CompositeHealthMetricInput struct {
-	Type         string   `json:"type" binding:"required"`
-	Value        *float64 `json:"value,omitempty"`        // For regular metrics
-	Systolic     *float64 `json:"systolic,omitempty"`     // For blood pressure
-	Diastolic    *float64 `json:"diastolic,omitempty"`    // For blood pressure
-	Fasting      *float64 `json:"fasting,omitempty"`      // For blood glucose (FPG)
-	Postprandial *float64 `json:"postprandial,omitempty"` // For blood glucose (PPG)
-	Unit         string   `json:"unit" binding:"required"`
-	Notes        string   `json:"notes,omitempty"`
-	Source       string   `json:"source,omitempty"`
+	Type         string     `json:"type" binding:"required"`
+	Value        *float64   `json:"value,omitempty"`        // For regular metrics
+	Systolic     *float64   `json:"systolic,omitempty"`     // For blood pressure
+	Diastolic    *float64   `json:"diastolic,omitempty"`    // For blood pressure
+	Fasting      *float64   `json:"fasting,omitempty"`      // For blood glucose (FPG)
+	Postprandial *float64   `json:"postprandial,omitempty"` // For blood glucose (PPG)
+	Unit         string     `json:"unit" binding:"required"`
+	Notes        string     `json:"notes,omitempty"`
+	Source       string     `json:"source,omitempty"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"` // Measurement time; defaults to now
 }
 
 // HealthSummary represents a summary of health metrics
@@ -74,23 +159,61 @@ type LatestMetric struct {
 	Unit      string    `json:"unit"`
 	Timestamp time.Time `json:"timestamp"`
 	Trend     string    `json:"trend,omitempty"` // "up", "down", "stable"
+	Status    string    `json:"status"`          // "normal", "high", "low", or "unknown" when the metric has no normal range
 }
 
 // HealthTrend represents trend data for a metric over time
 type HealthTrend struct {
-	MetricType string      `json:"metric_type"`
-	Period     string      `json:"period"` // "week", "month", "year"
-	DataPoints []DataPoint `json:"data_points"`
-	Average    float64     `json:"average"`
-	Min        float64     `json:"min"`
-	Max        float64     `json:"max"`
-	Trend      string      `json:"trend"`
+	MetricType    string      `json:"metric_type"`
+	Period        string      `json:"period"` // "week", "month", "year"
+	Unit          string      `json:"unit"`
+	Precision     int         `json:"precision"` // Decimal places the values are rounded to
+	DataPoints    []DataPoint `json:"data_points"`
+	Average       float64     `json:"average"`
+	Min           float64     `json:"min"`
+	Max           float64     `json:"max"`
+	StdDev        float64     `json:"std_dev"`        // Population standard deviation
+	MovingAverage []DataPoint `json:"moving_average"` // Trailing moving average, aligned with DataPoints
+	AnomalyCount  int         `json:"anomaly_count"`
+	Trend         string      `json:"trend"`
+	ValueKind     string      `json:"value_kind"`
+
+	// Boolean metrics only: how many readings were yes and no. Average is then the share of yes.
+	YesCount *int `json:"yes_count,omitempty"`
+	NoCount  *int `json:"no_count,omitempty"`
 }
 
 // DataPoint represents a single data point in a trend
 type DataPoint struct {
 	Timestamp time.Time `json:"timestamp"`
 	Value     float64   `json:"value"`
+	IsAnomaly bool      `json:"is_anomaly"` // Outside the metric's normal range
+}
+
+// Aggregation buckets for metric history
+const (
+	AggregateDay   = "day"
+	AggregateWeek  = "week" // ISO weeks starting on Monday
+	AggregateMonth = "month"
+)
+
+// MetricBucket summarizes the readings that fall in one calendar bucket
+type MetricBucket struct {
+	Start time.Time `json:"start"` // Inclusive bucket start in the requested timezone
+	End   time.Time `json:"end"`   // Exclusive bucket end
+	Mean  float64   `json:"mean"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int       `json:"count"`
+}
+
+// AggregatedHistory is a metric's history grouped into calendar buckets
+type AggregatedHistory struct {
+	MetricType string         `json:"metric_type"`
+	Unit       string         `json:"unit"`
+	Bucket     string         `json:"bucket"`
+	Timezone   string         `json:"timezone"`
+	Buckets    []MetricBucket `json:"buckets"`
 }
 
 // SupportedMetrics contains all supported health metric types
@@ -99,111 +222,173 @@ var SupportedMetrics = map[string]MetricInfo{
 		Name:        "Blood Pressure",
 		Unit:        "mmHg",
 		Category:    "cardiovascular",
+		Precision:   0,
 		NormalRange: nil, // Special handling for composite metric
 	},
 	"blood_pressure_systolic": {
 		Name:        "Blood Pressure (Systolic)",
 		Unit:        "mmHg",
 		Category:    "cardiovascular",
+		Precision:   0,
 		NormalRange: &Range{Min: 90, Max: 120},
+		SanityRange: &Range{Min: 60, Max: 250},
 	},
 	"blood_pressure_diastolic": {
 		Name:        "Blood Pressure (Diastolic)",
 		Unit:        "mmHg",
 		Category:    "cardiovascular",
+		Precision:   0,
 		NormalRange: &Range{Min: 60, Max: 80},
+		SanityRange: &Range{Min: 30, Max: 150},
 	},
 	"heart_rate": {
 		Name:        "Heart Rate",
 		Unit:        "bpm",
 		Category:    "cardiovascular",
+		Precision:   0,
 		NormalRange: &Range{Min: 60, Max: 100},
+		SanityRange: &Range{Min: 30, Max: 220},
 	},
 	"weight": {
-		Name:     "Weight",
-		Unit:     "kg",
-		Category: "physical",
+		Name:        "Weight",
+		Unit:        "kg",
+		Category:    "physical",
+		Precision:   1,
+		SanityRange: &Range{Min: 1, Max: 500},
 	},
 	"height": {
-		Name:     "Height",
-		Unit:     "cm",
-		Category: "physical",
+		Name:        "Height",
+		Unit:        "cm",
+		Category:    "physical",
+		Precision:   1,
+		SanityRange: &Range{Min: 50, Max: 250},
 	},
 	"bmi": {
 		Name:        "Body Mass Index",
 		Unit:        "kg/m²",
 		Category:    "physical",
+		Precision:   1,
 		NormalRange: &Range{Min: 18.5, Max: 24.9},
+		SanityRange: &Range{Min: 10, Max: 100},
 	},
 	"blood_glucose": {
 		Name:        "Blood Glucose",
 		Unit:        "mg/dL",
 		Category:    "metabolic",
+		Precision:   0,
 		NormalRange: nil, // Special handling for composite metric
+		SanityRange: &Range{Min: 20, Max: 600},
 	},
 	"blood_glucose_fasting": {
 		Name:        "Fasting Plasma Glucose (FPG)",
 		Unit:        "mg/dL",
 		Category:    "metabolic",
+		Precision:   0,
 		NormalRange: &Range{Min: 70, Max: 100},
+		SanityRange: &Range{Min: 50, Max: 200},
 	},
 	"blood_glucose_postprandial": {
 		Name:        "Postprandial Blood Glucose (PPG)",
 		Unit:        "mg/dL",
 		Category:    "metabolic",
+		Precision:   0,
 		NormalRange: &Range{Min: 70, Max: 140},
+		SanityRange: &Range{Min: 70, Max: 400},
 	},
 	"blood_oxygen_saturation": {
 		Name:        "Blood Oxygen Saturation (SpO2)",
 		Unit:        "%",
 		Category:    "respiratory",
+		Precision:   0,
 		NormalRange: &Range{Min: 95, Max: 100},
+		SanityRange: &Range{Min: 50, Max: 100},
 	},
 	"body_temperature": {
 		Name:        "Body Temperature",
 		Unit:        "°C",
 		Category:    "vital_signs",
+		Precision:   1,
 		NormalRange: &Range{Min: 36.1, Max: 37.2},
+		SanityRange: &Range{Min: 30, Max: 45},
 	},
 	"cholesterol_total": {
 		Name:        "Total Cholesterol",
 		Unit:        "mg/dL",
 		Category:    "metabolic",
+		Precision:   0,
 		NormalRange: &Range{Min: 0, Max: 200},
+		SanityRange: &Range{Min: 0, Max: 1000},
 	},
 	"cholesterol_hdl": {
 		Name:        "HDL Cholesterol",
 		Unit:        "mg/dL",
 		Category:    "metabolic",
+		Precision:   0,
 		NormalRange: &Range{Min: 40, Max: 999},
+		SanityRange: &Range{Min: 0, Max: 300},
 	},
 	"cholesterol_ldl": {
 		Name:        "LDL Cholesterol",
 		Unit:        "mg/dL",
 		Category:    "metabolic",
+		Precision:   0,
 		NormalRange: &Range{Min: 0, Max: 100},
+		SanityRange: &Range{Min: 0, Max: 1000},
 	},
 	"sleep_duration": {
 		Name:        "Sleep Duration",
 		Unit:        "hours",
 		Category:    "lifestyle",
+		Precision:   1,
 		NormalRange: &Range{Min: 7, Max: 9},
+		SanityRange: &Range{Min: 0, Max: 24},
 	},
 	"exercise_duration": {
-		Name:     "Exercise Duration",
-		Unit:     "minutes",
-		Category: "lifestyle",
+		Name:        "Exercise Duration",
+		Unit:        "minutes",
+		Category:    "lifestyle",
+		Precision:   0,
+		SanityRange: &Range{Min: 0, Max: 1440},
 	},
 	"water_intake": {
-		Name:     "Water Intake",
-		Unit:     "liters",
-		Category: "lifestyle",
+		Name:        "Water Intake",
+		Unit:        "liters",
+		Category:    "lifestyle",
+		Precision:   2,
+		SanityRange: &Range{Min: 0, Max: 20},
 	},
 	"steps": {
-		Name:     "Steps",
-		Unit:     "count",
-		Category: "activity",
+		Name:        "Steps",
+		Unit:        "count",
+		Category:    "activity",
+		Precision:   0,
+		SanityRange: &Range{Min: 0, Max: 200000},
+	},
+	"medication_taken": {
+		Name:      "Medication Taken",
+		Unit:      "yes/no",
+		Category:  "lifestyle",
+		Precision: 0,
+		ValueKind: ValueKindBoolean,
 	},
+	"mood": {
+		Name:      "Mood",
+		Unit:      "scale",
+		Category:  "lifestyle",
+		Precision: 0,
+		ValueKind: ValueKindOrdinal,
+		Levels:    []string{"very_low", "low", "neutral", "good", "very_good"},
+	},
+}
+
+// SupportedMetricTypes returns the sorted list of supported metric type keys
+func SupportedMetricTypes() []string {
+	types := make([]string, 0, len(SupportedMetrics))
+	for metricType := range SupportedMetrics {
+		types = append(types, metricType)
+	}
+	sort.Strings(types)
+	return types
 }
 
 // MetricInfo contains metadata about a health metric
@@ -212,6 +397,11 @@ type MetricInfo struct {
 	Unit        string `json:"unit"`
 	Category    string `json:"category"`
 	NormalRange *Range `json:"normal_range,omitempty"`
+	SanityRange *Range `json:"sanity_range,omitempty"` // Values outside it are rejected as implausible
+	Precision   int    `json:"precision"`              // Decimal places used when displaying values
+
+	ValueKind string   `json:"value_kind,omitempty"` // numeric, boolean or ordinal; empty means numeric
+	Levels    []string `json:"levels,omitempty"`     // Ordinal level names, lowest first; value i is Levels[i-1]
 }
 
 // Range represents a normal range for a metric
@@ -228,6 +418,12 @@ func (m *MetricInfo) IsWithinNormalRange(value float64) bool {
 	return value >= m.NormalRange.Min && value <= m.NormalRange.Max
 }
 
+// Round rounds a value to the metric's display precision
+func (m *MetricInfo) Round(value float64) float64 {
+	scale := math.Pow(10, float64(m.Precision))
+	return math.Round(value*scale) / scale
+}
+
 // ToDynamoDBItem converts HealthMetric to DynamoDB item
 func (h *HealthMetric) ToDynamoDBItem() (map[string]*dynamodb.AttributeValue, error) {
 	return dynamodbattribute.MarshalMap(h)
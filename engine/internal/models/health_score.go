@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Health score categories, from best to worst
+const (
+	HealthScoreExcellent      = "Excellent"
+	HealthScoreGood           = "Good"
+	HealthScoreFair           = "Fair"
+	HealthScoreNeedsAttention = "Needs attention"
+	HealthScoreNoData         = "Insufficient data"
+)
+
+// HealthScoreComponent is one metric's contribution to the health score
+type HealthScoreComponent struct {
+	MetricType string  `json:"metric_type"`
+	Value      float64 `json:"value"`
+	Status     string  `json:"status"` // "normal", "high" or "low" against the effective normal range
+	Weight     float64 `json:"weight"`
+	Points     float64 `json:"points"` // 1 in range, 0.5 mildly out of range, 0 far out of range
+}
+
+// HealthScore is an overall 0-100 score computed from the latest reading of each metric that has a
+// normal range
+type HealthScore struct {
+	Score       int                    `json:"score"`
+	Category    string                 `json:"category"`
+	Description string                 `json:"description"`
+	Components  []HealthScoreComponent `json:"components"`
+	EvaluatedAt time.Time              `json:"evaluated_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Insight is a single AI-generated observation about the user's health data
+type Insight struct {
+	Type        string `json:"type" dynamodbav:"type"` // trend, pattern, risk or achievement
+	Title       string `json:"title" dynamodbav:"title"`
+	Description string `json:"description" dynamodbav:"description"`
+	Confidence  string `json:"confidence" dynamodbav:"confidence"` // high, medium or low
+	Action      string `json:"action" dynamodbav:"action"`         // Recommended next step, e.g. "consult_doctor"
+}
+
+// HealthInsights is the AI-generated insight set for a user, cached so the agent isn't re-run on every load
+type HealthInsights struct {
+	UserID      string    `json:"user_id" dynamodbav:"user_id"`
+	Summary     string    `json:"summary" dynamodbav:"summary"` // Raw LLM response the insights were generated from
+	Insights    []Insight `json:"insights" dynamodbav:"insights"`
+	GeneratedAt time.Time `json:"generated_at" dynamodbav:"generated_at"`
+	Cached      bool      `json:"cached" dynamodbav:"-"` // True when served from the cache rather than freshly generated
+
+	// DataFingerprint identifies the health data the insights were generated from
+	DataFingerprint string `json:"-" dynamodbav:"data_fingerprint"`
+	ExpiresAt       int64  `json:"-" dynamodbav:"expires_at"` // Unix seconds; used as the table's TTL attribute
+}
+
+// ToDynamoDBItem converts HealthInsights to DynamoDB item
+func (h *HealthInsights) ToDynamoDBItem() (map[string]*dynamodb.AttributeValue, error) {
+	return dynamodbattribute.MarshalMap(h)
+}
+
+// FromDynamoDBItem converts DynamoDB item to HealthInsights
+func (h *HealthInsights) FromDynamoDBItem(item map[string]*dynamodb.AttributeValue) error {
+	return dynamodbattribute.UnmarshalMap(item, h)
+}
+
+// IsFresh reports whether the insights were generated from the given data and haven't expired
+func (h *HealthInsights) IsFresh(fingerprint string, now time.Time) bool {
+	return h.DataFingerprint == fingerprint && now.Unix() < h.ExpiresAt
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Range status values used when interpreting a value against a range
+const (
+	RangeStatusLow     = "low"
+	RangeStatusNormal  = "normal"
+	RangeStatusHigh    = "high"
+	RangeStatusUnknown = "unknown"
+)
+
+// LabResult is a metric value extracted from a document together with the lab's own reference range
+type LabResult struct {
+	MetricType     string  `json:"metric_type" dynamodbav:"metric_type"`
+	Name           string  `json:"name" dynamodbav:"name"` // Label as printed in the document
+	Value          float64 `json:"value" dynamodbav:"value"`
+	Unit           string  `json:"unit,omitempty" dynamodbav:"unit,omitempty"`
+	ReferenceRange *Range  `json:"reference_range,omitempty" dynamodbav:"reference_range,omitempty"`
+	Flag           string  `json:"flag,omitempty" dynamodbav:"flag,omitempty"` // "high"/"low" when the lab flagged the value
+}
+
+// RangeReconciliation compares a document's interpretation of a value with the default range and the user's tracked data
+type RangeReconciliation struct {
+	MetricType     string     `json:"metric_type"`
+	Name           string     `json:"name"`
+	Value          float64    `json:"value"`
+	Unit           string     `json:"unit,omitempty"`
+	DocumentRange  *Range     `json:"document_range,omitempty"`
+	DefaultRange   *Range     `json:"default_range,omitempty"`
+	DocumentStatus string     `json:"document_status"` // Per the lab's flag or reference range
+	DefaultStatus  string     `json:"default_status"`  // Per SupportedMetrics' normal range
+	Discrepancy    bool       `json:"discrepancy"`
+	TrackedValue   *float64   `json:"tracked_value,omitempty"`
+	TrackedAt      *time.Time `json:"tracked_at,omitempty"`
+	TrackedStatus  string     `json:"tracked_status,omitempty"` // Latest tracked value against the document's range
+	Note           string     `json:"note,omitempty"`
+}
+
+// ReferenceRangeReport lists reconciliations for every lab result found in a document
+type ReferenceRangeReport struct {
+	DocumentID       string                `json:"document_id"`
+	Results          []RangeReconciliation `json:"results"`
+	DiscrepancyCount int                   `json:"discrepancy_count"`
+}
+
+// Status classifies a value against the range; a nil range yields RangeStatusUnknown
+func (r *Range) Status(value float64) string {
+	if r == nil {
+		return RangeStatusUnknown
+	}
+	if value < r.Min {
+		return RangeStatusLow
+	}
+	if value > r.Max {
+		return RangeStatusHigh
+	}
+	return RangeStatusNormal
+}
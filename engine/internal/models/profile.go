@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// UserHealthProfile holds per-user health settings, such as normal ranges prescribed by the user's doctor
+type UserHealthProfile struct {
+	UserID        string                `json:"user_id" dynamodbav:"user_id"`
+	CustomRanges  map[string]Range      `json:"custom_ranges" dynamodbav:"custom_ranges"`                       // Keyed by metric type; overrides SupportedMetrics
+	CustomMetrics map[string]MetricInfo `json:"custom_metrics,omitempty" dynamodbav:"custom_metrics,omitempty"` // User-defined metric types, keyed by type
+	UpdatedAt     time.Time             `json:"updated_at" dynamodbav:"updated_at"`
+}
+
+// CustomMetricInput defines a user's own metric type, for measurements not in SupportedMetrics
+type CustomMetricInput struct {
+	Type        string `json:"type" binding:"required"` // Lowercase letters, digits and underscores, e.g. "peak_flow"
+	Name        string `json:"name" binding:"required"`
+	Unit        string `json:"unit" binding:"required"`
+	Category    string `json:"category,omitempty"` // Defaults to "custom"
+	NormalRange *Range `json:"normal_range,omitempty"`
+	SanityRange *Range `json:"sanity_range,omitempty"` // Values outside it are rejected; without it values must be positive
+	Precision   int    `json:"precision,omitempty"`
+}
+
+// EffectiveRange is the normal range applied to a user's metric and where it came from
+type EffectiveRange struct {
+	MetricType string `json:"metric_type"`
+	Range      *Range `json:"range"`  // Nil when the metric has no normal range
+	Custom     bool   `json:"custom"` // True when the user's override is in effect
+}
+
+// RangeBand is the normal range to shade on a metric's chart. Composite metrics such as
+// blood_pressure have no range of their own and instead list a band per component.
+type RangeBand struct {
+	MetricType string      `json:"metric_type"`
+	Unit       string      `json:"unit"`
+	Range      *Range      `json:"range"` // Nil when the metric has no normal range
+	Custom     bool        `json:"custom"`
+	Components []RangeBand `json:"components,omitempty"`
+}
+
+// ToDynamoDBItem converts UserHealthProfile to DynamoDB item
+func (p *UserHealthProfile) ToDynamoDBItem() (map[string]*dynamodb.AttributeValue, error) {
+	return dynamodbattribute.MarshalMap(p)
+}
+
+// FromDynamoDBItem converts DynamoDB item to UserHealthProfile
+func (p *UserHealthProfile) FromDynamoDBItem(item map[string]*dynamodb.AttributeValue) error {
+	return dynamodbattribute.UnmarshalMap(item, p)
+}
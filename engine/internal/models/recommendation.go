@@ -0,0 +1,17 @@
+package models
+
+// Recommendation priorities, from most to least pressing
+const (
+	RecommendationPriorityHigh   = "high"
+	RecommendationPriorityMedium = "medium"
+	RecommendationPriorityLow    = "low"
+)
+
+// Recommendation is a piece of advice prompted by the user's latest readings
+type Recommendation struct {
+	Type        string `json:"type"` // e.g. "exercise", "nutrition", "sleep"
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"`
+	MetricType  string `json:"metric_type,omitempty"` // The reading that prompted it
+}
@@ -0,0 +1,33 @@
+package models
+
+import "strings"
+
+// NormalizeTag trims, lowercases and collapses internal whitespace in a tag, then maps it through
+// synonyms (keyed by normalized form). It returns "" for blank tags.
+func NormalizeTag(tag string, synonyms map[string]string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	if canonical, exists := synonyms[normalized]; exists {
+		return canonical
+	}
+	return normalized
+}
+
+// NormalizeTags normalizes each tag and drops blanks and duplicates, keeping first-seen order
+func NormalizeTags(tags []string, synonyms map[string]string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag, synonyms)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	synonyms := map[string]string{"labs": "lab", "blood work": "lab"}
+
+	for _, tc := range []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"Lab", "lab", "lab "}, "lab"},
+		{[]string{"  Blood   Work ", "LABS", "Cardiology"}, "lab,cardiology"},
+		{[]string{"", "   ", "x-ray"}, "x-ray"},
+		{[]string{"Annual  Checkup", "annual checkup"}, "annual checkup"},
+	} {
+		if got := strings.Join(NormalizeTags(tc.tags, synonyms), ","); got != tc.want {
+			t.Errorf("NormalizeTags(%q) = %q, want %q", tc.tags, got, tc.want)
+		}
+	}
+
+	if got := NormalizeTags(nil, synonyms); got != nil {
+		t.Errorf("NormalizeTags(nil) = %q, want nil", got)
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import "strings"
+
+// unitConversion converts a value from an alias unit to a metric's canonical unit
+type unitConversion func(value float64) float64
+
+// mgPerDLPerMmolGlucose converts glucose between mmol/L and mg/dL (molar mass 180.16 g/mol)
+const mgPerDLPerMmolGlucose = 18.016
+
+var (
+	poundsToKilograms    unitConversion = func(v float64) float64 { return v * 0.45359237 }
+	fahrenheitToCelsius  unitConversion = func(v float64) float64 { return (v - 32) * 5 / 9 }
+	glucoseMmolToMgPerDL unitConversion = func(v float64) float64 { return v * mgPerDLPerMmolGlucose }
+	sameUnit             unitConversion = func(v float64) float64 { return v }
+)
+
+// glucoseUnitAliases is shared by every blood glucose metric
+var glucoseUnitAliases = map[string]unitConversion{
+	"mmol/l": glucoseMmolToMgPerDL,
+	"mg/dl":  sameUnit,
+}
+
+// unitAliases maps metric type -> lowercase alias unit -> conversion to the canonical unit in SupportedMetrics
+var unitAliases = map[string]map[string]unitConversion{
+	"weight": {
+		"lb":     poundsToKilograms,
+		"lbs":    poundsToKilograms,
+		"pound":  poundsToKilograms,
+		"pounds": poundsToKilograms,
+		"kgs":    sameUnit,
+	},
+	"body_temperature": {
+		"°f":         fahrenheitToCelsius,
+		"f":          fahrenheitToCelsius,
+		"degf":       fahrenheitToCelsius,
+		"fahrenheit": fahrenheitToCelsius,
+		"c":          sameUnit,
+		"degc":       sameUnit,
+		"celsius":    sameUnit,
+	},
+	"blood_glucose":              glucoseUnitAliases,
+	"blood_glucose_fasting":      glucoseUnitAliases,
+	"blood_glucose_postprandial": glucoseUnitAliases,
+}
+
+// ConvertToCanonical converts value from unit to the metric's canonical unit and returns the
+// converted value and canonical unit. ok is false if the metric type is unsupported or unit is
+// neither the canonical unit nor a known alias of it.
+func ConvertToCanonical(metricType string, value float64, unit string) (converted float64, canonicalUnit string, ok bool) {
+	metricInfo, exists := SupportedMetrics[metricType]
+	if !exists {
+		return 0, "", false
+	}
+
+	if metricInfo.Unit == "" || unit == metricInfo.Unit {
+		return value, unit, true
+	}
+
+	convert, exists := unitAliases[metricType][strings.ToLower(strings.TrimSpace(unit))]
+	if !exists {
+		return 0, "", false
+	}
+
+	return convert(value), metricInfo.Unit, true
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertToCanonical(t *testing.T) {
+	for _, tc := range []struct {
+		metricType string
+		value      float64
+		unit       string
+		want       float64
+		wantUnit   string
+	}{
+		{"weight", 180, "lbs", 81.647, "kg"},
+		{"weight", 180, "Pounds", 81.647, "kg"},
+		{"weight", 81.6, "kg", 81.6, "kg"},
+		{"body_temperature", 98.6, "°F", 37, "°C"},
+		{"body_temperature", 212, "fahrenheit", 100, "°C"},
+		{"body_temperature", 37, "celsius", 37, "°C"},
+		{"blood_glucose", 5.5, "mmol/L", 99.088, "mg/dL"},
+		{"blood_glucose_fasting", 5, "mmol/l", 90.08, "mg/dL"},
+		{"blood_glucose_postprandial", 140, "mg/dl", 140, "mg/dL"},
+	} {
+		got, unit, ok := ConvertToCanonical(tc.metricType, tc.value, tc.unit)
+		if !ok {
+			t.Errorf("%s %v %s: not converted", tc.metricType, tc.value, tc.unit)
+			continue
+		}
+		if math.Abs(got-tc.want) > 0.001 || unit != tc.wantUnit {
+			t.Errorf("%s %v %s = %v %s, want %v %s", tc.metricType, tc.value, tc.unit, got, unit, tc.want, tc.wantUnit)
+		}
+	}
+}
+
+func TestConvertToCanonicalRejectsUnknownUnits(t *testing.T) {
+	for _, tc := range []struct{ metricType, unit string }{
+		{"weight", "stone"},
+		{"body_temperature", "kelvin"},
+		{"heart_rate", "lbs"},
+		{"not_a_metric", "kg"},
+	} {
+		if _, _, ok := ConvertToCanonical(tc.metricType, 1, tc.unit); ok {
+			t.Errorf("%s in %s was accepted", tc.metricType, tc.unit)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Metric value kinds. Every kind is stored as a float64 so queries, trends and exports work
+// unchanged: booleans are 0 (no) or 1 (yes) and ordinal levels are 1..len(Levels).
+const (
+	ValueKindNumeric = "numeric"
+	ValueKindBoolean = "boolean"
+	ValueKindOrdinal = "ordinal"
+)
+
+// booleanLabels maps accepted spellings of a boolean value to its stored value
+var booleanLabels = map[string]float64{
+	"yes":   1,
+	"y":     1,
+	"true":  1,
+	"no":    0,
+	"n":     0,
+	"false": 0,
+}
+
+// Kind returns the metric's value kind, defaulting to numeric
+func (m *MetricInfo) Kind() string {
+	if m.ValueKind == "" {
+		return ValueKindNumeric
+	}
+	return m.ValueKind
+}
+
+// ValidateKind checks that a value is valid for a boolean or ordinal metric. Numeric metrics are
+// range-checked by ValidateSanity, so any value passes here.
+func (m *MetricInfo) ValidateKind(value float64) error {
+	switch m.Kind() {
+	case ValueKindBoolean:
+		if value != 0 && value != 1 {
+			return fmt.Errorf("%s value must be 0 (no) or 1 (yes)", m.Name)
+		}
+	case ValueKindOrdinal:
+		if value != math.Trunc(value) || value < 1 || int(value) > len(m.Levels) {
+			return fmt.Errorf("%s value must be a whole number from 1 to %d (%s)",
+				m.Name, len(m.Levels), strings.Join(m.Levels, ", "))
+		}
+	}
+	return nil
+}
+
+// ValidateSanity rejects implausible values: boolean and ordinal metrics are checked with
+// ValidateKind, numeric metrics against SanityRange, or must be positive when it isn't set
+func (m *MetricInfo) ValidateSanity(value float64) error {
+	if m.Kind() != ValueKindNumeric {
+		return m.ValidateKind(value)
+	}
+
+	if m.SanityRange == nil {
+		if value <= 0 {
+			return fmt.Errorf("%s value must be positive", m.Name)
+		}
+		return nil
+	}
+
+	if value < m.SanityRange.Min || value > m.SanityRange.Max {
+		return fmt.Errorf("%s value out of reasonable range (%g-%g %s)",
+			m.Name, m.SanityRange.Min, m.SanityRange.Max, m.Unit)
+	}
+	return nil
+}
+
+// ParseValue parses a value written as a number or, for boolean and ordinal metrics, as a label
+// such as "yes" or one of the metric's level names
+func (m *MetricInfo) ParseValue(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if value, err := strconv.ParseFloat(s, 64); err == nil {
+		return value, nil
+	}
+
+	label := strings.ToLower(s)
+	switch m.Kind() {
+	case ValueKindBoolean:
+		if value, ok := booleanLabels[label]; ok {
+			return value, nil
+		}
+	case ValueKindOrdinal:
+		for i, level := range m.Levels {
+			if label == level {
+				return float64(i + 1), nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("invalid value: %q", s)
+}
+
+// ValueLabel returns the label for a boolean or ordinal value, or "" for numeric metrics and
+// values with no label
+func (m *MetricInfo) ValueLabel(value float64) string {
+	switch m.Kind() {
+	case ValueKindBoolean:
+		if value == 1 {
+			return "yes"
+		}
+		if value == 0 {
+			return "no"
+		}
+	case ValueKindOrdinal:
+		if i := int(value); float64(i) == value && i >= 1 && i <= len(m.Levels) {
+			return m.Levels[i-1]
+		}
+	}
+	return ""
+}
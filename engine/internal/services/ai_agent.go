@@ -2,13 +2,19 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"go.uber.org/zap"
+
 	"health-dashboard-backend/internal/config"
 	"health-dashboard-backend/internal/models"
 	"health-dashboard-backend/pkg/ai"
+	"health-dashboard-backend/pkg/fileprocessor"
 )
 
 // AIAgent orchestrates AI-powered health analysis and chat
@@ -16,25 +22,71 @@ type AIAgent struct {
 	healthService *HealthService
 	ragService    *RAGService
 	llmClient     ai.LLMClient
+	insightsCache *InsightsCache
 	cfg           *config.Config
+	logger        *zap.Logger
+	history       ConversationHistory // Optional; without it every query starts a fresh conversation
+	userProfiles  UserProfileLookup   // Optional; without it the system prompt has no per-user details
+}
+
+// UserPromptProfile holds the account details the system prompt is personalized with
+type UserPromptProfile struct {
+	FirstName string // Name to address the user by
+	Locale    string // Preferred locale, e.g. "es-MX"; it also sets the default response language
+}
+
+// UserProfileLookup supplies users' account details for the system prompt
+type UserProfileLookup interface {
+	GetUserPromptProfile(ctx context.Context, userID string) (*UserPromptProfile, error)
+}
+
+// ConversationHistory supplies a chat session's earlier messages
+type ConversationHistory interface {
+	// RecentMessages returns up to limit of the session's latest messages, oldest first
+	RecentMessages(userID, sessionID string, limit int) ([]models.ChatMessage, error)
 }
 
 // NewAIAgent creates a new AI agent
-func NewAIAgent(healthService *HealthService, ragService *RAGService, llmClient ai.LLMClient, cfg *config.Config) *AIAgent {
+func NewAIAgent(healthService *HealthService, ragService *RAGService, llmClient ai.LLMClient, insightsCache *InsightsCache, cfg *config.Config, logger *zap.Logger) *AIAgent {
 	return &AIAgent{
 		healthService: healthService,
 		ragService:    ragService,
 		llmClient:     llmClient,
+		insightsCache: insightsCache,
 		cfg:           cfg,
+		logger:        logger,
 	}
 }
 
+// QueryOptions holds per-request settings for processing a chat query
+type QueryOptions struct {
+	Language       string // ISO 639-1 response language; empty uses the locale's language or the configured default
+	MaxSources     *int   // Cap on returned sources; nil uses MaxSourcesReturned
+	MaxSuggestions *int   // Cap on returned suggestions; nil uses MaxSuggestions
+	Deterministic  bool   // Force temperature 0 and a fixed seed for reproducible answers
+	MaxTokens      int    // Completion token cap; 0 or anything above the configured MaxTokens uses MaxTokens
+	Persona        string // Assistant persona; empty uses the configured AssistantPersona
+	Locale         string // Locale for answers; empty uses the user's preferred locale or the configured AssistantLocale
+}
+
+// SetConversationHistory sets where earlier turns of a chat session are loaded from
+func (a *AIAgent) SetConversationHistory(history ConversationHistory) {
+	a.history = history
+}
+
+// SetUserProfileLookup sets where users' names and preferred locales are looked up for the system prompt
+func (a *AIAgent) SetUserProfileLookup(lookup UserProfileLookup) {
+	a.userProfiles = lookup
+}
+
 // ProcessQuery processes a user query and generates a comprehensive response
-func (a *AIAgent) ProcessQuery(ctx context.Context, userID string, query string) (*models.ChatResponse, error) {
+func (a *AIAgent) ProcessQuery(ctx context.Context, userID, sessionID, query string, opts QueryOptions) (*models.ChatResponse, error) {
 	startTime := time.Now()
+	promptOpts := a.systemPromptOptions(ctx, userID, opts)
+	language := promptOpts.Language
 
 	// Analyze query intent
-	intent := a.analyzeQueryIntent(query)
+	intent, keywords := matchQueryIntent(query)
 
 	// Gather relevant context based on intent
 	healthContext, ragContext, err := a.gatherContext(ctx, userID, query, intent)
@@ -43,69 +95,248 @@ func (a *AIAgent) ProcessQuery(ctx context.Context, userID string, query string)
 	}
 
 	// Generate response using LLM
-	response, err := a.generateResponse(ctx, query, healthContext, ragContext)
+	messages := a.buildMessages(query, a.conversationHistory(userID, sessionID), healthContext, ragContext, promptOpts)
+	genOpts := a.generateOptions(opts.Deterministic, opts.MaxTokens)
+	response, err := a.generateResponse(ctx, messages, genOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate response: %w", err)
 	}
 
 	// Enrich response with structured data
-	enrichedResponse := a.enrichResponse(response, healthContext, ragContext)
+	enrichedResponse := a.enrichResponse(response, healthContext, ragContext, a.customRanges(userID, healthContext), opts)
 	enrichedResponse.ProcessingTime = time.Since(startTime).Milliseconds()
+	enrichedResponse.Metadata.Language = language
+	a.setRoutingMetadata(&enrichedResponse.Metadata, intent, keywords, healthContext, ragContext)
+	enrichedResponse.Prompt = a.buildPromptRecord(messages, genOpts, intent, language, healthContext, ragContext)
 
 	return enrichedResponse, nil
 }
 
+// QueryStream is a chat answer being generated. Chunks delivers the LLM output as it arrives;
+// once the stream is done, Finish turns the assembled text into the enriched response.
+type QueryStream struct {
+	Chunks <-chan ai.StreamChunk
+	finish func(content string, tokensUsed int) *models.ChatResponse
+}
+
+// Finish returns the response for the streamed content, with the same sources, health data,
+// metadata and prompt record ProcessQuery adds
+func (s *QueryStream) Finish(content string, tokensUsed int) *models.ChatResponse {
+	return s.finish(content, tokensUsed)
+}
+
+// ProcessQueryStream processes a user query and streams the LLM response as it is generated
+func (a *AIAgent) ProcessQueryStream(ctx context.Context, userID, sessionID, query string, opts QueryOptions) (*QueryStream, error) {
+	startTime := time.Now()
+	promptOpts := a.systemPromptOptions(ctx, userID, opts)
+	language := promptOpts.Language
+
+	// Analyze query intent
+	intent, keywords := matchQueryIntent(query)
+
+	// Gather relevant context based on intent
+	healthContext, ragContext, err := a.gatherContext(ctx, userID, query, intent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather context: %w", err)
+	}
+
+	messages := a.buildMessages(query, a.conversationHistory(userID, sessionID), healthContext, ragContext, promptOpts)
+	genOpts := a.generateOptions(opts.Deterministic, opts.MaxTokens)
+
+	chunks, err := a.llmClient.GenerateResponseStream(ctx, messages, genOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate response stream: %w", err)
+	}
+
+	finish := func(content string, tokensUsed int) *models.ChatResponse {
+		response := &models.ChatResponse{
+			ID:         generateResponseID(),
+			Message:    content,
+			Timestamp:  time.Now(),
+			TokensUsed: tokensUsed,
+		}
+
+		enrichedResponse := a.enrichResponse(response, healthContext, ragContext, a.customRanges(userID, healthContext), opts)
+		enrichedResponse.ProcessingTime = time.Since(startTime).Milliseconds()
+		enrichedResponse.Metadata.Language = language
+		a.setRoutingMetadata(&enrichedResponse.Metadata, intent, keywords, healthContext, ragContext)
+		enrichedResponse.Prompt = a.buildPromptRecord(messages, genOpts, intent, language, healthContext, ragContext)
+		return enrichedResponse
+	}
+
+	return &QueryStream{Chunks: chunks, finish: finish}, nil
+}
+
 // QueryDocuments allows the AI to search through user documents
 func (a *AIAgent) QueryDocuments(ctx context.Context, userID, query string, limit int) ([]models.RAGContext, error) {
 	return a.ragService.QueryRelevantContext(ctx, userID, query, limit)
 }
 
+// intentKeywords lists the keywords for each intent in the order they are checked; the first
+// intent with a matching keyword wins
+var intentKeywords = []struct {
+	intent   models.QueryIntent
+	keywords []string
+}{
+	{models.IntentHealthQuery, []string{"blood pressure", "heart rate", "weight", "glucose", "cholesterol", "trend", "history"}},
+	{models.IntentDocumentQuery, []string{"document", "report", "lab", "test", "results", "prescription"}},
+	{models.IntentTrendAnalysis, []string{"trend", "pattern", "change", "over time", "improving", "getting worse"}},
+	{models.IntentRecommendation, []string{"recommend", "suggest", "advice", "should i", "what can i"}},
+}
+
 // analyzeQueryIntent determines the type and intent of the user's query
 func (a *AIAgent) analyzeQueryIntent(query string) models.QueryIntent {
+	intent, _ := matchQueryIntent(query)
+	return intent
+}
+
+// matchQueryIntent returns the query's intent and the keywords of that intent found in it
+func matchQueryIntent(query string) (models.QueryIntent, []string) {
 	queryLower := strings.ToLower(query)
 
-	// Health data queries
-	healthKeywords := []string{"blood pressure", "heart rate", "weight", "glucose", "cholesterol", "trend", "history"}
-	for _, keyword := range healthKeywords {
-		if strings.Contains(queryLower, keyword) {
-			return models.IntentHealthQuery
+	for _, candidate := range intentKeywords {
+		var matched []string
+		for _, keyword := range candidate.keywords {
+			if strings.Contains(queryLower, keyword) {
+				matched = append(matched, keyword)
+			}
+		}
+		if len(matched) > 0 {
+			return candidate.intent, matched
 		}
 	}
 
-	// Document queries
-	docKeywords := []string{"document", "report", "lab", "test", "results", "prescription"}
-	for _, keyword := range docKeywords {
-		if strings.Contains(queryLower, keyword) {
-			return models.IntentDocumentQuery
+	return models.IntentGeneralQuery, nil
+}
+
+// intentConfidence scores how sure the keyword match is: 0.3 when nothing matched and the query
+// fell back to general, rising from 0.6 by 0.1 per matched keyword up to 0.95
+func intentConfidence(keywords []string) float32 {
+	if len(keywords) == 0 {
+		return 0.3
+	}
+	confidence := 0.5 + 0.1*float32(len(keywords))
+	if confidence > 0.95 {
+		confidence = 0.95
+	}
+	return confidence
+}
+
+// setRoutingMetadata records the detected intent and the context actually gathered for a response
+func (a *AIAgent) setRoutingMetadata(metadata *models.Metadata, intent models.QueryIntent, keywords []string, healthContext []models.HealthContext, ragContext []models.RAGContext) {
+	route := a.contextPlan(intent)
+
+	metadata.Intent = string(intent)
+	metadata.Confidence = intentConfidence(keywords)
+
+	var tools []string
+	if len(healthContext) > 0 {
+		tools = append(tools, string(models.ToolFetchHealthData))
+	}
+	if len(ragContext) > 0 {
+		if route.DocumentScope == models.DocumentScopeDocuments {
+			tools = append(tools, string(models.ToolSearchDocuments))
+		} else {
+			tools = append(tools, string(models.ToolQueryRAGContext))
 		}
 	}
+	metadata.ToolsUsed = tools
+
+	// QueryType summarises which kinds of context informed the answer
+	switch {
+	case len(healthContext) > 0 && len(ragContext) > 0:
+		metadata.QueryType = "health_and_documents"
+	case len(healthContext) > 0:
+		metadata.QueryType = "health_data"
+	case len(ragContext) > 0:
+		metadata.QueryType = "documents"
+	default:
+		metadata.QueryType = "general"
+	}
+}
+
+// contextPlan decides which context sources are gathered for an intent
+func (a *AIAgent) contextPlan(intent models.QueryIntent) models.QueryRoute {
+	route := models.QueryRoute{DocumentScope: models.DocumentScopeNone}
+
+	if intent == models.IntentHealthQuery || intent == models.IntentTrendAnalysis || intent == models.IntentRecommendation {
+		route.UsesHealthData = true
+		route.UsesHealthSnapshots = a.cfg.RAGIncludeHealthData
+	}
+
+	switch intent {
+	case models.IntentDocumentQuery:
+		route.DocumentScope = models.DocumentScopeDocuments
+	case models.IntentGeneralQuery:
+		route.DocumentScope = models.DocumentScopeAll
+	}
+
+	return route
+}
+
+// AnalyzeQuery previews how a query would be routed: its intent, the keywords and metrics detected
+// in it, and which context would be gathered. It makes no embedding, retrieval or LLM calls.
+func (a *AIAgent) AnalyzeQuery(query string) *models.QueryAnalysis {
+	intent, keywords := matchQueryIntent(query)
+	if keywords == nil {
+		keywords = []string{}
+	}
+
+	return &models.QueryAnalysis{
+		Query:      query,
+		Intent:     intent,
+		Confidence: intentConfidence(keywords),
+		Keywords:   keywords,
+		Metrics:    detectMetricTypes(query),
+		Route:      a.contextPlan(intent),
+	}
+}
 
-	// Trend analysis
-	trendKeywords := []string{"trend", "pattern", "change", "over time", "improving", "getting worse"}
-	for _, keyword := range trendKeywords {
-		if strings.Contains(queryLower, keyword) {
-			return models.IntentTrendAnalysis
+// detectMetricTypes returns the supported metric types named in a query, by type key (with spaces
+// for underscores) or display name, sorted. Composite parts are skipped when the composite matched.
+func detectMetricTypes(query string) []string {
+	queryLower := strings.ToLower(query)
+
+	matched := make(map[string]bool)
+	for metricType, info := range models.SupportedMetrics {
+		if strings.Contains(queryLower, strings.ReplaceAll(metricType, "_", " ")) ||
+			strings.Contains(queryLower, strings.ToLower(info.Name)) {
+			matched[metricType] = true
 		}
 	}
 
-	// Recommendation queries
-	recommendationKeywords := []string{"recommend", "suggest", "advice", "should i", "what can i"}
-	for _, keyword := range recommendationKeywords {
-		if strings.Contains(queryLower, keyword) {
-			return models.IntentRecommendation
+	metrics := []string{}
+	for metricType := range matched {
+		if composite, ok := compositeParent(metricType); ok && matched[composite] {
+			continue
 		}
+		metrics = append(metrics, metricType)
 	}
+	sort.Strings(metrics)
+
+	return metrics
+}
 
-	return models.IntentGeneralQuery
+// compositeParent returns the composite metric a component metric belongs to
+func compositeParent(metricType string) (string, bool) {
+	for composite, components := range compositeComponents {
+		for _, component := range components {
+			if component == metricType {
+				return composite, true
+			}
+		}
+	}
+	return "", false
 }
 
 // gatherContext collects relevant health data and document context
 func (a *AIAgent) gatherContext(ctx context.Context, userID, query string, intent models.QueryIntent) ([]models.HealthContext, []models.RAGContext, error) {
 	var healthContext []models.HealthContext
 	var ragContext []models.RAGContext
+	route := a.contextPlan(intent)
 
 	// Gather health data context if relevant
-	if intent == models.IntentHealthQuery || intent == models.IntentTrendAnalysis || intent == models.IntentRecommendation {
+	if route.UsesHealthData {
 		latestMetrics, err := a.healthService.GetLatestMetrics(userID)
 		if err == nil {
 			for metricType, metric := range latestMetrics {
@@ -117,40 +348,56 @@ func (a *AIAgent) gatherContext(ctx context.Context, userID, query string, inten
 					Query:      query,
 				})
 			}
+
+			// Keep periodic health snapshots in the vector store (no-op unless enabled)
+			a.indexHealthSnapshot(userID, latestMetrics)
+		}
+	}
+
+	// Retrieve past snapshots semantically so historical readings can inform the answer. They are
+	// queried on their own, and documents without them, so no snapshot is returned twice.
+	if route.UsesHealthSnapshots || (route.DocumentScope == models.DocumentScopeAll && a.cfg.RAGIncludeHealthData) {
+		snapshots, err := a.ragService.QueryHealthSnapshots(ctx, userID, query, 3)
+		if err == nil {
+			ragContext = append(ragContext, snapshots...)
 		}
 	}
 
 	// Gather document context if relevant
-	if intent == models.IntentDocumentQuery || intent == models.IntentGeneralQuery {
-		contexts, err := a.ragService.QueryRelevantContext(ctx, userID, query, 5)
+	if route.DocumentScope == models.DocumentScopeDocuments || route.DocumentScope == models.DocumentScopeAll {
+		contexts, err := a.ragService.QueryDocumentOnlyContext(ctx, userID, query, 5)
 		if err == nil {
-			ragContext = contexts
+			ragContext = append(ragContext, contexts...)
 		}
 	}
 
 	return healthContext, ragContext, nil
 }
 
-// generateResponse creates an AI response using the LLM
-func (a *AIAgent) generateResponse(ctx context.Context, query string, healthContext []models.HealthContext, ragContext []models.RAGContext) (*models.ChatResponse, error) {
-	// Build context strings
-	healthContextStr := a.buildHealthContextString(healthContext)
-	ragContextStr := a.buildRAGContextString(ragContext)
+// snapshotIndexTimeout bounds embedding and storing one health snapshot in the background
+const snapshotIndexTimeout = 30 * time.Second
 
-	// Create messages for the LLM
-	messages := []ai.ChatMessage{
-		{
-			Role:    "system",
-			Content: ai.GenerateSystemPrompt(),
-		},
-		{
-			Role:    "user",
-			Content: ai.GenerateRAGPrompt(query, healthContextStr, ragContextStr),
-		},
+// indexHealthSnapshot stores a snapshot of the latest metrics in the background, so replies don't
+// wait on the embedding and upsert. The RAG service throttles snapshots to one per interval.
+func (a *AIAgent) indexHealthSnapshot(userID string, metrics map[string]models.LatestMetric) {
+	if !a.cfg.RAGIncludeHealthData || len(metrics) == 0 {
+		return
 	}
 
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), snapshotIndexTimeout)
+		defer cancel()
+
+		if _, err := a.ragService.IndexHealthSnapshot(ctx, userID, metrics); err != nil {
+			a.logger.Warn("Failed to index health snapshot", zap.String("user_id", userID), zap.Error(err))
+		}
+	}()
+}
+
+// generateResponse creates an AI response from the assembled LLM messages
+func (a *AIAgent) generateResponse(ctx context.Context, messages []ai.ChatMessage, genOpts ai.GenerateOptions) (*models.ChatResponse, error) {
 	// Generate response
-	llmResponse, err := a.llmClient.GenerateResponse(ctx, messages, a.cfg.MaxTokens, a.cfg.Temperature)
+	llmResponse, err := a.llmClient.GenerateResponse(ctx, messages, genOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -163,8 +410,171 @@ func (a *AIAgent) generateResponse(ctx context.Context, query string, healthCont
 	}, nil
 }
 
+// buildMessages creates the system and user messages for the LLM
+func (a *AIAgent) buildMessages(query string, history []ai.ChatMessage, healthContext []models.HealthContext, ragContext []models.RAGContext, promptOpts ai.SystemPromptOptions) []ai.ChatMessage {
+	// Build context strings
+	healthContextStr := a.buildHealthContextString(healthContext)
+	ragContextStr := a.buildRAGContextString(ragContext)
+
+	systemPrompt := ai.RenderSystemPrompt(promptOpts)
+	if a.cfg.PromptGuardEnabled {
+		systemPrompt += ai.GenerateDocumentGuardInstructions()
+	}
+
+	// Earlier turns go between the system prompt and the new question, oldest first
+	messages := make([]ai.ChatMessage, 0, len(history)+2)
+	messages = append(messages, ai.ChatMessage{
+		Role:    "system",
+		Content: systemPrompt,
+	})
+	messages = append(messages, history...)
+	messages = append(messages, ai.ChatMessage{
+		Role:    "user",
+		Content: ai.GenerateRAGPromptWithLanguage(query, healthContextStr, ragContextStr, promptOpts.Language),
+	})
+
+	return messages
+}
+
+// conversationHistory returns the session's most recent turns as LLM messages, oldest first.
+// Up to ChatHistoryMessages messages are loaded, then the oldest are dropped until the rest fit in
+// MaxTokens. History always starts with a user turn. Without a session or history source it is empty.
+func (a *AIAgent) conversationHistory(userID, sessionID string) []ai.ChatMessage {
+	if sessionID == "" || a.history == nil || a.cfg.ChatHistoryMessages <= 0 {
+		return nil
+	}
+
+	messages, err := a.history.RecentMessages(userID, sessionID, a.cfg.ChatHistoryMessages)
+	if err != nil {
+		// Answering without history beats failing the question
+		a.logger.Warn("Failed to load conversation history",
+			zap.String("user_id", userID),
+			zap.String("session_id", sessionID),
+			zap.Error(err))
+		return nil
+	}
+
+	// Walk back from the newest message while the token budget allows
+	start := len(messages)
+	tokens := 0
+	for start > 0 {
+		cost := fileprocessor.EstimateTokens(messages[start-1].Content)
+		if tokens+cost > a.cfg.MaxTokens {
+			break
+		}
+		tokens += cost
+		start--
+	}
+	for start < len(messages) && messages[start].Role != "user" {
+		start++
+	}
+
+	history := make([]ai.ChatMessage, 0, len(messages)-start)
+	for _, message := range messages[start:] {
+		history = append(history, ai.ChatMessage{Role: message.Role, Content: message.Content})
+	}
+
+	return history
+}
+
+// generateOptions returns the LLM sampling settings for a request. Deterministic requests use
+// temperature 0 and the configured seed instead of the global temperature. A requested token cap
+// is clamped to the configured maximum.
+func (a *AIAgent) generateOptions(deterministic bool, maxTokens int) ai.GenerateOptions {
+	if maxTokens <= 0 || maxTokens > a.cfg.MaxTokens {
+		maxTokens = a.cfg.MaxTokens
+	}
+
+	if deterministic {
+		return ai.DeterministicOptions(maxTokens, a.cfg.DeterministicSeed)
+	}
+	return ai.GenerateOptions{
+		MaxTokens:   maxTokens,
+		Temperature: a.cfg.Temperature,
+	}
+}
+
+// buildPromptRecord captures the exact messages and settings of an LLM call for auditing.
+// It returns nil when prompt storage is disabled.
+func (a *AIAgent) buildPromptRecord(messages []ai.ChatMessage, genOpts ai.GenerateOptions, intent models.QueryIntent, language string, healthContext []models.HealthContext, ragContext []models.RAGContext) *models.PromptRecord {
+	if !a.cfg.StoreChatPrompts {
+		return nil
+	}
+
+	promptMessages := make([]models.PromptMessage, 0, len(messages))
+	for _, message := range messages {
+		promptMessages = append(promptMessages, models.PromptMessage{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+
+	return &models.PromptRecord{
+		Messages:      promptMessages,
+		Provider:      a.cfg.LLMProvider,
+		Intent:        string(intent),
+		Language:      language,
+		MaxTokens:     genOpts.MaxTokens,
+		Temperature:   genOpts.Temperature,
+		Seed:          genOpts.Seed,
+		HealthContext: healthContext,
+		RAGContext:    ragContext,
+	}
+}
+
+// systemPromptOptions fills the system prompt fields. The persona comes from opts or the config;
+// the locale from opts, the user's profile or the config; and the response language from opts, the
+// language of that locale or the configured default.
+func (a *AIAgent) systemPromptOptions(ctx context.Context, userID string, opts QueryOptions) ai.SystemPromptOptions {
+	promptOpts := ai.SystemPromptOptions{
+		Persona: a.cfg.AssistantPersona,
+		Locale:  a.cfg.AssistantLocale,
+	}
+	if opts.Persona != "" {
+		promptOpts.Persona = opts.Persona
+	}
+
+	locale := opts.Locale
+	if a.userProfiles != nil {
+		profile, err := a.userProfiles.GetUserPromptProfile(ctx, userID)
+		if err != nil {
+			// The prompt works without the profile, so don't fail the query
+			a.logger.Debug("Failed to look up user profile for prompt", zap.String("user_id", userID), zap.Error(err))
+		} else if profile != nil {
+			promptOpts.FirstName = profile.FirstName
+			if locale == "" {
+				locale = profile.Locale
+			}
+		}
+	}
+	if locale != "" {
+		promptOpts.Locale = locale
+	}
+
+	promptOpts.Language = a.resolveLanguage(opts.Language)
+	if _, ok := ai.NormalizeLanguage(opts.Language); !ok {
+		// No explicit language, so answer in the language of the chosen locale when supported
+		if language, ok := ai.NormalizeLanguage(locale); ok {
+			promptOpts.Language = language
+		}
+	}
+
+	return promptOpts
+}
+
+// resolveLanguage returns the requested response language, falling back to the configured default
+func (a *AIAgent) resolveLanguage(requested string) string {
+	if language, ok := ai.NormalizeLanguage(requested); ok {
+		return language
+	}
+	if language, ok := ai.NormalizeLanguage(a.cfg.DefaultResponseLanguage); ok {
+		return language
+	}
+	return "en"
+}
+
 // enrichResponse adds structured data to the response
-func (a *AIAgent) enrichResponse(response *models.ChatResponse, healthContext []models.HealthContext, ragContext []models.RAGContext) *models.ChatResponse {
+func (a *AIAgent) enrichResponse(response *models.ChatResponse, healthContext []models.HealthContext, ragContext []models.RAGContext, customRanges map[string]models.Range, opts QueryOptions) *models.ChatResponse {
 	// Add health data references
 	var healthData []models.HealthInfo
 	for _, hc := range healthContext {
@@ -173,7 +583,7 @@ func (a *AIAgent) enrichResponse(response *models.ChatResponse, healthContext []
 			Value:      hc.Value,
 			Unit:       hc.Unit,
 			Timestamp:  hc.Timestamp,
-			IsNormal:   a.isHealthValueNormal(hc.MetricType, hc.Value),
+			IsNormal:   a.isHealthValueNormal(hc.MetricType, hc.Value, customRanges),
 		}
 		healthData = append(healthData, healthInfo)
 	}
@@ -183,20 +593,44 @@ func (a *AIAgent) enrichResponse(response *models.ChatResponse, healthContext []
 	for _, rc := range ragContext {
 		source := models.Source{
 			DocumentID:   rc.DocumentID,
-			DocumentName: "Health Document",
+			DocumentName: documentName(rc, "Health Document"),
 			ChunkID:      rc.ChunkID,
+			PageNumber:   rc.PageNumber,
 			Content:      rc.Content,
 			Relevance:    rc.Score,
 		}
 		sources = append(sources, source)
 	}
 
+	// Highest relevance first so trimming keeps the most useful sources
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Relevance > sources[j].Relevance
+	})
+
 	response.HealthData = healthData
-	response.Sources = sources
+	response.AllSources = sources
+	response.Sources = capSlice(sources, resolveLimit(opts.MaxSources, a.cfg.MaxSourcesReturned))
+	response.Suggestions = capSlice(response.Suggestions, resolveLimit(opts.MaxSuggestions, a.cfg.MaxSuggestions))
 
 	return response
 }
 
+// resolveLimit returns the per-request override when set, otherwise the configured default
+func resolveLimit(override *int, fallback int) int {
+	if override != nil && *override >= 0 {
+		return *override
+	}
+	return fallback
+}
+
+// capSlice returns at most limit elements of items; a negative limit means no cap
+func capSlice[T any](items []T, limit int) []T {
+	if limit < 0 || len(items) <= limit {
+		return items
+	}
+	return items[:limit]
+}
+
 // buildHealthContextString creates a formatted string from health context
 func (a *AIAgent) buildHealthContextString(healthContext []models.HealthContext) string {
 	if len(healthContext) == 0 {
@@ -227,46 +661,171 @@ func (a *AIAgent) buildRAGContextString(ragContext []models.RAGContext) string {
 		if i >= 3 { // Limit to top 3 contexts
 			break
 		}
-		contextStr.WriteString(fmt.Sprintf("- Document %s: %s\n", rc.DocumentID, rc.Content[:min(200, len(rc.Content))]))
+
+		label := "Document " + rc.DocumentID
+		if rc.DocumentID == "" {
+			// Health snapshots are not tied to a document
+			label = "Health snapshot"
+		}
+		excerpt := rc.Content[:min(200, len(rc.Content))]
+
+		if !a.cfg.PromptGuardEnabled {
+			contextStr.WriteString(fmt.Sprintf("- %s: %s\n", label, excerpt))
+			continue
+		}
+
+		// Scan the full chunk, not just the excerpt, so phrases past the cut are still caught
+		flagged := false
+		if a.cfg.PromptGuardScan {
+			if matches := ai.DetectInjection(rc.Content); len(matches) > 0 {
+				flagged = true
+				a.logger.Warn("Possible prompt injection in retrieved document content",
+					zap.String("document_id", rc.DocumentID),
+					zap.String("chunk_id", rc.ChunkID),
+					zap.Strings("matches", matches))
+			}
+		}
+		contextStr.WriteString(ai.WrapDocumentContent(label, excerpt, flagged))
 	}
 
 	return contextStr.String()
 }
 
-// isHealthValueNormal checks if a health value is within normal range
-func (a *AIAgent) isHealthValueNormal(metricType string, value float64) bool {
-	if metricInfo, exists := models.SupportedMetrics[metricType]; exists {
-		return metricInfo.IsWithinNormalRange(value)
+// isHealthValueNormal checks if a health value is within the user's normal range, preferring
+// their custom range over the default
+func (a *AIAgent) isHealthValueNormal(metricType string, value float64, customRanges map[string]models.Range) bool {
+	if _, exists := models.SupportedMetrics[metricType]; exists {
+		return isWithinEffectiveRange(metricType, value, customRanges)
 	}
 	return true // Default to normal if unknown metric
 }
 
-// GenerateHealthInsights generates personalized health insights
-func (a *AIAgent) GenerateHealthInsights(ctx context.Context, userID string) ([]models.Metadata, error) {
+// customRanges loads the user's normal-range overrides when there is health data to classify.
+// On failure the default ranges are used.
+func (a *AIAgent) customRanges(userID string, healthContext []models.HealthContext) map[string]models.Range {
+	if len(healthContext) == 0 {
+		return nil
+	}
+
+	customRanges, err := a.healthService.GetCustomRanges(userID)
+	if err != nil {
+		a.logger.Warn("Failed to load custom ranges", zap.String("user_id", userID), zap.Error(err))
+		return nil
+	}
+	return customRanges
+}
+
+// GenerateHealthInsights generates personalized health insights. Cached insights are returned while
+// they are within the TTL and the user's health data hasn't materially changed; forceRefresh
+// always re-runs the agent.
+func (a *AIAgent) GenerateHealthInsights(ctx context.Context, userID string, forceRefresh bool) (*models.HealthInsights, error) {
 	// Get health summary
 	summary, err := a.healthService.GetHealthSummary(userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get health summary: %w", err)
 	}
 
-	// Generate insights using AI
-	query := "Generate personalized health insights based on my recent health data and trends"
+	fingerprint := insightsFingerprint(summary)
+	if !forceRefresh {
+		cached, err := a.insightsCache.Get(userID, fingerprint)
+		if err != nil {
+			// A cache read failure shouldn't stop insights from being generated
+			a.logger.Warn("Failed to read cached insights", zap.String("user_id", userID), zap.Error(err))
+		} else if cached != nil {
+			return cached, nil
+		}
+	}
+
+	// Generate insights using AI. There's no document context for insights.
 	healthContext := a.convertSummaryToHealthContext(summary)
-	ragContext := []models.RAGContext{} // No document context for insights
+	messages := []ai.ChatMessage{
+		{Role: "system", Content: ai.RenderSystemPrompt(a.systemPromptOptions(ctx, userID, QueryOptions{}))},
+		{Role: "user", Content: ai.GenerateInsightsPrompt(a.buildHealthContextString(healthContext))},
+	}
 
-	_, err = a.generateResponse(ctx, query, healthContext, ragContext)
+	response, err := a.generateResponse(ctx, messages, a.generateOptions(false, 0))
 	if err != nil {
 		return nil, err
 	}
 
-	// Return placeholder insights
-	return []models.Metadata{
-		{
-			QueryType:  "insights",
-			Intent:     "generate_insights",
-			Confidence: 0.8,
-		},
-	}, nil
+	parsed, err := parseInsights(response.Message)
+	if err != nil {
+		// Keep the free-text answer rather than returning nothing
+		a.logger.Warn("Failed to parse generated insights", zap.String("user_id", userID), zap.Error(err))
+		parsed = []models.Insight{{
+			Type:        "summary",
+			Title:       "Health Insights",
+			Description: strings.TrimSpace(response.Message),
+			Confidence:  insightConfidenceLow,
+			Action:      "continue_monitoring",
+		}}
+	}
+
+	insights := &models.HealthInsights{
+		UserID:          userID,
+		Summary:         response.Message,
+		Insights:        parsed,
+		GeneratedAt:     time.Now().UTC(),
+		DataFingerprint: fingerprint,
+	}
+
+	if err := a.insightsCache.Put(insights); err != nil {
+		a.logger.Warn("Failed to cache insights", zap.String("user_id", userID), zap.Error(err))
+	}
+
+	return insights, nil
+}
+
+// maxInsights caps how many parsed insights are kept
+const maxInsights = 5
+
+// Insight confidence levels
+const (
+	insightConfidenceHigh   = "high"
+	insightConfidenceMedium = "medium"
+	insightConfidenceLow    = "low"
+)
+
+// parseInsights extracts the JSON array of insights from an LLM response, tolerating surrounding
+// text such as a Markdown code fence. Insights without a title or description are dropped and an
+// unrecognised confidence becomes medium.
+func parseInsights(response string) ([]models.Insight, error) {
+	start := strings.Index(response, "[")
+	end := strings.LastIndex(response, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in response")
+	}
+
+	var raw []models.Insight
+	if err := json.Unmarshal([]byte(response[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("invalid insights JSON: %w", err)
+	}
+
+	insights := make([]models.Insight, 0, len(raw))
+	for _, insight := range raw {
+		insight.Title = strings.TrimSpace(insight.Title)
+		insight.Description = strings.TrimSpace(insight.Description)
+		if insight.Title == "" || insight.Description == "" {
+			continue
+		}
+
+		insight.Confidence = strings.ToLower(strings.TrimSpace(insight.Confidence))
+		switch insight.Confidence {
+		case insightConfidenceHigh, insightConfidenceMedium, insightConfidenceLow:
+		default:
+			insight.Confidence = insightConfidenceMedium
+		}
+
+		insights = append(insights, insight)
+		if len(insights) == maxInsights {
+			break
+		}
+	}
+
+	if len(insights) == 0 {
+		return nil, fmt.Errorf("response contained no usable insights")
+	}
+	return insights, nil
 }
 
 // convertSummaryToHealthContext converts health summary to health context
@@ -291,9 +850,13 @@ func generateResponseID() string {
 // randomString generates a random string of given length
 func randomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	random := make([]byte, length)
+	if _, err := rand.Read(random); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
 	result := make([]byte, length)
 	for i := range result {
-		result[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+		result[i] = charset[int(random[i])%len(charset)]
 	}
 	return string(result)
 }
@@ -21,18 +21,50 @@ func NewAIClientFactory(cfg *config.Config) *AIClientFactory {
 	}
 }
 
-// CreateLLMClient creates a new LLM client based on the provider
+// CreateLLMClient creates a new LLM client based on the provider, recording its token usage
 func (f *AIClientFactory) CreateLLMClient() (ai.LLMClient, error) {
+	var client ai.LLMClient
+	var err error
+
 	switch f.cfg.LLMProvider {
 	case "sonar":
-		return llms.NewSonarClient(f.cfg)
+		client, err = llms.NewSonarClient(f.cfg)
+	case "anthropic":
+		client, err = llms.NewAnthropicClient(f.cfg)
+	case "openai":
+		client, err = llms.NewOpenAIClient(f.cfg)
 	default:
 		return nil, fmt.Errorf("unsupported LLM provider: %s", f.cfg.LLMProvider)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &meteredLLMClient{LLMClient: client}, nil
 }
 
-// CreateEmbeddingClient creates a new embedding client
+// CreateEmbeddingClient creates a new embedding client, wrapped in an LRU cache when
+// EmbeddingCacheSize is set
 func (f *AIClientFactory) CreateEmbeddingClient() (ai.EmbeddingClient, error) {
 	// For now, we only support OpenAI for embeddings
-	return embeddings.NewOpenAIClient(f.cfg)
+	openAIClient, err := embeddings.NewOpenAIClient(f.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Metered inside the cache, so only embeddings actually generated are counted
+	client := &meteredEmbeddingClient{EmbeddingClient: openAIClient}
+	if f.cfg.EmbeddingCacheSize > 0 {
+		return embeddings.NewCachingEmbeddingClient(client, f.cfg.EmbeddingCacheSize), nil
+	}
+	return client, nil
+}
+
+// ExpectedEmbeddingDimension returns the dimension the embedding client produces: EmbeddingDimension
+// when set, otherwise the known dimension of EmbeddingModel. known is false for unknown models.
+func ExpectedEmbeddingDimension(cfg *config.Config) (dimension int, known bool) {
+	if cfg.EmbeddingDimension > 0 {
+		return cfg.EmbeddingDimension, true
+	}
+	return embeddings.ModelDimension(cfg.EmbeddingModel)
 }
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+
+	"health-dashboard-backend/internal/metrics"
+	"health-dashboard-backend/pkg/ai"
+)
+
+// meteredLLMClient records the tokens used by an LLM client's responses
+type meteredLLMClient struct {
+	ai.LLMClient
+}
+
+// GenerateResponse generates a response and records its token usage
+func (m *meteredLLMClient) GenerateResponse(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (*ai.ChatResponse, error) {
+	response, err := m.LLMClient.GenerateResponse(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.LLMTokensUsed.Add(float64(response.TokensUsed))
+	return response, nil
+}
+
+// GenerateResponseStream forwards the stream, recording the token usage reported by its final chunk
+func (m *meteredLLMClient) GenerateResponseStream(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (<-chan ai.StreamChunk, error) {
+	chunks, err := m.LLMClient.GenerateResponseStream(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	metered := make(chan ai.StreamChunk)
+	go func() {
+		defer close(metered)
+		for chunk := range chunks {
+			if chunk.Done {
+				metrics.LLMTokensUsed.Add(float64(chunk.TokensUsed))
+			}
+			select {
+			case metered <- chunk:
+			case <-ctx.Done():
+				return // The reader has gone; the provider stream stops on the same context
+			}
+		}
+	}()
+
+	return metered, nil
+}
+
+// meteredEmbeddingClient counts the embeddings generated by an embedding client
+type meteredEmbeddingClient struct {
+	ai.EmbeddingClient
+}
+
+// GenerateEmbedding generates an embedding and counts it
+func (m *meteredEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	embedding, err := m.EmbeddingClient.GenerateEmbedding(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.EmbeddingsGenerated.Inc()
+	return embedding, nil
+}
+
+// GenerateEmbeddings generates a batch of embeddings and counts them
+func (m *meteredEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, err := m.EmbeddingClient.GenerateEmbeddings(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.EmbeddingsGenerated.Add(float64(len(embeddings)))
+	return embeddings, nil
+}
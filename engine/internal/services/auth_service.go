@@ -7,6 +7,8 @@ import (
 	"github.com/clerk/clerk-sdk-go/v2"
 	"github.com/clerk/clerk-sdk-go/v2/user"
 	"go.uber.org/zap"
+
+	"health-dashboard-backend/pkg/ai"
 )
 
 // AuthService handles Clerk authentication and user management
@@ -28,6 +30,31 @@ func (s *AuthService) GetUserProfile(ctx context.Context, userID string) (*clerk
 	return user.Get(ctx, userID)
 }
 
+// GetUserPromptProfile returns the user's first name and the preferred locale stored under
+// "locale" in their public metadata
+func (s *AuthService) GetUserPromptProfile(ctx context.Context, userID string) (*UserPromptProfile, error) {
+	profile, err := s.GetUserProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	promptProfile := &UserPromptProfile{}
+	if profile.FirstName != nil {
+		promptProfile.FirstName = *profile.FirstName
+	}
+
+	var metadata struct {
+		Locale string `json:"locale"`
+	}
+	if len(profile.PublicMetadata) > 0 && json.Unmarshal(profile.PublicMetadata, &metadata) == nil {
+		if locale, ok := ai.NormalizeLocale(metadata.Locale); ok {
+			promptProfile.Locale = locale
+		}
+	}
+
+	return promptProfile, nil
+}
+
 // UpdateUserMetadata updates a user's public metadata
 func (s *AuthService) UpdateUserMetadata(ctx context.Context, userID string, metadata map[string]interface{}) (*clerk.User, error) {
 	s.logger.Debug("Updating user metadata", zap.String("user_id", userID))
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// ErrInsufficientData is returned when too few readings are available to produce a result
+var ErrInsufficientData = errors.New("insufficient data")
+
+// minCardioInputs is how many of the five factors must have a reading before a flag is produced
+const minCardioInputs = 3
+
+// cardioMaxReadingAge is how old a reading may be and still count towards the flag
+const cardioMaxReadingAge = 365 * 24 * time.Hour
+
+// cardioFactor scores one input of the cardiovascular flag from its readings, which are in
+// metricTypes order. It returns a category label and 0-2 points.
+type cardioFactor struct {
+	name        string
+	metricTypes []string
+	rule        string
+	score       func(values []float64) (category string, points int)
+}
+
+// cardioFactors are the inputs to the cardiovascular flag. Blood pressure categories follow the
+// 2017 ACC/AHA guideline; the lipid and BMI cut-offs are the commonly used adult thresholds.
+var cardioFactors = []cardioFactor{
+	{
+		name:        "blood_pressure",
+		metricTypes: []string{"blood_pressure_systolic", "blood_pressure_diastolic"},
+		rule:        "normal <120/<80 = 0; elevated 120-129/<80 = 1; stage 1 130-139 or 80-89 = 1; stage 2 >=140 or >=90 = 2",
+		score: func(values []float64) (string, int) {
+			systolic, diastolic := values[0], values[1]
+			switch {
+			case systolic >= 140 || diastolic >= 90:
+				return "stage_2_hypertension", 2
+			case systolic >= 130 || diastolic >= 80:
+				return "stage_1_hypertension", 1
+			case systolic >= 120:
+				return "elevated", 1
+			default:
+				return "normal", 0
+			}
+		},
+	},
+	{
+		name:        "resting_heart_rate",
+		metricTypes: []string{"heart_rate"},
+		rule:        "<=80 bpm = 0; 81-100 bpm = 1; >100 bpm = 2",
+		score: func(values []float64) (string, int) {
+			switch {
+			case values[0] > 100:
+				return "high", 2
+			case values[0] > 80:
+				return "upper_normal", 1
+			default:
+				return "normal", 0
+			}
+		},
+	},
+	{
+		name:        "bmi",
+		metricTypes: []string{"bmi"},
+		rule:        "<25 = 0; 25-29.9 (overweight) = 1; >=30 (obese) = 2",
+		score: func(values []float64) (string, int) {
+			switch {
+			case values[0] >= 30:
+				return "obese", 2
+			case values[0] >= 25:
+				return "overweight", 1
+			default:
+				return "normal", 0
+			}
+		},
+	},
+	{
+		name:        "ldl",
+		metricTypes: []string{"cholesterol_ldl"},
+		rule:        "<130 mg/dL = 0; 130-159 mg/dL (borderline high) = 1; >=160 mg/dL (high) = 2",
+		score: func(values []float64) (string, int) {
+			switch {
+			case values[0] >= 160:
+				return "high", 2
+			case values[0] >= 130:
+				return "borderline_high", 1
+			default:
+				return "optimal_or_near", 0
+			}
+		},
+	},
+	{
+		name:        "hdl",
+		metricTypes: []string{"cholesterol_hdl"},
+		rule:        ">=60 mg/dL = 0; 40-59 mg/dL = 1; <40 mg/dL (low) = 2",
+		score: func(values []float64) (string, int) {
+			switch {
+			case values[0] < 40:
+				return "low", 2
+			case values[0] < 60:
+				return "acceptable", 1
+			default:
+				return "protective", 0
+			}
+		},
+	},
+}
+
+// GetCardioRisk combines the latest blood pressure, resting heart rate, BMI and cholesterol readings
+// into a low/moderate/elevated flag. Each available factor scores 0-2 points; the flag is elevated
+// at half the available points or more, moderate at a quarter or more or when any single factor
+// scores 2, and low otherwise. Readings older than a year are treated as missing.
+func (h *HealthService) GetCardioRisk(userID string) (*models.CardioRisk, error) {
+	latest, err := h.db.GetLatestHealthMetrics(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest health metrics: %w", err)
+	}
+
+	now := time.Now()
+	risk := &models.CardioRisk{
+		Inputs:      make([]models.CardioRiskInput, 0, len(cardioFactors)),
+		Missing:     []string{},
+		Disclaimer:  models.CardioRiskDisclaimer,
+		EvaluatedAt: now.UTC(),
+	}
+
+	worstPoints := 0
+	for _, factor := range cardioFactors {
+		input := models.CardioRiskInput{
+			Factor:      factor.name,
+			MetricTypes: factor.metricTypes,
+			Unit:        models.SupportedMetrics[factor.metricTypes[0]].Unit,
+			Rule:        factor.rule,
+		}
+
+		values := make([]float64, 0, len(factor.metricTypes))
+		var oldest *time.Time
+		for _, metricType := range factor.metricTypes {
+			metric, exists := latest[metricType]
+			if !exists || now.Sub(metric.Timestamp) > cardioMaxReadingAge {
+				break
+			}
+			values = append(values, metric.Value)
+			if oldest == nil || metric.Timestamp.Before(*oldest) {
+				timestamp := metric.Timestamp
+				oldest = &timestamp
+			}
+		}
+
+		if len(values) < len(factor.metricTypes) {
+			risk.Missing = append(risk.Missing, factor.name)
+			risk.Inputs = append(risk.Inputs, input)
+			continue
+		}
+
+		input.Available = true
+		input.Values = values
+		input.MeasuredAt = oldest
+		input.Category, input.Points = factor.score(values)
+
+		risk.Points += input.Points
+		risk.MaxPoints += 2
+		if input.Points > worstPoints {
+			worstPoints = input.Points
+		}
+		risk.Inputs = append(risk.Inputs, input)
+	}
+
+	available := len(cardioFactors) - len(risk.Missing)
+	if available < minCardioInputs {
+		return risk, fmt.Errorf("%w: %d of %d cardiovascular inputs available, at least %d required",
+			ErrInsufficientData, available, len(cardioFactors), minCardioInputs)
+	}
+
+	switch {
+	case risk.Points*2 >= risk.MaxPoints:
+		risk.Flag = models.CardioRiskElevated
+	case risk.Points*4 >= risk.MaxPoints || worstPoints == 2:
+		risk.Flag = models.CardioRiskModerate
+	default:
+		risk.Flag = models.CardioRiskLow
+	}
+
+	return risk, nil
+}
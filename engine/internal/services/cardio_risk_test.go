@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+func TestGetCardioRiskFlags(t *testing.T) {
+	recent := time.Now().UTC().Add(-time.Hour)
+
+	for _, tc := range []struct {
+		name     string
+		readings map[string]float64
+		wantFlag string
+		wantMiss []string
+	}{
+		{
+			name: "all favourable",
+			readings: map[string]float64{"blood_pressure_systolic": 115, "blood_pressure_diastolic": 75,
+				"heart_rate": 62, "bmi": 22, "cholesterol_ldl": 100, "cholesterol_hdl": 65},
+			wantFlag: models.CardioRiskLow,
+		},
+		{
+			name:     "one factor at its worst",
+			readings: map[string]float64{"heart_rate": 62, "bmi": 22, "cholesterol_ldl": 170, "cholesterol_hdl": 65},
+			wantFlag: models.CardioRiskModerate,
+			wantMiss: []string{"blood_pressure"},
+		},
+		{
+			name: "half the points",
+			readings: map[string]float64{"blood_pressure_systolic": 145, "blood_pressure_diastolic": 85,
+				"heart_rate": 85, "bmi": 27},
+			wantFlag: models.CardioRiskElevated,
+			wantMiss: []string{"ldl", "hdl"},
+		},
+	} {
+		service, db, _ := newTestHealthService(t, nil)
+		for metricType, value := range tc.readings {
+			metric := &models.HealthMetric{UserID: "user-1", Type: metricType, Value: value,
+				Unit: models.SupportedMetrics[metricType].Unit, Timestamp: recent}
+			if err := db.PutHealthMetric(metric); err != nil {
+				t.Fatalf("put metric: %v", err)
+			}
+		}
+
+		risk, err := service.GetCardioRisk("user-1")
+		if err != nil {
+			t.Fatalf("%s: get cardio risk: %v", tc.name, err)
+		}
+		if risk.Flag != tc.wantFlag {
+			t.Errorf("%s: flag = %q (%d of %d points), want %q", tc.name, risk.Flag, risk.Points, risk.MaxPoints, tc.wantFlag)
+		}
+		if len(risk.Missing) != len(tc.wantMiss) {
+			t.Errorf("%s: missing = %v, want %v", tc.name, risk.Missing, tc.wantMiss)
+		}
+		if len(risk.Inputs) != len(cardioFactors) || risk.Disclaimer == "" {
+			t.Errorf("%s: want every factor reported with the disclaimer, got %+v", tc.name, risk)
+		}
+	}
+}
+
+func TestGetCardioRiskRefusesWithTooFewInputs(t *testing.T) {
+	service, db, _ := newTestHealthService(t, nil)
+
+	// Only the systolic half of blood pressure, and a BMI too old to count
+	for _, metric := range []*models.HealthMetric{
+		{UserID: "user-1", Type: "heart_rate", Value: 70, Unit: "bpm", Timestamp: time.Now().UTC().Add(-time.Hour)},
+		{UserID: "user-1", Type: "cholesterol_ldl", Value: 120, Unit: "mg/dL", Timestamp: time.Now().UTC().Add(-time.Hour)},
+		{UserID: "user-1", Type: "blood_pressure_systolic", Value: 130, Unit: "mmHg", Timestamp: time.Now().UTC().Add(-time.Hour)},
+		{UserID: "user-1", Type: "bmi", Value: 24, Unit: "kg/m²", Timestamp: time.Now().UTC().Add(-2 * cardioMaxReadingAge)},
+	} {
+		if err := db.PutHealthMetric(metric); err != nil {
+			t.Fatalf("put metric: %v", err)
+		}
+	}
+
+	risk, err := service.GetCardioRisk("user-1")
+	if !errors.Is(err, ErrInsufficientData) {
+		t.Fatalf("err = %v, want ErrInsufficientData", err)
+	}
+	if risk.Flag != "" || len(risk.Missing) != 3 {
+		t.Errorf("got flag %q with missing %v, want no flag and blood_pressure, bmi and hdl missing", risk.Flag, risk.Missing)
+	}
+}
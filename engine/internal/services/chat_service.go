@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/models"
+)
+
+// ErrMessageNotFound is returned when a chat message doesn't exist for the user/session
+var ErrMessageNotFound = errors.New("chat message not found")
+
+// ErrPromptNotStored is returned when a message exists but has no stored prompt, e.g. user
+// messages or replies saved before prompt storage was enabled
+var ErrPromptNotStored = errors.New("no prompt stored for chat message")
+
+// ChatService handles chat history persistence
+type ChatService struct {
+	db  *database.DynamoDBClient
+	cfg *config.Config
+}
+
+// NewChatService creates a new chat service
+func NewChatService(db *database.DynamoDBClient, cfg *config.Config) *ChatService {
+	return &ChatService{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// SaveMessage stores a single chat message for a session, set to expire after ChatRetentionDays
+func (s *ChatService) SaveMessage(sessionID string, message *models.ChatMessage) error {
+	message.SessionID = sessionID
+	message.SetRetention(time.Duration(s.cfg.ChatRetentionDays) * 24 * time.Hour)
+
+	if err := s.db.PutChatMessage(message); err != nil {
+		return fmt.Errorf("failed to store chat message: %w", err)
+	}
+
+	return nil
+}
+
+// SaveExchange stores a user message and the assistant's reply for a session
+func (s *ChatService) SaveExchange(userID, sessionID, userContent string, response *models.ChatResponse) error {
+	userMsg := models.NewChatMessage(userID, "user", userContent)
+	if err := s.SaveMessage(sessionID, userMsg); err != nil {
+		return err
+	}
+
+	assistantMsg := models.NewChatMessage(userID, "assistant", response.Message)
+	assistantMsg.ID = response.ID
+	// Keep the untrimmed source list so it can be fetched later via the detail endpoint
+	assistantMsg.Metadata.Sources = response.AllSources
+	assistantMsg.Prompt = response.Prompt
+	// Ensure the reply sorts after the question even if both were created in the same microsecond
+	if !assistantMsg.Timestamp.After(userMsg.Timestamp) {
+		assistantMsg.Timestamp = userMsg.Timestamp.Add(time.Microsecond)
+	}
+	if err := s.SaveMessage(sessionID, assistantMsg); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RecentMessages returns up to limit of a session's latest messages, oldest first
+func (s *ChatService) RecentMessages(userID, sessionID string, limit int) ([]models.ChatMessage, error) {
+	messages, _, err := s.db.GetChatMessages(userID, sessionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat messages: %w", err)
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+
+	return messages, nil
+}
+
+// GetChatHistory retrieves the latest limit stored messages grouped into sessions.
+// Sessions are ordered most recently active first; messages within a session are chronological.
+func (s *ChatService) GetChatHistory(userID, sessionID string, limit int) (*models.ChatHistory, error) {
+	messages, hasMore, err := s.db.GetChatMessages(userID, sessionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat messages: %w", err)
+	}
+
+	sessionMap := make(map[string]*models.ChatSession)
+	for _, message := range messages {
+		session, exists := sessionMap[message.SessionID]
+		if !exists {
+			session = &models.ChatSession{
+				SessionID: message.SessionID,
+				UserID:    userID,
+				StartTime: message.Timestamp,
+				Messages:  make([]models.ChatMessage, 0),
+			}
+			sessionMap[message.SessionID] = session
+		}
+
+		session.Messages = append(session.Messages, message)
+		session.MessageCount++
+		if message.Timestamp.Before(session.StartTime) {
+			session.StartTime = message.Timestamp
+		}
+		if message.Timestamp.After(session.LastActive) {
+			session.LastActive = message.Timestamp
+		}
+	}
+
+	sessions := make([]models.ChatSession, 0, len(sessionMap))
+	for _, session := range sessionMap {
+		sort.Slice(session.Messages, func(i, j int) bool {
+			return session.Messages[i].Timestamp.Before(session.Messages[j].Timestamp)
+		})
+		sessions = append(sessions, *session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActive.After(sessions[j].LastActive)
+	})
+
+	return &models.ChatHistory{
+		UserID:     userID,
+		Sessions:   sessions,
+		TotalCount: len(sessions),
+		HasMore:    hasMore,
+	}, nil
+}
+
+// GetMessageSources returns the full set of sources stored with an assistant message
+func (s *ChatService) GetMessageSources(userID, sessionID, messageID string) (*models.MessageSources, error) {
+	messages, _, err := s.db.GetChatMessages(userID, sessionID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat messages: %w", err)
+	}
+
+	for _, message := range messages {
+		if message.ID != messageID {
+			continue
+		}
+
+		sources := message.Metadata.Sources
+		if sources == nil {
+			sources = []models.Source{}
+		}
+
+		return &models.MessageSources{
+			MessageID: message.ID,
+			SessionID: message.SessionID,
+			Sources:   sources,
+			Count:     len(sources),
+		}, nil
+	}
+
+	return nil, ErrMessageNotFound
+}
+
+// GetMessagePrompt returns the prompt stored with an assistant message for auditing
+func (s *ChatService) GetMessagePrompt(userID, sessionID, messageID string) (*models.MessagePrompt, error) {
+	messages, _, err := s.db.GetChatMessages(userID, sessionID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat messages: %w", err)
+	}
+
+	for _, message := range messages {
+		if message.ID != messageID {
+			continue
+		}
+
+		if message.Prompt == nil {
+			return nil, ErrPromptNotStored
+		}
+
+		return &models.MessagePrompt{
+			MessageID: message.ID,
+			SessionID: message.SessionID,
+			UserID:    message.UserID,
+			Timestamp: message.Timestamp,
+			Prompt:    message.Prompt,
+		}, nil
+	}
+
+	return nil, ErrMessageNotFound
+}
+
+// DeleteSession removes the user's stored messages in one session and returns how many were
+// deleted. Messages are keyed by user, so a session belonging to someone else deletes nothing.
+func (s *ChatService) DeleteSession(userID, sessionID string) (int, error) {
+	deleted, err := s.db.DeleteChatSession(userID, sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete chat session: %w", err)
+	}
+	return deleted, nil
+}
+
+// DeleteHistory removes all of the user's stored messages and returns the IDs of the sessions
+// that were cleared
+func (s *ChatService) DeleteHistory(userID string) ([]string, error) {
+	sessionIDs, err := s.db.DeleteAllChatSessions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete chat history: %w", err)
+	}
+	return sessionIDs, nil
+}
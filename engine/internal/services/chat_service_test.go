@@ -0,0 +1,208 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/models"
+)
+
+func newTestChatService(t *testing.T) *ChatService {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	db, _ := dynamotest.NewClient(cfg)
+	return NewChatService(db, cfg)
+}
+
+// saveMessage stores a message sent at the given time
+func saveMessage(t *testing.T, service *ChatService, userID, sessionID, role, content string, at time.Time) {
+	t.Helper()
+
+	message := models.NewChatMessage(userID, role, content)
+	message.Timestamp = at
+	if err := service.SaveMessage(sessionID, message); err != nil {
+		t.Fatalf("save message: %v", err)
+	}
+}
+
+func TestChatServiceStoresAndRetrievesConversation(t *testing.T) {
+	service := newTestChatService(t)
+
+	exchanges := []struct{ question, answer string }{
+		{"What is my resting heart rate?", "Your resting heart rate is 62 bpm."},
+		{"Is that normal?", "Yes, 60-100 bpm is the normal range."},
+		{"How has it changed?", "It dropped 4 bpm over the last month."},
+	}
+	for i, exchange := range exchanges {
+		response := &models.ChatResponse{ID: fmt.Sprintf("reply-%d", i), Message: exchange.answer}
+		if err := service.SaveExchange("user-1", "session-1", exchange.question, response); err != nil {
+			t.Fatalf("save exchange: %v", err)
+		}
+	}
+	saveMessage(t, service, "user-2", "session-2", "user", "Someone else's question", time.Now())
+
+	history, err := service.GetChatHistory("user-1", "session-1", 0)
+	if err != nil {
+		t.Fatalf("get chat history: %v", err)
+	}
+	if len(history.Sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(history.Sessions))
+	}
+
+	session := history.Sessions[0]
+	if session.SessionID != "session-1" || session.MessageCount != 6 {
+		t.Fatalf("got session %q with %d messages, want session-1 with 6", session.SessionID, session.MessageCount)
+	}
+	for i, exchange := range exchanges {
+		question, answer := session.Messages[2*i], session.Messages[2*i+1]
+		if question.Role != "user" || question.Content != exchange.question {
+			t.Errorf("message %d = %s %q, want user %q", 2*i, question.Role, question.Content, exchange.question)
+		}
+		if answer.Role != "assistant" || answer.Content != exchange.answer {
+			t.Errorf("message %d = %s %q, want assistant %q", 2*i+1, answer.Role, answer.Content, exchange.answer)
+		}
+	}
+	if history.HasMore {
+		t.Error("HasMore = true for an unlimited read")
+	}
+
+	recent, err := service.RecentMessages("user-1", "session-1", 2)
+	if err != nil {
+		t.Fatalf("recent messages: %v", err)
+	}
+	if len(recent) != 2 || recent[0].Content != exchanges[2].question || recent[1].Content != exchanges[2].answer {
+		t.Errorf("recent messages = %+v, want the last exchange oldest first", recent)
+	}
+}
+
+func TestGetChatHistoryLimitAcrossSessions(t *testing.T) {
+	service := newTestChatService(t)
+
+	// Session "a" sorts before "b" by key, but "b" holds both the oldest and the newest messages
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	saveMessage(t, service, "user-1", "b", "user", "b1", base)
+	saveMessage(t, service, "user-1", "a", "user", "a1", base.Add(time.Minute))
+	saveMessage(t, service, "user-1", "a", "user", "a2", base.Add(2*time.Minute))
+	saveMessage(t, service, "user-1", "b", "user", "b2", base.Add(3*time.Minute))
+
+	history, err := service.GetChatHistory("user-1", "", 2)
+	if err != nil {
+		t.Fatalf("get chat history: %v", err)
+	}
+	if !history.HasMore {
+		t.Error("HasMore = false with older messages left")
+	}
+
+	got := map[string]bool{}
+	for _, session := range history.Sessions {
+		for _, message := range session.Messages {
+			got[message.Content] = true
+		}
+	}
+	if len(got) != 2 || !got["b2"] || !got["a2"] {
+		t.Errorf("got messages %v, want the latest two (a2, b2)", got)
+	}
+	if history.Sessions[0].SessionID != "b" {
+		t.Errorf("first session = %q, want the most recently active (b)", history.Sessions[0].SessionID)
+	}
+
+	history, err = service.GetChatHistory("user-1", "", 4)
+	if err != nil {
+		t.Fatalf("get chat history: %v", err)
+	}
+	if history.HasMore {
+		t.Error("HasMore = true when the limit covers every message")
+	}
+	if history.TotalCount != 2 {
+		t.Errorf("TotalCount = %d, want 2", history.TotalCount)
+	}
+}
+
+func TestGetChatHistorySkipsExpiredMessages(t *testing.T) {
+	service := newTestChatService(t)
+	service.cfg.ChatRetentionDays = 1
+
+	now := time.Now().UTC()
+	saveMessage(t, service, "user-1", "s", "user", "expired", now.Add(-48*time.Hour))
+	saveMessage(t, service, "user-1", "s", "user", "old", now.Add(-2*time.Hour))
+	saveMessage(t, service, "user-1", "s", "user", "new", now.Add(-time.Hour))
+
+	history, err := service.GetChatHistory("user-1", "", 2)
+	if err != nil {
+		t.Fatalf("get chat history: %v", err)
+	}
+	if history.HasMore {
+		t.Error("HasMore = true when only expired messages remain")
+	}
+	if len(history.Sessions) != 1 || history.Sessions[0].MessageCount != 2 {
+		t.Fatalf("got %+v, want one session with the two live messages", history.Sessions)
+	}
+}
+
+func TestGetMessageSourcesReturnsUntrimmedSources(t *testing.T) {
+	service := newTestChatService(t)
+
+	response := &models.ChatResponse{
+		ID:         "reply-1",
+		Message:    "Your LDL is borderline high.",
+		Sources:    []models.Source{{ChunkID: "doc-1#0"}},
+		AllSources: []models.Source{{ChunkID: "doc-1#0"}, {ChunkID: "doc-1#1"}, {ChunkID: "doc-2#0"}},
+	}
+	if err := service.SaveExchange("user-1", "session-1", "How is my cholesterol?", response); err != nil {
+		t.Fatalf("save exchange: %v", err)
+	}
+
+	sources, err := service.GetMessageSources("user-1", "session-1", "reply-1")
+	if err != nil {
+		t.Fatalf("get message sources: %v", err)
+	}
+	if sources.Count != 3 || len(sources.Sources) != 3 {
+		t.Errorf("got %d sources, want all 3", sources.Count)
+	}
+
+	if _, err := service.GetMessageSources("user-2", "session-1", "reply-1"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("another user's lookup returned %v, want ErrMessageNotFound", err)
+	}
+}
+
+func TestSaveMessageSetsTTLFromRetention(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	db, fake := dynamotest.NewClient(cfg)
+	service := NewChatService(db, cfg)
+	sent := time.Now().UTC().Add(-time.Minute)
+
+	cfg.ChatRetentionDays = 0
+	saveMessage(t, service, "user-1", "kept", "user", "kept forever", sent)
+	cfg.ChatRetentionDays = 30
+	saveMessage(t, service, "user-1", "purged", "user", "purged in a month", sent)
+
+	items := fake.Items(cfg.DynamoDBTableChat)
+	if len(items) != 2 {
+		t.Fatalf("stored %d messages, want 2", len(items))
+	}
+	want := fmt.Sprint(sent.Add(30 * 24 * time.Hour).Unix())
+	for _, item := range items {
+		expiresAt, hasTTL := item["expires_at"]
+		switch *item["session_id"].S {
+		case "kept":
+			if hasTTL {
+				t.Errorf("expires_at = %v with retention disabled, want it omitted", expiresAt)
+			}
+		case "purged":
+			if !hasTTL || expiresAt.N == nil || *expiresAt.N != want {
+				t.Errorf("expires_at = %v, want %s", expiresAt, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/internal/storage"
+	"health-dashboard-backend/internal/vectordb"
+	"health-dashboard-backend/pkg/ai"
+	"health-dashboard-backend/pkg/ai/embeddings"
+)
+
+// diagnosticCheckTimeout bounds each individual dependency check
+const diagnosticCheckTimeout = 10 * time.Second
+
+// readinessTimeout bounds the whole readiness probe, which load balancers call frequently
+const readinessTimeout = 3 * time.Second
+
+// DiagnosticsService runs connectivity checks against every external dependency
+type DiagnosticsService struct {
+	db              *database.DynamoDBClient
+	s3Client        *storage.S3Client
+	vectorDB        *vectordb.PineconeClient
+	llmClient       ai.LLMClient
+	embeddingClient ai.EmbeddingClient
+	cfg             *config.Config
+
+	queueStats QueueStatsSource
+}
+
+// QueueStatsSource reports the document processing queue's state
+type QueueStatsSource interface {
+	QueueStats() ProcessingQueueStats
+}
+
+// NewDiagnosticsService creates a new diagnostics service
+func NewDiagnosticsService(db *database.DynamoDBClient, s3Client *storage.S3Client, vectorDB *vectordb.PineconeClient, llmClient ai.LLMClient, embeddingClient ai.EmbeddingClient, cfg *config.Config) *DiagnosticsService {
+	return &DiagnosticsService{
+		db:              db,
+		s3Client:        s3Client,
+		vectorDB:        vectorDB,
+		llmClient:       llmClient,
+		embeddingClient: embeddingClient,
+		cfg:             cfg,
+	}
+}
+
+// SetQueueStatsSource sets where processing queue depth is read from for the report
+func (d *DiagnosticsService) SetQueueStatsSource(source QueueStatsSource) {
+	d.queueStats = source
+}
+
+// RunDiagnostics checks all dependencies concurrently and reports their status with the resolved config
+func (d *DiagnosticsService) RunDiagnostics(ctx context.Context) *models.Diagnostics {
+	start := time.Now()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	components := make(map[string]models.ComponentStatus)
+
+	run := func(name string, check func(ctx context.Context) (map[string]interface{}, error)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, diagnosticCheckTimeout)
+			defer cancel()
+
+			checkStart := time.Now()
+			details, err := check(checkCtx)
+			status := models.ComponentStatus{
+				Healthy:   err == nil,
+				LatencyMs: time.Since(checkStart).Milliseconds(),
+				Details:   details,
+			}
+			if err != nil {
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			components[name] = status
+			mu.Unlock()
+		}()
+	}
+
+	for purpose, tableName := range d.db.TableNames() {
+		// The sessions table only has to exist when sessions are stored in DynamoDB
+		if purpose == "sessions" && d.cfg.WSSessionStore != "dynamodb" {
+			continue
+		}
+		tableName := tableName
+		run("dynamodb_"+purpose, func(ctx context.Context) (map[string]interface{}, error) {
+			return d.db.DescribeTable(tableName)
+		})
+	}
+
+	run("s3", func(ctx context.Context) (map[string]interface{}, error) {
+		details := map[string]interface{}{"bucket": d.s3Client.GetBucketName()}
+		return details, d.s3Client.HealthCheck()
+	})
+
+	run("pinecone", func(ctx context.Context) (map[string]interface{}, error) {
+		return d.vectorDB.GetIndexSummary(ctx)
+	})
+
+	run("llm", func(ctx context.Context) (map[string]interface{}, error) {
+		details := map[string]interface{}{"provider": d.cfg.LLMProvider}
+		return details, d.llmClient.HealthCheck(ctx)
+	})
+
+	run("embeddings", func(ctx context.Context) (map[string]interface{}, error) {
+		// Bypass the embedding cache so the provider is actually reached
+		client := d.embeddingClient
+		if caching, ok := client.(*embeddings.CachingEmbeddingClient); ok {
+			client = caching.Unwrap()
+		}
+
+		embedding, err := client.GenerateEmbedding(ctx, "diagnostics")
+		if err != nil {
+			return map[string]interface{}{"model": d.cfg.EmbeddingModel}, err
+		}
+		return map[string]interface{}{
+			"model":     d.cfg.EmbeddingModel,
+			"dimension": len(embedding),
+		}, nil
+	})
+
+	if d.queueStats != nil {
+		run("processing_queue", func(ctx context.Context) (map[string]interface{}, error) {
+			stats := d.queueStats.QueueStats()
+			details := map[string]interface{}{
+				"workers":       stats.Workers,
+				"pending":       stats.Pending,
+				"running":       stats.Running,
+				"pending_users": stats.PendingUsers,
+			}
+			if stats.ShuttingDown {
+				return details, fmt.Errorf("processing queue is shutting down")
+			}
+			return details, nil
+		})
+	}
+
+	wg.Wait()
+
+	// A dimension mismatch means every upsert and query will fail even though both services are reachable
+	pinecone, embeddings := components["pinecone"], components["embeddings"]
+	if pinecone.Healthy && embeddings.Healthy {
+		indexDim := fmt.Sprint(pinecone.Details["dimension"])
+		embeddingDim := fmt.Sprint(embeddings.Details["dimension"])
+		embeddings.Details["dimension_matches_index"] = indexDim == embeddingDim
+		if indexDim != embeddingDim {
+			embeddings.Healthy = false
+			embeddings.Error = fmt.Sprintf("embedding dimension %s does not match index dimension %s", embeddingDim, indexDim)
+		}
+		components["embeddings"] = embeddings
+	}
+
+	healthy := true
+	for _, status := range components {
+		if !status.Healthy {
+			healthy = false
+			break
+		}
+	}
+
+	return &models.Diagnostics{
+		Healthy:    healthy,
+		Timestamp:  time.Now(),
+		DurationMs: time.Since(start).Milliseconds(),
+		Components: components,
+		Config:     d.resolvedConfig(),
+	}
+}
+
+// CheckReadiness runs the lightweight health check of each core dependency in parallel and reports
+// which are reachable. A check that doesn't finish within readinessTimeout counts as down.
+func (d *DiagnosticsService) CheckReadiness(ctx context.Context) (bool, map[string]bool) {
+	return runReadinessChecks(ctx, readinessTimeout, map[string]func(ctx context.Context) error{
+		"dynamodb": func(ctx context.Context) error { return d.db.HealthCheck() },
+		"s3":       func(ctx context.Context) error { return d.s3Client.HealthCheck() },
+		"pinecone": d.vectorDB.HealthCheck,
+		"llm":      d.llmClient.HealthCheck,
+	})
+}
+
+// runReadinessChecks runs checks in parallel, giving up on any still running after timeout
+func runReadinessChecks(ctx context.Context, timeout time.Duration, checks map[string]func(ctx context.Context) error) (bool, map[string]bool) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		name string
+		ok   bool
+	}
+	// Buffered so checks that finish after the timeout don't block
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		name, check := name, check
+		go func() {
+			results <- result{name: name, ok: check(ctx) == nil}
+		}()
+	}
+
+	status := make(map[string]bool, len(checks))
+	for name := range checks {
+		status[name] = false
+	}
+
+	healthy := true
+	for remaining := len(checks); remaining > 0; remaining-- {
+		select {
+		case r := <-results:
+			status[r.name] = r.ok
+			if !r.ok {
+				healthy = false
+			}
+		case <-ctx.Done():
+			return false, status
+		}
+	}
+
+	return healthy, status
+}
+
+// resolvedConfig returns the effective configuration with secret values replaced by whether they are set
+func (d *DiagnosticsService) resolvedConfig() map[string]interface{} {
+	cfg := d.cfg
+	return map[string]interface{}{
+		"environment":                   cfg.Environment,
+		"port":                          cfg.Port,
+		"test_mode":                     cfg.TestMode,
+		"test_mode_skip_roles":          cfg.TestModeSkipRoles,
+		"tls_enabled":                   cfg.TLSEnabled,
+		"log_mode":                      cfg.LogMode,
+		"log_file_path":                 cfg.LogFilePath,
+		"log_max_size_mb":               cfg.LogMaxSizeMB,
+		"log_max_backups":               cfg.LogMaxBackups,
+		"log_max_age_days":              cfg.LogMaxAgeDays,
+		"cors_allow_all_origins":        cfg.CORSAllowAllOrigins,
+		"cors_allowed_origins":          cfg.CORSAllowedOrigins,
+		"cors_max_age":                  cfg.CORSMaxAge,
+		"aws_region":                    cfg.AWSRegion,
+		"dynamodb_table_health":         cfg.DynamoDBTableHealth,
+		"dynamodb_table_docs":           cfg.DynamoDBTableDocs,
+		"dynamodb_table_chat":           cfg.DynamoDBTableChat,
+		"dynamodb_chat_timestamp_index": cfg.DynamoDBChatTimestampIndex,
+		"dynamodb_table_access_log":     cfg.DynamoDBTableAccessLog,
+		"dynamodb_table_insights":       cfg.DynamoDBTableInsights,
+		"insights_cache_ttl_hours":      cfg.InsightsCacheTTLHours,
+		"dynamodb_table_goals":          cfg.DynamoDBTableGoals,
+		"dynamodb_table_profiles":       cfg.DynamoDBTableProfiles,
+		"dynamodb_table_chunks":         cfg.DynamoDBTableChunks,
+		"s3_bucket":                     cfg.S3Bucket,
+		"max_presign_minutes":           cfg.MaxPresignMinutes,
+		"s3_upload_part_size_mb":        cfg.S3UploadPartSizeMB,
+		"s3_upload_concurrency":         cfg.S3UploadConcurrency,
+		"s3_sse_mode":                   cfg.S3SSEMode,
+		"s3_kms_key_id":                 cfg.S3KMSKeyID,
+		"s3_upload_checksums":           cfg.S3UploadChecksums,
+		"pinecone_index_name":           cfg.PineconeIndexName,
+		"pinecone_namespace":            cfg.PineconeNamespace,
+		"pinecone_ns_per_user":          cfg.PineconeNamespacePerUser,
+		"pinecone_host":                 cfg.PineconeHost,
+		"pinecone_upsert_batch":         cfg.PineconeUpsertBatchSize,
+		"llm_provider":                  cfg.LLMProvider,
+		"chat_model":                    cfg.ChatModel,
+		"anthropic_model":               cfg.AnthropicModel,
+		"openai_chat_model":             cfg.OpenAIChatModel,
+		"embedding_model":               cfg.EmbeddingModel,
+		"embedding_dimension":           cfg.EmbeddingDimension,
+		"embedding_mismatch_action":     cfg.EmbeddingMismatchAction,
+		"embedding_batch_size":          cfg.EmbeddingBatchSize,
+		"embedding_cache_size":          cfg.EmbeddingCacheSize,
+		"max_tokens":                    cfg.MaxTokens,
+		"provider_timeout_seconds":      cfg.ProviderTimeoutSeconds,
+		"temperature":                   cfg.Temperature,
+		"default_response_language":     cfg.DefaultResponseLanguage,
+		"assistant_persona":             cfg.AssistantPersona,
+		"assistant_locale":              cfg.AssistantLocale,
+		"chat_history_messages":         cfg.ChatHistoryMessages,
+		"chat_retention_days":           cfg.ChatRetentionDays,
+		"stream_coalesce_ms":            cfg.StreamCoalesceMs,
+		"ws_ping_interval_seconds":      cfg.WSPingIntervalSeconds,
+		"ws_idle_timeout_minutes":       cfg.WSIdleTimeoutMinutes,
+		"ws_session_store":              cfg.WSSessionStore,
+		"dynamodb_table_sessions":       cfg.DynamoDBTableSessions,
+		"ws_session_ttl_hours":          cfg.WSSessionTTLHours,
+		"max_file_size":                 cfg.MaxFileSize,
+		"supported_formats":             cfg.SupportedFormats,
+		"excluded_view_sources":         cfg.MetricViewExcludedSources,
+		"idempotency_ttl_minutes":       cfg.IdempotencyTTLMinutes,
+		"rate_limit_per_minute":         cfg.RateLimitPerMinute,
+		"rate_limit_burst":              cfg.RateLimitBurst,
+		"chunk_size":                    cfg.ChunkSize,
+		"chunk_overlap":                 cfg.ChunkOverlap,
+		"chunk_by_tokens":               cfg.ChunkByTokens,
+		"chunk_max_tokens":              cfg.ChunkMaxTokens,
+		"chunk_overlap_tokens":          cfg.ChunkOverlapTokens,
+		"store_extracted_text":          cfg.StoreExtractedText,
+		"processing_timeout_secs":       cfg.ProcessingTimeoutSec,
+		"ocr_enabled":                   cfg.OCREnabled,
+		"tesseract_path":                cfg.TesseractPath,
+		"ocr_languages":                 cfg.OCRLanguages,
+		"ocr_timeout_seconds":           cfg.OCRTimeoutSeconds,
+		"normalize_tags":                cfg.NormalizeTags,
+		"tag_synonyms":                  cfg.TagSynonyms,
+		"rag_include_health_data":       cfg.RAGIncludeHealthData,
+		"rag_min_relevance_score":       cfg.RAGMinRelevanceScore,
+		"rag_max_chunks_per_doc":        cfg.RAGMaxChunksPerDocument,
+		"rag_hybrid_alpha":              cfg.HybridAlpha,
+		"retry_attempts":                cfg.RetryAttempts,
+		"retry_backoff_ms":              cfg.RetryBackoffMs,
+		"secrets": map[string]string{
+			"jwt_secret":            redactSecret(cfg.JWTSecret),
+			"clerk_secret_key":      redactSecret(cfg.ClerkSecretKey),
+			"aws_access_key_id":     redactSecret(cfg.AWSAccessKeyID),
+			"aws_secret_access_key": redactSecret(cfg.AWSSecretAccessKey),
+			"pinecone_api_key":      redactSecret(cfg.PineconeAPIKey),
+			"sonar_api_key":         redactSecret(cfg.SonarAPIKey),
+			"openai_api_key":        redactSecret(cfg.OpenAIAPIKey),
+			"anthropic_api_key":     redactSecret(cfg.AnthropicAPIKey),
+		},
+	}
+}
+
+// redactSecret reports whether a secret is configured without exposing its value
+func redactSecret(value string) string {
+	if value == "" {
+		return "not set"
+	}
+	return "set (redacted)"
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadinessChecksReportDownAndSlowDependencies(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	hung := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // Reports after the probe has given up
+		return ctx.Err()
+	}
+
+	healthy, status := runReadinessChecks(context.Background(), time.Second, map[string]func(ctx context.Context) error{
+		"dynamodb": up, "s3": up, "pinecone": down, "llm": up,
+	})
+	if healthy || status["pinecone"] || !status["dynamodb"] || !status["s3"] || !status["llm"] {
+		t.Errorf("healthy %v, status %v; want unhealthy with only pinecone down", healthy, status)
+	}
+
+	start := time.Now()
+	healthy, status = runReadinessChecks(context.Background(), 50*time.Millisecond, map[string]func(ctx context.Context) error{
+		"dynamodb": up, "llm": hung,
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("probe took %v, want it to give up after the timeout", elapsed)
+	}
+	if healthy || status["llm"] || !status["dynamodb"] {
+		t.Errorf("healthy %v, status %v; want unhealthy with the hung llm check down", healthy, status)
+	}
+
+	if healthy, _ := runReadinessChecks(context.Background(), time.Second, map[string]func(ctx context.Context) error{"dynamodb": up, "s3": up}); !healthy {
+		t.Error("all dependencies up reported unhealthy")
+	}
+}
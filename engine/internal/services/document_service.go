@@ -2,19 +2,44 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"go.uber.org/zap"
+
 	"health-dashboard-backend/internal/config"
 	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/metrics"
 	"health-dashboard-backend/internal/models"
 	"health-dashboard-backend/internal/storage"
 	"health-dashboard-backend/pkg/fileprocessor"
 )
 
+// ErrDocumentNotFound is returned when the user has no document with the given ID
+var ErrDocumentNotFound = errors.New("document not found")
+
+// ErrInvalidCursor is returned when a list cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrVersionConflict is returned when a document was changed since the version the client last read
+var ErrVersionConflict = errors.New("document version conflict")
+
+// ErrInvalidDocumentUpdate is returned when a document update has invalid fields
+var ErrInvalidDocumentUpdate = errors.New("invalid document update")
+
 // DocumentService handles document operations
 type DocumentService struct {
 	s3Client   *storage.S3Client
@@ -22,17 +47,37 @@ type DocumentService struct {
 	processor  *fileprocessor.FileProcessor
 	ragService *RAGService
 	cfg        *config.Config
+	queue      *ProcessingQueue
+	logger     *zap.Logger
 }
 
 // NewDocumentService creates a new document service
 func NewDocumentService(s3Client *storage.S3Client, db *database.DynamoDBClient, ragService *RAGService, cfg *config.Config) *DocumentService {
-	return &DocumentService{
+	d := &DocumentService{
 		s3Client:   s3Client,
 		db:         db,
 		processor:  fileprocessor.NewFileProcessor(),
 		ragService: ragService,
 		cfg:        cfg,
+		logger:     zap.NewNop(),
+	}
+	d.queue = NewProcessingQueue(cfg.DocumentWorkers, cfg.MaxProcessingPerUser, func(userID, documentID string) error {
+		return d.ProcessDocument(context.Background(), userID, documentID)
+	})
+
+	if cfg.OCREnabled {
+		timeout := time.Duration(cfg.OCRTimeoutSeconds) * time.Second
+		d.processor.SetOCRProvider(fileprocessor.NewTesseractOCR(cfg.TesseractPath, cfg.OCRLanguages, timeout))
 	}
+
+	return d
+}
+
+// SetLogger sets the logger for failures that don't fail the request, including those of queued
+// processing jobs; defaults to a no-op logger
+func (d *DocumentService) SetLogger(logger *zap.Logger) {
+	d.logger = logger
+	d.queue.SetLogger(logger)
 }
 
 // UploadDocument uploads and processes a document
@@ -52,16 +97,43 @@ func (d *DocumentService) UploadDocument(userID string, file *multipart.FileHead
 	fileType := strings.ToLower(filepath.Ext(file.Filename)[1:])
 	document := models.NewDocument(userID, request.Title, file.Filename, fileType, contentType, request.Category, file.Size)
 	document.Description = request.Description
-	document.Tags = request.Tags
+	document.Tags = d.normalizeTags(request.Tags)
 	document.SetS3Key(d.cfg.S3Bucket)
 
-	// Upload file to S3
+	// Stream the file to S3; large uploads are already spooled to disk by the multipart parser,
+	// so they are read part by part rather than held in memory
 	fileReader, err := file.Open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer fileReader.Close()
 
+	// Hash the content so re-uploads of the same file return the existing document. The lookup
+	// and the save aren't atomic, so two uploads of the same file racing each other can both be
+	// stored; that costs a duplicate document, which the user can delete, and nothing is lost.
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, fileReader); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if _, err := fileReader.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind file: %w", err)
+	}
+	document.ContentHash = hex.EncodeToString(hasher.Sum(nil))
+
+	existing, err := d.db.GetDocumentByHash(userID, document.ContentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for duplicate document: %w", err)
+	}
+	if existing != nil {
+		d.annotateQueueStatus(existing)
+		return &models.DocumentUploadResponse{
+			Document:  existing,
+			Status:    existing.Status,
+			Message:   "Document was already uploaded",
+			Duplicate: true,
+		}, nil
+	}
+
 	metadata := map[string]*string{
 		"user_id":     &userID,
 		"document_id": &document.DocumentID,
@@ -70,7 +142,8 @@ func (d *DocumentService) UploadDocument(userID string, file *multipart.FileHead
 		"category":    &request.Category,
 	}
 
-	s3URL, err := d.s3Client.UploadFile(document.S3Key, fileReader, contentType, metadata)
+	partSize := int64(d.cfg.S3UploadPartSizeMB) * 1024 * 1024
+	s3URL, err := d.s3Client.UploadStream(document.S3Key, fileReader, contentType, partSize, metadata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload file to S3: %w", err)
 	}
@@ -85,36 +158,46 @@ func (d *DocumentService) UploadDocument(userID string, file *multipart.FileHead
 		return nil, fmt.Errorf("failed to save document metadata: %w", err)
 	}
 
-	// Automatically trigger processing in background
-	go func() {
-		if err := d.ProcessDocument(userID, document.DocumentID); err != nil {
-			// Log error but don't fail the upload
-			// The document will be marked as failed and can be retried
-			fmt.Printf("Failed to auto-process document %s: %v\n", document.DocumentID, err)
-		}
-	}()
+	// Queue processing on the shared worker pool
+	d.queue.Enqueue(userID, document.DocumentID)
+	d.annotateQueueStatus(document)
 
 	return &models.DocumentUploadResponse{
 		Document: document,
 		Status:   models.StatusUploaded,
-		Message:  "Document uploaded successfully and processing started",
+		Message:  "Document uploaded successfully and queued for processing",
 	}, nil
 }
 
-// GetUserDocuments retrieves documents for a user
-func (d *DocumentService) GetUserDocuments(userID string, limit int, cursor string) (*models.DocumentListResponse, error) {
-	// Parse cursor if provided (simplified implementation)
+// GetUserDocuments retrieves a page of the user's documents matching filter. cursor is the
+// NextCursor of the previous page, or empty for the first page.
+func (d *DocumentService) GetUserDocuments(userID string, filter models.DocumentFilter, limit int, cursor string) (*models.DocumentListResponse, error) {
+	startKey, err := decodeDocumentCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stored tags are normalized, so the filter must be too
+	filter.Tags = d.normalizeTags(filter.Tags)
 
-	documents, nextKey, err := d.db.GetUserDocuments(userID, limit, nil)
+	documents, nextKey, err := d.db.GetUserDocuments(userID, filter, limit, startKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user documents: %w", err)
 	}
+	if documents == nil {
+		documents = []models.Document{}
+	}
 
 	hasMore := nextKey != nil
 	nextCursor := ""
 	if hasMore {
-		// Convert nextKey to cursor string (simplified)
-		nextCursor = "next_page"
+		if nextCursor, err = encodeDocumentCursor(nextKey); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range documents {
+		d.annotateQueueStatus(&documents[i])
 	}
 
 	return &models.DocumentListResponse{
@@ -125,9 +208,341 @@ func (d *DocumentService) GetUserDocuments(userID string, limit int, cursor stri
 	}, nil
 }
 
+// encodeDocumentCursor turns a DynamoDB key into an opaque cursor. Document keys are strings.
+func encodeDocumentCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+	values := make(map[string]string, len(key))
+	for name, value := range key {
+		if value.S == nil {
+			return "", fmt.Errorf("unsupported key attribute %s", name)
+		}
+		values[name] = *value.S
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeDocumentCursor reverses encodeDocumentCursor; an empty cursor means the first page
+func decodeDocumentCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil || len(values) == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	key := make(map[string]*dynamodb.AttributeValue, len(values))
+	for name, value := range values {
+		key[name] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+	return key, nil
+}
+
 // GetDocument retrieves a specific document
 func (d *DocumentService) GetDocument(userID, documentID string) (*models.Document, error) {
-	return d.db.GetDocument(userID, documentID)
+	document, err := d.db.GetDocument(userID, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	d.annotateQueueStatus(document)
+	return document, nil
+}
+
+// UpdateDocumentDetails applies a partial update to a document's title, description and tags.
+// When expectedVersion is non-negative the update only succeeds if the document is still at that
+// version, and ErrVersionConflict is returned otherwise.
+func (d *DocumentService) UpdateDocumentDetails(userID, documentID string, update *models.DocumentUpdateRequest, expectedVersion int) (*models.Document, error) {
+	document, err := d.db.GetDocument(userID, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDocumentNotFound, err)
+	}
+
+	// Fail fast on a stale version; the conditional write still guards against races
+	if expectedVersion >= 0 && document.Version != expectedVersion {
+		return nil, fmt.Errorf("%w: document is at version %d, not %d", ErrVersionConflict, document.Version, expectedVersion)
+	}
+
+	if update.Title != nil {
+		title := strings.TrimSpace(*update.Title)
+		if title == "" {
+			return nil, fmt.Errorf("%w: title cannot be empty", ErrInvalidDocumentUpdate)
+		}
+		document.Title = title
+	}
+	if update.Description != nil {
+		document.Description = strings.TrimSpace(*update.Description)
+	}
+	if update.Tags != nil {
+		document.Tags = d.normalizeTags(*update.Tags)
+	}
+
+	if err := d.db.UpdateDocumentDetails(document, expectedVersion); err != nil {
+		if errors.Is(err, database.ErrVersionConflict) {
+			return nil, fmt.Errorf("%w: %v", ErrVersionConflict, err)
+		}
+		return nil, fmt.Errorf("failed to update document: %w", err)
+	}
+
+	d.annotateQueueStatus(document)
+	return document, nil
+}
+
+// SetArchived archives or restores a document. Archived documents keep their file, record and
+// vectors but are left out of document listings unless asked for.
+func (d *DocumentService) SetArchived(userID, documentID string, archived bool) (*models.Document, error) {
+	document, err := d.db.GetDocument(userID, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDocumentNotFound, err)
+	}
+
+	if document.Archived == archived {
+		return document, nil
+	}
+
+	document.Archived = archived
+	if err := d.db.SetDocumentArchived(document); err != nil {
+		return nil, err
+	}
+
+	d.annotateQueueStatus(document)
+	return document, nil
+}
+
+// normalizeTags applies the configured tag normalization and synonym mapping
+func (d *DocumentService) normalizeTags(tags []string) []string {
+	if !d.cfg.NormalizeTags {
+		return tags
+	}
+
+	// Synonym keys are matched against normalized tags, so normalize them too
+	synonyms := make(map[string]string, len(d.cfg.TagSynonyms))
+	for from, to := range d.cfg.TagSynonyms {
+		synonyms[models.NormalizeTag(from, nil)] = models.NormalizeTag(to, nil)
+	}
+
+	return models.NormalizeTags(tags, synonyms)
+}
+
+// GetUserTags returns the distinct tags across the user's documents, sorted
+func (d *DocumentService) GetUserTags(userID string) ([]string, error) {
+	seen := make(map[string]bool)
+	var lastKey map[string]*dynamodb.AttributeValue
+	for {
+		documents, nextKey, err := d.db.GetUserDocuments(userID, models.DocumentFilter{}, 0, lastKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user documents: %w", err)
+		}
+
+		for _, document := range documents {
+			// Documents stored before normalization may carry variants, so normalize on read too
+			for _, tag := range d.normalizeTags(document.Tags) {
+				seen[tag] = true
+			}
+		}
+
+		if nextKey == nil {
+			break
+		}
+		lastKey = nextKey
+	}
+
+	tags := make([]string, 0, len(seen))
+	for tag := range seen {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	return tags, nil
+}
+
+// metadataMatch is a document matched by SearchMetadata and where the query was found
+type metadataMatch struct {
+	document models.Document
+	field    int // 0 title, 1 description, 2 tags
+	position int // Byte offset of the match within the field
+}
+
+// SearchMetadata finds the user's documents whose title, description or tags contain query,
+// ignoring case. Title matches rank first, then description and then tag matches; within a field
+// an earlier match ranks higher, and ties keep the latest upload first. At most limit documents are
+// returned; a limit of 0 returns every match.
+func (d *DocumentService) SearchMetadata(userID, query string, limit int) ([]models.Document, error) {
+	needle := strings.ToLower(strings.TrimSpace(query))
+	if needle == "" {
+		return []models.Document{}, nil
+	}
+
+	var matches []metadataMatch
+	var lastKey map[string]*dynamodb.AttributeValue
+	for {
+		documents, nextKey, err := d.db.GetUserDocuments(userID, models.DocumentFilter{}, 0, lastKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user documents: %w", err)
+		}
+
+		for _, document := range documents {
+			if match, ok := matchDocumentMetadata(document, needle); ok {
+				matches = append(matches, match)
+			}
+		}
+
+		if nextKey == nil {
+			break
+		}
+		lastKey = nextKey
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].field != matches[j].field {
+			return matches[i].field < matches[j].field
+		}
+		return matches[i].position < matches[j].position
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	results := make([]models.Document, 0, len(matches))
+	for _, match := range matches {
+		d.annotateQueueStatus(&match.document)
+		results = append(results, match.document)
+	}
+	return results, nil
+}
+
+// matchDocumentMetadata reports the first field of document containing needle, which must be
+// lower case
+func matchDocumentMetadata(document models.Document, needle string) (metadataMatch, bool) {
+	if position := strings.Index(strings.ToLower(document.Title), needle); position >= 0 {
+		return metadataMatch{document: document, field: 0, position: position}, true
+	}
+	if position := strings.Index(strings.ToLower(document.Description), needle); position >= 0 {
+		return metadataMatch{document: document, field: 1, position: position}, true
+	}
+	for _, tag := range document.Tags {
+		if position := strings.Index(strings.ToLower(tag), needle); position >= 0 {
+			return metadataMatch{document: document, field: 2, position: position}, true
+		}
+	}
+	return metadataMatch{}, false
+}
+
+// ReprocessAll deletes the vectors of every processed document the user has and queues the
+// documents to be processed again, e.g. after switching embedding models. Documents that fail are
+// reported in the result without stopping the rest; processing itself happens in the background.
+func (d *DocumentService) ReprocessAll(ctx context.Context, userID string) (*models.ReprocessResult, error) {
+	result := &models.ReprocessResult{
+		UserID:   userID,
+		Failures: []models.ReprocessFailure{},
+	}
+
+	var processed []models.Document
+	var lastKey map[string]*dynamodb.AttributeValue
+	for {
+		// Archived documents keep their vectors, so they need re-embedding too
+		documents, nextKey, err := d.db.GetUserDocuments(userID, models.DocumentFilter{IncludeArchived: true}, 0, lastKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user documents: %w", err)
+		}
+
+		for _, document := range documents {
+			if document.Status == models.StatusProcessed {
+				processed = append(processed, document)
+			} else {
+				result.Skipped++
+			}
+		}
+
+		if nextKey == nil {
+			break
+		}
+		lastKey = nextKey
+	}
+	result.Total = len(processed)
+
+	for i := range processed {
+		document := &processed[i]
+
+		if err := d.ragService.DeleteDocumentVectors(ctx, userID, document.DocumentID); err != nil {
+			result.Failures = append(result.Failures, models.ReprocessFailure{
+				DocumentID: document.DocumentID,
+				Error:      fmt.Sprintf("failed to delete vectors: %v", err),
+			})
+			continue
+		}
+
+		// ProcessDocument skips processed documents, so reset the status first
+		document.Status = models.StatusUploaded
+		document.ErrorMessage = ""
+		if err := d.db.UpdateDocument(document); err != nil {
+			result.Failures = append(result.Failures, models.ReprocessFailure{
+				DocumentID: document.DocumentID,
+				Error:      fmt.Sprintf("failed to reset status: %v", err),
+			})
+			continue
+		}
+
+		d.queue.Enqueue(userID, document.DocumentID)
+		result.Queued++
+	}
+
+	return result, nil
+}
+
+// QueueStats reports the processing queue's depth and worker usage
+func (d *DocumentService) QueueStats() ProcessingQueueStats {
+	return d.queue.Stats()
+}
+
+// Shutdown stops queued processing and waits for documents already being processed to finish
+func (d *DocumentService) Shutdown(ctx context.Context) error {
+	return d.queue.Shutdown(ctx)
+}
+
+// GetDocumentStatus returns the processing state of a document without loading the whole record
+func (d *DocumentService) GetDocumentStatus(userID, documentID string) (*models.DocumentStatus, error) {
+	status, err := d.db.GetDocumentStatus(userID, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unprocessed documents store a zero processed_at
+	if status.ProcessedAt != nil && status.ProcessedAt.IsZero() {
+		status.ProcessedAt = nil
+	}
+
+	if status.Status == models.StatusUploaded {
+		if position, wait, ok := d.queue.Position(userID, documentID); ok {
+			status.QueuePosition = position
+			status.EstimatedWaitSeconds = int(wait.Seconds())
+		}
+	}
+
+	return status, nil
+}
+
+// annotateQueueStatus fills in queue position and estimated wait for documents still waiting to be processed
+func (d *DocumentService) annotateQueueStatus(document *models.Document) {
+	if document.Status != models.StatusUploaded {
+		return
+	}
+
+	if position, wait, ok := d.queue.Position(document.UserID, document.DocumentID); ok {
+		document.QueuePosition = position
+		document.EstimatedWaitSeconds = int(wait.Seconds())
+	}
 }
 
 // DeleteDocument deletes a document and its file
@@ -142,7 +557,22 @@ func (d *DocumentService) DeleteDocument(userID, documentID string) error {
 	if document.IndexedInPinecone {
 		if err := d.ragService.DeleteDocumentVectors(context.Background(), userID, documentID); err != nil {
 			// Log error but continue with deletion
-			fmt.Printf("Failed to delete document vectors from Pinecone: %v\n", err)
+			d.logger.Warn("Failed to delete document vectors from Pinecone",
+				zap.String("document_id", documentID), zap.Error(err))
+		}
+	}
+
+	// Delete the stored chunk text
+	if err := d.db.DeleteDocumentChunks(documentID); err != nil {
+		d.logger.Warn("Failed to delete document chunks",
+			zap.String("document_id", documentID), zap.Error(err))
+	}
+
+	// Delete the stored extracted text, if any
+	if document.TextS3Key != "" {
+		if err := d.s3Client.DeleteFile(document.TextS3Key); err != nil {
+			d.logger.Warn("Failed to delete extracted text from S3",
+				zap.String("document_id", documentID), zap.Error(err))
 		}
 	}
 
@@ -160,8 +590,15 @@ func (d *DocumentService) DeleteDocument(userID, documentID string) error {
 	return nil
 }
 
-// ProcessDocument extracts text and creates chunks from a document
-func (d *DocumentService) ProcessDocument(userID, documentID string) error {
+// ProcessDocument extracts text and creates chunks from a document. Processing is limited to
+// ProcessingTimeoutSec; a document that runs over is marked failed with a timeout message.
+func (d *DocumentService) ProcessDocument(ctx context.Context, userID, documentID string) error {
+	if d.cfg.ProcessingTimeoutSec > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(d.cfg.ProcessingTimeoutSec)*time.Second)
+		defer cancel()
+	}
+
 	// Get document
 	document, err := d.db.GetDocument(userID, documentID)
 	if err != nil {
@@ -179,55 +616,112 @@ func (d *DocumentService) ProcessDocument(userID, documentID string) error {
 		return fmt.Errorf("failed to update document status: %w", err)
 	}
 
-	// Download file from S3
-	fileData, err := d.s3Client.DownloadFile(document.S3Key)
+	// Reuse stored text from an earlier run when there is some
+	text, err := d.storedText(document)
 	if err != nil {
-		document.MarkAsFailed("Failed to download file from S3")
-		d.db.UpdateDocument(document)
-		return fmt.Errorf("failed to download file: %w", err)
+		d.logger.Warn("Failed to read stored text, re-extracting",
+			zap.String("document_id", documentID), zap.Error(err))
+		text = ""
 	}
+	pages := fileprocessor.SplitPages(text)
+
+	if text == "" {
+		// Download file from S3
+		fileData, err := d.s3Client.DownloadFile(document.S3Key)
+		if err != nil {
+			document.MarkAsFailed("Failed to download file from S3")
+			metrics.DocumentsProcessed.WithLabelValues(models.StatusFailed).Inc()
+			d.db.UpdateDocument(document)
+			return fmt.Errorf("failed to download file: %w", err)
+		}
 
-	// Extract text
-	text, err := d.processor.ExtractText(fileData, document.FileType)
-	if err != nil {
-		document.MarkAsFailed("Failed to extract text from file")
-		d.db.UpdateDocument(document)
-		return fmt.Errorf("failed to extract text: %w", err)
+		// Extract text, keeping page boundaries so chunks can cite their page
+		pages, err = d.processor.ExtractTextWithPages(fileData, document.FileType)
+		if err == nil {
+			err = ctx.Err() // Extraction doesn't take a context, so check for a timeout after it
+		}
+		if err != nil {
+			document.MarkAsFailed(d.processingFailure(ctx, "Failed to extract text from file"))
+			metrics.DocumentsProcessed.WithLabelValues(models.StatusFailed).Inc()
+			d.db.UpdateDocument(document)
+			return fmt.Errorf("failed to extract text: %w", err)
+		}
+		text = fileprocessor.JoinPages(pages)
+
+		if d.cfg.StoreExtractedText {
+			// Storing the text is an optimisation, so processing carries on without it
+			key := document.ExtractedTextKey()
+			if _, err := d.s3Client.UploadBytes(key, []byte(text), "text/plain; charset=utf-8", nil); err != nil {
+				d.logger.Warn("Failed to store extracted text",
+					zap.String("document_id", documentID), zap.Error(err))
+			} else {
+				document.TextS3Key = key
+			}
+		}
 	}
 
 	// Create chunks
-	chunkTexts := d.processor.ChunkText(text, d.cfg.ChunkSize, d.cfg.ChunkOverlap)
+	pageChunks := d.chunkPages(pages)
 
 	// Convert to DocumentChunk objects with metadata
 	var chunks []models.DocumentChunk
-	for i, chunkText := range chunkTexts {
-		chunk := models.NewDocumentChunk(documentID, userID, chunkText, i)
+	for i, pageChunk := range pageChunks {
+		chunk := models.NewDocumentChunk(documentID, userID, pageChunk.text, i)
 		// Add document metadata to chunk for better retrieval
 		chunk.SetMetadata("document_title", document.Title)
 		chunk.SetMetadata("document_category", document.Category)
 		chunk.SetMetadata("document_file_type", document.FileType)
 		chunk.SetMetadata("upload_time", document.UploadTime.Format(time.RFC3339))
+		if pageChunk.page > 0 {
+			chunk.SetMetadata("page_number", strconv.Itoa(pageChunk.page))
+		}
 		chunks = append(chunks, *chunk)
 	}
 
+	// Store the full chunk text, replacing chunks from any earlier run, so content cut from the
+	// vector metadata can still be resolved
+	if err := d.db.DeleteDocumentChunks(documentID); err == nil {
+		err = d.db.PutDocumentChunks(chunks)
+	}
+	if err != nil {
+		document.MarkAsFailed("Failed to store document chunks")
+		metrics.DocumentsProcessed.WithLabelValues(models.StatusFailed).Inc()
+		d.db.UpdateDocument(document)
+		return fmt.Errorf("failed to store document chunks: %w", err)
+	}
+
 	// Index chunks in Pinecone
-	if err := d.ragService.ProcessDocumentChunks(userID, documentID, chunks); err != nil {
-		document.MarkAsFailed("Failed to index document in vector database")
+	if err := d.ragService.ProcessDocumentChunks(ctx, userID, documentID, chunks); err != nil {
+		document.MarkAsFailed(d.processingFailure(ctx, "Failed to index document in vector database"))
+		metrics.DocumentsProcessed.WithLabelValues(models.StatusFailed).Inc()
 		d.db.UpdateDocument(document)
 		return fmt.Errorf("failed to index document chunks: %w", err)
 	}
 
+	// Keep any lab values and their reference ranges for reconciliation against tracked metrics
+	document.LabResults = ExtractLabResults(text)
+
 	// Mark as processed
 	document.MarkAsProcessed(len(chunks))
 	if err := d.db.UpdateDocument(document); err != nil {
 		return fmt.Errorf("failed to update document status: %w", err)
 	}
+	metrics.DocumentsProcessed.WithLabelValues(models.StatusProcessed).Inc()
 
 	return nil
 }
 
+// processingFailure returns the error message for a failed processing step, replacing it with a
+// timeout message when the processing deadline has passed
+func (d *DocumentService) processingFailure(ctx context.Context, message string) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Sprintf("Processing timed out after %d seconds", d.cfg.ProcessingTimeoutSec)
+	}
+	return message
+}
+
 // RetryProcessDocument retries processing for a failed document
-func (d *DocumentService) RetryProcessDocument(userID, documentID string) error {
+func (d *DocumentService) RetryProcessDocument(ctx context.Context, userID, documentID string) error {
 	// Get document
 	document, err := d.db.GetDocument(userID, documentID)
 	if err != nil {
@@ -241,7 +735,7 @@ func (d *DocumentService) RetryProcessDocument(userID, documentID string) error
 
 	// Reset error message and process
 	document.ErrorMessage = ""
-	return d.ProcessDocument(userID, documentID)
+	return d.ProcessDocument(ctx, userID, documentID)
 }
 
 // GetDocumentContent retrieves the content of a document
@@ -254,14 +748,174 @@ func (d *DocumentService) GetDocumentContent(userID, documentID string) ([]byte,
 	return d.s3Client.DownloadFile(document.S3Key)
 }
 
-// GetDocumentViewURL generates a presigned URL for viewing a document
-func (d *DocumentService) GetDocumentViewURL(userID, documentID string, expirationMinutes int) (string, error) {
+// GetChunkContent returns the text of a single chunk from the chunks table. It is called while
+// answering queries, so it never falls back to downloading and re-chunking the original file;
+// documents processed before chunks were stored resolve to an error and callers keep whatever
+// text the vector metadata carries. Reprocessing such a document fills in its chunks.
+func (d *DocumentService) GetChunkContent(userID, documentID string, chunkIndex int) (string, error) {
+	chunk, err := d.db.GetDocumentChunk(documentID, chunkIndex)
+	if err != nil {
+		return "", fmt.Errorf("failed to read chunk %d of document %s: %w", chunkIndex, documentID, err)
+	}
+	if chunk == nil || chunk.UserID != userID {
+		return "", fmt.Errorf("chunk %d of document %s not stored", chunkIndex, documentID)
+	}
+
+	return chunk.Content, nil
+}
+
+// pageChunk is a chunk of document text and the page it came from, 0 when unknown
+type pageChunk struct {
+	text string
+	page int
+}
+
+// chunkPages chunks each page separately so every chunk belongs to exactly one page
+func (d *DocumentService) chunkPages(pages []fileprocessor.PageText) []pageChunk {
+	var chunks []pageChunk
+	for _, page := range pages {
+		for _, text := range d.chunkText(page.Text) {
+			chunks = append(chunks, pageChunk{text: text, page: page.Number})
+		}
+	}
+	return chunks
+}
+
+// chunkText splits extracted text using the configured chunking strategy
+func (d *DocumentService) chunkText(text string) []string {
+	if d.cfg.ChunkByTokens {
+		return d.processor.ChunkTextByTokens(text, d.cfg.ChunkMaxTokens, d.cfg.ChunkOverlapTokens)
+	}
+	return d.processor.ChunkText(text, d.cfg.ChunkSize, d.cfg.ChunkOverlap)
+}
+
+// documentPages returns the document's stored extracted text split into pages, falling back to
+// downloading and extracting the original file
+func (d *DocumentService) documentPages(document *models.Document) ([]fileprocessor.PageText, error) {
+	text, err := d.storedText(document)
+	if err == nil && text != "" {
+		return fileprocessor.SplitPages(text), nil
+	}
+
+	fileData, err := d.s3Client.DownloadFile(document.S3Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+
+	pages, err := d.processor.ExtractTextWithPages(fileData, document.FileType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract text: %w", err)
+	}
+
+	return pages, nil
+}
+
+// storedText reads the document's extracted text from S3, or returns "" when none was stored
+func (d *DocumentService) storedText(document *models.Document) (string, error) {
+	if document.TextS3Key == "" {
+		return "", nil
+	}
+
+	data, err := d.s3Client.DownloadFile(document.TextS3Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to download extracted text: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// RecordDocumentAccess stores an access-log entry for a document
+func (d *DocumentService) RecordDocumentAccess(userID, documentID, action, ipAddress, userAgent string) error {
+	retention := time.Duration(d.cfg.AccessLogRetentionDays) * 24 * time.Hour
+	entry := models.NewDocumentAccessLog(documentID, userID, action, ipAddress, userAgent, retention)
+
+	if err := d.db.PutDocumentAccessLog(entry); err != nil {
+		return fmt.Errorf("failed to record document access: %w", err)
+	}
+
+	return nil
+}
+
+// GetDocumentAccessLog returns access records for a document owned by the user
+func (d *DocumentService) GetDocumentAccessLog(userID, documentID string, limit int) ([]models.DocumentAccessLog, error) {
+	// Only the owner may read a document's access log
+	if _, err := d.db.GetDocument(userID, documentID); err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	entries, err := d.db.GetDocumentAccessLogs(documentID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ReconcileReferenceRanges compares lab values found in a document, interpreted with the lab's own
+// reference ranges, against the default normal ranges and the user's latest tracked metrics
+func (d *DocumentService) ReconcileReferenceRanges(userID, documentID string) (*models.ReferenceRangeReport, error) {
 	document, err := d.db.GetDocument(userID, documentID)
 	if err != nil {
-		return "", fmt.Errorf("failed to get document: %w", err)
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	// Documents processed before lab extraction existed have no stored results; extract them once now
+	if document.LabResults == nil && document.Status == models.StatusProcessed {
+		pages, err := d.documentPages(document)
+		if err != nil {
+			return nil, err
+		}
+
+		document.LabResults = ExtractLabResults(fileprocessor.JoinPages(pages))
+		if document.LabResults == nil {
+			document.LabResults = []models.LabResult{} // Remember that extraction ran and found nothing
+		}
+		if err := d.db.UpdateDocument(document); err != nil {
+			return nil, fmt.Errorf("failed to store lab results: %w", err)
+		}
+	}
+
+	latest, err := d.db.GetLatestHealthMetrics(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest metrics: %w", err)
+	}
+
+	report := &models.ReferenceRangeReport{
+		DocumentID: documentID,
+		Results:    make([]models.RangeReconciliation, 0, len(document.LabResults)),
+	}
+
+	for _, result := range document.LabResults {
+		var tracked *models.HealthMetric
+		if metric, exists := latest[result.MetricType]; exists {
+			tracked = &metric
+		}
+
+		reconciliation := reconcileLabResult(result, tracked)
+		if reconciliation.Discrepancy {
+			report.DiscrepancyCount++
+		}
+		report.Results = append(report.Results, reconciliation)
+	}
+
+	return report, nil
+}
+
+// GetDocumentViewURL generates a presigned URL for viewing a document.
+// It returns the URL and the effective expiration in minutes after applying the server-side maximum.
+func (d *DocumentService) GetDocumentViewURL(userID, documentID string, expirationMinutes int) (string, int, error) {
+	document, err := d.db.GetDocument(userID, documentID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	effectiveMinutes := d.s3Client.EffectivePresignMinutes(expirationMinutes)
+	url, err := d.s3Client.GeneratePresignedURL(document.S3Key, effectiveMinutes)
+	if err != nil {
+		return "", 0, err
 	}
 
-	return d.s3Client.GeneratePresignedURL(document.S3Key, expirationMinutes)
+	return url, effectiveMinutes, nil
 }
 
 // validateFile validates the uploaded file
@@ -0,0 +1,498 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/internal/vectordb"
+)
+
+// newTestDocumentService returns a DocumentService without S3 or a vector store
+func newTestDocumentService(t *testing.T, configure func(cfg *config.Config)) (*DocumentService, *database.DynamoDBClient, *dynamotest.Fake) {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	db, fake := dynamotest.NewClient(cfg)
+	service := NewDocumentService(nil, db, nil, cfg)
+	t.Cleanup(func() { service.Shutdown(context.Background()) })
+	return service, db, fake
+}
+
+// putDocument stores a processed document record
+func putDocument(t *testing.T, db *database.DynamoDBClient, userID, documentID string) *models.Document {
+	t.Helper()
+
+	document := &models.Document{
+		UserID:     userID,
+		DocumentID: documentID,
+		SortKey:    "general#" + documentID,
+		Title:      "Lipid panel",
+		FileName:   documentID + ".pdf",
+		Category:   "general",
+		Status:     models.StatusProcessed,
+		UploadTime: time.Now().UTC(),
+	}
+	if err := db.PutDocument(document); err != nil {
+		t.Fatalf("put document: %v", err)
+	}
+	return document
+}
+
+func TestDocumentAccessLogIsOwnerOnlyAndExpires(t *testing.T) {
+	service, db, fake := newTestDocumentService(t, func(cfg *config.Config) { cfg.AccessLogRetentionDays = 30 })
+	putDocument(t, db, "user-1", "doc-1")
+
+	for _, action := range []string{"view", "download"} {
+		if err := service.RecordDocumentAccess("user-1", "doc-1", action, "203.0.113.7", "test-agent"); err != nil {
+			t.Fatalf("record access: %v", err)
+		}
+	}
+
+	entries, err := service.GetDocumentAccessLog("user-1", "doc-1", 10)
+	if err != nil {
+		t.Fatalf("get access log: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != "download" || entries[1].Action != "view" {
+		t.Fatalf("entries = %+v, want download then view (latest first)", entries)
+	}
+	if entries[0].IPAddress != "203.0.113.7" || entries[0].UserID != "user-1" {
+		t.Errorf("entry = %+v, want the caller's user and IP", entries[0])
+	}
+
+	wantExpiry := time.Now().Add(30 * 24 * time.Hour).Unix()
+	for _, item := range fake.Items(service.cfg.DynamoDBTableAccessLog) {
+		if item["expires_at"] == nil || item["expires_at"].N == nil {
+			t.Fatalf("access-log item has no expires_at TTL attribute")
+		}
+		var expiresAt int64
+		if _, err := fmt.Sscan(*item["expires_at"].N, &expiresAt); err != nil || expiresAt < wantExpiry-60 || expiresAt > wantExpiry+60 {
+			t.Errorf("expires_at = %s, want about %d", *item["expires_at"].N, wantExpiry)
+		}
+	}
+
+	if _, err := service.GetDocumentAccessLog("user-2", "doc-1", 10); err == nil {
+		t.Error("another user read the access log")
+	}
+}
+
+func TestUpdateDocumentDetailsRejectsConflictingUpdate(t *testing.T) {
+	service, db, _ := newTestDocumentService(t, nil)
+	putDocument(t, db, "user-1", "doc-1")
+
+	// Two tabs read the document at version 1; the first save wins
+	first, second := "Lipid panel (March)", "Cholesterol results"
+	updated, err := service.UpdateDocumentDetails("user-1", "doc-1", &models.DocumentUpdateRequest{Title: &first}, 1)
+	if err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("version after first update = %d, want 2", updated.Version)
+	}
+
+	if _, err := service.UpdateDocumentDetails("user-1", "doc-1", &models.DocumentUpdateRequest{Title: &second}, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale update returned %v, want ErrVersionConflict", err)
+	}
+
+	// A write racing past the version check is still caught by the conditional update
+	stale, err := db.GetDocument("user-1", "doc-1")
+	if err != nil {
+		t.Fatalf("get document: %v", err)
+	}
+	if _, err := service.UpdateDocumentDetails("user-1", "doc-1", &models.DocumentUpdateRequest{Title: &second}, 2); err != nil {
+		t.Fatalf("second update: %v", err)
+	}
+	stale.Title = "Lost update"
+	if err := db.UpdateDocumentDetails(stale, 2); !errors.Is(err, database.ErrVersionConflict) {
+		t.Errorf("racing write returned %v, want database.ErrVersionConflict", err)
+	}
+
+	document, err := db.GetDocument("user-1", "doc-1")
+	if err != nil {
+		t.Fatalf("get document: %v", err)
+	}
+	if document.Title != second || document.Version != 3 {
+		t.Errorf("got %q at version %d, want %q at version 3", document.Title, document.Version, second)
+	}
+
+	// Without an expected version the update is unconditional
+	if _, err := service.UpdateDocumentDetails("user-1", "doc-1", &models.DocumentUpdateRequest{Title: &first}, -1); err != nil {
+		t.Errorf("unconditional update: %v", err)
+	}
+}
+
+// indexingFixture is a DocumentService that processes documents end to end against a fake S3,
+// vector store and embedding client
+type indexingFixture struct {
+	cfg        *config.Config
+	service    *DocumentService
+	db         *database.DynamoDBClient
+	s3         *fakeS3
+	vectors    *fakeVectorStore
+	embeddings *fakeEmbeddingClient
+}
+
+func newIndexingFixture(t *testing.T, configure func(cfg *config.Config)) *indexingFixture {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.S3Bucket = "health-documents"
+	if configure != nil {
+		configure(cfg)
+	}
+
+	f := &indexingFixture{
+		cfg:        cfg,
+		vectors:    newFakeVectorStore(testEmbeddingDimension),
+		embeddings: newFakeEmbeddingClient(testEmbeddingDimension),
+	}
+	f.db, _ = dynamotest.NewClient(cfg)
+	s3Client, s3 := newTestS3Client(t, cfg)
+	f.s3 = s3
+	rag := NewRAGService(f.vectors, &fakeLLMClient{}, f.embeddings, cfg)
+	f.service = NewDocumentService(s3Client, f.db, rag, cfg)
+	t.Cleanup(func() { f.service.Shutdown(context.Background()) })
+	return f
+}
+
+// putTextDocument stores a text document's record and file, in the given status
+func (f *indexingFixture) putTextDocument(t *testing.T, userID, documentID, status, text string) *models.Document {
+	t.Helper()
+
+	document := &models.Document{
+		UserID:     userID,
+		DocumentID: documentID,
+		SortKey:    "general#" + documentID,
+		Title:      "Lipid panel",
+		FileName:   documentID + ".txt",
+		FileType:   "txt",
+		S3Key:      "documents/" + userID + "/" + documentID + ".txt",
+		Category:   "general",
+		Status:     status,
+		UploadTime: time.Now().UTC(),
+	}
+	if err := f.db.PutDocument(document); err != nil {
+		t.Fatalf("put document: %v", err)
+	}
+	f.s3.put(document.S3Key, []byte(text))
+	return document
+}
+
+// waitForStatus polls until the document reaches status, failing after a few seconds
+func (f *indexingFixture) waitForStatus(t *testing.T, userID, documentID, status string) *models.Document {
+	t.Helper()
+
+	var document *models.Document
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var err error
+		if document, err = f.db.GetDocument(userID, documentID); err != nil {
+			t.Fatalf("get document: %v", err)
+		}
+		if document.Status == status {
+			return document
+		}
+	}
+	t.Fatalf("document %s is %s (%s), want %s", documentID, document.Status, document.ErrorMessage, status)
+	return nil
+}
+
+// documentVectorIDs returns the IDs of a document's stored vectors
+func (f *indexingFixture) documentVectorIDs(userID, documentID string) map[string]bool {
+	f.vectors.mu.Lock()
+	defer f.vectors.mu.Unlock()
+
+	ids := make(map[string]bool)
+	for id, vector := range f.vectors.vectors[f.vectors.Namespace(userID)] {
+		if vector.Metadata["document_id"] == documentID {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+func TestReprocessAllReplacesDocumentVectors(t *testing.T) {
+	f := newIndexingFixture(t, nil)
+	f.putTextDocument(t, "user-1", "doc-1", models.StatusProcessed, "Total cholesterol 212 mg/dL. LDL 140 mg/dL.")
+	f.putTextDocument(t, "user-1", "doc-2", models.StatusFailed, "Not indexed yet.")
+
+	// Vectors from the previous embedding model
+	stale := vectordb.Vector{ID: "doc-1#stale", Values: make([]float32, testEmbeddingDimension), Metadata: vectordb.VectorMetadata{
+		"user_id": "user-1", "document_id": "doc-1", "content": "old embedding",
+	}}
+	if err := f.vectors.UpsertVectors(context.Background(), f.vectors.Namespace("user-1"), []vectordb.Vector{stale}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	result, err := f.service.ReprocessAll(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("reprocess: %v", err)
+	}
+	if result.Total != 1 || result.Queued != 1 || result.Skipped != 1 || len(result.Failures) != 0 {
+		t.Fatalf("result = %+v, want the processed document queued and the failed one skipped", result)
+	}
+
+	document := f.waitForStatus(t, "user-1", "doc-1", models.StatusProcessed)
+	ids := f.documentVectorIDs("user-1", "doc-1")
+	if ids["doc-1#stale"] {
+		t.Error("old vector still stored after reprocessing")
+	}
+	if len(ids) == 0 || len(ids) != document.ChunkCount {
+		t.Errorf("stored %d vectors for %d chunks, want the document re-indexed", len(ids), document.ChunkCount)
+	}
+}
+
+func TestSlowEmbeddingMarksDocumentTimedOut(t *testing.T) {
+	f := newIndexingFixture(t, func(cfg *config.Config) { cfg.ProcessingTimeoutSec = 1 })
+	f.embeddings.embed = func(ctx context.Context, text string) ([]float32, error) {
+		<-ctx.Done() // Never answers before the processing deadline
+		return nil, ctx.Err()
+	}
+	f.putTextDocument(t, "user-1", "doc-1", models.StatusUploaded, "Total cholesterol 212 mg/dL. LDL 140 mg/dL.")
+
+	if err := f.service.ProcessDocument(context.Background(), "user-1", "doc-1"); err == nil {
+		t.Fatal("processing succeeded despite the embedding timeout")
+	}
+
+	document, err := f.db.GetDocument("user-1", "doc-1")
+	if err != nil {
+		t.Fatalf("get document: %v", err)
+	}
+	if document.Status != models.StatusFailed || document.ErrorMessage != "Processing timed out after 1 seconds" {
+		t.Errorf("status %q error %q, want failed with the timeout message", document.Status, document.ErrorMessage)
+	}
+}
+
+func TestExtractedTextIsStoredReusedAndDeleted(t *testing.T) {
+	f := newIndexingFixture(t, func(cfg *config.Config) { cfg.StoreExtractedText = true })
+	document := f.putTextDocument(t, "user-1", "doc-1", models.StatusUploaded, "Total cholesterol 212 mg/dL.")
+	textKey := document.ExtractedTextKey()
+
+	if err := f.service.ProcessDocument(context.Background(), "user-1", "doc-1"); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	document = f.waitForStatus(t, "user-1", "doc-1", models.StatusProcessed)
+	f.s3.mu.Lock()
+	stored, ok := f.s3.objects[textKey]
+	f.s3.mu.Unlock()
+	if document.TextS3Key != textKey || !ok || string(stored) != "Total cholesterol 212 mg/dL." {
+		t.Fatalf("text key %q stored %q, want the extracted text under %s", document.TextS3Key, stored, textKey)
+	}
+
+	// A second run reads the stored text rather than extracting the original again
+	f.s3.put(document.S3Key, []byte("Replaced original that must not be read."))
+	document.Status = models.StatusUploaded
+	if err := f.db.UpdateDocument(document); err != nil {
+		t.Fatalf("update document: %v", err)
+	}
+	if err := f.service.ProcessDocument(context.Background(), "user-1", "doc-1"); err != nil {
+		t.Fatalf("reprocess: %v", err)
+	}
+	f.embeddings.mu.Lock()
+	embedded := strings.Join(f.embeddings.texts, "\n")
+	f.embeddings.mu.Unlock()
+	if strings.Contains(embedded, "Replaced original") {
+		t.Error("reprocessing re-extracted the original instead of reusing the stored text")
+	}
+
+	if err := f.service.DeleteDocument("user-1", "doc-1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	f.s3.mu.Lock()
+	defer f.s3.mu.Unlock()
+	if _, ok := f.s3.objects[textKey]; ok {
+		t.Error("extracted text left in S3 after the document was deleted")
+	}
+}
+
+// buildPDF returns a PDF with one page per text, each drawn in a single line of Helvetica. An
+// empty text gives a page without any.
+func buildPDF(pages ...string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // Pages, filled in below
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var kids []string
+	for _, text := range pages {
+		content := ""
+		if text != "" {
+			content = fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		}
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", len(objects)))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestPDFChunksCarryTheirPageNumbers(t *testing.T) {
+	f := newIndexingFixture(t, nil)
+	document := f.putTextDocument(t, "user-1", "doc-1", models.StatusUploaded, "")
+	document.FileName, document.FileType = "labs.pdf", "pdf"
+	if err := f.db.PutDocument(document); err != nil {
+		t.Fatalf("put document: %v", err)
+	}
+	// The second page is blank, so the chunks come from pages 1 and 3
+	f.s3.put(document.S3Key, buildPDF("Total cholesterol 212 mg/dL", "", "LDL 140 mg/dL"))
+
+	if err := f.service.ProcessDocument(context.Background(), "user-1", "doc-1"); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	f.vectors.mu.Lock()
+	defer f.vectors.mu.Unlock()
+	pages := make(map[string]string)
+	for _, vector := range f.vectors.vectors[f.vectors.Namespace("user-1")] {
+		pages[fmt.Sprint(vector.Metadata["content"])] = fmt.Sprint(vector.Metadata["page_number"])
+	}
+	want := map[string]string{"Total cholesterol 212 mg/dL": "1", "LDL 140 mg/dL": "3"}
+	if len(pages) != len(want) {
+		t.Fatalf("indexed chunks %v, want one per page with text", pages)
+	}
+	for content, page := range want {
+		if pages[content] != page {
+			t.Errorf("chunk %q on page %s, want %s", content, pages[content], page)
+		}
+	}
+}
+
+// uploadFile returns a multipart file header for content, as the upload handler receives it
+func uploadFile(t *testing.T, fileName string, content []byte) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("read form: %v", err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+func TestDuplicateUploadReturnsExistingDocument(t *testing.T) {
+	// Only uploads are stored, so the objects in S3 can be counted
+	f := newIndexingFixture(t, func(cfg *config.Config) { cfg.StoreExtractedText = false })
+	content := []byte("Total cholesterol 212 mg/dL. LDL 140 mg/dL.")
+	request := &models.DocumentUploadRequest{Title: "Lipid panel", Category: "general"}
+
+	first, err := f.service.UploadDocument("user-1", uploadFile(t, "labs.txt", content), request)
+	if err != nil {
+		t.Fatalf("first upload: %v", err)
+	}
+	if first.Duplicate || first.Document.ContentHash == "" {
+		t.Fatalf("first upload = %+v, want a new document with its content hash", first)
+	}
+
+	// The same bytes under another name are still a duplicate
+	second, err := f.service.UploadDocument("user-1", uploadFile(t, "labs-again.txt", content), request)
+	if err != nil {
+		t.Fatalf("second upload: %v", err)
+	}
+	if !second.Duplicate || second.Document.DocumentID != first.Document.DocumentID {
+		t.Errorf("second upload = %+v, want the first document flagged as a duplicate", second)
+	}
+
+	f.s3.mu.Lock()
+	objects := len(f.s3.objects)
+	f.s3.mu.Unlock()
+	if objects != 1 {
+		t.Errorf("%d objects in S3, want the file uploaded once", objects)
+	}
+
+	// Hashes are per user
+	other, err := f.service.UploadDocument("user-2", uploadFile(t, "labs.txt", content), request)
+	if err != nil {
+		t.Fatalf("upload by another user: %v", err)
+	}
+	if other.Duplicate {
+		t.Error("another user's upload of the same file flagged as a duplicate")
+	}
+}
+
+func TestSearchMetadataRanksByMatchLocation(t *testing.T) {
+	service, db, fake := newTestDocumentService(t, nil)
+	fake.MaxPageItems = 2 // Every page of documents is searched
+
+	for id, fields := range map[string][3]string{ // Title, description, tag
+		"doc-title":       {"Statin prescription", "", ""},
+		"doc-late-title":  {"Refill of statin", "", ""},
+		"doc-description": {"Pharmacy receipt", "Atorvastatin 20mg, a STATIN", ""},
+		"doc-tag":         {"Cardiology letter", "Follow-up visit", "statins"},
+		"doc-none":        {"Lipid panel", "Fasting bloods", "lab"},
+	} {
+		document := putDocument(t, db, "user-1", id)
+		document.Title, document.Description = fields[0], fields[1]
+		if fields[2] != "" {
+			document.Tags = []string{fields[2]}
+		}
+		if err := db.PutDocument(document); err != nil {
+			t.Fatalf("put document: %v", err)
+		}
+	}
+	other := putDocument(t, db, "user-2", "doc-other")
+	other.Title = "Statin side effects"
+	if err := db.PutDocument(other); err != nil {
+		t.Fatalf("put document: %v", err)
+	}
+
+	results, err := service.SearchMetadata("user-1", "Statin", 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	var ids []string
+	for _, document := range results {
+		ids = append(ids, document.DocumentID)
+	}
+	if got := strings.Join(ids, ","); got != "doc-title,doc-late-title,doc-description,doc-tag" {
+		t.Errorf("results %s, want title matches (earliest first), then description, then tag", got)
+	}
+
+	if results, err := service.SearchMetadata("user-1", "statin", 2); err != nil || len(results) != 2 {
+		t.Errorf("limited search: %d results (err %v), want 2", len(results), err)
+	}
+	if results, err := service.SearchMetadata("user-1", "insulin", 0); err != nil || len(results) != 0 {
+		t.Errorf("no match: %d results (err %v), want none", len(results), err)
+	}
+}
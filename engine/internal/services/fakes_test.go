@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/storage"
+	"health-dashboard-backend/internal/vectordb"
+	"health-dashboard-backend/pkg/ai"
+)
+
+// fakeVectorStore is an in-memory VectorStore. Queries score vectors by dot product and apply
+// metadata filters with plain values, $eq, $ne, $in, $gt, $gte, $lt and $lte.
+type fakeVectorStore struct {
+	mu        sync.Mutex
+	dimension int
+	vectors   map[string]map[string]vectordb.Vector // namespace -> ID -> vector
+	upserts   int
+	queries   []vectordb.VectorMetadata // Filters of each query, in order
+
+	upserted chan struct{} // Optional; receives after each upsert
+}
+
+func newFakeVectorStore(dimension int) *fakeVectorStore {
+	return &fakeVectorStore{
+		dimension: dimension,
+		vectors:   make(map[string]map[string]vectordb.Vector),
+	}
+}
+
+func (f *fakeVectorStore) Namespace(userID string) string {
+	return "user-" + userID
+}
+
+func (f *fakeVectorStore) UpsertVectors(ctx context.Context, namespace string, vectors []vectordb.Vector) error {
+	f.mu.Lock()
+	if f.vectors[namespace] == nil {
+		f.vectors[namespace] = make(map[string]vectordb.Vector)
+	}
+	for _, vector := range vectors {
+		f.vectors[namespace][vector.ID] = vector
+	}
+	f.upserts++
+	upserted := f.upserted
+	f.mu.Unlock()
+
+	if upserted != nil {
+		upserted <- struct{}{}
+	}
+	return nil
+}
+
+func (f *fakeVectorStore) QueryVectors(ctx context.Context, namespace string, queryVector []float32, topK int, filter vectordb.VectorMetadata) (*vectordb.QueryResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queries = append(f.queries, filter)
+
+	var results []vectordb.QueryResult
+	for _, vector := range f.vectors[namespace] {
+		if !matchesFilter(vector.Metadata, filter) {
+			continue
+		}
+		var score float32
+		for i := range vector.Values {
+			if i < len(queryVector) {
+				score += vector.Values[i] * queryVector[i]
+			}
+		}
+		results = append(results, vectordb.QueryResult{ID: vector.ID, Score: score, Metadata: vector.Metadata})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return &vectordb.QueryResponse{Results: results}, nil
+}
+
+func (f *fakeVectorStore) DeleteVectorsByFilter(ctx context.Context, namespace string, filter vectordb.VectorMetadata) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for id, vector := range f.vectors[namespace] {
+		if matchesFilter(vector.Metadata, filter) {
+			delete(f.vectors[namespace], id)
+		}
+	}
+	return nil
+}
+
+func (f *fakeVectorStore) IndexDimension(ctx context.Context) (int, error) {
+	return f.dimension, nil
+}
+
+func (f *fakeVectorStore) GetIndexStats(ctx context.Context) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts := make(map[string]int)
+	for namespace, vectors := range f.vectors {
+		counts[namespace] = len(vectors)
+	}
+	return counts, nil
+}
+
+// count returns how many vectors a namespace holds
+func (f *fakeVectorStore) count(namespace string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.vectors[namespace])
+}
+
+// matchesFilter applies a Pinecone-style metadata filter
+func matchesFilter(metadata, filter vectordb.VectorMetadata) bool {
+	for key, condition := range filter {
+		value, exists := metadata[key]
+		operators, isOperator := condition.(map[string]interface{})
+		if !isOperator {
+			operators = map[string]interface{}{"$eq": condition}
+		}
+
+		for op, operand := range operators {
+			var ok bool
+			switch op {
+			case "$eq":
+				ok = exists && equalValues(value, operand)
+			case "$ne":
+				ok = !exists || !equalValues(value, operand)
+			case "$in":
+				for _, candidate := range toSlice(operand) {
+					ok = ok || (exists && equalValues(value, candidate))
+				}
+			case "$gt", "$gte", "$lt", "$lte":
+				x, xok := toFloat(value)
+				y, yok := toFloat(operand)
+				ok = exists && xok && yok && map[string]bool{"$gt": x > y, "$gte": x >= y, "$lt": x < y, "$lte": x <= y}[op]
+			default:
+				panic("unsupported filter operator " + op)
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func equalValues(a, b interface{}) bool {
+	x, xok := toFloat(a)
+	y, yok := toFloat(b)
+	if xok && yok {
+		return x == y
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func toSlice(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		values := make([]interface{}, len(v))
+		for i, s := range v {
+			values[i] = s
+		}
+		return values
+	}
+	return []interface{}{value}
+}
+
+// fakeEmbeddingClient returns deterministic embeddings derived from a hash of each text
+type fakeEmbeddingClient struct {
+	mu        sync.Mutex
+	dimension int
+	calls     int      // GenerateEmbedding and GenerateEmbeddings calls
+	texts     []string // Every text embedded, in order
+
+	// embed, when set, replaces the hash-based embedding
+	embed func(ctx context.Context, text string) ([]float32, error)
+}
+
+func newFakeEmbeddingClient(dimension int) *fakeEmbeddingClient {
+	return &fakeEmbeddingClient{dimension: dimension}
+}
+
+func (f *fakeEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	f.mu.Lock()
+	f.calls++
+	f.texts = append(f.texts, text)
+	f.mu.Unlock()
+
+	return f.embedding(ctx, text)
+}
+
+func (f *fakeEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	f.calls++
+	f.texts = append(f.texts, texts...)
+	f.mu.Unlock()
+
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := f.embedding(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+func (f *fakeEmbeddingClient) embedding(ctx context.Context, text string) ([]float32, error) {
+	if f.embed != nil {
+		return f.embed(ctx, text)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(text))
+	seed := h.Sum32()
+
+	embedding := make([]float32, f.dimension)
+	for i := range embedding {
+		seed = seed*1664525 + 1013904223
+		embedding[i] = float32(seed%1000) / 1000
+	}
+	return embedding, nil
+}
+
+// callCount returns how many embedding requests were made
+func (f *fakeEmbeddingClient) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// fakeLLMClient records the messages it is sent and answers with a canned reply
+type fakeLLMClient struct {
+	mu       sync.Mutex
+	reply    string
+	requests [][]ai.ChatMessage
+	options  []ai.GenerateOptions
+}
+
+func (f *fakeLLMClient) GenerateResponse(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (*ai.ChatResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, messages)
+	f.options = append(f.options, opts)
+	return &ai.ChatResponse{Content: f.reply, TokensUsed: 42}, nil
+}
+
+func (f *fakeLLMClient) GenerateResponseStream(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (<-chan ai.StreamChunk, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, messages)
+	f.options = append(f.options, opts)
+	f.mu.Unlock()
+
+	chunks := make(chan ai.StreamChunk, 2)
+	chunks <- ai.StreamChunk{Content: f.reply}
+	chunks <- ai.StreamChunk{Done: true}
+	close(chunks)
+	return chunks, nil
+}
+
+func (f *fakeLLMClient) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// lastRequest returns the messages of the latest call
+func (f *fakeLLMClient) lastRequest() []ai.ChatMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.requests) == 0 {
+		return nil
+	}
+	return f.requests[len(f.requests)-1]
+}
+
+// fakeS3 is an in-memory S3 bucket serving single-part uploads, downloads and deletes by key
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Path-style requests: /<bucket>/<key>
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		http.Error(w, "bucket operations aren't supported", http.StatusNotImplemented)
+		return
+	}
+	key := parts[1]
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+		w.Header().Set("ETag", `"object"`)
+	case http.MethodGet, http.MethodHead:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported method", http.StatusNotImplemented)
+	}
+}
+
+// put stores an object as if it had been uploaded
+func (f *fakeS3) put(key string, body []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = body
+}
+
+// newTestS3Client returns an S3Client backed by a fakeS3
+func newTestS3Client(t *testing.T, cfg *config.Config) (*storage.S3Client, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("test", "test", ""),
+	})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	return storage.NewS3ClientWithSession(sess, cfg), fake
+}
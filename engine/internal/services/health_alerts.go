@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// criticalLimits are the values beyond which a reading needs prompt attention, in canonical units.
+// Readings outside the normal range but within these limits are warnings, or info when only
+// mildly out of range.
+var criticalLimits = map[string]struct{ low, high float64 }{
+	"blood_oxygen_saturation":    {low: 92, high: math.Inf(1)},
+	"blood_pressure_systolic":    {low: 80, high: 180},
+	"blood_pressure_diastolic":   {low: 40, high: 120},
+	"heart_rate":                 {low: 40, high: 130},
+	"blood_glucose_fasting":      {low: 54, high: 300},
+	"blood_glucose_postprandial": {low: 54, high: 300},
+	"body_temperature":           {low: 35, high: 39.5},
+}
+
+// alertSeverityOrder sorts alerts with the most severe first
+var alertSeverityOrder = map[string]int{
+	models.AlertSeverityCritical: 0,
+	models.AlertSeverityWarning:  1,
+	models.AlertSeverityInfo:     2,
+}
+
+// GenerateAlerts checks the user's latest reading of each metric against its effective normal
+// range and returns an alert for every reading outside it, most severe first
+func (h *HealthService) GenerateAlerts(userID string) ([]models.HealthAlert, error) {
+	latest, err := h.db.GetLatestHealthMetrics(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest health metrics: %w", err)
+	}
+
+	customRanges, err := h.GetCustomRanges(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []models.HealthAlert{}
+	for _, metric := range latest {
+		if alert := metricAlert(&metric, customRanges); alert != nil {
+			alerts = append(alerts, *alert)
+		}
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Severity != alerts[j].Severity {
+			return alertSeverityOrder[alerts[i].Severity] < alertSeverityOrder[alerts[j].Severity]
+		}
+		return alerts[i].MetricType < alerts[j].MetricType
+	})
+
+	return alerts, nil
+}
+
+// metricAlert returns the alert for a reading outside its normal range, or nil when it is in range
+// or the metric has none
+func metricAlert(metric *models.HealthMetric, customRanges map[string]models.Range) *models.HealthAlert {
+	normalRange := effectiveRange(metric.Type, customRanges).Range
+	status := normalRange.Status(metric.Value)
+	if status != models.RangeStatusLow && status != models.RangeStatusHigh {
+		return nil
+	}
+
+	severity := models.AlertSeverityWarning
+	limits, hasLimits := criticalLimits[metric.Type]
+	switch {
+	case hasLimits && (metric.Value < limits.low || metric.Value > limits.high):
+		severity = models.AlertSeverityCritical
+	case normalRange.Max > normalRange.Min && rangePoints(metric.Value, normalRange) > 0:
+		severity = models.AlertSeverityInfo
+	}
+
+	metricInfo := models.SupportedMetrics[metric.Type]
+	name, precision := metricInfo.Name, metricInfo.Precision
+	if name == "" {
+		name = metric.Type
+	}
+	format := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', precision, 64)
+	}
+
+	level := status
+	if severity == models.AlertSeverityCritical {
+		level = "critically " + status
+	}
+
+	return &models.HealthAlert{
+		Severity:   severity,
+		MetricType: metric.Type,
+		Value:      metric.Value,
+		Unit:       metric.Unit,
+		Status:     status,
+		Message: fmt.Sprintf("%s of %s %s is %s (normal range %s-%s %s)",
+			name, format(metric.Value), metric.Unit, level,
+			format(normalRange.Min), format(normalRange.Max), metric.Unit),
+		MeasuredAt: metric.Timestamp,
+	}
+}
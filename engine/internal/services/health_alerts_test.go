@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+func TestGenerateAlertsBySeverity(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+	at := time.Now().UTC().Add(-time.Hour)
+
+	addMetric(t, service, "blood_oxygen_saturation", 88, "%", at)
+	addMetric(t, service, "blood_pressure_systolic", 150, "mmHg", at)
+	addMetric(t, service, "heart_rate", 105, "bpm", at)
+	addMetric(t, service, "blood_glucose_fasting", 90, "mg/dL", at)
+
+	alerts, err := service.GenerateAlerts("user-1")
+	if err != nil {
+		t.Fatalf("generate alerts: %v", err)
+	}
+
+	want := []struct{ metricType, severity string }{
+		{"blood_oxygen_saturation", models.AlertSeverityCritical},
+		{"blood_pressure_systolic", models.AlertSeverityWarning},
+		{"heart_rate", models.AlertSeverityInfo},
+	}
+	if len(alerts) != len(want) {
+		t.Fatalf("got %d alerts (%+v), want %d", len(alerts), alerts, len(want))
+	}
+	for i, alert := range alerts {
+		if alert.MetricType != want[i].metricType || alert.Severity != want[i].severity {
+			t.Errorf("alert %d = %s %s, want %s %s", i, alert.Severity, alert.MetricType, want[i].severity, want[i].metricType)
+		}
+	}
+	if alerts[0].Status != models.RangeStatusLow || alerts[0].Message != "Blood Oxygen Saturation (SpO2) of 88 % is critically low (normal range 95-100 %)" {
+		t.Errorf("SpO2 alert = %+v", alerts[0])
+	}
+}
+
+func TestGenerateAlertsNoneWhenInRange(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+	at := time.Now().UTC().Add(-time.Hour)
+
+	addMetric(t, service, "blood_oxygen_saturation", 98, "%", at)
+	addMetric(t, service, "blood_pressure_systolic", 115, "mmHg", at)
+	addMetric(t, service, "heart_rate", 68, "bpm", at)
+	addMetric(t, service, "weight", 80, "kg", at) // No normal range
+
+	alerts, err := service.GenerateAlerts("user-1")
+	if err != nil {
+		t.Fatalf("generate alerts: %v", err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("got alerts %+v for in-range readings", alerts)
+	}
+}
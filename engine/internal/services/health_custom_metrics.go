@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// ErrMetricTypeConflict is returned when a custom metric would reuse the name of a built-in metric
+var ErrMetricTypeConflict = errors.New("metric type conflicts with a built-in metric")
+
+// ErrInvalidCustomMetric is returned when a custom metric definition fails validation
+var ErrInvalidCustomMetric = errors.New("invalid custom metric")
+
+// customMetricCategory is the category given to custom metrics that don't name one
+const customMetricCategory = "custom"
+
+// customMetricTypePattern restricts custom metric types to the same shape as the built-in ones
+var customMetricTypePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// RegisterCustomMetric stores a user-defined metric type, replacing the user's earlier definition
+// of the same type. Built-in metric types can't be redefined.
+func (h *HealthService) RegisterCustomMetric(userID string, input *models.CustomMetricInput) (*models.MetricInfo, error) {
+	metricType := strings.TrimSpace(input.Type)
+	if _, exists := models.SupportedMetrics[metricType]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrMetricTypeConflict, metricType)
+	}
+	if !customMetricTypePattern.MatchString(metricType) {
+		return nil, fmt.Errorf("%w: type must be 2-50 lowercase letters, digits or underscores, starting with a letter", ErrInvalidCustomMetric)
+	}
+
+	metricInfo := models.MetricInfo{
+		Name:        strings.TrimSpace(input.Name),
+		Unit:        strings.TrimSpace(input.Unit),
+		Category:    strings.TrimSpace(input.Category),
+		NormalRange: input.NormalRange,
+		SanityRange: input.SanityRange,
+		Precision:   input.Precision,
+	}
+	if metricInfo.Name == "" || metricInfo.Unit == "" {
+		return nil, fmt.Errorf("%w: name and unit are required", ErrInvalidCustomMetric)
+	}
+	if metricInfo.Category == "" {
+		metricInfo.Category = customMetricCategory
+	}
+	if metricInfo.Precision < 0 {
+		return nil, fmt.Errorf("%w: precision must not be negative", ErrInvalidCustomMetric)
+	}
+	for _, r := range []*models.Range{metricInfo.NormalRange, metricInfo.SanityRange} {
+		if r != nil && r.Min >= r.Max {
+			return nil, fmt.Errorf("%w: range min must be less than max", ErrInvalidCustomMetric)
+		}
+	}
+
+	if _, err := h.updateProfile(userID, func(profile *models.UserHealthProfile) {
+		if profile.CustomMetrics == nil {
+			profile.CustomMetrics = make(map[string]models.MetricInfo)
+		}
+		profile.CustomMetrics[metricType] = metricInfo
+	}); err != nil {
+		return nil, err
+	}
+
+	return &metricInfo, nil
+}
+
+// resolveMetricInfo looks a metric type up in SupportedMetrics and then in the user's custom
+// metrics. exists is false when neither defines it.
+func (h *HealthService) resolveMetricInfo(userID, metricType string) (metricInfo models.MetricInfo, exists bool, err error) {
+	if metricInfo, exists := models.SupportedMetrics[metricType]; exists {
+		return metricInfo, true, nil
+	}
+	if userID == "" {
+		return models.MetricInfo{}, false, nil
+	}
+
+	profile, err := h.db.GetUserHealthProfile(userID)
+	if err != nil {
+		return models.MetricInfo{}, false, fmt.Errorf("failed to get user profile: %w", err)
+	}
+	if profile == nil {
+		return models.MetricInfo{}, false, nil
+	}
+
+	metricInfo, exists = profile.CustomMetrics[metricType]
+	return metricInfo, exists, nil
+}
+
+// metricInfos resolves each metric type like resolveMetricInfo, loading the user's profile at most
+// once. Types that neither SupportedMetrics nor the user's custom metrics define are left out.
+func (h *HealthService) metricInfos(userID string, metricTypes []string) (map[string]models.MetricInfo, error) {
+	infos := make(map[string]models.MetricInfo, len(metricTypes))
+	var customMetrics map[string]models.MetricInfo
+	loaded := false
+	for _, metricType := range metricTypes {
+		if metricInfo, exists := models.SupportedMetrics[metricType]; exists {
+			infos[metricType] = metricInfo
+			continue
+		}
+		if userID == "" {
+			continue
+		}
+
+		if !loaded {
+			profile, err := h.db.GetUserHealthProfile(userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get user profile: %w", err)
+			}
+			if profile != nil {
+				customMetrics = profile.CustomMetrics
+			}
+			loaded = true
+		}
+		if metricInfo, exists := customMetrics[metricType]; exists {
+			infos[metricType] = metricInfo
+		}
+	}
+	return infos, nil
+}
+
+// ResolveMetricTypes splits metricTypes into those that are built in or among the user's custom
+// metrics and those that aren't, keeping the order of each
+func (h *HealthService) ResolveMetricTypes(userID string, metricTypes []string) (known, unknown []string, err error) {
+	infos, err := h.metricInfos(userID, metricTypes)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, metricType := range metricTypes {
+		if _, exists := infos[metricType]; exists {
+			known = append(known, metricType)
+		} else {
+			unknown = append(unknown, metricType)
+		}
+	}
+	return known, unknown, nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// ErrInvalidGoal is returned when a goal request fails validation
+var ErrInvalidGoal = errors.New("invalid goal")
+
+// ErrGoalNotFound is returned when a goal doesn't exist for the user
+var ErrGoalNotFound = errors.New("goal not found")
+
+// CreateGoal validates and stores a new goal. Target goals without an explicit start value use
+// the latest reading as their baseline so progress can be measured from it.
+func (h *HealthService) CreateGoal(userID string, input *models.HealthGoalInput) (*models.HealthGoal, error) {
+	if _, exists := models.SupportedMetrics[input.MetricType]; !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMetric, input.MetricType)
+	}
+
+	switch input.GoalType {
+	case models.GoalTypeTarget, models.GoalTypeBelow, models.GoalTypeAbove:
+	default:
+		return nil, fmt.Errorf("%w: goal_type must be one of %s, %s or %s",
+			ErrInvalidGoal, models.GoalTypeTarget, models.GoalTypeBelow, models.GoalTypeAbove)
+	}
+
+	if err := h.validateValueRange(input.MetricType, input.TargetValue); err != nil {
+		return nil, fmt.Errorf("%w: target_value: %v", ErrInvalidGoal, err)
+	}
+
+	if input.TargetDate != nil && !input.TargetDate.After(time.Now()) {
+		return nil, fmt.Errorf("%w: target_date must be in the future", ErrInvalidGoal)
+	}
+
+	goal := models.NewHealthGoal(userID, input)
+
+	if goal.GoalType == models.GoalTypeTarget && goal.StartValue == nil {
+		latest, err := h.db.GetLatestHealthMetrics(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest health metrics: %w", err)
+		}
+		if metric, exists := latest[goal.MetricType]; exists {
+			start := metric.Value
+			goal.StartValue = &start
+		}
+	}
+
+	if err := h.db.PutHealthGoal(goal); err != nil {
+		return nil, fmt.Errorf("failed to store goal: %w", err)
+	}
+
+	return goal, nil
+}
+
+// ListGoals returns the user's goals, oldest first
+func (h *HealthService) ListGoals(userID string) ([]models.HealthGoal, error) {
+	goals, err := h.db.GetHealthGoals(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goals: %w", err)
+	}
+
+	sort.Slice(goals, func(i, j int) bool {
+		return goals[i].CreatedAt.Before(goals[j].CreatedAt)
+	})
+
+	return goals, nil
+}
+
+// DeleteGoal removes one of the user's goals
+func (h *HealthService) DeleteGoal(userID, goalID string) error {
+	goals, err := h.db.GetHealthGoals(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get goals: %w", err)
+	}
+
+	for _, goal := range goals {
+		if goal.GoalID == goalID {
+			if err := h.db.DeleteHealthGoal(userID, goalID); err != nil {
+				return fmt.Errorf("failed to delete goal: %w", err)
+			}
+			return nil
+		}
+	}
+
+	return ErrGoalNotFound
+}
+
+// EvaluateGoals computes progress for each of the user's goals against the latest metric values
+func (h *HealthService) EvaluateGoals(userID string) ([]models.GoalProgress, error) {
+	goals, err := h.ListGoals(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := h.db.GetLatestHealthMetrics(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest health metrics: %w", err)
+	}
+
+	now := time.Now()
+	progress := make([]models.GoalProgress, 0, len(goals))
+	for _, goal := range goals {
+		var current *models.HealthMetric
+		if metric, exists := latest[goal.MetricType]; exists {
+			current = &metric
+		}
+		progress = append(progress, evaluateGoal(goal, current, now))
+	}
+
+	return progress, nil
+}
+
+// evaluateGoal compares a goal against the current reading of its metric, which may be nil
+func evaluateGoal(goal models.HealthGoal, current *models.HealthMetric, now time.Time) models.GoalProgress {
+	metricInfo := models.SupportedMetrics[goal.MetricType]
+	result := models.GoalProgress{
+		Goal:   goal,
+		Unit:   metricInfo.Unit,
+		Status: models.GoalStatusNoData,
+	}
+
+	if current == nil {
+		return result
+	}
+
+	value := current.Value
+	timestamp := current.Timestamp
+	result.CurrentValue = &value
+	result.CurrentAt = &timestamp
+
+	switch goal.GoalType {
+	case models.GoalTypeBelow, models.GoalTypeAbove:
+		met := value <= goal.TargetValue
+		if goal.GoalType == models.GoalTypeAbove {
+			met = value >= goal.TargetValue
+		}
+		if met {
+			result.ProgressPercent = 100
+			result.Status = models.GoalStatusOnTrack
+			return result
+		}
+		// How close the value is to the threshold, as a ratio of the two
+		ratio := goal.TargetValue / value
+		if goal.GoalType == models.GoalTypeAbove {
+			ratio = value / goal.TargetValue
+		}
+		result.ProgressPercent = roundPercent(ratio * 100)
+		result.Status = models.GoalStatusOffTrack
+
+	case models.GoalTypeTarget:
+		if goal.StartValue == nil || *goal.StartValue == goal.TargetValue {
+			// No baseline to measure from; only an exact hit counts
+			if metricInfo.Round(value) == metricInfo.Round(goal.TargetValue) {
+				result.ProgressPercent = 100
+				result.Status = models.GoalStatusAchieved
+			} else {
+				result.Status = models.GoalStatusOnTrack
+			}
+			return result
+		}
+
+		// Fraction of the distance from start to target covered so far; negative means moving away
+		raw := (value - *goal.StartValue) / (goal.TargetValue - *goal.StartValue)
+		result.ProgressPercent = roundPercent(math.Max(0, math.Min(1, raw)) * 100)
+
+		switch {
+		case raw >= 1:
+			result.Status = models.GoalStatusAchieved
+		case raw < 0:
+			result.Status = models.GoalStatusOffTrack
+		case goal.TargetDate != nil:
+			// On track when progress keeps pace with the time elapsed since the goal was set
+			total := goal.TargetDate.Sub(goal.CreatedAt)
+			expected := 1.0
+			if total > 0 {
+				expected = math.Min(1, float64(now.Sub(goal.CreatedAt))/float64(total))
+			}
+			if raw >= expected {
+				result.Status = models.GoalStatusOnTrack
+			} else {
+				result.Status = models.GoalStatusOffTrack
+			}
+		default:
+			result.Status = models.GoalStatusOnTrack
+		}
+	}
+
+	return result
+}
+
+// roundPercent rounds a percentage to one decimal place
+func roundPercent(value float64) float64 {
+	return math.Round(value*10) / 10
+}
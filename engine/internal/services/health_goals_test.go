@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// goalProgress creates a goal and returns its evaluation after each reading is recorded
+func goalProgress(t *testing.T, service *HealthService, input *models.HealthGoalInput, readings ...float64) []models.GoalProgress {
+	t.Helper()
+
+	if _, err := service.CreateGoal("user-1", input); err != nil {
+		t.Fatalf("create goal: %v", err)
+	}
+
+	info := models.SupportedMetrics[input.MetricType]
+	at := time.Now().UTC().Add(-time.Duration(len(readings)+1) * time.Minute)
+
+	var results []models.GoalProgress
+	for _, value := range readings {
+		at = at.Add(time.Minute)
+		addMetric(t, service, input.MetricType, value, info.Unit, at)
+
+		progress, err := service.EvaluateGoals("user-1")
+		if err != nil {
+			t.Fatalf("evaluate goals: %v", err)
+		}
+		if len(progress) != 1 {
+			t.Fatalf("got %d goals, want 1", len(progress))
+		}
+		results = append(results, progress[0])
+	}
+	return results
+}
+
+func TestEvaluateBelowThresholdGoal(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	input := &models.HealthGoalInput{MetricType: "blood_pressure_systolic", GoalType: models.GoalTypeBelow, TargetValue: 120}
+	results := goalProgress(t, service, input, 130, 118)
+
+	if above := results[0]; above.Status != models.GoalStatusOffTrack || above.ProgressPercent != 92.3 {
+		t.Errorf("at 130: %s %v%%, want off_track at 92.3%%", above.Status, above.ProgressPercent)
+	}
+	if below := results[1]; below.Status != models.GoalStatusOnTrack || below.ProgressPercent != 100 || *below.CurrentValue != 118 {
+		t.Errorf("at 118: %s %v%%, want on_track at 100%%", below.Status, below.ProgressPercent)
+	}
+}
+
+func TestEvaluateReachTargetGoal(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	start := 85.0
+	input := &models.HealthGoalInput{MetricType: "weight", GoalType: models.GoalTypeTarget, TargetValue: 75, StartValue: &start}
+	results := goalProgress(t, service, input, 80, 87, 74.5)
+
+	for i, want := range []struct {
+		status  string
+		percent float64
+	}{
+		{models.GoalStatusOnTrack, 50},
+		{models.GoalStatusOffTrack, 0},
+		{models.GoalStatusAchieved, 100},
+	} {
+		if results[i].Status != want.status || results[i].ProgressPercent != want.percent {
+			t.Errorf("reading %d (%v kg): %s %v%%, want %s %v%%", i, *results[i].CurrentValue,
+				results[i].Status, results[i].ProgressPercent, want.status, want.percent)
+		}
+	}
+}
+
+func TestTargetGoalDefaultsStartToLatestReading(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+	addMetric(t, service, "weight", 90, "kg", time.Now().UTC().Add(-time.Hour))
+
+	goal, err := service.CreateGoal("user-1", &models.HealthGoalInput{MetricType: "weight", GoalType: models.GoalTypeTarget, TargetValue: 80})
+	if err != nil {
+		t.Fatalf("create goal: %v", err)
+	}
+	if goal.StartValue == nil || *goal.StartValue != 90 {
+		t.Errorf("start value = %v, want the latest reading of 90", goal.StartValue)
+	}
+
+	if _, err := service.CreateGoal("user-1", &models.HealthGoalInput{MetricType: "weight", GoalType: "sideways", TargetValue: 80}); err == nil {
+		t.Error("unknown goal type accepted")
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// ErrInvalidRange is returned when a custom normal range fails validation
+var ErrInvalidRange = errors.New("invalid range")
+
+// GetCustomRanges returns the user's normal-range overrides keyed by metric type
+func (h *HealthService) GetCustomRanges(userID string) (map[string]models.Range, error) {
+	profile, err := h.db.GetUserHealthProfile(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+	if profile == nil || profile.CustomRanges == nil {
+		return map[string]models.Range{}, nil
+	}
+
+	return profile.CustomRanges, nil
+}
+
+// GetEffectiveRange returns the user's override for a metric's normal range, or the default from
+// SupportedMetrics when there is none
+func (h *HealthService) GetEffectiveRange(userID, metricType string) (*models.EffectiveRange, error) {
+	metricInfo, exists, err := h.resolveMetricInfo(userID, metricType)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMetric, metricType)
+	}
+
+	customRanges, err := h.GetCustomRanges(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return effectiveRangeFor(metricType, metricInfo, customRanges), nil
+}
+
+// compositeComponents lists the stored component metrics of each composite metric type
+var compositeComponents = map[string][]string{
+	"blood_pressure": {"blood_pressure_systolic", "blood_pressure_diastolic"},
+	"blood_glucose":  {"blood_glucose_fasting", "blood_glucose_postprandial"},
+}
+
+// GetRangeBands returns the effective normal range and unit of each requested metric for chart
+// overlays, or of every supported metric when none are given
+func (h *HealthService) GetRangeBands(userID string, metricTypes []string) ([]models.RangeBand, error) {
+	if len(metricTypes) == 0 {
+		metricTypes = models.SupportedMetricTypes()
+	}
+	infos, err := h.metricInfos(userID, metricTypes)
+	if err != nil {
+		return nil, err
+	}
+	for _, metricType := range metricTypes {
+		if _, exists := infos[metricType]; !exists {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedMetric, metricType)
+		}
+	}
+
+	customRanges, err := h.GetCustomRanges(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bands := make([]models.RangeBand, 0, len(metricTypes))
+	for _, metricType := range metricTypes {
+		band := rangeBand(metricType, infos[metricType], customRanges)
+		for _, component := range compositeComponents[metricType] {
+			band.Components = append(band.Components, rangeBand(component, models.SupportedMetrics[component], customRanges))
+		}
+		bands = append(bands, band)
+	}
+
+	return bands, nil
+}
+
+// rangeBand builds the chart band for a single metric
+func rangeBand(metricType string, metricInfo models.MetricInfo, customRanges map[string]models.Range) models.RangeBand {
+	effective := effectiveRangeFor(metricType, metricInfo, customRanges)
+	return models.RangeBand{
+		MetricType: metricType,
+		Unit:       metricInfo.Unit,
+		Range:      effective.Range,
+		Custom:     effective.Custom,
+	}
+}
+
+// SetCustomRange stores the user's normal range for a metric, replacing any earlier override
+func (h *HealthService) SetCustomRange(userID, metricType string, normalRange models.Range) (*models.UserHealthProfile, error) {
+	metricInfo, exists, err := h.resolveMetricInfo(userID, metricType)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMetric, metricType)
+	}
+
+	if normalRange.Min >= normalRange.Max {
+		return nil, fmt.Errorf("%w: min must be less than max", ErrInvalidRange)
+	}
+	for _, bound := range []float64{normalRange.Min, normalRange.Max} {
+		if err := metricInfo.ValidateSanity(bound); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRange, err)
+		}
+	}
+
+	return h.updateProfile(userID, func(profile *models.UserHealthProfile) {
+		profile.CustomRanges[metricType] = normalRange
+	})
+}
+
+// DeleteCustomRange removes the user's override for a metric so the default range applies again
+func (h *HealthService) DeleteCustomRange(userID, metricType string) (*models.UserHealthProfile, error) {
+	return h.updateProfile(userID, func(profile *models.UserHealthProfile) {
+		delete(profile.CustomRanges, metricType)
+	})
+}
+
+// updateProfile loads the user's profile (or starts a new one), applies update and stores it
+func (h *HealthService) updateProfile(userID string, update func(profile *models.UserHealthProfile)) (*models.UserHealthProfile, error) {
+	profile, err := h.db.GetUserHealthProfile(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+	if profile == nil {
+		profile = &models.UserHealthProfile{UserID: userID}
+	}
+	if profile.CustomRanges == nil {
+		profile.CustomRanges = make(map[string]models.Range)
+	}
+
+	update(profile)
+	profile.UpdatedAt = time.Now().UTC()
+
+	if err := h.db.PutUserHealthProfile(profile); err != nil {
+		return nil, fmt.Errorf("failed to store user profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+// effectiveRange resolves a built-in metric's normal range from the user's overrides and the defaults
+func effectiveRange(metricType string, customRanges map[string]models.Range) *models.EffectiveRange {
+	return effectiveRangeFor(metricType, models.SupportedMetrics[metricType], customRanges)
+}
+
+// effectiveRangeFor resolves a metric's normal range from the user's overrides and the default in
+// metricInfo, which for a custom metric is the range it was registered with
+func effectiveRangeFor(metricType string, metricInfo models.MetricInfo, customRanges map[string]models.Range) *models.EffectiveRange {
+	if custom, exists := customRanges[metricType]; exists {
+		return &models.EffectiveRange{
+			MetricType: metricType,
+			Range:      &custom,
+			Custom:     true,
+		}
+	}
+
+	return &models.EffectiveRange{
+		MetricType: metricType,
+		Range:      metricInfo.NormalRange,
+	}
+}
+
+// isWithinEffectiveRange checks a value against the user's override or the default normal range.
+// Metrics without any range are always considered normal.
+func isWithinEffectiveRange(metricType string, value float64, customRanges map[string]models.Range) bool {
+	normalRange := effectiveRange(metricType, customRanges).Range
+	return normalRange == nil || normalRange.Status(value) == models.RangeStatusNormal
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+func TestCustomRangeChangesIsNormal(t *testing.T) {
+	f := newAgentFixture(t, nil)
+	// 110 mg/dL is above the default fasting range of 70-100
+	f.putMetric(t, "user-1", "blood_glucose_fasting", 110, "mg/dL", time.Now().Add(-time.Hour))
+
+	isNormal := func() bool {
+		t.Helper()
+		response, err := f.agent.ProcessQuery(context.Background(), "user-1", "", "how is my blood glucose", QueryOptions{})
+		if err != nil {
+			t.Fatalf("process query: %v", err)
+		}
+		for _, data := range response.HealthData {
+			if data.MetricType == "blood_glucose_fasting" {
+				return data.IsNormal
+			}
+		}
+		t.Fatalf("health data = %+v, want the fasting glucose reading", response.HealthData)
+		return false
+	}
+
+	if isNormal() {
+		t.Error("110 mg/dL is normal under the default range")
+	}
+
+	if _, err := f.health.SetCustomRange("user-1", "blood_glucose_fasting", models.Range{Min: 80, Max: 120}); err != nil {
+		t.Fatalf("set custom range: %v", err)
+	}
+	if !isNormal() {
+		t.Error("110 mg/dL isn't normal under the 80-120 override")
+	}
+}
+
+func TestGetEffectiveRangePrefersOverride(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	effective, err := service.GetEffectiveRange("user-1", "heart_rate")
+	if err != nil {
+		t.Fatalf("get effective range: %v", err)
+	}
+	if effective.Custom || effective.Range.Min != 60 || effective.Range.Max != 100 {
+		t.Errorf("default = %+v, want 60-100 from SupportedMetrics", effective)
+	}
+
+	if _, err := service.SetCustomRange("user-1", "heart_rate", models.Range{Min: 45, Max: 90}); err != nil {
+		t.Fatalf("set custom range: %v", err)
+	}
+	effective, err = service.GetEffectiveRange("user-1", "heart_rate")
+	if err != nil {
+		t.Fatalf("get effective range: %v", err)
+	}
+	if !effective.Custom || effective.Range.Min != 45 || effective.Range.Max != 90 {
+		t.Errorf("override = %+v, want the custom 45-90", effective)
+	}
+	if other, _ := service.GetEffectiveRange("user-2", "heart_rate"); other.Custom {
+		t.Error("another user sees the override")
+	}
+
+	if _, err := service.DeleteCustomRange("user-1", "heart_rate"); err != nil {
+		t.Fatalf("delete custom range: %v", err)
+	}
+	if effective, _ = service.GetEffectiveRange("user-1", "heart_rate"); effective.Custom {
+		t.Error("override still applies after deletion")
+	}
+
+	if _, err := service.SetCustomRange("user-1", "heart_rate", models.Range{Min: 90, Max: 45}); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("inverted range returned %v, want ErrInvalidRange", err)
+	}
+}
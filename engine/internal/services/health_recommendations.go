@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// maxRecommendations caps how many recommendations are returned
+const maxRecommendations = 5
+
+// recommendationRule produces a recommendation when a metric's latest value matches
+type recommendationRule struct {
+	metricType     string
+	matches        func(value float64, status string) bool
+	recommendation models.Recommendation
+}
+
+// isHigh and isLow match values above or below the metric's effective normal range
+func isHigh(_ float64, status string) bool { return status == models.RangeStatusHigh }
+func isLow(_ float64, status string) bool  { return status == models.RangeStatusLow }
+
+// below matches values under a threshold, for metrics without a normal range
+func below(threshold float64) func(float64, string) bool {
+	return func(value float64, _ string) bool { return value < threshold }
+}
+
+// recommendationRules map concerning readings to advice. Rules sharing a type are alternatives;
+// only the most pressing one of each type is returned.
+var recommendationRules = []recommendationRule{
+	{"blood_pressure_systolic", isHigh, models.Recommendation{
+		Type: "blood_pressure", Title: "Bring Your Blood Pressure Down", Priority: models.RecommendationPriorityHigh,
+		Description: "Cut back on salt and alcohol, stay active and keep tracking your blood pressure; discuss persistent high readings with your doctor",
+	}},
+	{"blood_pressure_diastolic", isHigh, models.Recommendation{
+		Type: "blood_pressure", Title: "Bring Your Blood Pressure Down", Priority: models.RecommendationPriorityHigh,
+		Description: "Cut back on salt and alcohol, stay active and keep tracking your blood pressure; discuss persistent high readings with your doctor",
+	}},
+	{"blood_glucose_fasting", isHigh, models.Recommendation{
+		Type: "blood_sugar", Title: "Manage Your Blood Sugar", Priority: models.RecommendationPriorityHigh,
+		Description: "Limit sugary drinks and refined carbohydrates, favour whole grains and fibre, and ask your doctor about your fasting glucose",
+	}},
+	{"cholesterol_ldl", isHigh, models.Recommendation{
+		Type: "nutrition", Title: "Lower Your LDL Cholesterol", Priority: models.RecommendationPriorityHigh,
+		Description: "Reduce saturated fat from red meat and full-fat dairy, and eat more oats, beans, nuts and other soluble fibre",
+	}},
+	{"cholesterol_total", isHigh, models.Recommendation{
+		Type: "nutrition", Title: "Watch Your Cholesterol", Priority: models.RecommendationPriorityMedium,
+		Description: "Choose unsaturated fats such as olive oil and fish over saturated fats, and eat plenty of vegetables and fibre",
+	}},
+	{"cholesterol_hdl", isLow, models.Recommendation{
+		Type: "exercise", Title: "Raise Your HDL Cholesterol", Priority: models.RecommendationPriorityMedium,
+		Description: "Regular aerobic exercise and avoiding smoking help raise HDL, the protective cholesterol",
+	}},
+	{"bmi", isHigh, models.Recommendation{
+		Type: "weight", Title: "Work Towards a Healthy Weight", Priority: models.RecommendationPriorityMedium,
+		Description: "Small, sustainable changes to portion sizes and daily activity add up; aim for gradual weight loss",
+	}},
+	{"bmi", isLow, models.Recommendation{
+		Type: "weight", Title: "Reach a Healthy Weight", Priority: models.RecommendationPriorityMedium,
+		Description: "Eat regular, nutrient-dense meals and consider talking to your doctor or a dietitian about your weight",
+	}},
+	{"sleep_duration", isLow, models.Recommendation{
+		Type: "sleep", Title: "Improve Your Sleep", Priority: models.RecommendationPriorityMedium,
+		Description: "Aim for 7-9 hours: keep a regular bedtime, avoid screens and caffeine late in the day and keep your bedroom dark and cool",
+	}},
+	{"sleep_duration", isHigh, models.Recommendation{
+		Type: "sleep", Title: "Review Your Sleep", Priority: models.RecommendationPriorityLow,
+		Description: "Regularly sleeping more than 9 hours can be a sign of poor sleep quality; keep a consistent schedule",
+	}},
+	{"heart_rate", isHigh, models.Recommendation{
+		Type: "stress", Title: "Check Your Resting Heart Rate", Priority: models.RecommendationPriorityMedium,
+		Description: "Measure your heart rate at rest; stress, caffeine and dehydration can raise it, and regular exercise lowers it over time",
+	}},
+	{"steps", below(5000), models.Recommendation{
+		Type: "exercise", Title: "Stay Active", Priority: models.RecommendationPriorityMedium,
+		Description: "Build up towards 7,000-10,000 steps a day, for example with a short walk after meals",
+	}},
+	{"exercise_duration", below(30), models.Recommendation{
+		Type: "exercise", Title: "Stay Active", Priority: models.RecommendationPriorityMedium,
+		Description: "Aim for 30 minutes of moderate exercise daily",
+	}},
+	{"water_intake", below(1.5), models.Recommendation{
+		Type: "hydration", Title: "Drink More Water", Priority: models.RecommendationPriorityLow,
+		Description: "Keep a bottle of water nearby and drink regularly through the day",
+	}},
+}
+
+// defaultRecommendation is returned when none of the user's readings call for advice
+var defaultRecommendation = models.Recommendation{
+	Type:        "monitoring",
+	Title:       "Keep Tracking",
+	Description: "Your recent readings look good; keep recording your metrics regularly to spot changes early",
+	Priority:    models.RecommendationPriorityLow,
+}
+
+// recommendationPriorityOrder sorts recommendations with the most pressing first
+var recommendationPriorityOrder = map[string]int{
+	models.RecommendationPriorityHigh:   0,
+	models.RecommendationPriorityMedium: 1,
+	models.RecommendationPriorityLow:    2,
+}
+
+// GenerateRecommendations returns advice prompted by the user's latest readings, at most one per
+// recommendation type and maxRecommendations in total, most pressing first
+func (h *HealthService) GenerateRecommendations(userID string) ([]models.Recommendation, error) {
+	latest, err := h.db.GetLatestHealthMetrics(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest health metrics: %w", err)
+	}
+
+	customRanges, err := h.GetCustomRanges(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64, len(latest))
+	for metricType, metric := range latest {
+		values[metricType] = metric.Value
+	}
+
+	return recommendationsFor(values, customRanges), nil
+}
+
+// recommendationsFor applies the recommendation rules to the latest value of each metric
+func recommendationsFor(values map[string]float64, customRanges map[string]models.Range) []models.Recommendation {
+	byType := make(map[string]models.Recommendation)
+	for _, rule := range recommendationRules {
+		value, exists := values[rule.metricType]
+		if !exists {
+			continue
+		}
+
+		status := effectiveRange(rule.metricType, customRanges).Range.Status(value)
+		if !rule.matches(value, status) {
+			continue
+		}
+
+		recommendation := rule.recommendation
+		recommendation.MetricType = rule.metricType
+		if current, exists := byType[recommendation.Type]; !exists ||
+			recommendationPriorityOrder[recommendation.Priority] < recommendationPriorityOrder[current.Priority] {
+			byType[recommendation.Type] = recommendation
+		}
+	}
+
+	if len(byType) == 0 {
+		return []models.Recommendation{defaultRecommendation}
+	}
+
+	recommendations := make([]models.Recommendation, 0, len(byType))
+	for _, recommendation := range byType {
+		recommendations = append(recommendations, recommendation)
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		pi, pj := recommendationPriorityOrder[recommendations[i].Priority], recommendationPriorityOrder[recommendations[j].Priority]
+		if pi != pj {
+			return pi < pj
+		}
+		return recommendations[i].Type < recommendations[j].Type
+	})
+
+	if len(recommendations) > maxRecommendations {
+		recommendations = recommendations[:maxRecommendations]
+	}
+	return recommendations
+}
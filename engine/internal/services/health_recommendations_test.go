@@ -0,0 +1,69 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// recommendationTypes returns the recommendations' types in order, comma separated
+func recommendationTypes(recommendations []models.Recommendation) string {
+	types := make([]string, len(recommendations))
+	for i, recommendation := range recommendations {
+		types[i] = recommendation.Type
+	}
+	return strings.Join(types, ",")
+}
+
+func TestRecommendationsFollowOutOfRangeMetrics(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		values map[string]float64
+		want   string
+	}{
+		{"low steps", map[string]float64{"steps": 3000}, "exercise"},
+		{"high LDL", map[string]float64{"cholesterol_ldl": 160}, "nutrition"},
+		{"short sleep", map[string]float64{"sleep_duration": 5}, "sleep"},
+		{"all in range", map[string]float64{"steps": 9000, "cholesterol_ldl": 80, "sleep_duration": 8}, "monitoring"},
+		{"high priority first", map[string]float64{"steps": 3000, "sleep_duration": 5, "cholesterol_ldl": 160}, "nutrition,exercise,sleep"},
+	} {
+		if got := recommendationTypes(recommendationsFor(tc.values, nil)); got != tc.want {
+			t.Errorf("%s: recommendations %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRecommendationsAreDeduplicatedAndCapped(t *testing.T) {
+	// High LDL and high total cholesterol both call for nutrition advice; the more pressing wins
+	recommendations := recommendationsFor(map[string]float64{"cholesterol_ldl": 160, "cholesterol_total": 260}, nil)
+	if len(recommendations) != 1 || recommendations[0].MetricType != "cholesterol_ldl" || recommendations[0].Priority != models.RecommendationPriorityHigh {
+		t.Errorf("recommendations = %+v, want only the high-priority LDL advice", recommendations)
+	}
+
+	everything := map[string]float64{
+		"blood_pressure_systolic": 160, "blood_glucose_fasting": 140, "cholesterol_ldl": 160,
+		"bmi": 32, "sleep_duration": 5, "heart_rate": 110, "steps": 2000, "water_intake": 0.5,
+	}
+	if got := recommendationsFor(everything, nil); len(got) != maxRecommendations {
+		t.Errorf("got %d recommendations, want the cap of %d", len(got), maxRecommendations)
+	}
+}
+
+func TestGenerateRecommendationsUsesLatestReadings(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	// Steps were low last week but are fine now
+	addMetric(t, service, "steps", 2000, "count", time.Now().UTC().AddDate(0, 0, -7))
+	addMetric(t, service, "steps", 9500, "count", time.Now().UTC().Add(-time.Hour))
+	addMetric(t, service, "cholesterol_ldl", 160, "mg/dL", time.Now().UTC().Add(-time.Hour))
+
+	recommendations, err := service.GenerateRecommendations("user-1")
+	if err != nil {
+		t.Fatalf("generate recommendations: %v", err)
+	}
+	if got := recommendationTypes(recommendations); got != "nutrition" {
+		t.Errorf("recommendations %s, want nutrition only", got)
+	}
+}
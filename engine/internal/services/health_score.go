@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// healthScoreMildDeviation is how far outside its normal range a value may be, as a fraction of
+// the range's width, and still earn partial points
+const healthScoreMildDeviation = 0.25
+
+// healthScoreWeights weights the metrics that matter most for overall health; others count once
+var healthScoreWeights = map[string]float64{
+	"blood_pressure_systolic":  2,
+	"blood_pressure_diastolic": 2,
+	"blood_glucose_fasting":    2,
+	"heart_rate":               1.5,
+	"bmi":                      1.5,
+	"cholesterol_ldl":          1.5,
+}
+
+// healthScoreBands maps the lowest score of each category to its description, best first
+var healthScoreBands = []struct {
+	minScore    int
+	category    string
+	description string
+}{
+	{85, models.HealthScoreExcellent, "Your health metrics are within normal ranges"},
+	{70, models.HealthScoreGood, "Your health metrics are generally within normal ranges"},
+	{50, models.HealthScoreFair, "Some of your health metrics are outside normal ranges"},
+	{0, models.HealthScoreNeedsAttention, "Several of your health metrics are outside normal ranges; consider discussing them with a healthcare provider"},
+}
+
+// ComputeHealthScore scores the user's latest reading of each metric that has a normal range
+// (the user's override or the default): full points in range, half points when mildly out of
+// range and none when far out, weighted and normalized to 0-100
+func (h *HealthService) ComputeHealthScore(userID string) (*models.HealthScore, error) {
+	latest, err := h.db.GetLatestHealthMetrics(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest health metrics: %w", err)
+	}
+
+	customRanges, err := h.GetCustomRanges(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64, len(latest))
+	for metricType, metric := range latest {
+		values[metricType] = metric.Value
+	}
+
+	score := scoreHealthMetrics(values, customRanges)
+	score.EvaluatedAt = time.Now().UTC()
+	return score, nil
+}
+
+// scoreHealthMetrics computes the health score from the latest value of each metric
+func scoreHealthMetrics(values map[string]float64, customRanges map[string]models.Range) *models.HealthScore {
+	score := &models.HealthScore{Components: []models.HealthScoreComponent{}}
+
+	var earned, possible float64
+	for metricType, value := range values {
+		normalRange := effectiveRange(metricType, customRanges).Range
+		if normalRange == nil || normalRange.Max <= normalRange.Min {
+			continue // Composite and unranged metrics aren't scored
+		}
+
+		component := models.HealthScoreComponent{
+			MetricType: metricType,
+			Value:      value,
+			Status:     normalRange.Status(value),
+			Weight:     1,
+			Points:     rangePoints(value, normalRange),
+		}
+		if weight, exists := healthScoreWeights[metricType]; exists {
+			component.Weight = weight
+		}
+
+		earned += component.Weight * component.Points
+		possible += component.Weight
+		score.Components = append(score.Components, component)
+	}
+
+	sort.Slice(score.Components, func(i, j int) bool {
+		return score.Components[i].MetricType < score.Components[j].MetricType
+	})
+
+	if possible == 0 {
+		score.Category = models.HealthScoreNoData
+		score.Description = "Record metrics such as blood pressure, heart rate or weight to get a health score"
+		return score
+	}
+
+	score.Score = int(math.Round(100 * earned / possible))
+	for _, band := range healthScoreBands {
+		if score.Score >= band.minScore {
+			score.Category = band.category
+			score.Description = band.description
+			break
+		}
+	}
+
+	return score
+}
+
+// rangePoints awards 1 point for a value within the range, 0.5 for one mildly outside it and 0
+// for one far outside it
+func rangePoints(value float64, normalRange *models.Range) float64 {
+	var distance float64
+	switch {
+	case value < normalRange.Min:
+		distance = normalRange.Min - value
+	case value > normalRange.Max:
+		distance = value - normalRange.Max
+	default:
+		return 1
+	}
+
+	if distance/(normalRange.Max-normalRange.Min) <= healthScoreMildDeviation {
+		return 0.5
+	}
+	return 0
+}
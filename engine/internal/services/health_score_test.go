@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+func TestHealthScoreDropsWithOutOfRangeMetrics(t *testing.T) {
+	at := time.Now().UTC().Add(-time.Hour)
+
+	healthy, _, _ := newTestHealthService(t, nil)
+	addMetric(t, healthy, "blood_pressure_systolic", 115, "mmHg", at)
+	addMetric(t, healthy, "heart_rate", 70, "bpm", at)
+	addMetric(t, healthy, "blood_glucose_fasting", 90, "mg/dL", at)
+	addMetric(t, healthy, "cholesterol_ldl", 80, "mg/dL", at)
+
+	score, err := healthy.ComputeHealthScore("user-1")
+	if err != nil {
+		t.Fatalf("compute score: %v", err)
+	}
+	if score.Score != 100 || score.Category != models.HealthScoreExcellent || len(score.Components) != 4 {
+		t.Errorf("all normal: score %d (%s) from %d components, want 100 and excellent from 4", score.Score, score.Category, len(score.Components))
+	}
+
+	// Systolic and glucose far out of range, heart rate just above it
+	unwell, _, _ := newTestHealthService(t, nil)
+	addMetric(t, unwell, "blood_pressure_systolic", 160, "mmHg", at)
+	addMetric(t, unwell, "heart_rate", 105, "bpm", at)
+	addMetric(t, unwell, "blood_glucose_fasting", 140, "mg/dL", at)
+	addMetric(t, unwell, "cholesterol_ldl", 80, "mg/dL", at)
+
+	score, err = unwell.ComputeHealthScore("user-1")
+	if err != nil {
+		t.Fatalf("compute score: %v", err)
+	}
+	// (2*0 + 1.5*0.5 + 2*0 + 1.5*1) / 7 weighted points
+	if score.Score != 32 || score.Category != models.HealthScoreNeedsAttention {
+		t.Errorf("out of range: score %d (%s), want 32 and needs attention", score.Score, score.Category)
+	}
+	for _, component := range score.Components {
+		if component.MetricType == "heart_rate" && component.Points != 0.5 {
+			t.Errorf("mildly high heart rate earned %v points, want 0.5", component.Points)
+		}
+	}
+}
+
+func TestHealthScoreWithoutRangedMetrics(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+	addMetric(t, service, "weight", 80, "kg", time.Now().UTC().Add(-time.Hour))
+
+	score, err := service.ComputeHealthScore("user-1")
+	if err != nil {
+		t.Fatalf("compute score: %v", err)
+	}
+	if score.Score != 0 || score.Category != models.HealthScoreNoData {
+		t.Errorf("score %d (%s), want no score from weight alone", score.Score, score.Category)
+	}
+}
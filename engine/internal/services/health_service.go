@@ -1,41 +1,127 @@
 package services
 
 import (
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"go.uber.org/zap"
+
 	"health-dashboard-backend/internal/config"
 	"health-dashboard-backend/internal/database"
 	"health-dashboard-backend/internal/logger"
 	"health-dashboard-backend/internal/models"
 )
 
+// ErrUnsupportedMetric is returned when a request references a metric type that isn't in SupportedMetrics
+var ErrUnsupportedMetric = errors.New("unsupported metric type")
+
+// ErrTrendsTooComplex is returned when a trends request asks for too many metrics or too much history
+var ErrTrendsTooComplex = errors.New("trends request too complex")
+
+// ErrFutureTimestamp is returned when a metric's measurement time is in the future
+var ErrFutureTimestamp = errors.New("timestamp must not be in the future")
+
+// ErrUnsupportedAggregation is returned when a history aggregation bucket isn't day, week or month
+var ErrUnsupportedAggregation = errors.New("unsupported aggregation bucket")
+
+// Lookback windows for pairing a new weight/height reading with its counterpart when deriving BMI.
+// Adult height rarely changes, so an older reading is still usable; weight needs to be recent.
+const (
+	bmiHeightLookback = 365 * 24 * time.Hour
+	bmiWeightLookback = 30 * 24 * time.Hour
+)
+
+// maxTimestampSkew tolerates client clocks running slightly ahead when checking for future timestamps
+const maxTimestampSkew = 5 * time.Minute
+
+// trendMaxPoints caps how many readings from the trend window are used for the regression
+const trendMaxPoints = 500
+
+// defaultMetricPageSize is the page size used for filtered history reads when no limit is given
+const defaultMetricPageSize = 10
+
+// maxImportRows caps the number of data rows accepted in a single CSV import
+const maxImportRows = 5000
+
+// importTimestampLayouts are the timestamp formats accepted in CSV imports, tried in order
+var importTimestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
 // HealthService handles health data operations
 type HealthService struct {
-	db  *database.DynamoDBClient
-	cfg *config.Config
+	db          *database.DynamoDBClient
+	cfg         *config.Config
+	idempotency *idempotencyStore // Nil when idempotency keys are disabled
+	logger      *zap.Logger
 }
 
 // NewHealthService creates a new health service
 func NewHealthService(db *database.DynamoDBClient, cfg *config.Config) *HealthService {
-	return &HealthService{
-		db:  db,
-		cfg: cfg,
+	h := &HealthService{
+		db:     db,
+		cfg:    cfg,
+		logger: zap.NewNop(),
+	}
+	if cfg.IdempotencyTTLMinutes > 0 {
+		h.idempotency = newIdempotencyStore(time.Duration(cfg.IdempotencyTTLMinutes) * time.Minute)
+	}
+	return h
+}
+
+// SetLogger sets the logger for failures that don't fail the request; defaults to a no-op logger
+func (h *HealthService) SetLogger(logger *zap.Logger) {
+	h.logger = logger
+}
+
+// AddHealthData adds a new health metric of a built-in type or one of the user's custom types.
+// When the input carries an idempotency key already used by the user within the TTL, the metric
+// stored by the first request is returned instead and replayed is true.
+func (h *HealthService) AddHealthData(userID string, input *models.HealthMetricInput) (metric *models.HealthMetric, replayed bool, err error) {
+	if input.IdempotencyKey == "" || h.idempotency == nil {
+		metric, err = h.addHealthData(userID, input)
+		return metric, false, err
 	}
+
+	return h.idempotency.do(userID, input.IdempotencyKey, input, func() (*models.HealthMetric, error) {
+		return h.addHealthData(userID, input)
+	})
 }
 
-// AddHealthData adds a new health metric
-func (h *HealthService) AddHealthData(userID string, input *models.HealthMetricInput) (*models.HealthMetric, error) {
+// addHealthData validates the metric type and stores the metric
+func (h *HealthService) addHealthData(userID string, input *models.HealthMetricInput) (*models.HealthMetric, error) {
 	// Validate metric type
-	metricInfo, exists := models.SupportedMetrics[input.Type]
+	metricInfo, exists, err := h.resolveMetricInfo(userID, input.Type)
+	if err != nil {
+		return nil, err
+	}
 	if !exists {
-		return nil, fmt.Errorf("unsupported metric type: %s", input.Type)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMetric, input.Type)
+	}
+
+	timestamp, err := measurementTime(input.Timestamp)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create health metric
 	metric := &models.HealthMetric{
 		UserID:    userID,
-		Timestamp: time.Now(),
+		Timestamp: timestamp,
 		Type:      input.Type,
 		Value:     input.Value,
 		Unit:      input.Unit,
@@ -43,10 +129,9 @@ func (h *HealthService) AddHealthData(userID string, input *models.HealthMetricI
 		Source:    input.Source,
 	}
 
-	// Validate unit matches expected unit
-	if metricInfo.Unit != "" && input.Unit != metricInfo.Unit {
-		return nil, fmt.Errorf("invalid unit for %s. Expected: %s, got: %s",
-			input.Type, metricInfo.Unit, input.Unit)
+	// Convert known alias units (lbs, °F, mmol/L) to the canonical unit
+	if err := applyCanonicalUnit(metric, metricInfo); err != nil {
+		return nil, err
 	}
 
 	logger.DebugPrint("metricInfo", metricInfo)
@@ -57,9 +142,321 @@ func (h *HealthService) AddHealthData(userID string, input *models.HealthMetricI
 		return nil, fmt.Errorf("failed to store health metric: %w", err)
 	}
 
+	if h.cfg.AutoComputeBMI && (metric.Type == "weight" || metric.Type == "height") {
+		// A failed derivation shouldn't fail the measurement the user actually submitted
+		if _, err := h.deriveBMI(userID, metric); err != nil {
+			h.logger.Warn("Failed to derive BMI",
+				zap.String("user_id", userID),
+				zap.String("metric_type", metric.Type),
+				zap.Error(err))
+		}
+	}
+
 	return metric, nil
 }
 
+// deriveBMI computes and stores a bmi metric from a new weight or height reading and the
+// most recent counterpart on record. It returns nil without error when no counterpart exists.
+func (h *HealthService) deriveBMI(userID string, metric *models.HealthMetric) (*models.HealthMetric, error) {
+	counterpartType, lookback := "height", bmiHeightLookback
+	if metric.Type == "height" {
+		counterpartType, lookback = "weight", bmiWeightLookback
+	}
+
+	counterparts, _, err := h.GetMetricHistory(userID, counterpartType, metric.Timestamp.Add(-lookback), metric.Timestamp, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", counterpartType, err)
+	}
+	if len(counterparts) == 0 {
+		return nil, nil // Nothing to pair with yet
+	}
+
+	weightKg, heightCm := metric.Value, counterparts[0].Value
+	if metric.Type == "height" {
+		weightKg, heightCm = counterparts[0].Value, metric.Value
+	}
+	if heightCm <= 0 {
+		return nil, nil
+	}
+
+	heightM := heightCm / 100
+	bmiInfo := models.SupportedMetrics["bmi"]
+	bmi := bmiInfo.Round(weightKg / (heightM * heightM))
+	if err := h.validateValueRange("bmi", bmi); err != nil {
+		return nil, err
+	}
+
+	derived := &models.HealthMetric{
+		UserID:    userID,
+		Timestamp: metric.Timestamp,
+		Type:      "bmi",
+		Value:     bmi,
+		Unit:      bmiInfo.Unit,
+		Notes:     fmt.Sprintf("Computed from weight %.1f kg and height %.1f cm", weightKg, heightCm),
+		Source:    models.MetricSourceDerived,
+	}
+
+	if err := h.db.PutHealthMetric(derived); err != nil {
+		return nil, fmt.Errorf("failed to store derived bmi: %w", err)
+	}
+
+	return derived, nil
+}
+
+// measurementTime returns the client-supplied measurement time in UTC, or now when none was given.
+// Times in the future are rejected.
+func measurementTime(timestamp *time.Time) (time.Time, error) {
+	now := time.Now().UTC()
+	if timestamp == nil {
+		return now, nil
+	}
+	if timestamp.After(now.Add(maxTimestampSkew)) {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrFutureTimestamp, timestamp.Format(time.RFC3339))
+	}
+	return timestamp.UTC(), nil
+}
+
+// applyCanonicalUnit converts a metric submitted in an alias unit to its canonical unit in place,
+// keeping the submitted value and unit in OriginalValue/OriginalUnit
+func applyCanonicalUnit(metric *models.HealthMetric, metricInfo models.MetricInfo) error {
+	// Custom metrics have no unit aliases, so only their own unit is accepted
+	if _, builtIn := models.SupportedMetrics[metric.Type]; !builtIn {
+		if metric.Unit != metricInfo.Unit {
+			return fmt.Errorf("invalid unit for %s. Expected: %s, got: %s",
+				metric.Type, metricInfo.Unit, metric.Unit)
+		}
+		return nil
+	}
+
+	value, unit, ok := models.ConvertToCanonical(metric.Type, metric.Value, metric.Unit)
+	if !ok {
+		return fmt.Errorf("invalid unit for %s. Expected: %s, got: %s",
+			metric.Type, metricInfo.Unit, metric.Unit)
+	}
+
+	// Aliases that only differ in spelling (e.g. "mg/dl") are normalized without recording an original
+	if value != metric.Value {
+		original := metric.Value
+		metric.OriginalValue = &original
+		metric.OriginalUnit = metric.Unit
+		metric.Value = value
+	}
+	metric.Unit = unit
+
+	return nil
+}
+
+// AddHealthDataBatch stores already-validated import rows in batches.
+// Rows that share a metric type and timestamp with an earlier row are rejected as duplicates.
+func (h *HealthService) AddHealthDataBatch(userID string, rows []models.MetricImportRow) []models.MetricImportRowResult {
+	results := make([]models.MetricImportRowResult, 0, len(rows))
+	metrics := make([]*models.HealthMetric, 0, len(rows))
+	metricRows := make([]models.MetricImportRow, 0, len(rows))
+	seen := make(map[string]int)
+	metricInfos := make(map[string]models.MetricInfo)
+
+	for _, row := range rows {
+		metricInfo, exists := metricInfos[row.Input.Type]
+		if !exists {
+			var err error
+			metricInfo, exists, err = h.resolveMetricInfo(userID, row.Input.Type)
+			if err != nil || !exists {
+				if err == nil {
+					err = fmt.Errorf("%w: %s", ErrUnsupportedMetric, row.Input.Type)
+				}
+				results = append(results, models.MetricImportRowResult{
+					Row:   row.Row,
+					Type:  row.Input.Type,
+					Error: err.Error(),
+				})
+				continue
+			}
+			metricInfos[row.Input.Type] = metricInfo
+		}
+
+		metric := &models.HealthMetric{
+			UserID:    userID,
+			Timestamp: row.Timestamp.UTC(),
+			Type:      row.Input.Type,
+			Value:     row.Input.Value,
+			Unit:      row.Input.Unit,
+			Notes:     row.Input.Notes,
+			Source:    row.Input.Source,
+		}
+		if err := applyCanonicalUnit(metric, metricInfo); err != nil {
+			results = append(results, models.MetricImportRowResult{
+				Row:   row.Row,
+				Type:  row.Input.Type,
+				Error: err.Error(),
+			})
+			continue
+		}
+
+		// BatchWriteItem rejects duplicate keys within a request, and a later row would overwrite the earlier one anyway
+		sortKey := metric.GetSortKey()
+		if firstRow, exists := seen[sortKey]; exists {
+			results = append(results, models.MetricImportRowResult{
+				Row:   row.Row,
+				Type:  row.Input.Type,
+				Error: fmt.Sprintf("duplicate of row %d (same type and timestamp)", firstRow),
+			})
+			continue
+		}
+		seen[sortKey] = row.Row
+
+		metrics = append(metrics, metric)
+		metricRows = append(metricRows, row)
+	}
+
+	errs := h.db.BatchPutHealthMetrics(metrics)
+	for i, row := range metricRows {
+		result := models.MetricImportRowResult{
+			Row:     row.Row,
+			Type:    row.Input.Type,
+			Success: errs[i] == nil,
+		}
+		if errs[i] != nil {
+			h.logger.Warn("Failed to store imported health metric",
+				zap.String("user_id", userID),
+				zap.Int("row", row.Row),
+				zap.String("type", row.Input.Type),
+				zap.Error(errs[i]))
+			result.Error = errs[i].Error()
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// ParseMetricsCSV reads a metrics CSV with a header row containing type, value, unit and timestamp
+// columns, plus optional notes and source columns. Rows that can't be parsed are returned as failures.
+func (h *HealthService) ParseMetricsCSV(r io.Reader) ([]models.MetricImportRow, []models.MetricImportRowResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Report short rows per line instead of aborting the whole file
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("CSV file is empty")
+		}
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, required := range []string{"type", "value", "unit", "timestamp"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing required column: %s", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []models.MetricImportRow
+	var failures []models.MetricImportRowResult
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				failures = append(failures, models.MetricImportRowResult{
+					Row:   parseErr.StartLine,
+					Error: parseErr.Err.Error(),
+				})
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		// Skip blank lines
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		if len(rows)+len(failures) >= maxImportRows {
+			return nil, nil, fmt.Errorf("CSV file exceeds the maximum of %d rows", maxImportRows)
+		}
+
+		metricType := field(record, "type")
+		row := models.MetricImportRow{
+			Row: line,
+			Input: models.HealthMetricInput{
+				Type:   metricType,
+				Unit:   field(record, "unit"),
+				Notes:  field(record, "notes"),
+				Source: field(record, "source"),
+			},
+		}
+		if row.Input.Source == "" {
+			row.Input.Source = models.MetricSourceCSVImport
+		}
+
+		// Boolean and ordinal metrics also accept labels such as "yes" or a level name
+		var value float64
+		if metricInfo, exists := models.SupportedMetrics[metricType]; exists {
+			value, err = metricInfo.ParseValue(field(record, "value"))
+		} else {
+			value, err = strconv.ParseFloat(field(record, "value"), 64)
+		}
+		if err != nil {
+			failures = append(failures, models.MetricImportRowResult{
+				Row:   line,
+				Type:  metricType,
+				Error: fmt.Sprintf("invalid value: %q", field(record, "value")),
+			})
+			continue
+		}
+		row.Input.Value = value
+
+		timestamp, err := parseImportTimestamp(field(record, "timestamp"))
+		if err != nil {
+			failures = append(failures, models.MetricImportRowResult{
+				Row:   line,
+				Type:  metricType,
+				Error: err.Error(),
+			})
+			continue
+		}
+		row.Timestamp = timestamp
+
+		rows = append(rows, row)
+	}
+
+	return rows, failures, nil
+}
+
+// parseImportTimestamp parses a CSV timestamp using the accepted layouts; values without a zone are treated as UTC
+func parseImportTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("timestamp is required")
+	}
+
+	for _, layout := range importTimestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid timestamp: %q (expected RFC3339 or YYYY-MM-DD HH:MM:SS)", value)
+}
+
 // AddBloodPressureData adds blood pressure data with both systolic and diastolic values
 func (h *HealthService) AddBloodPressureData(userID string, input *models.BloodPressureInput) ([]*models.HealthMetric, error) {
 	// Validate blood pressure input
@@ -85,7 +482,10 @@ func (h *HealthService) AddBloodPressureData(userID string, input *models.BloodP
 		return nil, fmt.Errorf("systolic pressure must be greater than diastolic pressure")
 	}
 
-	timestamp := time.Now()
+	timestamp, err := measurementTime(input.Timestamp)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create systolic metric
 	systolicMetric := &models.HealthMetric{
@@ -128,28 +528,11 @@ func (h *HealthService) AddBloodGlucoseData(userID string, input *models.BloodGl
 		return nil, fmt.Errorf("invalid type for blood glucose input: %s", input.Type)
 	}
 
-	if input.Unit != "mg/dL" {
-		return nil, fmt.Errorf("invalid unit for blood glucose. Expected: mg/dL, got: %s", input.Unit)
-	}
-
-	// Validate fasting and postprandial values
-	if err := h.validateValueRange("blood_glucose_fasting", input.Fasting); err != nil {
-		return nil, fmt.Errorf("invalid fasting glucose value: %w", err)
-	}
-
-	if err := h.validateValueRange("blood_glucose_postprandial", input.Postprandial); err != nil {
-		return nil, fmt.Errorf("invalid postprandial glucose value: %w", err)
-	}
-
-	// Validate that postprandial is typically higher than fasting (but not always required)
-	// This is a soft validation - we'll just log a warning if it seems unusual
-	if input.Postprandial < input.Fasting {
-		// This could be normal in some cases, so we won't fail but could log
-		logger.DebugPrint("Postprandial glucose is lower than fasting glucose")
+	timestamp, err := measurementTime(input.Timestamp)
+	if err != nil {
+		return nil, err
 	}
 
-	timestamp := time.Now()
-
 	// Create fasting glucose metric
 	fastingMetric := &models.HealthMetric{
 		UserID:    userID,
@@ -172,6 +555,32 @@ func (h *HealthService) AddBloodGlucoseData(userID string, input *models.BloodGl
 		Source:    input.Source,
 	}
 
+	// Convert mmol/L readings to mg/dL before range checks, as for single readings
+	for _, metric := range []*models.HealthMetric{fastingMetric, postprandialMetric} {
+		if err := applyCanonicalUnit(metric, models.SupportedMetrics[metric.Type]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Validate fasting and postprandial values
+	if err := h.validateValueRange("blood_glucose_fasting", fastingMetric.Value); err != nil {
+		return nil, fmt.Errorf("invalid fasting glucose value: %w", err)
+	}
+
+	if err := h.validateValueRange("blood_glucose_postprandial", postprandialMetric.Value); err != nil {
+		return nil, fmt.Errorf("invalid postprandial glucose value: %w", err)
+	}
+
+	// Validate that postprandial is typically higher than fasting (but not always required)
+	// This is a soft validation - we'll just log a warning if it seems unusual
+	if postprandialMetric.Value < fastingMetric.Value {
+		// This could be normal in some cases, so we won't fail but log it
+		h.logger.Info("Postprandial glucose is lower than fasting glucose",
+			zap.String("user_id", userID),
+			zap.Float64("fasting", fastingMetric.Value),
+			zap.Float64("postprandial", postprandialMetric.Value))
+	}
+
 	// Store both metrics in database
 	if err := h.db.PutHealthMetric(fastingMetric); err != nil {
 		return nil, fmt.Errorf("failed to store fasting glucose metric: %w", err)
@@ -199,13 +608,15 @@ func (h *HealthService) AddCompositeHealthData(userID string, input *models.Comp
 			Unit:      input.Unit,
 			Notes:     input.Notes,
 			Source:    input.Source,
+			Timestamp: input.Timestamp,
 		}
 
 		return h.AddBloodPressureData(userID, bpInput)
 	}
 
-	// Handle blood glucose specially
-	if input.Type == "blood_glucose" {
+	// Handle blood glucose specially when sent as a fasting/postprandial pair; a plain
+	// blood_glucose reading with a value is stored as a regular metric below
+	if input.Type == "blood_glucose" && (input.Fasting != nil || input.Postprandial != nil) {
 		if input.Fasting == nil || input.Postprandial == nil {
 			return nil, fmt.Errorf("blood glucose requires both fasting and postprandial values")
 		}
@@ -217,6 +628,7 @@ func (h *HealthService) AddCompositeHealthData(userID string, input *models.Comp
 			Unit:         input.Unit,
 			Notes:        input.Notes,
 			Source:       input.Source,
+			Timestamp:    input.Timestamp,
 		}
 
 		return h.AddBloodGlucoseData(userID, bgInput)
@@ -228,43 +640,256 @@ func (h *HealthService) AddCompositeHealthData(userID string, input *models.Comp
 	}
 
 	regularInput := &models.HealthMetricInput{
-		Type:   input.Type,
-		Value:  *input.Value,
-		Unit:   input.Unit,
-		Notes:  input.Notes,
-		Source: input.Source,
+		Type:      input.Type,
+		Value:     *input.Value,
+		Unit:      input.Unit,
+		Notes:     input.Notes,
+		Source:    input.Source,
+		Timestamp: input.Timestamp,
+	}
+
+	return h.addHealthData(userID, regularInput)
+}
+
+// CompositeMetricTypes returns the metric types AddCompositeHealthData stores for input
+func CompositeMetricTypes(input *models.CompositeHealthMetricInput) []string {
+	switch {
+	case input.Type == "blood_pressure":
+		return []string{"blood_pressure_systolic", "blood_pressure_diastolic"}
+	case input.Type == "blood_glucose" && (input.Fasting != nil || input.Postprandial != nil):
+		return []string{"blood_glucose_fasting", "blood_glucose_postprandial"}
+	default:
+		return []string{input.Type}
 	}
+}
 
-	return h.AddHealthData(userID, regularInput)
+// GetMetricHistory retrieves the latest page of historical data for a specific metric type, along
+// with the LastEvaluatedKey to continue from (nil when there are no older readings)
+func (h *HealthService) GetMetricHistory(userID, metricType string, startTime, endTime time.Time, limit int) ([]models.HealthMetric, map[string]*dynamodb.AttributeValue, error) {
+	return h.GetMetricHistoryFromSources(userID, metricType, startTime, endTime, limit, nil, models.MetricSourceFilter{})
 }
 
-// GetMetricHistory retrieves historical data for a specific metric type
-func (h *HealthService) GetMetricHistory(userID, metricType string, startTime, endTime time.Time, limit int) ([]models.HealthMetric, error) {
+// GetMetricHistoryFromSources returns a page of a metric's history starting after startKey, keeping
+// only readings the filter accepts. The returned key continues from the last reading returned.
+func (h *HealthService) GetMetricHistoryFromSources(userID, metricType string, startTime, endTime time.Time, limit int, startKey map[string]*dynamodb.AttributeValue, filter models.MetricSourceFilter) ([]models.HealthMetric, map[string]*dynamodb.AttributeValue, error) {
 	// Validate metric type
-	if _, exists := models.SupportedMetrics[metricType]; !exists {
-		return nil, fmt.Errorf("unsupported metric type: %s", metricType)
+	if _, exists, err := h.resolveMetricInfo(userID, metricType); err != nil {
+		return nil, nil, err
+	} else if !exists {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedMetric, metricType)
+	}
+
+	if filter.IsEmpty() {
+		metrics, lastKey, err := h.db.GetHealthMetrics(userID, metricType, startTime, endTime, limit, startKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get health metrics: %w", err)
+		}
+		return metrics, lastKey, nil
+	}
+
+	// Sources are filtered after the query, so keep reading pages until enough readings match or
+	// the history runs out
+	pageSize := limit
+	if pageSize <= 0 {
+		pageSize = defaultMetricPageSize
+	}
+
+	var metrics []models.HealthMetric
+	lastKey := startKey
+	for {
+		page, next, err := h.db.GetHealthMetrics(userID, metricType, startTime, endTime, pageSize, lastKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get health metrics: %w", err)
+		}
+
+		for i := range page {
+			if !filter.Matches(&page[i]) {
+				continue
+			}
+			metrics = append(metrics, page[i])
+
+			if len(metrics) == pageSize {
+				// The next page starts after the last reading kept
+				if i == len(page)-1 && next == nil {
+					return metrics, nil, nil
+				}
+				return metrics, database.HealthMetricKey(userID, page[i].GetSortKey()), nil
+			}
+		}
+
+		if next == nil {
+			return metrics, nil, nil
+		}
+		lastKey = next
+	}
+}
+
+// EncodeMetricCursor turns the key returned with a page of metric history into an opaque cursor.
+// Only the sort key is kept; the user comes from the request.
+func EncodeMetricCursor(lastKey map[string]*dynamodb.AttributeValue) string {
+	if lastKey == nil || lastKey["sort_key"] == nil || lastKey["sort_key"].S == nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(*lastKey["sort_key"].S))
+}
+
+// DecodeMetricCursor reverses EncodeMetricCursor for the user's history of metricType. An empty
+// cursor means the first page.
+func DecodeMetricCursor(userID, metricType, cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	sortKey, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(sortKey), metricType+"#") {
+		return nil, ErrInvalidCursor
+	}
+	return database.HealthMetricKey(userID, string(sortKey)), nil
+}
+
+// ViewSourceFilter builds the source filter for a raw-data view. Explicitly requested sources are
+// kept; otherwise the sources configured in MetricViewExcludedSources are hidden.
+func (h *HealthService) ViewSourceFilter(requested []string) models.MetricSourceFilter {
+	if len(requested) > 0 {
+		return models.MetricSourceFilter{Include: requested}
+	}
+	return models.MetricSourceFilter{Exclude: h.cfg.MetricViewExcludedSources}
+}
+
+// GetAggregatedHistory groups a metric's readings between startTime and endTime into day, week or
+// month buckets and returns the mean, min, max and count of each. Bucket boundaries are calendar
+// boundaries in endTime's location, so callers pick the timezone by converting endTime.
+func (h *HealthService) GetAggregatedHistory(userID, metricType string, startTime, endTime time.Time, bucket string) (*models.AggregatedHistory, error) {
+	metricInfo, exists, err := h.resolveMetricInfo(userID, metricType)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMetric, metricType)
+	}
+
+	if bucket != models.AggregateDay && bucket != models.AggregateWeek && bucket != models.AggregateMonth {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAggregation, bucket)
+	}
+
+	if endTime.IsZero() {
+		endTime = time.Now()
 	}
+	loc := endTime.Location()
 
-	metrics, err := h.db.GetHealthMetrics(userID, metricType, startTime, endTime, limit)
+	var metrics []models.HealthMetric
+	err = h.db.ScanMetricRange(userID, metricType, startTime, endTime, func(page []models.HealthMetric) error {
+		metrics = append(metrics, page...)
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get health metrics: %w", err)
 	}
 
-	// Apply limit if specified
-	if limit > 0 && len(metrics) > limit {
-		metrics = metrics[:limit]
+	return &models.AggregatedHistory{
+		MetricType: metricType,
+		Unit:       metricInfo.Unit,
+		Bucket:     bucket,
+		Timezone:   loc.String(),
+		Buckets:    aggregateMetrics(metrics, bucket, loc, metricInfo),
+	}, nil
+}
+
+// aggregateMetrics groups chronologically ordered readings into buckets. Empty buckets are omitted.
+func aggregateMetrics(metrics []models.HealthMetric, bucket string, loc *time.Location, metricInfo models.MetricInfo) []models.MetricBucket {
+	buckets := make([]models.MetricBucket, 0)
+	var sum float64
+
+	flush := func() {
+		last := &buckets[len(buckets)-1]
+		last.Mean = metricInfo.Round(sum / float64(last.Count))
+	}
+
+	for _, metric := range metrics {
+		start := bucketStart(metric.Timestamp.In(loc), bucket)
+
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			if len(buckets) > 0 {
+				flush()
+			}
+			buckets = append(buckets, models.MetricBucket{
+				Start: start,
+				End:   bucketEnd(start, bucket),
+				Min:   metric.Value,
+				Max:   metric.Value,
+			})
+			sum = 0
+		}
+
+		current := &buckets[len(buckets)-1]
+		current.Count++
+		sum += metric.Value
+		current.Min = math.Min(current.Min, metric.Value)
+		current.Max = math.Max(current.Max, metric.Value)
+	}
+
+	if len(buckets) > 0 {
+		flush()
+	}
+
+	return buckets
+}
+
+// bucketStart returns the calendar start of the bucket containing t, in t's location.
+// time.Date is used rather than Truncate so boundaries stay at local midnight across DST changes.
+func bucketStart(t time.Time, bucket string) time.Time {
+	year, month, day := t.Date()
+	switch bucket {
+	case models.AggregateWeek:
+		// Go weeks start on Sunday; shift so Monday is day 0
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case models.AggregateMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// bucketEnd returns the exclusive end of the bucket starting at start
+func bucketEnd(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case models.AggregateWeek:
+		return start.AddDate(0, 0, 7)
+	case models.AggregateMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
 	}
+}
 
-	return metrics, nil
+// ExportMetrics streams the user's complete metric history page by page
+func (h *HealthService) ExportMetrics(userID string, handle func(page []models.HealthMetric) error) error {
+	return h.db.ScanAllUserMetrics(userID, handle)
 }
 
 // GetLatestMetrics retrieves the latest metrics for all types for a user
 func (h *HealthService) GetLatestMetrics(userID string) (map[string]models.LatestMetric, error) {
-	latestMetrics, err := h.db.GetLatestHealthMetrics(userID)
+	return h.GetLatestMetricsFromSources(userID, models.MetricSourceFilter{})
+}
+
+// GetLatestMetricsFromSources returns the latest reading of each metric among those the filter accepts
+func (h *HealthService) GetLatestMetricsFromSources(userID string, filter models.MetricSourceFilter) (map[string]models.LatestMetric, error) {
+	var keep func(metric *models.HealthMetric) bool
+	if !filter.IsEmpty() {
+		keep = filter.Matches
+	}
+
+	latestMetrics, err := h.db.GetLatestHealthMetricsMatching(userID, nil, keep)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get latest health metrics: %w", err)
 	}
 
+	customRanges, err := h.GetCustomRanges(userID)
+	if err != nil {
+		return nil, err
+	}
+
 	result := make(map[string]models.LatestMetric)
 	for metricType, metric := range latestMetrics {
 		// Calculate trend (placeholder - would need more sophisticated logic)
@@ -275,6 +900,8 @@ func (h *HealthService) GetLatestMetrics(userID string) (map[string]models.Lates
 			Unit:      metric.Unit,
 			Timestamp: metric.Timestamp,
 			Trend:     trend,
+			// Composite and custom metrics without a normal range are "unknown"
+			Status: effectiveRange(metricType, customRanges).Range.Status(metric.Value),
 		}
 	}
 
@@ -305,8 +932,6 @@ func (h *HealthService) GetHealthSummary(userID string) (*models.HealthSummary,
 
 // GetHealthTrends analyzes trends for specific metrics
 func (h *HealthService) GetHealthTrends(userID string, metricTypes []string, period string) ([]models.HealthTrend, error) {
-	var trends []models.HealthTrend
-
 	// Calculate time range based on period
 	endTime := time.Now()
 	var startTime time.Time
@@ -322,93 +947,223 @@ func (h *HealthService) GetHealthTrends(userID string, metricTypes []string, per
 		startTime = endTime.AddDate(0, -1, 0) // Default to month
 	}
 
+	if limit := h.cfg.TrendsMaxMetricTypes; limit > 0 && len(metricTypes) > limit {
+		return nil, fmt.Errorf("%w: %d metric types requested, at most %d allowed; request fewer metric types",
+			ErrTrendsTooComplex, len(metricTypes), limit)
+	}
+
+	// Custom metrics trend like built-in ones; types the user hasn't defined are skipped
+	infos, err := h.metricInfos(userID, metricTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each metric that's trended scans its whole period, so cap the total days scanned
+	scanned := 0
 	for _, metricType := range metricTypes {
-		metrics, err := h.GetMetricHistory(userID, metricType, startTime, endTime, 0)
-		if err != nil {
-			continue // Skip failed metrics
+		if _, exists := infos[metricType]; exists {
+			scanned++
 		}
+	}
+	days := int(endTime.Sub(startTime).Hours() / 24)
+	if limit := h.cfg.TrendsMaxCostDays; limit > 0 && scanned*days > limit {
+		return nil, fmt.Errorf("%w: %d metric types over %d days exceeds the limit of %d metric-days; use a shorter period or fewer metric types",
+			ErrTrendsTooComplex, scanned, days, limit)
+	}
+
+	// Anomalies are flagged against the user's own ranges where they've set them
+	customRanges, err := h.GetCustomRanges(userID)
+	if err != nil {
+		return nil, err
+	}
 
-		if len(metrics) == 0 {
+	// Query metrics on a bounded pool; results keep the requested order
+	results := make([]*models.HealthTrend, len(metricTypes))
+	errs := make([]error, len(metricTypes))
+	sem := make(chan struct{}, h.cfg.TrendsConcurrency)
+	var wg sync.WaitGroup
+	for i, metricType := range metricTypes {
+		metricInfo, exists := infos[metricType]
+		if !exists {
 			continue
 		}
 
-		trend := h.analyzeMetricTrend(metrics, metricType, period)
-		trends = append(trends, trend)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, metricType string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Read the whole window; analyzeMetricTrend takes the latest reading first
+			var metrics []models.HealthMetric
+			err := h.db.ScanMetricRange(userID, metricType, startTime, endTime, func(page []models.HealthMetric) error {
+				metrics = append(metrics, page...)
+				return nil
+			})
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to read %s readings: %w", metricType, err)
+				return
+			}
+			if len(metrics) == 0 {
+				return // Skip empty metrics
+			}
+			slices.Reverse(metrics)
+
+			trend := h.analyzeMetricTrend(metrics, metricType, metricInfo, period, customRanges)
+			results[i] = &trend
+		}(i, metricType)
+	}
+	wg.Wait()
+
+	// A partial trend list would look like the missing metrics have no readings
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var trends []models.HealthTrend
+	for _, trend := range results {
+		if trend != nil {
+			trends = append(trends, *trend)
+		}
 	}
 
 	return trends, nil
 }
 
-// ValidateHealthData validates health metric input
-func (h *HealthService) ValidateHealthData(input *models.HealthMetricInput) error {
+// ValidateHealthData validates health metric input against the built-in metrics and, when userID is
+// set, the user's custom metrics
+func (h *HealthService) ValidateHealthData(userID string, input *models.HealthMetricInput) error {
 	// Check if metric type is supported
-	metricInfo, exists := models.SupportedMetrics[input.Type]
+	metricInfo, exists, err := h.resolveMetricInfo(userID, input.Type)
+	if err != nil {
+		return err
+	}
 	if !exists {
-		return fmt.Errorf("unsupported metric type: %s", input.Type)
+		return fmt.Errorf("%w: %s", ErrUnsupportedMetric, input.Type)
 	}
 
-	// Validate unit
-	if metricInfo.Unit != "" && input.Unit != metricInfo.Unit {
-		return fmt.Errorf("invalid unit for %s. Expected: %s", input.Type, metricInfo.Unit)
+	if _, err := measurementTime(input.Timestamp); err != nil {
+		return err
 	}
 
-	// Validate value is positive for most metrics
-	if input.Value <= 0 {
-		return fmt.Errorf("value must be positive")
+	// Validate unit, accepting known aliases that are converted on ingest
+	value := input.Value
+	if _, builtIn := models.SupportedMetrics[input.Type]; builtIn {
+		converted, _, ok := models.ConvertToCanonical(input.Type, input.Value, input.Unit)
+		if !ok {
+			return fmt.Errorf("invalid unit for %s. Expected: %s", input.Type, metricInfo.Unit)
+		}
+		value = converted
+	} else if input.Unit != metricInfo.Unit {
+		return fmt.Errorf("invalid unit for %s. Expected: %s", input.Type, metricInfo.Unit)
 	}
 
-	// Validate value is within reasonable ranges (basic sanity checks)
-	if err := h.validateValueRange(input.Type, input.Value); err != nil {
-		return err
+	// Boolean and ordinal values are checked against their kind rather than a numeric range
+	if metricInfo.Kind() != models.ValueKindNumeric {
+		return metricInfo.ValidateKind(input.Value)
 	}
 
-	return nil
+	// Validate value is within the metric's reasonable range (basic sanity checks), in canonical units
+	return metricInfo.ValidateSanity(value)
 }
 
-// calculateTrend calculates trend for a metric (placeholder implementation)
+// calculateTrend classifies the last 30 days of a metric as "up", "down" or "stable" using the
+// least-squares regression slope, normalized by the mean so the threshold works across units
 func (h *HealthService) calculateTrend(userID, metricType string) string {
-	// Get recent metrics to calculate trend
 	endTime := time.Now()
 	startTime := endTime.AddDate(0, 0, -30) // Last 30 days
 
-	metrics, err := h.GetMetricHistory(userID, metricType, startTime, endTime, 10)
+	metrics, _, err := h.GetMetricHistory(userID, metricType, startTime, endTime, trendMaxPoints)
 	if err != nil || len(metrics) < 2 {
 		return "stable"
 	}
 
-	// Simple trend calculation: compare first and last values
-	first := metrics[len(metrics)-1].Value // Oldest
-	last := metrics[0].Value               // Newest (reversed order)
+	return classifySlope(metrics, h.cfg.TrendSlopeThreshold)
+}
+
+// classifySlope fits value = a + b*days by least squares and compares b/mean against threshold
+func classifySlope(metrics []models.HealthMetric, threshold float64) string {
+	if len(metrics) < 2 {
+		return "stable"
+	}
+
+	// x is days since the earliest reading so the slope is in units per day
+	origin := metrics[0].Timestamp
+	for _, metric := range metrics {
+		if metric.Timestamp.Before(origin) {
+			origin = metric.Timestamp
+		}
+	}
+
+	n := float64(len(metrics))
+	var sumX, sumY float64
+	for _, metric := range metrics {
+		sumX += metric.Timestamp.Sub(origin).Hours() / 24
+		sumY += metric.Value
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var covXY, varX float64
+	for _, metric := range metrics {
+		dx := metric.Timestamp.Sub(origin).Hours()/24 - meanX
+		covXY += dx * (metric.Value - meanY)
+		varX += dx * dx
+	}
+
+	// All readings at the same instant (or a zero mean) give no usable slope
+	if varX == 0 || meanY == 0 {
+		return "stable"
+	}
 
-	if last > first*1.05 { // 5% increase
+	normalizedSlope := (covXY / varX) / math.Abs(meanY)
+	if normalizedSlope > threshold {
 		return "up"
-	} else if last < first*0.95 { // 5% decrease
+	} else if normalizedSlope < -threshold {
 		return "down"
 	}
 
 	return "stable"
 }
 
-// analyzeMetricTrend analyzes trend data for a metric
-func (h *HealthService) analyzeMetricTrend(metrics []models.HealthMetric, metricType, period string) models.HealthTrend {
+// analyzeMetricTrend analyzes trend data for a metric described by metricInfo
+func (h *HealthService) analyzeMetricTrend(metrics []models.HealthMetric, metricType string, metricInfo models.MetricInfo, period string, customRanges map[string]models.Range) models.HealthTrend {
+	normalRange := effectiveRangeFor(metricType, metricInfo, customRanges).Range
+
 	if len(metrics) == 0 {
 		return models.HealthTrend{
-			MetricType: metricType,
-			Period:     period,
-			DataPoints: []models.DataPoint{},
+			MetricType:    metricType,
+			Period:        period,
+			Unit:          metricInfo.Unit,
+			Precision:     metricInfo.Precision,
+			DataPoints:    []models.DataPoint{},
+			MovingAverage: []models.DataPoint{},
+			ValueKind:     metricInfo.Kind(),
 		}
 	}
 
+	if metricInfo.Kind() == models.ValueKindBoolean {
+		return analyzeBooleanTrend(metrics, metricType, period, metricInfo)
+	}
+
 	// Convert to data points
 	dataPoints := make([]models.DataPoint, len(metrics))
 	sum := 0.0
 	min := metrics[0].Value
 	max := metrics[0].Value
 
+	anomalyCount := 0
+
 	for i, metric := range metrics {
+		// Metrics without a normal range (including composites like blood_pressure) are never flagged
+		isAnomaly := normalRange != nil && normalRange.Status(metric.Value) != models.RangeStatusNormal
+		if isAnomaly {
+			anomalyCount++
+		}
+
 		dataPoints[i] = models.DataPoint{
 			Timestamp: metric.Timestamp,
-			Value:     metric.Value,
+			Value:     metricInfo.Round(metric.Value),
+			IsAnomaly: isAnomaly,
 		}
 
 		sum += metric.Value
@@ -422,66 +1177,111 @@ func (h *HealthService) analyzeMetricTrend(metrics []models.HealthMetric, metric
 
 	average := sum / float64(len(metrics))
 
-	// Calculate overall trend
-	trend := "stable"
-	if len(metrics) >= 2 {
-		first := metrics[len(metrics)-1].Value
-		last := metrics[0].Value
-
-		if last > first*1.1 {
-			trend = "up"
-		} else if last < first*0.9 {
-			trend = "down"
-		}
+	variance := 0.0
+	for _, metric := range metrics {
+		diff := metric.Value - average
+		variance += diff * diff
 	}
+	stdDev := math.Sqrt(variance / float64(len(metrics)))
+
+	movingAverage := h.movingAverage(metrics, metricInfo)
 
+	// Classify the overall trend the same way as the dashboard summary
+	trend := classifySlope(metrics, h.cfg.TrendSlopeThreshold)
+
+	// Aggregates are computed on raw values and rounded only for display
 	return models.HealthTrend{
-		MetricType: metricType,
-		Period:     period,
-		DataPoints: dataPoints,
-		Average:    average,
-		Min:        min,
-		Max:        max,
-		Trend:      trend,
+		MetricType:    metricType,
+		Period:        period,
+		Unit:          metricInfo.Unit,
+		Precision:     metricInfo.Precision,
+		DataPoints:    dataPoints,
+		Average:       metricInfo.Round(average),
+		Min:           metricInfo.Round(min),
+		Max:           metricInfo.Round(max),
+		StdDev:        metricInfo.Round(stdDev),
+		MovingAverage: movingAverage,
+		AnomalyCount:  anomalyCount,
+		Trend:         trend,
+		ValueKind:     metricInfo.Kind(),
 	}
 }
 
-// validateValueRange validates if a value is within reasonable ranges
-func (h *HealthService) validateValueRange(metricType string, value float64) error {
-	switch metricType {
-	case "blood_pressure_systolic":
-		if value < 60 || value > 250 {
-			return fmt.Errorf("systolic blood pressure value out of reasonable range (60-250 mmHg)")
-		}
-	case "blood_pressure_diastolic":
-		if value < 30 || value > 150 {
-			return fmt.Errorf("diastolic blood pressure value out of reasonable range (30-150 mmHg)")
-		}
-	case "heart_rate":
-		if value < 30 || value > 220 {
-			return fmt.Errorf("heart rate value out of reasonable range (30-220 bpm)")
-		}
-	case "weight":
-		if value < 1 || value > 500 {
-			return fmt.Errorf("weight value out of reasonable range (1-500 kg)")
-		}
-	case "height":
-		if value < 50 || value > 250 {
-			return fmt.Errorf("height value out of reasonable range (50-250 cm)")
+// analyzeBooleanTrend summarizes yes/no readings as counts. A moving average, spread or
+// percentage trend isn't meaningful for 0/1 values, so only the share of yes is reported.
+func analyzeBooleanTrend(metrics []models.HealthMetric, metricType, period string, metricInfo models.MetricInfo) models.HealthTrend {
+	dataPoints := make([]models.DataPoint, len(metrics))
+	yes, no := 0, 0
+	for i, metric := range metrics {
+		if metric.Value == 1 {
+			yes++
+		} else {
+			no++
 		}
-	case "blood_glucose":
-		if value < 20 || value > 600 {
-			return fmt.Errorf("blood glucose value out of reasonable range (20-600 mg/dL)")
+		dataPoints[i] = models.DataPoint{
+			Timestamp: metric.Timestamp,
+			Value:     metric.Value,
 		}
-	case "blood_glucose_fasting":
-		if value < 50 || value > 200 {
-			return fmt.Errorf("fasting blood glucose value out of reasonable range (50-200 mg/dL)")
+	}
+
+	min, max := 0.0, 1.0
+	if no == 0 {
+		min = 1
+	}
+	if yes == 0 {
+		max = 0
+	}
+
+	return models.HealthTrend{
+		MetricType:    metricType,
+		Period:        period,
+		Unit:          metricInfo.Unit,
+		Precision:     2,
+		DataPoints:    dataPoints,
+		Average:       math.Round(float64(yes)/float64(len(metrics))*100) / 100,
+		Min:           min,
+		Max:           max,
+		MovingAverage: []models.DataPoint{},
+		Trend:         "stable",
+		ValueKind:     models.ValueKindBoolean,
+		YesCount:      &yes,
+		NoCount:       &no,
+	}
+}
+
+// movingAverage computes a trailing moving average over the configured window.
+// Metrics arrive latest first; each output point averages that point and up to window-1
+// earlier readings, so the start of the series (or a series shorter than the window)
+// averages whatever points exist. The result is aligned index-for-index with the input.
+func (h *HealthService) movingAverage(metrics []models.HealthMetric, metricInfo models.MetricInfo) []models.DataPoint {
+	window := h.cfg.TrendMAWindow
+
+	points := make([]models.DataPoint, len(metrics))
+	sum := 0.0
+	// Walk oldest to newest, keeping a running sum of the last `window` values
+	for i := len(metrics) - 1; i >= 0; i-- {
+		sum += metrics[i].Value
+		count := len(metrics) - i
+		if count > window {
+			sum -= metrics[i+window].Value
+			count = window
 		}
-	case "blood_glucose_postprandial":
-		if value < 70 || value > 400 {
-			return fmt.Errorf("postprandial blood glucose value out of reasonable range (70-400 mg/dL)")
+
+		points[i] = models.DataPoint{
+			Timestamp: metrics[i].Timestamp,
+			Value:     metricInfo.Round(sum / float64(count)),
 		}
 	}
 
-	return nil
+	return points
+}
+
+// validateValueRange validates if a value is within the reasonable range set for its metric type
+// in models.SupportedMetrics. Unknown metric types are not checked here.
+func (h *HealthService) validateValueRange(metricType string, value float64) error {
+	metricInfo, exists := models.SupportedMetrics[metricType]
+	if !exists {
+		return nil
+	}
+	return metricInfo.ValidateSanity(value)
 }
@@ -0,0 +1,748 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/models"
+)
+
+// newTestHealthService returns a HealthService over an in-memory DynamoDB
+func newTestHealthService(t *testing.T, configure func(cfg *config.Config)) (*HealthService, *database.DynamoDBClient, *dynamotest.Fake) {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	db, fake := dynamotest.NewClient(cfg)
+	return NewHealthService(db, cfg), db, fake
+}
+
+// metricSeries returns readings of one type, latest first as GetMetricHistory returns them,
+// taken a day apart and ending at end
+func metricSeries(metricType, unit string, end time.Time, values ...float64) []models.HealthMetric {
+	metrics := make([]models.HealthMetric, len(values))
+	for i, value := range values {
+		metrics[len(values)-1-i] = models.HealthMetric{
+			UserID:    "user-1",
+			Type:      metricType,
+			Value:     value,
+			Unit:      unit,
+			Timestamp: end.AddDate(0, 0, i-len(values)+1),
+		}
+	}
+	return metrics
+}
+
+func TestTrendCarriesUnitAndRoundsToPrecision(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	// Oldest to newest
+	metrics := metricSeries("weight", "kg", time.Now(), 70.04, 70.26, 71.13)
+	trend := service.analyzeMetricTrend(metrics, "weight", models.SupportedMetrics["weight"], "month", nil)
+
+	if trend.Unit != "kg" || trend.Precision != 1 {
+		t.Errorf("unit %q precision %d, want kg and 1", trend.Unit, trend.Precision)
+	}
+	if trend.Average != 70.5 || trend.Min != 70 || trend.Max != 71.1 {
+		t.Errorf("average %v min %v max %v, want 70.5, 70 and 71.1", trend.Average, trend.Min, trend.Max)
+	}
+
+	want := []float64{71.1, 70.3, 70}
+	for i, point := range trend.DataPoints {
+		if point.Value != want[i] {
+			t.Errorf("data point %d = %v, want %v", i, point.Value, want[i])
+		}
+	}
+}
+
+// addMetric submits a reading through AddHealthData
+func addMetric(t *testing.T, service *HealthService, metricType string, value float64, unit string, at time.Time) {
+	t.Helper()
+
+	input := &models.HealthMetricInput{Type: metricType, Value: value, Unit: unit, Timestamp: &at}
+	if _, _, err := service.AddHealthData("user-1", input); err != nil {
+		t.Fatalf("add %s: %v", metricType, err)
+	}
+}
+
+func TestAddHealthDataDerivesBMI(t *testing.T) {
+	service, _, _ := newTestHealthService(t, func(cfg *config.Config) { cfg.AutoComputeBMI = true })
+
+	now := time.Now().UTC().Add(-time.Minute)
+	addMetric(t, service, "height", 180, "cm", now.AddDate(0, -2, 0))
+	addMetric(t, service, "weight", 81, "kg", now)
+
+	bmi, _, err := service.GetMetricHistory("user-1", "bmi", now.Add(-time.Hour), now.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatalf("get bmi: %v", err)
+	}
+	if len(bmi) != 1 {
+		t.Fatalf("got %d bmi readings, want 1", len(bmi))
+	}
+	if bmi[0].Value != 25 || bmi[0].Source != models.MetricSourceDerived || !bmi[0].Timestamp.Equal(now) {
+		t.Errorf("bmi = %+v, want 25 derived at the weight's timestamp", bmi[0])
+	}
+}
+
+func TestAddHealthDataSkipsBMIWithoutHeight(t *testing.T) {
+	service, _, _ := newTestHealthService(t, func(cfg *config.Config) { cfg.AutoComputeBMI = true })
+
+	now := time.Now().UTC().Add(-time.Minute)
+	// Too old to pair with: heights are only looked up for the past year
+	addMetric(t, service, "height", 180, "cm", now.AddDate(-2, 0, 0))
+	addMetric(t, service, "weight", 81, "kg", now)
+
+	bmi, _, err := service.GetMetricHistory("user-1", "bmi", now.AddDate(-3, 0, 0), now.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatalf("get bmi: %v", err)
+	}
+	if len(bmi) != 0 {
+		t.Errorf("got %+v, want no bmi without a recent height", bmi)
+	}
+}
+
+func TestAddHealthDataConvertsToCanonicalUnit(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	at := time.Now().UTC().Add(-time.Hour)
+	metric, _, err := service.AddHealthData("user-1", &models.HealthMetricInput{Type: "weight", Value: 180, Unit: "lbs", Timestamp: &at})
+	if err != nil {
+		t.Fatalf("add weight: %v", err)
+	}
+	if metric.Unit != "kg" || metric.Value < 81.6 || metric.Value > 81.7 {
+		t.Errorf("stored %v %s, want about 81.6 kg", metric.Value, metric.Unit)
+	}
+	if metric.OriginalUnit != "lbs" || metric.OriginalValue == nil || *metric.OriginalValue != 180 {
+		t.Errorf("original = %v %q, want 180 lbs", metric.OriginalValue, metric.OriginalUnit)
+	}
+
+	_, _, err = service.AddHealthData("user-1", &models.HealthMetricInput{Type: "weight", Value: 12, Unit: "stone", Timestamp: &at})
+	if err == nil {
+		t.Error("unknown unit accepted")
+	}
+}
+
+func TestAddHealthDataUsesMeasurementTimestamp(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	backdated := time.Date(2023, 3, 14, 7, 30, 0, 0, time.FixedZone("CET", 3600))
+	metric, _, err := service.AddHealthData("user-1", &models.HealthMetricInput{Type: "heart_rate", Value: 64, Unit: "bpm", Timestamp: &backdated})
+	if err != nil {
+		t.Fatalf("add backdated: %v", err)
+	}
+	if !metric.Timestamp.Equal(backdated) || metric.Timestamp.Location() != time.UTC {
+		t.Errorf("timestamp %v, want %v in UTC", metric.Timestamp, backdated)
+	}
+
+	before := time.Now().UTC()
+	metric, _, err = service.AddHealthData("user-1", &models.HealthMetricInput{Type: "heart_rate", Value: 66, Unit: "bpm"})
+	if err != nil {
+		t.Fatalf("add without timestamp: %v", err)
+	}
+	if metric.Timestamp.Before(before) || metric.Timestamp.After(time.Now().UTC()) {
+		t.Errorf("timestamp %v, want now", metric.Timestamp)
+	}
+
+	future := time.Now().UTC().Add(time.Hour)
+	if _, _, err := service.AddHealthData("user-1", &models.HealthMetricInput{Type: "heart_rate", Value: 68, Unit: "bpm", Timestamp: &future}); !errors.Is(err, ErrFutureTimestamp) {
+		t.Errorf("future timestamp: err = %v, want ErrFutureTimestamp", err)
+	}
+	bp := &models.BloodPressureInput{Type: "blood_pressure", Systolic: 120, Diastolic: 80, Unit: "mmHg", Timestamp: &future}
+	if _, err := service.AddBloodPressureData("user-1", bp); !errors.Is(err, ErrFutureTimestamp) {
+		t.Errorf("future blood pressure: err = %v, want ErrFutureTimestamp", err)
+	}
+}
+
+func TestAddCompositeHealthDataStoresBloodGlucosePair(t *testing.T) {
+	service, db, _ := newTestHealthService(t, nil)
+
+	at := time.Now().UTC().Add(-time.Hour)
+	fasting, postprandial := 92.0, 138.0
+	input := &models.CompositeHealthMetricInput{
+		Type: "blood_glucose", Fasting: &fasting, Postprandial: &postprandial, Unit: "mg/dL", Timestamp: &at,
+	}
+	if _, err := service.AddCompositeHealthData("user-1", input); err != nil {
+		t.Fatalf("add composite: %v", err)
+	}
+
+	latest, err := db.GetLatestHealthMetrics("user-1")
+	if err != nil {
+		t.Fatalf("get latest: %v", err)
+	}
+	for metricType, want := range map[string]float64{"blood_glucose_fasting": 92, "blood_glucose_postprandial": 138} {
+		metric, ok := latest[metricType]
+		if !ok {
+			t.Errorf("%s not stored", metricType)
+			continue
+		}
+		if metric.Value != want || metric.Unit != "mg/dL" || !metric.Timestamp.Equal(at) {
+			t.Errorf("%s = %+v, want %v mg/dL at %v", metricType, metric, want, at)
+		}
+	}
+}
+
+func TestAddCompositeHealthDataConvertsBloodGlucoseFromMmol(t *testing.T) {
+	service, db, _ := newTestHealthService(t, nil)
+
+	fasting, postprandial := 5.0, 7.8
+	input := &models.CompositeHealthMetricInput{
+		Type: "blood_glucose", Fasting: &fasting, Postprandial: &postprandial, Unit: "mmol/L",
+	}
+	if _, err := service.AddCompositeHealthData("user-1", input); err != nil {
+		t.Fatalf("add composite: %v", err)
+	}
+
+	latest, err := db.GetLatestHealthMetrics("user-1")
+	if err != nil {
+		t.Fatalf("get latest: %v", err)
+	}
+	for metricType, original := range map[string]float64{"blood_glucose_fasting": 5.0, "blood_glucose_postprandial": 7.8} {
+		metric, ok := latest[metricType]
+		if !ok {
+			t.Errorf("%s not stored", metricType)
+			continue
+		}
+		want := original * 18.016
+		if math.Abs(metric.Value-want) > 0.01 || metric.Unit != "mg/dL" {
+			t.Errorf("%s = %v %s, want %.2f mg/dL", metricType, metric.Value, metric.Unit, want)
+		}
+		if metric.OriginalValue == nil || *metric.OriginalValue != original || metric.OriginalUnit != "mmol/L" {
+			t.Errorf("%s original = %v %q, want %v mmol/L", metricType, metric.OriginalValue, metric.OriginalUnit, original)
+		}
+	}
+}
+
+func TestAddCompositeHealthDataRejectsInvalidBloodGlucose(t *testing.T) {
+	service, db, _ := newTestHealthService(t, nil)
+
+	value := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		name  string
+		input models.CompositeHealthMetricInput
+	}{
+		{"fasting out of range", models.CompositeHealthMetricInput{Fasting: value(20), Postprandial: value(140), Unit: "mg/dL"}},
+		{"postprandial out of range", models.CompositeHealthMetricInput{Fasting: value(90), Postprandial: value(900), Unit: "mg/dL"}},
+		{"wrong unit", models.CompositeHealthMetricInput{Fasting: value(90), Postprandial: value(140), Unit: "mg"}},
+		{"mmol/L out of range", models.CompositeHealthMetricInput{Fasting: value(1), Postprandial: value(7.8), Unit: "mmol/L"}},
+		{"missing postprandial", models.CompositeHealthMetricInput{Fasting: value(90), Unit: "mg/dL"}},
+	} {
+		tc.input.Type = "blood_glucose"
+		if _, err := service.AddCompositeHealthData("user-1", &tc.input); err == nil {
+			t.Errorf("%s: accepted", tc.name)
+		}
+	}
+
+	latest, err := db.GetLatestHealthMetrics("user-1")
+	if err != nil {
+		t.Fatalf("get latest: %v", err)
+	}
+	if len(latest) != 0 {
+		t.Errorf("stored %v from rejected input", latest)
+	}
+}
+
+func TestTrendStdDevAndMovingAverage(t *testing.T) {
+	service, _, _ := newTestHealthService(t, func(cfg *config.Config) { cfg.TrendMAWindow = 3 })
+
+	// Oldest to newest; water_intake keeps two decimals
+	metrics := metricSeries("water_intake", "liters", time.Now(), 1, 2, 4, 7)
+	trend := service.analyzeMetricTrend(metrics, "water_intake", models.SupportedMetrics["water_intake"], "week", nil)
+
+	if trend.Average != 3.5 || trend.StdDev != 2.29 {
+		t.Errorf("average %v std dev %v, want 3.5 and 2.29", trend.Average, trend.StdDev)
+	}
+
+	// Latest first like the data points; the oldest points average whatever precedes them
+	want := []float64{4.33, 2.33, 1.5, 1}
+	if len(trend.MovingAverage) != len(want) {
+		t.Fatalf("got %d moving average points, want %d", len(trend.MovingAverage), len(want))
+	}
+	for i, point := range trend.MovingAverage {
+		if point.Value != want[i] || !point.Timestamp.Equal(metrics[i].Timestamp) {
+			t.Errorf("moving average %d = %v at %v, want %v at %v", i, point.Value, point.Timestamp, want[i], metrics[i].Timestamp)
+		}
+	}
+}
+
+func TestMovingAverageShorterThanWindow(t *testing.T) {
+	service, _, _ := newTestHealthService(t, func(cfg *config.Config) { cfg.TrendMAWindow = 7 })
+
+	metrics := metricSeries("water_intake", "liters", time.Now(), 2, 4)
+	trend := service.analyzeMetricTrend(metrics, "water_intake", models.SupportedMetrics["water_intake"], "week", nil)
+
+	if len(trend.MovingAverage) != 2 || trend.MovingAverage[0].Value != 3 || trend.MovingAverage[1].Value != 2 {
+		t.Errorf("moving average = %+v, want 3 then 2", trend.MovingAverage)
+	}
+}
+
+func TestTrendFlagsOutOfRangePoints(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	// Oldest to newest; the normal heart rate range is 60-100 bpm
+	metrics := metricSeries("heart_rate", "bpm", time.Now(), 72, 105, 98, 130)
+	trend := service.analyzeMetricTrend(metrics, "heart_rate", models.SupportedMetrics["heart_rate"], "week", nil)
+
+	if trend.AnomalyCount != 2 {
+		t.Errorf("anomaly count = %d, want 2", trend.AnomalyCount)
+	}
+	want := []bool{true, false, true, false} // Latest first
+	for i, point := range trend.DataPoints {
+		if point.IsAnomaly != want[i] {
+			t.Errorf("point %d (%v) anomaly = %v, want %v", i, point.Value, point.IsAnomaly, want[i])
+		}
+	}
+
+	// A custom range replaces the default
+	trend = service.analyzeMetricTrend(metrics, "heart_rate", models.SupportedMetrics["heart_rate"], "week", map[string]models.Range{"heart_rate": {Min: 50, Max: 140}})
+	if trend.AnomalyCount != 0 {
+		t.Errorf("anomaly count with a custom range = %d, want 0", trend.AnomalyCount)
+	}
+}
+
+func TestTrendNeverFlagsMetricsWithoutRange(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	// blood_pressure is a composite without a range of its own; weight only has a sanity range
+	for _, metricType := range []string{"blood_pressure", "weight"} {
+		metrics := metricSeries(metricType, models.SupportedMetrics[metricType].Unit, time.Now(), 40, 150, 300)
+		trend := service.analyzeMetricTrend(metrics, metricType, models.SupportedMetrics[metricType], "week", nil)
+
+		if trend.AnomalyCount != 0 {
+			t.Errorf("%s: anomaly count = %d, want 0", metricType, trend.AnomalyCount)
+		}
+		for i, point := range trend.DataPoints {
+			if point.IsAnomaly {
+				t.Errorf("%s: point %d flagged for a metric without a normal range", metricType, i)
+			}
+		}
+	}
+}
+
+func TestCalculateTrendUsesRegressionSlope(t *testing.T) {
+	noisy := make([]float64, 20)
+	rising := make([]float64, 20)
+	falling := make([]float64, 20)
+	for i := range noisy {
+		noisy[i] = 70 + float64(3-6*(i%2)) // 73, 67, 73, ...
+		rising[i] = 60 + float64(i)
+		falling[i] = 100 - float64(i)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		values []float64
+		want   string
+	}{
+		{"rising", rising, "up"},
+		{"falling", falling, "down"},
+		{"flat noisy", noisy, "stable"},
+		{"single reading", []float64{70}, "stable"},
+	} {
+		service, db, _ := newTestHealthService(t, nil)
+		for _, metric := range metricSeries("heart_rate", "bpm", time.Now().Add(-time.Hour), tc.values...) {
+			metric := metric
+			if err := db.PutHealthMetric(&metric); err != nil {
+				t.Fatalf("put metric: %v", err)
+			}
+		}
+
+		if got := service.calculateTrend("user-1", "heart_rate"); got != tc.want {
+			t.Errorf("%s: trend = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestGetAggregatedHistoryBuckets(t *testing.T) {
+	service, db, _ := newTestHealthService(t, nil)
+
+	readings := []struct {
+		at    string
+		value float64
+	}{
+		{"2024-03-04T02:00:00Z", 70}, // Monday
+		{"2024-03-04T15:00:00Z", 80},
+		{"2024-03-05T10:00:00Z", 90},
+		{"2024-03-10T23:00:00Z", 60}, // Sunday, the last day of the week
+		{"2024-03-11T01:00:00Z", 100},
+	}
+	for _, reading := range readings {
+		at, _ := time.Parse(time.RFC3339, reading.at)
+		if err := db.PutHealthMetric(&models.HealthMetric{UserID: "user-1", Type: "heart_rate", Value: reading.value, Unit: "bpm", Timestamp: at}); err != nil {
+			t.Fatalf("put metric: %v", err)
+		}
+	}
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	type bucket struct {
+		start string
+		mean  float64
+		min   float64
+		max   float64
+		count int
+	}
+	for _, tc := range []struct {
+		name   string
+		bucket string
+		loc    *time.Location
+		want   []bucket
+	}{
+		{"day UTC", models.AggregateDay, time.UTC, []bucket{
+			{"2024-03-04T00:00:00Z", 75, 70, 80, 2},
+			{"2024-03-05T00:00:00Z", 90, 90, 90, 1},
+			{"2024-03-10T00:00:00Z", 60, 60, 60, 1},
+			{"2024-03-11T00:00:00Z", 100, 100, 100, 1},
+		}},
+		// Both ends cross midnight in New York, and DST starts on March 10
+		{"day New York", models.AggregateDay, newYork, []bucket{
+			{"2024-03-03T00:00:00-05:00", 70, 70, 70, 1},
+			{"2024-03-04T00:00:00-05:00", 80, 80, 80, 1},
+			{"2024-03-05T00:00:00-05:00", 90, 90, 90, 1},
+			{"2024-03-10T00:00:00-05:00", 80, 60, 100, 2},
+		}},
+		{"week", models.AggregateWeek, time.UTC, []bucket{
+			{"2024-03-04T00:00:00Z", 75, 60, 90, 4},
+			{"2024-03-11T00:00:00Z", 100, 100, 100, 1},
+		}},
+		{"month", models.AggregateMonth, time.UTC, []bucket{
+			{"2024-03-01T00:00:00Z", 80, 60, 100, 5},
+		}},
+	} {
+		history, err := service.GetAggregatedHistory("user-1", "heart_rate", start, end.In(tc.loc), tc.bucket)
+		if err != nil {
+			t.Fatalf("%s: aggregate: %v", tc.name, err)
+		}
+		if len(history.Buckets) != len(tc.want) {
+			t.Errorf("%s: got %d buckets %+v, want %d", tc.name, len(history.Buckets), history.Buckets, len(tc.want))
+			continue
+		}
+		for i, got := range history.Buckets {
+			want := tc.want[i]
+			if got.Start.Format(time.RFC3339) != want.start || got.Mean != want.mean || got.Min != want.min || got.Max != want.max || got.Count != want.count {
+				t.Errorf("%s: bucket %d = %s mean %v min %v max %v count %d, want %+v",
+					tc.name, i, got.Start.Format(time.RFC3339), got.Mean, got.Min, got.Max, got.Count, want)
+			}
+		}
+	}
+
+	if _, err := service.GetAggregatedHistory("user-1", "heart_rate", start, end, "hour"); !errors.Is(err, ErrUnsupportedAggregation) {
+		t.Errorf("hourly buckets returned %v, want ErrUnsupportedAggregation", err)
+	}
+}
+
+func TestGetMetricHistoryFromSourcesPagesUntilLimitFilled(t *testing.T) {
+	service, db, fake := newTestHealthService(t, nil)
+	fake.MaxPageItems = 2
+
+	// The newest four readings are derived, so the first two pages hold nothing the filter accepts
+	base := time.Now().UTC().Add(-time.Hour)
+	for i, source := range []string{models.MetricSourceManual, models.MetricSourceManual, models.MetricSourceManual,
+		models.MetricSourceDerived, models.MetricSourceDerived, models.MetricSourceDerived, models.MetricSourceDerived} {
+		metric := &models.HealthMetric{UserID: "user-1", Type: "weight", Value: float64(70 + i), Unit: "kg", Source: source, Timestamp: base.Add(time.Duration(i) * time.Minute)}
+		if err := db.PutHealthMetric(metric); err != nil {
+			t.Fatalf("put metric: %v", err)
+		}
+	}
+
+	filter := models.MetricSourceFilter{Exclude: []string{models.MetricSourceDerived}}
+	start, end := base.Add(-time.Hour), time.Now().UTC()
+
+	queries := fake.Calls("Query")
+	metrics, lastKey, err := service.GetMetricHistoryFromSources("user-1", "weight", start, end, 2, nil, filter)
+	if err != nil {
+		t.Fatalf("get history: %v", err)
+	}
+	if len(metrics) != 2 || metrics[0].Value != 72 || metrics[1].Value != 71 {
+		t.Fatalf("got %+v, want the two newest manual readings", metrics)
+	}
+	if got := fake.Calls("Query") - queries; got < 3 {
+		t.Errorf("read %d pages, want paging past the derived readings", got)
+	}
+	if lastKey == nil {
+		t.Fatal("no cursor with a manual reading left")
+	}
+
+	metrics, lastKey, err = service.GetMetricHistoryFromSources("user-1", "weight", start, end, 2, lastKey, filter)
+	if err != nil {
+		t.Fatalf("get next page: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].Value != 70 {
+		t.Errorf("got %+v, want the remaining manual reading", metrics)
+	}
+	if lastKey != nil {
+		t.Error("cursor returned after the last reading")
+	}
+}
+
+func TestGetHealthTrendsRejectsTooComplexRequests(t *testing.T) {
+	service, _, _ := newTestHealthService(t, func(cfg *config.Config) {
+		cfg.TrendsMaxMetricTypes = 3
+		cfg.TrendsMaxCostDays = 400
+	})
+
+	for _, tc := range []struct {
+		name        string
+		metricTypes []string
+		period      string
+		wantErr     bool
+	}{
+		{"within limits", []string{"heart_rate", "weight", "steps"}, "month", false},
+		{"too many types", []string{"heart_rate", "weight", "steps", "sleep_duration"}, "week", true},
+		{"too many metric-days", []string{"heart_rate", "weight"}, "year", true},
+		{"one type for a year", []string{"heart_rate"}, "year", false},
+	} {
+		_, err := service.GetHealthTrends("user-1", tc.metricTypes, tc.period)
+		if got := errors.Is(err, ErrTrendsTooComplex); got != tc.wantErr {
+			t.Errorf("%s: err = %v, want too complex %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestGetHealthTrendsKeepsRequestedOrder(t *testing.T) {
+	service, _, _ := newTestHealthService(t, func(cfg *config.Config) { cfg.TrendsConcurrency = 2 })
+
+	now := time.Now().UTC()
+	for i := 1; i <= 3; i++ {
+		at := now.Add(-time.Duration(i) * 24 * time.Hour)
+		addMetric(t, service, "heart_rate", float64(70+i), "bpm", at)
+		addMetric(t, service, "weight", float64(80+i), "kg", at)
+		addMetric(t, service, "steps", float64(8000+i), "count", at)
+	}
+
+	trends, err := service.GetHealthTrends("user-1", []string{"steps", "sleep_duration", "weight", "heart_rate"}, "month")
+	if err != nil {
+		t.Fatalf("get trends: %v", err)
+	}
+
+	var got []string
+	for _, trend := range trends {
+		got = append(got, trend.MetricType)
+	}
+	if strings.Join(got, ",") != "steps,weight,heart_rate" {
+		t.Errorf("trends for %v, want steps, weight and heart_rate in request order without the empty metric", got)
+	}
+}
+
+func TestGetHealthTrendsReadsWholePeriod(t *testing.T) {
+	service, _, fake := newTestHealthService(t, func(cfg *config.Config) { cfg.TrendMAWindow = 3 })
+	fake.MaxPageItems = 4
+
+	// Fifteen daily readings, more than one page of history
+	now := time.Now().UTC()
+	for i := 0; i < 15; i++ {
+		addMetric(t, service, "water_intake", float64(i+1), "liters", now.Add(-time.Duration(i)*24*time.Hour))
+	}
+
+	trends, err := service.GetHealthTrends("user-1", []string{"water_intake"}, "month")
+	if err != nil {
+		t.Fatalf("get trends: %v", err)
+	}
+	if len(trends) != 1 {
+		t.Fatalf("got %d trends, want 1", len(trends))
+	}
+	trend := trends[0]
+	if len(trend.DataPoints) != 15 || len(trend.MovingAverage) != 15 {
+		t.Fatalf("%d data points and %d moving average points, want 15 of each", len(trend.DataPoints), len(trend.MovingAverage))
+	}
+	if trend.Average != 8 || trend.Min != 1 || trend.Max != 15 {
+		t.Errorf("average %v min %v max %v, want 8, 1 and 15", trend.Average, trend.Min, trend.Max)
+	}
+
+	// Latest first, averaging the reading with the two before it
+	if first := trend.MovingAverage[0]; first.Value != 2 || !first.Timestamp.Equal(trend.DataPoints[0].Timestamp) {
+		t.Errorf("latest moving average = %+v, want 2 at the latest reading", first)
+	}
+	if last := trend.MovingAverage[14]; last.Value != 15 {
+		t.Errorf("oldest moving average = %v, want 15", last.Value)
+	}
+}
+
+func TestGetHealthTrendsCountsAnomaliesOverWholePeriod(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	// Twelve readings; only the two oldest are outside 60-100 bpm, beyond the latest ten
+	now := time.Now().UTC()
+	for i := 0; i < 12; i++ {
+		value := 72.0
+		if i >= 10 {
+			value = 130
+		}
+		addMetric(t, service, "heart_rate", value, "bpm", now.Add(-time.Duration(i)*12*time.Hour))
+	}
+
+	trends, err := service.GetHealthTrends("user-1", []string{"heart_rate"}, "week")
+	if err != nil {
+		t.Fatalf("get trends: %v", err)
+	}
+	if len(trends) != 1 {
+		t.Fatalf("got %d trends, want 1", len(trends))
+	}
+	if trends[0].AnomalyCount != 2 {
+		t.Errorf("anomaly count = %d, want the 2 oldest readings", trends[0].AnomalyCount)
+	}
+	for i, point := range trends[0].DataPoints {
+		if point.IsAnomaly != (i >= 10) {
+			t.Errorf("point %d (%v) anomaly = %v", i, point.Value, point.IsAnomaly)
+		}
+	}
+}
+
+func TestGetHealthTrendsCapsScannedMetricDays(t *testing.T) {
+	service, _, fake := newTestHealthService(t, func(cfg *config.Config) { cfg.TrendsMaxCostDays = 400 })
+
+	now := time.Now().UTC()
+	addMetric(t, service, "heart_rate", 72, "bpm", now.Add(-time.Hour))
+	addMetric(t, service, "weight", 80, "kg", now.Add(-time.Hour))
+
+	// Two year-long scans exceed 400 metric-days and are refused before any history is read
+	queries := fake.Calls("Query")
+	if _, err := service.GetHealthTrends("user-1", []string{"heart_rate", "weight"}, "year"); !errors.Is(err, ErrTrendsTooComplex) {
+		t.Fatalf("err = %v, want ErrTrendsTooComplex", err)
+	}
+	if got := fake.Calls("Query") - queries; got != 0 {
+		t.Errorf("ran %d queries for a refused request", got)
+	}
+
+	// Types that aren't defined are never scanned, so they don't count towards the cost
+	trends, err := service.GetHealthTrends("user-1", []string{"heart_rate", "peak_flow"}, "year")
+	if err != nil {
+		t.Fatalf("get trends: %v", err)
+	}
+	if len(trends) != 1 || trends[0].MetricType != "heart_rate" {
+		t.Errorf("trends = %+v, want heart_rate only", trends)
+	}
+}
+
+func TestGetHealthTrendsFailsWhenAMetricCannotBeRead(t *testing.T) {
+	service, _, fake := newTestHealthService(t, nil)
+
+	now := time.Now().UTC()
+	addMetric(t, service, "heart_rate", 72, "bpm", now.Add(-time.Hour))
+	addMetric(t, service, "weight", 80, "kg", now.Add(-time.Hour))
+
+	fake.Hook = func(op string, input interface{}) error {
+		if query, ok := input.(*dynamodb.QueryInput); ok {
+			if startKey := query.ExpressionAttributeValues[":startKey"]; startKey != nil && strings.HasPrefix(*startKey.S, "weight#") {
+				return errors.New("throughput exceeded")
+			}
+		}
+		return nil
+	}
+
+	// Leaving weight out would look like it has no readings in the period
+	trends, err := service.GetHealthTrends("user-1", []string{"heart_rate", "weight"}, "month")
+	if err == nil || !strings.Contains(err.Error(), "weight") || !strings.Contains(err.Error(), "throughput exceeded") {
+		t.Fatalf("trends = %+v, err = %v, want the weight read failure", trends, err)
+	}
+}
+
+func TestAnalyzeMetricTrendUsesRegressionSlope(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	// Steadily up 8% over a month, and alternating readings whose newest is a third above the oldest
+	rising := make([]float64, 30)
+	alternating := make([]float64, 30)
+	for i := range rising {
+		rising[i] = 100 + float64(i)*8/29
+		alternating[i] = 60 + float64(20*(i%2))
+	}
+
+	for _, tc := range []struct {
+		name   string
+		values []float64
+		want   string
+	}{
+		{"slow rise", rising, "up"},
+		{"alternating", alternating, "stable"},
+	} {
+		metrics := metricSeries("heart_rate", "bpm", time.Now(), tc.values...)
+		trend := service.analyzeMetricTrend(metrics, "heart_rate", models.SupportedMetrics["heart_rate"], "month", nil)
+		if trend.Trend != tc.want {
+			t.Errorf("%s: trend = %q, want %q", tc.name, trend.Trend, tc.want)
+		}
+		if summary := classifySlope(metrics, service.cfg.TrendSlopeThreshold); summary != trend.Trend {
+			t.Errorf("%s: trend %q differs from the summary's %q", tc.name, trend.Trend, summary)
+		}
+	}
+}
+
+func TestSanityBoundsGuardEveryMetric(t *testing.T) {
+	service, _, _ := newTestHealthService(t, nil)
+
+	for _, tc := range []struct {
+		metricType string
+		value      float64
+		unit       string
+		wantErr    bool
+	}{
+		{"blood_oxygen_saturation", 97, "%", false},
+		{"blood_oxygen_saturation", 120, "%", true},
+		{"body_temperature", 36.8, "°C", false},
+		{"body_temperature", 52, "°C", true},
+		{"cholesterol_total", 190, "mg/dL", false},
+		{"cholesterol_total", 1500, "mg/dL", true},
+	} {
+		input := &models.HealthMetricInput{Type: tc.metricType, Value: tc.value, Unit: tc.unit}
+		if err := service.ValidateHealthData("user-1", input); (err != nil) != tc.wantErr {
+			t.Errorf("%s %v %s: err = %v, want error %v", tc.metricType, tc.value, tc.unit, err, tc.wantErr)
+		}
+	}
+
+	// A metric without sanity bounds only has to be positive
+	if _, err := service.RegisterCustomMetric("user-1", &models.CustomMetricInput{Type: "peak_flow", Name: "Peak flow", Unit: "L/min"}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	for value, wantErr := range map[float64]bool{450: false, 0: true, -20: true} {
+		input := &models.HealthMetricInput{Type: "peak_flow", Value: value, Unit: "L/min"}
+		if err := service.ValidateHealthData("user-1", input); (err != nil) != wantErr {
+			t.Errorf("peak_flow %v: err = %v, want error %v", value, err, wantErr)
+		}
+	}
+}
+
+func TestLatestMetricsCarryRangeStatus(t *testing.T) {
+	service, db, _ := newTestHealthService(t, nil)
+	at := time.Now().UTC().Add(-time.Hour)
+
+	addMetric(t, service, "blood_pressure_systolic", 140, "mmHg", at)
+	addMetric(t, service, "heart_rate", 70, "bpm", at)
+	addMetric(t, service, "blood_oxygen_saturation", 90, "%", at)
+	// Composites have no range of their own
+	if err := db.PutHealthMetric(&models.HealthMetric{UserID: "user-1", Type: "blood_pressure", Value: 140, Unit: "mmHg", Timestamp: at}); err != nil {
+		t.Fatalf("put metric: %v", err)
+	}
+
+	latest, err := service.GetLatestMetrics("user-1")
+	if err != nil {
+		t.Fatalf("get latest: %v", err)
+	}
+	for metricType, want := range map[string]string{
+		"blood_pressure_systolic": models.RangeStatusHigh,
+		"heart_rate":              models.RangeStatusNormal,
+		"blood_oxygen_saturation": models.RangeStatusLow,
+		"blood_pressure":          models.RangeStatusUnknown,
+	} {
+		if got := latest[metricType].Status; got != want {
+			t.Errorf("%s status = %q, want %q", metricType, got, want)
+		}
+	}
+}
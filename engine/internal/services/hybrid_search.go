@@ -0,0 +1,83 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// hybridOverfetch is how many times more vector matches hybrid search retrieves than it returns,
+// so keyword matches that rank low on similarity alone can still make the cut
+const hybridOverfetch = 3
+
+// keywordStopWords are dropped from queries before keyword scoring
+var keywordStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"did": true, "do": true, "does": true, "for": true, "from": true, "has": true, "have": true,
+	"how": true, "i": true, "in": true, "is": true, "it": true, "me": true, "my": true, "of": true,
+	"on": true, "or": true, "show": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "what": true, "when": true, "which": true, "with": true,
+}
+
+// keywordTerms splits text into lower-case alphanumeric terms
+func keywordTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// rerankHybrid replaces each context's score with alpha times its vector score plus (1 - alpha)
+// times its keyword score, then sorts the contexts best first. The keyword score is the share of
+// the query terms found in the chunk, with each term weighted by how few of the candidate chunks
+// contain it, so a rare exact term counts for more than a common one.
+func rerankHybrid(query string, contexts []models.RAGContext, alpha float64) {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range keywordTerms(query) {
+		if !keywordStopWords[term] && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	chunkTerms := make([]map[string]bool, len(contexts))
+	docFreq := make(map[string]int, len(terms))
+	for i, context := range contexts {
+		chunkTerms[i] = make(map[string]bool)
+		for _, term := range keywordTerms(context.Content) {
+			chunkTerms[i][term] = true
+		}
+		for _, term := range terms {
+			if chunkTerms[i][term] {
+				docFreq[term]++
+			}
+		}
+	}
+
+	weights := make(map[string]float64, len(terms))
+	totalWeight := 0.0
+	for _, term := range terms {
+		weights[term] = math.Log(1 + float64(len(contexts)+1)/float64(docFreq[term]+1))
+		totalWeight += weights[term]
+	}
+
+	for i := range contexts {
+		keywordScore := 0.0
+		if totalWeight > 0 {
+			for _, term := range terms {
+				if chunkTerms[i][term] {
+					keywordScore += weights[term]
+				}
+			}
+			keywordScore /= totalWeight
+		}
+		contexts[i].Score = float32(alpha*float64(contexts[i].Score) + (1-alpha)*keywordScore)
+	}
+
+	sort.SliceStable(contexts, func(i, j int) bool {
+		return contexts[i].Score > contexts[j].Score
+	})
+}
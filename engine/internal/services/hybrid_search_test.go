@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// searchCandidates are vector matches for a query naming a specific drug: the closest by
+// similarity talks about statins in general, and only one chunk names the drug
+func searchCandidates() []models.RAGContext {
+	return []models.RAGContext{
+		{ChunkID: "general", Content: "Statins lower LDL cholesterol and are reviewed yearly.", Score: 0.91},
+		{ChunkID: "lifestyle", Content: "Diet and exercise also lower cholesterol.", Score: 0.84},
+		{ChunkID: "exact", Content: "Started rosuvastatin at a dose of 10 mg daily.", Score: 0.72},
+	}
+}
+
+func TestHybridRankingPrefersRareExactTerm(t *testing.T) {
+	contexts := searchCandidates()
+	rerankHybrid("rosuvastatin dose", contexts, 0.5)
+
+	if contexts[0].ChunkID != "exact" {
+		t.Errorf("ranked %s first, want the chunk naming rosuvastatin", contexts[0].ChunkID)
+	}
+	for i := 1; i < len(contexts); i++ {
+		if contexts[i].Score > contexts[i-1].Score {
+			t.Errorf("contexts not sorted by blended score: %+v", contexts)
+		}
+	}
+}
+
+func TestHybridRankingWithFullAlphaKeepsVectorOrder(t *testing.T) {
+	contexts := searchCandidates()
+	rerankHybrid("rosuvastatin dose", contexts, 1)
+
+	for i, want := range []string{"general", "lifestyle", "exact"} {
+		if contexts[i].ChunkID != want {
+			t.Errorf("position %d is %s, want %s", i, contexts[i].ChunkID, want)
+		}
+	}
+	if contexts[0].Score != 0.91 {
+		t.Errorf("score %v, want the vector score unchanged", contexts[0].Score)
+	}
+}
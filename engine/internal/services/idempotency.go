@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a different metric
+var ErrIdempotencyKeyReused = errors.New("idempotency key already used for a different metric")
+
+// ErrInvalidIdempotencyKey is returned when an idempotency key is too long
+var ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+
+// maxIdempotencyKeyLength caps the length of client-supplied idempotency keys
+const maxIdempotencyKeyLength = 255
+
+// idempotencyEntry is the outcome of the first request made with a key. done is closed once the
+// metric has been stored or the attempt failed.
+type idempotencyEntry struct {
+	request   string // Fingerprint of the submitted metric
+	done      chan struct{}
+	metric    *models.HealthMetric
+	err       error
+	expiresAt time.Time
+}
+
+// idempotencyStore remembers recently used idempotency keys per user, so a retried submission
+// returns the metric stored by the first one. Keys are kept in memory, so they only deduplicate
+// retries that reach the same instance.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	ttl     time.Duration
+}
+
+// newIdempotencyStore creates a store and starts its expiry cleanup
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	s := &idempotencyStore{
+		entries: make(map[string]*idempotencyEntry),
+		ttl:     ttl,
+	}
+
+	go func() {
+		ticker := time.NewTicker(ttl)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			s.cleanup(now)
+		}
+	}()
+
+	return s
+}
+
+// do runs create once per user and key within the TTL. Repeats wait for the first request and
+// return its metric with replayed set; a repeat describing a different metric is rejected. Failed
+// attempts are forgotten so the client can retry with the same key.
+func (s *idempotencyStore) do(userID, key string, input *models.HealthMetricInput, create func() (*models.HealthMetric, error)) (metric *models.HealthMetric, replayed bool, err error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, false, fmt.Errorf("%w: must be at most %d characters", ErrInvalidIdempotencyKey, maxIdempotencyKeyLength)
+	}
+
+	entryKey := userID + "\x00" + key
+	request := fmt.Sprintf("%s|%g|%s|%s|%s", input.Type, input.Value, input.Unit, input.Notes, input.Source)
+	if input.Timestamp != nil {
+		request += "|" + input.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, exists := s.entries[entryKey]
+	if exists && now.After(entry.expiresAt) {
+		exists = false
+	}
+	if exists {
+		s.mu.Unlock()
+		if entry.request != request {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		<-entry.done
+		return entry.metric, entry.err == nil, entry.err
+	}
+
+	entry = &idempotencyEntry{
+		request:   request,
+		done:      make(chan struct{}),
+		expiresAt: now.Add(s.ttl),
+	}
+	s.entries[entryKey] = entry
+	s.mu.Unlock()
+
+	entry.metric, entry.err = create()
+	if entry.err != nil {
+		s.mu.Lock()
+		if s.entries[entryKey] == entry {
+			delete(s.entries, entryKey)
+		}
+		s.mu.Unlock()
+	}
+	close(entry.done)
+
+	return entry.metric, false, entry.err
+}
+
+// cleanup drops expired keys whose request has finished
+func (s *idempotencyStore) cleanup(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, entry := range s.entries {
+		select {
+		case <-entry.done:
+			if now.After(entry.expiresAt) {
+				delete(s.entries, key)
+			}
+		default:
+		}
+	}
+}
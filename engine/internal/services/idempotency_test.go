@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/models"
+)
+
+// countingCreate returns a create function that counts its calls and stores nothing
+func countingCreate(calls *int) func() (*models.HealthMetric, error) {
+	return func() (*models.HealthMetric, error) {
+		*calls++
+		return &models.HealthMetric{Type: "heart_rate", Value: float64(70 + *calls)}, nil
+	}
+}
+
+func TestIdempotencyKeyReplaysFirstResult(t *testing.T) {
+	store := newIdempotencyStore(time.Hour)
+	input := &models.HealthMetricInput{Type: "heart_rate", Value: 72, Unit: "bpm"}
+	calls := 0
+
+	first, replayed, err := store.do("user-1", "sync-1", input, countingCreate(&calls))
+	if err != nil || replayed {
+		t.Fatalf("first request: replayed %v err %v", replayed, err)
+	}
+	again, replayed, err := store.do("user-1", "sync-1", input, countingCreate(&calls))
+	if err != nil || !replayed || again != first {
+		t.Errorf("repeat: metric %+v replayed %v err %v, want the first metric replayed", again, replayed, err)
+	}
+	if calls != 1 {
+		t.Errorf("created %d metrics, want 1", calls)
+	}
+
+	// The key is bound to the metric it was first used for, and to the user
+	changed := &models.HealthMetricInput{Type: "heart_rate", Value: 90, Unit: "bpm"}
+	if _, _, err := store.do("user-1", "sync-1", changed, countingCreate(&calls)); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("different metric: err = %v, want ErrIdempotencyKeyReused", err)
+	}
+	if _, replayed, _ := store.do("user-2", "sync-1", input, countingCreate(&calls)); replayed || calls != 2 {
+		t.Errorf("another user: replayed %v after %d creates, want a new metric", replayed, calls)
+	}
+}
+
+func TestExpiredIdempotencyKeyRunsAgain(t *testing.T) {
+	store := newIdempotencyStore(20 * time.Millisecond)
+	input := &models.HealthMetricInput{Type: "heart_rate", Value: 72, Unit: "bpm"}
+	calls := 0
+
+	if _, _, err := store.do("user-1", "sync-1", input, countingCreate(&calls)); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if _, replayed, err := store.do("user-1", "sync-1", input, countingCreate(&calls)); err != nil || replayed || calls != 2 {
+		t.Errorf("after expiry: replayed %v err %v after %d creates, want the metric created again", replayed, err, calls)
+	}
+}
+
+func TestFailedIdempotentRequestCanBeRetried(t *testing.T) {
+	store := newIdempotencyStore(time.Hour)
+	input := &models.HealthMetricInput{Type: "heart_rate", Value: 72, Unit: "bpm"}
+
+	failure := errors.New("throttled")
+	if _, _, err := store.do("user-1", "sync-1", input, func() (*models.HealthMetric, error) { return nil, failure }); !errors.Is(err, failure) {
+		t.Fatalf("err = %v, want the create error", err)
+	}
+
+	calls := 0
+	if _, replayed, err := store.do("user-1", "sync-1", input, countingCreate(&calls)); err != nil || replayed || calls != 1 {
+		t.Errorf("retry: replayed %v err %v after %d creates, want the metric created", replayed, err, calls)
+	}
+}
+
+func TestAddHealthDataStoresIdempotentSubmissionOnce(t *testing.T) {
+	service, _, fake := newTestHealthService(t, func(cfg *config.Config) { cfg.IdempotencyTTLMinutes = 60 })
+	input := &models.HealthMetricInput{Type: "heart_rate", Value: 72, Unit: "bpm", IdempotencyKey: "sync-1"}
+
+	first, _, err := service.AddHealthData("user-1", input)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	again, replayed, err := service.AddHealthData("user-1", input)
+	if err != nil || !replayed || again.SortKey != first.SortKey {
+		t.Errorf("repeat: %+v replayed %v err %v, want the first metric", again, replayed, err)
+	}
+	if got := len(fake.Items(service.cfg.DynamoDBTableHealth)); got != 1 {
+		t.Errorf("stored %d metrics, want 1", got)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/models"
+)
+
+// InsightsCache stores generated health insights in DynamoDB so they survive restarts and the
+// agent is only re-run when the TTL expires or the user's health data materially changes
+type InsightsCache struct {
+	db  *database.DynamoDBClient
+	ttl time.Duration
+}
+
+// NewInsightsCache creates a new insights cache
+func NewInsightsCache(db *database.DynamoDBClient, cfg *config.Config) *InsightsCache {
+	return &InsightsCache{
+		db:  db,
+		ttl: time.Duration(cfg.InsightsCacheTTLHours) * time.Hour,
+	}
+}
+
+// Get returns the user's cached insights if they were generated from the same data and haven't
+// expired, or nil when they need to be regenerated
+func (c *InsightsCache) Get(userID, fingerprint string) (*models.HealthInsights, error) {
+	if c.ttl <= 0 {
+		return nil, nil // Caching disabled
+	}
+
+	insights, err := c.db.GetHealthInsights(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// TTL deletion can lag, so expiry is checked here too
+	if insights == nil || !insights.IsFresh(fingerprint, time.Now()) {
+		return nil, nil
+	}
+
+	insights.Cached = true
+	return insights, nil
+}
+
+// Put stores freshly generated insights, setting their expiry from the configured TTL
+func (c *InsightsCache) Put(insights *models.HealthInsights) error {
+	if c.ttl <= 0 {
+		return nil
+	}
+
+	insights.ExpiresAt = insights.GeneratedAt.Add(c.ttl).Unix()
+	if err := c.db.PutHealthInsights(insights); err != nil {
+		return fmt.Errorf("failed to cache health insights: %w", err)
+	}
+
+	return nil
+}
+
+// insightsFingerprint hashes the parts of a health summary that insights depend on. Values are
+// rounded to display precision and timestamps are left out, so re-recording the same reading
+// doesn't count as a material change but a new value or a trend flip does.
+func insightsFingerprint(summary *models.HealthSummary) string {
+	metricTypes := make([]string, 0, len(summary.Metrics))
+	for metricType := range summary.Metrics {
+		metricTypes = append(metricTypes, metricType)
+	}
+	sort.Strings(metricTypes)
+
+	var b strings.Builder
+	for _, metricType := range metricTypes {
+		metric := summary.Metrics[metricType]
+		value := metric.Value
+		if metricInfo, exists := models.SupportedMetrics[metricType]; exists {
+			value = metricInfo.Round(value)
+		}
+		fmt.Fprintf(&b, "%s=%g%s/%s;", metricType, value, metric.Unit, metric.Trend)
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
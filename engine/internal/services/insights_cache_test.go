@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"health-dashboard-backend/internal/config"
+)
+
+func TestGenerateHealthInsightsUsesCache(t *testing.T) {
+	f := newAgentFixture(t, func(cfg *config.Config) { cfg.InsightsCacheTTLHours = 24 })
+	f.llm.reply = `[{"type":"trend","title":"Steady heart rate","description":"Your resting heart rate is stable.","confidence":"high","action":"continue_monitoring"}]`
+	agent := NewAIAgent(f.health, f.rag, f.llm, NewInsightsCache(f.db, f.cfg), f.cfg, zap.NewNop())
+	f.putMetric(t, "user-1", "heart_rate", 72, "bpm", time.Now().Add(-time.Hour))
+
+	generate := func(forceRefresh bool) (cached bool, llmCalls int) {
+		t.Helper()
+		insights, err := agent.GenerateHealthInsights(context.Background(), "user-1", forceRefresh)
+		if err != nil {
+			t.Fatalf("generate insights: %v", err)
+		}
+		if insights.GeneratedAt.IsZero() || len(insights.Insights) != 1 {
+			t.Fatalf("insights = %+v, want one insight with a generated-at time", insights)
+		}
+		return insights.Cached, len(f.llm.options)
+	}
+
+	if cached, calls := generate(false); cached || calls != 1 {
+		t.Errorf("first load: cached %v after %d LLM calls, want a fresh generation", cached, calls)
+	}
+	if cached, calls := generate(false); !cached || calls != 1 {
+		t.Errorf("second load: cached %v after %d LLM calls, want the cached insights", cached, calls)
+	}
+	if cached, calls := generate(true); cached || calls != 2 {
+		t.Errorf("forced refresh: cached %v after %d LLM calls, want a regeneration", cached, calls)
+	}
+
+	// A new reading changes the fingerprint
+	f.putMetric(t, "user-1", "heart_rate", 95, "bpm", time.Now().Add(-time.Minute))
+	if cached, calls := generate(false); cached || calls != 3 {
+		t.Errorf("after new data: cached %v after %d LLM calls, want a regeneration", cached, calls)
+	}
+
+	// Expired insights are regenerated even if DynamoDB hasn't deleted them yet
+	stored, err := f.db.GetHealthInsights("user-1")
+	if err != nil || stored == nil {
+		t.Fatalf("get cached insights: %v", err)
+	}
+	stored.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	if err := f.db.PutHealthInsights(stored); err != nil {
+		t.Fatalf("put insights: %v", err)
+	}
+	if cached, calls := generate(false); cached || calls != 4 {
+		t.Errorf("after expiry: cached %v after %d LLM calls, want a regeneration", cached, calls)
+	}
+}
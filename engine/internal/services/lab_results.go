@@ -0,0 +1,239 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"health-dashboard-backend/internal/models"
+)
+
+// labAliases maps lab-report labels (lowercase) to supported metric types
+var labAliases = map[string]string{
+	"total cholesterol":          "cholesterol_total",
+	"cholesterol, total":         "cholesterol_total",
+	"cholesterol total":          "cholesterol_total",
+	"cholesterol":                "cholesterol_total",
+	"hdl cholesterol":            "cholesterol_hdl",
+	"cholesterol, hdl":           "cholesterol_hdl",
+	"hdl-c":                      "cholesterol_hdl",
+	"hdl":                        "cholesterol_hdl",
+	"ldl cholesterol":            "cholesterol_ldl",
+	"cholesterol, ldl":           "cholesterol_ldl",
+	"ldl-c":                      "cholesterol_ldl",
+	"ldl":                        "cholesterol_ldl",
+	"fasting glucose":            "blood_glucose_fasting",
+	"glucose, fasting":           "blood_glucose_fasting",
+	"fasting blood sugar":        "blood_glucose_fasting",
+	"fasting plasma glucose":     "blood_glucose_fasting",
+	"postprandial glucose":       "blood_glucose_postprandial",
+	"post prandial glucose":      "blood_glucose_postprandial",
+	"glucose, postprandial":      "blood_glucose_postprandial",
+	"glucose":                    "blood_glucose",
+	"blood sugar":                "blood_glucose",
+	"heart rate":                 "heart_rate",
+	"pulse":                      "heart_rate",
+	"spo2":                       "blood_oxygen_saturation",
+	"oxygen saturation":          "blood_oxygen_saturation",
+	"body temperature":           "body_temperature",
+	"temperature":                "body_temperature",
+	"body mass index":            "bmi",
+	"bmi":                        "bmi",
+	"weight":                     "weight",
+	"height":                     "height",
+	"blood glucose":              "blood_glucose",
+	"blood glucose fasting":      "blood_glucose_fasting",
+	"blood glucose postprandial": "blood_glucose_postprandial",
+}
+
+// labAliasOrder lists aliases longest first so "hdl cholesterol" wins over "cholesterol"
+var labAliasOrder = func() []string {
+	aliases := make([]string, 0, len(labAliases))
+	for alias := range labAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		if len(aliases[i]) != len(aliases[j]) {
+			return len(aliases[i]) > len(aliases[j])
+		}
+		return aliases[i] < aliases[j]
+	})
+	return aliases
+}()
+
+var (
+	// labValuePattern captures the first number after the label and an optional unit right after it
+	labValuePattern = regexp.MustCompile(`^[\s:=\-]*(-?\d+(?:\.\d+)?)\s*([a-zA-Z%°²/]+(?:/[a-zA-Z]+)?)?`)
+	// labRangePattern captures "70 - 100", "70–100" or "70 to 100"
+	labRangePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(?:-|–|to)\s*(\d+(?:\.\d+)?)`)
+	// labUpperBoundPattern captures "< 200" or "<=200"
+	labUpperBoundPattern = regexp.MustCompile(`<\s*=?\s*(\d+(?:\.\d+)?)`)
+	// labLowerBoundPattern captures "> 40" or ">=40"
+	labLowerBoundPattern = regexp.MustCompile(`>\s*=?\s*(\d+(?:\.\d+)?)`)
+	// labFlagPattern captures a trailing H/L or High/Low flag
+	labFlagPattern = regexp.MustCompile(`(?i)\b(h|l|high|low)\b\s*$`)
+)
+
+// ExtractLabResults scans document text line by line for supported metrics printed with a value
+// and, where present, the lab's reference range and high/low flag. Only the first occurrence of
+// each metric type is kept.
+func ExtractLabResults(text string) []models.LabResult {
+	var results []models.LabResult
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lower := strings.ToLower(line)
+
+		for _, alias := range labAliasOrder {
+			if !strings.HasPrefix(lower, alias) {
+				continue
+			}
+			// Require a word boundary so "hdl" doesn't match "hdlx"
+			rest := line[len(alias):]
+			if rest != "" && isLabelChar(rest[0]) {
+				continue
+			}
+
+			metricType := labAliases[alias]
+			if seen[metricType] {
+				break
+			}
+
+			result, ok := parseLabResult(line[:len(alias)], metricType, rest)
+			if ok {
+				results = append(results, result)
+				seen[metricType] = true
+			}
+			break
+		}
+	}
+
+	return results
+}
+
+// parseLabResult parses the value, unit, reference range and flag that follow a label
+func parseLabResult(name, metricType, rest string) (models.LabResult, bool) {
+	match := labValuePattern.FindStringSubmatchIndex(rest)
+	if match == nil {
+		return models.LabResult{}, false
+	}
+
+	value, err := strconv.ParseFloat(rest[match[2]:match[3]], 64)
+	if err != nil {
+		return models.LabResult{}, false
+	}
+
+	result := models.LabResult{
+		MetricType: metricType,
+		Name:       strings.TrimSpace(name),
+		Value:      value,
+	}
+
+	// Everything after the value is where labs print the reference range and flag
+	tail := rest[match[1]:]
+
+	if match[4] >= 0 {
+		unit := rest[match[4]:match[5]]
+		if isLabFlag(unit) {
+			// A bare "H"/"L" right after the value is a flag, not a unit
+			result.Flag = labFlagStatus(unit)
+			tail = rest[match[5]:]
+		} else {
+			result.Unit = unit
+		}
+	}
+
+	if m := labRangePattern.FindStringSubmatch(tail); m != nil {
+		low, _ := strconv.ParseFloat(m[1], 64)
+		high, _ := strconv.ParseFloat(m[2], 64)
+		result.ReferenceRange = &models.Range{Min: low, Max: high}
+	} else if m := labUpperBoundPattern.FindStringSubmatch(tail); m != nil {
+		high, _ := strconv.ParseFloat(m[1], 64)
+		result.ReferenceRange = &models.Range{Min: 0, Max: high}
+	} else if m := labLowerBoundPattern.FindStringSubmatch(tail); m != nil {
+		low, _ := strconv.ParseFloat(m[1], 64)
+		result.ReferenceRange = &models.Range{Min: low, Max: 999} // Open-ended, matching how HDL's default range is expressed
+	}
+
+	if m := labFlagPattern.FindStringSubmatch(tail); m != nil {
+		result.Flag = labFlagStatus(m[1])
+	}
+
+	return result, true
+}
+
+// isLabFlag reports whether s is exactly a high/low flag token
+func isLabFlag(s string) bool {
+	return labFlagStatus(s) != ""
+}
+
+// labFlagStatus maps a flag token to a range status, or "" if it isn't a flag
+func labFlagStatus(flag string) string {
+	switch strings.ToLower(flag) {
+	case "h", "high":
+		return models.RangeStatusHigh
+	case "l", "low":
+		return models.RangeStatusLow
+	}
+	return ""
+}
+
+// isLabelChar reports whether c can continue a label word
+func isLabelChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
+}
+
+// reconcileLabResult compares a lab result's interpretation with the default range and the latest tracked value
+func reconcileLabResult(result models.LabResult, tracked *models.HealthMetric) models.RangeReconciliation {
+	metricInfo := models.SupportedMetrics[result.MetricType]
+
+	reconciliation := models.RangeReconciliation{
+		MetricType:    result.MetricType,
+		Name:          result.Name,
+		Value:         result.Value,
+		Unit:          result.Unit,
+		DocumentRange: result.ReferenceRange,
+		DefaultRange:  metricInfo.NormalRange,
+	}
+
+	// The lab's explicit flag takes precedence over our reading of its range
+	reconciliation.DocumentStatus = result.ReferenceRange.Status(result.Value)
+	if result.Flag != "" {
+		reconciliation.DocumentStatus = result.Flag
+	}
+
+	unitMatches := result.Unit == "" || strings.EqualFold(result.Unit, metricInfo.Unit)
+	if !unitMatches {
+		// Different units (e.g. mmol/L vs mg/dL) can't be compared against the default range
+		reconciliation.DefaultStatus = models.RangeStatusUnknown
+		reconciliation.Note = "Document unit " + result.Unit + " differs from tracked unit " + metricInfo.Unit + "; default range not compared"
+	} else {
+		reconciliation.DefaultStatus = metricInfo.NormalRange.Status(result.Value)
+	}
+
+	reconciliation.Discrepancy = reconciliation.DocumentStatus != models.RangeStatusUnknown &&
+		reconciliation.DefaultStatus != models.RangeStatusUnknown &&
+		reconciliation.DocumentStatus != reconciliation.DefaultStatus
+
+	if reconciliation.Discrepancy {
+		reconciliation.Note = "The lab reports this value as " + reconciliation.DocumentStatus +
+			" but the default range considers it " + reconciliation.DefaultStatus
+	}
+
+	if tracked != nil {
+		value := tracked.Value
+		timestamp := tracked.Timestamp
+		reconciliation.TrackedValue = &value
+		reconciliation.TrackedAt = &timestamp
+		if unitMatches && result.ReferenceRange != nil {
+			reconciliation.TrackedStatus = result.ReferenceRange.Status(tracked.Value)
+		}
+	}
+
+	return reconciliation
+}
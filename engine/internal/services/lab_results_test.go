@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/models"
+)
+
+const labReport = `Lipid Panel
+HDL Cholesterol 38 mg/dL >40 L
+LDL Cholesterol 95 mg/dL <130
+Fasting Glucose 105 mg/dL 70 - 110
+Comment: fasting glucose repeated 102
+`
+
+func TestExtractLabResults(t *testing.T) {
+	results := ExtractLabResults(labReport)
+
+	want := map[string]models.LabResult{
+		"cholesterol_hdl":       {Value: 38, Unit: "mg/dL", ReferenceRange: &models.Range{Min: 40, Max: 999}, Flag: models.RangeStatusLow},
+		"cholesterol_ldl":       {Value: 95, Unit: "mg/dL", ReferenceRange: &models.Range{Min: 0, Max: 130}},
+		"blood_glucose_fasting": {Value: 105, Unit: "mg/dL", ReferenceRange: &models.Range{Min: 70, Max: 110}},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results %+v, want %d (first occurrence of each metric)", len(results), results, len(want))
+	}
+	for _, result := range results {
+		expected, ok := want[result.MetricType]
+		if !ok {
+			t.Errorf("unexpected result %+v", result)
+			continue
+		}
+		if result.Value != expected.Value || result.Unit != expected.Unit || result.Flag != expected.Flag ||
+			result.ReferenceRange == nil || *result.ReferenceRange != *expected.ReferenceRange {
+			t.Errorf("%s = %+v (range %v), want %+v (range %v)", result.MetricType, result, result.ReferenceRange, expected, expected.ReferenceRange)
+		}
+	}
+}
+
+func TestReconcileReferenceRangesFlagsDiscrepancies(t *testing.T) {
+	service, db, _ := newTestDocumentService(t, nil)
+
+	document := putDocument(t, db, "user-1", "doc-1")
+	document.LabResults = ExtractLabResults(labReport)
+	if err := db.UpdateDocument(document); err != nil {
+		t.Fatalf("update document: %v", err)
+	}
+	tracked := &models.HealthMetric{UserID: "user-1", Type: "blood_glucose_fasting", Value: 98, Unit: "mg/dL", Timestamp: time.Now().Add(-time.Hour)}
+	if err := db.PutHealthMetric(tracked); err != nil {
+		t.Fatalf("put metric: %v", err)
+	}
+
+	report, err := service.ReconcileReferenceRanges("user-1", "doc-1")
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	byType := make(map[string]models.RangeReconciliation)
+	for _, result := range report.Results {
+		byType[result.MetricType] = result
+	}
+
+	// The lab's wider glucose range calls 105 normal; the default 70-100 calls it high
+	glucose := byType["blood_glucose_fasting"]
+	if !glucose.Discrepancy || glucose.DocumentStatus != models.RangeStatusNormal || glucose.DefaultStatus != models.RangeStatusHigh {
+		t.Errorf("glucose = %+v, want a normal-vs-high discrepancy", glucose)
+	}
+	if glucose.TrackedValue == nil || *glucose.TrackedValue != 98 || glucose.TrackedStatus != models.RangeStatusNormal {
+		t.Errorf("glucose tracked = %v %q, want 98 judged normal by the lab's range", glucose.TrackedValue, glucose.TrackedStatus)
+	}
+
+	// Both agree HDL is low and LDL is normal
+	if byType["cholesterol_hdl"].Discrepancy || byType["cholesterol_ldl"].Discrepancy {
+		t.Errorf("cholesterol results = %+v, want no discrepancies", byType)
+	}
+	if report.DiscrepancyCount != 1 {
+		t.Errorf("discrepancy count = %d, want 1", report.DiscrepancyCount)
+	}
+
+	if _, err := service.ReconcileReferenceRanges("user-2", "doc-1"); err == nil {
+		t.Error("another user reconciled the document")
+	}
+}
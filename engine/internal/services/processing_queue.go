@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultProcessingEstimate is the assumed per-document processing time before any job has completed
+const defaultProcessingEstimate = 30 * time.Second
+
+// processingJob is a document waiting to be processed
+type processingJob struct {
+	userID     string
+	documentID string
+}
+
+// ProcessingQueue runs document processing on a fixed pool of workers. Each user may have at most
+// perUserLimit jobs running at once, and users with pending jobs are served round-robin so one
+// large upload batch can't starve everyone else.
+type ProcessingQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	workers      int
+	perUserLimit int
+	process      func(userID, documentID string) error
+	logger       *zap.Logger // Guarded by mu
+
+	pending   map[string][]processingJob // userID -> jobs in arrival order
+	userOrder []string                   // Round-robin ring of users with pending jobs
+	cursor    int                        // Index in userOrder to try first
+	running   map[string]int             // userID -> jobs currently running
+	queued    map[string]bool            // documentID -> pending, to avoid double-enqueueing
+
+	avgDuration time.Duration // Moving average of completed job durations
+
+	closed   bool           // Set by Shutdown; workers stop taking new jobs
+	workerWG sync.WaitGroup // Tracks worker goroutines so Shutdown can wait for in-flight jobs
+}
+
+// ProcessingQueueStats is a point-in-time view of the queue for monitoring
+type ProcessingQueueStats struct {
+	Workers      int  `json:"workers"`
+	Pending      int  `json:"pending"`       // Jobs waiting for a worker
+	Running      int  `json:"running"`       // Jobs currently being processed
+	PendingUsers int  `json:"pending_users"` // Users with at least one job waiting
+	ShuttingDown bool `json:"shutting_down"`
+}
+
+// NewProcessingQueue creates a queue and starts its workers
+func NewProcessingQueue(workers, perUserLimit int, process func(userID, documentID string) error) *ProcessingQueue {
+	q := &ProcessingQueue{
+		workers:      workers,
+		perUserLimit: perUserLimit,
+		process:      process,
+		logger:       zap.NewNop(),
+		pending:      make(map[string][]processingJob),
+		running:      make(map[string]int),
+		queued:       make(map[string]bool),
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	q.workerWG.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+
+	return q
+}
+
+// SetLogger sets the logger for failed jobs; defaults to a no-op logger
+func (q *ProcessingQueue) SetLogger(logger *zap.Logger) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.logger = logger
+}
+
+// Enqueue adds a document to the user's queue; it is a no-op if the document is already pending
+// or the queue is shutting down, in which case the document stays uploaded and can be processed later
+func (q *ProcessingQueue) Enqueue(userID, documentID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed || q.queued[documentID] {
+		return
+	}
+
+	if len(q.pending[userID]) == 0 {
+		q.userOrder = append(q.userOrder, userID)
+	}
+	q.pending[userID] = append(q.pending[userID], processingJob{userID: userID, documentID: documentID})
+	q.queued[documentID] = true
+
+	q.cond.Signal()
+}
+
+// Position returns the document's estimated 1-based position in the queue and the estimated wait
+// before it starts. ok is false if the document isn't waiting in the queue.
+func (q *ProcessingQueue) Position(userID, documentID string) (position int, wait time.Duration, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	index := -1
+	for i, job := range q.pending[userID] {
+		if job.documentID == documentID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return 0, 0, false
+	}
+
+	// Under round-robin, each user ahead of this one in the ring gets index+1 turns before this
+	// job starts and each user behind it gets index turns, capped by how many jobs they have
+	userPos := q.ringPosition(userID)
+	ahead := index
+	for i, other := range q.userOrder {
+		if other == userID {
+			continue
+		}
+		turns := index
+		if (i-q.cursor+len(q.userOrder))%len(q.userOrder) < userPos {
+			turns++
+		}
+		ahead += min(turns, len(q.pending[other]))
+	}
+
+	estimate := q.avgDuration
+	if estimate == 0 {
+		estimate = defaultProcessingEstimate
+	}
+	rounds := ahead/q.workers + 1
+
+	return ahead + 1, time.Duration(rounds) * estimate, true
+}
+
+// ringPosition returns how many turns away a user is from the cursor
+func (q *ProcessingQueue) ringPosition(userID string) int {
+	for i, other := range q.userOrder {
+		if other == userID {
+			return (i - q.cursor + len(q.userOrder)) % len(q.userOrder)
+		}
+	}
+	return 0
+}
+
+// Stats returns the current queue depth and worker usage
+func (q *ProcessingQueue) Stats() ProcessingQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := ProcessingQueueStats{
+		Workers:      q.workers,
+		PendingUsers: len(q.userOrder),
+		ShuttingDown: q.closed,
+	}
+	for _, jobs := range q.pending {
+		stats.Pending += len(jobs)
+	}
+	for _, running := range q.running {
+		stats.Running += running
+	}
+
+	return stats
+}
+
+// Shutdown stops workers from taking new jobs and waits for in-flight jobs to finish or ctx to
+// expire. Jobs still pending are dropped; their documents stay uploaded and can be reprocessed.
+func (q *ProcessingQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+
+	done := make(chan struct{})
+	go func() {
+		q.workerWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("processing queue did not drain: %w", ctx.Err())
+	}
+}
+
+// worker takes jobs fairly across users and runs them until the queue is shut down
+func (q *ProcessingQueue) worker() {
+	defer q.workerWG.Done()
+
+	for {
+		q.mu.Lock()
+		var job processingJob
+		ok := false
+		for !q.closed {
+			if job, ok = q.nextJob(); ok {
+				break
+			}
+			q.cond.Wait()
+		}
+		if !ok {
+			q.mu.Unlock()
+			return
+		}
+		q.running[job.userID]++
+		logger := q.logger
+		q.mu.Unlock()
+
+		start := time.Now()
+		if err := q.process(job.userID, job.documentID); err != nil {
+			// The document is marked as failed and can be retried
+			logger.Warn("Failed to auto-process document",
+				zap.String("user_id", job.userID),
+				zap.String("document_id", job.documentID),
+				zap.Error(err))
+		}
+		elapsed := time.Since(start)
+
+		q.mu.Lock()
+		q.running[job.userID]--
+		if q.running[job.userID] == 0 {
+			delete(q.running, job.userID)
+		}
+		if q.avgDuration == 0 {
+			q.avgDuration = elapsed
+		} else {
+			q.avgDuration = (q.avgDuration*4 + elapsed) / 5
+		}
+		q.mu.Unlock()
+
+		// A finished job may unblock a user that was at their concurrency limit
+		q.cond.Broadcast()
+	}
+}
+
+// nextJob pops the next job in round-robin order, skipping users at their concurrency limit.
+// The caller must hold q.mu.
+func (q *ProcessingQueue) nextJob() (processingJob, bool) {
+	for offset := 0; offset < len(q.userOrder); offset++ {
+		i := (q.cursor + offset) % len(q.userOrder)
+		userID := q.userOrder[i]
+		if q.running[userID] >= q.perUserLimit {
+			continue
+		}
+
+		jobs := q.pending[userID]
+		job := jobs[0]
+		delete(q.queued, job.documentID)
+
+		if len(jobs) == 1 {
+			delete(q.pending, userID)
+			q.userOrder = append(q.userOrder[:i], q.userOrder[i+1:]...)
+			// The next user slid into index i, so it is next in line
+			q.cursor = i
+		} else {
+			q.pending[userID] = jobs[1:]
+			q.cursor = i + 1
+		}
+		if len(q.userOrder) > 0 {
+			q.cursor %= len(q.userOrder)
+		} else {
+			q.cursor = 0
+		}
+
+		return job, true
+	}
+
+	return processingJob{}, false
+}
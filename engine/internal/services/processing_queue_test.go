@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingProcessor records jobs as they start and holds each one until released
+type blockingProcessor struct {
+	started chan string
+	release chan struct{}
+
+	mu         sync.Mutex
+	running    map[string]int
+	maxRunning map[string]int
+	total      int // Jobs running across all users
+	maxTotal   int
+}
+
+func newBlockingProcessor() *blockingProcessor {
+	return &blockingProcessor{
+		started:    make(chan string, 100),
+		release:    make(chan struct{}),
+		running:    make(map[string]int),
+		maxRunning: make(map[string]int),
+	}
+}
+
+func (p *blockingProcessor) process(userID, documentID string) error {
+	p.mu.Lock()
+	p.running[userID]++
+	p.maxRunning[userID] = max(p.maxRunning[userID], p.running[userID])
+	p.total++
+	p.maxTotal = max(p.maxTotal, p.total)
+	p.mu.Unlock()
+
+	p.started <- documentID
+	<-p.release
+
+	p.mu.Lock()
+	p.running[userID]--
+	p.total--
+	p.mu.Unlock()
+	return nil
+}
+
+// next waits for the next job to start
+func (p *blockingProcessor) next(t *testing.T) string {
+	t.Helper()
+
+	select {
+	case documentID := <-p.started:
+		return documentID
+	case <-time.After(5 * time.Second):
+		t.Fatal("no job started")
+		return ""
+	}
+}
+
+func TestProcessingQueueServesUsersRoundRobin(t *testing.T) {
+	p := newBlockingProcessor()
+	queue := NewProcessingQueue(1, 1, p.process)
+	defer func() {
+		close(p.release)
+		queue.Shutdown(context.Background())
+	}()
+
+	queue.Enqueue("a", "a1")
+	order := []string{p.next(t)}
+
+	// a's second upload arrives first, but b and c each get a turn before a's third
+	for _, job := range [][2]string{{"a", "a2"}, {"a", "a3"}, {"b", "b1"}, {"c", "c1"}} {
+		queue.Enqueue(job[0], job[1])
+	}
+	queue.Enqueue("a", "a2") // Already pending; ignored
+
+	for document, want := range map[string]int{"a2": 1, "b1": 2, "c1": 3, "a3": 4} {
+		user := document[:1]
+		if position, wait, ok := queue.Position(user, document); !ok || position != want || wait <= 0 {
+			t.Errorf("position of %s = %d (wait %v, ok %v), want %d", document, position, wait, ok, want)
+		}
+	}
+	if stats := queue.Stats(); stats.Pending != 4 || stats.Running != 1 || stats.PendingUsers != 3 {
+		t.Errorf("stats = %+v, want 4 pending for 3 users and 1 running", stats)
+	}
+
+	for len(order) < 5 {
+		p.release <- struct{}{}
+		order = append(order, p.next(t))
+	}
+	if got := strings.Join(order, ","); got != "a1,a2,b1,c1,a3" {
+		t.Errorf("processing order = %s, want a1,a2,b1,c1,a3", got)
+	}
+}
+
+func TestProcessingQueueCapsJobsPerUser(t *testing.T) {
+	p := newBlockingProcessor()
+	queue := NewProcessingQueue(3, 1, p.process)
+	defer queue.Shutdown(context.Background())
+
+	for _, document := range []string{"a1", "a2", "a3"} {
+		queue.Enqueue("a", document)
+	}
+	queue.Enqueue("b", "b1")
+
+	// Three workers are free, but a may only use one of them
+	first := map[string]bool{p.next(t): true, p.next(t): true}
+	if !first["a1"] || !first["b1"] {
+		t.Errorf("first jobs = %v, want a1 and b1", first)
+	}
+	select {
+	case documentID := <-p.started:
+		t.Fatalf("%s started while a was at its limit", documentID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Let everything run to completion
+	close(p.release)
+	p.next(t)
+	p.next(t)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.maxRunning["a"] != 1 {
+		t.Errorf("a ran %d jobs at once, want at most 1", p.maxRunning["a"])
+	}
+}
+
+func TestProcessingQueueCapsWorkers(t *testing.T) {
+	p := newBlockingProcessor()
+	queue := NewProcessingQueue(2, 1, p.process)
+	defer queue.Shutdown(context.Background())
+
+	users := []string{"a", "b", "c", "d", "e"}
+	for _, user := range users {
+		queue.Enqueue(user, user+"1")
+	}
+
+	p.next(t)
+	p.next(t)
+	select {
+	case documentID := <-p.started:
+		t.Fatalf("%s started with both workers busy", documentID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if stats := queue.Stats(); stats.Running != 2 || stats.Pending != 3 {
+		t.Errorf("stats = %+v, want 2 running and 3 pending", stats)
+	}
+
+	close(p.release)
+	for range users[2:] {
+		p.next(t)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.maxTotal != 2 {
+		t.Errorf("ran %d jobs at once, want at most the 2 workers", p.maxTotal)
+	}
+}
+
+func TestProcessingQueueShutdownDrainsInFlightJobs(t *testing.T) {
+	p := newBlockingProcessor()
+	queue := NewProcessingQueue(1, 1, p.process)
+
+	queue.Enqueue("a", "a1")
+	queue.Enqueue("a", "a2")
+	p.next(t)
+
+	// The running job holds its worker, so a short deadline expires
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := queue.Shutdown(ctx); err == nil {
+		t.Error("shutdown returned before the in-flight job finished")
+	}
+	if stats := queue.Stats(); !stats.ShuttingDown {
+		t.Errorf("stats = %+v, want shutting down", stats)
+	}
+
+	queue.Enqueue("b", "b1") // Ignored once shutting down
+	close(p.release)
+	if err := queue.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	select {
+	case documentID := <-p.started:
+		t.Errorf("%s started after shutdown", documentID)
+	default:
+	}
+}
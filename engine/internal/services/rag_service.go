@@ -2,7 +2,15 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
 
 	"health-dashboard-backend/internal/config"
 	"health-dashboard-backend/internal/models"
@@ -10,76 +18,289 @@ import (
 	"health-dashboard-backend/pkg/ai"
 )
 
+// errEmbeddingDimensionMismatch is returned when an embedding still has the wrong dimension after
+// any configured regeneration attempts
+var errEmbeddingDimensionMismatch = errors.New("embedding dimension mismatch")
+
+// VectorStore is the vector index the RAG service reads and writes, implemented by
+// *vectordb.PineconeClient
+type VectorStore interface {
+	Namespace(userID string) string
+	UpsertVectors(ctx context.Context, namespace string, vectors []vectordb.Vector) error
+	QueryVectors(ctx context.Context, namespace string, queryVector []float32, topK int, filter vectordb.VectorMetadata) (*vectordb.QueryResponse, error)
+	DeleteVectorsByFilter(ctx context.Context, namespace string, filter vectordb.VectorMetadata) error
+	IndexDimension(ctx context.Context) (int, error)
+	GetIndexStats(ctx context.Context) (interface{}, error)
+}
+
 // RAGService handles retrieval-augmented generation operations
 type RAGService struct {
-	vectorDB        *vectordb.PineconeClient
+	vectorDB        VectorStore
 	llmClient       ai.LLMClient
 	embeddingClient ai.EmbeddingClient
 	cfg             *config.Config
+
+	snapshotMu    sync.Mutex
+	lastSnapshots map[string]time.Time // userID -> last health snapshot time
+
+	chunkFetcher ChunkContentFetcher
+	titleLookup  DocumentLookup
+	logger       *zap.Logger
+}
+
+// ChunkContentFetcher retrieves the original text of a document chunk.
+// It is used when a vector's metadata doesn't carry the chunk content.
+type ChunkContentFetcher interface {
+	GetChunkContent(userID, documentID string, chunkIndex int) (string, error)
+}
+
+// DocumentLookup retrieves a document record, used to resolve the titles of matched documents
+type DocumentLookup interface {
+	GetDocument(userID, documentID string) (*models.Document, error)
 }
 
 // NewRAGService creates a new RAG service
-func NewRAGService(vectorDB *vectordb.PineconeClient, llmClient ai.LLMClient, embeddingClient ai.EmbeddingClient, cfg *config.Config) *RAGService {
+func NewRAGService(vectorDB VectorStore, llmClient ai.LLMClient, embeddingClient ai.EmbeddingClient, cfg *config.Config) *RAGService {
 	return &RAGService{
 		vectorDB:        vectorDB,
 		llmClient:       llmClient,
 		embeddingClient: embeddingClient,
 		cfg:             cfg,
+		lastSnapshots:   make(map[string]time.Time),
+		logger:          zap.NewNop(),
 	}
 }
 
+// SetLogger sets the logger for skipped chunks and other failures that don't fail the request;
+// defaults to a no-op logger
+func (r *RAGService) SetLogger(logger *zap.Logger) {
+	r.logger = logger
+}
+
+// SetChunkContentFetcher sets the fallback source for chunk text missing from vector metadata
+func (r *RAGService) SetChunkContentFetcher(fetcher ChunkContentFetcher) {
+	r.chunkFetcher = fetcher
+}
+
+// SetDocumentLookup sets the source of document titles for query results
+func (r *RAGService) SetDocumentLookup(lookup DocumentLookup) {
+	r.titleLookup = lookup
+}
+
 // ProcessDocumentChunks processes document chunks and stores them in vector database
-func (r *RAGService) ProcessDocumentChunks(userID, documentID string, chunks []models.DocumentChunk) error {
-	ctx := context.Background()
+func (r *RAGService) ProcessDocumentChunks(ctx context.Context, userID, documentID string, chunks []models.DocumentChunk) error {
+	expectedDim, err := r.expectedDimension(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Generate embeddings in batches to keep API round-trips down
+	embeddings, err := r.generateBatchEmbeddings(ctx, chunks)
+	if err != nil {
+		return err
+	}
 
-	// Generate embeddings for each chunk
 	var vectors []vectordb.Vector
-	for _, chunk := range chunks {
-		// Generate embedding
-		embedding, err := r.embeddingClient.GenerateEmbedding(ctx, chunk.Content)
-		if err != nil {
-			return fmt.Errorf("failed to generate embedding for chunk %s: %w", chunk.ChunkID, err)
+	skipped := 0
+	for i, chunk := range chunks {
+		embedding := embeddings[i]
+		if expectedDim > 0 && len(embedding) != expectedDim {
+			r.logger.Warn("Embedding has unexpected dimension",
+				zap.String("chunk_id", chunk.ChunkID),
+				zap.Int("dimension", len(embedding)),
+				zap.Int("expected_dimension", expectedDim))
+			embedding, err = r.regenerateEmbedding(ctx, chunk.ChunkID, chunk.Content, len(embedding), expectedDim)
+			if errors.Is(err, errEmbeddingDimensionMismatch) {
+				// A single bad embedding shouldn't fail the whole document
+				r.logger.Warn("Skipping chunk with mismatched embedding",
+					zap.String("chunk_id", chunk.ChunkID),
+					zap.String("document_id", documentID),
+					zap.Error(err))
+				skipped++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to generate embedding for chunk %s: %w", chunk.ChunkID, err)
+			}
 		}
 
 		// Create vector
 		chunk.Embedding = embedding
 		vector := vectordb.CreateVectorFromChunk(&chunk)
+
+		// Keep metadata under Pinecone's per-vector size limit
+		if maxBytes := r.cfg.PineconeMaxContentBytes; maxBytes > 0 && len(chunk.Content) > maxBytes {
+			vector.Metadata["content"] = truncateUTF8(chunk.Content, maxBytes)
+			vector.Metadata["content_truncated"] = true
+		}
+
 		vectors = append(vectors, *vector)
 	}
 
+	if len(vectors) == 0 && skipped > 0 {
+		return fmt.Errorf("all %d chunks produced embeddings with the wrong dimension: %w", skipped, errEmbeddingDimensionMismatch)
+	}
+
 	// Store vectors in Pinecone
-	if err := r.vectorDB.UpsertVectors(ctx, vectors); err != nil {
+	if err := r.vectorDB.UpsertVectors(ctx, r.vectorDB.Namespace(userID), vectors); err != nil {
 		return fmt.Errorf("failed to store vectors in database: %w", err)
 	}
 
 	return nil
 }
 
-// QueryRelevantContext queries for relevant document context
+// expectedDimension returns the configured embedding dimension, falling back to the index's
+func (r *RAGService) expectedDimension(ctx context.Context) (int, error) {
+	if r.cfg.EmbeddingDimension > 0 {
+		return r.cfg.EmbeddingDimension, nil
+	}
+
+	dimension, err := r.vectorDB.IndexDimension(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine index dimension: %w", err)
+	}
+	return dimension, nil
+}
+
+// generateBatchEmbeddings embeds the chunks EmbeddingBatchSize at a time, returning embeddings in
+// chunk order. Dimensions are not checked here.
+func (r *RAGService) generateBatchEmbeddings(ctx context.Context, chunks []models.DocumentChunk) ([][]float32, error) {
+	batchSize := r.cfg.EmbeddingBatchSize
+
+	embeddings := make([][]float32, 0, len(chunks))
+	for start := 0; start < len(chunks); start += batchSize {
+		end := min(start+batchSize, len(chunks))
+
+		texts := make([]string, 0, end-start)
+		for _, chunk := range chunks[start:end] {
+			texts = append(texts, chunk.Content)
+		}
+
+		batch, err := r.embeddingClient.GenerateEmbeddings(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate embeddings for chunks %s to %s: %w",
+				chunks[start].ChunkID, chunks[end-1].ChunkID, err)
+		}
+		if len(batch) != len(texts) {
+			return nil, fmt.Errorf("expected %d embeddings for chunks %s to %s, got %d",
+				len(texts), chunks[start].ChunkID, chunks[end-1].ChunkID, len(batch))
+		}
+
+		embeddings = append(embeddings, batch...)
+	}
+
+	return embeddings, nil
+}
+
+// regenerateEmbedding retries a batch embedding that came back with the wrong dimension, one
+// text at a time. The batch request counts as the first attempt, so with EmbeddingMismatchAction
+// "skip" it gives up straight away.
+func (r *RAGService) regenerateEmbedding(ctx context.Context, id, text string, dimension, expectedDim int) ([]float32, error) {
+	regenerations := 0
+	if r.cfg.EmbeddingMismatchAction == "regenerate" {
+		regenerations = max(r.cfg.EmbeddingMaxRegenerations, 0)
+	}
+	if regenerations == 0 {
+		return nil, fmt.Errorf("%w: %s has dimension %d, expected %d", errEmbeddingDimensionMismatch, id, dimension, expectedDim)
+	}
+
+	return r.generateEmbeddingAttempts(ctx, id, text, expectedDim, regenerations)
+}
+
+// generateCheckedEmbedding generates an embedding and verifies it has the expected dimension.
+// Depending on EmbeddingMismatchAction a mismatched embedding is regenerated a few times before
+// giving up with errEmbeddingDimensionMismatch. An expected dimension of 0 skips the check.
+func (r *RAGService) generateCheckedEmbedding(ctx context.Context, id, text string, expectedDim int) ([]float32, error) {
+	attempts := 1
+	if r.cfg.EmbeddingMismatchAction == "regenerate" {
+		attempts += max(r.cfg.EmbeddingMaxRegenerations, 0)
+	}
+
+	return r.generateEmbeddingAttempts(ctx, id, text, expectedDim, attempts)
+}
+
+// generateEmbeddingAttempts generates an embedding up to attempts times until it has the expected dimension
+func (r *RAGService) generateEmbeddingAttempts(ctx context.Context, id, text string, expectedDim, attempts int) ([]float32, error) {
+	var dimension int
+	for attempt := 1; attempt <= attempts; attempt++ {
+		embedding, err := r.embeddingClient.GenerateEmbedding(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+
+		dimension = len(embedding)
+		if expectedDim == 0 || dimension == expectedDim {
+			return embedding, nil
+		}
+
+		r.logger.Warn("Embedding has unexpected dimension",
+			zap.String("id", id),
+			zap.Int("dimension", dimension),
+			zap.Int("expected_dimension", expectedDim),
+			zap.Int("attempt", attempt),
+			zap.Int("attempts", attempts))
+	}
+
+	return nil, fmt.Errorf("%w: %s has dimension %d, expected %d", errEmbeddingDimensionMismatch, id, dimension, expectedDim)
+}
+
+// QueryRelevantContext queries for relevant context across all of the user's vectors
 func (r *RAGService) QueryRelevantContext(ctx context.Context, userID, query string, topK int) ([]models.RAGContext, error) {
+	return r.queryContext(ctx, userID, query, topK, vectordb.FilterByUser(userID))
+}
+
+// QueryDocumentOnlyContext queries for relevant context from uploaded documents only,
+// excluding any health snapshots stored in the vector index
+func (r *RAGService) QueryDocumentOnlyContext(ctx context.Context, userID, query string, topK int) ([]models.RAGContext, error) {
+	if !r.cfg.RAGIncludeHealthData {
+		// No snapshots are indexed, so a plain user filter is sufficient
+		return r.QueryRelevantContext(ctx, userID, query, topK)
+	}
+	return r.queryContext(ctx, userID, query, topK, vectordb.FilterByUserExcludingType(userID, vectordb.VectorTypeHealthSnapshot))
+}
+
+// QueryHealthSnapshots queries for relevant health snapshots only
+func (r *RAGService) QueryHealthSnapshots(ctx context.Context, userID, query string, topK int) ([]models.RAGContext, error) {
+	if !r.cfg.RAGIncludeHealthData {
+		return nil, nil
+	}
+	return r.queryContext(ctx, userID, query, topK, vectordb.FilterByUserAndType(userID, vectordb.VectorTypeHealthSnapshot))
+}
+
+// queryContext embeds the query and returns matching vectors as RAG context
+func (r *RAGService) queryContext(ctx context.Context, userID, query string, topK int, filter vectordb.VectorMetadata) ([]models.RAGContext, error) {
 	// Generate embedding for the query
 	queryEmbedding, err := r.embeddingClient.GenerateEmbedding(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
-	// Create filter for user's documents
-	filter := vectordb.FilterByUser(userID)
+	// Over-fetch when capping per document so other documents can fill the freed slots
+	queryTopK := topK
+	if r.cfg.RAGMaxChunksPerDocument > 0 {
+		queryTopK = topK * 2
+	}
 
 	// Query similar vectors
-	response, err := r.vectorDB.QueryVectors(ctx, queryEmbedding, topK, filter)
+	response, err := r.vectorDB.QueryVectors(ctx, r.vectorDB.Namespace(userID), queryEmbedding, queryTopK, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query vectors: %w", err)
 	}
 
-	// Convert results to RAG context
+	results := filterQueryResults(response.Results, r.cfg.RAGMinRelevanceScore, r.cfg.RAGMaxChunksPerDocument, topK)
+
+	// Convert results to RAG context, looking each document's title up once
+	titles := make(map[string]string)
 	var contexts []models.RAGContext
-	for _, result := range response.Results {
+	for _, result := range results {
+		documentID := extractDocumentID(result.Metadata)
 		context := models.RAGContext{
-			DocumentID: extractDocumentID(result.Metadata),
-			ChunkID:    result.ID,
-			Content:    extractContent(result.Metadata),
-			Score:      result.Score,
+			DocumentID:   documentID,
+			DocumentName: r.resolveDocumentName(userID, documentID, titles),
+			ChunkID:      result.ID,
+			PageNumber:   extractPageNumber(result.Metadata),
+			Content:      r.resolveContent(ctx, documentID, result.Metadata),
+			Score:        result.Score,
 		}
 		contexts = append(contexts, context)
 	}
@@ -87,6 +308,35 @@ func (r *RAGService) QueryRelevantContext(ctx context.Context, userID, query str
 	return contexts, nil
 }
 
+// filterQueryResults drops matches scoring below minScore and keeps at most maxPerDocument matches
+// from any one document, up to limit results. Results must be sorted best first, as Pinecone
+// returns them, so the best chunks of each document are the ones kept. Matches without a
+// document_id, such as health snapshots, aren't capped. Fewer than limit results may be returned.
+func filterQueryResults(results []vectordb.QueryResult, minScore float64, maxPerDocument, limit int) []vectordb.QueryResult {
+	filtered := make([]vectordb.QueryResult, 0, min(len(results), limit))
+	perDocument := make(map[string]int)
+
+	for _, result := range results {
+		if len(filtered) >= limit {
+			break
+		}
+		if float64(result.Score) < minScore {
+			continue
+		}
+
+		if documentID := extractDocumentID(result.Metadata); documentID != "" && maxPerDocument > 0 {
+			if perDocument[documentID] >= maxPerDocument {
+				continue
+			}
+			perDocument[documentID]++
+		}
+
+		filtered = append(filtered, result)
+	}
+
+	return filtered
+}
+
 // QueryDocumentContext queries for context within specific documents
 func (r *RAGService) QueryDocumentContext(ctx context.Context, userID string, documentIDs []string, query string, topK int) ([]models.RAGContext, error) {
 	// Generate embedding for the query
@@ -96,12 +346,13 @@ func (r *RAGService) QueryDocumentContext(ctx context.Context, userID string, do
 	}
 
 	var allContexts []models.RAGContext
+	titles := make(map[string]string)
 
 	// Query each document separately
 	for _, documentID := range documentIDs {
 		filter := vectordb.FilterByDocument(userID, documentID)
 
-		response, err := r.vectorDB.QueryVectors(ctx, queryEmbedding, topK, filter)
+		response, err := r.vectorDB.QueryVectors(ctx, r.vectorDB.Namespace(userID), queryEmbedding, topK, filter)
 		if err != nil {
 			continue // Skip failed documents
 		}
@@ -109,10 +360,12 @@ func (r *RAGService) QueryDocumentContext(ctx context.Context, userID string, do
 		// Convert results to RAG context
 		for _, result := range response.Results {
 			context := models.RAGContext{
-				DocumentID: documentID,
-				ChunkID:    result.ID,
-				Content:    extractContent(result.Metadata),
-				Score:      result.Score,
+				DocumentID:   documentID,
+				DocumentName: r.resolveDocumentName(userID, documentID, titles),
+				ChunkID:      result.ID,
+				PageNumber:   extractPageNumber(result.Metadata),
+				Content:      r.resolveContent(ctx, documentID, result.Metadata),
+				Score:        result.Score,
 			}
 			allContexts = append(allContexts, context)
 		}
@@ -121,25 +374,179 @@ func (r *RAGService) QueryDocumentContext(ctx context.Context, userID string, do
 	return allContexts, nil
 }
 
+// IndexHealthSnapshot embeds a summary of the user's latest metrics as a health snapshot vector.
+// Snapshots are only written when RAG_INCLUDE_HEALTH_DATA is enabled and at most once per
+// configured interval per user; it returns true when a snapshot was stored.
+func (r *RAGService) IndexHealthSnapshot(ctx context.Context, userID string, metrics map[string]models.LatestMetric) (bool, error) {
+	if !r.cfg.RAGIncludeHealthData || len(metrics) == 0 {
+		return false, nil
+	}
+
+	now := time.Now()
+	interval := time.Duration(r.cfg.HealthSnapshotIntervalHr) * time.Hour
+
+	r.snapshotMu.Lock()
+	if last, exists := r.lastSnapshots[userID]; exists && now.Sub(last) < interval {
+		r.snapshotMu.Unlock()
+		return false, nil
+	}
+	r.lastSnapshots[userID] = now
+	r.snapshotMu.Unlock()
+
+	content := buildHealthSnapshotText(now, metrics)
+
+	expectedDim, err := r.expectedDimension(ctx)
+	if err != nil {
+		r.clearSnapshotTime(userID)
+		return false, err
+	}
+
+	embedding, err := r.generateCheckedEmbedding(ctx, "health snapshot for user "+userID, content, expectedDim)
+	if err != nil {
+		r.clearSnapshotTime(userID)
+		return false, fmt.Errorf("failed to generate embedding for health snapshot: %w", err)
+	}
+
+	vector := vectordb.Vector{
+		ID:     fmt.Sprintf("health_snapshot#%s#%s", userID, now.UTC().Format("2006-01-02T15")),
+		Values: embedding,
+		Metadata: vectordb.VectorMetadata{
+			"user_id":       userID,
+			"type":          vectordb.VectorTypeHealthSnapshot,
+			"content":       content,
+			"snapshot_time": now.UTC().Format(time.RFC3339),
+		},
+	}
+
+	if err := r.vectorDB.UpsertVectors(ctx, r.vectorDB.Namespace(userID), []vectordb.Vector{vector}); err != nil {
+		r.clearSnapshotTime(userID)
+		return false, fmt.Errorf("failed to store health snapshot: %w", err)
+	}
+
+	return true, nil
+}
+
+// clearSnapshotTime forgets the last snapshot time so a failed snapshot is retried
+func (r *RAGService) clearSnapshotTime(userID string) {
+	r.snapshotMu.Lock()
+	delete(r.lastSnapshots, userID)
+	r.snapshotMu.Unlock()
+}
+
+// buildHealthSnapshotText renders latest metrics as text suitable for embedding
+func buildHealthSnapshotText(snapshotTime time.Time, metrics map[string]models.LatestMetric) string {
+	metricTypes := make([]string, 0, len(metrics))
+	for metricType := range metrics {
+		metricTypes = append(metricTypes, metricType)
+	}
+	sort.Strings(metricTypes)
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Health snapshot taken on %s:\n", snapshotTime.Format("2006-01-02")))
+
+	for _, metricType := range metricTypes {
+		metric := metrics[metricType]
+		name := metricType
+		if info, exists := models.SupportedMetrics[metricType]; exists {
+			name = info.Name
+		}
+
+		text.WriteString(fmt.Sprintf("- %s: %.2f %s (recorded on %s", name, metric.Value, metric.Unit, metric.Timestamp.Format("2006-01-02 15:04")))
+		if metric.Trend != "" {
+			text.WriteString(fmt.Sprintf(", trend %s", metric.Trend))
+		}
+		text.WriteString(")\n")
+	}
+
+	return text.String()
+}
+
+// indexedChunkMetadataKeys are the vector metadata fields IndexedChunk already carries as fields
+var indexedChunkMetadataKeys = []string{"content", "content_truncated", "user_id", "document_id", "chunk_id", "chunk_index", "page_number", "type"}
+
+// GetDocumentChunks returns the indexed chunks of a document with chunk_index in [offset, offset+limit),
+// in chunk order. Pinecone can't list vectors by metadata alone, so the chunks are found by a query
+// with a constant probe vector restricted to that index range; similarity plays no part.
+func (r *RAGService) GetDocumentChunks(ctx context.Context, userID, documentID string, offset, limit int) ([]models.IndexedChunk, error) {
+	dimension, err := r.expectedDimension(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dimension <= 0 {
+		return nil, fmt.Errorf("unknown index dimension")
+	}
+
+	probe := make([]float32, dimension)
+	for i := range probe {
+		probe[i] = 1
+	}
+
+	filter := vectordb.FilterByDocumentChunkRange(userID, documentID, offset, offset+limit)
+	response, err := r.vectorDB.QueryVectors(ctx, r.vectorDB.Namespace(userID), probe, limit, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query document chunks: %w", err)
+	}
+
+	chunks := make([]models.IndexedChunk, 0, len(response.Results))
+	for _, result := range response.Results {
+		chunkIndex, _ := extractChunkIndex(result.Metadata)
+		truncated, _ := result.Metadata["content_truncated"].(bool)
+
+		metadata := make(map[string]interface{}, len(result.Metadata))
+		for k, v := range result.Metadata {
+			metadata[k] = v
+		}
+		for _, k := range indexedChunkMetadataKeys {
+			delete(metadata, k)
+		}
+
+		chunks = append(chunks, models.IndexedChunk{
+			ChunkID:          result.ID,
+			ChunkIndex:       chunkIndex,
+			PageNumber:       extractPageNumber(result.Metadata),
+			Content:          r.resolveContent(ctx, documentID, result.Metadata),
+			ContentTruncated: truncated,
+			Metadata:         metadata,
+		})
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].ChunkIndex < chunks[j].ChunkIndex
+	})
+
+	return chunks, nil
+}
+
 // DeleteDocumentVectors deletes vectors for a specific document
 func (r *RAGService) DeleteDocumentVectors(ctx context.Context, userID, documentID string) error {
 	filter := vectordb.FilterByDocument(userID, documentID)
-	return r.vectorDB.DeleteVectorsByFilter(ctx, filter)
+	return r.vectorDB.DeleteVectorsByFilter(ctx, r.vectorDB.Namespace(userID), filter)
 }
 
 // DeleteUserVectors deletes all vectors for a user
 func (r *RAGService) DeleteUserVectors(ctx context.Context, userID string) error {
 	filter := vectordb.FilterByUser(userID)
-	return r.vectorDB.DeleteVectorsByFilter(ctx, filter)
+	return r.vectorDB.DeleteVectorsByFilter(ctx, r.vectorDB.Namespace(userID), filter)
 }
 
-// SearchDocuments searches for relevant documents based on semantic similarity
-func (r *RAGService) SearchDocuments(ctx context.Context, userID, query string, topK int) ([]models.Source, error) {
-	contexts, err := r.QueryRelevantContext(ctx, userID, query, topK)
+// SearchDocuments searches for relevant documents based on semantic similarity. In hybrid mode
+// it over-fetches matches and re-ranks them by a blend of vector similarity and keyword overlap
+// with the query, weighted by HybridAlpha, so exact terms such as drug names aren't missed.
+func (r *RAGService) SearchDocuments(ctx context.Context, userID, query string, topK int, hybrid bool) ([]models.Source, error) {
+	fetchK := topK
+	if hybrid {
+		fetchK = topK * hybridOverfetch
+	}
+
+	contexts, err := r.QueryRelevantContext(ctx, userID, query, fetchK)
 	if err != nil {
 		return nil, err
 	}
 
+	if hybrid {
+		rerankHybrid(query, contexts, r.cfg.HybridAlpha)
+	}
+
 	// Group contexts by document and convert to sources
 	documentMap := make(map[string][]models.RAGContext)
 	for _, context := range contexts {
@@ -158,14 +565,22 @@ func (r *RAGService) SearchDocuments(ctx context.Context, userID, query string,
 
 		source := models.Source{
 			DocumentID:   documentID,
-			DocumentName: "Document", // Would need to fetch actual name
+			DocumentName: documentName(bestContext, "Document"),
 			ChunkID:      bestContext.ChunkID,
+			PageNumber:   bestContext.PageNumber,
 			Content:      bestContext.Content,
 			Relevance:    bestContext.Score,
 		}
 		sources = append(sources, source)
 	}
 
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Relevance > sources[j].Relevance
+	})
+	if len(sources) > topK {
+		sources = sources[:topK]
+	}
+
 	return sources, nil
 }
 
@@ -177,16 +592,113 @@ func extractDocumentID(metadata vectordb.VectorMetadata) string {
 	return ""
 }
 
-// extractContent extracts content from vector metadata (placeholder)
+// extractContent extracts content from vector metadata
 func extractContent(metadata vectordb.VectorMetadata) string {
-	// In a real implementation, you'd store chunk content in metadata
-	// or fetch it from another source
-	if content, ok := metadata["content"].(string); ok {
+	if content, ok := metadata["content"].(string); ok && content != "" {
 		return content
 	}
 	return "Content not available"
 }
 
+// extractChunkIndex extracts the chunk index from vector metadata.
+// Pinecone returns numeric metadata as float64.
+func extractChunkIndex(metadata vectordb.VectorMetadata) (int, bool) {
+	switch index := metadata["chunk_index"].(type) {
+	case float64:
+		return int(index), true
+	case int:
+		return index, true
+	}
+	return 0, false
+}
+
+// extractPageNumber extracts the page number from vector metadata, or 0 when it isn't known.
+// Pinecone returns numeric metadata as float64.
+func extractPageNumber(metadata vectordb.VectorMetadata) int {
+	switch page := metadata["page_number"].(type) {
+	case float64:
+		return int(page)
+	case int:
+		return page
+	}
+	return 0
+}
+
+// resolveContent returns the chunk text from metadata, falling back to the chunk content
+// fetcher when the metadata doesn't carry it or carries a truncated copy
+func (r *RAGService) resolveContent(ctx context.Context, documentID string, metadata vectordb.VectorMetadata) string {
+	truncated, _ := metadata["content_truncated"].(bool)
+	if content, ok := metadata["content"].(string); ok && content != "" && !truncated {
+		return content
+	}
+
+	userID, _ := metadata["user_id"].(string)
+	chunkIndex, hasIndex := extractChunkIndex(metadata)
+	if r.chunkFetcher == nil || documentID == "" || userID == "" || !hasIndex || ctx.Err() != nil {
+		return extractContent(metadata)
+	}
+
+	content, err := r.chunkFetcher.GetChunkContent(userID, documentID, chunkIndex)
+	if err != nil || content == "" {
+		r.logger.Warn("Failed to fetch chunk content",
+			zap.String("document_id", documentID),
+			zap.Int("chunk_index", chunkIndex),
+			zap.Error(err))
+		return extractContent(metadata)
+	}
+
+	return content
+}
+
+// resolveDocumentName returns the title of a matched document, consulting titles first and
+// recording every lookup in it, failures included, so each document is fetched at most once
+func (r *RAGService) resolveDocumentName(userID, documentID string, titles map[string]string) string {
+	if r.titleLookup == nil || documentID == "" {
+		return ""
+	}
+
+	if title, ok := titles[documentID]; ok {
+		return title
+	}
+
+	title := ""
+	document, err := r.titleLookup.GetDocument(userID, documentID)
+	if err != nil {
+		r.logger.Warn("Failed to look up document title",
+			zap.String("document_id", documentID), zap.Error(err))
+	} else if document != nil {
+		title = document.Title
+		if title == "" {
+			title = document.FileName
+		}
+	}
+
+	titles[documentID] = title
+	return title
+}
+
+// documentName returns the resolved title of a context's document, or fallback when there is none
+func documentName(context models.RAGContext, fallback string) string {
+	if context.DocumentName != "" {
+		return context.DocumentName
+	}
+	return fallback
+}
+
+// truncateUTF8 truncates s to at most maxBytes without splitting a multi-byte rune
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+
+	// Back up to the start of the rune that straddles the limit
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
 // GetIndexStats returns statistics about the vector index
 func (r *RAGService) GetIndexStats(ctx context.Context) (map[string]interface{}, error) {
 	stats, err := r.vectorDB.GetIndexStats(ctx)
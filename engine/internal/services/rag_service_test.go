@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/internal/vectordb"
+)
+
+func TestTruncatedChunkContentResolvedFromChunkStore(t *testing.T) {
+	f := newAgentFixture(t, func(cfg *config.Config) {
+		cfg.PineconeMaxContentBytes = 16
+		cfg.EmbeddingDimension = testEmbeddingDimension
+	})
+
+	// The S3 client is nil, so any fallback to the original file would panic
+	documents := NewDocumentService(nil, f.db, f.rag, f.cfg)
+	t.Cleanup(func() { documents.Shutdown(context.Background()) })
+	f.rag.SetChunkContentFetcher(documents)
+
+	chunks := []models.DocumentChunk{
+		{ChunkID: "doc-1#0", DocumentID: "doc-1", UserID: "user-1", ChunkIndex: 0, Content: strings.Repeat("cholesterol ", 10)},
+		{ChunkID: "doc-1#1", DocumentID: "doc-1", UserID: "user-1", ChunkIndex: 1, Content: strings.Repeat("triglycerides ", 10)},
+		{ChunkID: "doc-1#2", DocumentID: "doc-1", UserID: "user-1", ChunkIndex: 2, Content: "HDL 55"},
+	}
+	if err := f.rag.ProcessDocumentChunks(context.Background(), "user-1", "doc-1", chunks); err != nil {
+		t.Fatalf("process chunks: %v", err)
+	}
+	// Only the first chunk made it to the chunk store, as for a document processed before it existed
+	if err := f.db.PutDocumentChunks(chunks[:1]); err != nil {
+		t.Fatalf("put chunks: %v", err)
+	}
+
+	indexed, err := f.rag.GetDocumentChunks(context.Background(), "user-1", "doc-1", 0, 10)
+	if err != nil {
+		t.Fatalf("get document chunks: %v", err)
+	}
+	if len(indexed) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(indexed))
+	}
+
+	if indexed[0].Content != chunks[0].Content {
+		t.Errorf("chunk 0 = %q, want the full text from the chunk store", indexed[0].Content)
+	}
+	if !indexed[1].ContentTruncated || indexed[1].Content != chunks[1].Content[:16] {
+		t.Errorf("chunk 1 = %q, want the truncated metadata copy when the store has none", indexed[1].Content)
+	}
+	if indexed[2].Content != chunks[2].Content || indexed[2].ContentTruncated {
+		t.Errorf("chunk 2 = %q, want the untruncated metadata text", indexed[2].Content)
+	}
+}
+
+func TestChunkContentRequiresOwner(t *testing.T) {
+	f := newAgentFixture(t, nil)
+	documents := NewDocumentService(nil, f.db, f.rag, f.cfg)
+	t.Cleanup(func() { documents.Shutdown(context.Background()) })
+
+	chunk := models.DocumentChunk{ChunkID: "doc-1#0", DocumentID: "doc-1", UserID: "user-1", ChunkIndex: 0, Content: "LDL 130"}
+	if err := f.db.PutDocumentChunks([]models.DocumentChunk{chunk}); err != nil {
+		t.Fatalf("put chunks: %v", err)
+	}
+
+	if content, err := documents.GetChunkContent("user-1", "doc-1", 0); err != nil || content != chunk.Content {
+		t.Errorf("owner got %q, %v; want %q", content, err, chunk.Content)
+	}
+	if content, err := documents.GetChunkContent("user-2", "doc-1", 0); err == nil {
+		t.Errorf("another user got %q, want an error", content)
+	}
+	if _, err := documents.GetChunkContent("user-1", "doc-1", 5); err == nil {
+		t.Error("missing chunk returned no error")
+	}
+}
+
+func TestProcessDocumentChunksHandlesDimensionMismatch(t *testing.T) {
+	chunks := []models.DocumentChunk{
+		{ChunkID: "doc-1#0", DocumentID: "doc-1", UserID: "user-1", ChunkIndex: 0, Content: "LDL 130"},
+		{ChunkID: "doc-1#1", DocumentID: "doc-1", UserID: "user-1", ChunkIndex: 1, Content: "glitch"},
+	}
+
+	for _, tc := range []struct {
+		action      string
+		wantVectors int
+	}{
+		{"skip", 1},
+		{"regenerate", 2},
+	} {
+		f := newAgentFixture(t, func(cfg *config.Config) {
+			cfg.EmbeddingDimension = testEmbeddingDimension
+			cfg.EmbeddingMismatchAction = tc.action
+			cfg.EmbeddingMaxRegenerations = 2
+		})
+
+		// "glitch" comes back one dimension short the first time it is embedded
+		glitched := false
+		f.embeddings.embed = func(ctx context.Context, text string) ([]float32, error) {
+			if text == "glitch" && !glitched {
+				glitched = true
+				return make([]float32, testEmbeddingDimension-1), nil
+			}
+			return make([]float32, testEmbeddingDimension), nil
+		}
+
+		if err := f.rag.ProcessDocumentChunks(context.Background(), "user-1", "doc-1", chunks); err != nil {
+			t.Fatalf("%s: process chunks: %v", tc.action, err)
+		}
+		if got := f.vectors.count(f.vectors.Namespace("user-1")); got != tc.wantVectors {
+			t.Errorf("%s: stored %d vectors, want %d", tc.action, got, tc.wantVectors)
+		}
+	}
+}
+
+func TestProcessDocumentChunksFailsWhenEveryEmbeddingMismatches(t *testing.T) {
+	f := newAgentFixture(t, func(cfg *config.Config) {
+		cfg.EmbeddingDimension = testEmbeddingDimension
+		cfg.EmbeddingMismatchAction = "regenerate"
+		cfg.EmbeddingMaxRegenerations = 1
+	})
+	f.embeddings.embed = func(ctx context.Context, text string) ([]float32, error) {
+		return make([]float32, testEmbeddingDimension*2), nil
+	}
+
+	chunks := []models.DocumentChunk{{ChunkID: "doc-1#0", DocumentID: "doc-1", UserID: "user-1", Content: "LDL 130"}}
+	err := f.rag.ProcessDocumentChunks(context.Background(), "user-1", "doc-1", chunks)
+	if !errors.Is(err, errEmbeddingDimensionMismatch) {
+		t.Errorf("got %v, want errEmbeddingDimensionMismatch", err)
+	}
+	if f.vectors.upserts != 0 {
+		t.Errorf("made %d upserts, want none with only mismatched embeddings", f.vectors.upserts)
+	}
+}
+
+func TestFilterQueryResultsThresholdsAndCapsPerDocument(t *testing.T) {
+	match := func(id, documentID string, score float32) vectordb.QueryResult {
+		metadata := vectordb.VectorMetadata{}
+		if documentID != "" {
+			metadata["document_id"] = documentID
+		}
+		return vectordb.QueryResult{ID: id, Score: score, Metadata: metadata}
+	}
+
+	// Best first, as Pinecone returns them
+	results := []vectordb.QueryResult{
+		match("a#0", "a", 0.95),
+		match("a#1", "a", 0.93),
+		match("a#2", "a", 0.91),
+		match("snapshot", "", 0.9),
+		match("b#0", "b", 0.8),
+		match("c#0", "c", 0.4),
+	}
+
+	ids := func(results []vectordb.QueryResult) string {
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	for _, tc := range []struct {
+		name           string
+		minScore       float64
+		maxPerDocument int
+		limit          int
+		want           string
+	}{
+		{"no filtering", 0, 0, 10, "a#0,a#1,a#2,snapshot,b#0,c#0"},
+		{"threshold", 0.5, 0, 10, "a#0,a#1,a#2,snapshot,b#0"},
+		{"one per document", 0, 1, 10, "a#0,snapshot,b#0,c#0"},
+		{"capped slots refilled", 0.5, 2, 4, "a#0,a#1,snapshot,b#0"},
+		{"fewer rather than padded", 0.85, 1, 4, "a#0,snapshot"},
+	} {
+		if got := ids(filterQueryResults(results, tc.minScore, tc.maxPerDocument, tc.limit)); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestQueryRelevantContextDropsLowScoringMatches(t *testing.T) {
+	f := newAgentFixture(t, func(cfg *config.Config) {
+		cfg.RAGMinRelevanceScore = 0.5
+		cfg.RAGMaxChunksPerDocument = 1
+	})
+
+	namespace := f.vectors.Namespace("user-1")
+	unit := func(weight float32) []float32 {
+		values := make([]float32, testEmbeddingDimension)
+		for i := range values {
+			values[i] = weight
+		}
+		return values
+	}
+	query := unit(1.0 / testEmbeddingDimension)
+	f.embeddings.embed = func(ctx context.Context, text string) ([]float32, error) { return query, nil }
+
+	err := f.vectors.UpsertVectors(context.Background(), namespace, []vectordb.Vector{
+		{ID: "a#0", Values: unit(0.9), Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "a", "content": "a0"}},
+		{ID: "a#1", Values: unit(0.8), Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "a", "content": "a1"}},
+		{ID: "b#0", Values: unit(0.7), Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "b", "content": "b0"}},
+		{ID: "c#0", Values: unit(0.1), Metadata: vectordb.VectorMetadata{"user_id": "user-1", "document_id": "c", "content": "c0"}},
+	})
+	if err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	contexts, err := f.rag.QueryRelevantContext(context.Background(), "user-1", "cholesterol", 5)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+
+	var got []string
+	for _, c := range contexts {
+		got = append(got, c.ChunkID)
+	}
+	if strings.Join(got, ",") != "a#0,b#0" {
+		t.Errorf("got %v, want the best chunk of a and b only", got)
+	}
+}
+
+func TestProcessDocumentChunksEmbedsInBatches(t *testing.T) {
+	f := newAgentFixture(t, func(cfg *config.Config) { cfg.EmbeddingBatchSize = 16 })
+
+	chunks := make([]models.DocumentChunk, 50)
+	for i := range chunks {
+		chunks[i] = models.DocumentChunk{
+			ChunkID:    fmt.Sprintf("doc-1#%d", i),
+			DocumentID: "doc-1",
+			UserID:     "user-1",
+			ChunkIndex: i,
+			Content:    fmt.Sprintf("chunk %d", i),
+		}
+	}
+
+	if err := f.rag.ProcessDocumentChunks(context.Background(), "user-1", "doc-1", chunks); err != nil {
+		t.Fatalf("process chunks: %v", err)
+	}
+	if got := f.embeddings.callCount(); got != 4 {
+		t.Errorf("made %d embedding requests, want 4 batches of up to 16", got)
+	}
+	if got := f.vectors.count(f.vectors.Namespace("user-1")); got != 50 {
+		t.Errorf("stored %d vectors, want 50", got)
+	}
+}
+
+// countingLookup counts document lookups per document ID
+type countingLookup struct {
+	DocumentLookup
+	calls map[string]int
+}
+
+func (l *countingLookup) GetDocument(userID, documentID string) (*models.Document, error) {
+	l.calls[documentID]++
+	return l.DocumentLookup.GetDocument(userID, documentID)
+}
+
+func TestQueryRelevantContextLooksUpEachTitleOnce(t *testing.T) {
+	f := newAgentFixture(t, func(cfg *config.Config) { cfg.RAGMaxChunksPerDocument = 0 })
+	lookup := &countingLookup{DocumentLookup: f.db, calls: make(map[string]int)}
+	f.rag.SetDocumentLookup(lookup)
+
+	putDocument(t, f.db, "user-1", "doc-1")
+	untitled := putDocument(t, f.db, "user-1", "doc-2")
+	untitled.Title = ""
+	if err := f.db.PutDocument(untitled); err != nil {
+		t.Fatalf("put document: %v", err)
+	}
+
+	embedding := make([]float32, testEmbeddingDimension)
+	for i := range embedding {
+		embedding[i] = 1
+	}
+	var vectors []vectordb.Vector
+	for _, id := range []string{"doc-1#0", "doc-1#1", "doc-2#0"} {
+		documentID := strings.Split(id, "#")[0]
+		vectors = append(vectors, vectordb.Vector{ID: id, Values: embedding, Metadata: vectordb.VectorMetadata{
+			"user_id": "user-1", "document_id": documentID, "content": id,
+		}})
+	}
+	if err := f.vectors.UpsertVectors(context.Background(), f.vectors.Namespace("user-1"), vectors); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	contexts, err := f.rag.QueryRelevantContext(context.Background(), "user-1", "cholesterol", 5)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(contexts) != 3 {
+		t.Fatalf("got %d contexts, want 3", len(contexts))
+	}
+	for _, c := range contexts {
+		want := map[string]string{"doc-1": "Lipid panel", "doc-2": "doc-2.pdf"}[c.DocumentID]
+		if c.DocumentName != want {
+			t.Errorf("%s: document name %q, want %q", c.ChunkID, c.DocumentName, want)
+		}
+	}
+	if lookup.calls["doc-1"] != 1 || lookup.calls["doc-2"] != 1 {
+		t.Errorf("lookups = %v, want one per document", lookup.calls)
+	}
+}
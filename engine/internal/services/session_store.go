@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database"
+	"health-dashboard-backend/internal/models"
+)
+
+// maxStoredSessionMessages caps the messages kept with a stored session, keeping DynamoDB items
+// well under the 400 KB limit. The full conversation is still in chat history.
+const maxStoredSessionMessages = 50
+
+// SessionStore keeps WebSocket chat sessions by session ID so a client can resume one
+type SessionStore interface {
+	// Get returns the stored session, or nil when there is none
+	Get(sessionID string) (*models.ChatSession, error)
+	// Put stores the session, replacing any earlier copy
+	Put(session *models.ChatSession) error
+	// Delete removes the stored session
+	Delete(sessionID string) error
+}
+
+// NewSessionStore creates the session store selected by WSSessionStore: "dynamodb" survives
+// restarts and is shared between instances, anything else keeps sessions in memory
+func NewSessionStore(cfg *config.Config, db *database.DynamoDBClient) (SessionStore, error) {
+	ttl := time.Duration(cfg.WSSessionTTLHours) * time.Hour
+
+	switch cfg.WSSessionStore {
+	case "dynamodb":
+		return &dynamoSessionStore{db: db, ttl: ttl}, nil
+	case "", "memory":
+		return NewMemorySessionStore(ttl), nil
+	default:
+		return nil, fmt.Errorf("unsupported session store: %s", cfg.WSSessionStore)
+	}
+}
+
+// prepareStoredSession copies a session for storage, trimmed to the latest messages and with its
+// expiry set ttl after its last activity
+func prepareStoredSession(session *models.ChatSession, ttl time.Duration) *models.ChatSession {
+	stored := *session
+	if len(stored.Messages) > maxStoredSessionMessages {
+		stored.Messages = stored.Messages[len(stored.Messages)-maxStoredSessionMessages:]
+	}
+	stored.Messages = append([]models.ChatMessage(nil), stored.Messages...)
+
+	stored.ExpiresAt = 0
+	if ttl > 0 {
+		stored.ExpiresAt = stored.LastActive.Add(ttl).Unix()
+	}
+	return &stored
+}
+
+// isExpiredSession reports whether a stored session's TTL has passed. DynamoDB TTL deletes
+// expired items in the background, so they can still be read for a while.
+func isExpiredSession(session *models.ChatSession, now time.Time) bool {
+	return session.ExpiresAt > 0 && now.Unix() >= session.ExpiresAt
+}
+
+// MemorySessionStore keeps sessions in process memory, so they are lost on restart
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*models.ChatSession
+	ttl      time.Duration
+}
+
+// NewMemorySessionStore creates an in-memory session store whose sessions expire ttl after their
+// last activity; 0 keeps them until deleted
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*models.ChatSession),
+		ttl:      ttl,
+	}
+}
+
+// Get returns a copy of the stored session, or nil when there is none
+func (s *MemorySessionStore) Get(sessionID string) (*models.ChatSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		return nil, nil
+	}
+	if isExpiredSession(session, time.Now()) {
+		delete(s.sessions, sessionID)
+		return nil, nil
+	}
+
+	return prepareStoredSession(session, s.ttl), nil
+}
+
+// Put stores a copy of the session, first dropping any expired sessions
+func (s *MemorySessionStore) Put(session *models.ChatSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for sessionID, stored := range s.sessions {
+		if isExpiredSession(stored, now) {
+			delete(s.sessions, sessionID)
+		}
+	}
+
+	s.sessions[session.SessionID] = prepareStoredSession(session, s.ttl)
+	return nil
+}
+
+// Delete removes the stored session
+func (s *MemorySessionStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+// dynamoSessionStore keeps sessions in the DynamoDB sessions table
+type dynamoSessionStore struct {
+	db  *database.DynamoDBClient
+	ttl time.Duration
+}
+
+// Get returns the stored session, or nil when there is none or it has expired
+func (s *dynamoSessionStore) Get(sessionID string) (*models.ChatSession, error) {
+	session, err := s.db.GetStoredChatSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || isExpiredSession(session, time.Now()) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Put stores the session with its latest messages
+func (s *dynamoSessionStore) Put(session *models.ChatSession) error {
+	return s.db.PutStoredChatSession(prepareStoredSession(session, s.ttl))
+}
+
+// Delete removes the stored session
+func (s *dynamoSessionStore) Delete(sessionID string) error {
+	return s.db.DeleteStoredChatSession(sessionID)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/internal/database/dynamotest"
+	"health-dashboard-backend/internal/models"
+)
+
+func TestDynamoSessionStoreOutlivesTheStoreInstance(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.WSSessionStore = "dynamodb"
+	db, _ := dynamotest.NewClient(cfg)
+
+	store, err := NewSessionStore(cfg, db)
+	if err != nil {
+		t.Fatalf("new session store: %v", err)
+	}
+	session := &models.ChatSession{
+		SessionID:  "session-1",
+		UserID:     "user-1",
+		StartTime:  time.Now().Add(-time.Minute),
+		LastActive: time.Now(),
+		Messages:   []models.ChatMessage{*models.NewChatMessage("user-1", "user", "How is my LDL?")},
+	}
+	if err := store.Put(session); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	// A second store, as after a restart, sees the same session
+	restarted, err := NewSessionStore(cfg, db)
+	if err != nil {
+		t.Fatalf("new session store: %v", err)
+	}
+	stored, err := restarted.Get("session-1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored == nil || stored.UserID != "user-1" || len(stored.Messages) != 1 || stored.Messages[0].Content != "How is my LDL?" {
+		t.Fatalf("stored session = %+v, want user-1's session with its message", stored)
+	}
+
+	if err := restarted.Delete("session-1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if stored, err := store.Get("session-1"); err != nil || stored != nil {
+		t.Errorf("after delete got %+v, %v; want no session", stored, err)
+	}
+}
+
+func TestNewSessionStoreRejectsUnknownStore(t *testing.T) {
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.WSSessionStore = "redis"
+	db, _ := dynamotest.NewClient(cfg)
+
+	if _, err := NewSessionStore(cfg, db); err == nil {
+		t.Error("created a session store for \"redis\", want an error")
+	}
+}
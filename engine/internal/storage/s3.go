@@ -2,6 +2,8 @@ package storage
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"time"
@@ -17,9 +19,14 @@ import (
 
 // S3Client wraps the AWS S3 client
 type S3Client struct {
-	client   *s3.S3
-	uploader *s3manager.Uploader
-	bucket   string
+	client            *s3.S3
+	uploader          *s3manager.Uploader
+	bucket            string
+	maxPresignMinutes int
+	uploadConcurrency int
+	sseMode           string
+	kmsKeyID          string
+	checksums         bool
 }
 
 // NewS3Client creates a new S3 client
@@ -42,13 +49,22 @@ func NewS3Client(cfg *config.Config) (*S3Client, error) {
 		return nil, fmt.Errorf("failed to create AWS session: %w", err)
 	}
 
-	client := s3.New(sess)
+	return NewS3ClientWithSession(sess, cfg), nil
+}
 
+// NewS3ClientWithSession creates a client that sends its calls through sess, such as one pointed
+// at a local S3-compatible server or a stand-in in tests
+func NewS3ClientWithSession(sess *session.Session, cfg *config.Config) *S3Client {
 	return &S3Client{
-		client:   client,
-		uploader: s3manager.NewUploader(sess),
-		bucket:   cfg.S3Bucket,
-	}, nil
+		client:            s3.New(sess),
+		uploader:          s3manager.NewUploader(sess),
+		bucket:            cfg.S3Bucket,
+		maxPresignMinutes: cfg.MaxPresignMinutes,
+		uploadConcurrency: cfg.S3UploadConcurrency,
+		sseMode:           cfg.S3SSEMode,
+		kmsKeyID:          cfg.S3KMSKeyID,
+		checksums:         cfg.S3UploadChecksums,
+	}
 }
 
 // UploadFile uploads a file to S3
@@ -61,6 +77,8 @@ func (s *S3Client) UploadFile(key string, content io.Reader, contentType string,
 		Metadata:    metadata,
 	}
 
+	s.prepareUpload(input)
+
 	result, err := s.uploader.Upload(input)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file to S3: %w", err)
@@ -69,9 +87,77 @@ func (s *S3Client) UploadFile(key string, content io.Reader, contentType string,
 	return result.Location, nil
 }
 
-// UploadBytes uploads byte data to S3
+// UploadStream uploads from a reader in parts of partSize bytes without reading the whole body
+// into memory first. Parts are uploaded in parallel up to the configured concurrency; bodies
+// smaller than one part are sent with a single PutObject. A partSize below S3's 5MB minimum is raised to it.
+func (s *S3Client) UploadStream(key string, body io.Reader, contentType string, partSize int64, metadata map[string]*string) (string, error) {
+	input := &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+		Metadata:    metadata,
+	}
+	s.prepareUpload(input)
+
+	result, err := s.uploader.Upload(input, func(u *s3manager.Uploader) {
+		u.PartSize = max(partSize, s3manager.MinUploadPartSize)
+		if s.uploadConcurrency > 0 {
+			u.Concurrency = s.uploadConcurrency
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to stream file to S3: %w", err)
+	}
+
+	return result.Location, nil
+}
+
+// UploadBytes uploads byte data to S3. Data that fits in a single part is sent with a Content-MD5
+// so S3 rejects it if it was corrupted in transit.
 func (s *S3Client) UploadBytes(key string, data []byte, contentType string, metadata map[string]*string) (string, error) {
-	return s.UploadFile(key, bytes.NewReader(data), contentType, metadata)
+	if !s.checksums || int64(len(data)) >= s.uploader.PartSize {
+		return s.UploadFile(key, bytes.NewReader(data), contentType, metadata)
+	}
+
+	sum := md5.Sum(data)
+	input := &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+		ContentMD5:  aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+		Metadata:    metadata,
+	}
+	s.applyEncryption(input)
+
+	result, err := s.uploader.Upload(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to S3: %w", err)
+	}
+
+	return result.Location, nil
+}
+
+// prepareUpload sets server-side encryption and, for uploads of unknown size, a SHA-256 checksum.
+// S3 verifies the checksum of each part, which a whole-object Content-MD5 can't do for multipart uploads.
+func (s *S3Client) prepareUpload(input *s3manager.UploadInput) {
+	s.applyEncryption(input)
+	if s.checksums {
+		input.ChecksumAlgorithm = aws.String(s3.ChecksumAlgorithmSha256)
+	}
+}
+
+// applyEncryption sets the configured server-side encryption on an upload
+func (s *S3Client) applyEncryption(input *s3manager.UploadInput) {
+	if s.sseMode == "" || s.sseMode == "none" {
+		return
+	}
+
+	input.ServerSideEncryption = aws.String(s.sseMode)
+	if s.sseMode == s3.ServerSideEncryptionAwsKms && s.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
 }
 
 // DownloadFile downloads a file from S3
@@ -144,7 +230,9 @@ func (s *S3Client) ListFiles(prefix string, maxKeys int64) (*s3.ListObjectsV2Out
 	return result, nil
 }
 
-// GeneratePresignedURL generates a pre-signed URL for file access
+// GeneratePresignedURL generates a pre-signed URL for file access.
+// The expiration is clamped to the configured maximum; use EffectivePresignMinutes
+// to find out the lifetime actually applied.
 func (s *S3Client) GeneratePresignedURL(key string, expirationMinutes int) (string, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
@@ -154,7 +242,7 @@ func (s *S3Client) GeneratePresignedURL(key string, expirationMinutes int) (stri
 	req, _ := s.client.GetObjectRequest(input)
 
 	// Set expiration time
-	duration := time.Duration(expirationMinutes) * time.Minute
+	duration := time.Duration(s.EffectivePresignMinutes(expirationMinutes)) * time.Minute
 	url, err := req.Presign(duration)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
@@ -163,6 +251,17 @@ func (s *S3Client) GeneratePresignedURL(key string, expirationMinutes int) (stri
 	return url, nil
 }
 
+// EffectivePresignMinutes clamps a requested presigned URL lifetime to [1, max] minutes
+func (s *S3Client) EffectivePresignMinutes(requestedMinutes int) int {
+	if requestedMinutes < 1 {
+		requestedMinutes = 1
+	}
+	if s.maxPresignMinutes > 0 && requestedMinutes > s.maxPresignMinutes {
+		return s.maxPresignMinutes
+	}
+	return requestedMinutes
+}
+
 // CopyFile copies a file within S3
 func (s *S3Client) CopyFile(sourceKey, destKey string) error {
 	copySource := fmt.Sprintf("%s/%s", s.bucket, sourceKey)
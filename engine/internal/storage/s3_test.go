@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// s3Request is a request received by the fake S3 server
+type s3Request struct {
+	Method string
+	Query  string
+	Header http.Header
+	Size   int64 // Body bytes
+}
+
+// fakeS3 answers the S3 calls made by uploads and records each request
+type fakeS3 struct {
+	mu       sync.Mutex
+	requests []s3Request
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	size, _ := io.Copy(io.Discard, r.Body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, s3Request{Method: r.Method, Query: r.URL.RawQuery, Header: r.Header.Clone(), Size: size})
+	f.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>test-bucket</Bucket><Key>k</Key><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		w.Header().Set("ETag", `"part-`+query.Get("partNumber")+`"`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><Location>`+r.URL.Path+`</Location><ETag>"done"</ETag></CompleteMultipartUploadResult>`)
+	default:
+		w.Header().Set("ETag", `"object"`)
+	}
+}
+
+// count returns how many recorded requests match
+func (f *fakeS3) count(match func(s3Request) bool) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, request := range f.requests {
+		if match(request) {
+			n++
+		}
+	}
+	return n
+}
+
+// newTestS3Client returns an S3Client talking to a fake S3 server
+func newTestS3Client(t *testing.T, configure func(s *S3Client)) (*S3Client, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("test", "test", ""),
+	})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+
+	client := &S3Client{
+		client:   s3.New(sess),
+		uploader: s3manager.NewUploader(sess),
+		bucket:   "test-bucket",
+	}
+	if configure != nil {
+		configure(client)
+	}
+	return client, fake
+}
+
+// zeroReader streams zero bytes without holding them in memory, and isn't seekable
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestUploadStreamUsesMultipartParts(t *testing.T) {
+	client, fake := newTestS3Client(t, func(s *S3Client) { s.uploadConcurrency = 2 })
+
+	const size = 12 << 20 // Three 5MB parts, the last one short
+	body := io.LimitReader(zeroReader{}, size)
+	if _, err := client.UploadStream("users/user-1/scan.pdf", body, "application/pdf", 5<<20, nil); err != nil {
+		t.Fatalf("upload stream: %v", err)
+	}
+
+	isPart := func(r s3Request) bool { return r.Method == http.MethodPut && strings.Contains(r.Query, "partNumber=") }
+	if got := fake.count(isPart); got != 3 {
+		t.Errorf("uploaded %d parts, want 3", got)
+	}
+
+	var uploaded int64
+	fake.mu.Lock()
+	for _, request := range fake.requests {
+		if isPart(request) {
+			uploaded += request.Size
+		}
+	}
+	fake.mu.Unlock()
+	if uploaded != size {
+		t.Errorf("parts carried %d bytes, want %d", uploaded, size)
+	}
+
+	if got := fake.count(func(r s3Request) bool { return r.Method == http.MethodPost && strings.Contains(r.Query, "uploadId=") }); got != 1 {
+		t.Errorf("completed %d multipart uploads, want 1", got)
+	}
+}
+
+func TestUploadStreamSendsSmallBodyInOneRequest(t *testing.T) {
+	client, fake := newTestS3Client(t, nil)
+
+	// A part size below S3's minimum is raised to it, so 1MB still fits in one part
+	if _, err := client.UploadStream("users/user-1/note.txt", io.LimitReader(zeroReader{}, 1<<20), "text/plain", 1024, nil); err != nil {
+		t.Fatalf("upload stream: %v", err)
+	}
+
+	if got := fake.count(func(s3Request) bool { return true }); got != 1 {
+		t.Errorf("sent %d requests, want a single PutObject", got)
+	}
+}
+
+func TestUploadsCarryEncryptionHeaders(t *testing.T) {
+	kms := func(s *S3Client) {
+		s.sseMode = s3.ServerSideEncryptionAwsKms
+		s.kmsKeyID = "key-1"
+		s.checksums = true
+	}
+
+	client, fake := newTestS3Client(t, kms)
+	data := []byte("LDL 130 mg/dL")
+	if _, err := client.UploadBytes("users/user-1/labs.txt", data, "text/plain", nil); err != nil {
+		t.Fatalf("upload bytes: %v", err)
+	}
+	put := fake.requests[0]
+	if put.Header.Get("X-Amz-Server-Side-Encryption") != "aws:kms" || put.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != "key-1" {
+		t.Errorf("PutObject headers = %v, want aws:kms with key-1", put.Header)
+	}
+	sum := md5.Sum(data)
+	if got := put.Header.Get("Content-Md5"); got != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Errorf("Content-MD5 = %q, want the body's MD5", got)
+	}
+
+	// Multipart uploads set encryption when the upload is created
+	client, fake = newTestS3Client(t, func(s *S3Client) { s.sseMode = s3.ServerSideEncryptionAes256 })
+	if _, err := client.UploadStream("users/user-1/scan.pdf", io.LimitReader(zeroReader{}, 6<<20), "application/pdf", 5<<20, nil); err != nil {
+		t.Fatalf("upload stream: %v", err)
+	}
+	if got := fake.requests[0]; !strings.Contains(got.Query, "uploads") || got.Header.Get("X-Amz-Server-Side-Encryption") != "AES256" {
+		t.Errorf("first request %s?%s has encryption %q, want CreateMultipartUpload with AES256",
+			got.Method, got.Query, got.Header.Get("X-Amz-Server-Side-Encryption"))
+	}
+
+	client, fake = newTestS3Client(t, func(s *S3Client) { s.sseMode = "none" })
+	if _, err := client.UploadFile("users/user-1/notes.txt", strings.NewReader("notes"), "text/plain", nil); err != nil {
+		t.Fatalf("upload file: %v", err)
+	}
+	if got := fake.requests[0].Header.Get("X-Amz-Server-Side-Encryption"); got != "" {
+		t.Errorf("encryption header %q with SSE disabled", got)
+	}
+}
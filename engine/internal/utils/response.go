@@ -32,6 +32,48 @@ func ErrorResponse(c *gin.Context, statusCode int, message string) {
 	})
 }
 
+// ErrorCode is a machine-readable error category clients can branch on instead of parsing messages
+type ErrorCode string
+
+// Error codes returned in APIError
+const (
+	CodeValidationError ErrorCode = "VALIDATION_ERROR"
+	CodeUnauthorized    ErrorCode = "UNAUTHORIZED"
+	CodeForbidden       ErrorCode = "FORBIDDEN"
+	CodeNotFound        ErrorCode = "NOT_FOUND"
+	CodeConflict        ErrorCode = "CONFLICT"
+	CodeRateLimited     ErrorCode = "RATE_LIMITED"
+	CodeUpstreamError   ErrorCode = "UPSTREAM_ERROR"
+	CodeInternalError   ErrorCode = "INTERNAL_ERROR"
+	CodeNotImplemented  ErrorCode = "NOT_IMPLEMENTED"
+)
+
+// APIError is the error object of a coded error response
+type APIError struct {
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// ErrorResponseWithCode sends an error API response whose error carries a machine-readable code.
+// The message is also kept at the top level for clients reading the older shape.
+func ErrorResponseWithCode(c *gin.Context, statusCode int, code ErrorCode, message string) {
+	ErrorResponseWithCodeDetails(c, statusCode, code, message, nil)
+}
+
+// ErrorResponseWithCodeDetails sends a coded error API response with additional error details
+func ErrorResponseWithCodeDetails(c *gin.Context, statusCode int, code ErrorCode, message string, details interface{}) {
+	c.JSON(statusCode, APIResponse{
+		Success: false,
+		Message: message,
+		Error: APIError{
+			Code:    code,
+			Message: message,
+			Details: details,
+		},
+	})
+}
+
 // ErrorResponseWithDetails sends an error API response with detailed error information
 func ErrorResponseWithDetails(c *gin.Context, statusCode int, message string, errorDetails interface{}) {
 	c.JSON(statusCode, APIResponse{
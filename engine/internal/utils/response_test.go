@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// codedResponse is the body of a coded error response
+type codedResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Error   struct {
+		Code    string          `json:"code"`
+		Message string          `json:"message"`
+		Details json.RawMessage `json:"details"`
+	} `json:"error"`
+}
+
+// respond runs send against a test context and decodes the body
+func respond(t *testing.T, send func(c *gin.Context)) (*httptest.ResponseRecorder, codedResponse) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	send(c)
+
+	var body codedResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %s: %v", recorder.Body.String(), err)
+	}
+	return recorder, body
+}
+
+func TestErrorCodesSerialize(t *testing.T) {
+	for code, want := range map[ErrorCode]string{
+		CodeValidationError: "VALIDATION_ERROR",
+		CodeUnauthorized:    "UNAUTHORIZED",
+		CodeForbidden:       "FORBIDDEN",
+		CodeNotFound:        "NOT_FOUND",
+		CodeConflict:        "CONFLICT",
+		CodeRateLimited:     "RATE_LIMITED",
+		CodeUpstreamError:   "UPSTREAM_ERROR",
+		CodeInternalError:   "INTERNAL_ERROR",
+		CodeNotImplemented:  "NOT_IMPLEMENTED",
+	} {
+		recorder, body := respond(t, func(c *gin.Context) {
+			ErrorResponseWithCode(c, http.StatusTeapot, code, "Something went wrong")
+		})
+
+		if recorder.Code != http.StatusTeapot {
+			t.Errorf("%s: status %d, want %d", want, recorder.Code, http.StatusTeapot)
+		}
+		if body.Success || body.Error.Code != want || body.Error.Message != "Something went wrong" {
+			t.Errorf("%s: body %s", want, recorder.Body.String())
+		}
+		if body.Error.Details != nil {
+			t.Errorf("%s: details %s present without being given", want, body.Error.Details)
+		}
+	}
+}
+
+func TestErrorResponseCarriesDetails(t *testing.T) {
+	recorder, body := respond(t, func(c *gin.Context) {
+		ErrorResponseWithCodeDetails(c, http.StatusBadRequest, CodeValidationError, "Invalid input", map[string]string{"value": "required"})
+	})
+
+	if body.Success || body.Error.Code != "VALIDATION_ERROR" || body.Message != "Invalid input" {
+		t.Errorf("body %s", recorder.Body.String())
+	}
+	if string(body.Error.Details) != `{"value":"required"}` {
+		t.Errorf("details = %s, want the field errors", body.Error.Details)
+	}
+}
@@ -2,20 +2,44 @@ package vectordb
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/pinecone-io/go-pinecone/pinecone"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"health-dashboard-backend/internal/config"
 	"health-dashboard-backend/internal/models"
+	"health-dashboard-backend/pkg/retry"
 )
 
+// ErrIndexDimensionMismatch is returned when the Pinecone index dimension differs from the embedding model's
+var ErrIndexDimensionMismatch = errors.New("index dimension mismatch")
+
 // PineconeClient wraps the official Pinecone Go SDK
 type PineconeClient struct {
 	client          *pinecone.Client
-	indexConnection *pinecone.IndexConnection
 	indexName       string
+	upsertBatchSize int
+	retryAttempts   int           // Tries for upserts that fail with transient errors
+	retryBackoff    time.Duration // Wait before the first retry
+
+	namespace        string // Base namespace from config; "" is Pinecone's default namespace
+	namespacePerUser bool   // Give each user their own namespace under the base one
+
+	connMu          sync.Mutex
+	indexConnection *pinecone.IndexConnection // The one gRPC connection, in the default namespace
+
+	dimensionMu sync.Mutex
+	dimension   int // Cached index dimension; an index's dimension can't change after creation
+
+	logger *zap.Logger
 }
 
 // Vector represents a vector with metadata
@@ -28,6 +52,12 @@ type Vector struct {
 // VectorMetadata represents metadata for a vector
 type VectorMetadata map[string]interface{}
 
+// Vector types stored in the "type" metadata field
+const (
+	VectorTypeDocumentChunk  = "document_chunk"
+	VectorTypeHealthSnapshot = "health_snapshot"
+)
+
 // QueryResponse represents a query response from Pinecone
 type QueryResponse struct {
 	Results []QueryResult
@@ -40,6 +70,16 @@ type QueryResult struct {
 	Metadata VectorMetadata
 }
 
+// retryableGRPCError marks errors with transient gRPC status codes, such as rate limiting and
+// unavailability, as retryable; invalid requests are returned unchanged
+func retryableGRPCError(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+		return retry.Retryable(err)
+	}
+	return err
+}
+
 // NewPineconeClient creates a new Pinecone client using the official SDK
 func NewPineconeClient(cfg *config.Config) (*PineconeClient, error) {
 	clientParams := pinecone.NewClientParams{
@@ -52,37 +92,99 @@ func NewPineconeClient(cfg *config.Config) (*PineconeClient, error) {
 	}
 
 	return &PineconeClient{
-		client:    client,
-		indexName: cfg.PineconeIndexName,
+		client:           client,
+		indexName:        cfg.PineconeIndexName,
+		upsertBatchSize:  cfg.PineconeUpsertBatchSize,
+		retryAttempts:    cfg.RetryAttempts,
+		retryBackoff:     time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
+		namespace:        cfg.PineconeNamespace,
+		namespacePerUser: cfg.PineconeNamespacePerUser,
+		logger:           zap.NewNop(),
 	}, nil
 }
 
-// ConnectToIndex connects to the Pinecone index
+// SetLogger sets the logger for upsert diagnostics; defaults to a no-op logger
+func (p *PineconeClient) SetLogger(logger *zap.Logger) {
+	p.logger = logger
+}
+
+// ConnectToIndex opens the connection to the Pinecone index if it isn't already open
 func (p *PineconeClient) ConnectToIndex(ctx context.Context) error {
+	_, err := p.connection(ctx)
+	return err
+}
+
+// Close closes the connection to the index
+func (p *PineconeClient) Close() error {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if p.indexConnection == nil {
+		return nil
+	}
+	err := p.indexConnection.Close()
+	p.indexConnection = nil
+	return err
+}
+
+// Namespace returns the namespace a user's vectors are stored in
+func (p *PineconeClient) Namespace(userID string) string {
+	if !p.namespacePerUser || userID == "" {
+		return p.namespace
+	}
+	if p.namespace == "" {
+		return userID
+	}
+	return p.namespace + "-" + userID
+}
+
+// connection returns the connection to the index, opening it on first use
+func (p *PineconeClient) connection(ctx context.Context) (*pinecone.IndexConnection, error) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if p.indexConnection != nil {
+		return p.indexConnection, nil
+	}
+
 	// Get index details
 	idx, err := p.client.DescribeIndex(ctx, p.indexName)
 	if err != nil {
-		return fmt.Errorf("failed to describe index: %w", err)
+		return nil, fmt.Errorf("failed to describe index: %w", err)
 	}
 
 	// Connect to index
-	indexConnection, err := p.client.Index(pinecone.NewIndexConnParams{
-		Host: idx.Host,
-	})
+	conn, err := p.client.Index(pinecone.NewIndexConnParams{Host: idx.Host})
 	if err != nil {
-		return fmt.Errorf("failed to connect to index: %w", err)
+		return nil, fmt.Errorf("failed to connect to index: %w", err)
 	}
 
-	p.indexConnection = indexConnection
-	return nil
+	p.indexConnection = conn
+	return conn, nil
 }
 
-// UpsertVectors upserts vectors to the Pinecone index
-func (p *PineconeClient) UpsertVectors(ctx context.Context, vectors []Vector) error {
-	if p.indexConnection == nil {
-		if err := p.ConnectToIndex(ctx); err != nil {
-			return err
-		}
+// namespaced returns the connection to the index scoped to namespace
+func (p *PineconeClient) namespaced(ctx context.Context, namespace string) (*pinecone.IndexConnection, error) {
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return inNamespace(conn, namespace), nil
+}
+
+// inNamespace returns a copy of conn whose requests go to namespace. The SDK reads Namespace on
+// each request, so the copy shares conn's gRPC connection instead of opening another.
+func inNamespace(conn *pinecone.IndexConnection, namespace string) *pinecone.IndexConnection {
+	scoped := *conn
+	scoped.Namespace = namespace
+	return &scoped
+}
+
+// UpsertVectors upserts vectors to the Pinecone index in the given namespace
+func (p *PineconeClient) UpsertVectors(ctx context.Context, namespace string, vectors []Vector) error {
+	conn, err := p.namespaced(ctx, namespace)
+	if err != nil {
+		return err
 	}
 
 	if len(vectors) == 0 {
@@ -97,8 +199,9 @@ func (p *PineconeClient) UpsertVectors(ctx context.Context, vectors []Vector) er
 
 	// Log vector dimensions for debugging
 	firstVectorDim := len(vectors[0].Values)
-	fmt.Printf("DEBUG: First vector dimension: %d\n", firstVectorDim)
-	fmt.Printf("DEBUG: Index stats: %+v\n", stats)
+	p.logger.Debug("Validating vectors for upsert",
+		zap.Int("vector_dimension", firstVectorDim),
+		zap.Any("index_stats", stats))
 
 	// Validate all vectors have the same dimension
 	for i, v := range vectors {
@@ -126,31 +229,59 @@ func (p *PineconeClient) UpsertVectors(ctx context.Context, vectors []Vector) er
 		}
 	}
 
-	fmt.Printf("DEBUG: Upserting %d vectors to Pinecone\n", len(pineconeVectors))
+	p.logger.Debug("Upserting vectors to Pinecone",
+		zap.Int("vectors", len(pineconeVectors)),
+		zap.Int("batch_size", p.upsertBatchSize))
+
+	// Send batches sequentially, carrying on past a failed batch so one bad request doesn't
+	// drop the rest of the document
+	var upserted uint32
+	var failures []error
+	failedVectors := 0
+	for _, batch := range batchVectors(pineconeVectors, p.upsertBatchSize) {
+		var res uint32
+		err := retry.Do(ctx, p.retryAttempts, p.retryBackoff, func() error {
+			var err error
+			res, err = conn.UpsertVectors(ctx, batch)
+			return retryableGRPCError(err)
+		})
+		if err != nil {
+			failures = append(failures, fmt.Errorf("batch starting at %s: %w", batch[0].Id, err))
+			failedVectors += len(batch)
+			continue
+		}
+		upserted += res
+	}
 
-	res, err := p.indexConnection.UpsertVectors(ctx, pineconeVectors)
+	p.logger.Debug("Upsert completed", zap.Uint32("upserted", upserted))
 
-	fmt.Printf("DEBUG: Upsert response: %+v\n", res)
-	if err != nil {
-		return fmt.Errorf("failed to upsert vectors: %w", err)
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to upsert %d of %d vectors in %d batch(es): %w",
+			failedVectors, len(pineconeVectors), len(failures), errors.Join(failures...))
 	}
 
-	// Verify the upsert was successful
-	if res > 0 {
-		fmt.Printf("DEBUG: Upsert completed successfully, upserted count: %d\n", res)
-	} else {
-		fmt.Println("WARNING: Upsert response is 0, this might indicate an issue")
+	if upserted == 0 {
+		p.logger.Warn("Pinecone reported 0 vectors upserted", zap.Int("vectors", len(pineconeVectors)))
 	}
 
 	return nil
 }
 
-// QueryVectors queries the Pinecone index for similar vectors
-func (p *PineconeClient) QueryVectors(ctx context.Context, queryVector []float32, topK int, filter VectorMetadata) (*QueryResponse, error) {
-	if p.indexConnection == nil {
-		if err := p.ConnectToIndex(ctx); err != nil {
-			return nil, err
-		}
+// batchVectors splits vectors into consecutive batches of at most size vectors
+func batchVectors(vectors []*pinecone.Vector, size int) [][]*pinecone.Vector {
+	batches := make([][]*pinecone.Vector, 0, (len(vectors)+size-1)/size)
+	for start := 0; start < len(vectors); start += size {
+		end := min(start+size, len(vectors))
+		batches = append(batches, vectors[start:end])
+	}
+	return batches
+}
+
+// QueryVectors queries the given namespace of the Pinecone index for similar vectors
+func (p *PineconeClient) QueryVectors(ctx context.Context, namespace string, queryVector []float32, topK int, filter VectorMetadata) (*QueryResponse, error) {
+	conn, err := p.namespaced(ctx, namespace)
+	if err != nil {
+		return nil, err
 	}
 
 	// Convert filter to structpb.Struct if provided
@@ -164,7 +295,7 @@ func (p *PineconeClient) QueryVectors(ctx context.Context, queryVector []float32
 	}
 
 	// Query the index
-	response, err := p.indexConnection.QueryByVectorValues(ctx, &pinecone.QueryByVectorValuesRequest{
+	response, err := conn.QueryByVectorValues(ctx, &pinecone.QueryByVectorValuesRequest{
 		Vector:          queryVector,
 		TopK:            uint32(topK),
 		MetadataFilter:  metadataFilter,
@@ -195,12 +326,11 @@ func (p *PineconeClient) QueryVectors(ctx context.Context, queryVector []float32
 	}, nil
 }
 
-// DeleteVectorsByFilter deletes vectors matching a filter
-func (p *PineconeClient) DeleteVectorsByFilter(ctx context.Context, filter VectorMetadata) error {
-	if p.indexConnection == nil {
-		if err := p.ConnectToIndex(ctx); err != nil {
-			return err
-		}
+// DeleteVectorsByFilter deletes vectors matching a filter in the given namespace
+func (p *PineconeClient) DeleteVectorsByFilter(ctx context.Context, namespace string, filter VectorMetadata) error {
+	conn, err := p.namespaced(ctx, namespace)
+	if err != nil {
+		return err
 	}
 
 	// Convert filter to structpb.Struct
@@ -209,7 +339,7 @@ func (p *PineconeClient) DeleteVectorsByFilter(ctx context.Context, filter Vecto
 		return fmt.Errorf("failed to convert filter: %w", err)
 	}
 
-	err = p.indexConnection.DeleteVectorsByFilter(ctx, metadataFilter)
+	err = conn.DeleteVectorsByFilter(ctx, metadataFilter)
 	if err != nil {
 		return fmt.Errorf("failed to delete vectors by filter: %w", err)
 	}
@@ -219,13 +349,12 @@ func (p *PineconeClient) DeleteVectorsByFilter(ctx context.Context, filter Vecto
 
 // GetIndexStats returns statistics about the index
 func (p *PineconeClient) GetIndexStats(ctx context.Context) (interface{}, error) {
-	if p.indexConnection == nil {
-		if err := p.ConnectToIndex(ctx); err != nil {
-			return nil, err
-		}
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	stats, err := p.indexConnection.DescribeIndexStats(ctx)
+	stats, err := conn.DescribeIndexStats(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get index stats: %w", err)
 	}
@@ -233,6 +362,57 @@ func (p *PineconeClient) GetIndexStats(ctx context.Context) (interface{}, error)
 	return stats, nil
 }
 
+// IndexDimension returns the dimension of the connected index, fetching it once and caching it
+func (p *PineconeClient) IndexDimension(ctx context.Context) (int, error) {
+	p.dimensionMu.Lock()
+	defer p.dimensionMu.Unlock()
+
+	if p.dimension > 0 {
+		return p.dimension, nil
+	}
+
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	stats, err := conn.DescribeIndexStats(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get index stats: %w", err)
+	}
+
+	p.dimension = int(stats.Dimension)
+	return p.dimension, nil
+}
+
+// GetIndexSummary returns the index dimension, fullness and per-namespace vector counts
+func (p *PineconeClient) GetIndexSummary(ctx context.Context) (map[string]interface{}, error) {
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := conn.DescribeIndexStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index stats: %w", err)
+	}
+
+	namespaces := make(map[string]uint32, len(stats.Namespaces))
+	for name, summary := range stats.Namespaces {
+		if summary != nil {
+			namespaces[name] = summary.VectorCount
+		}
+	}
+
+	return map[string]interface{}{
+		"index_name":         p.indexName,
+		"dimension":          stats.Dimension,
+		"index_fullness":     stats.IndexFullness,
+		"total_vector_count": stats.TotalVectorCount,
+		"namespaces":         namespaces,
+	}, nil
+}
+
 // Helper functions for creating vectors and filters
 
 // CreateVectorFromChunk creates a vector from a document chunk
@@ -243,6 +423,7 @@ func CreateVectorFromChunk(chunk *models.DocumentChunk) *Vector {
 		"content":     chunk.Content,
 		"user_id":     chunk.UserID,
 		"chunk_index": chunk.ChunkIndex,
+		"type":        VectorTypeDocumentChunk,
 	}
 
 	// Add custom metadata from the chunk
@@ -250,6 +431,11 @@ func CreateVectorFromChunk(chunk *models.DocumentChunk) *Vector {
 		metadata[k] = v
 	}
 
+	// Store the page number as a number so it can be filtered on
+	if page, err := strconv.Atoi(chunk.Metadata["page_number"]); err == nil {
+		metadata["page_number"] = page
+	}
+
 	return &Vector{
 		ID:       chunk.ChunkID,
 		Values:   chunk.Embedding,
@@ -272,6 +458,31 @@ func FilterByDocument(userID, documentID string) VectorMetadata {
 	}
 }
 
+// FilterByDocumentChunkRange creates a filter for a document's chunks with chunk_index in [from, to)
+func FilterByDocumentChunkRange(userID, documentID string, from, to int) VectorMetadata {
+	return VectorMetadata{
+		"user_id":     userID,
+		"document_id": documentID,
+		"chunk_index": map[string]interface{}{"$gte": from, "$lt": to},
+	}
+}
+
+// FilterByUserAndType creates a filter for a specific user and vector type
+func FilterByUserAndType(userID, vectorType string) VectorMetadata {
+	return VectorMetadata{
+		"user_id": userID,
+		"type":    vectorType,
+	}
+}
+
+// FilterByUserExcludingType creates a filter for a specific user that excludes a vector type
+func FilterByUserExcludingType(userID, vectorType string) VectorMetadata {
+	return VectorMetadata{
+		"user_id": userID,
+		"type":    map[string]interface{}{"$ne": vectorType},
+	}
+}
+
 // FilterByDocumentType creates a filter for a specific document type
 func FilterByDocumentType(userID, docType string) VectorMetadata {
 	return VectorMetadata{
@@ -289,16 +500,23 @@ func (p *PineconeClient) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
-// ValidateIndexConfiguration validates that the index configuration matches expected dimensions
+// ValidateIndexConfiguration checks that the index's declared dimension matches expectedDimensions,
+// returning ErrIndexDimensionMismatch when it doesn't. An expected dimension of 0 skips the check.
 func (p *PineconeClient) ValidateIndexConfiguration(ctx context.Context, expectedDimensions int) error {
+	if expectedDimensions <= 0 {
+		return nil
+	}
+
 	// Get index details
 	idx, err := p.client.DescribeIndex(ctx, p.indexName)
 	if err != nil {
 		return fmt.Errorf("failed to describe index: %w", err)
 	}
 
-	fmt.Printf("DEBUG: Index '%s' details: %+v\n", p.indexName, idx)
-	fmt.Printf("INFO: Expected dimensions: %d\n", expectedDimensions)
-	fmt.Printf("INFO: Index configuration check completed\n")
+	if int(idx.Dimension) != expectedDimensions {
+		return fmt.Errorf("%w: index '%s' has dimension %d but the embedding model produces %d; recreate the index or change the embedding model",
+			ErrIndexDimensionMismatch, p.indexName, idx.Dimension, expectedDimensions)
+	}
+
 	return nil
 }
@@ -0,0 +1,124 @@
+package vectordb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pinecone-io/go-pinecone/pinecone"
+
+	"health-dashboard-backend/internal/config"
+)
+
+func TestBatchVectorsSplitsInOrder(t *testing.T) {
+	for _, tc := range []struct {
+		count, size int
+		want        []int
+	}{
+		{250, 100, []int{100, 100, 50}},
+		{200, 100, []int{100, 100}},
+		{3, 100, []int{3}},
+		{5, 1, []int{1, 1, 1, 1, 1}},
+	} {
+		vectors := make([]*pinecone.Vector, tc.count)
+		for i := range vectors {
+			vectors[i] = &pinecone.Vector{Id: fmt.Sprintf("v%d", i)}
+		}
+
+		batches := batchVectors(vectors, tc.size)
+		if len(batches) != len(tc.want) {
+			t.Fatalf("%d vectors in batches of %d: got %d batches, want %d", tc.count, tc.size, len(batches), len(tc.want))
+		}
+
+		next := 0
+		for i, batch := range batches {
+			if len(batch) != tc.want[i] {
+				t.Errorf("%d vectors in batches of %d: batch %d has %d vectors, want %d", tc.count, tc.size, i, len(batch), tc.want[i])
+			}
+			for _, vector := range batch {
+				if vector.Id != fmt.Sprintf("v%d", next) {
+					t.Fatalf("%d vectors in batches of %d: got %s, want v%d", tc.count, tc.size, vector.Id, next)
+				}
+				next++
+			}
+		}
+	}
+}
+
+func TestNamespacedConnectionsShareOneConnection(t *testing.T) {
+	p, err := NewPineconeClient(&config.Config{PineconeAPIKey: "test-key", PineconeNamespace: "health", PineconeNamespacePerUser: true})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	// gRPC connects lazily, so no server is needed until a request is sent
+	p.indexConnection, err = p.client.Index(pinecone.NewIndexConnParams{Host: "localhost:5081"})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	base := p.indexConnection
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		conn, err := p.namespaced(context.Background(), p.Namespace(userID))
+		if err != nil {
+			t.Fatalf("namespaced: %v", err)
+		}
+		if conn.Namespace != "health-"+userID {
+			t.Errorf("namespace = %q, want health-%s", conn.Namespace, userID)
+		}
+	}
+	if p.indexConnection != base || base.Namespace != "" {
+		t.Errorf("base connection replaced or rescoped to %q", base.Namespace)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if p.indexConnection != nil {
+		t.Error("connection kept after Close")
+	}
+}
+
+// describeIndexServer serves a Pinecone control plane whose index has the given dimension
+func describeIndexServer(t *testing.T, dimension int) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/indexes/health-documents" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name":"health-documents","dimension":%d,"metric":"cosine","host":"localhost:5081",`+
+			`"spec":{"serverless":{"cloud":"aws","region":"us-east-1"}},"status":{"ready":true,"state":"Ready"}}`, dimension)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestValidateIndexConfigurationComparesDimensions(t *testing.T) {
+	p, err := NewPineconeClient(&config.Config{PineconeAPIKey: "test-key", PineconeIndexName: "health-documents"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	p.client, err = pinecone.NewClient(pinecone.NewClientParams{ApiKey: "test-key", Host: describeIndexServer(t, 1536)})
+	if err != nil {
+		t.Fatalf("new SDK client: %v", err)
+	}
+
+	if err := p.ValidateIndexConfiguration(context.Background(), 1536); err != nil {
+		t.Errorf("matching dimension: %v, want nil", err)
+	}
+
+	err = p.ValidateIndexConfiguration(context.Background(), 3072)
+	if !errors.Is(err, ErrIndexDimensionMismatch) {
+		t.Fatalf("mismatched dimension: %v, want ErrIndexDimensionMismatch", err)
+	}
+	if !strings.Contains(err.Error(), "1536") || !strings.Contains(err.Error(), "3072") {
+		t.Errorf("error %q should name both dimensions", err)
+	}
+}
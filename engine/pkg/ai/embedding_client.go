@@ -7,4 +7,6 @@ import (
 // EmbeddingClient interface for different embedding providers
 type EmbeddingClient interface {
 	GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
+	// GenerateEmbeddings embeds several texts in one request, returning embeddings in input order
+	GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
 }
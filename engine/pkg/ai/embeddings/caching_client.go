@@ -0,0 +1,134 @@
+package embeddings
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"health-dashboard-backend/pkg/ai"
+)
+
+// cacheEntry is one cached embedding in the LRU list
+type cacheEntry struct {
+	key       [sha256.Size]byte
+	embedding []float32
+}
+
+// CachingEmbeddingClient wraps an EmbeddingClient with an in-memory LRU cache keyed by the SHA-256
+// of the input text, so retried documents and repeated queries don't pay for the same embedding
+// twice. Cached embeddings are shared between callers and must not be modified.
+type CachingEmbeddingClient struct {
+	client  ai.EmbeddingClient
+	size    int
+	mu      sync.Mutex
+	order   *list.List // Most recently used at the front
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+// NewCachingEmbeddingClient wraps client with a cache holding up to size embeddings
+func NewCachingEmbeddingClient(client ai.EmbeddingClient, size int) *CachingEmbeddingClient {
+	if size < 1 {
+		size = 1
+	}
+
+	return &CachingEmbeddingClient{
+		client:  client,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// Unwrap returns the underlying client, for callers that must bypass the cache
+func (c *CachingEmbeddingClient) Unwrap() ai.EmbeddingClient {
+	return c.client
+}
+
+// GenerateEmbedding returns the cached embedding for text or generates and caches it
+func (c *CachingEmbeddingClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	key := sha256.Sum256([]byte(text))
+	if embedding, ok := c.get(key); ok {
+		return embedding, nil
+	}
+
+	embedding, err := c.client.GenerateEmbedding(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	c.put(key, embedding)
+	return embedding, nil
+}
+
+// GenerateEmbeddings serves cached texts from the cache and generates the rest in one batch request
+func (c *CachingEmbeddingClient) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	keys := make([][sha256.Size]byte, len(texts))
+
+	var missing []string
+	var missingIdx []int
+	for i, text := range texts {
+		keys[i] = sha256.Sum256([]byte(text))
+		if embedding, ok := c.get(keys[i]); ok {
+			embeddings[i] = embedding
+			continue
+		}
+		missing = append(missing, text)
+		missingIdx = append(missingIdx, i)
+	}
+
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	generated, err := c.client.GenerateEmbeddings(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, embedding := range generated {
+		if j >= len(missingIdx) {
+			break
+		}
+		i := missingIdx[j]
+		embeddings[i] = embedding
+		c.put(keys[i], embedding)
+	}
+
+	return embeddings, nil
+}
+
+// get looks up an embedding and marks it as recently used
+func (c *CachingEmbeddingClient) get(key [sha256.Size]byte) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).embedding, true
+}
+
+// put stores an embedding, evicting the least recently used one when the cache is full
+func (c *CachingEmbeddingClient) put(key [sha256.Size]byte, embedding []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.entries[key]; exists {
+		element.Value.(*cacheEntry).embedding = embedding
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, embedding: embedding})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
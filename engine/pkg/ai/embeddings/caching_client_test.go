@@ -0,0 +1,88 @@
+package embeddings
+
+import (
+	"context"
+	"testing"
+)
+
+// countingClient embeds each text as its length and counts the texts it is asked to embed
+type countingClient struct {
+	embedded []string
+	batches  int
+}
+
+func (c *countingClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	c.embedded = append(c.embedded, text)
+	return []float32{float32(len(text))}, nil
+}
+
+func (c *countingClient) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	c.batches++
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i], _ = c.GenerateEmbedding(ctx, text)
+	}
+	return embeddings, nil
+}
+
+func TestCachingEmbeddingClientServesRepeatsFromCache(t *testing.T) {
+	inner := &countingClient{}
+	client := NewCachingEmbeddingClient(inner, 10)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		embedding, err := client.GenerateEmbedding(ctx, "resting heart rate")
+		if err != nil {
+			t.Fatalf("generate embedding: %v", err)
+		}
+		if embedding[0] != 18 {
+			t.Errorf("embedding = %v, want [18]", embedding)
+		}
+	}
+	if len(inner.embedded) != 1 {
+		t.Fatalf("underlying client embedded %d texts, want 1", len(inner.embedded))
+	}
+
+	// Only the new text in a batch goes to the underlying client, and results keep input order
+	embeddings, err := client.GenerateEmbeddings(ctx, []string{"resting heart rate", "ldl", "resting heart rate"})
+	if err != nil {
+		t.Fatalf("generate embeddings: %v", err)
+	}
+	if embeddings[0][0] != 18 || embeddings[1][0] != 3 || embeddings[2][0] != 18 {
+		t.Errorf("embeddings = %v, want [18] [3] [18]", embeddings)
+	}
+	if len(inner.embedded) != 2 || inner.embedded[1] != "ldl" || inner.batches != 1 {
+		t.Errorf("underlying client embedded %q in %d batches, want only ldl in one", inner.embedded, inner.batches)
+	}
+
+	if _, err := client.GenerateEmbeddings(ctx, []string{"ldl"}); err != nil {
+		t.Fatalf("generate embeddings: %v", err)
+	}
+	if inner.batches != 1 {
+		t.Error("fully cached batch reached the underlying client")
+	}
+}
+
+func TestCachingEmbeddingClientEvictsLeastRecentlyUsed(t *testing.T) {
+	inner := &countingClient{}
+	client := NewCachingEmbeddingClient(inner, 2)
+	ctx := context.Background()
+
+	for _, text := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := client.GenerateEmbedding(ctx, text); err != nil {
+			t.Fatalf("generate embedding: %v", err)
+		}
+	}
+
+	// "a" stays cached as it keeps being used; "b" is evicted by "c" and embedded again
+	want := []string{"a", "b", "c", "b"}
+	if len(inner.embedded) != len(want) {
+		t.Fatalf("underlying client embedded %q, want %q", inner.embedded, want)
+	}
+	for i := range want {
+		if inner.embedded[i] != want[i] {
+			t.Errorf("underlying client embedded %q, want %q", inner.embedded, want)
+			break
+		}
+	}
+}
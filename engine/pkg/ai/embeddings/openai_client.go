@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/pkg/ai"
+	"health-dashboard-backend/pkg/retry"
 )
 
 // OpenAIClient implements EmbeddingClient for OpenAI's API
@@ -15,6 +18,22 @@ type OpenAIClient struct {
 	apiKey string
 	model  string
 	client *http.Client
+
+	retryAttempts int           // Tries for requests that fail with rate limiting, 5xx or network errors
+	retryBackoff  time.Duration // Wait before the first retry
+}
+
+// modelDimensions holds the vector dimension produced by each known OpenAI embedding model
+var modelDimensions = map[string]int{
+	"text-embedding-ada-002": 1536,
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+}
+
+// ModelDimension returns the vector dimension of a known embedding model
+func ModelDimension(model string) (int, bool) {
+	dimension, known := modelDimensions[model]
+	return dimension, known
 }
 
 // NewOpenAIClient creates a new OpenAI client for embeddings
@@ -31,36 +50,56 @@ func NewOpenAIClient(cfg *config.Config) (*OpenAIClient, error) {
 	// Log the model being used for debugging
 	fmt.Printf("DEBUG: Using embedding model: %s\n", model)
 
-	// Warn about dimension mismatches
-	switch model {
-	case "text-embedding-ada-002", "text-embedding-3-small":
-		fmt.Printf("WARNING: Model %s produces 1536-dimensional vectors. Ensure your Pinecone index is configured for 1536 dimensions.\n", model)
-	case "text-embedding-3-large":
-		fmt.Printf("INFO: Model %s produces 3072-dimensional vectors. Ensure your Pinecone index is configured for 3072 dimensions.\n", model)
-	default:
+	// Unknown models can't have their dimension checked against the Pinecone index at startup
+	if _, known := ModelDimension(model); !known {
 		fmt.Printf("WARNING: Unknown embedding model %s. Please verify the dimensions match your Pinecone index.\n", model)
 	}
 
+	client, _ := ai.NewHTTPClients(time.Duration(cfg.ProviderTimeoutSeconds) * time.Second)
+
 	return &OpenAIClient{
 		apiKey: cfg.OpenAIAPIKey,
 		model:  model,
-		client: &http.Client{},
+		client: client,
+
+		retryAttempts: cfg.RetryAttempts,
+		retryBackoff:  time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
 	}, nil
 }
 
+// SetTransport replaces the HTTP transport used for requests, e.g. to route through a proxy
+func (c *OpenAIClient) SetTransport(transport http.RoundTripper) {
+	c.client.Transport = transport
+}
+
 // GenerateEmbedding generates an embedding using OpenAI API
 func (c *OpenAIClient) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
-	requestBody := map[string]interface{}{
-		"model": c.model,
-		"input": text,
+	embeddings, err := c.createEmbeddings(ctx, text, 1)
+	if err != nil {
+		return nil, err
 	}
 
-	jsonData, err := json.Marshal(requestBody)
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings generates embeddings for several texts in a single OpenAI API request
+func (c *OpenAIClient) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	embeddings, err := c.createEmbeddings(ctx, texts, len(texts))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/embeddings", bytes.NewBuffer(jsonData))
+	return embeddings, nil
+}
+
+// send posts a request to the embeddings endpoint. Rate limiting, server errors and network
+// failures are marked retryable; other error statuses, such as invalid input, are not.
+func (c *OpenAIClient) send(ctx context.Context, jsonData []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/embeddings", bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -70,16 +109,50 @@ func (c *OpenAIClient) GenerateEmbedding(ctx context.Context, text string) ([]fl
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		return nil, retry.Retryable(fmt.Errorf("failed to send request: %w", err))
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+		resp.Body.Close()
+		err := fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return nil, retry.Retryable(err)
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// createEmbeddings calls the embeddings endpoint with a single string or an array of strings as
+// input and returns the embeddings in input order
+func (c *OpenAIClient) createEmbeddings(ctx context.Context, input interface{}, count int) ([][]float32, error) {
+	requestBody := map[string]interface{}{
+		"model": c.model,
+		"input": input,
 	}
 
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var resp *http.Response
+	err = retry.Do(ctx, c.retryAttempts, c.retryBackoff, func() error {
+		resp, err = c.send(ctx, jsonData)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
 	var response struct {
 		Data []struct {
+			Index     int       `json:"index"`
 			Embedding []float32 `json:"embedding"`
 		} `json:"data"`
 		Usage struct {
@@ -94,9 +167,18 @@ func (c *OpenAIClient) GenerateEmbedding(ctx context.Context, text string) ([]fl
 	if len(response.Data) == 0 {
 		return nil, fmt.Errorf("no embedding data returned from OpenAI API")
 	}
+	if len(response.Data) != count {
+		return nil, fmt.Errorf("expected %d embeddings from OpenAI API, got %d", count, len(response.Data))
+	}
 
-	embedding := response.Data[0].Embedding
-	fmt.Printf("DEBUG: Generated embedding with %d dimensions using model %s\n", len(embedding), c.model)
+	// The API tags each embedding with its input index; don't rely on response order
+	embeddings := make([][]float32, count)
+	for _, data := range response.Data {
+		if data.Index < 0 || data.Index >= count || embeddings[data.Index] != nil {
+			return nil, fmt.Errorf("unexpected embedding index %d in OpenAI API response", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
 
-	return embedding, nil
+	return embeddings, nil
 }
@@ -0,0 +1,91 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"health-dashboard-backend/internal/config"
+)
+
+// redirectTransport sends every request to a test server instead of OpenAI
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestOpenAIClient returns a client whose requests are answered by handler
+func newTestOpenAIClient(t *testing.T, handler http.HandlerFunc) *OpenAIClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse test server URL: %v", err)
+	}
+
+	client, err := NewOpenAIClient(&config.Config{OpenAIAPIKey: "test-key", EmbeddingModel: "text-embedding-3-small", RetryAttempts: 1})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	client.SetTransport(&redirectTransport{target: target})
+	return client
+}
+
+func TestGenerateEmbeddingsSendsOneBatchedRequest(t *testing.T) {
+	requests := 0
+	client := newTestOpenAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		var body struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		// Answer in reverse order; the client must place embeddings by index
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var data []datum
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			data = append(data, datum{Index: i, Embedding: []float32{float32(len(body.Input[i]))}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	})
+
+	embeddings, err := client.GenerateEmbeddings(context.Background(), []string{"a", "bb", "ccc"})
+	if err != nil {
+		t.Fatalf("generate embeddings: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("sent %d requests, want 1", requests)
+	}
+	for i, embedding := range embeddings {
+		if len(embedding) != 1 || embedding[0] != float32(i+1) {
+			t.Errorf("embedding %d = %v, want [%d]", i, embedding, i+1)
+		}
+	}
+}
+
+func TestGenerateEmbeddingsRejectsMissingEmbeddings(t *testing.T) {
+	client := newTestOpenAIClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[1]}]}`))
+	})
+
+	if _, err := client.GenerateEmbeddings(context.Background(), []string{"a", "b"}); err == nil {
+		t.Error("two texts answered with one embedding, want an error")
+	}
+}
@@ -0,0 +1,25 @@
+package ai
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultRequestTimeout bounds a provider request when no timeout is configured
+const DefaultRequestTimeout = 60 * time.Second
+
+// NewHTTPClients returns the HTTP clients used for provider requests. client bounds a whole request
+// by timeout, so calls made with a context that has no deadline (such as background document
+// processing) can't hang on an unresponsive upstream. streamClient only bounds the wait for the
+// response headers, so long streamed answers aren't cut off; the caller's context still cancels
+// both. The clients share one transport.
+func NewHTTPClients(timeout time.Duration) (client, streamClient *http.Client) {
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+
+	return &http.Client{Transport: transport, Timeout: timeout}, &http.Client{Transport: transport}
+}
@@ -0,0 +1,57 @@
+package ai
+
+import "strings"
+
+// SupportedLanguages maps ISO 639-1 language codes to their English names
+var SupportedLanguages = map[string]string{
+	"ar": "Arabic",
+	"bn": "Bengali",
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"hi": "Hindi",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"sv": "Swedish",
+	"ta": "Tamil",
+	"te": "Telugu",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// NormalizeLanguage validates a language code such as "es" or "pt-BR" and returns
+// its normalized base code. The second return value is false for unsupported codes.
+func NormalizeLanguage(code string) (string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return "", false
+	}
+
+	// Accept region subtags (pt-BR, zh_TW) but match on the base language
+	if idx := strings.IndexAny(code, "-_"); idx > 0 {
+		code = code[:idx]
+	}
+
+	if _, exists := SupportedLanguages[code]; !exists {
+		return "", false
+	}
+
+	return code, true
+}
+
+// LanguageName returns the English name for a supported language code
+func LanguageName(code string) string {
+	if name, exists := SupportedLanguages[code]; exists {
+		return name
+	}
+	return code
+}
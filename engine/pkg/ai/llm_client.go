@@ -6,10 +6,34 @@ import (
 
 // LLMClient interface for different LLM providers
 type LLMClient interface {
-	GenerateResponse(ctx context.Context, messages []ChatMessage, maxTokens int, temperature float32) (*ChatResponse, error)
+	GenerateResponse(ctx context.Context, messages []ChatMessage, opts GenerateOptions) (*ChatResponse, error)
+	GenerateResponseStream(ctx context.Context, messages []ChatMessage, opts GenerateOptions) (<-chan StreamChunk, error)
 	HealthCheck(ctx context.Context) error
 }
 
+// GenerateOptions controls sampling for a single LLM call.
+//
+// Seed support varies by provider:
+//   - OpenAI honors Seed (best effort; OpenAI does not guarantee identical output across backend changes)
+//   - Anthropic has no seed parameter; Deterministic requests rely on temperature 0 alone
+//   - Sonar has no seed parameter; Deterministic requests rely on temperature 0 alone
+type GenerateOptions struct {
+	MaxTokens     int
+	Temperature   float32
+	Seed          *int64 // Fixed sampling seed, ignored by providers that don't support one
+	Deterministic bool   // Caller wants reproducible output; Temperature is 0 and Seed is set
+}
+
+// DeterministicOptions returns options that force temperature 0 and a fixed seed
+func DeterministicOptions(maxTokens int, seed int64) GenerateOptions {
+	return GenerateOptions{
+		MaxTokens:     maxTokens,
+		Temperature:   0,
+		Seed:          &seed,
+		Deterministic: true,
+	}
+}
+
 // ChatMessage represents a chat message for the LLM
 type ChatMessage struct {
 	Role    string `json:"role"` // "system", "user", "assistant"
@@ -22,3 +46,13 @@ type ChatResponse struct {
 	TokensUsed   int    `json:"tokens_used"`
 	FinishReason string `json:"finish_reason"`
 }
+
+// StreamChunk represents an incremental piece of a streamed LLM response.
+// The final chunk on a stream has Done set; Err is set if the stream failed part-way.
+type StreamChunk struct {
+	Content      string `json:"content"`
+	Done         bool   `json:"done"`
+	TokensUsed   int    `json:"tokens_used,omitempty"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	Err          error  `json:"-"`
+}
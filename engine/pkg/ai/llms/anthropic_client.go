@@ -0,0 +1,282 @@
+package llms
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/pkg/ai"
+)
+
+const (
+	// anthropicAPIURL is the Anthropic Messages API endpoint
+	anthropicAPIURL = "https://api.anthropic.com/v1/messages"
+	// anthropicVersion is the API version sent with every request
+	anthropicVersion = "2023-06-01"
+	// anthropicMaxTemperature is the Messages API's upper temperature bound; other providers
+	// accept up to 2
+	anthropicMaxTemperature = 1
+)
+
+// AnthropicClient implements LLMClient for Anthropic's Messages API
+type AnthropicClient struct {
+	apiKey       string
+	model        string
+	client       *http.Client
+	streamClient *http.Client // Same transport as client, without the whole-request timeout
+}
+
+// anthropicMessage represents a single turn in the Messages API
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// NewAnthropicClient creates a new Anthropic client
+func NewAnthropicClient(cfg *config.Config) (*AnthropicClient, error) {
+	if cfg.AnthropicAPIKey == "" {
+		return nil, fmt.Errorf("Anthropic API key is required")
+	}
+
+	client, streamClient := ai.NewHTTPClients(time.Duration(cfg.ProviderTimeoutSeconds) * time.Second)
+
+	return &AnthropicClient{
+		apiKey:       cfg.AnthropicAPIKey,
+		model:        cfg.AnthropicModel,
+		client:       client,
+		streamClient: streamClient,
+	}, nil
+}
+
+// SetTransport replaces the HTTP transport used for requests, e.g. to route through a proxy
+func (a *AnthropicClient) SetTransport(transport http.RoundTripper) {
+	a.client.Transport = transport
+	a.streamClient.Transport = transport
+}
+
+// GenerateResponse generates a response using the Anthropic Messages API
+func (a *AnthropicClient) GenerateResponse(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (*ai.ChatResponse, error) {
+	req, err := a.newRequest(ctx, messages, opts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var content strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("no text content returned from Anthropic API")
+	}
+
+	return &ai.ChatResponse{
+		Content:      content.String(),
+		TokensUsed:   response.Usage.InputTokens + response.Usage.OutputTokens,
+		FinishReason: response.StopReason,
+	}, nil
+}
+
+// GenerateResponseStream generates a response using the Anthropic streaming Messages API.
+// Chunks are delivered on the returned channel, which is closed after the final (Done) chunk.
+func (a *AnthropicClient) GenerateResponseStream(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (<-chan ai.StreamChunk, error) {
+	req, err := a.newRequest(ctx, messages, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := a.streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+	}
+
+	chunks := make(chan ai.StreamChunk)
+
+	go func() {
+		defer close(chunks)
+		defer resp.Body.Close()
+
+		var inputTokens, outputTokens int
+		var stopReason string
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				continue // Skip "event:" lines and keep-alives; the type is repeated in the data
+			}
+
+			var event struct {
+				Type    string `json:"type"`
+				Message struct {
+					Usage struct {
+						InputTokens int `json:"input_tokens"`
+					} `json:"usage"`
+				} `json:"message"`
+				Delta struct {
+					Type       string `json:"type"`
+					Text       string `json:"text"`
+					StopReason string `json:"stop_reason"`
+				} `json:"delta"`
+				Usage struct {
+					OutputTokens int `json:"output_tokens"`
+				} `json:"usage"`
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				sendChunk(ctx, chunks, ai.StreamChunk{Done: true, Err: fmt.Errorf("failed to decode stream event: %w", err)})
+				return
+			}
+
+			switch event.Type {
+			case "message_start":
+				inputTokens = event.Message.Usage.InputTokens
+			case "content_block_delta":
+				if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					if !sendChunk(ctx, chunks, ai.StreamChunk{Content: event.Delta.Text}) {
+						return
+					}
+				}
+			case "message_delta":
+				outputTokens = event.Usage.OutputTokens
+				if event.Delta.StopReason != "" {
+					stopReason = event.Delta.StopReason
+				}
+			case "error":
+				sendChunk(ctx, chunks, ai.StreamChunk{Done: true, Err: fmt.Errorf("stream error from Anthropic API: %s", event.Error.Message)})
+				return
+			}
+
+			if event.Type == "message_stop" {
+				break
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			sendChunk(ctx, chunks, ai.StreamChunk{Done: true, Err: fmt.Errorf("failed to read stream: %w", err)})
+			return
+		}
+
+		sendChunk(ctx, chunks, ai.StreamChunk{
+			Done:         true,
+			TokensUsed:   inputTokens + outputTokens,
+			FinishReason: stopReason,
+		})
+	}()
+
+	return chunks, nil
+}
+
+// HealthCheck checks if the Anthropic API is accessible
+func (a *AnthropicClient) HealthCheck(ctx context.Context) error {
+	messages := []ai.ChatMessage{
+		{
+			Role:    "system",
+			Content: "Be precise and concise.",
+		},
+		{
+			Role:    "user",
+			Content: "Hello",
+		},
+	}
+
+	_, err := a.GenerateResponse(ctx, messages, ai.GenerateOptions{MaxTokens: 10, Temperature: 0.7})
+	return err
+}
+
+// newRequest builds a Messages API request. System messages are moved to the
+// top-level "system" field since the API only accepts user/assistant turns.
+// The temperature is clamped to [0, 1], which the API requires.
+// The Messages API has no seed parameter, so opts.Seed is ignored.
+func (a *AnthropicClient) newRequest(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions, stream bool) (*http.Request, error) {
+	var systemPrompts []string
+	turns := make([]anthropicMessage, 0, len(messages))
+
+	for _, message := range messages {
+		if message.Role == "system" {
+			systemPrompts = append(systemPrompts, message.Content)
+			continue
+		}
+		turns = append(turns, anthropicMessage{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+
+	requestBody := map[string]interface{}{
+		"model":       a.model,
+		"messages":    turns,
+		"max_tokens":  opts.MaxTokens,
+		"temperature": min(max(opts.Temperature, 0), anthropicMaxTemperature),
+	}
+
+	if len(systemPrompts) > 0 {
+		requestBody["system"] = strings.Join(systemPrompts, "\n\n")
+	}
+
+	if stream {
+		requestBody["stream"] = true
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", anthropicAPIURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	return req, nil
+}
@@ -0,0 +1,140 @@
+package llms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/pkg/ai"
+)
+
+func newTestAnthropicClient(t *testing.T, handler http.HandlerFunc) (*AnthropicClient, *redirectTransport) {
+	t.Helper()
+
+	client, err := NewAnthropicClient(&config.Config{AnthropicAPIKey: "test-key", AnthropicModel: "claude-test"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	transport := newRedirectTransport(t, handler)
+	client.SetTransport(transport)
+	return client, transport
+}
+
+func TestAnthropicGenerateResponse(t *testing.T) {
+	client, transport := newTestAnthropicClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"content": [{"type": "text", "text": "Your blood pressure "}, {"type": "text", "text": "is normal."}],
+			"stop_reason": "end_turn",
+			"usage": {"input_tokens": 120, "output_tokens": 30}
+		}`)
+	})
+
+	messages := []ai.ChatMessage{
+		{Role: "system", Content: "You are a health assistant."},
+		{Role: "user", Content: "How is my blood pressure?"},
+		{Role: "assistant", Content: "Let me check."},
+		{Role: "user", Content: "Thanks"},
+	}
+	response, err := client.GenerateResponse(context.Background(), messages, ai.GenerateOptions{MaxTokens: 500, Temperature: 0.3})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if response.Content != "Your blood pressure is normal." {
+		t.Errorf("content = %q", response.Content)
+	}
+	if response.TokensUsed != 150 {
+		t.Errorf("tokens used = %d, want input plus output (150)", response.TokensUsed)
+	}
+	if response.FinishReason != "end_turn" {
+		t.Errorf("finish reason = %q", response.FinishReason)
+	}
+
+	request := transport.last(t)
+	if request.URL != anthropicAPIURL {
+		t.Errorf("URL = %s, want %s", request.URL, anthropicAPIURL)
+	}
+	if request.Header.Get("x-api-key") != "test-key" || request.Header.Get("anthropic-version") != anthropicVersion {
+		t.Errorf("headers = %v", request.Header)
+	}
+
+	body := request.Body
+	if body["model"] != "claude-test" || body["max_tokens"] != 500.0 || body["temperature"] != 0.3 {
+		t.Errorf("body = %v, want model claude-test, max_tokens 500, temperature 0.3", body)
+	}
+	if body["system"] != "You are a health assistant." {
+		t.Errorf("system = %v, want the system message", body["system"])
+	}
+	turns, _ := body["messages"].([]interface{})
+	if len(turns) != 3 {
+		t.Fatalf("messages = %v, want the three user and assistant turns", body["messages"])
+	}
+	for i, want := range []string{"user", "assistant", "user"} {
+		if role := turns[i].(map[string]interface{})["role"]; role != want {
+			t.Errorf("turn %d role = %v, want %s", i, role, want)
+		}
+	}
+}
+
+func TestAnthropicClampsTemperature(t *testing.T) {
+	client, transport := newTestAnthropicClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"content": [{"type": "text", "text": "ok"}], "usage": {"input_tokens": 1, "output_tokens": 1}}`)
+	})
+
+	for _, tc := range []struct {
+		temperature float32
+		want        float64
+	}{
+		{1.5, 1},
+		{-0.5, 0},
+		{0.7, 0.7},
+	} {
+		if _, err := client.GenerateResponse(context.Background(), []ai.ChatMessage{{Role: "user", Content: "hi"}}, ai.GenerateOptions{MaxTokens: 10, Temperature: tc.temperature}); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		if got := transport.last(t).Body["temperature"]; got != tc.want {
+			t.Errorf("temperature %g sent as %v, want %g", tc.temperature, got, tc.want)
+		}
+	}
+}
+
+func TestAnthropicGenerateResponseStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":80}}}`,
+		`{"type":"content_block_delta","delta":{"type":"text_delta","text":"All "}}`,
+		`{"type":"content_block_delta","delta":{"type":"text_delta","text":"good."}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+		`{"type":"message_stop"}`,
+	}
+	client, transport := newTestAnthropicClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", event)
+		}
+	})
+
+	chunks, err := client.GenerateResponseStream(context.Background(), []ai.ChatMessage{{Role: "user", Content: "hi"}}, ai.GenerateOptions{MaxTokens: 10})
+	if err != nil {
+		t.Fatalf("generate stream: %v", err)
+	}
+
+	var content strings.Builder
+	var last ai.StreamChunk
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		content.WriteString(chunk.Content)
+		last = chunk
+	}
+
+	if content.String() != "All good." || !last.Done || last.TokensUsed != 84 || last.FinishReason != "end_turn" {
+		t.Errorf("got %q and final chunk %+v, want \"All good.\" with 84 tokens", content.String(), last)
+	}
+	if transport.last(t).Body["stream"] != true {
+		t.Error("stream not requested")
+	}
+}
@@ -0,0 +1,165 @@
+package llms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/pkg/ai"
+)
+
+// openAIChatURL is the OpenAI chat completions endpoint
+const openAIChatURL = "https://api.openai.com/v1/chat/completions"
+
+// OpenAIClient implements LLMClient for OpenAI's chat completions API
+type OpenAIClient struct {
+	apiKey       string
+	model        string
+	client       *http.Client
+	streamClient *http.Client // Same transport as client, without the whole-request timeout
+}
+
+// NewOpenAIClient creates a new OpenAI chat client
+func NewOpenAIClient(cfg *config.Config) (*OpenAIClient, error) {
+	if cfg.OpenAIAPIKey == "" {
+		return nil, fmt.Errorf("OpenAI API key is required")
+	}
+
+	client, streamClient := ai.NewHTTPClients(time.Duration(cfg.ProviderTimeoutSeconds) * time.Second)
+
+	return &OpenAIClient{
+		apiKey:       cfg.OpenAIAPIKey,
+		model:        cfg.OpenAIChatModel,
+		client:       client,
+		streamClient: streamClient,
+	}, nil
+}
+
+// SetTransport replaces the HTTP transport used for requests, e.g. to route through a proxy
+func (o *OpenAIClient) SetTransport(transport http.RoundTripper) {
+	o.client.Transport = transport
+	o.streamClient.Transport = transport
+}
+
+// GenerateResponse generates a response using the OpenAI chat completions API
+func (o *OpenAIClient) GenerateResponse(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (*ai.ChatResponse, error) {
+	req, err := o.newRequest(ctx, messages, opts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("no response choices returned from OpenAI API")
+	}
+
+	choice := response.Choices[0]
+	return &ai.ChatResponse{
+		Content:      choice.Message.Content,
+		TokensUsed:   response.Usage.TotalTokens,
+		FinishReason: choice.FinishReason,
+	}, nil
+}
+
+// GenerateResponseStream generates a response using the OpenAI streaming API.
+// Chunks are delivered on the returned channel, which is closed after the final (Done) chunk.
+func (o *OpenAIClient) GenerateResponseStream(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (<-chan ai.StreamChunk, error) {
+	req, err := o.newRequest(ctx, messages, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := o.streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+	}
+
+	return readChatCompletionStream(ctx, resp.Body), nil
+}
+
+// HealthCheck checks if the OpenAI API is accessible
+func (o *OpenAIClient) HealthCheck(ctx context.Context) error {
+	messages := []ai.ChatMessage{
+		{
+			Role:    "system",
+			Content: "Be precise and concise.",
+		},
+		{
+			Role:    "user",
+			Content: "Hello",
+		},
+	}
+
+	_, err := o.GenerateResponse(ctx, messages, ai.GenerateOptions{MaxTokens: 10, Temperature: 0.7})
+	return err
+}
+
+// newRequest builds a chat completions request
+func (o *OpenAIClient) newRequest(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions, stream bool) (*http.Request, error) {
+	requestBody := map[string]interface{}{
+		"model":       o.model,
+		"messages":    messages,
+		"max_tokens":  opts.MaxTokens,
+		"temperature": opts.Temperature,
+	}
+
+	if opts.Seed != nil {
+		requestBody["seed"] = *opts.Seed
+	}
+
+	if stream {
+		requestBody["stream"] = true
+		// Ask for a final usage chunk so token accounting works for streams too
+		requestBody["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", openAIChatURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", o.apiKey))
+
+	return req, nil
+}
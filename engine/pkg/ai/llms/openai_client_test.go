@@ -0,0 +1,87 @@
+package llms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/pkg/ai"
+)
+
+func TestOpenAIGenerateResponse(t *testing.T) {
+	client, err := NewOpenAIClient(&config.Config{OpenAIAPIKey: "sk-test", OpenAIChatModel: "gpt-4o-mini", ChatModel: "sonar"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	transport := newRedirectTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"choices": [
+				{"message": {"role": "assistant", "content": "Your glucose is in range."}, "finish_reason": "stop"},
+				{"message": {"role": "assistant", "content": "ignored"}, "finish_reason": "stop"}
+			],
+			"usage": {"prompt_tokens": 90, "completion_tokens": 10, "total_tokens": 100}
+		}`)
+	})
+	client.SetTransport(transport)
+
+	messages := []ai.ChatMessage{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "How is my glucose?"}}
+	response, err := client.GenerateResponse(context.Background(), messages, ai.GenerateOptions{MaxTokens: 256, Temperature: 0.2})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if response.Content != "Your glucose is in range." || response.TokensUsed != 100 || response.FinishReason != "stop" {
+		t.Errorf("response = %+v, want the first choice with 100 tokens", response)
+	}
+
+	request := transport.last(t)
+	if request.URL != openAIChatURL || request.Header.Get("Authorization") != "Bearer sk-test" {
+		t.Errorf("sent to %s with headers %v", request.URL, request.Header)
+	}
+	body := request.Body
+	if body["max_tokens"] != 256.0 || body["temperature"] != 0.2 {
+		t.Errorf("body = %v, want max_tokens 256 and temperature 0.2", body)
+	}
+	if body["model"] != "gpt-4o-mini" {
+		t.Errorf("model = %v, want the OpenAI chat model rather than CHAT_MODEL", body["model"])
+	}
+	if _, sent := body["seed"]; sent {
+		t.Error("seed sent without a seed option")
+	}
+}
+
+func TestOpenAIGenerateResponseNoChoices(t *testing.T) {
+	client, err := NewOpenAIClient(&config.Config{OpenAIAPIKey: "sk-test", OpenAIChatModel: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	client.SetTransport(newRedirectTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices": [], "usage": {"total_tokens": 5}}`)
+	}))
+
+	if _, err := client.GenerateResponse(context.Background(), nil, ai.GenerateOptions{MaxTokens: 10}); err == nil {
+		t.Fatal("expected an error when no choices are returned")
+	}
+}
+
+func TestOpenAIGenerateResponseSendsSeed(t *testing.T) {
+	client, err := NewOpenAIClient(&config.Config{OpenAIAPIKey: "sk-test", OpenAIChatModel: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	transport := newRedirectTransport(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "ok"}}], "usage": {"total_tokens": 5}}`)
+	})
+	client.SetTransport(transport)
+
+	if _, err := client.GenerateResponse(context.Background(), nil, ai.DeterministicOptions(64, 1234)); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	body := transport.last(t).Body
+	if body["seed"] != 1234.0 || body["temperature"] != 0.0 {
+		t.Errorf("body = %v, want seed 1234 and temperature 0", body)
+	}
+}
@@ -1,21 +1,26 @@
 package llms
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
+	"time"
 
 	"health-dashboard-backend/internal/config"
 	"health-dashboard-backend/pkg/ai"
 )
 
+// sonarAPIURL is the Perplexity chat completions endpoint
+const sonarAPIURL = "https://api.perplexity.ai/chat/completions"
+
 // SonarClient implements LLMClient for Perplexity's Sonar API
 type SonarClient struct {
-	apiKey string
-	model  string
-	client *http.Client
+	apiKey       string
+	model        string
+	client       *http.Client
+	streamClient *http.Client // Same transport as client, without the whole-request timeout
 }
 
 // NewSonarClient creates a new Sonar client
@@ -24,33 +29,29 @@ func NewSonarClient(cfg *config.Config) (*SonarClient, error) {
 		return nil, fmt.Errorf("Sonar API key is required")
 	}
 
+	client, streamClient := ai.NewHTTPClients(time.Duration(cfg.ProviderTimeoutSeconds) * time.Second)
+
 	return &SonarClient{
-		apiKey: cfg.SonarAPIKey,
-		model:  cfg.ChatModel,
-		client: &http.Client{},
+		apiKey:       cfg.SonarAPIKey,
+		model:        cfg.ChatModel,
+		client:       client,
+		streamClient: streamClient,
 	}, nil
 }
 
-// GenerateResponse generates a response using Sonar API
-func (s *SonarClient) GenerateResponse(ctx context.Context, messages []ai.ChatMessage, maxTokens int, temperature float32) (*ai.ChatResponse, error) {
-	requestBody := map[string]interface{}{
-		"model":    s.model,
-		"messages": messages,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+// SetTransport replaces the HTTP transport used for requests, e.g. to route through a proxy
+func (s *SonarClient) SetTransport(transport http.RoundTripper) {
+	s.client.Transport = transport
+	s.streamClient.Transport = transport
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.perplexity.ai/chat/completions", strings.NewReader(string(jsonData)))
+// GenerateResponse generates a response using Sonar API
+func (s *SonarClient) GenerateResponse(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (*ai.ChatResponse, error) {
+	req, err := s.newRequest(ctx, messages, opts, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.apiKey))
-
 	resp, err := s.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -89,6 +90,27 @@ func (s *SonarClient) GenerateResponse(ctx context.Context, messages []ai.ChatMe
 	}, nil
 }
 
+// GenerateResponseStream generates a response using the Sonar streaming API.
+// Chunks are delivered on the returned channel, which is closed after the final (Done) chunk.
+func (s *SonarClient) GenerateResponseStream(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions) (<-chan ai.StreamChunk, error) {
+	req, err := s.newRequest(ctx, messages, opts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+	}
+
+	return readChatCompletionStream(ctx, resp.Body), nil
+}
+
 // HealthCheck checks if Sonar API is accessible
 func (s *SonarClient) HealthCheck(ctx context.Context) error {
 	// Simple health check by sending a minimal request
@@ -103,6 +125,42 @@ func (s *SonarClient) HealthCheck(ctx context.Context) error {
 		},
 	}
 
-	_, err := s.GenerateResponse(ctx, messages, 10, 0.7)
+	_, err := s.GenerateResponse(ctx, messages, ai.GenerateOptions{MaxTokens: 10, Temperature: 0.7})
 	return err
 }
+
+// newRequest builds a chat completions request. Sonar has no seed parameter, so deterministic
+// requests rely on their temperature of 0.
+func (s *SonarClient) newRequest(ctx context.Context, messages []ai.ChatMessage, opts ai.GenerateOptions, stream bool) (*http.Request, error) {
+	requestBody := map[string]interface{}{
+		"model":       s.model,
+		"messages":    messages,
+		"temperature": opts.Temperature,
+	}
+
+	if opts.MaxTokens > 0 {
+		requestBody["max_tokens"] = opts.MaxTokens
+	}
+
+	if stream {
+		requestBody["stream"] = true
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sonarAPIURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.apiKey))
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return req, nil
+}
@@ -0,0 +1,109 @@
+package llms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/pkg/ai"
+)
+
+func newTestSonarClient(t *testing.T, handler http.HandlerFunc) (*SonarClient, *redirectTransport) {
+	t.Helper()
+
+	client, err := NewSonarClient(&config.Config{SonarAPIKey: "test-key", ChatModel: "sonar"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	transport := newRedirectTransport(t, handler)
+	client.SetTransport(transport)
+	return client, transport
+}
+
+func TestSonarGenerateResponseStream(t *testing.T) {
+	frames := []string{
+		`{"choices":[{"delta":{"content":"Your heart"}}]}`,
+		`{"choices":[{"delta":{"content":" rate is normal."}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"stop"}],"usage":{"total_tokens":57}}`,
+		`[DONE]`,
+	}
+	client, transport := newTestSonarClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, frame := range frames {
+			fmt.Fprintf(w, "data: %s\n\n", frame)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	messages := []ai.ChatMessage{{Role: "user", Content: "How is my heart rate?"}}
+	chunks, err := client.GenerateResponseStream(context.Background(), messages, ai.GenerateOptions{MaxTokens: 300, Temperature: 0.4})
+	if err != nil {
+		t.Fatalf("generate stream: %v", err)
+	}
+
+	var content strings.Builder
+	var last ai.StreamChunk
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		content.WriteString(chunk.Content)
+		last = chunk
+	}
+
+	if content.String() != "Your heart rate is normal." {
+		t.Errorf("content = %q", content.String())
+	}
+	if !last.Done || last.TokensUsed != 57 || last.FinishReason != "stop" {
+		t.Errorf("final chunk = %+v, want Done with 57 tokens and finish reason stop", last)
+	}
+
+	request := transport.last(t)
+	if request.URL != sonarAPIURL {
+		t.Errorf("URL = %s, want %s", request.URL, sonarAPIURL)
+	}
+	if request.Header.Get("Accept") != "text/event-stream" || request.Header.Get("Authorization") != "Bearer test-key" {
+		t.Errorf("headers = %v", request.Header)
+	}
+	if request.Body["stream"] != true || request.Body["max_tokens"] != 300.0 || request.Body["temperature"] != 0.4 {
+		t.Errorf("body = %v, want stream with max_tokens 300 and temperature 0.4", request.Body)
+	}
+}
+
+func TestSonarGenerateResponseSendsOptions(t *testing.T) {
+	client, transport := newTestSonarClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"Hi"},"finish_reason":"stop"}],"usage":{"total_tokens":12}}`)
+	})
+
+	response, err := client.GenerateResponse(context.Background(), []ai.ChatMessage{{Role: "user", Content: "Hello"}}, ai.DeterministicOptions(200, 7))
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if response.Content != "Hi" || response.TokensUsed != 12 {
+		t.Errorf("response = %+v", response)
+	}
+
+	body := transport.last(t).Body
+	if body["max_tokens"] != 200.0 || body["temperature"] != 0.0 {
+		t.Errorf("body = %v, want max_tokens 200 and temperature 0", body)
+	}
+	if _, sent := body["seed"]; sent {
+		t.Error("seed sent to Sonar, which has no seed parameter")
+	}
+	if _, sent := body["stream"]; sent {
+		t.Error("stream set on a non-streaming request")
+	}
+}
+
+func TestSonarGenerateResponseStreamError(t *testing.T) {
+	client, _ := newTestSonarClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	})
+
+	if _, err := client.GenerateResponseStream(context.Background(), nil, ai.GenerateOptions{}); err == nil {
+		t.Fatal("expected an error for a 429 response")
+	}
+}
@@ -0,0 +1,101 @@
+package llms
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"health-dashboard-backend/pkg/ai"
+)
+
+// readChatCompletionStream parses an OpenAI-compatible chat completions SSE body
+// (used by both OpenAI and Perplexity Sonar) into stream chunks. The body is closed
+// and the channel is closed after the final (Done) chunk.
+func readChatCompletionStream(ctx context.Context, body io.ReadCloser) <-chan ai.StreamChunk {
+	chunks := make(chan ai.StreamChunk)
+
+	go func() {
+		defer close(chunks)
+		defer body.Close()
+
+		var tokensUsed int
+		var finishReason string
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				continue // Skip blank keep-alive lines and other SSE fields
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				break
+			}
+
+			var event struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+					FinishReason string `json:"finish_reason"`
+				} `json:"choices"`
+				Usage struct {
+					TotalTokens int `json:"total_tokens"`
+				} `json:"usage"`
+			}
+
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				sendChunk(ctx, chunks, ai.StreamChunk{Done: true, Err: fmt.Errorf("failed to decode stream event: %w", err)})
+				return
+			}
+
+			if event.Usage.TotalTokens > 0 {
+				tokensUsed = event.Usage.TotalTokens
+			}
+
+			if len(event.Choices) == 0 {
+				continue
+			}
+
+			choice := event.Choices[0]
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+
+			if choice.Delta.Content != "" {
+				if !sendChunk(ctx, chunks, ai.StreamChunk{Content: choice.Delta.Content}) {
+					return
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			sendChunk(ctx, chunks, ai.StreamChunk{Done: true, Err: fmt.Errorf("failed to read stream: %w", err)})
+			return
+		}
+
+		sendChunk(ctx, chunks, ai.StreamChunk{
+			Done:         true,
+			TokensUsed:   tokensUsed,
+			FinishReason: finishReason,
+		})
+	}()
+
+	return chunks
+}
+
+// sendChunk delivers a chunk unless the context is cancelled first
+func sendChunk(ctx context.Context, chunks chan<- ai.StreamChunk, chunk ai.StreamChunk) bool {
+	select {
+	case chunks <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package llms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"health-dashboard-backend/internal/config"
+	"health-dashboard-backend/pkg/ai"
+)
+
+// recordedRequest is a provider request captured by redirectTransport
+type recordedRequest struct {
+	URL    string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// redirectTransport sends every request to a test server instead of the provider, recording
+// the original URL, headers and JSON body
+type redirectTransport struct {
+	target *url.URL
+
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+// newRedirectTransport starts a test server with handler and returns a transport aimed at it
+func newRedirectTransport(t *testing.T, handler http.HandlerFunc) *redirectTransport {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse test server URL: %v", err)
+	}
+	return &redirectTransport{target: target}
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+
+	recorded := recordedRequest{URL: req.URL.String(), Header: req.Header.Clone()}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &recorded.Body); err != nil {
+			return nil, err
+		}
+	}
+	r.mu.Lock()
+	r.requests = append(r.requests, recorded)
+	r.mu.Unlock()
+
+	redirected := req.Clone(req.Context())
+	redirected.URL.Scheme = r.target.Scheme
+	redirected.URL.Host = r.target.Host
+	redirected.Host = r.target.Host
+	redirected.Body = io.NopCloser(bytes.NewReader(body))
+	return http.DefaultTransport.RoundTrip(redirected)
+}
+
+// last returns the latest recorded request
+func (r *redirectTransport) last(t *testing.T) recordedRequest {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		t.Fatal("no request was sent")
+	}
+	return r.requests[len(r.requests)-1]
+}
+
+func TestSlowProviderTimesOut(t *testing.T) {
+	cfg := &config.Config{OpenAIAPIKey: "sk-test", SonarAPIKey: "test-key", ChatModel: "sonar", ProviderTimeoutSeconds: 1}
+	openAI, err := NewOpenAIClient(cfg)
+	if err != nil {
+		t.Fatalf("new OpenAI client: %v", err)
+	}
+	sonar, err := NewSonarClient(cfg)
+	if err != nil {
+		t.Fatalf("new Sonar client: %v", err)
+	}
+
+	// The upstream doesn't answer until the test is over
+	release := make(chan struct{})
+	hang := func(w http.ResponseWriter, r *http.Request) { <-release }
+
+	for name, client := range map[string]interface {
+		ai.LLMClient
+		SetTransport(http.RoundTripper)
+	}{"openai": openAI, "sonar": sonar} {
+		client.SetTransport(newRedirectTransport(t, hang))
+
+		start := time.Now()
+		_, err := client.GenerateResponse(context.Background(), []ai.ChatMessage{{Role: "user", Content: "Hi"}}, ai.GenerateOptions{})
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Errorf("%s: err = %v, want a timeout", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: gave up after %v, want about the 1s timeout", name, elapsed)
+		}
+	}
+	t.Cleanup(func() { close(release) }) // Runs before the servers are closed
+}
@@ -1,10 +1,60 @@
 package ai
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
 
-// GenerateSystemPrompt creates a system prompt for health-related queries
-func GenerateSystemPrompt() string {
-	return `You are a knowledgeable health assistant with access to the user's health data and uploaded medical documents. Your role is to:
+// DefaultPersona is the assistant persona used when neither the config nor the request picks one
+const DefaultPersona = "friendly"
+
+// Personas maps assistant persona names to the tone instructions they add to the system prompt
+var Personas = map[string]string{
+	"friendly": "Use a warm, conversational tone. Explain medical terms in plain language and be encouraging.",
+	"clinical": "Use a precise, neutral clinical tone. Prefer correct medical terminology, give values with their units and reference ranges, and keep reassurance brief.",
+	"concise":  "Be brief. Lead with the answer in one or two sentences and put any detail in short bullet points.",
+}
+
+// NormalizePersona validates a persona name and returns it in canonical form. The second return
+// value is false for unknown personas.
+func NormalizePersona(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, exists := Personas[name]; !exists {
+		return "", false
+	}
+	return name, true
+}
+
+// localePattern accepts BCP 47 style locale tags such as "en", "en-GB" or "pt_BR"
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8}){0,2}$`)
+
+// NormalizeLocale validates a locale tag such as "en-GB", returning it with "-" separators. The
+// second return value is false for malformed tags.
+func NormalizeLocale(locale string) (string, bool) {
+	locale = strings.TrimSpace(locale)
+	if !localePattern.MatchString(locale) {
+		return "", false
+	}
+	return strings.ReplaceAll(locale, "_", "-"), true
+}
+
+// maxPromptNameLength caps the user name placed in the system prompt
+const maxPromptNameLength = 50
+
+// SystemPromptOptions are the per-user and per-request fields of the system prompt. Empty fields
+// are left out, and an unknown persona falls back to DefaultPersona.
+type SystemPromptOptions struct {
+	Persona   string // Key of Personas
+	FirstName string // User's first name, to address them by
+	Locale    string // Locale for date, number and unit conventions, e.g. "en-GB"
+	Language  string // ISO 639-1 response language; English or empty adds no instruction
+}
+
+// systemPromptTemplate is the customizable part of the system prompt. The medical disclaimer is
+// appended outside the template so no option can remove it.
+var systemPromptTemplate = template.Must(template.New("system").Parse(`You are a knowledgeable health assistant with access to the user's health data and uploaded medical documents. Your role is to:
 
 1. Provide accurate, evidence-based health information
 2. Help users understand their health metrics and trends
@@ -12,21 +62,111 @@ func GenerateSystemPrompt() string {
 4. Offer general wellness advice
 5. Identify patterns in health data
 
+Tone:
+- {{.PersonaInstructions}}
+{{- if .FirstName}}
+- The user's first name is {{.FirstName}}; you may address them by it.
+{{- end}}
+{{- if .Locale}}
+- Format dates, numbers and units following the conventions of the {{.Locale}} locale.
+{{- end}}
+
 Important guidelines:
-- Always emphasize that you're not a replacement for professional medical advice
-- Encourage users to consult healthcare providers for serious concerns
 - Use the available tools to fetch relevant health data and document context
 - Be empathetic and supportive while being informative
-- If health metrics are concerning, gently suggest medical consultation
 - Respect user privacy and only access data relevant to their queries
 
 Available tools:
 - fetch_health_data: Get user's health metrics and trends
 - query_rag_context: Search through uploaded medical documents
 - analyze_trends: Analyze patterns in health data
-- generate_insights: Provide personalized health insights
+- generate_insights: Provide personalized health insights`))
+
+// medicalDisclaimer is the mandatory closing section of every system prompt
+const medicalDisclaimer = `
+
+Medical disclaimer (applies whatever the tone):
+- Always emphasize that you're not a replacement for professional medical advice
+- Encourage users to consult healthcare providers for serious concerns
+- If health metrics are concerning, gently suggest medical consultation
 
 Please be helpful, accurate, and caring in your responses.`
+
+// RenderSystemPrompt creates the system prompt for health-related queries from opts
+func RenderSystemPrompt(opts SystemPromptOptions) string {
+	persona, ok := NormalizePersona(opts.Persona)
+	if !ok {
+		persona = DefaultPersona
+	}
+
+	// The name comes from the user's account, so keep it to a single short line
+	firstName := strings.Join(strings.Fields(opts.FirstName), " ")
+	if len([]rune(firstName)) > maxPromptNameLength {
+		firstName = string([]rune(firstName)[:maxPromptNameLength])
+	}
+
+	locale, _ := NormalizeLocale(opts.Locale)
+
+	var prompt strings.Builder
+	// Execute only fails on a broken template or writer, neither of which can happen here
+	_ = systemPromptTemplate.Execute(&prompt, struct {
+		PersonaInstructions string
+		FirstName           string
+		Locale              string
+	}{Personas[persona], firstName, locale})
+	prompt.WriteString(medicalDisclaimer)
+
+	if opts.Language != "" && opts.Language != "en" {
+		fmt.Fprintf(&prompt, `
+
+Response language:
+- Always write your response in %s, regardless of the language of the question or context.
+- Keep quotes from the user's documents and health data in their original language; translate or explain them in %s where helpful.
+- Keep metric names, units and numeric values unchanged.`, LanguageName(opts.Language), LanguageName(opts.Language))
+	}
+
+	return prompt.String()
+}
+
+// GenerateSystemPrompt creates a system prompt for health-related queries with the default persona
+func GenerateSystemPrompt() string {
+	return RenderSystemPrompt(SystemPromptOptions{})
+}
+
+// GenerateSystemPromptWithLanguage creates a system prompt that instructs the model to
+// answer in the given language. English (or an empty code) yields the default prompt.
+func GenerateSystemPromptWithLanguage(language string) string {
+	return RenderSystemPrompt(SystemPromptOptions{Language: language})
+}
+
+// GenerateInsightsPrompt asks for personalized insights about the user's health data as a JSON
+// array, so they can be shown as individual cards
+func GenerateInsightsPrompt(healthContext string) string {
+	return fmt.Sprintf(`Generate personalized health insights based on the user's recent health data and trends.
+
+Health Data Context:
+%s
+
+Respond with only a JSON array of up to 5 insights and no other text. Each insight is an object with these fields:
+- "type": one of "trend", "pattern", "risk" or "achievement"
+- "title": a short headline
+- "description": one or two sentences explaining the insight, referencing the user's values
+- "confidence": "high", "medium" or "low", depending on how well the data supports the insight
+- "action": a short recommended next step, such as "continue_monitoring" or "consult_doctor"
+
+Base every insight on the data above, and recommend consulting a healthcare professional for concerning values.`, healthContext)
+}
+
+// GenerateRAGPromptWithLanguage creates a RAG prompt that repeats the response language
+// instruction next to the question, where models are less likely to drift back to English.
+// English (or an empty code) yields the default prompt.
+func GenerateRAGPromptWithLanguage(userQuery, healthContext, documentContext, language string) string {
+	prompt := GenerateRAGPrompt(userQuery, healthContext, documentContext)
+	if language == "" || language == "en" {
+		return prompt
+	}
+
+	return prompt + fmt.Sprintf("\n\nRespond in %s.", LanguageName(language))
 }
 
 // GenerateRAGPrompt creates a prompt for RAG-enhanced responses
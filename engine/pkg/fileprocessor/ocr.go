@@ -0,0 +1,79 @@
+package fileprocessor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// imageFormats are the file types extracted with OCR when a provider is set
+var imageFormats = []string{"png", "jpg", "jpeg"}
+
+// OCRProvider recognises the text in an image
+type OCRProvider interface {
+	RecognizeText(image []byte) (string, error)
+}
+
+// TesseractOCR recognises text by running the tesseract command-line tool
+type TesseractOCR struct {
+	path      string        // tesseract binary
+	languages string        // Tesseract language codes, e.g. "eng" or "eng+deu"
+	timeout   time.Duration // Per-image limit; 0 is unlimited
+}
+
+// NewTesseractOCR creates an OCR provider that runs the tesseract binary at path
+func NewTesseractOCR(path, languages string, timeout time.Duration) *TesseractOCR {
+	if path == "" {
+		path = "tesseract"
+	}
+	if languages == "" {
+		languages = "eng"
+	}
+
+	return &TesseractOCR{path: path, languages: languages, timeout: timeout}
+}
+
+// RecognizeText pipes the image through tesseract and returns the recognised text
+func (t *TesseractOCR) RecognizeText(image []byte) (string, error) {
+	ctx := context.Background()
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.path, "stdin", "stdout", "-l", t.languages)
+	cmd.Stdin = bytes.NewReader(image)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// SetOCRProvider enables text extraction from images. OCR is slow, so it is off unless a
+// provider is set.
+func (fp *FileProcessor) SetOCRProvider(provider OCRProvider) {
+	fp.ocr = provider
+}
+
+// extractTextFromImage extracts text from an image with the OCR provider
+func (fp *FileProcessor) extractTextFromImage(content []byte) (string, error) {
+	if fp.ocr == nil {
+		return "", fmt.Errorf("OCR is not enabled")
+	}
+
+	text, err := fp.ocr.RecognizeText(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to recognize text: %w", err)
+	}
+
+	return text, nil
+}
@@ -0,0 +1,51 @@
+package fileprocessor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// fakeOCR recognises the canned text of known images
+type fakeOCR struct {
+	texts map[string]string // Image bytes -> text
+}
+
+func (f fakeOCR) RecognizeText(image []byte) (string, error) {
+	if text, ok := f.texts[string(image)]; ok {
+		return text, nil
+	}
+	return "", errors.New("no text found")
+}
+
+func TestImagesNeedAnOCRProvider(t *testing.T) {
+	fp := NewFileProcessor()
+	image := []byte("\x89PNG lab results photo")
+
+	if fp.IsFormatSupported("png") {
+		t.Error("png supported without an OCR provider")
+	}
+	if _, err := fp.ExtractText(image, "png"); err == nil {
+		t.Error("extracted text from an image without an OCR provider")
+	}
+
+	fp.SetOCRProvider(fakeOCR{texts: map[string]string{string(image): "HbA1c 6.1 %"}})
+	for _, format := range []string{"png", "jpg", "jpeg"} {
+		if !fp.IsFormatSupported(format) {
+			t.Errorf("%s not supported with an OCR provider", format)
+		}
+	}
+
+	text, err := fp.ExtractText(image, "png")
+	if err != nil || text != "HbA1c 6.1 %" {
+		t.Errorf("text %q err %v, want the recognised text", text, err)
+	}
+	pages, err := fp.ExtractTextWithPages(image, "jpg")
+	if err != nil || len(pages) != 1 || pages[0].Number != 0 || pages[0].Text != "HbA1c 6.1 %" {
+		t.Errorf("pages %+v err %v, want one unnumbered page", pages, err)
+	}
+
+	if _, err := fp.ExtractText(bytes.Repeat([]byte{0}, 8), "jpeg"); err == nil {
+		t.Error("no error for an image without recognisable text")
+	}
+}
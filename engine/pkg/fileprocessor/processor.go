@@ -9,7 +9,19 @@ import (
 )
 
 // FileProcessor handles text extraction from various file formats
-type FileProcessor struct{}
+type FileProcessor struct {
+	countTokens TokenCounter // Used by ChunkTextByTokens; nil means EstimateTokens
+	ocr         OCRProvider  // Extracts text from images; nil means images aren't supported
+}
+
+// PageText is the text of one page of a document
+type PageText struct {
+	Number int // 1-based page number; 0 for formats without pages
+	Text   string
+}
+
+// PageBreak separates pages in text produced by JoinPages
+const PageBreak = "\f"
 
 // NewFileProcessor creates a new file processor
 func NewFileProcessor() *FileProcessor {
@@ -25,23 +37,97 @@ func (fp *FileProcessor) ExtractText(content []byte, fileType string) (string, e
 		return fp.extractTextFromTXT(content)
 	case "md", "markdown":
 		return fp.extractTextFromMarkdown(content)
+	case "png", "jpg", "jpeg":
+		return fp.extractTextFromImage(content)
 	default:
 		return "", fmt.Errorf("unsupported file type: %s", fileType)
 	}
 }
 
+// ExtractTextWithPages extracts text from a file page by page. PDFs yield one entry per page
+// with text; other formats yield a single entry with page number 0.
+func (fp *FileProcessor) ExtractTextWithPages(content []byte, fileType string) ([]PageText, error) {
+	if strings.ToLower(fileType) == "pdf" {
+		return fp.extractPagesFromPDF(content)
+	}
+
+	text, err := fp.ExtractText(content, fileType)
+	if err != nil {
+		return nil, err
+	}
+	return []PageText{{Text: text}}, nil
+}
+
+// JoinPages combines pages into a single text with PageBreak between them, so SplitPages can
+// recover the page numbers. Pages missing from the slice are kept as empty pages.
+func JoinPages(pages []PageText) string {
+	if len(pages) == 0 {
+		return ""
+	}
+	if len(pages) == 1 && pages[0].Number == 0 {
+		return pages[0].Text
+	}
+
+	var text strings.Builder
+	current := 1
+	for i, page := range pages {
+		if i > 0 {
+			text.WriteString("\n\n")
+		}
+		for ; current < page.Number; current++ {
+			text.WriteString(PageBreak)
+		}
+		text.WriteString(page.Text)
+	}
+	text.WriteString(PageBreak) // Marks even a single page as paginated
+
+	return text.String()
+}
+
+// SplitPages reverses JoinPages. Text without page breaks is returned as a single page with
+// page number 0.
+func SplitPages(text string) []PageText {
+	if !strings.Contains(text, PageBreak) {
+		return []PageText{{Text: text}}
+	}
+
+	var pages []PageText
+	for i, pageText := range strings.Split(text, PageBreak) {
+		if pageText = strings.TrimSpace(pageText); pageText != "" {
+			pages = append(pages, PageText{Number: i + 1, Text: pageText})
+		}
+	}
+	return pages
+}
+
 // extractTextFromPDF extracts text from PDF files
 func (fp *FileProcessor) extractTextFromPDF(content []byte) (string, error) {
+	pages, err := fp.extractPagesFromPDF(content)
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, page := range pages {
+		text.WriteString(page.Text)
+		text.WriteString("\n\n") // Add page separator
+	}
+
+	return strings.TrimSpace(text.String()), nil
+}
+
+// extractPagesFromPDF extracts the text of each PDF page, skipping pages without text
+func (fp *FileProcessor) extractPagesFromPDF(content []byte) ([]PageText, error) {
 	// Create a reader from the byte content
 	reader := &ByteReaderAt{data: content}
 
 	// Open PDF
 	pdfReader, err := pdf.NewReader(reader, int64(len(content)))
 	if err != nil {
-		return "", fmt.Errorf("failed to open PDF: %w", err)
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
 	}
 
-	var text strings.Builder
+	var pages []PageText
 
 	// Extract text from each page
 	for i := 1; i <= pdfReader.NumPage(); i++ {
@@ -56,11 +142,12 @@ func (fp *FileProcessor) extractTextFromPDF(content []byte) (string, error) {
 			continue
 		}
 
-		text.WriteString(pageText)
-		text.WriteString("\n\n") // Add page separator
+		if pageText = strings.TrimSpace(pageText); pageText != "" {
+			pages = append(pages, PageText{Number: i, Text: pageText})
+		}
 	}
 
-	return strings.TrimSpace(text.String()), nil
+	return pages, nil
 }
 
 // extractTextFromTXT extracts text from plain text files
@@ -81,21 +168,7 @@ func (fp *FileProcessor) ChunkText(text string, chunkSize int, overlap int) []st
 		return nil
 	}
 
-	// Normalize line endings and clean up text
-	text = strings.ReplaceAll(text, "\r\n", "\n")
-	text = strings.ReplaceAll(text, "\r", "\n")
-
-	// Remove excessive whitespace
-	lines := strings.Split(text, "\n")
-	var cleanLines []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			cleanLines = append(cleanLines, line)
-		}
-	}
-
-	cleanText := strings.Join(cleanLines, "\n")
+	cleanText := normalizeText(text)
 
 	if len(cleanText) <= chunkSize {
 		return []string{cleanText}
@@ -126,6 +199,24 @@ func (fp *FileProcessor) ChunkText(text string, chunkSize int, overlap int) []st
 	return chunks
 }
 
+// normalizeText normalizes line endings and drops blank lines and surrounding whitespace
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	// Remove excessive whitespace
+	lines := strings.Split(text, "\n")
+	var cleanLines []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			cleanLines = append(cleanLines, line)
+		}
+	}
+
+	return strings.Join(cleanLines, "\n")
+}
+
 // adjustChunkBoundary tries to break chunks at natural boundaries
 func (fp *FileProcessor) adjustChunkBoundary(chunk string) string {
 	if len(chunk) == 0 {
@@ -160,7 +251,11 @@ func (fp *FileProcessor) adjustChunkBoundary(chunk string) string {
 
 // GetSupportedFormats returns a list of supported file formats
 func (fp *FileProcessor) GetSupportedFormats() []string {
-	return []string{"pdf", "txt", "md", "markdown"}
+	formats := []string{"pdf", "txt", "md", "markdown"}
+	if fp.ocr != nil {
+		formats = append(formats, imageFormats...)
+	}
+	return formats
 }
 
 // IsFormatSupported checks if a file format is supported
@@ -0,0 +1,164 @@
+package fileprocessor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// charsPerToken is the average number of characters per token for English text with BPE
+// tokenizers such as the ones used by OpenAI embedding models
+const charsPerToken = 4
+
+// tokenPiece is a sentence (or part of one) with its token count
+type tokenPiece struct {
+	text   string
+	tokens int
+}
+
+// TokenCounter returns the number of tokens a piece of text encodes to
+type TokenCounter func(text string) int
+
+// EstimateTokens approximates a token count without a tokenizer. It takes the larger of the
+// character-based estimate and a word-based one (about 4 tokens per 3 words), since dense text
+// with long words and sparse text with many short words each fool one of them.
+func EstimateTokens(text string) int {
+	byChars := (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+	byWords := (len(strings.Fields(text))*4 + 2) / 3
+	if byWords > byChars {
+		return byWords
+	}
+	return byChars
+}
+
+// SetTokenCounter replaces the token estimate used by ChunkTextByTokens, e.g. with a real tokenizer
+func (fp *FileProcessor) SetTokenCounter(counter TokenCounter) {
+	fp.countTokens = counter
+}
+
+// tokenCount counts tokens with the configured counter, falling back to EstimateTokens
+func (fp *FileProcessor) tokenCount(text string) int {
+	if fp.countTokens != nil {
+		return fp.countTokens(text)
+	}
+	return EstimateTokens(text)
+}
+
+// ChunkTextByTokens splits text into chunks of at most maxTokens tokens, breaking at sentence
+// boundaries. Each chunk after the first repeats trailing sentences of the previous one, up to
+// overlapTokens, so context isn't lost at the seams. Sentences longer than maxTokens are split
+// at word boundaries.
+func (fp *FileProcessor) ChunkTextByTokens(text string, maxTokens, overlapTokens int) []string {
+	cleanText := normalizeText(text)
+	if cleanText == "" {
+		return nil
+	}
+	if maxTokens < 1 {
+		maxTokens = 1
+	}
+	if overlapTokens >= maxTokens {
+		overlapTokens = maxTokens / 2
+	}
+
+	var pieces []tokenPiece
+	for _, sentence := range splitSentences(cleanText) {
+		for _, part := range fp.splitOversized(sentence, maxTokens) {
+			pieces = append(pieces, tokenPiece{text: part, tokens: fp.tokenCount(part)})
+		}
+	}
+
+	var chunks []string
+	var current []tokenPiece
+	currentTokens := 0
+
+	for _, p := range pieces {
+		if currentTokens+p.tokens > maxTokens && len(current) > 0 {
+			chunks = append(chunks, joinPieces(current))
+
+			// Carry trailing sentences into the next chunk as overlap
+			overlap := 0
+			start := len(current)
+			for start > 0 && overlap+current[start-1].tokens <= overlapTokens &&
+				overlap+current[start-1].tokens+p.tokens <= maxTokens {
+				start--
+				overlap += current[start].tokens
+			}
+			current = append([]tokenPiece(nil), current[start:]...)
+			currentTokens = overlap
+		}
+
+		current = append(current, p)
+		currentTokens += p.tokens
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, joinPieces(current))
+	}
+
+	return chunks
+}
+
+// splitOversized splits a sentence that exceeds maxTokens into word-bounded parts that fit
+func (fp *FileProcessor) splitOversized(sentence string, maxTokens int) []string {
+	if fp.tokenCount(sentence) <= maxTokens {
+		return []string{sentence}
+	}
+
+	var parts []string
+	var current []string
+	for _, word := range strings.Fields(sentence) {
+		candidate := append(current, word)
+		if len(current) > 0 && fp.tokenCount(strings.Join(candidate, " ")) > maxTokens {
+			parts = append(parts, strings.Join(current, " "))
+			current = []string{word}
+			continue
+		}
+		current = candidate
+	}
+	if len(current) > 0 {
+		parts = append(parts, strings.Join(current, " "))
+	}
+
+	return parts
+}
+
+// splitSentences splits text after sentence-ending punctuation followed by whitespace and at line
+// breaks, dropping empty pieces
+func splitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+
+	for i, r := range runes {
+		end := -1
+		switch {
+		case r == '\n':
+			end = i
+		case (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])):
+			end = i + 1
+		}
+		if end < 0 {
+			continue
+		}
+
+		if sentence := strings.TrimSpace(string(runes[start:end])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = end
+	}
+
+	if sentence := strings.TrimSpace(string(runes[start:])); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+
+	return sentences
+}
+
+// joinPieces joins chunk pieces with single spaces
+func joinPieces(pieces []tokenPiece) string {
+	parts := make([]string, len(pieces))
+	for i, p := range pieces {
+		parts[i] = p.text
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,79 @@
+package fileprocessor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkTextByTokensVersusRuneWindows(t *testing.T) {
+	fp := NewFileProcessor()
+
+	// Short words: about 4 tokens per 3 words, so 1000 runes hold more than 250 tokens
+	sparse := strings.Repeat("I am ok. It is a go. ", 200)
+	// Long words: about 4 characters per token
+	dense := strings.Repeat("Hypercholesterolemia necessitates pharmacological intervention. ", 80)
+
+	for _, tc := range []struct {
+		name string
+		text string
+	}{
+		{"sparse", sparse},
+		{"dense", dense},
+	} {
+		byRunes := fp.ChunkText(tc.text, 1000, 200)
+		byTokens := fp.ChunkTextByTokens(tc.text, 250, 50)
+
+		oversized := 0
+		for _, chunk := range byRunes {
+			if EstimateTokens(chunk) > 250 {
+				oversized++
+			}
+		}
+		for i, chunk := range byTokens {
+			if tokens := EstimateTokens(chunk); tokens > 250 {
+				t.Errorf("%s: token chunk %d has %d tokens, want at most 250", tc.name, i, tokens)
+			}
+			if !strings.HasSuffix(chunk, ".") {
+				t.Errorf("%s: token chunk %d doesn't end at a sentence boundary: %q", tc.name, i, chunk[max(0, len(chunk)-20):])
+			}
+		}
+
+		switch tc.name {
+		case "sparse":
+			// Rune windows overshoot the token limit, so token chunking needs more chunks
+			if oversized == 0 || len(byTokens) <= len(byRunes) {
+				t.Errorf("sparse: %d rune chunks (%d oversized) and %d token chunks, want more token chunks",
+					len(byRunes), oversized, len(byTokens))
+			}
+		case "dense":
+			// At about 4 characters per token both strategies need a similar number of chunks
+			if oversized != 0 || len(byTokens) < len(byRunes)-1 || len(byTokens) > len(byRunes)+1 {
+				t.Errorf("dense: %d rune chunks (%d oversized) and %d token chunks, want about the same",
+					len(byRunes), oversized, len(byTokens))
+			}
+		}
+	}
+}
+
+func TestChunkTextByTokensOverlapsAndUsesTokenCounter(t *testing.T) {
+	fp := NewFileProcessor()
+	fp.SetTokenCounter(func(text string) int { return len(strings.Fields(text)) })
+
+	text := "One two three. Four five six. Seven eight nine. Ten eleven twelve."
+	chunks := fp.ChunkTextByTokens(text, 6, 3)
+
+	want := []string{
+		"One two three. Four five six.",
+		"Four five six. Seven eight nine.",
+		"Seven eight nine. Ten eleven twelve.",
+	}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Errorf("chunks = %q, want %q", chunks, want)
+	}
+
+	// A sentence longer than the limit is split at word boundaries
+	chunks = fp.ChunkTextByTokens("a b c d e f g h", 3, 0)
+	if len(chunks) != 3 || chunks[0] != "a b c" || chunks[2] != "g h" {
+		t.Errorf("oversized sentence chunks = %q, want three word-bounded parts", chunks)
+	}
+}
@@ -0,0 +1,66 @@
+// Package retry retries operations that fail with transient errors, backing off exponentially
+// between attempts.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// retryableError marks an error as transient
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Retryable marks err as transient, so Do tries the operation again. It returns nil for nil.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// IsRetryable reports whether err, or any error it wraps, was marked with Retryable
+func IsRetryable(err error) bool {
+	var retryable *retryableError
+	return errors.As(err, &retryable)
+}
+
+// Do calls fn up to attempts times until it succeeds. Only errors marked with Retryable are
+// retried; any other error is returned immediately. The wait before retry n is backoff * 2^(n-1)
+// plus up to 50% jitter. If ctx is done while waiting, Do gives up and returns the last error
+// joined with the context's error.
+func Do(ctx context.Context, attempts int, backoff time.Duration, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			delay := backoff << (attempt - 1)
+			if delay > 0 {
+				delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+			}
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		if err = fn(); err == nil || !IsRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
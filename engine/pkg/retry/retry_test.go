@@ -0,0 +1,75 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("throttled")
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), 5, time.Millisecond, func() error {
+		calls++
+		if calls <= 2 {
+			return Retryable(errTransient)
+		}
+		return nil
+	})
+
+	if err != nil || calls != 3 {
+		t.Errorf("err = %v after %d calls, want success on the third", err, calls)
+	}
+}
+
+func TestDoReturnsNonRetryableErrorAtOnce(t *testing.T) {
+	permanent := errors.New("validation failed")
+	calls := 0
+	err := Do(context.Background(), 5, time.Millisecond, func() error {
+		calls++
+		return permanent
+	})
+
+	if !errors.Is(err, permanent) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want the error after one call", err, calls)
+	}
+}
+
+func TestDoStopsBackingOffWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- Do(ctx, 5, time.Hour, func() error {
+			calls++
+			return Retryable(errTransient)
+		})
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errTransient) || !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want the last error joined with context.Canceled", err)
+		}
+		if calls != 1 {
+			t.Errorf("%d calls, want 1", calls)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Do kept waiting after the context was cancelled")
+	}
+}
+
+func TestIsRetryableSeesWrappedErrors(t *testing.T) {
+	if Retryable(nil) != nil {
+		t.Error("Retryable(nil) is not nil")
+	}
+	if !IsRetryable(errors.Join(errors.New("batch write"), Retryable(errTransient))) {
+		t.Error("wrapped retryable error not detected")
+	}
+	if IsRetryable(errTransient) {
+		t.Error("unmarked error reported as retryable")
+	}
+}
@@ -26,7 +26,12 @@ func main() {
 		}
 
 		// Create logger
-		customLogger, err := logger.NewLogger(logger.LogMode(cfg.LogMode))
+		customLogger, err := logger.NewLogger(logger.LogMode(cfg.LogMode), logger.FileOptions{
+			Path:       cfg.LogFilePath,
+			MaxSizeMB:  cfg.LogMaxSizeMB,
+			MaxBackups: cfg.LogMaxBackups,
+			MaxAgeDays: cfg.LogMaxAgeDays,
+		})
 		if err != nil {
 			panic(err)
 		}